@@ -0,0 +1,249 @@
+// Package admin serves an HTTP API through which authorized clients push prefix inserts
+// and deletes to a table, e.g. from a central controller to every edge node. Operations
+// are written to an lpm.Journal, so they survive restarts, and published to an lpm.Atomic
+// as a snapshot after every batch, so lookups switch to the updated table atomically:
+//
+//	journal, err := lpm.OpenJournal("/var/lib/routes.lpm")
+//	if err != nil {
+//	    return err
+//	}
+//	table := lpm.NewAtomic(journal.LPM().Snapshot())
+//	http.Handle("/admin/", http.StripPrefix("/admin", admin.NewHandler(journal, table, admin.BearerToken(token))))
+//
+//	value, found := table.Lookup(addr)
+//
+// The API has three endpoints, all taking and returning JSON:
+//
+//	POST /v1/updates     apply an UpdateRequest, respond with an UpdateResponse
+//	POST /v1/checkpoint  fold the journal into a new snapshot file
+//	GET  /v1/status      respond with a Status
+//
+// Client pushes batches to one node; run one per edge to fan updates out.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sync"
+
+	"github.com/sakateka/lpm"
+)
+
+// maxRequestBytes bounds the size of a request body
+const maxRequestBytes = 64 << 20
+
+// Kinds of operations
+const (
+	OpInsert    = "insert"    // store Value for Prefix with Priority, see lpm.LPM.InsertWithPriority
+	OpTombstone = "tombstone" // carve Prefix out of broader prefixes, see lpm.LPM.InsertTombstone
+	OpDelete    = "delete"    // remove Prefix, see lpm.LPM.Delete
+)
+
+// Op is a single change of the table
+type Op struct {
+	Op       string       `json:"op"`
+	Prefix   netip.Prefix `json:"cidr"`
+	Value    string       `json:"value,omitempty"`
+	Priority uint8        `json:"priority,omitempty"`
+}
+
+// UpdateRequest is the body of POST /v1/updates. The operations are applied in order.
+type UpdateRequest struct {
+	Ops []Op `json:"ops"`
+}
+
+// UpdateResponse is the body of a successful POST /v1/updates
+type UpdateResponse struct {
+	Applied    int    `json:"applied"`    // operations applied
+	Deleted    int    `json:"deleted"`    // delete operations that found their prefix
+	Generation uint64 `json:"generation"` // generation of the published table
+}
+
+// Status is the body of GET /v1/status
+type Status struct {
+	Generation  uint64 `json:"generation"`   // generation of the published table
+	JournalSize int64  `json:"journal_size"` // bytes logged since the last checkpoint
+}
+
+// ErrorResponse is the body of failed requests. Applied tells how many operations of an
+// update were applied, and published, before the failing one.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Applied int    `json:"applied,omitempty"`
+}
+
+// Authorizer reports whether a request may use the API
+type Authorizer func(r *http.Request) bool
+
+// BearerToken authorizes requests carrying "Authorization: Bearer <token>"
+func BearerToken(token string) Authorizer {
+	want := []byte("Bearer " + token)
+	return func(r *http.Request) bool {
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) == 1
+	}
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// CheckpointSize makes the handler checkpoint the journal after an update grows its log
+// past the given number of bytes
+func CheckpointSize(bytes int64) Option {
+	return func(h *Handler) {
+		h.checkpointSize = bytes
+	}
+}
+
+// Handler serves the admin API, see the package documentation
+type Handler struct {
+	journal        *lpm.Journal
+	table          *lpm.Atomic
+	authorize      Authorizer
+	checkpointSize int64
+
+	mu  sync.Mutex // serializes changes of the journal
+	mux *http.ServeMux
+}
+
+// NewHandler returns a handler applying updates to journal and publishing them to table.
+// Requests that authorize rejects fail with 401 Unauthorized; authorize must not be nil.
+// The trie of the journal must not be modified by others while the handler is in use.
+func NewHandler(journal *lpm.Journal, table *lpm.Atomic, authorize Authorizer, opts ...Option) *Handler {
+	if authorize == nil {
+		panic("admin: nil Authorizer")
+	}
+	h := &Handler{
+		journal:   journal,
+		table:     table,
+		authorize: authorize,
+		mux:       http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("POST /v1/updates", h.updates)
+	h.mux.HandleFunc("POST /v1/checkpoint", h.checkpoint)
+	h.mux.HandleFunc("GET /v1/status", h.status)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) updates(w http.ResponseWriter, r *http.Request) {
+	var req UpdateRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	// Reject malformed batches before applying any of it
+	for i, op := range req.Ops {
+		if err := op.validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("op %d: %v", i, err)})
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	resp := UpdateResponse{}
+	err := h.apply(req.Ops, &resp)
+	if err == nil {
+		err = h.journal.Sync()
+	}
+	// Publish what was applied even if the batch failed halfway, as it is in the journal
+	if resp.Applied > 0 {
+		h.table.Store(h.journal.LPM().Snapshot())
+	}
+	resp.Generation = h.journal.LPM().Generation()
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, lpm.ErrInvalidPrefix) || errors.Is(err, lpm.ErrCapacity) ||
+			errors.Is(err, lpm.ErrMemoryLimit) || errors.Is(err, lpm.ErrReadOnly) {
+			code = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, code, ErrorResponse{Error: err.Error(), Applied: resp.Applied})
+		return
+	}
+	if h.checkpointSize > 0 && h.journal.Size() > h.checkpointSize {
+		if err := h.journal.Checkpoint(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: fmt.Sprintf("checkpoint: %v", err), Applied: resp.Applied})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// apply applies the operations to the journal, counting them in resp
+func (h *Handler) apply(ops []Op, resp *UpdateResponse) error {
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case OpInsert:
+			err = h.journal.InsertWithPriority(op.Prefix, op.Value, op.Priority)
+		case OpTombstone:
+			err = h.journal.InsertTombstone(op.Prefix)
+		case OpDelete:
+			var deleted bool
+			deleted, err = h.journal.Delete(op.Prefix)
+			if deleted {
+				resp.Deleted++
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("op %d: %w", i, err)
+		}
+		resp.Applied++
+	}
+	return nil
+}
+
+func (op Op) validate() error {
+	switch op.Op {
+	case OpInsert, OpTombstone, OpDelete:
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	if !op.Prefix.IsValid() {
+		return fmt.Errorf("%w: missing cidr", lpm.ErrInvalidPrefix)
+	}
+	return nil
+}
+
+func (h *Handler) checkpoint(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.journal.Checkpoint(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Status{Generation: h.journal.LPM().Generation()})
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, Status{Generation: h.journal.LPM().Generation(), JournalSize: h.journal.Size()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+const testToken = "secret"
+
+// newTestNode serves the admin API of a journaled table and returns a client of it
+func newTestNode(t *testing.T, opts ...Option) (*Client, *lpm.Atomic, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "routes.lpm")
+	journal, err := lpm.OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	t.Cleanup(func() { _ = journal.Close() })
+
+	table := lpm.NewAtomic(journal.LPM().Snapshot())
+	srv := httptest.NewServer(NewHandler(journal, table, BearerToken(testToken), opts...))
+	t.Cleanup(srv.Close)
+	return &Client{URL: srv.URL, Token: testToken, HTTPClient: srv.Client()}, table, path
+}
+
+// TestPush tests that pushed updates are published and survive a restart
+func TestPush(t *testing.T) {
+	ctx := context.Background()
+	client, table, path := newTestNode(t)
+
+	resp, err := client.Push(ctx, []Op{
+		{Op: OpInsert, Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "private"},
+		{Op: OpInsert, Prefix: netip.MustParsePrefix("10.1.0.0/16"), Value: "east"},
+		{Op: OpTombstone, Prefix: netip.MustParsePrefix("10.2.0.0/16")},
+		{Op: OpDelete, Prefix: netip.MustParsePrefix("10.1.0.0/16")},
+		{Op: OpDelete, Prefix: netip.MustParsePrefix("192.0.2.0/24")},
+	})
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if resp.Applied != 5 || resp.Deleted != 1 || resp.Generation != table.Load().Generation() {
+		t.Errorf("Push = %+v, want 5 applied, 1 deleted, generation %d", resp, table.Load().Generation())
+	}
+	for addr, want := range map[string]string{"10.1.2.3": "private", "10.2.0.1": "", "192.0.2.1": ""} {
+		if got, _ := table.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Generation != resp.Generation || status.JournalSize == 0 {
+		t.Errorf("Status = %+v, want generation %d and a non-empty journal", status, resp.Generation)
+	}
+
+	recovered, err := lpm.OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	defer recovered.Close()
+	if recovered.LPM().Fingerprint() != table.Load().Fingerprint() {
+		t.Error("table recovered from the journal differs from the published one")
+	}
+
+	if err := client.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if status, _ := client.Status(ctx); status.JournalSize != 0 {
+		t.Errorf("JournalSize after Checkpoint = %d, want 0", status.JournalSize)
+	}
+}
+
+// TestPushErrors tests rejected requests and batches failing halfway
+func TestPushErrors(t *testing.T) {
+	ctx := context.Background()
+	client, table, _ := newTestNode(t)
+
+	var apiErr *Error
+	unauthorized := &Client{URL: client.URL, Token: "wrong", HTTPClient: client.HTTPClient}
+	if _, err := unauthorized.Push(ctx, nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Push with a wrong token error = %v, want HTTP 401", err)
+	}
+
+	malformed := []Op{
+		{Op: OpInsert, Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "a"},
+		{Op: "upsert", Prefix: netip.MustParsePrefix("10.1.0.0/16")},
+	}
+	if _, err := client.Push(ctx, malformed); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Push of an unknown op error = %v, want HTTP 400", err)
+	}
+	if _, err := client.Push(ctx, []Op{{Op: OpDelete}}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Push without a prefix error = %v, want HTTP 400", err)
+	}
+	if _, found := table.Lookup(netip.MustParseAddr("10.0.0.1")); found {
+		t.Error("a malformed batch was partially applied")
+	}
+}
+
+// TestPushRejectedPrefix tests that prefixes the table rejects are client errors
+func TestPushRejectedPrefix(t *testing.T) {
+	journal, err := lpm.OpenJournal(filepath.Join(t.TempDir(), "routes.lpm"), lpm.IPv6Max64())
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	t.Cleanup(func() { _ = journal.Close() })
+	srv := httptest.NewServer(NewHandler(journal, lpm.NewAtomic(journal.LPM().Snapshot()), BearerToken(testToken)))
+	t.Cleanup(srv.Close)
+	client := &Client{URL: srv.URL, Token: testToken, HTTPClient: srv.Client()}
+
+	var apiErr *Error
+	_, err = client.Push(context.Background(), []Op{
+		{Op: OpInsert, Prefix: netip.MustParsePrefix("2001:db8::/32"), Value: "doc"},
+		{Op: OpInsert, Prefix: netip.MustParsePrefix("2001:db8::1/128"), Value: "host"},
+	})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Applied != 1 {
+		t.Errorf("Push of a prefix longer than /64 error = %v, want HTTP 422 after 1 applied op", err)
+	}
+}
+
+// TestCheckpointSize tests that updates checkpoint a journal grown past the limit
+func TestCheckpointSize(t *testing.T) {
+	ctx := context.Background()
+	client, _, _ := newTestNode(t, CheckpointSize(64))
+
+	if _, err := client.Push(ctx, []Op{{Op: OpInsert, Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "a"}}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if status, _ := client.Status(ctx); status.JournalSize == 0 {
+		t.Error("journal below the limit was checkpointed")
+	}
+	ops := make([]Op, 8)
+	for i := range ops {
+		ops[i] = Op{Op: OpInsert, Prefix: netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 0}), 16), Value: "b"}
+	}
+	if _, err := client.Push(ctx, ops); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if status, _ := client.Status(ctx); status.JournalSize != 0 {
+		t.Errorf("JournalSize = %d, want a checkpoint past the limit", status.JournalSize)
+	}
+}
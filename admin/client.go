@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Client pushes updates to the admin API of one node
+type Client struct {
+	URL        string       // base URL of the API, e.g. https://edge1:8443/admin
+	Token      string       // sent as a bearer token, see BearerToken
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+// Push applies the operations on the node. If the node fails the batch halfway, the
+// error is an *Error telling how many operations were applied.
+func (c *Client) Push(ctx context.Context, ops []Op) (UpdateResponse, error) {
+	var resp UpdateResponse
+	err := c.do(ctx, http.MethodPost, "/v1/updates", UpdateRequest{Ops: ops}, &resp)
+	return resp, err
+}
+
+// Checkpoint folds the journal of the node into a new snapshot file
+func (c *Client) Checkpoint(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/checkpoint", nil, &Status{})
+}
+
+// Status returns the generation and journal size of the node
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodGet, "/v1/status", nil, &status)
+	return status, err
+}
+
+// Error is a request the API failed
+type Error struct {
+	StatusCode int    // HTTP status of the response
+	Message    string // error reported by the API
+	Applied    int    // operations of an update applied before the failing one
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("admin API: %s (HTTP %d, %d ops applied)", e.Message, e.StatusCode, e.Applied)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failed ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&failed); err != nil || failed.Error == "" {
+			failed.Error = resp.Status
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: failed.Error, Applied: failed.Applied}
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
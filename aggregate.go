@@ -0,0 +1,124 @@
+package lpm
+
+import "slices"
+
+// noValue is the ORTC value of addresses without a match
+const noValue = -1
+
+// ortcNode is a node of the binary trie the ORTC passes work on. Leaves cover runs of
+// addresses mapping to the same value.
+type ortcNode struct {
+	children [2]*ortcNode // nil for leaves
+	values   []int        // sorted candidate values the subtree can inherit at no cost
+}
+
+// Aggregate returns the smallest set of entries mapping every address like the trie does,
+// as computed by the Optimal Routing Table Constructor (Draves et al., 1999): adjacent and
+// nested prefixes with equal values are merged, e.g. 256 contiguous /24s into one /16, and
+// a hole in a range is kept as a tombstone where that takes fewer prefixes than splitting
+// the range. Prefixes with equal values but different priorities are merged as well, so the
+// entries carry no priorities.
+//
+// Use it on a finished table before packing, e.g. Build(m.Aggregate()): the result answers
+// every lookup the same, but inserts into it may resolve differently, as the original
+// prefixes and priorities are gone.
+func (m *LPM) Aggregate() []PrefixValue {
+	var names []string
+	ids := make(map[string]int)
+	byIndex := make(map[int]int)
+	id := func(encoded uint32) int {
+		valueIdx, _ := decodeValue(encoded)
+		if isInvalid(encoded) || valueIdx == tombstoneIdx {
+			return noValue
+		}
+		if v, ok := byIndex[valueIdx]; ok {
+			return v
+		}
+		value, _ := m.getValueByIndex(valueIdx)
+		v, ok := ids[value]
+		if !ok {
+			v = len(names)
+			ids[value] = v
+			names = append(names, value)
+		}
+		byIndex[valueIdx] = v
+		return v
+	}
+
+	var result []PrefixValue
+	for _, proto := range []int{v4LPM, v6LPM} {
+		if m.blockCount(proto) == 0 {
+			continue
+		}
+		root := m.ortcBlock(proto, m.root[proto], 0, blockSize, id)
+		var addr [16]byte
+		root.emit(noValue, &addr, 0, func(bits int, v int) {
+			e := PrefixValue{Prefix: prefixFromPath(proto, addr, bits)}
+			if v == noValue {
+				e.Tombstone = true
+			} else {
+				e.Value = names[v]
+			}
+			result = append(result, e)
+		})
+	}
+	return result
+}
+
+// ortcBlock builds the subtree covering span slots of a block from slot lo and computes the
+// candidate values of every node bottom up
+func (t *trie) ortcBlock(proto int, blockIdx int, lo, span int, id func(uint32) int) *ortcNode {
+	blk := t.getBlockRef(proto, blockIdx)
+	first := blk[lo]
+	if isBlockRef(first) && span == 1 {
+		return t.ortcBlock(proto, decodeBlockRef(first), 0, blockSize, id)
+	}
+	if !isBlockRef(first) {
+		uniform := true
+		for _, encoded := range blk[lo+1 : lo+span] {
+			if encoded != first {
+				uniform = false
+				break
+			}
+		}
+		if uniform {
+			return &ortcNode{values: []int{id(first)}}
+		}
+	}
+
+	left := t.ortcBlock(proto, blockIdx, lo, span/2, id)
+	right := t.ortcBlock(proto, blockIdx, lo+span/2, span/2, id)
+	node := &ortcNode{children: [2]*ortcNode{left, right}}
+	for _, v := range left.values {
+		if _, found := slices.BinarySearch(right.values, v); found {
+			node.values = append(node.values, v)
+		}
+	}
+	if len(node.values) == 0 {
+		node.values = append(slices.Clone(left.values), right.values...)
+		slices.Sort(node.values)
+		node.values = slices.Compact(node.values)
+	}
+	return node
+}
+
+// emit walks the subtree top down and reports a prefix wherever the value chosen for a
+// node differs from the one it inherits
+func (n *ortcNode) emit(inherited int, addr *[16]byte, bits int, fn func(bits int, v int)) {
+	chosen := inherited
+	if _, found := slices.BinarySearch(n.values, inherited); !found {
+		// Prefer a value to a tombstone
+		chosen = n.values[0]
+		if chosen == noValue && len(n.values) > 1 {
+			chosen = n.values[1]
+		}
+		fn(bits, chosen)
+	}
+	if n.children[0] == nil {
+		return
+	}
+	n.children[0].emit(chosen, addr, bits+1, fn)
+	addr[bits/8] |= 0x80 >> (bits % 8)
+	n.children[1].emit(chosen, addr, bits+1, fn)
+	addr[bits/8] &^= 0x80 >> (bits % 8)
+}
@@ -0,0 +1,12 @@
+package lpm
+
+// storageAlign is the alignment of buffers from AlignedBuffer: blocks are accessed in
+// place as uint32 arrays, and the seqlock sequence as a uint64
+const storageAlign = 8
+
+// alignedClone copies b into an aligned buffer
+func alignedClone(b []byte) []byte {
+	buf := AlignedBuffer(len(b))
+	copy(buf, b)
+	return buf
+}
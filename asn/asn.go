@@ -0,0 +1,76 @@
+// Package asn maps prefixes to the AS originating them, the most common use of an LPM
+// table: a Table stores origin ASNs as integers in an lpm.U32 trie, with the names of the
+// ASes in a side table, and loads both from public datasets:
+//
+//	table, err := asn.LoadPfx2as(pfx2as) // routeviews-rv2-20240101-1200.pfx2as.gz
+//	err = table.LoadNames(asnames)       // https://ftp.ripe.net/ripe/asnames/asn.txt
+//
+//	asn, name, ok := table.LookupASN(addr) // 13335, "CLOUDFLARENET - Cloudflare, Inc., US", true
+//
+// Origins are also loaded from RIPE RIS whois dumps with LoadRISWhois. For the GeoLite2
+// ASN database, see the mmdb package.
+package asn
+
+import (
+	"net/netip"
+
+	"github.com/sakateka/lpm"
+)
+
+// Table maps prefixes to origin ASNs and ASNs to AS names. Like the tries it is built on,
+// it may be read concurrently, but not while being modified.
+type Table struct {
+	prefixes *lpm.U32
+	names    map[uint32]string
+}
+
+// New creates an empty table
+func New() *Table {
+	return &Table{prefixes: lpm.NewU32(), names: make(map[uint32]string)}
+}
+
+// Insert maps the prefix to the origin asn, failing like lpm.U32.Insert
+func (t *Table) Insert(prefix netip.Prefix, asn uint32) error {
+	return t.prefixes.Insert(prefix, asn)
+}
+
+// SetName sets the name of the AS
+func (t *Table) SetName(asn uint32, name string) {
+	t.names[asn] = name
+}
+
+// Name returns the name of the AS, or an empty string if it has none
+func (t *Table) Name(asn uint32) string {
+	return t.names[asn]
+}
+
+// Names returns the number of ASes with a name
+func (t *Table) Names() int {
+	return len(t.names)
+}
+
+// Lookup returns the origin ASN of the longest prefix containing addr
+func (t *Table) Lookup(addr netip.Addr) (uint32, bool) {
+	return t.prefixes.Lookup(addr)
+}
+
+// LookupASN returns the origin ASN of the longest prefix containing addr along with the
+// name of the AS, empty if it has none
+func (t *Table) LookupASN(addr netip.Addr) (asn uint32, name string, ok bool) {
+	asn, ok = t.prefixes.Lookup(addr)
+	if !ok {
+		return 0, "", false
+	}
+	return asn, t.names[asn], true
+}
+
+// Compact collapses blocks of the trie in which every address maps to the same ASN, see
+// lpm.LPM.Compact. Call it once all prefixes are inserted.
+func (t *Table) Compact() int {
+	return t.prefixes.Compact()
+}
+
+// Trie returns the trie of origin ASNs, e.g. for Stats or lookups by integer address
+func (t *Table) Trie() *lpm.U32 {
+	return t.prefixes
+}
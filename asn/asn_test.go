@@ -0,0 +1,138 @@
+package asn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const (
+	pfx2as = "1.0.0.0\t24\t13335\n" +
+		"1.0.4.0\t22\t38803_56203\n" +
+		"10.0.0.0\t8\t64500,64501\n" +
+		"10.1.0.0\t16\t64502\n" +
+		"\n" +
+		"2001:db8::\t32\t64503\n"
+	risWhois = "% This file is made available by the RIPE NCC\n" +
+		"%\n" +
+		"13335\t1.1.1.0/24\t342\n" +
+		"64500\t1.1.1.0/24\t3\n" +
+		"64501\t1.1.1.0/24\t400\n" +
+		"{64502,64503}\t192.0.2.0/24\t10\n" +
+		"64504\t2001:db8::/32\t300\n"
+	asnames = "13335 CLOUDFLARENET - Cloudflare, Inc., US\n" +
+		"38803 WPL-AS-AP Wirefreebroadband Pty Ltd, AU\n" +
+		"AS64501 EXAMPLE\n"
+)
+
+func gzipped(t *testing.T, s string) io.Reader {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+type lookup struct {
+	asn  uint32
+	name string
+}
+
+func checkLookups(t *testing.T, table *Table, want map[string]lookup) {
+	t.Helper()
+	for addr, w := range want {
+		asn, name, ok := table.LookupASN(netip.MustParseAddr(addr))
+		if asn != w.asn || name != w.name || ok != (w.asn != 0) {
+			t.Errorf("LookupASN(%s) = %d, %q, %v, want %d, %q", addr, asn, name, ok, w.asn, w.name)
+		}
+	}
+}
+
+// TestLoadPfx2as tests loading RouteViews files, plain and gzipped, and AS names
+func TestLoadPfx2as(t *testing.T) {
+	for name, r := range map[string]func() io.Reader{
+		"plain":   func() io.Reader { return strings.NewReader(pfx2as) },
+		"gzipped": func() io.Reader { return gzipped(t, pfx2as) },
+	} {
+		table, err := LoadPfx2as(r())
+		if err != nil {
+			t.Fatalf("%s: LoadPfx2as failed: %v", name, err)
+		}
+		if err := table.LoadNames(gzipped(t, asnames)); err != nil {
+			t.Fatalf("%s: LoadNames failed: %v", name, err)
+		}
+		if table.Names() != 3 {
+			t.Errorf("%s: Names() = %d, want 3", name, table.Names())
+		}
+		checkLookups(t, table, map[string]lookup{
+			"1.0.0.1":     {13335, "CLOUDFLARENET - Cloudflare, Inc., US"},
+			"1.0.5.1":     {38803, "WPL-AS-AP Wirefreebroadband Pty Ltd, AU"},
+			"10.2.0.1":    {64500, ""},
+			"10.1.0.1":    {64502, ""},
+			"2001:db8::1": {64503, ""},
+			"8.8.8.8":     {},
+		})
+	}
+
+	for _, input := range []string{
+		"1.0.0.0\t24\n",
+		"1.0.0.0\t33\t13335\n",
+		"1.0.0.0\t24\tAS-FOO\n",
+		"1.0.0.0\t24\t4294967296\n",
+	} {
+		if _, err := LoadPfx2as(strings.NewReader(input)); err == nil {
+			t.Errorf("LoadPfx2as(%q) succeeded", input)
+		}
+	}
+}
+
+// TestLoadRISWhois tests picking the origin seen by the most peers
+func TestLoadRISWhois(t *testing.T) {
+	table, err := LoadRISWhois(gzipped(t, risWhois))
+	if err != nil {
+		t.Fatalf("LoadRISWhois failed: %v", err)
+	}
+	if err := table.LoadNames(strings.NewReader(asnames)); err != nil {
+		t.Fatalf("LoadNames failed: %v", err)
+	}
+	checkLookups(t, table, map[string]lookup{
+		"1.1.1.1":     {64501, "EXAMPLE"},
+		"192.0.2.1":   {},
+		"2001:db8::1": {64504, ""},
+	})
+
+	if _, err := LoadRISWhois(strings.NewReader("13335\t1.1.1.0/24\tmany\n")); err == nil {
+		t.Error("LoadRISWhois of a malformed peer count succeeded")
+	}
+	if err := table.LoadNames(strings.NewReader("x EXAMPLE\n")); err == nil {
+		t.Error("LoadNames of a malformed ASN succeeded")
+	}
+}
+
+// TestTable tests building a table by hand
+func TestTable(t *testing.T) {
+	table := New()
+	if err := table.Insert(netip.MustParsePrefix("192.0.2.0/24"), 64500); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Insert(netip.Prefix{}, 64501); err == nil {
+		t.Error("Insert of an invalid prefix succeeded")
+	}
+	table.SetName(64500, "EXAMPLE")
+	if asn, ok := table.Lookup(netip.MustParseAddr("192.0.2.1")); asn != 64500 || !ok {
+		t.Errorf("Lookup = %d, %v, want 64500", asn, ok)
+	}
+	if got := table.Name(64500); got != "EXAMPLE" {
+		t.Errorf("Name = %q, want EXAMPLE", got)
+	}
+	if got := table.Trie().Stats().Values; got != 1 {
+		t.Errorf("Stats().Values = %d, want 1", got)
+	}
+}
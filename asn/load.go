@@ -0,0 +1,165 @@
+package asn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// gzipMagic starts gzip streams, which the datasets are usually distributed as
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// lines calls fn with every line of r, decompressing r if it is gzipped. An error returned
+// by fn stops reading and is returned with the line number.
+func lines(r io.Reader, fn func(line string) error) error {
+	br := bufio.NewReaderSize(r, 1<<16)
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("asn: %w", err)
+		}
+		defer zr.Close()
+		br = bufio.NewReaderSize(zr, 1<<16)
+	}
+	scanner := bufio.NewScanner(br)
+	for n := 1; scanner.Scan(); n++ {
+		if err := fn(scanner.Text()); err != nil {
+			return fmt.Errorf("asn: line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("asn: %w", err)
+	}
+	return nil
+}
+
+// parseASN parses an ASN in decimal, with or without an "AS" prefix
+func parseASN(s string) (uint32, error) {
+	asn, err := strconv.ParseUint(strings.TrimPrefix(s, "AS"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q", s)
+	}
+	return uint32(asn), nil
+}
+
+// LoadPfx2as loads a CAIDA RouteViews prefix-to-AS file, plain or gzipped, whose lines
+// hold an address, a prefix length and the origin separated by tabs:
+//
+//	1.0.0.0	24	13335
+//
+// Prefixes originated by several ASes ("13335_209242") or by an AS set ("64500,64501")
+// are mapped to the first of them. The trie is compacted at the end.
+func LoadPfx2as(r io.Reader) (*Table, error) {
+	t := New()
+	err := lines(r, func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		if len(fields) != 3 {
+			return fmt.Errorf("malformed line %q", line)
+		}
+		prefix, err := netip.ParsePrefix(fields[0] + "/" + fields[1])
+		if err != nil {
+			return err
+		}
+		origin, _, _ := strings.Cut(fields[2], "_")
+		origin, _, _ = strings.Cut(origin, ",")
+		asn, err := parseASN(origin)
+		if err != nil {
+			return err
+		}
+		return t.Insert(prefix, asn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Compact()
+	return t, nil
+}
+
+// LoadRISWhois loads a RIPE RIS whois dump, plain or gzipped, such as
+// riswhoisdump.IPv4.gz, whose lines hold an origin, a prefix and the number of RIS peers
+// seeing the route, skipping comments starting with '%':
+//
+//	13335	1.1.1.0/24	342
+//
+// Prefixes announced by several origins are mapped to the one seen by the most peers, the
+// first of them on ties. Origins that are AS sets ("{64500,64501}") are skipped. The trie
+// is compacted at the end.
+func LoadRISWhois(r io.Reader) (*Table, error) {
+	type route struct {
+		asn  uint32
+		seen int
+	}
+	best := make(map[netip.Prefix]route)
+	var order []netip.Prefix
+	err := lines(r, func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "%") {
+			return nil
+		}
+		if len(fields) != 3 {
+			return fmt.Errorf("malformed line %q", line)
+		}
+		if strings.HasPrefix(fields[0], "{") {
+			return nil
+		}
+		asn, err := parseASN(fields[0])
+		if err != nil {
+			return err
+		}
+		prefix, err := netip.ParsePrefix(fields[1])
+		if err != nil {
+			return err
+		}
+		seen, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("invalid peer count %q", fields[2])
+		}
+		prefix = prefix.Masked()
+		current, ok := best[prefix]
+		if !ok {
+			order = append(order, prefix)
+		}
+		if !ok || seen > current.seen {
+			best[prefix] = route{asn, seen}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	t := New()
+	for _, prefix := range order {
+		if err := t.Insert(prefix, best[prefix].asn); err != nil {
+			return nil, fmt.Errorf("asn: %s: %w", prefix, err)
+		}
+	}
+	t.Compact()
+	return t, nil
+}
+
+// LoadNames loads AS names from a list such as the asn.txt of RIPE, plain or gzipped,
+// whose lines hold an ASN and the name of the AS separated by a space:
+//
+//	13335 CLOUDFLARENET - Cloudflare, Inc., US
+func (t *Table) LoadNames(r io.Reader) error {
+	return lines(r, func(line string) error {
+		number, name, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if number == "" {
+			return nil
+		}
+		asn, err := parseASN(number)
+		if err != nil {
+			return err
+		}
+		t.SetName(asn, strings.TrimSpace(name))
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package lpm
+
+import (
+	"net/netip"
+	"sync/atomic"
+)
+
+// Atomic holds an LPM that a writer replaces as a whole while readers keep looking up
+// addresses without locks. The writer builds or loads a new trie, e.g. by copying the
+// current one, and publishes it with Store; readers see either the old or the new trie.
+// A trie must not be modified once stored. The zero value holds no trie and its lookups
+// report no match.
+//
+//	var table lpm.Atomic
+//	table.Store(initial)
+//
+//	// Readers, from any number of goroutines
+//	value, found := table.Lookup(addr)
+//
+//	// Writer
+//	next, err := lpm.LoadFromFile(path)
+//	if err == nil {
+//	    table.Store(next)
+//	}
+type Atomic struct {
+	p atomic.Pointer[LPM]
+}
+
+// NewAtomic returns an Atomic holding m
+func NewAtomic(m *LPM) *Atomic {
+	a := &Atomic{}
+	a.p.Store(m)
+	return a
+}
+
+// Load returns the current trie, or nil if none was stored
+func (a *Atomic) Load() *LPM {
+	return a.p.Load()
+}
+
+// Store publishes m as the current trie
+func (a *Atomic) Store(m *LPM) {
+	a.p.Store(m)
+}
+
+// Swap publishes m as the current trie and returns the previous one, e.g. to release
+// its storage once readers are done with it
+func (a *Atomic) Swap(m *LPM) *LPM {
+	return a.p.Swap(m)
+}
+
+// Lookup looks up addr in the current trie, see LPM.Lookup
+func (a *Atomic) Lookup(addr netip.Addr) (string, bool) {
+	m := a.p.Load()
+	if m == nil {
+		return "", false
+	}
+	return m.Lookup(addr)
+}
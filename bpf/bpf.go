@@ -0,0 +1,239 @@
+// Package bpf exports LPM tables into BPF_MAP_TYPE_LPM_TRIE maps of the Linux kernel and
+// keeps them in sync, so the same dataset drives userspace lookups and XDP or TC programs:
+//
+//	v4, err := bpf.NewMap("routes_v4", false, 4, 1<<20)
+//	if err != nil {
+//	    return err
+//	}
+//	defer v4.Close()
+//	if err := v4.Pin("/sys/fs/bpf/routes_v4"); err != nil {
+//	    return err
+//	}
+//
+//	exporter := bpf.NewExporter(v4, nil, bpf.Uint32)
+//	syncer, err := bpf.NewSyncer(ctx, exporter, &table, time.Second)
+//
+// BPF LPM tries know no tombstones or priorities, so the maps receive the effective
+// entries of the table (see lpm.LPM.EffectiveEntries): disjoint prefixes a BPF lookup
+// resolves exactly like the table. The maps are written through the bpf(2) syscall, so
+// exporting only works on Linux, by processes with CAP_BPF or CAP_SYS_ADMIN; elsewhere
+// creating or opening a map fails with errors.ErrUnsupported.
+package bpf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// Encoder converts a value of the table to the bytes stored in the map, which must be
+// exactly as long as the values of the map
+type Encoder func(value string) ([]byte, error)
+
+// Uint32 encodes decimal values as 32-bit integers in host byte order, the way a BPF
+// program reads a __u32 value
+func Uint32(value string) ([]byte, error) {
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return binary.NativeEndian.AppendUint32(nil, uint32(n)), nil
+}
+
+// String returns an Encoder storing values as NUL-padded strings of size bytes, rejecting
+// longer values
+func String(size int) Encoder {
+	return func(value string) ([]byte, error) {
+		if len(value) > size {
+			return nil, fmt.Errorf("value of %d bytes exceeds %d", len(value), size)
+		}
+		b := make([]byte, size)
+		copy(b, value)
+		return b, nil
+	}
+}
+
+// Exporter writes tables into a map of IPv4 prefixes and a map of IPv6 prefixes. It
+// remembers what it wrote, so syncing a changed table only touches the prefixes that
+// changed. An Exporter is not safe for concurrent use.
+type Exporter struct {
+	v4, v6 *Map
+	encode Encoder
+
+	written map[netip.Prefix][]byte // entries of the maps, nil until the maps are read
+}
+
+// NewExporter returns an Exporter writing IPv4 prefixes to v4 and IPv6 prefixes to v6.
+// Either map may be nil to leave the prefixes of its family out.
+func NewExporter(v4, v6 *Map, encode Encoder) *Exporter {
+	return &Exporter{v4: v4, v6: v6, encode: encode}
+}
+
+// Sync makes the maps hold the effective entries of m and nothing else. Entries are
+// stored before stale ones are deleted, so BPF lookups see either the old or the new
+// value of an address but never miss it in between; the maps need room for both while
+// a large change is applied. The first Sync deletes whatever else the maps hold, e.g.
+// entries of a previous run left in pinned maps.
+func (e *Exporter) Sync(m *lpm.LPM) error {
+	want := make(map[netip.Prefix][]byte)
+	for _, entry := range m.EffectiveEntries() {
+		if e.mapOf(entry.Prefix) == nil {
+			continue
+		}
+		value, err := e.encode(entry.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Prefix, err)
+		}
+		want[entry.Prefix] = value
+	}
+
+	if e.written == nil {
+		written, err := e.read()
+		if err != nil {
+			return err
+		}
+		e.written = written
+	}
+	// A failed write leaves the maps in an unknown state, so the next Sync reads them again
+	ok := false
+	defer func() {
+		if !ok {
+			e.written = nil
+		}
+	}()
+
+	for prefix, value := range want {
+		if old, found := e.written[prefix]; found && bytes.Equal(old, value) {
+			continue
+		}
+		if err := e.mapOf(prefix).Update(prefix, value); err != nil {
+			return err
+		}
+		e.written[prefix] = value
+	}
+	for prefix := range e.written {
+		if _, found := want[prefix]; found {
+			continue
+		}
+		if _, err := e.mapOf(prefix).Delete(prefix); err != nil {
+			return err
+		}
+		delete(e.written, prefix)
+	}
+	ok = true
+	return nil
+}
+
+// read returns the prefixes the maps hold, with values that match no encoded value
+func (e *Exporter) read() (map[netip.Prefix][]byte, error) {
+	written := make(map[netip.Prefix][]byte)
+	for _, m := range []*Map{e.v4, e.v6} {
+		if m == nil {
+			continue
+		}
+		prefixes, err := m.Prefixes()
+		if err != nil {
+			return nil, err
+		}
+		for _, prefix := range prefixes {
+			written[prefix] = nil
+		}
+	}
+	return written, nil
+}
+
+// mapOf returns the map of the family of the prefix, or nil if there is none
+func (e *Exporter) mapOf(prefix netip.Prefix) *Map {
+	if prefix.Addr().Is4() {
+		return e.v4
+	}
+	return e.v6
+}
+
+// Source provides the current table, like lpm.Atomic, lpm.Refresher or fib.Monitor
+type Source interface {
+	Load() *lpm.LPM
+}
+
+// Syncer keeps maps in sync with the table of a source: it checks the source every
+// interval and syncs the maps whenever a different table was published.
+type Syncer struct {
+	exporter *Exporter
+	source   Source
+	interval time.Duration
+
+	mu     sync.Mutex // guards synced, err and the exporter
+	synced *lpm.LPM   // table the maps hold
+	err    error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSyncer syncs the maps of the exporter with the current table of the source and keeps
+// them in sync until Close is called or ctx is done
+func NewSyncer(ctx context.Context, exporter *Exporter, source Source, interval time.Duration) (*Syncer, error) {
+	s := &Syncer{
+		exporter: exporter,
+		source:   source,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	if err := s.Sync(); err != nil {
+		return nil, err
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.run(ctx)
+	return s, nil
+}
+
+// Sync checks the source right away and syncs the maps if the table changed
+func (s *Syncer) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	table := s.source.Load()
+	if table == nil || table == s.synced {
+		return nil
+	}
+	s.err = s.exporter.Sync(table)
+	if s.err == nil {
+		s.synced = table
+	}
+	return s.err
+}
+
+// Err returns the error of the last sync, or nil if it succeeded. Failed syncs are
+// retried every interval.
+func (s *Syncer) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops syncing. The maps keep the entries of the last synced table.
+func (s *Syncer) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *Syncer) run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sync()
+		}
+	}
+}
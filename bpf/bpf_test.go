@@ -0,0 +1,27 @@
+package bpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// TestEncoders tests the integer and string encoders
+func TestEncoders(t *testing.T) {
+	if got, err := Uint32("4000000000"); err != nil || binary.NativeEndian.Uint32(got) != 4000000000 {
+		t.Errorf("Uint32(4000000000) = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "-1", "4294967296", "eth0"} {
+		if _, err := Uint32(bad); err == nil {
+			t.Errorf("Uint32(%q) succeeded", bad)
+		}
+	}
+
+	encode := String(4)
+	if got, err := encode("ab"); err != nil || !bytes.Equal(got, []byte{'a', 'b', 0, 0}) {
+		t.Errorf("String(4)(ab) = %v, %v", got, err)
+	}
+	if _, err := encode("abcde"); err == nil {
+		t.Error("String(4)(abcde) succeeded")
+	}
+}
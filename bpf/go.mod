@@ -0,0 +1,10 @@
+module github.com/sakateka/lpm/bpf
+
+go 1.25.1
+
+require (
+	github.com/sakateka/lpm v0.1.0
+	golang.org/x/sys v0.35.0
+)
+
+require github.com/klauspost/compress v1.18.0 // indirect
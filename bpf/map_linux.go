@@ -0,0 +1,293 @@
+package bpf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Map is a BPF_MAP_TYPE_LPM_TRIE map holding prefixes of one address family
+type Map struct {
+	fd        int
+	ipv6      bool
+	valueSize int
+}
+
+// mapCreateAttr is the union bpf_attr of BPF_MAP_CREATE
+type mapCreateAttr struct {
+	mapType    uint32
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+	mapFlags   uint32
+	innerMapFD uint32
+	numaNode   uint32
+	mapName    [unix.BPF_OBJ_NAME_LEN]byte
+}
+
+// mapElemAttr is the union bpf_attr of the BPF_MAP_*_ELEM commands and
+// BPF_MAP_GET_NEXT_KEY, where value holds the next key
+type mapElemAttr struct {
+	mapFD uint32
+	_     uint32
+	key   uint64
+	value uint64
+	flags uint64
+}
+
+// objAttr is the union bpf_attr of BPF_OBJ_PIN and BPF_OBJ_GET
+type objAttr struct {
+	pathname  uint64
+	bpfFD     uint32
+	fileFlags uint32
+}
+
+// infoAttr is the union bpf_attr of BPF_OBJ_GET_INFO_BY_FD
+type infoAttr struct {
+	bpfFD   uint32
+	infoLen uint32
+	info    uint64
+}
+
+// mapInfo is the head of struct bpf_map_info
+type mapInfo struct {
+	mapType    uint32
+	id         uint32
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+	mapFlags   uint32
+}
+
+// NewMap creates an LPM trie map of IPv4 or IPv6 prefixes with values of valueSize bytes
+// and room for maxEntries prefixes. The name shows up in bpftool and is truncated to 15
+// bytes. Pin the map, or pass its FD to the loader of the program, to share it.
+func NewMap(name string, ipv6 bool, valueSize, maxEntries int) (*Map, error) {
+	if valueSize <= 0 || maxEntries <= 0 {
+		return nil, fmt.Errorf("bpf: map of %d-byte values and %d entries", valueSize, maxEntries)
+	}
+	attr := mapCreateAttr{
+		mapType:    unix.BPF_MAP_TYPE_LPM_TRIE,
+		keySize:    uint32(keySize(ipv6)),
+		valueSize:  uint32(valueSize),
+		maxEntries: uint32(maxEntries),
+		// LPM tries allocate entries on insert only
+		mapFlags: unix.BPF_F_NO_PREALLOC,
+	}
+	copy(attr.mapName[:len(attr.mapName)-1], name)
+	fd, err := bpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, os.NewSyscallError("bpf_map_create", err)
+	}
+	return &Map{fd: fd, ipv6: ipv6, valueSize: valueSize}, nil
+}
+
+// OpenMap opens an LPM trie map pinned at path, e.g. by Pin or by the loader of a program
+func OpenMap(path string) (*Map, error) {
+	pathname, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	attr := objAttr{pathname: uint64(uintptr(unsafe.Pointer(pathname)))}
+	fd, err := bpf(unix.BPF_OBJ_GET, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(pathname)
+	if err != nil {
+		return nil, &os.PathError{Op: "bpf_obj_get", Path: path, Err: err}
+	}
+
+	var info mapInfo
+	infoAttr := infoAttr{bpfFD: uint32(fd), infoLen: uint32(unsafe.Sizeof(info)), info: uint64(uintptr(unsafe.Pointer(&info)))}
+	_, err = bpf(unix.BPF_OBJ_GET_INFO_BY_FD, unsafe.Pointer(&infoAttr), unsafe.Sizeof(infoAttr))
+	runtime.KeepAlive(&info)
+	if err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bpf_obj_get_info_by_fd", err)
+	}
+	if info.mapType != unix.BPF_MAP_TYPE_LPM_TRIE || (info.keySize != uint32(keySize(false)) && info.keySize != uint32(keySize(true))) {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bpf: %s is not an LPM trie of IPv4 or IPv6 prefixes", path)
+	}
+	return &Map{fd: fd, ipv6: info.keySize == uint32(keySize(true)), valueSize: int(info.valueSize)}, nil
+}
+
+// Pin pins the map at path on a BPF filesystem, so it outlives the process
+func (m *Map) Pin(path string) error {
+	pathname, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attr := objAttr{pathname: uint64(uintptr(unsafe.Pointer(pathname))), bpfFD: uint32(m.fd)}
+	_, err = bpf(unix.BPF_OBJ_PIN, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(pathname)
+	if err != nil {
+		return &os.PathError{Op: "bpf_obj_pin", Path: path, Err: err}
+	}
+	return nil
+}
+
+// FD returns the file descriptor of the map
+func (m *Map) FD() int {
+	return m.fd
+}
+
+// IPv6 reports whether the map holds IPv6 prefixes
+func (m *Map) IPv6() bool {
+	return m.ipv6
+}
+
+// ValueSize returns the size of the values of the map
+func (m *Map) ValueSize() int {
+	return m.valueSize
+}
+
+// Close closes the map, which the kernel frees once no program or pin refers to it
+func (m *Map) Close() error {
+	return unix.Close(m.fd)
+}
+
+// Update stores value for the prefix
+func (m *Map) Update(prefix netip.Prefix, value []byte) error {
+	if len(value) != m.valueSize {
+		return fmt.Errorf("bpf: %d-byte value for a map of %d-byte values", len(value), m.valueSize)
+	}
+	key, err := m.key(prefix)
+	if err != nil {
+		return err
+	}
+	attr := mapElemAttr{
+		mapFD: uint32(m.fd),
+		key:   uint64(uintptr(unsafe.Pointer(&key[0]))),
+		value: uint64(uintptr(unsafe.Pointer(&value[0]))),
+		flags: unix.BPF_ANY,
+	}
+	_, err = bpf(unix.BPF_MAP_UPDATE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(key)
+	runtime.KeepAlive(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", prefix, os.NewSyscallError("bpf_map_update_elem", err))
+	}
+	return nil
+}
+
+// Delete removes the prefix, reporting whether it was present
+func (m *Map) Delete(prefix netip.Prefix) (bool, error) {
+	key, err := m.key(prefix)
+	if err != nil {
+		return false, err
+	}
+	attr := mapElemAttr{mapFD: uint32(m.fd), key: uint64(uintptr(unsafe.Pointer(&key[0])))}
+	_, err = bpf(unix.BPF_MAP_DELETE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(key)
+	if errors.Is(err, unix.ENOENT) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", prefix, os.NewSyscallError("bpf_map_delete_elem", err))
+	}
+	return true, nil
+}
+
+// Lookup returns the value of the longest prefix containing addr, like a BPF program
+// looking the address up
+func (m *Map) Lookup(addr netip.Addr) ([]byte, bool, error) {
+	key, err := m.key(netip.PrefixFrom(addr, addr.BitLen()))
+	if err != nil {
+		return nil, false, err
+	}
+	value := make([]byte, m.valueSize)
+	attr := mapElemAttr{
+		mapFD: uint32(m.fd),
+		key:   uint64(uintptr(unsafe.Pointer(&key[0]))),
+		value: uint64(uintptr(unsafe.Pointer(&value[0]))),
+	}
+	_, err = bpf(unix.BPF_MAP_LOOKUP_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(key)
+	runtime.KeepAlive(value)
+	if errors.Is(err, unix.ENOENT) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, os.NewSyscallError("bpf_map_lookup_elem", err)
+	}
+	return value, true, nil
+}
+
+// Prefixes returns the prefixes stored in the map, in no particular order
+func (m *Map) Prefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	size := keySize(m.ipv6)
+	// A nil key starts the iteration
+	var key []byte
+	next := make([]byte, size)
+	for {
+		attr := mapElemAttr{mapFD: uint32(m.fd), value: uint64(uintptr(unsafe.Pointer(&next[0])))}
+		if key != nil {
+			attr.key = uint64(uintptr(unsafe.Pointer(&key[0])))
+		}
+		_, err := bpf(unix.BPF_MAP_GET_NEXT_KEY, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+		runtime.KeepAlive(key)
+		runtime.KeepAlive(next)
+		if errors.Is(err, unix.ENOENT) {
+			return prefixes, nil
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("bpf_map_get_next_key", err)
+		}
+		prefix, err := m.prefix(next)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+		key, next = next, make([]byte, size)
+	}
+}
+
+// keySize is the size of struct bpf_lpm_trie_key with the address of the family
+func keySize(ipv6 bool) int {
+	if ipv6 {
+		return 4 + 16
+	}
+	return 4 + 4
+}
+
+// key encodes a prefix as struct bpf_lpm_trie_key: the prefix length in host byte order,
+// followed by the address in network byte order
+func (m *Map) key(prefix netip.Prefix) ([]byte, error) {
+	if !prefix.IsValid() || prefix.Addr().Is6() != m.ipv6 {
+		return nil, fmt.Errorf("bpf: prefix %s in a map of IPv%d prefixes", prefix, m.family())
+	}
+	prefix = prefix.Masked()
+	key := binary.NativeEndian.AppendUint32(make([]byte, 0, keySize(m.ipv6)), uint32(prefix.Bits()))
+	return append(key, prefix.Addr().AsSlice()...), nil
+}
+
+// prefix decodes a key
+func (m *Map) prefix(key []byte) (netip.Prefix, error) {
+	addr, _ := netip.AddrFromSlice(key[4:])
+	prefix := netip.PrefixFrom(addr, int(binary.NativeEndian.Uint32(key)))
+	if !prefix.IsValid() {
+		return netip.Prefix{}, fmt.Errorf("bpf: key of prefix length %d", binary.NativeEndian.Uint32(key))
+	}
+	return prefix, nil
+}
+
+func (m *Map) family() int {
+	if m.ipv6 {
+		return 6
+	}
+	return 4
+}
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(fd), nil
+}
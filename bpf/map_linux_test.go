@@ -0,0 +1,194 @@
+package bpf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sakateka/lpm"
+	"golang.org/x/sys/unix"
+)
+
+// newTestMap creates a map, skipping the test if the process may not create BPF maps
+func newTestMap(t *testing.T, ipv6 bool, valueSize int) *Map {
+	t.Helper()
+	m, err := NewMap("lpm_test", ipv6, valueSize, 1024)
+	if errors.Is(err, unix.EPERM) || errors.Is(err, unix.ENOSYS) {
+		t.Skipf("cannot create BPF maps: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("NewMap failed: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// lookupUint32 looks addr up in the map, returning -1 if no prefix contains it
+func lookupUint32(t *testing.T, m *Map, addr string) int {
+	t.Helper()
+	value, found, err := m.Lookup(netip.MustParseAddr(addr))
+	if err != nil {
+		t.Fatalf("Lookup(%s) failed: %v", addr, err)
+	}
+	if !found {
+		return -1
+	}
+	return int(binary.NativeEndian.Uint32(value))
+}
+
+// TestMap tests updating, looking up, listing and deleting prefixes
+func TestMap(t *testing.T) {
+	m := newTestMap(t, false, 4)
+	for prefix, value := range map[string]uint32{"10.0.0.0/8": 1, "10.1.0.0/16": 2} {
+		if err := m.Update(netip.MustParsePrefix(prefix), binary.NativeEndian.AppendUint32(nil, value)); err != nil {
+			t.Fatalf("Update(%s) failed: %v", prefix, err)
+		}
+	}
+	if err := m.Update(netip.MustParsePrefix("10.2.0.0/16"), []byte{1}); err == nil {
+		t.Error("Update with a short value succeeded")
+	}
+	if err := m.Update(netip.MustParsePrefix("2001:db8::/32"), make([]byte, 4)); err == nil {
+		t.Error("Update with an IPv6 prefix succeeded")
+	}
+
+	for addr, want := range map[string]int{"10.1.2.3": 2, "10.2.0.0": 1, "11.0.0.0": -1} {
+		if got := lookupUint32(t, m, addr); got != want {
+			t.Errorf("Lookup(%s) = %d, want %d", addr, got, want)
+		}
+	}
+
+	prefixes, err := m.Prefixes()
+	if err != nil {
+		t.Fatalf("Prefixes failed: %v", err)
+	}
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int { return a.Bits() - b.Bits() })
+	if want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.1.0.0/16")}; !slices.Equal(prefixes, want) {
+		t.Errorf("Prefixes() = %v, want %v", prefixes, want)
+	}
+
+	if deleted, err := m.Delete(netip.MustParsePrefix("10.1.0.0/16")); !deleted || err != nil {
+		t.Errorf("Delete(10.1.0.0/16) = %v, %v", deleted, err)
+	}
+	if deleted, err := m.Delete(netip.MustParsePrefix("10.1.0.0/16")); deleted || err != nil {
+		t.Errorf("second Delete(10.1.0.0/16) = %v, %v", deleted, err)
+	}
+	if got := lookupUint32(t, m, "10.1.2.3"); got != 1 {
+		t.Errorf("Lookup(10.1.2.3) after Delete = %d, want 1", got)
+	}
+}
+
+// TestPin tests opening a pinned map
+func TestPin(t *testing.T) {
+	dir, err := os.MkdirTemp("/sys/fs/bpf", "lpm_test")
+	if err != nil {
+		t.Skipf("no BPF filesystem: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newTestMap(t, true, 8)
+	path := filepath.Join(dir, "map")
+	if err := m.Pin(path); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+	opened, err := OpenMap(path)
+	if err != nil {
+		t.Fatalf("OpenMap failed: %v", err)
+	}
+	defer opened.Close()
+	if !opened.IPv6() || opened.ValueSize() != 8 {
+		t.Errorf("OpenMap() = IPv6 %v with %d-byte values, want true and 8", opened.IPv6(), opened.ValueSize())
+	}
+	if _, err := OpenMap(filepath.Join(dir, "missing")); err == nil {
+		t.Error("OpenMap of a missing pin succeeded")
+	}
+}
+
+// TestExporter tests that syncs leave the maps answering lookups like the trie
+func TestExporter(t *testing.T) {
+	v4, v6 := newTestMap(t, false, 4), newTestMap(t, true, 4)
+	// Entries of a previous run are dropped by the first sync
+	if err := v4.Update(netip.MustParsePrefix("192.0.2.0/24"), make([]byte, 4)); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "1")
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "2")
+	m.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	m.InsertWithPriority(netip.MustParsePrefix("10.3.0.0/16"), "1", 1)
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "6")
+
+	exporter := NewExporter(v4, v6, Uint32)
+	check := func(addrs map[string]int) {
+		t.Helper()
+		if err := exporter.Sync(m); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		for addr, want := range addrs {
+			bm := v4
+			if netip.MustParseAddr(addr).Is6() {
+				bm = v6
+			}
+			if got := lookupUint32(t, bm, addr); got != want {
+				t.Errorf("Lookup(%s) = %d, want %d", addr, got, want)
+			}
+		}
+		var want []netip.Prefix
+		for _, e := range m.EffectiveEntries() {
+			want = append(want, e.Prefix)
+		}
+		v4Prefixes, _ := v4.Prefixes()
+		v6Prefixes, _ := v6.Prefixes()
+		got := append(v4Prefixes, v6Prefixes...)
+		slices.SortFunc(got, func(a, b netip.Prefix) int { return a.Addr().Compare(b.Addr()) })
+		if !slices.Equal(got, want) {
+			t.Errorf("maps hold %v, want %v", got, want)
+		}
+	}
+	check(map[string]int{"10.1.1.1": 2, "10.1.2.3": -1, "10.3.0.1": 1, "192.0.2.1": -1, "2001:db8::1": 6})
+
+	m.Delete(netip.MustParsePrefix("10.1.2.0/24"))
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "3")
+	check(map[string]int{"10.1.2.3": 2, "10.2.0.0": 3, "10.3.0.1": 1})
+
+	m.Insert(netip.MustParsePrefix("10.4.0.0/16"), "x")
+	if err := exporter.Sync(m); err == nil {
+		t.Error("Sync with a value Uint32 cannot encode succeeded")
+	}
+}
+
+// TestSyncer tests that published tables reach the maps
+func TestSyncer(t *testing.T) {
+	v4 := newTestMap(t, false, 4)
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "1")
+	table := lpm.NewAtomic(m.Snapshot())
+
+	syncer, err := NewSyncer(context.Background(), NewExporter(v4, nil, Uint32), table, time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	defer syncer.Close()
+	if got := lookupUint32(t, v4, "10.1.2.3"); got != 1 {
+		t.Fatalf("Lookup(10.1.2.3) = %d, want 1", got)
+	}
+
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "2")
+	table.Store(m.Snapshot())
+	deadline := time.Now().Add(5 * time.Second)
+	for lookupUint32(t, v4, "10.1.2.3") != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the change did not reach the map")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := syncer.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package bpf
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// Map is a BPF_MAP_TYPE_LPM_TRIE map holding prefixes of one address family. BPF maps
+// only exist on Linux, so elsewhere they cannot be created or opened.
+type Map struct {
+	ipv6      bool
+	valueSize int
+}
+
+// NewMap fails with errors.ErrUnsupported on systems other than Linux
+func NewMap(name string, ipv6 bool, valueSize, maxEntries int) (*Map, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// OpenMap fails with errors.ErrUnsupported on systems other than Linux
+func OpenMap(path string) (*Map, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (m *Map) Pin(path string) error                          { return errors.ErrUnsupported }
+func (m *Map) FD() int                                        { return -1 }
+func (m *Map) IPv6() bool                                     { return m.ipv6 }
+func (m *Map) ValueSize() int                                 { return m.valueSize }
+func (m *Map) Close() error                                   { return errors.ErrUnsupported }
+func (m *Map) Update(prefix netip.Prefix, value []byte) error { return errors.ErrUnsupported }
+func (m *Map) Delete(prefix netip.Prefix) (bool, error)       { return false, errors.ErrUnsupported }
+func (m *Map) Lookup(addr netip.Addr) ([]byte, bool, error)   { return nil, false, errors.ErrUnsupported }
+func (m *Map) Prefixes() ([]netip.Prefix, error)              { return nil, errors.ErrUnsupported }
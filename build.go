@@ -0,0 +1,275 @@
+package lpm
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// parallelBuildMin is the number of entries from which Build shards the inserts by the
+// first address byte across goroutines
+const parallelBuildMin = 1 << 14
+
+// Build returns a trie holding the entries, mapping addresses as inserting them one by one
+// in order would, but constructed in bulk:
+//
+//   - entries are inserted from the shortest prefix to the longest, so no insert has to
+//     descend into blocks created by earlier ones;
+//   - blocks in which every address maps to the same value, e.g. those of sibling prefixes
+//     with equal values, are collapsed, see Compact;
+//   - blocks are laid out breadth first, so the upper levels every lookup walks through
+//     are next to each other, and stay so when packed.
+//
+// Large inputs are built on GOMAXPROCS goroutines: the prefixes below each slot of the root
+// block, i.e. sharing the first address byte, never touch those of another slot, so they are
+// inserted into separate tries which are then stitched together under the root. With
+// WithMaxMemory, entries are inserted one by one to check the limit before every insert.
+//
+// Entries with tombstones are inserted with InsertTombstone. Invalid prefixes and inserts
+// exceeding the trie capacity or memory limit fail like Insert does. The options that apply
+// are those of New.
+func Build(entries []PrefixValue, opts ...Option) (*LPM, error) {
+	return build(entries, newOptions(opts), runtime.GOMAXPROCS(0))
+}
+
+func build(entries []PrefixValue, o options, workers int) (*LPM, error) {
+	start := time.Now()
+	m := New()
+	m.ipv6Max64 = o.ipv6Max64
+	m.maxMemory = o.maxMemory
+	m.logger = o.logger
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(entries[a].Prefix.Bits(), entries[b].Prefix.Bits())
+	})
+	var err error
+	parallel := workers > 1 && len(entries) >= parallelBuildMin && o.maxMemory == 0
+	if parallel {
+		err = m.buildParallel(entries, order, workers)
+	} else {
+		err = m.buildSequential(entries, order)
+	}
+	if err != nil {
+		m.log().Warn("building table failed", "entries", len(entries), "error", err)
+		return nil, err
+	}
+
+	for _, proto := range []int{v4LPM, v6LPM} {
+		m.layoutBreadthFirst(proto)
+	}
+	if o.stride16 {
+		m.enableStride16()
+	}
+	stats := m.Stats()
+	m.log().Info("built table",
+		"entries", len(entries),
+		"ipv4_blocks", stats.IPv4Blocks,
+		"ipv6_blocks", stats.IPv6Blocks,
+		"values", stats.Values,
+		"bytes", stats.TotalSize,
+		"parallel", parallel,
+		"duration", time.Since(start))
+	return m, nil
+}
+
+// buildSequential inserts the entries in the given order and compacts the trie
+func (m *LPM) buildSequential(entries []PrefixValue, order []int) error {
+	for _, i := range order {
+		e := entries[i]
+		var err error
+		if e.Tombstone {
+			err = m.InsertTombstone(e.Prefix)
+		} else {
+			err = m.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+		}
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	m.compact()
+	return nil
+}
+
+// buildShard is the trie of the prefixes below one slot of the root block
+type buildShard struct {
+	proto   int
+	slot    uint8
+	entries []int
+	trie    trie
+}
+
+// prefix returns the prefix of the addresses below the root slot of the shard
+func (s *buildShard) prefix() netip.Prefix {
+	if s.proto == v4LPM {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte{s.slot}), 8)
+	}
+	return netip.PrefixFrom(netip.AddrFrom16([16]byte{s.slot}), 8)
+}
+
+// buildParallel inserts the entries in the given order like buildSequential, building the
+// subtries below the root slots concurrently. Values are added upfront in insertion order,
+// so they get the same indices, and prefixes shorter than a byte, which span several root
+// slots, are inserted into the root before the subtries start from the slots they left.
+func (m *LPM) buildParallel(entries []PrefixValue, order []int, workers int) error {
+	valueIdx := make([]int, len(entries))
+	var slots [2][blockSize][]int
+	for _, i := range order {
+		e := entries[i]
+		if err := m.checkInsert(e.Prefix); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		valueIdx[i] = tombstoneIdx
+		if !e.Tombstone {
+			idx, err := m.addValue(e.Value, e.Priority)
+			if err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+			valueIdx[i] = idx
+		}
+		if e.Prefix.Bits() < 8 {
+			m.insert(e.Prefix, valueIdx[i], buildPriority(e), m.priorityByIndex)
+			continue
+		}
+		proto, first := firstByte(e.Prefix.Addr())
+		slots[proto][first] = append(slots[proto][first], i)
+	}
+
+	var shards []*buildShard
+	for proto := range slots {
+		for slot, shardEntries := range slots[proto] {
+			if len(shardEntries) > 0 {
+				shards = append(shards, &buildShard{proto: proto, slot: uint8(slot), entries: shardEntries})
+			}
+		}
+	}
+
+	// Subtries only read the value priorities, which no longer change. Whether a block
+	// collapses depends on the blocks below it only, so compacting every subtrie compacts
+	// the whole trie.
+	removed := make([]int, len(shards))
+	parallelFor(workers, len(shards), func(k int) {
+		shard := shards[k]
+		shard.trie = newTrie()
+		root := shard.trie.root[shard.proto]
+		shard.trie.setValue(shard.proto, root, shard.slot, m.getValue(shard.proto, m.root[shard.proto], shard.slot))
+		// The prefixes covering the slot, for inserts that rebuild slots to fall back to
+		m.prefixes.covering(shard.prefix(), func(prefix netip.Prefix, valueIdx int) {
+			shard.trie.prefixes.set(prefix, valueIdx)
+		})
+		for _, i := range shard.entries {
+			shard.trie.insert(entries[i].Prefix, valueIdx[i], buildPriority(entries[i]), m.priorityByIndex)
+		}
+		removed[k] = shard.trie.compact()
+	})
+
+	// Append the blocks of every subtrie but its root, which compact keeps first, and
+	// link the subtrie below its slot
+	offsets := make([]int, len(shards))
+	for k, shard := range shards {
+		proto := shard.proto
+		offsets[k] = len(m.dynamic[proto]) - 1
+		m.dynamic[proto] = append(m.dynamic[proto], shard.trie.dynamic[proto][1:]...)
+		if len(m.dynamic[proto]) > maxBlockCount {
+			return fmt.Errorf("%w: building needs more than %d blocks", ErrCapacity, maxBlockCount)
+		}
+		m.generation += uint64(len(shard.entries))
+		shard.trie.prefixes.all(func(prefix netip.Prefix, valueIdx int) {
+			m.prefixes.set(prefix, valueIdx)
+		})
+	}
+	parallelFor(workers, len(shards), func(k int) {
+		for _, blk := range shards[k].trie.dynamic[shards[k].proto] {
+			for slot, encoded := range blk {
+				if isBlockRef(encoded) {
+					blk[slot] = encodeBlockRef(decodeBlockRef(encoded) + offsets[k])
+				}
+			}
+		}
+	})
+	for _, shard := range shards {
+		m.setValue(shard.proto, m.root[shard.proto], shard.slot, shard.trie.getValue(shard.proto, 0, shard.slot))
+	}
+	if slices.ContainsFunc(removed, func(n int) bool { return n > 0 }) {
+		m.generation++
+	}
+	return nil
+}
+
+// parallelFor calls fn for every k in [0, n) on up to workers goroutines
+func parallelFor(workers, n int, fn func(k int)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(workers, n) {
+		wg.Go(func() {
+			for {
+				k := int(next.Add(1)) - 1
+				if k >= n {
+					return
+				}
+				fn(k)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// buildPriority returns the priority an entry is inserted with
+func buildPriority(e PrefixValue) uint8 {
+	if e.Tombstone {
+		return 0
+	}
+	return e.Priority
+}
+
+// firstByte returns the protocol trie of the address and its first byte
+func firstByte(addr netip.Addr) (proto int, first uint8) {
+	if addr.Is4() {
+		return v4LPM, addr.As4()[0]
+	}
+	return v6LPM, addr.As16()[0]
+}
+
+// layoutBreadthFirst renumbers the blocks of a trie without shared blocks in breadth first
+// order from the root, dropping unreachable ones
+func (t *trie) layoutBreadthFirst(proto int) {
+	remap := make([]int, t.blockCount(proto))
+	for i := range remap {
+		remap[i] = -1
+	}
+	order := []int{t.root[proto]}
+	remap[t.root[proto]] = 0
+	for i := 0; i < len(order); i++ {
+		for _, encoded := range t.getBlockRef(proto, order[i]) {
+			if isBlockRef(encoded) && remap[decodeBlockRef(encoded)] < 0 {
+				remap[decodeBlockRef(encoded)] = len(order)
+				order = append(order, decodeBlockRef(encoded))
+			}
+		}
+	}
+
+	blocks := make([]*LPMBlock, len(order))
+	for i, blockIdx := range order {
+		blk := t.dynamic[proto][blockIdx]
+		for slot, encoded := range blk {
+			if isBlockRef(encoded) {
+				blk[slot] = encodeBlockRef(remap[decodeBlockRef(encoded)])
+			}
+		}
+		blocks[i] = blk
+	}
+	// Copy the blocks into one slab, so they are in breadth first order in memory as well
+	t.dynamic[proto] = cloneBlocks(blocks)
+	t.root[proto] = 0
+	t.copied[proto] = 0
+	t.frozen[proto] = 0
+	t.free[proto] = nil
+}
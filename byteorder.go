@@ -0,0 +1,45 @@
+package lpm
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// byteOrderMark is stored in StorageHeader.ByteOrder in the byte order of the host that packed
+// the storage, so a loader reading it back as 0x04030201 knows the storage is byte-swapped
+const byteOrderMark = 0x01020304
+
+// swapStorage returns a copy of storage with every multi-byte field byte-swapped.
+// header describes the layout of storage in host byte order and must have been validated.
+func swapStorage(storage []byte, header *StorageHeader) []byte {
+	swapped := alignedClone(storage)
+
+	swapWords := func(data []byte) {
+		for i := 0; i+4 <= len(data); i += 4 {
+			binary.NativeEndian.PutUint32(data[i:], bits.ReverseBytes32(binary.NativeEndian.Uint32(data[i:])))
+		}
+	}
+
+	// Headers before version 3 consist of 32-bit fields only
+	if header.Version == versionV1 || header.Version == versionV2 {
+		swapWords(swapped[:storedHeaderSize(header)])
+	} else {
+		swapWords(swapped[:preambleSize+8])
+		for i := preambleSize + 8; i < headerSize(header.Version); i += 8 {
+			binary.NativeEndian.PutUint64(swapped[i:], bits.ReverseBytes64(binary.NativeEndian.Uint64(swapped[i:])))
+		}
+	}
+
+	blockByteSize := uint64(blockSize * 4)
+	swapWords(swapped[header.V4BlocksOffset:sectionEnd(header.V4BlocksOffset, header.V4BlockCount, blockByteSize)])
+	swapWords(swapped[header.V6BlocksOffset:sectionEnd(header.V6BlocksOffset, header.V6BlockCount, blockByteSize)])
+
+	// Version 1 value slots start with a single length byte, later versions with a 2-byte length
+	if header.Version != versionV1 && header.ValueSlotSize >= 2 {
+		for i := 0; i < int(header.ValueCount); i++ {
+			slot := swapped[int(header.ValuesOffset)+i*int(header.ValueSlotSize):]
+			slot[0], slot[1] = slot[1], slot[0]
+		}
+	}
+	return swapped
+}
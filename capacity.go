@@ -0,0 +1,44 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+const (
+	// maxValueCount is the number of value indices a slot can encode; the last index
+	// is reserved for tombstones
+	maxValueCount = tombstoneIdx
+	// maxBlockCount is the number of block indices a block reference can encode
+	maxBlockCount = blockIndexMask + 1
+)
+
+// checkInsert reports an invalid prefix, or a trie that might run out of block indices
+// while inserting it. An insert creates at most one block per address byte and copies
+// every frozen block it modifies at most once, so the check is conservative for tries
+// over large shared storage.
+func (t *trie) checkInsert(net netip.Prefix) error {
+	if !net.IsValid() {
+		return fmt.Errorf("%w: %v", ErrInvalidPrefix, net)
+	}
+	if err := t.checkMax64(net); err != nil {
+		return err
+	}
+	proto := v4LPM
+	if net.Addr().Is6() {
+		proto = v6LPM
+	}
+	need := net.Addr().BitLen()/8 + max(len(t.shared[proto]), t.frozen[proto])
+	if t.blockCount(proto)+need > maxBlockCount {
+		return fmt.Errorf("%w: inserting %s may exceed %d blocks", ErrCapacity, net, maxBlockCount)
+	}
+	return nil
+}
+
+// checkValueCount reports a value table that has no index left for a new value
+func checkValueCount(count int) error {
+	if count >= maxValueCount {
+		return fmt.Errorf("%w: more than %d distinct values", ErrCapacity, maxValueCount)
+	}
+	return nil
+}
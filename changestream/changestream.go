@@ -0,0 +1,142 @@
+// Package changestream keeps a table in sync with an event stream of prefix updates, for
+// deployments whose source of truth is a Kafka topic or a NATS JetStream stream rather
+// than a table file:
+//
+//	js, err := jetstream.New(nc)
+//	connector, err := changestream.NewConnector(ctx, changestream.JetStream(js, "ROUTES"), "/var/lib/routes.lpm")
+//	value, found := connector.Lookup(addr)
+//
+// Every message is a batch of operations, encoded either as JSON in the format of
+// admin.UpdateRequest:
+//
+//	{"ops": [{"op": "insert", "cidr": "10.0.0.0/8", "value": "ten"}, {"op": "delete", "cidr": "192.0.2.0/24"}]}
+//
+// or as an Update protobuf message, defined in update.proto. Batches are applied all or
+// nothing: lookups see either none or all operations of a message.
+//
+// Streams are read through the Reader interface. JetStream reads NATS JetStream streams;
+// for Kafka, wrap the reader of a client library, e.g. for a single-partition topic with
+// github.com/segmentio/kafka-go:
+//
+//	type kafkaReader struct{ *kafka.Reader }
+//
+//	func (r kafkaReader) ReadMessage(ctx context.Context) (changestream.Message, error) {
+//	    msg, err := r.Reader.ReadMessage(ctx)
+//	    return changestream.Message{Offset: msg.Offset, Value: msg.Value}, err
+//	}
+//
+//	stream := func(ctx context.Context, after int64) (changestream.Reader, error) {
+//	    r := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: "routes"})
+//	    if after >= 0 {
+//	        r.SetOffset(after + 1)
+//	    }
+//	    return kafkaReader{r}, nil
+//	}
+package changestream
+
+//go:generate buf generate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sakateka/lpm"
+	"github.com/sakateka/lpm/admin"
+)
+
+// Message is an encoded update read from a stream
+type Message struct {
+	Offset int64 // position in the stream, increasing from message to message
+	Value  []byte
+}
+
+// Reader reads the messages of a stream in order
+type Reader interface {
+	// ReadMessage blocks until the next message arrives or ctx is done
+	ReadMessage(ctx context.Context) (Message, error)
+	Close() error
+}
+
+// Stream opens a reader starting at the message following offset after, or at the first
+// message of the stream if after is negative
+type Stream func(ctx context.Context, after int64) (Reader, error)
+
+// Decode decodes a message into operations. JSON is told apart from protobuf by its
+// leading '{', which encoded Update messages never start with.
+func Decode(data []byte) ([]admin.Op, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		var update admin.UpdateRequest
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&update); err != nil {
+			return nil, err
+		}
+		return update.Ops, nil
+	}
+
+	var update Update
+	if err := proto.Unmarshal(data, &update); err != nil {
+		return nil, err
+	}
+	ops := make([]admin.Op, len(update.Ops))
+	for i, op := range update.Ops {
+		prefix, err := netip.ParsePrefix(op.Cidr)
+		if err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+		if op.Priority > 255 {
+			return nil, fmt.Errorf("op %d: priority %d exceeds 255", i, op.Priority)
+		}
+		ops[i] = admin.Op{Prefix: prefix, Value: op.Value, Priority: uint8(op.Priority)}
+		switch op.Kind {
+		case Operation_KIND_INSERT:
+			ops[i].Op = admin.OpInsert
+		case Operation_KIND_TOMBSTONE:
+			ops[i].Op = admin.OpTombstone
+		case Operation_KIND_DELETE:
+			ops[i].Op = admin.OpDelete
+		default:
+			return nil, fmt.Errorf("op %d: unknown kind %v", i, op.Kind)
+		}
+	}
+	return ops, nil
+}
+
+// validate rejects operations that cannot be applied, before any of a batch is
+func validate(ops []admin.Op) error {
+	for i, op := range ops {
+		switch op.Op {
+		case admin.OpInsert, admin.OpTombstone, admin.OpDelete:
+		default:
+			return fmt.Errorf("op %d: unknown op %q", i, op.Op)
+		}
+		if !op.Prefix.IsValid() {
+			return fmt.Errorf("op %d: %w: missing cidr", i, lpm.ErrInvalidPrefix)
+		}
+	}
+	return nil
+}
+
+// apply applies operations to m in order
+func apply(m *lpm.LPM, ops []admin.Op) error {
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case admin.OpInsert:
+			err = m.InsertWithPriority(op.Prefix, op.Value, op.Priority)
+		case admin.OpTombstone:
+			err = m.InsertTombstone(op.Prefix)
+		case admin.OpDelete:
+			_, err = m.Delete(op.Prefix)
+		}
+		if err != nil {
+			return fmt.Errorf("op %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package changestream
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sakateka/lpm"
+	"github.com/sakateka/lpm/admin"
+)
+
+// memStream is a stream kept in memory, with offsets starting at 1
+type memStream struct {
+	mu       sync.Mutex
+	messages []Message
+	opened   []int64 // offsets readers were opened after
+}
+
+func (s *memStream) append(value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, Message{Offset: int64(len(s.messages) + 1), Value: value})
+}
+
+func (s *memStream) open(ctx context.Context, after int64) (Reader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opened = append(s.opened, after)
+	return &memReader{stream: s, next: max(after, 0)}, nil
+}
+
+type memReader struct {
+	stream *memStream
+	next   int64 // index of the next message
+}
+
+func (r *memReader) ReadMessage(ctx context.Context) (Message, error) {
+	for {
+		r.stream.mu.Lock()
+		if r.next < int64(len(r.stream.messages)) {
+			msg := r.stream.messages[r.next]
+			r.stream.mu.Unlock()
+			r.next++
+			return msg, nil
+		}
+		r.stream.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (r *memReader) Close() error {
+	return nil
+}
+
+// waitOffset waits until the connector applied the messages up to offset
+func waitOffset(t *testing.T, c *Connector, offset int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Offset() < offset {
+		if time.Now().After(deadline) {
+			t.Fatalf("connector at offset %d, want %d (error %v)", c.Offset(), offset, c.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func lookup(t *testing.T, c *Connector, want map[string]string) {
+	t.Helper()
+	for addr, value := range want {
+		if got, _ := c.Lookup(netip.MustParseAddr(addr)); got != value {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, value)
+		}
+	}
+}
+
+// TestDecode tests that JSON and protobuf messages decode to the same operations
+func TestDecode(t *testing.T) {
+	want := []admin.Op{
+		{Op: admin.OpInsert, Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "ten", Priority: 2},
+		{Op: admin.OpTombstone, Prefix: netip.MustParsePrefix("10.1.0.0/16")},
+		{Op: admin.OpDelete, Prefix: netip.MustParsePrefix("2001:db8::/32")},
+	}
+
+	ops, err := Decode([]byte(` {"ops": [
+		{"op": "insert", "cidr": "10.0.0.0/8", "value": "ten", "priority": 2},
+		{"op": "tombstone", "cidr": "10.1.0.0/16"},
+		{"op": "delete", "cidr": "2001:db8::/32"}]}`))
+	if err != nil {
+		t.Fatalf("Decode of JSON failed: %v", err)
+	}
+	if !equalOps(ops, want) {
+		t.Errorf("Decode of JSON = %v, want %v", ops, want)
+	}
+
+	data, err := proto.Marshal(&Update{Ops: []*Operation{
+		{Kind: Operation_KIND_INSERT, Cidr: "10.0.0.0/8", Value: "ten", Priority: 2},
+		{Kind: Operation_KIND_TOMBSTONE, Cidr: "10.1.0.0/16"},
+		{Kind: Operation_KIND_DELETE, Cidr: "2001:db8::/32"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err = Decode(data)
+	if err != nil {
+		t.Fatalf("Decode of protobuf failed: %v", err)
+	}
+	if !equalOps(ops, want) {
+		t.Errorf("Decode of protobuf = %v, want %v", ops, want)
+	}
+
+	for name, update := range map[string]*Update{
+		"missing kind":   {Ops: []*Operation{{Cidr: "10.0.0.0/8"}}},
+		"invalid cidr":   {Ops: []*Operation{{Kind: Operation_KIND_INSERT, Cidr: "10.0.0.0"}}},
+		"large priority": {Ops: []*Operation{{Kind: Operation_KIND_INSERT, Cidr: "10.0.0.0/8", Priority: 256}}},
+	} {
+		data, _ := proto.Marshal(update)
+		if _, err := Decode(data); err == nil {
+			t.Errorf("Decode of a protobuf message with %s succeeded", name)
+		}
+	}
+	if _, err := Decode([]byte(`{"ops": [], "extra": 1}`)); err == nil {
+		t.Error("Decode of JSON with an unknown field succeeded")
+	}
+}
+
+func equalOps(a, b []admin.Op) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestConnector tests that messages are applied all or nothing and that a restarted
+// connector resumes from its snapshot
+func TestConnector(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "routes.lpm")
+	stream := &memStream{}
+	stream.append([]byte(`{"ops": [{"op": "insert", "cidr": "10.0.0.0/8", "value": "ten"}, {"op": "insert", "cidr": "192.0.2.0/24", "value": "test"}]}`))
+
+	// Leave room for the inserts of the first message but not for a large value
+	limit := TableOptions(lpm.WithMaxMemory(lpm.New().Stats().TotalSize + 1<<16))
+	c, err := NewConnector(ctx, stream.open, path, limit, SnapshotInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewConnector failed: %v", err)
+	}
+	waitOffset(t, c, 1)
+	lookup(t, c, map[string]string{"10.1.2.3": "ten", "192.0.2.1": "test"})
+
+	// The first operation fits, the second does not, so neither is applied
+	stream.append([]byte(`{"ops": [{"op": "delete", "cidr": "192.0.2.0/24"}, {"op": "insert", "cidr": "172.16.0.0/12", "value": "` + strings.Repeat("x", 1<<17) + `"}]}`))
+	waitOffset(t, c, 2)
+	if err := c.Err(); !errors.Is(err, lpm.ErrMemoryLimit) {
+		t.Errorf("Err() = %v, want %v", err, lpm.ErrMemoryLimit)
+	}
+	lookup(t, c, map[string]string{"192.0.2.1": "test", "172.16.0.1": ""})
+
+	stream.append([]byte("not a message"))
+	stream.append([]byte(`{"ops": [{"op": "tombstone", "cidr": "10.1.0.0/16"}]}`))
+	waitOffset(t, c, 4)
+	if err := c.Err(); err != nil {
+		t.Errorf("Err() = %v after a message applied", err)
+	}
+	lookup(t, c, map[string]string{"10.1.2.3": "", "10.2.0.1": "ten", "192.0.2.1": "test"})
+	fingerprint := c.Load().Fingerprint()
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A restart loads the snapshot and reads the messages following it
+	stream.append([]byte(`{"ops": [{"op": "delete", "cidr": "10.0.0.0/8"}]}`))
+	c, err = NewConnector(ctx, stream.open, path, limit)
+	if err != nil {
+		t.Fatalf("NewConnector failed: %v", err)
+	}
+	defer c.Close()
+	if c.Load().Fingerprint() != fingerprint {
+		t.Error("snapshot differs from the table saved")
+	}
+	if after := stream.opened[len(stream.opened)-1]; after != 4 {
+		t.Errorf("stream reopened after offset %d, want 4", after)
+	}
+	waitOffset(t, c, 5)
+	lookup(t, c, map[string]string{"10.2.0.1": "", "192.0.2.1": "test"})
+}
+
+// TestConnectorRedelivery tests that messages read again after the stream is reopened
+// are skipped
+func TestConnectorRedelivery(t *testing.T) {
+	stream := &memStream{}
+	stream.append([]byte(`{"ops": [{"op": "insert", "cidr": "10.0.0.0/8", "value": "ten"}]}`))
+	stream.append([]byte(`{"ops": [{"op": "delete", "cidr": "10.0.0.0/8"}]}`))
+	c, err := NewConnector(context.Background(), stream.open, "")
+	if err != nil {
+		t.Fatalf("NewConnector failed: %v", err)
+	}
+	defer c.Close()
+	waitOffset(t, c, 2)
+
+	c.applyMessage(stream.messages[0])
+	if _, found := c.Lookup(netip.MustParseAddr("10.0.0.1")); found {
+		t.Error("redelivered message was applied")
+	}
+	if c.Offset() != 2 {
+		t.Errorf("Offset() = %d, want 2", c.Offset())
+	}
+}
+
+// TestConnectorSnapshot tests that snapshots are saved periodically
+func TestConnectorSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.lpm")
+	stream := &memStream{}
+	stream.append([]byte(`{"ops": [{"op": "insert", "cidr": "10.0.0.0/8", "value": "ten"}]}`))
+	c, err := NewConnector(context.Background(), stream.open, path, SnapshotInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewConnector failed: %v", err)
+	}
+	defer c.Close()
+	waitOffset(t, c, 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m, err := lpm.LoadFromFile(path)
+		if err == nil && m.Metadata()[MetadataOffset] == "1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no snapshot saved: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,246 @@
+package changestream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// MetadataOffset is the metadata key under which snapshots record the offset of the last
+// message applied to them
+const MetadataOffset = "changestream_offset"
+
+// retryDelay is how long the connector waits before reopening a failed stream
+const retryDelay = time.Second
+
+// Option configures a Connector
+type Option func(*Connector)
+
+// SnapshotInterval sets how often the connector saves a snapshot, if messages were
+// applied since the last one; every minute by default
+func SnapshotInterval(d time.Duration) Option {
+	return func(c *Connector) {
+		c.snapshotInterval = d
+	}
+}
+
+// TableOptions sets the options tables are loaded and built with, e.g. lpm.WithMaxMemory
+func TableOptions(opts ...lpm.Option) Option {
+	return func(c *Connector) {
+		c.opts = opts
+	}
+}
+
+// Connector applies the updates of a stream to a table and publishes it as a snapshot
+// after every message, so lookups never block. It periodically saves the table to a
+// snapshot file along with the stream offset reached, so a restart loads the snapshot and
+// only replays the messages that followed it; streams may then drop older messages, e.g.
+// through retention limits.
+//
+// Messages that fail to decode or to apply are skipped as a whole and reported by Err.
+type Connector struct {
+	stream           Stream
+	path             string
+	snapshotInterval time.Duration
+	opts             []lpm.Option
+
+	table lpm.Atomic
+
+	mu      sync.Mutex // guards the fields below
+	work    *lpm.LPM   // working copy the messages are applied to
+	offset  int64      // offset of the last message applied, -1 before the first
+	pending int        // messages applied since the last snapshot
+	err     error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConnector loads the snapshot at path, if it exists, and applies the messages of the
+// stream following it until Close is called or ctx is done. Without a path, no snapshots
+// are saved and the whole stream is applied. It fails if the snapshot cannot be loaded or
+// the stream cannot be opened.
+func NewConnector(ctx context.Context, stream Stream, path string, opts ...Option) (*Connector, error) {
+	c := &Connector{
+		stream:           stream,
+		path:             path,
+		snapshotInterval: time.Minute,
+		offset:           -1,
+		done:             make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	reader, err := stream(ctx, c.offset)
+	if err != nil {
+		return nil, fmt.Errorf("changestream: %w", err)
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.run(ctx, reader)
+	return c, nil
+}
+
+// load loads the snapshot, or starts from an empty table if there is none
+func (c *Connector) load() error {
+	c.work = lpm.New(c.opts...)
+	if c.path != "" {
+		m, err := lpm.LoadFromFile(c.path, c.opts...)
+		switch {
+		case err == nil:
+			offset, err := strconv.ParseInt(m.Metadata()[MetadataOffset], 10, 64)
+			if err != nil {
+				return fmt.Errorf("changestream: %s: missing %s metadata", c.path, MetadataOffset)
+			}
+			c.work, c.offset = m, offset
+		case !errors.Is(err, fs.ErrNotExist):
+			return fmt.Errorf("changestream: %w", err)
+		}
+	}
+	c.table.Store(c.work.Snapshot())
+	return nil
+}
+
+// Load returns the current table
+func (c *Connector) Load() *lpm.LPM {
+	return c.table.Load()
+}
+
+// Lookup looks addr up in the current table
+func (c *Connector) Lookup(addr netip.Addr) (string, bool) {
+	return c.table.Lookup(addr)
+}
+
+// Offset returns the offset of the last message applied, or -1 if none was
+func (c *Connector) Offset() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset
+}
+
+// Err returns the error of the last message, or of reading the stream, or nil if it
+// succeeded
+func (c *Connector) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Checkpoint saves the snapshot right away, if messages were applied since the last one
+func (c *Connector) Checkpoint() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || c.pending == 0 {
+		return nil
+	}
+	c.work.SetMetadata(MetadataOffset, strconv.FormatInt(c.offset, 10))
+	if err := c.work.SaveToFile(c.path, c.opts...); err != nil {
+		return err
+	}
+	c.pending = 0
+	return nil
+}
+
+// Close stops applying messages and saves the snapshot
+func (c *Connector) Close() error {
+	c.cancel()
+	<-c.done
+	return c.Checkpoint()
+}
+
+// applyMessage applies a message to the working copy and publishes it, or leaves the
+// table as it was if any operation fails
+func (c *Connector) applyMessage(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Offset <= c.offset {
+		// Redelivered after the stream was reopened
+		return
+	}
+	ops, err := Decode(msg.Value)
+	if err == nil {
+		err = validate(ops)
+	}
+	if err == nil {
+		if err = apply(c.work, ops); err != nil {
+			// Undo the operations applied before the failing one
+			work, rebuildErr := lpm.Build(c.table.Load().Entries(), c.opts...)
+			if rebuildErr != nil {
+				err = errors.Join(err, rebuildErr)
+			} else {
+				c.work = work
+			}
+		}
+	}
+	c.offset = msg.Offset
+	c.pending++
+	if err != nil {
+		c.err = fmt.Errorf("changestream: message %d: %w", msg.Offset, err)
+		return
+	}
+	c.err = nil
+	c.table.Store(c.work.Snapshot())
+}
+
+func (c *Connector) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *Connector) run(ctx context.Context, reader Reader) {
+	defer close(c.done)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.snapshotInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.Checkpoint(); err != nil {
+					c.setErr(fmt.Errorf("changestream: snapshot: %w", err))
+				}
+			}
+		}
+	}()
+	defer wg.Wait()
+
+	for {
+		if reader == nil {
+			var err error
+			if reader, err = c.stream(ctx, c.Offset()); err != nil {
+				c.setErr(fmt.Errorf("changestream: %w", err))
+			}
+		}
+		if reader != nil {
+			msg, err := reader.ReadMessage(ctx)
+			if err == nil {
+				c.applyMessage(msg)
+				continue
+			}
+			reader.Close()
+			reader = nil
+			if ctx.Err() == nil {
+				c.setErr(fmt.Errorf("changestream: %w", err))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
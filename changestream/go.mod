@@ -0,0 +1,18 @@
+module github.com/sakateka/lpm/changestream
+
+go 1.25.1
+
+require (
+	github.com/nats-io/nats.go v1.48.0
+	github.com/sakateka/lpm v0.1.0
+	google.golang.org/protobuf v1.36.8
+)
+
+require (
+	github.com/google/go-cmp v0.7.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/nats-io/nkeys v0.4.11 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+)
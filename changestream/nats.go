@@ -0,0 +1,51 @@
+package changestream
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// JetStream returns a stream reading the messages of a NATS JetStream stream, only those
+// of the given subjects if any, through an ordered consumer. Offsets are stream sequence
+// numbers.
+func JetStream(js jetstream.JetStream, stream string, subjects ...string) Stream {
+	return func(ctx context.Context, after int64) (Reader, error) {
+		cfg := jetstream.OrderedConsumerConfig{FilterSubjects: subjects, DeliverPolicy: jetstream.DeliverAllPolicy}
+		if after >= 0 {
+			cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+			cfg.OptStartSeq = uint64(after) + 1
+		}
+		consumer, err := js.OrderedConsumer(ctx, stream, cfg)
+		if err != nil {
+			return nil, err
+		}
+		messages, err := consumer.Messages()
+		if err != nil {
+			return nil, err
+		}
+		return &natsReader{messages: messages}, nil
+	}
+}
+
+// natsReader reads the messages of an ordered consumer
+type natsReader struct {
+	messages jetstream.MessagesContext
+}
+
+func (r *natsReader) ReadMessage(ctx context.Context) (Message, error) {
+	msg, err := r.messages.Next(jetstream.NextContext(ctx))
+	if err != nil {
+		return Message{}, err
+	}
+	meta, err := msg.Metadata()
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Offset: int64(meta.Sequence.Stream), Value: msg.Data()}, nil
+}
+
+func (r *natsReader) Close() error {
+	r.messages.Stop()
+	return nil
+}
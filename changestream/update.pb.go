@@ -0,0 +1,261 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: update.proto
+
+// Prefix updates consumed by changestream.Connector.
+
+package changestream
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Operation_Kind int32
+
+const (
+	Operation_KIND_UNSPECIFIED Operation_Kind = 0
+	Operation_KIND_INSERT      Operation_Kind = 1 // store value for cidr with priority, see LPM.InsertWithPriority
+	Operation_KIND_TOMBSTONE   Operation_Kind = 2 // carve cidr out of broader prefixes, see LPM.InsertTombstone
+	Operation_KIND_DELETE      Operation_Kind = 3 // remove cidr, see LPM.Delete
+)
+
+// Enum value maps for Operation_Kind.
+var (
+	Operation_Kind_name = map[int32]string{
+		0: "KIND_UNSPECIFIED",
+		1: "KIND_INSERT",
+		2: "KIND_TOMBSTONE",
+		3: "KIND_DELETE",
+	}
+	Operation_Kind_value = map[string]int32{
+		"KIND_UNSPECIFIED": 0,
+		"KIND_INSERT":      1,
+		"KIND_TOMBSTONE":   2,
+		"KIND_DELETE":      3,
+	}
+)
+
+func (x Operation_Kind) Enum() *Operation_Kind {
+	p := new(Operation_Kind)
+	*p = x
+	return p
+}
+
+func (x Operation_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Operation_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_update_proto_enumTypes[0].Descriptor()
+}
+
+func (Operation_Kind) Type() protoreflect.EnumType {
+	return &file_update_proto_enumTypes[0]
+}
+
+func (x Operation_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Operation_Kind.Descriptor instead.
+func (Operation_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_update_proto_rawDescGZIP(), []int{1, 0}
+}
+
+// Update is a batch of operations, applied in order and all or nothing.
+type Update struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ops           []*Operation           `protobuf:"bytes,1,rep,name=ops,proto3" json:"ops,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Update) Reset() {
+	*x = Update{}
+	mi := &file_update_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Update) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Update) ProtoMessage() {}
+
+func (x *Update) ProtoReflect() protoreflect.Message {
+	mi := &file_update_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Update.ProtoReflect.Descriptor instead.
+func (*Update) Descriptor() ([]byte, []int) {
+	return file_update_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Update) GetOps() []*Operation {
+	if x != nil {
+		return x.Ops
+	}
+	return nil
+}
+
+type Operation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          Operation_Kind         `protobuf:"varint,1,opt,name=kind,proto3,enum=lpm.changestream.v1.Operation_Kind" json:"kind,omitempty"`
+	Cidr          string                 `protobuf:"bytes,2,opt,name=cidr,proto3" json:"cidr,omitempty"` // host bits are ignored
+	Value         string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Priority      uint32                 `protobuf:"varint,4,opt,name=priority,proto3" json:"priority,omitempty"` // at most 255
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Operation) Reset() {
+	*x = Operation{}
+	mi := &file_update_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Operation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Operation) ProtoMessage() {}
+
+func (x *Operation) ProtoReflect() protoreflect.Message {
+	mi := &file_update_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Operation.ProtoReflect.Descriptor instead.
+func (*Operation) Descriptor() ([]byte, []int) {
+	return file_update_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Operation) GetKind() Operation_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return Operation_KIND_UNSPECIFIED
+}
+
+func (x *Operation) GetCidr() string {
+	if x != nil {
+		return x.Cidr
+	}
+	return ""
+}
+
+func (x *Operation) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *Operation) GetPriority() uint32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+var File_update_proto protoreflect.FileDescriptor
+
+const file_update_proto_rawDesc = "" +
+	"\n" +
+	"\fupdate.proto\x12\x13lpm.changestream.v1\":\n" +
+	"\x06Update\x120\n" +
+	"\x03ops\x18\x01 \x03(\v2\x1e.lpm.changestream.v1.OperationR\x03ops\"\xde\x01\n" +
+	"\tOperation\x127\n" +
+	"\x04kind\x18\x01 \x01(\x0e2#.lpm.changestream.v1.Operation.KindR\x04kind\x12\x12\n" +
+	"\x04cidr\x18\x02 \x01(\tR\x04cidr\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12\x1a\n" +
+	"\bpriority\x18\x04 \x01(\rR\bpriority\"R\n" +
+	"\x04Kind\x12\x14\n" +
+	"\x10KIND_UNSPECIFIED\x10\x00\x12\x0f\n" +
+	"\vKIND_INSERT\x10\x01\x12\x12\n" +
+	"\x0eKIND_TOMBSTONE\x10\x02\x12\x0f\n" +
+	"\vKIND_DELETE\x10\x03B&Z$github.com/sakateka/lpm/changestreamb\x06proto3"
+
+var (
+	file_update_proto_rawDescOnce sync.Once
+	file_update_proto_rawDescData []byte
+)
+
+func file_update_proto_rawDescGZIP() []byte {
+	file_update_proto_rawDescOnce.Do(func() {
+		file_update_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_update_proto_rawDesc), len(file_update_proto_rawDesc)))
+	})
+	return file_update_proto_rawDescData
+}
+
+var file_update_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_update_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_update_proto_goTypes = []any{
+	(Operation_Kind)(0), // 0: lpm.changestream.v1.Operation.Kind
+	(*Update)(nil),      // 1: lpm.changestream.v1.Update
+	(*Operation)(nil),   // 2: lpm.changestream.v1.Operation
+}
+var file_update_proto_depIdxs = []int32{
+	2, // 0: lpm.changestream.v1.Update.ops:type_name -> lpm.changestream.v1.Operation
+	0, // 1: lpm.changestream.v1.Operation.kind:type_name -> lpm.changestream.v1.Operation.Kind
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_update_proto_init() }
+func file_update_proto_init() {
+	if File_update_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_update_proto_rawDesc), len(file_update_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_update_proto_goTypes,
+		DependencyIndexes: file_update_proto_depIdxs,
+		EnumInfos:         file_update_proto_enumTypes,
+		MessageInfos:      file_update_proto_msgTypes,
+	}.Build()
+	File_update_proto = out.File
+	file_update_proto_goTypes = nil
+	file_update_proto_depIdxs = nil
+}
@@ -0,0 +1,24 @@
+package lpm
+
+import "hash/crc32"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// storageChecksum returns the CRC-32C of the first size bytes of storage in the given format
+// version, with the Checksum header field and, from version 6, the Sequence field read as zero,
+// so the sequence counter may change without invalidating the checksum. The bytes are hashed
+// as stored, so the checksum of storage packed on a host of the other byte order is verified
+// before conversion.
+func storageChecksum(storage []byte, size int, version uint32) uint32 {
+	var zero [8]byte
+	offset := checksumOffset(version)
+	crc := crc32.Update(0, castagnoli, storage[:offset])
+	crc = crc32.Update(crc, castagnoli, zero[:4])
+	rest := offset + 4
+	if sequence := sequenceOffset(version); sequence > 0 {
+		crc = crc32.Update(crc, castagnoli, storage[rest:sequence])
+		crc = crc32.Update(crc, castagnoli, zero[:])
+		rest = sequence + 8
+	}
+	return crc32.Update(crc, castagnoli, storage[rest:size])
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+func runBuild(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := fs.String("o", "-", "output `file`, - for standard output")
+	format := fs.String("format", "", "input `format`, csv or json; guessed from the input file extension, csv by default")
+	compress := fs.Bool("compress", false, "compress the storage with zstd, see lpm.PackCompressed")
+	metadata := map[string]string{}
+	fs.Func("meta", "add a `key=value` metadata entry, e.g. the source of the data", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("want key=value, got %q", s)
+		}
+		metadata[key] = value
+		return nil
+	})
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	input := "-"
+	if fs.NArg() == 1 {
+		input = fs.Arg(0)
+	}
+	if *format == "" {
+		*format = "csv"
+		if strings.EqualFold(filepath.Ext(input), ".json") {
+			*format = "json"
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var entries []lpm.PrefixValue
+	var err error
+	switch *format {
+	case "csv":
+		entries, err = readCSV(r)
+	case "json":
+		err = json.NewDecoder(bufio.NewReader(r)).Decode(&entries)
+	default:
+		return fmt.Errorf("unknown input format %q", *format)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	table, err := lpm.Build(entries)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	for key, value := range metadata {
+		table.SetMetadata(key, value)
+	}
+	return writeTable(table, *output, *compress, stdout)
+}
+
+// readCSV reads cidr,value rows. Lines starting with # are comments, and a first row
+// starting with "cidr" is taken for a header and skipped.
+func readCSV(r io.Reader) ([]lpm.PrefixValue, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 2
+	cr.ReuseRecord = true
+
+	var entries []lpm.PrefixValue
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cidr := strings.TrimSpace(record[0])
+		if len(entries) == 0 && strings.EqualFold(cidr, "cidr") {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, lpm.PrefixValue{Prefix: prefix, Value: record[1]})
+	}
+}
+
+// writeTable packs the table to path, or to stdout if path is -
+func writeTable(table *lpm.LPM, path string, compress bool, stdout io.Writer) error {
+	if path == "-" {
+		w := bufio.NewWriter(stdout)
+		var err error
+		if compress {
+			_, err = table.PackCompressed(w)
+		} else {
+			_, err = table.PackTo(w)
+		}
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	if !compress {
+		return table.SaveToFile(path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := table.PackCompressed(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/sakateka/lpm"
+)
+
+func runGet(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	if err := parseFlags(fs, args, 2); err != nil {
+		return err
+	}
+	addrs := make([]netip.Addr, fs.NArg()-1)
+	for i, arg := range fs.Args()[1:] {
+		addr, err := netip.ParseAddr(arg)
+		if err != nil {
+			return err
+		}
+		addrs[i] = addr
+	}
+	table, err := lpm.LoadFromFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+	missing := false
+	for _, addr := range addrs {
+		if value, found := table.Lookup(addr); found {
+			fmt.Fprintf(w, "%s %q\n", addr, value)
+		} else {
+			fmt.Fprintf(w, "%s not found\n", addr)
+			missing = true
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if missing {
+		return errNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/sakateka/lpm"
+)
+
+func runInfo(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	storage, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := lpm.StorageInfo(storage)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	table, err := lpm.NewWithSharedStorage(storage)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	printInfo(stdout, path, len(storage), info, table)
+	return nil
+}
+
+func printInfo(w io.Writer, path string, size int, info lpm.Info, table *lpm.LPM) {
+	header := info.Header
+	fmt.Fprintf(w, "file:          %s (%d bytes)\n", path, size)
+	fmt.Fprintf(w, "version:       %d\n", header.Version)
+	fmt.Fprintf(w, "byte order:    %s\n", byteOrder(info.Foreign))
+	fmt.Fprintf(w, "compressed:    %t\n", info.Compressed)
+	fmt.Fprintf(w, "checksum:      0x%08X\n", header.Checksum)
+	fmt.Fprintf(w, "generation:    %d\n", header.Generation)
+	fmt.Fprintf(w, "ipv4 blocks:   %d\n", header.V4BlockCount)
+	fmt.Fprintf(w, "ipv6 blocks:   %d\n", header.V6BlockCount)
+	fmt.Fprintf(w, "values:        %d in slots of %d bytes\n", header.ValueCount, header.ValueSlotSize)
+	fmt.Fprintf(w, "fingerprint:   0x%016X\n", table.Fingerprint())
+
+	printPrefixCounts(w, table.StructureStats())
+
+	if len(info.Metadata) > 0 {
+		fmt.Fprintln(w, "metadata:")
+		for _, key := range slices.Sorted(maps.Keys(info.Metadata)) {
+			fmt.Fprintf(w, "  %s=%s\n", key, info.Metadata[key])
+		}
+	}
+}
+
+func printPrefixCounts(w io.Writer, structure lpm.StructureStats) {
+	for _, proto := range []struct {
+		name  string
+		stats lpm.ProtoStructure
+	}{{"ipv4", structure.IPv4}, {"ipv6", structure.IPv6}} {
+		prefixes := 0
+		for _, n := range proto.stats.PrefixLengths {
+			prefixes += n
+		}
+		fmt.Fprintf(w, "%s prefixes: %d, slot fill %.1f%%\n", proto.name, prefixes, 100*proto.stats.FillFactor())
+	}
+}
+
+func byteOrder(foreign bool) string {
+	if foreign {
+		return "foreign, converted on load"
+	}
+	return "native"
+}
@@ -0,0 +1,105 @@
+// Command lpm builds, inspects and queries packed storage files:
+//
+//	lpm build -o routes.lpm routes.csv
+//	lpm info routes.lpm
+//	lpm get routes.lpm 10.1.2.3 2001:db8::1
+//	lpm shell routes.lpm
+//
+// Run lpm help for the options of each command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a subcommand of lpm
+type command struct {
+	name    string
+	usage   string // arguments after the command name
+	summary string
+	run     func(fs *flag.FlagSet, args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"build", "[-o file.lpm] [-format csv|json] [-compress] [-meta key=value]... [input]",
+		"build packed storage from cidr,value CSV rows or a JSON list of entries", runBuild},
+	{"info", "file.lpm", "print the header, metadata and statistics of packed storage", runInfo},
+	{"get", "file.lpm addr...", "print the value of the longest prefix containing each address", runGet},
+	{"shell", "file.lpm", "map the storage and read lookup, explain, list-subnets and stats commands from standard input", runShell},
+}
+
+// errUsage means the command line was malformed; the usage has been printed
+var errUsage = errors.New("usage")
+
+// errNotFound means some lookups found no prefix
+var errNotFound = errors.New("not found")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit status: 1 if the command failed or
+// some address was not found, 2 if the command line was malformed
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet("lpm "+cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "Usage: lpm %s %s\n\n%s\n", cmd.name, cmd.usage, cmd.summary)
+			fs.PrintDefaults()
+		}
+		err := cmd.run(fs, args[1:], stdout)
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		case errors.Is(err, errNotFound):
+			return 1
+		}
+		fmt.Fprintf(stderr, "lpm %s: %v\n", cmd.name, err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "lpm: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: lpm <command> [arguments]")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  lpm %s %s\n    \t%s\n", cmd.name, cmd.usage, cmd.summary)
+	}
+}
+
+// parseFlags parses the flags of a command, requiring at least minArgs arguments after them
+func parseFlags(fs *flag.FlagSet, args []string, minArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() < minArgs {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
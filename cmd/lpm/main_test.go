@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCommand runs the command line and returns its exit status and output
+func runCommand(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	status := run(args, &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestBuildInfoGet tests building storage from CSV and JSON, inspecting and querying it
+func TestBuildInfoGet(t *testing.T) {
+	inputs := map[string]string{
+		"routes.csv": "cidr,value\n# private ranges\n10.0.0.0/8,private\n10.1.0.0/16,\"dc, east\"\n2001:db8::/32,doc\n",
+		"routes.json": `[{"cidr":"10.0.0.0/8","value":"private"},{"cidr":"10.1.0.0/16","value":"dc, east"},
+			{"cidr":"2001:db8::/32","value":"doc"}]`,
+	}
+	for name, content := range inputs {
+		t.Run(name, func(t *testing.T) {
+			input := writeFile(t, name, content)
+			output := filepath.Join(t.TempDir(), "routes.lpm")
+			if status, _, stderr := runCommand(t, "build", "-o", output, "-meta", "source=test", input); status != 0 {
+				t.Fatalf("build exited with %d: %s", status, stderr)
+			}
+
+			status, stdout, stderr := runCommand(t, "info", output)
+			if status != 0 {
+				t.Fatalf("info exited with %d: %s", status, stderr)
+			}
+			for _, want := range []string{"ipv4 prefixes: 2", "ipv6 prefixes: 1", "values:        3", "source=test"} {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("info output lacks %q:\n%s", want, stdout)
+				}
+			}
+
+			status, stdout, _ = runCommand(t, "get", output, "10.1.2.3", "10.2.0.1", "2001:db8::1", "192.0.2.1")
+			if status != 1 {
+				t.Errorf("get with a missing address exited with %d, want 1", status)
+			}
+			want := "10.1.2.3 \"dc, east\"\n10.2.0.1 \"private\"\n2001:db8::1 \"doc\"\n192.0.2.1 not found\n"
+			if stdout != want {
+				t.Errorf("get output = %q, want %q", stdout, want)
+			}
+			if status, _, _ := runCommand(t, "get", output, "10.0.0.1"); status != 0 {
+				t.Errorf("get exited with %d, want 0", status)
+			}
+		})
+	}
+}
+
+// TestBuildCompressed tests building compressed storage to standard output
+func TestBuildCompressed(t *testing.T) {
+	input := writeFile(t, "routes.csv", "10.0.0.0/8,private\n")
+	status, stdout, stderr := runCommand(t, "build", "-compress", input)
+	if status != 0 {
+		t.Fatalf("build exited with %d: %s", status, stderr)
+	}
+	output := writeFile(t, "routes.lpm.zst", stdout)
+	if status, stdout, _ := runCommand(t, "info", output); status != 0 || !strings.Contains(stdout, "compressed:    true") {
+		t.Errorf("info exited with %d and output:\n%s", status, stdout)
+	}
+	if status, stdout, _ := runCommand(t, "get", output, "10.0.0.1"); status != 0 || stdout != "10.0.0.1 \"private\"\n" {
+		t.Errorf("get exited with %d and output %q", status, stdout)
+	}
+}
+
+// TestErrors tests the exit status and messages of failing command lines
+func TestErrors(t *testing.T) {
+	badCSV := writeFile(t, "bad.csv", "10.0.0.0/8,a\n10.0.0.0/33,b\n")
+	tests := []struct {
+		args   []string
+		status int
+		stderr string
+	}{
+		{nil, 2, "Usage: lpm <command>"},
+		{[]string{"frobnicate"}, 2, "unknown command"},
+		{[]string{"get", "missing.lpm"}, 2, "Usage: lpm get"},
+		{[]string{"get", "missing.lpm", "10.0.0.1"}, 1, "missing.lpm"},
+		{[]string{"get", "missing.lpm", "not-an-address"}, 1, "not-an-address"},
+		{[]string{"build", "-format", "xml", badCSV}, 1, "unknown input format"},
+		{[]string{"build", badCSV}, 1, "line 2"},
+		{[]string{"build", "-meta", "novalue", badCSV}, 2, "want key=value"},
+	}
+	for _, tt := range tests {
+		status, _, stderr := runCommand(t, tt.args...)
+		if status != tt.status || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("lpm %v exited with %d and %q, want %d and %q", tt.args, status, stderr, tt.status, tt.stderr)
+		}
+	}
+}
+
+// TestShell tests the commands of the shell against a mapped table
+func TestShell(t *testing.T) {
+	input := writeFile(t, "routes.csv", "10.0.0.0/8,private\n10.1.0.0/16,east\n10.1.2.0/24,rack\n2001:db8::/32,doc\n")
+	output := filepath.Join(t.TempDir(), "routes.lpm")
+	if status, _, stderr := runCommand(t, "build", "-o", output, input); status != 0 {
+		t.Fatalf("build exited with %d: %s", status, stderr)
+	}
+	table, closer, err := openTable(output)
+	if err != nil {
+		t.Fatalf("openTable failed: %v", err)
+	}
+	defer closer.Close()
+
+	commands := strings.Join([]string{
+		"lookup 10.1.2.3 192.0.2.1",
+		"",
+		"# comment",
+		"list-subnets 10.1.0.0/16",
+		"explain 10.1.2.3",
+		"stats",
+		"lookup not-an-address",
+		"frobnicate",
+		"quit",
+		"lookup 10.0.0.1",
+	}, "\n")
+	var stdout bytes.Buffer
+	if err := shell(table, strings.NewReader(commands), &stdout, "> "); err != nil {
+		t.Fatalf("shell failed: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"> 10.1.2.3 \"rack\"\n192.0.2.1 not found\n",
+		"10.1.0.0/16 \"east\"\n10.1.2.0/24 \"rack\"\n2 prefixes\n",
+		table.Explain(netip.MustParseAddr("10.1.2.3")).String(),
+		"ipv4 blocks:   3 (3 shared, 0 dynamic)",
+		"ipv4 prefixes: 3",
+		"error: ParseAddr(\"not-an-address\")",
+		"error: unknown command \"frobnicate\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("shell output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "10.0.0.1") {
+		t.Errorf("shell ran commands after quit:\n%s", out)
+	}
+}
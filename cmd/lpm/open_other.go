@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+
+	"github.com/sakateka/lpm"
+)
+
+// openTable loads the storage file at path into memory, as there is no mmap support
+func openTable(path string) (*lpm.LPM, io.Closer, error) {
+	table, err := lpm.LoadFromFile(path, lpm.ReadOnly())
+	if err != nil {
+		return nil, nil, err
+	}
+	return table, io.NopCloser(nil), nil
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+
+	"github.com/sakateka/lpm"
+	"github.com/sakateka/lpm/shm"
+)
+
+// openTable maps the storage file at path, so inspecting a large table neither reads it
+// whole nor copies it
+func openTable(path string) (*lpm.LPM, io.Closer, error) {
+	return shm.OpenFile(path, lpm.ReadOnly())
+}
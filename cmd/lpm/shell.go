@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+// shellCommands are the commands of lpm shell, in the order help lists them
+var shellCommands = []struct {
+	names   []string
+	usage   string
+	summary string
+}{
+	{[]string{"lookup", "get"}, "addr...", "print the value of the longest prefix containing each address"},
+	{[]string{"explain"}, "addr", "print the blocks and slots the lookup of addr walks through"},
+	{[]string{"list-subnets", "subnets"}, "prefix", "print the prefixes inside prefix with their values"},
+	{[]string{"stats"}, "", "print the block, value and prefix counts of the table"},
+	{[]string{"help"}, "", "print this list"},
+	{[]string{"quit", "exit"}, "", "leave the shell, as does end of input"},
+}
+
+func runShell(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	table, closer, err := openTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	prompt := ""
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		prompt = "lpm> "
+	}
+	return shell(table, os.Stdin, stdout, prompt)
+}
+
+// shell runs the commands read line by line from r against the table until the input ends
+// or a quit command, printing prompt before every line. Failing commands print an error
+// and the shell goes on.
+func shell(table *lpm.LPM, r io.Reader, stdout io.Writer, prompt string) error {
+	w := bufio.NewWriter(stdout)
+	scanner := bufio.NewScanner(r)
+	for {
+		if prompt != "" {
+			fmt.Fprint(w, prompt)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			if prompt != "" {
+				fmt.Fprintln(w)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			return scanner.Err()
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return w.Flush()
+		}
+		if err := shellCommand(table, w, fields[0], fields[1:]); err != nil {
+			fmt.Fprintf(w, "error: %v\n", err)
+		}
+	}
+}
+
+func shellCommand(table *lpm.LPM, w io.Writer, cmd string, args []string) error {
+	switch cmd {
+	case "lookup", "get":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: %s addr...", cmd)
+		}
+		for _, arg := range args {
+			addr, err := netip.ParseAddr(arg)
+			if err != nil {
+				return err
+			}
+			if value, found := table.Lookup(addr); found {
+				fmt.Fprintf(w, "%s %q\n", addr, value)
+			} else {
+				fmt.Fprintf(w, "%s not found\n", addr)
+			}
+		}
+	case "explain":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: explain addr")
+		}
+		addr, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, table.Explain(addr))
+	case "list-subnets", "subnets":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s prefix", cmd)
+		}
+		prefix, err := netip.ParsePrefix(args[0])
+		if err != nil {
+			return err
+		}
+		listSubnets(w, table, prefix.Masked())
+	case "stats":
+		if len(args) != 0 {
+			return fmt.Errorf("usage: stats")
+		}
+		printStats(w, table)
+	case "help":
+		for _, c := range shellCommands {
+			fmt.Fprintf(w, "  %s %s\n    \t%s\n", strings.Join(c.names, ", "), c.usage, c.summary)
+		}
+	default:
+		return fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	return nil
+}
+
+// listSubnets prints the entries of the table inside prefix, including prefix itself
+func listSubnets(w io.Writer, table *lpm.LPM, prefix netip.Prefix) {
+	count := 0
+	for _, e := range table.Entries() {
+		if e.Prefix.Bits() < prefix.Bits() || !prefix.Contains(e.Prefix.Addr()) {
+			continue
+		}
+		count++
+		if e.Tombstone {
+			fmt.Fprintf(w, "%s tombstone\n", e.Prefix)
+		} else {
+			fmt.Fprintf(w, "%s %q\n", e.Prefix, e.Value)
+		}
+	}
+	fmt.Fprintf(w, "%d prefixes\n", count)
+}
+
+func printStats(w io.Writer, table *lpm.LPM) {
+	stats := table.Stats()
+	fmt.Fprintf(w, "generation:    %d\n", table.Generation())
+	fmt.Fprintf(w, "ipv4 blocks:   %d (%d shared, %d dynamic)\n", stats.IPv4Blocks, stats.IPv4SharedBlocks, stats.IPv4DynamicBlocks)
+	fmt.Fprintf(w, "ipv6 blocks:   %d (%d shared, %d dynamic)\n", stats.IPv6Blocks, stats.IPv6SharedBlocks, stats.IPv6DynamicBlocks)
+	fmt.Fprintf(w, "values:        %d (%d shared, %d dynamic)\n", stats.Values, stats.SharedValues, stats.DynamicValues)
+	fmt.Fprintf(w, "size:          %d bytes\n", stats.TotalSize)
+	printPrefixCounts(w, table.StructureStats())
+}
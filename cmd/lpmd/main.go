@@ -0,0 +1,76 @@
+//go:build unix
+
+// Command lpmd serves lookups in a packed storage file over HTTP:
+//
+//	lpmd -file /var/lib/routes.lpm -listen :8080
+//
+//	GET  /lookup?ip=10.1.2.3  {"ip":"10.1.2.3","value":"DC6","found":true}
+//	GET  /stats               Stats and generation of the table and the last reload error
+//	POST /reload              check the file right away instead of waiting for the next poll
+//
+// The file is mapped with shm.Reloader and mapped again whenever it is replaced, e.g. by
+// lpm.SaveToFile or lpm build, so lookups switch to the new table atomically.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sakateka/lpm"
+	"github.com/sakateka/lpm/shm"
+)
+
+func main() {
+	file := flag.String("file", "", "packed storage `file` to serve")
+	listen := flag.String("listen", ":8080", "HTTP listen `address`")
+	interval := flag.Duration("interval", 10*time.Second, "how often to check the file for a replacement")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(*file, *listen, *interval, logger); err != nil {
+		logger.Error("lpmd failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(file, listen string, interval time.Duration, logger *slog.Logger) error {
+	if file == "" {
+		return errors.New("-file is required")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid -interval %v", interval)
+	}
+	reloader, err := shm.NewReloader(file, interval, lpm.ReadOnly(), lpm.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+	defer reloader.Close()
+
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           newServer(reloader, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	logger.Info("serving", "file", file, "listen", listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
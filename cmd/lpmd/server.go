@@ -0,0 +1,85 @@
+//go:build unix
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/sakateka/lpm"
+)
+
+// tables is the table source the server answers from, implemented by shm.Reloader
+type tables interface {
+	Load() *lpm.LPM
+	Reload() (bool, error)
+	Err() error
+}
+
+// lookupResponse is the body of GET /lookup
+type lookupResponse struct {
+	IP    netip.Addr `json:"ip"`
+	Value string     `json:"value,omitempty"`
+	Found bool       `json:"found"`
+}
+
+// statsResponse is the body of GET /stats
+type statsResponse struct {
+	Stats       lpm.Stats `json:"stats"`
+	Generation  uint64    `json:"generation"`
+	ReloadError string    `json:"reload_error,omitempty"`
+}
+
+// reloadResponse is the body of POST /reload
+type reloadResponse struct {
+	Reloaded   bool   `json:"reloaded"`
+	Generation uint64 `json:"generation"`
+}
+
+// errorResponse is the body of failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func newServer(t tables, logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lookup", func(w http.ResponseWriter, r *http.Request) {
+		addr, err := netip.ParseAddr(r.URL.Query().Get("ip"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+		resp := lookupResponse{IP: addr}
+		resp.Value, resp.Found = t.Load().Lookup(addr)
+		writeJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		table := t.Load()
+		resp := statsResponse{Stats: table.Stats(), Generation: table.Generation()}
+		if err := t.Err(); err != nil {
+			resp.ReloadError = err.Error()
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		reloaded, err := t.Reload()
+		if err != nil {
+			logger.Warn("reload failed", "error", err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{err.Error()})
+			return
+		}
+		if reloaded {
+			logger.Info("reloaded table", "generation", t.Load().Generation())
+		}
+		writeJSON(w, http.StatusOK, reloadResponse{Reloaded: reloaded, Generation: t.Load().Generation()})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,93 @@
+//go:build unix
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sakateka/lpm"
+	"github.com/sakateka/lpm/shm"
+)
+
+func saveTable(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	table := lpm.New()
+	for prefix, value := range entries {
+		if err := table.Insert(netip.MustParsePrefix(prefix), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+}
+
+// request sends a request to the server and decodes the JSON response into body
+func request(t *testing.T, srv *httptest.Server, method, path string, body any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+	return resp.StatusCode
+}
+
+// TestServer tests lookups, stats and reloads of a replaced storage file
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.lpm")
+	saveTable(t, path, map[string]string{"10.0.0.0/8": "private", "2001:db8::/32": "doc"})
+
+	reloader, err := shm.NewReloader(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	defer reloader.Close()
+	srv := httptest.NewServer(newServer(reloader, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer srv.Close()
+
+	var lookup lookupResponse
+	if status := request(t, srv, "GET", "/lookup?ip=10.1.2.3", &lookup); status != http.StatusOK ||
+		lookup != (lookupResponse{IP: netip.MustParseAddr("10.1.2.3"), Value: "private", Found: true}) {
+		t.Errorf("GET /lookup = %d %+v, want a match of private", status, lookup)
+	}
+	if status := request(t, srv, "GET", "/lookup?ip=192.0.2.1", &lookup); status != http.StatusOK || lookup.Found {
+		t.Errorf("GET /lookup = %d %+v, want no match", status, lookup)
+	}
+	var failed errorResponse
+	if status := request(t, srv, "GET", "/lookup?ip=bogus", &failed); status != http.StatusBadRequest || failed.Error == "" {
+		t.Errorf("GET /lookup of a bad address = %d %+v, want %d", status, failed, http.StatusBadRequest)
+	}
+
+	var stats statsResponse
+	if status := request(t, srv, "GET", "/stats", &stats); status != http.StatusOK || stats.Stats != reloader.Load().Stats() {
+		t.Errorf("GET /stats = %d %+v, want the stats of the table", status, stats)
+	}
+
+	var reload reloadResponse
+	if status := request(t, srv, "POST", "/reload", &reload); status != http.StatusOK || reload.Reloaded {
+		t.Errorf("POST /reload of an unchanged file = %d %+v, want no reload", status, reload)
+	}
+	saveTable(t, path, map[string]string{"10.0.0.0/8": "replaced"})
+	if status := request(t, srv, "POST", "/reload", &reload); status != http.StatusOK || !reload.Reloaded {
+		t.Errorf("POST /reload of a replaced file = %d %+v, want a reload", status, reload)
+	}
+	if request(t, srv, "GET", "/lookup?ip=10.1.2.3", &lookup); lookup.Value != "replaced" {
+		t.Errorf("GET /lookup after reload = %+v, want replaced", lookup)
+	}
+}
@@ -0,0 +1,166 @@
+package lpm
+
+// collapsedNone marks blocks that do not collapse in a compaction plan. A block
+// reference is never the result of a collapse, so it cannot clash with a value.
+const collapsedNone = blockRefMask
+
+// collapsePlan computes, for every block of the protocol trie, the value its parent slot
+// takes if the block collapses, or collapsedNone. A block collapses when all of its slots
+// resolve to the same value index, after collapsing its own children. The parent slot keeps
+// the broadest prefix length among the slots, but no longer than the prefix covering the
+// block, so Entries reports the aggregate rather than one of its parts.
+func (t *trie) collapsePlan(proto int) []uint32 {
+	plan := make([]uint32, t.blockCount(proto))
+	for i := range plan {
+		plan[i] = collapsedNone
+	}
+	if len(plan) > 0 {
+		t.planBlock(proto, t.root[proto], 0, plan)
+	}
+	return plan
+}
+
+func (t *trie) planBlock(proto int, blockIdx int, depth int, plan []uint32) {
+	blk := t.getBlockRef(proto, blockIdx)
+	for _, encoded := range blk {
+		if isBlockRef(encoded) {
+			t.planBlock(proto, decodeBlockRef(encoded), depth+1, plan)
+		}
+	}
+
+	// The root block holds the whole address space and has no parent slot
+	if depth == 0 {
+		return
+	}
+
+	first := resolveSlot(blk[0], plan)
+	if isBlockRef(first) {
+		return
+	}
+	firstIdx, prefixLen := decodeValue(first)
+	for _, encoded := range blk[1:] {
+		encoded = resolveSlot(encoded, plan)
+		if isBlockRef(encoded) || isInvalid(encoded) != isInvalid(first) {
+			return
+		}
+		valueIdx, slotLen := decodeValue(encoded)
+		if valueIdx != firstIdx {
+			return
+		}
+		prefixLen = min(prefixLen, slotLen)
+	}
+
+	if isInvalid(first) {
+		plan[blockIdx] = first
+		return
+	}
+	plan[blockIdx] = encodeValue(firstIdx, min(prefixLen, depth*8))
+}
+
+// resolveSlot returns the value a slot holds once the block it refers to, if any, is collapsed
+func resolveSlot(encoded uint32, plan []uint32) uint32 {
+	if isBlockRef(encoded) {
+		if collapsed := plan[decodeBlockRef(encoded)]; collapsed != collapsedNone {
+			return collapsed
+		}
+	}
+	return encoded
+}
+
+// compact collapses blocks as computed by collapsePlan and renumbers the remaining blocks,
+// returning the number of blocks removed. Protocols with nothing to collapse are left as
+// they are, so their shared blocks stay in shared storage; otherwise the remaining blocks
+// are copied to dynamic memory.
+func (t *trie) compact() int {
+	removed := 0
+	for _, proto := range []int{v4LPM, v6LPM} {
+		plan := t.collapsePlan(proto)
+
+		// Collapsed blocks, every block below them and shared blocks replaced by
+		// copies are unreachable
+		remap := make([]int, len(plan))
+		for i := range remap {
+			remap[i] = -1
+		}
+		var order []int
+		var mark func(blockIdx int)
+		mark = func(blockIdx int) {
+			remap[blockIdx] = 0
+			order = append(order, blockIdx)
+			for _, encoded := range t.getBlockRef(proto, blockIdx) {
+				if isBlockRef(encoded) && plan[decodeBlockRef(encoded)] == collapsedNone {
+					mark(decodeBlockRef(encoded))
+				}
+			}
+		}
+		if len(plan) > 0 {
+			mark(t.root[proto])
+		}
+		if len(order) == len(plan) {
+			continue
+		}
+
+		// Keep the original relative order of the blocks
+		kept := 0
+		for i := range remap {
+			if remap[i] == 0 {
+				remap[i] = kept
+				kept++
+			}
+		}
+
+		blocks := make([]*LPMBlock, 0, kept)
+		for blockIdx := range plan {
+			if remap[blockIdx] >= 0 {
+				blocks = append(blocks, t.getBlockRef(proto, blockIdx))
+			}
+		}
+		blocks = cloneBlocks(blocks)
+		for _, blk := range blocks {
+			for slot, encoded := range blk {
+				if isBlockRef(encoded) {
+					if collapsed := plan[decodeBlockRef(encoded)]; collapsed != collapsedNone {
+						blk[slot] = collapsed
+					} else {
+						blk[slot] = encodeBlockRef(remap[decodeBlockRef(encoded)])
+					}
+				}
+			}
+		}
+
+		removed += len(plan) - kept
+		t.shared[proto] = nil
+		t.dynamic[proto] = blocks
+		t.root[proto] = remap[t.root[proto]]
+		t.copied[proto] = 0
+		t.frozen[proto] = 0
+		t.free[proto] = nil
+	}
+	if removed > 0 {
+		t.generation++
+		t.refreshStride16(0, stride16Size-1)
+	}
+	return removed
+}
+
+// Compact collapses blocks in which every address maps to the same value into a single
+// slot of the parent block and drops the blocks no longer referenced, returning how many
+// blocks were removed. Lookups return the same values as before.
+//
+// Prefixes whose value is repeated by the prefixes around them are forgotten, so a later
+// Insert of a broader prefix may override addresses that the forgotten prefixes would have
+// kept. Compact is meant to run once the trie is complete, e.g. right before packing.
+// If anything is collapsed, the blocks of the affected protocol move out of shared storage.
+func (m *LPM) Compact() int {
+	return m.compact()
+}
+
+// Compact collapses blocks in which every address maps to the same value, see LPM.Compact
+func (m *Numeric[T]) Compact() int {
+	return m.compact()
+}
+
+// Compact collapses blocks in which every address has the same tags, see LPM.Compact
+func (m *Tags) Compact() int {
+	return m.compact()
+}
@@ -0,0 +1,52 @@
+package lpm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMagic starts every zstd frame. It cannot be mistaken for a storage header, whose
+// magic is one of two fixed byte sequences.
+var zstdMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+// zstdDecoder decompresses whole storage blobs; DecodeAll is safe for concurrent use
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+// isCompressed reports whether data starts with a zstd frame
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, zstdMagic)
+}
+
+// PackCompressed streams the storage PackTo produces to w as a zstd frame and returns
+// the number of compressed bytes written. The block arrays consist mostly of repeated
+// slots and compress very well, which matters when shipping tables over slow links.
+//
+// NewWithSharedStorage, NewFromReader and LoadFromFile detect compressed storage and
+// decompress it into memory the LPM owns, so it is not shared with other processes.
+func (m *LPM) PackCompressed(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	enc, err := zstd.NewWriter(cw, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := m.PackTo(enc); err != nil {
+		_ = enc.Close()
+		return cw.n, err
+	}
+	if err := enc.Close(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+// decompressStorage decompresses storage written by PackCompressed
+func decompressStorage(data []byte) ([]byte, error) {
+	storage, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing storage: %w", err)
+	}
+	return storage, nil
+}
@@ -0,0 +1,139 @@
+package lpm
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// csvProgressEvery is the default number of rows between calls of CSVOptions.Progress
+const csvProgressEvery = 100_000
+
+// CSVOptions configures how LoadCSV reads rows
+type CSVOptions struct {
+	// CIDRColumn and ValueColumn are the zero-based columns holding the prefix and the
+	// value. If both are zero, the prefix is read from column 0 and the value from column 1.
+	CIDRColumn  int
+	ValueColumn int
+	// Comma is the field delimiter, ',' if zero
+	Comma rune
+	// Comment makes lines starting with it comments, if not zero
+	Comment rune
+	// Header skips the first row
+	Header bool
+	// Malformed is called for rows that cannot be parsed or lack a column, or hold an
+	// invalid prefix, with the line number of the row. Returning nil skips the row, while
+	// an error stops loading and is returned by LoadCSV. If Malformed is nil, the first
+	// malformed row stops loading.
+	Malformed func(line int, err error) error
+	// Progress is called with the number of rows read so far every ProgressEvery rows,
+	// 100000 if zero, and once more when the input ends
+	Progress      func(rows int)
+	ProgressEvery int
+}
+
+// LoadCSV reads a table of prefix and value rows from r, such as the network and location
+// columns of GeoIP CSV databases. Rows are inserted as they are read, so only the trie is
+// kept in memory however many rows there are, and the trie is compacted at the end. Rows
+// are inserted in order, so of rows with equal prefixes the last one wins. Inserts failing
+// with ErrCapacity stop loading regardless of Malformed.
+func LoadCSV(r io.Reader, opts CSVOptions) (*LPM, error) {
+	cidrColumn, valueColumn := opts.CIDRColumn, opts.ValueColumn
+	if cidrColumn == 0 && valueColumn == 0 {
+		valueColumn = 1
+	}
+	progressEvery := opts.ProgressEvery
+	if progressEvery <= 0 {
+		progressEvery = csvProgressEvery
+	}
+
+	cr := csv.NewReader(bufio.NewReaderSize(r, 1<<16))
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.Comment = opts.Comment
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	m := New()
+	malformed := func(line int, err error) error {
+		err = fmt.Errorf("line %d: %w", line, err)
+		if opts.Malformed == nil {
+			return err
+		}
+		return opts.Malformed(line, err)
+	}
+	for rows := 0; ; {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			if opts.Progress != nil {
+				opts.Progress(rows)
+			}
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := malformed(parseErr.Line, parseErr.Err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows++
+		if opts.Progress != nil && rows%progressEvery == 0 {
+			opts.Progress(rows)
+		}
+		if rows == 1 && opts.Header {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) <= max(cidrColumn, valueColumn) {
+			if err := malformed(line, fmt.Errorf("%d columns, want at least %d", len(record), max(cidrColumn, valueColumn)+1)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		prefix, err := netip.ParsePrefix(record[cidrColumn])
+		if err == nil {
+			err = m.Insert(prefix, record[valueColumn])
+		}
+		if errors.Is(err, ErrCapacity) || errors.Is(err, ErrMemoryLimit) {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err != nil {
+			if err := malformed(line, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	m.Compact()
+	return m, nil
+}
+
+// WriteCSV writes the effective table as prefix and value rows, the disjoint prefixes
+// Dump prints, so LoadCSV reads back a trie that answers every lookup the same, including
+// for addresses carved out by tombstones.
+func (m *LPM) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	var err error
+	for _, proto := range []int{v4LPM, v6LPM} {
+		m.effectiveRanges(proto, func(start, end netip.Addr, value string) {
+			for _, prefix := range rangePrefixes(start, end) {
+				if err == nil {
+					err = cw.Write([]string{prefix.String(), value})
+				}
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,48 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Delete removes the prefix inserted with Insert, InsertWithPriority or InsertTombstone,
+// reporting whether it was present. Addresses it covered map as if it had never been
+// inserted: they fall back to the broader and more specific prefixes it shadowed, even
+// those it hid completely. A trie loaded from storage only knows the prefixes Entries
+// reports, as packing drops the shadowed ones. It fails with ErrReadOnly if the LPM was
+// loaded with the ReadOnly option.
+func (m *LPM) Delete(net netip.Prefix) (bool, error) {
+	if m.readOnly {
+		return false, ErrReadOnly
+	}
+	if !net.IsValid() {
+		return false, fmt.Errorf("%w: %v", ErrInvalidPrefix, net)
+	}
+	oldIdx, oldFound := m.watchedValue(net)
+	delete(m.expiries, net.Masked())
+	deleted := m.delete(net, m.priorityByIndex)
+	if deleted && len(m.watchers) > 0 {
+		m.notify(net, oldIdx, oldFound, 0, false)
+	}
+	return deleted, nil
+}
+
+// Delete removes the prefix, see LPM.Delete. Invalid prefixes are never present.
+func (m *Numeric[T]) Delete(net netip.Prefix) bool {
+	if !net.IsValid() {
+		return false
+	}
+	return m.delete(net, noPriority)
+}
+
+// delete drops the prefix from the prefix set and rebuilds the slots it covered
+func (t *trie) delete(net netip.Prefix, priorityOf func(int) uint8) bool {
+	net = net.Masked()
+	t.loadPrefixes()
+	if !t.prefixes.remove(net) {
+		return false
+	}
+	t.generation++
+	t.rebuild(net, priorityOf)
+	return true
+}
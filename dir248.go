@@ -0,0 +1,216 @@
+package lpm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// DIR-24-8 entries: zero for addresses without a match, a next hop ID, or a TBL8 group
+// index with dir248Group set
+const (
+	dir248Group   = 1 << 31
+	dir248Magic   = "D248"
+	dir248Version = 1
+	// dir248HeaderSize is the size of magic, version, byte order mark, group count,
+	// value count and a reserved word
+	dir248HeaderSize = 24
+)
+
+// DIR248 is the IPv4 table of an LPM in the DIR-24-8 layout (Gupta, Lin and McKeown,
+// 1998), as used by DPDK's rte_lpm: TBL24 holds an entry for every /24, and /24s split by
+// longer prefixes point to a group of 256 entries in TBL8, one for every address. Every
+// lookup reads one or two entries at computed indexes, so dataplane code that cannot
+// follow pointers, e.g. XDP programs over array maps or hardware tables, looks addresses
+// up with a few lines:
+//
+//	entry := tbl24[addr>>8]
+//	if entry&(1<<31) != 0 {
+//	    entry = tbl8[(entry&^(1<<31))<<8|addr&0xff]
+//	}
+//	// entry 0: no match, otherwise the value is values[entry-1]
+//
+// Entries hold next hop IDs: the position of the value in Values plus one.
+type DIR248 struct {
+	TBL24  []uint32 // 1<<24 entries, indexed by the first three address bytes
+	TBL8   []uint32 // groups of 256 entries, indexed by the group and the last address byte
+	Values []string // Values[id-1] is the value of next hop id
+}
+
+// DIR248 builds the DIR-24-8 table of the IPv4 addresses of the trie, with next hop IDs
+// assigned in address order. Tombstoned addresses and addresses covered by no prefix get
+// entry 0; IPv6 prefixes are left out.
+func (m *LPM) DIR248() *DIR248 {
+	d := &DIR248{TBL24: make([]uint32, 1<<24)}
+	ids := make(map[string]uint32)
+	m.effectiveRanges(v4LPM, func(start, end netip.Addr, value string) {
+		id, ok := ids[value]
+		if !ok {
+			d.Values = append(d.Values, value)
+			id = uint32(len(d.Values))
+			ids[value] = id
+		}
+		first, last := addr4(start), addr4(end)
+		for slot := first >> 8; slot <= last>>8; slot++ {
+			lo, hi := max(first, slot<<8), min(last, slot<<8|0xff)
+			if lo&0xff == 0 && hi&0xff == 0xff {
+				d.TBL24[slot] = id
+				continue
+			}
+			// Ranges are disjoint, so the slot holds either nothing or a group of earlier ranges
+			if d.TBL24[slot]&dir248Group == 0 {
+				d.TBL24[slot] = dir248Group | uint32(len(d.TBL8)>>8)
+				d.TBL8 = append(d.TBL8, make([]uint32, 256)...)
+			}
+			group := d.TBL8[(d.TBL24[slot]&^dir248Group)<<8:]
+			for addr := lo; addr <= hi; addr++ {
+				group[addr&0xff] = id
+			}
+		}
+	})
+	return d
+}
+
+// addr4 returns an IPv4 address as a uint32 in host byte order
+func addr4(addr netip.Addr) uint32 {
+	return binary.BigEndian.Uint32(addr.AsSlice())
+}
+
+// Lookup returns the value of the address, like LPM.Lookup on the trie the table was
+// built from
+func (d *DIR248) Lookup(addr netip.Addr) (string, bool) {
+	if !addr.Is4() {
+		return "", false
+	}
+	key := addr4(addr)
+	entry := d.TBL24[key>>8]
+	if entry&dir248Group != 0 {
+		entry = d.TBL8[(entry&^dir248Group)<<8|key&0xff]
+	}
+	if entry == 0 {
+		return "", false
+	}
+	return d.Values[entry-1], true
+}
+
+// WriteTo writes the table as a blob dataplane code can map or copy into its tables as is:
+// a header of 32-bit words (magic "D248", version 1, a byte order mark 0x01020304, the
+// number of TBL8 groups and of values, and a reserved zero), TBL24, TBL8, and the values,
+// each a 32-bit length followed by its bytes. Words are written in host byte order, so the
+// blob is used on the host that wrote it without conversion.
+func (d *DIR248) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriterSize(w, 1<<16)
+	header := []byte(dir248Magic)
+	for _, word := range []uint32{dir248Version, byteOrderMark, uint32(len(d.TBL8) >> 8), uint32(len(d.Values)), 0} {
+		header = binary.NativeEndian.AppendUint32(header, word)
+	}
+	n, _ := bw.Write(header)
+	written := int64(n)
+
+	buf := make([]byte, 0, 1<<16)
+	for _, table := range [][]uint32{d.TBL24, d.TBL8} {
+		for len(table) > 0 {
+			chunk := table[:min(len(table), cap(buf)/4)]
+			buf = buf[:0]
+			for _, entry := range chunk {
+				buf = binary.NativeEndian.AppendUint32(buf, entry)
+			}
+			n, _ := bw.Write(buf)
+			written += int64(n)
+			table = table[len(chunk):]
+		}
+	}
+	for _, value := range d.Values {
+		n, _ := bw.Write(binary.NativeEndian.AppendUint32(buf[:0], uint32(len(value))))
+		written += int64(n)
+		n, _ = bw.WriteString(value)
+		written += int64(n)
+	}
+	return written, bw.Flush()
+}
+
+// ReadDIR248 reads a table written by DIR248.WriteTo, on a host of either byte order
+func ReadDIR248(r io.Reader) (*DIR248, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	header := make([]byte, dir248HeaderSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("DIR-24-8 header: %w", err)
+	}
+	if string(header[:4]) != dir248Magic {
+		return nil, fmt.Errorf("DIR-24-8 table: %w", ErrBadMagic)
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.BigEndian.Uint32(header[8:]) == byteOrderMark:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header[8:]) == byteOrderMark:
+		order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("DIR-24-8 table: %w", ErrBadByteOrder)
+	}
+	if version := order.Uint32(header[4:]); version != dir248Version {
+		return nil, fmt.Errorf("DIR-24-8 table version %d: %w", version, ErrVersionMismatch)
+	}
+	groups, valueCount := order.Uint32(header[12:]), order.Uint32(header[16:])
+	if groups > 1<<24 {
+		return nil, fmt.Errorf("DIR-24-8 table of %d groups: %w", groups, ErrCorrupt)
+	}
+
+	d := &DIR248{}
+	var err error
+	if d.TBL24, err = readWords(br, order, 1<<24); err != nil {
+		return nil, fmt.Errorf("DIR-24-8 TBL24: %w", err)
+	}
+	// TBL8 is read in chunks, so a header claiming more groups than the blob holds fails
+	// before they are allocated
+	if d.TBL8, err = readWords(br, order, uint64(groups)<<8); err != nil {
+		return nil, fmt.Errorf("DIR-24-8 TBL8: %w", err)
+	}
+	for _, entry := range d.TBL24 {
+		if entry&dir248Group != 0 && entry&^dir248Group >= groups || entry&dir248Group == 0 && entry > valueCount {
+			return nil, fmt.Errorf("DIR-24-8 TBL24 entry %#x: %w", entry, ErrCorrupt)
+		}
+	}
+	// Groups only hold next hop IDs
+	for _, entry := range d.TBL8 {
+		if entry > valueCount {
+			return nil, fmt.Errorf("DIR-24-8 TBL8 entry %#x: %w", entry, ErrCorrupt)
+		}
+	}
+
+	buf := make([]byte, 4)
+	for range valueCount {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("DIR-24-8 values: %w", err)
+		}
+		size := order.Uint32(buf)
+		if size > maxValueLen {
+			return nil, fmt.Errorf("DIR-24-8 value of %d bytes: %w", size, ErrCorrupt)
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(br, value); err != nil {
+			return nil, fmt.Errorf("DIR-24-8 values: %w", err)
+		}
+		d.Values = append(d.Values, string(value))
+	}
+	return d, nil
+}
+
+// readWords reads count 32-bit words
+func readWords(r io.Reader, order binary.ByteOrder, count uint64) ([]uint32, error) {
+	words := make([]uint32, 0, min(count, 1<<24))
+	buf := make([]byte, 1<<16)
+	for remaining := count; remaining > 0; {
+		chunk := buf[:min(remaining*4, uint64(len(buf)))]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(chunk); i += 4 {
+			words = append(words, order.Uint32(chunk[i:]))
+		}
+		remaining -= uint64(len(chunk) / 4)
+	}
+	return words, nil
+}
@@ -0,0 +1,150 @@
+package lpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// DOTOptions selects the part of the trie WriteDOT renders
+type DOTOptions struct {
+	// Prefix restricts the graph to the slots the prefix covers in the block holding its
+	// last byte, and the blocks below them. The zero Prefix renders both tries whole.
+	Prefix netip.Prefix
+	// MaxDepth is the number of block levels rendered, 0 for all
+	MaxDepth int
+}
+
+// WriteDOT renders the structure of the trie in the Graphviz DOT language, for inspecting
+// it visually, e.g. with dot -Tsvg. Every block is a record node listing its runs of slots
+// holding the same content; block references point to the blocks below, and value slots
+// to one leaf per value, labeled with the prefix length the slot was set with.
+func (m *LPM) WriteDOT(w io.Writer, opts DOTOptions) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph lpm {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=record, fontname=monospace];")
+
+	protos := []int{v4LPM, v6LPM}
+	if opts.Prefix.IsValid() {
+		protos = protos[:1]
+		if opts.Prefix.Addr().Is6() {
+			protos = []int{v6LPM}
+		}
+	}
+	for _, proto := range protos {
+		if m.blockCount(proto) == 0 {
+			continue
+		}
+		d := dotWriter{m: m, w: bw, proto: proto, maxDepth: opts.MaxDepth, visited: make(map[int]bool), leaves: make(map[int]bool)}
+		d.name = "v4"
+		if proto == v6LPM {
+			d.name = "v6"
+		}
+		blockIdx, depth, startIdx, endIdx := m.root[proto], 0, uint8(0), uint8(0xff)
+		if opts.Prefix.IsValid() {
+			blockIdx, depth, startIdx, endIdx = m.dotStart(proto, opts.Prefix.Masked())
+		}
+		d.block(blockIdx, depth, 0, startIdx, endIdx)
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotStart returns the block holding the last byte of the prefix and the slots the prefix
+// covers in it, or the single slot the path ends in if it ends in a value slot earlier
+func (m *LPM) dotStart(proto int, net netip.Prefix) (blockIdx int, depth int, startIdx, endIdx uint8) {
+	blockIdx = m.root[proto]
+	for idx, inBlockIdx := range net.Addr().AsSlice() {
+		if tail := (idx+1)*8 - net.Bits(); tail >= 0 {
+			mask := uint8(0xff << tail)
+			return blockIdx, idx, inBlockIdx & mask, inBlockIdx | ^mask
+		}
+		encoded := m.getValue(proto, blockIdx, inBlockIdx)
+		if !isBlockRef(encoded) {
+			return blockIdx, idx, inBlockIdx, inBlockIdx
+		}
+		blockIdx = decodeBlockRef(encoded)
+	}
+	panic("unreachable: prefix length exceeds address length")
+}
+
+// dotWriter renders the blocks of one protocol trie
+type dotWriter struct {
+	m        *LPM
+	w        *bufio.Writer
+	proto    int
+	name     string // node name prefix of the protocol
+	maxDepth int
+	visited  map[int]bool // blocks rendered
+	leaves   map[int]bool // value indices rendered
+}
+
+// block renders the slots [startIdx, endIdx] of a block at the given address byte and
+// the blocks below them, level levels below the first rendered block
+func (d *dotWriter) block(blockIdx int, depth int, level int, startIdx, endIdx uint8) {
+	d.visited[blockIdx] = true
+	blk := d.m.getBlockRef(d.proto, blockIdx)
+
+	shared := ""
+	if blockIdx < len(d.m.shared[d.proto]) {
+		shared = " (shared)"
+	}
+	var fields []string
+	fields = append(fields, fmt.Sprintf("%s block %d%s, byte %d", d.name, blockIdx, shared, depth))
+	type run struct {
+		start, end int
+		encoded    uint32
+	}
+	var runs []run
+	for slot := int(startIdx); slot <= int(endIdx); slot++ {
+		if n := len(runs); n > 0 && runs[n-1].encoded == blk[slot] && !isBlockRef(blk[slot]) {
+			runs[n-1].end = slot
+			continue
+		}
+		runs = append(runs, run{slot, slot, blk[slot]})
+	}
+	for _, r := range runs {
+		slots := fmt.Sprint(r.start)
+		if r.end != r.start {
+			slots = fmt.Sprintf("%d-%d", r.start, r.end)
+		}
+		fields = append(fields, fmt.Sprintf("<s%d> %s: %s", r.start, slots, slotKind(r.encoded)))
+	}
+	fmt.Fprintf(d.w, "  %s_b%d [label=\"%s\"];\n", d.name, blockIdx, strings.Join(fields, "|"))
+
+	for _, r := range runs {
+		port := fmt.Sprintf("%s_b%d:s%d", d.name, blockIdx, r.start)
+		switch kind := slotKind(r.encoded); kind {
+		case SlotBlockRef:
+			child := decodeBlockRef(r.encoded)
+			if d.maxDepth > 0 && level+1 >= d.maxDepth {
+				continue
+			}
+			fmt.Fprintf(d.w, "  %s -> %s_b%d;\n", port, d.name, child)
+			if !d.visited[child] {
+				d.block(child, depth+1, level+1, 0, 0xff)
+			}
+		case SlotValue, SlotTombstone:
+			valueIdx, prefixLen := decodeValue(r.encoded)
+			if !d.leaves[valueIdx] {
+				d.leaves[valueIdx] = true
+				label := "tombstone"
+				if kind == SlotValue {
+					value, _ := d.m.getValueByIndex(valueIdx)
+					label = fmt.Sprintf("%d: %s", valueIdx, dotEscape(value))
+				}
+				fmt.Fprintf(d.w, "  %s_v%d [shape=ellipse, label=\"%s\"];\n", d.name, valueIdx, label)
+			}
+			fmt.Fprintf(d.w, "  %s -> %s_v%d [label=\"/%d\"];\n", port, d.name, valueIdx, prefixLen)
+		}
+	}
+}
+
+// dotEscape quotes a value for a DOT string
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,121 @@
+package lpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// Dump writes the effective routing table reconstructed from the blocks: disjoint prefixes
+// covering every address a lookup finds a value for, one "prefix value" line each with the
+// value quoted, sorted by address family and address. Neighbouring addresses with equal
+// values are merged into the fewest prefixes, so tries answering every lookup the same dump
+// the same lines, however they were built, and the output diffs against the intended table.
+// Addresses that are tombstoned or covered by no prefix are left out.
+func (m *LPM) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, proto := range []int{v4LPM, v6LPM} {
+		m.effectiveRanges(proto, func(start, end netip.Addr, value string) {
+			for _, prefix := range rangePrefixes(start, end) {
+				fmt.Fprintf(bw, "%s %q\n", prefix, value)
+			}
+		})
+	}
+	return bw.Flush()
+}
+
+// EffectiveEntries returns the entries Dump writes: disjoint prefixes covering every
+// address a lookup finds a value for, with neighbouring addresses of equal values merged,
+// sorted by address family and address. The entries carry no tombstones or priorities, so
+// consumers with exact-match or plain longest-prefix semantics, e.g. kernel maps, answer
+// every lookup like the trie.
+func (m *LPM) EffectiveEntries() []PrefixValue {
+	var result []PrefixValue
+	for _, proto := range []int{v4LPM, v6LPM} {
+		m.effectiveRanges(proto, func(start, end netip.Addr, value string) {
+			for _, prefix := range rangePrefixes(start, end) {
+				result = append(result, PrefixValue{Prefix: prefix, Value: value})
+			}
+		})
+	}
+	return result
+}
+
+// effectiveRanges calls fn for every maximal range of consecutive addresses of the protocol
+// trie that lookups map to the same value, in address order
+func (m *LPM) effectiveRanges(proto int, fn func(start, end netip.Addr, value string)) {
+	var runStart, runEnd netip.Addr
+	var runValue string
+	flush := func() {
+		if runStart.IsValid() {
+			fn(runStart, runEnd, runValue)
+		}
+		runStart = netip.Addr{}
+	}
+	m.walkValues(proto, func(addr [16]byte, depth int, encoded uint32) {
+		valueIdx, _ := decodeValue(encoded)
+		if valueIdx == tombstoneIdx {
+			flush()
+			return
+		}
+		value, _ := m.getValueByIndex(valueIdx)
+		start := pathAddr(proto, addr)
+		for i := depth + 1; i < len(addr); i++ {
+			addr[i] = 0xff
+		}
+		end := pathAddr(proto, addr)
+		if runStart.IsValid() && value == runValue && runEnd.Next() == start {
+			runEnd = end
+			return
+		}
+		flush()
+		runStart, runEnd, runValue = start, end, value
+	})
+	flush()
+}
+
+// pathAddr returns the address of the path bytes of a protocol trie
+func pathAddr(proto int, addr [16]byte) netip.Addr {
+	if proto == v4LPM {
+		return netip.AddrFrom4([4]byte(addr[:4]))
+	}
+	return netip.AddrFrom16(addr)
+}
+
+// rangePrefixes splits the range [start, end] into the fewest prefixes
+func rangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		// Widen the prefix while it starts at start and ends within the range
+		bits := start.BitLen()
+		for bits > 0 {
+			wider := netip.PrefixFrom(start, bits-1)
+			if wider.Masked().Addr() != start || lastAddr(wider).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, prefix)
+		last := lastAddr(prefix)
+		if last == end {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// lastAddr returns the last address of a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr().As16()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	for i := len(addr) - 1; hostBits > 0; i-- {
+		addr[i] |= byte(0xff >> max(8-hostBits, 0))
+		hostBits -= 8
+	}
+	if prefix.Addr().Is4() {
+		return netip.AddrFrom4([4]byte(addr[12:]))
+	}
+	return netip.AddrFrom16(addr)
+}
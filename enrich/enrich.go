@@ -0,0 +1,141 @@
+// Package enrich annotates the source and destination addresses of packets and flow
+// records with lookups in a table, in batches, e.g. to tag captured traffic or exported
+// flows with the customer, ASN or country of their ends:
+//
+//	decoder := enrich.NewDecoder(layers.LayerTypeEthernet)
+//	annotations = decoder.Frames(table, frames, annotations[:0])
+//	for i, a := range annotations {
+//	    log.Printf("frame %d: %s (%s) -> %s (%s)", i, a.Src, a.SrcValue, a.Dst, a.DstValue)
+//	}
+//
+// Decoder decodes raw frames, as read from a pcap handle or an AF_PACKET ring, with a
+// gopacket.DecodingLayerParser; Packets annotates packets gopacket already decoded; Flows
+// annotates IPFIX, NetFlow or sFlow records by their address fields. Lookups go through
+// the allocation-free lookup path, and annotations are appended to a slice the caller
+// reuses, so a batch allocates nothing once the slice has grown to the batch size, except
+// for the errors gopacket returns for malformed frames.
+package enrich
+
+import (
+	"net/netip"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Table is what addresses are looked up in, e.g. an *lpm.LPM, or an *lpm.Atomic
+// holding the current table. Load an lpm.Atomic once per batch for all lookups of
+// the batch to see the same table.
+type Table interface {
+	Lookup(addr netip.Addr) (string, bool)
+}
+
+// Annotation holds the addresses of a packet or record and the values they map to. The
+// addresses are invalid, and nothing is found, for packets without an IP header.
+type Annotation struct {
+	Src      netip.Addr
+	Dst      netip.Addr
+	SrcValue string
+	DstValue string
+	SrcFound bool
+	DstFound bool
+}
+
+// annotate returns the annotation of a source and a destination address
+func annotate(table Table, src, dst netip.Addr) Annotation {
+	a := Annotation{Src: src, Dst: dst}
+	if src.IsValid() {
+		a.SrcValue, a.SrcFound = table.Lookup(src)
+	}
+	if dst.IsValid() {
+		a.DstValue, a.DstFound = table.Lookup(dst)
+	}
+	return a
+}
+
+// addrFromSlice returns the address of a 4 or 16 byte slice, unmapping IPv4-mapped IPv6
+// addresses as net.IP holds IPv4 addresses, or an invalid address for other lengths
+func addrFromSlice(b []byte) netip.Addr {
+	addr, _ := netip.AddrFromSlice(b)
+	return addr.Unmap()
+}
+
+// Flow is a flow record by its address fields, as raw 4 or 16 byte addresses in network
+// byte order, e.g. the sourceIPv4Address and destinationIPv4Address fields of IPFIX, or
+// the SrcAddr and DstAddr of decoded goflow2 messages. net.IP values may be used as is.
+type Flow struct {
+	Src []byte
+	Dst []byte
+}
+
+// Flows appends the annotations of the records to out and returns it
+func Flows(table Table, flows []Flow, out []Annotation) []Annotation {
+	for _, f := range flows {
+		out = append(out, annotate(table, addrFromSlice(f.Src), addrFromSlice(f.Dst)))
+	}
+	return out
+}
+
+// Packets appends the annotations of packets decoded by gopacket to out and returns it.
+// Decoding a gopacket.Packet allocates its layers; Decoder avoids that for raw frames.
+func Packets(table Table, packets []gopacket.Packet, out []Annotation) []Annotation {
+	for _, p := range packets {
+		var src, dst netip.Addr
+		switch ip := p.NetworkLayer().(type) {
+		case *layers.IPv4:
+			src, dst = addrFromSlice(ip.SrcIP), addrFromSlice(ip.DstIP)
+		case *layers.IPv6:
+			src, dst = addrFromSlice(ip.SrcIP), addrFromSlice(ip.DstIP)
+		}
+		out = append(out, annotate(table, src, dst))
+	}
+	return out
+}
+
+// Decoder decodes the IP headers of raw frames into reused layers, with Ethernet, 802.1Q
+// VLAN tags, IPv4 and IPv6 supported. A Decoder is not safe for concurrent use.
+type Decoder struct {
+	parser  *gopacket.DecodingLayerParser
+	decoded []gopacket.LayerType
+
+	eth  layers.Ethernet
+	vlan layers.Dot1Q
+	ip4  layers.IPv4
+	ip6  layers.IPv6
+}
+
+// NewDecoder returns a decoder of frames starting with the given layer:
+// layers.LayerTypeEthernet for Ethernet captures, or layers.LayerTypeIPv4 or
+// layers.LayerTypeIPv6 for raw IP
+func NewDecoder(first gopacket.LayerType) *Decoder {
+	d := &Decoder{decoded: make([]gopacket.LayerType, 0, 4)}
+	d.parser = gopacket.NewDecodingLayerParser(first, &d.eth, &d.vlan, &d.ip4, &d.ip6)
+	// Decoding stops at the transport layer, which is of no interest
+	d.parser.IgnoreUnsupported = true
+	return d
+}
+
+// Frames appends the annotations of the frames to out and returns it. Frames that fail to
+// decode up to an IP header are annotated with invalid addresses.
+func (d *Decoder) Frames(table Table, frames [][]byte, out []Annotation) []Annotation {
+	for _, frame := range frames {
+		out = append(out, d.frame(table, frame))
+	}
+	return out
+}
+
+// frame returns the annotation of a frame
+func (d *Decoder) frame(table Table, frame []byte) Annotation {
+	if err := d.parser.DecodeLayers(frame, &d.decoded); err != nil {
+		return Annotation{}
+	}
+	for _, typ := range d.decoded {
+		switch typ {
+		case layers.LayerTypeIPv4:
+			return annotate(table, addrFromSlice(d.ip4.SrcIP), addrFromSlice(d.ip4.DstIP))
+		case layers.LayerTypeIPv6:
+			return annotate(table, addrFromSlice(d.ip6.SrcIP), addrFromSlice(d.ip6.DstIP))
+		}
+	}
+	return Annotation{}
+}
@@ -0,0 +1,140 @@
+package enrich
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/sakateka/lpm"
+)
+
+func testTable() *lpm.LPM {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "internal")
+	m.Insert(netip.MustParsePrefix("192.0.2.0/24"), "customer-a")
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "customer-b")
+	return m
+}
+
+// frame serializes the layers into a frame
+func frame(t testing.TB, ls ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	for _, l := range ls {
+		if udp, ok := l.(*layers.UDP); ok {
+			for _, ip := range ls {
+				if nl, ok := ip.(gopacket.NetworkLayer); ok {
+					udp.SetNetworkLayerForChecksum(nl)
+				}
+			}
+		}
+	}
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testFrames(t testing.TB) [][]byte {
+	mac := net.HardwareAddr{0, 1, 2, 3, 4, 5}
+	v4 := frame(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: mac, EthernetType: layers.EthernetTypeIPv4},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP("10.1.2.3"), DstIP: net.ParseIP("192.0.2.1")},
+		&layers.UDP{SrcPort: 1234, DstPort: 53},
+		gopacket.Payload("query"),
+	)
+	v6 := frame(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: mac, EthernetType: layers.EthernetTypeDot1Q},
+		&layers.Dot1Q{VLANIdentifier: 100, Type: layers.EthernetTypeIPv6},
+		&layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolUDP, SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2a00::1")},
+		&layers.UDP{SrcPort: 1234, DstPort: 53},
+	)
+	arp := frame(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: mac, EthernetType: layers.EthernetTypeARP},
+		&layers.ARP{AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4, HwAddressSize: 6, ProtAddressSize: 4,
+			SourceHwAddress: mac, SourceProtAddress: []byte{10, 0, 0, 1}, DstHwAddress: mac, DstProtAddress: []byte{10, 0, 0, 2}},
+	)
+	return [][]byte{v4, v6, arp, v4[:20]}
+}
+
+var wantFrames = []Annotation{
+	{Src: netip.MustParseAddr("10.1.2.3"), Dst: netip.MustParseAddr("192.0.2.1"), SrcValue: "internal", DstValue: "customer-a", SrcFound: true, DstFound: true},
+	{Src: netip.MustParseAddr("2001:db8::1"), Dst: netip.MustParseAddr("2a00::1"), SrcValue: "customer-b", SrcFound: true},
+	{},
+	{},
+}
+
+func checkAnnotations(t *testing.T, got, want []Annotation) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d annotations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("annotation %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestFrames tests annotating raw frames
+func TestFrames(t *testing.T) {
+	d := NewDecoder(layers.LayerTypeEthernet)
+	checkAnnotations(t, d.Frames(testTable(), testFrames(t), nil), wantFrames)
+
+	// Raw IP, without a link layer
+	raw := NewDecoder(layers.LayerTypeIPv4)
+	checkAnnotations(t, raw.Frames(testTable(), [][]byte{testFrames(t)[0][14:]}, nil), wantFrames[:1])
+}
+
+// TestPackets tests annotating packets decoded by gopacket
+func TestPackets(t *testing.T) {
+	var packets []gopacket.Packet
+	for _, data := range testFrames(t) {
+		packets = append(packets, gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default))
+	}
+	checkAnnotations(t, Packets(testTable(), packets, nil), wantFrames)
+}
+
+// TestFlows tests annotating flow records given as raw and as net.IP addresses
+func TestFlows(t *testing.T) {
+	flows := []Flow{
+		{Src: []byte{10, 1, 2, 3}, Dst: net.ParseIP("192.0.2.1")},
+		{Src: net.ParseIP("2001:db8::1"), Dst: net.ParseIP("2a00::1")},
+		{},
+	}
+	out := make([]Annotation, 1, 4)
+	out = Flows(testTable(), flows, out)
+	checkAnnotations(t, out[1:], wantFrames[:3])
+}
+
+// TestAllocations tests that batches of well-formed frames and records allocate nothing
+// once the output has grown
+func TestAllocations(t *testing.T) {
+	table := testTable()
+	frames := testFrames(t)[:3] // gopacket allocates errors for malformed frames
+	d := NewDecoder(layers.LayerTypeEthernet)
+	out := d.Frames(table, frames, nil)
+	if allocs := testing.AllocsPerRun(100, func() { out = d.Frames(table, frames, out[:0]) }); allocs != 0 {
+		t.Errorf("Frames allocated %v times per batch", allocs)
+	}
+
+	flows := []Flow{{Src: []byte{10, 1, 2, 3}, Dst: net.ParseIP("192.0.2.1")}, {Src: net.ParseIP("2001:db8::1")}}
+	out = Flows(table, flows, out[:0])
+	if allocs := testing.AllocsPerRun(100, func() { out = Flows(table, flows, out[:0]) }); allocs != 0 {
+		t.Errorf("Flows allocated %v times per batch", allocs)
+	}
+}
+
+func BenchmarkFrames(b *testing.B) {
+	table := testTable()
+	frames := testFrames(b)[:3]
+	d := NewDecoder(layers.LayerTypeEthernet)
+	out := make([]Annotation, 0, len(frames))
+	b.ReportAllocs()
+	for b.Loop() {
+		out = d.Frames(table, frames, out[:0])
+	}
+}
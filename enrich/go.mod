@@ -0,0 +1,10 @@
+module github.com/sakateka/lpm/enrich
+
+go 1.25.1
+
+require (
+	github.com/google/gopacket v1.1.19
+	github.com/sakateka/lpm v0.1.0
+)
+
+require github.com/klauspost/compress v1.18.0 // indirect
@@ -0,0 +1,55 @@
+package lpm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned when loading shared storage. They are wrapped with details,
+// so test for them with errors.Is and errors.As:
+//
+//	table, err := lpm.NewWithSharedStorage(storage)
+//	switch {
+//	case errors.Is(err, lpm.ErrVersionMismatch):
+//	    // built by an incompatible release, rebuild it
+//	case err != nil:
+//	    // corrupted or truncated storage
+//	}
+var (
+	// ErrBadMagic means the storage does not start with the LPM magic number
+	ErrBadMagic = errors.New("invalid magic number")
+	// ErrVersionMismatch means the storage format version is not supported by this release
+	ErrVersionMismatch = errors.New("unsupported version")
+	// ErrBadByteOrder means the byte order mark of the header is neither native nor swapped
+	ErrBadByteOrder = errors.New("invalid byte order mark")
+	// ErrChecksumMismatch means the storage content does not match the checksum of its header
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// ErrInvalidPrefix is returned for prefixes that are invalid or, by InsertStrict, not masked
+var ErrInvalidPrefix = errors.New("invalid prefix")
+
+// ErrCapacity means an insert would need more distinct values (2^24-1) or blocks (2^30)
+// than slots can encode
+var ErrCapacity = errors.New("trie capacity exceeded")
+
+// ErrMemoryLimit is returned by inserts into an LPM that might outgrow WithMaxMemory
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// ErrReadOnly is returned by inserts into an LPM loaded with the ReadOnly option
+var ErrReadOnly = errors.New("lpm is read-only")
+
+// ErrTruncated means the storage ends before one of its sections does
+type ErrTruncated struct {
+	Section string // "header", "IPv4 blocks", "IPv6 blocks", "values" or "storage"
+	Need    int    // Number of bytes the section requires from the start of the storage
+	Got     int    // Number of bytes available
+}
+
+func (e *ErrTruncated) Error() string {
+	return fmt.Sprintf("storage too small for %s: need %d bytes, got %d", e.Section, e.Need, e.Got)
+}
+
+// ErrCorrupt is returned by Verify when the trie breaks one of its structural invariants,
+// and by loaders for headers declaring more blocks or values than slots can encode
+var ErrCorrupt = errors.New("corrupt trie")
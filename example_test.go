@@ -67,6 +67,6 @@ func ExampleLPM_Insert() {
 	// Size of v6 storage: 11376
 	// Number of v4 blocks: 13
 	// Number of v6 blocks: 11
-	// Size of the lpm: 25822
+	// Size of the lpm: 27472
 	// Values storage size: 1006
 }
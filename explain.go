@@ -0,0 +1,152 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// SlotKind tells what a slot visited by a lookup holds, see Explain
+type SlotKind uint8
+
+const (
+	SlotInvalid   SlotKind = iota // no prefix covers the slot
+	SlotBlockRef                  // a reference to the block of the next address byte
+	SlotValue                     // the value of the longest prefix covering the slot
+	SlotTombstone                 // a tombstone carving the slot out of broader prefixes
+)
+
+func (k SlotKind) String() string {
+	switch k {
+	case SlotInvalid:
+		return "invalid"
+	case SlotBlockRef:
+		return "block ref"
+	case SlotValue:
+		return "value"
+	case SlotTombstone:
+		return "tombstone"
+	}
+	return "unknown"
+}
+
+// slotKind classifies an encoded slot
+func slotKind(encoded uint32) SlotKind {
+	switch {
+	case isBlockRef(encoded):
+		return SlotBlockRef
+	case isInvalid(encoded):
+		return SlotInvalid
+	}
+	if valueIdx, _ := decodeValue(encoded); valueIdx == tombstoneIdx {
+		return SlotTombstone
+	}
+	return SlotValue
+}
+
+// TraceStep is a slot visited by a lookup
+type TraceStep struct {
+	Depth      int          // Index of the address byte selecting the slot
+	Block      int          // Index of the block in the IPv4 or IPv6 trie
+	Shared     bool         // The block lives in shared storage
+	Slot       uint8        // Slot of the block, the address byte
+	Encoded    uint32       // Raw content of the slot
+	Kind       SlotKind     // What the slot holds
+	Child      int          // Block referenced by SlotBlockRef slots
+	ValueIndex int          // Value index of SlotValue slots, see LookupIndex
+	Prefix     netip.Prefix // Prefix that set SlotValue and SlotTombstone slots, as Entries reports it
+}
+
+// Trace records how a lookup decided, see Explain
+type Trace struct {
+	Addr  netip.Addr
+	Steps []TraceStep
+	Value string
+	Found bool
+}
+
+// Explain looks up addr like Lookup and records every block it visits and how it decoded
+// the slot it read there, for debugging lookups that return unexpected values. The blocks
+// are walked even when the IPv4Stride16 table answers lookups, as it holds copies of their
+// slots.
+func (m *LPM) Explain(addr netip.Addr) Trace {
+	trace := Trace{Addr: addr}
+	if !addr.IsValid() {
+		return trace
+	}
+	proto := v4LPM
+	var key []byte
+	if addr.Is4() {
+		key4 := addr.As4()
+		key = key4[:]
+	} else {
+		proto = v6LPM
+		key16 := addr.As16()
+		key = key16[:]
+		if m.ipv6Max64 {
+			key = key[:max64Bytes]
+		}
+	}
+	if m.blockCount(proto) == 0 {
+		return trace
+	}
+
+	var path [16]byte
+	blockIdx := m.root[proto]
+	for depth, inBlockIdx := range key {
+		path[depth] = inBlockIdx
+		encoded := m.getValue(proto, blockIdx, inBlockIdx)
+		step := TraceStep{
+			Depth:   depth,
+			Block:   blockIdx,
+			Shared:  blockIdx < len(m.shared[proto]),
+			Slot:    inBlockIdx,
+			Encoded: encoded,
+		}
+		switch step.Kind = slotKind(encoded); step.Kind {
+		case SlotBlockRef:
+			step.Child = decodeBlockRef(encoded)
+		case SlotValue, SlotTombstone:
+			valueIdx, prefixLen := decodeValue(encoded)
+			step.ValueIndex = valueIdx
+			step.Prefix = prefixFromPath(proto, path, prefixLen)
+		}
+		trace.Steps = append(trace.Steps, step)
+
+		if step.Kind != SlotBlockRef {
+			if step.Kind == SlotValue {
+				trace.Value, trace.Found = m.getValueByIndex(step.ValueIndex)
+			}
+			break
+		}
+		blockIdx = step.Child
+	}
+	return trace
+}
+
+// String formats the trace one step per line
+func (t Trace) String() string {
+	var b strings.Builder
+	if t.Found {
+		fmt.Fprintf(&b, "%s: %q\n", t.Addr, t.Value)
+	} else {
+		fmt.Fprintf(&b, "%s: not found\n", t.Addr)
+	}
+	for _, step := range t.Steps {
+		shared := ""
+		if step.Shared {
+			shared = " (shared)"
+		}
+		fmt.Fprintf(&b, "  byte %d: block %d%s slot %d = 0x%08X %s", step.Depth, step.Block, shared, step.Slot, step.Encoded, step.Kind)
+		switch step.Kind {
+		case SlotBlockRef:
+			fmt.Fprintf(&b, " -> block %d", step.Child)
+		case SlotValue:
+			fmt.Fprintf(&b, " %d of %s", step.ValueIndex, step.Prefix)
+		case SlotTombstone:
+			fmt.Fprintf(&b, " of %s", step.Prefix)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
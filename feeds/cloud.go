@@ -0,0 +1,234 @@
+package feeds
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// URLs of the cloud provider feeds. Azure publishes its service tags under a new URL every
+// week, linked from https://www.microsoft.com/en-us/download/details.aspx?id=56519.
+const (
+	AWSURL        = "https://ip-ranges.amazonaws.com/ip-ranges.json"
+	GCPURL        = "https://www.gstatic.com/ipranges/cloud.json"
+	CloudflareURL = "https://api.cloudflare.com/client/v4/ips"
+)
+
+// AWS is the feed of AWS address ranges
+func AWS() Feed {
+	return Feed{URL: AWSURL, Parse: ParseAWS}
+}
+
+// GCP is the feed of the address ranges of Google Cloud customers
+func GCP() Feed {
+	return Feed{URL: GCPURL, Parse: ParseGCP}
+}
+
+// Azure is the feed of Azure service tags published at url, which changes every week
+func Azure(url string) Feed {
+	return Feed{URL: url, Parse: ParseAzure}
+}
+
+// Cloudflare is the feed of Cloudflare address ranges
+func Cloudflare() Feed {
+	return Feed{URL: CloudflareURL, Parse: ParseCloudflare}
+}
+
+// awsRanges is the layout of ip-ranges.json
+type awsRanges struct {
+	Prefixes []struct {
+		Prefix  string `json:"ip_prefix"`
+		Region  string `json:"region"`
+		Service string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		Prefix  string `json:"ipv6_prefix"`
+		Region  string `json:"region"`
+		Service string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+// ParseAWS reads ip-ranges.json of AWS. Service AMAZON covers the prefixes of all others,
+// so the specific service of a prefix listed under both is stored. Region GLOBAL becomes
+// an empty region.
+func ParseAWS(r io.Reader) ([]Range, error) {
+	var doc awsRanges
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	var ranges []Range
+	add := func(cidr, service, region string) error {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("aws: %w", err)
+		}
+		if region == "GLOBAL" {
+			region = ""
+		}
+		rank := 1
+		if service == "AMAZON" {
+			rank = 0
+		}
+		ranges = append(ranges, Range{Prefix: prefix, Provider: "aws", Service: service, Region: region, rank: rank})
+		return nil
+	}
+	for _, p := range doc.Prefixes {
+		if err := add(p.Prefix, p.Service, p.Region); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range doc.IPv6Prefixes {
+		if err := add(p.Prefix, p.Service, p.Region); err != nil {
+			return nil, err
+		}
+	}
+	return ranges, nil
+}
+
+// gcpRanges is the layout of cloud.json
+type gcpRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Service    string `json:"service"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+// ParseGCP reads cloud.json of Google Cloud, with the scope of a prefix as its region
+func ParseGCP(r io.Reader) ([]Range, error) {
+	var doc gcpRanges
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("gcp: %w", err)
+	}
+	var ranges []Range
+	for _, p := range doc.Prefixes {
+		cidr := p.IPv4Prefix
+		if cidr == "" {
+			cidr = p.IPv6Prefix
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("gcp: %w", err)
+		}
+		ranges = append(ranges, Range{Prefix: prefix, Provider: "gcp", Service: p.Service, Region: p.Scope})
+	}
+	return ranges, nil
+}
+
+// azureTags is the layout of ServiceTags_Public_*.json
+type azureTags struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// ParseAzure reads the service tags of Azure, with the system service of a tag as the
+// service, e.g. "AzureStorage", and its region, e.g. "westeurope". A prefix is listed
+// under the tags of the whole cloud, of its region and of its service; the tag naming
+// both a service and a region is stored, then one naming a service, then one naming a
+// region.
+func ParseAzure(r io.Reader) ([]Range, error) {
+	var doc azureTags
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("azure: %w", err)
+	}
+	var ranges []Range
+	for _, tag := range doc.Values {
+		props := tag.Properties
+		rank := 0
+		if props.SystemService != "" {
+			rank += 2
+		}
+		if props.Region != "" {
+			rank++
+		}
+		for _, cidr := range props.AddressPrefixes {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("azure: tag %s: %w", tag.Name, err)
+			}
+			ranges = append(ranges, Range{Prefix: prefix, Provider: "azure", Service: props.SystemService, Region: props.Region, rank: rank})
+		}
+	}
+	return ranges, nil
+}
+
+// cloudflareRanges is the layout of the response of the ips endpoint of the Cloudflare API
+type cloudflareRanges struct {
+	Result struct {
+		IPv4CIDRs []string `json:"ipv4_cidrs"`
+		IPv6CIDRs []string `json:"ipv6_cidrs"`
+	} `json:"result"`
+	Success bool `json:"success"`
+}
+
+// ParseCloudflare reads the ranges of Cloudflare returned by its API, or listed one prefix
+// per line as at https://www.cloudflare.com/ips-v4 and https://www.cloudflare.com/ips-v6
+func ParseCloudflare(r io.Reader) ([]Range, error) {
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.Peek(1)
+		if err != nil || c[0] == '{' {
+			break
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(c[0])) {
+			return parseLines(br, "cloudflare", "")
+		}
+		br.ReadByte()
+	}
+
+	var doc cloudflareRanges
+	if err := json.NewDecoder(br).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("cloudflare: %w", err)
+	}
+	if !doc.Success {
+		return nil, errors.New("cloudflare: API request failed")
+	}
+	var ranges []Range
+	for _, cidr := range append(doc.Result.IPv4CIDRs, doc.Result.IPv6CIDRs...) {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("cloudflare: %w", err)
+		}
+		ranges = append(ranges, Range{Prefix: prefix, Provider: "cloudflare"})
+	}
+	return ranges, nil
+}
+
+// parseLines reads a list of prefixes, one per line. Empty lines, comments starting with
+// '#' or ';', and text after the prefix are skipped.
+func parseLines(r io.Reader, provider, service string) ([]Range, error) {
+	var ranges []Range
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		cidr := strings.TrimSuffix(fields[0], ";")
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			// Lists of single addresses
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("%s: line %d: %w", provider, line, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		ranges = append(ranges, Range{Prefix: prefix, Provider: provider, Service: service})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	return ranges, nil
+}
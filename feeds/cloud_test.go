@@ -0,0 +1,98 @@
+package feeds
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testAWS = `{"syncToken": "1", "prefixes": [
+	{"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+	{"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "S3", "network_border_group": "ap-northeast-2"},
+	{"ip_prefix": "52.93.0.0/16", "region": "GLOBAL", "service": "AMAZON", "network_border_group": "GLOBAL"}
+], "ipv6_prefixes": [
+	{"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2", "service": "EC2", "network_border_group": "us-west-2"}
+]}`
+	testGCP = `{"syncToken": "1", "prefixes": [
+	{"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"},
+	{"ipv6Prefix": "2600:1900:4010::/44", "service": "Google Cloud", "scope": "europe-west1"}
+]}`
+	testAzure = `{"changeNumber": 1, "cloud": "Public", "values": [
+	{"name": "AzureCloud", "properties": {"region": "", "systemService": "", "addressPrefixes": ["13.64.0.0/16", "20.38.0.0/16"]}},
+	{"name": "AzureCloud.westus", "properties": {"region": "westus", "systemService": "", "addressPrefixes": ["13.64.0.0/16", "20.38.0.0/16"]}},
+	{"name": "Storage.WestUS", "properties": {"region": "westus", "systemService": "AzureStorage", "addressPrefixes": ["20.38.0.0/16"]}}
+]}`
+	testCloudflare = `{"result": {"ipv4_cidrs": ["173.245.48.0/20"], "ipv6_cidrs": ["2400:cb00::/32"], "etag": "x"}, "success": true, "errors": [], "messages": []}`
+)
+
+// TestParse tests the parsers and the choice among ranges of the same prefix
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) ([]Range, error)
+		doc   string
+		want  map[string]string
+	}{
+		{"aws", func(s string) ([]Range, error) { return ParseAWS(strings.NewReader(s)) }, testAWS, map[string]string{
+			"3.5.140.0/22":   "aws,S3,ap-northeast-2",
+			"52.93.0.0/16":   "aws,AMAZON,",
+			"2600:1f14::/35": "aws,EC2,us-west-2",
+		}},
+		{"gcp", func(s string) ([]Range, error) { return ParseGCP(strings.NewReader(s)) }, testGCP, map[string]string{
+			"34.80.0.0/15":        "gcp,Google Cloud,asia-east1",
+			"2600:1900:4010::/44": "gcp,Google Cloud,europe-west1",
+		}},
+		{"azure", func(s string) ([]Range, error) { return ParseAzure(strings.NewReader(s)) }, testAzure, map[string]string{
+			"13.64.0.0/16": "azure,,westus",
+			"20.38.0.0/16": "azure,AzureStorage,westus",
+		}},
+		{"cloudflare", func(s string) ([]Range, error) { return ParseCloudflare(strings.NewReader(s)) }, testCloudflare, map[string]string{
+			"173.245.48.0/20": "cloudflare,,",
+			"2400:cb00::/32":  "cloudflare,,",
+		}},
+		{"cloudflare list", func(s string) ([]Range, error) { return ParseCloudflare(strings.NewReader(s)) }, "\n173.245.48.0/20\n103.21.244.0/22\n", map[string]string{
+			"173.245.48.0/20": "cloudflare,,",
+			"103.21.244.0/22": "cloudflare,,",
+		}},
+	}
+	for _, tt := range tests {
+		ranges, err := tt.parse(tt.doc)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.name, err)
+		}
+		got := make(map[string]string)
+		for _, e := range Entries(ranges, Tuple) {
+			got[e.Prefix.String()] = e.Value
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: entries %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for name, parse := range map[string]func(string) ([]Range, error){
+		"aws":        func(s string) ([]Range, error) { return ParseAWS(strings.NewReader(s)) },
+		"gcp":        func(s string) ([]Range, error) { return ParseGCP(strings.NewReader(s)) },
+		"cloudflare": func(s string) ([]Range, error) { return ParseCloudflare(strings.NewReader(s)) },
+	} {
+		if _, err := parse(`{"prefixes": [{"ip_prefix": "bogus", "ipv4Prefix": "bogus"}], "success": true, "result": {"ipv4_cidrs": ["bogus"]}}`); err == nil {
+			t.Errorf("%s: parsing a bad prefix succeeded", name)
+		}
+	}
+}
+
+// TestLoad tests that the values are looked up
+func TestLoad(t *testing.T) {
+	ranges, err := ParseAWS(strings.NewReader(testAWS))
+	if err != nil {
+		t.Fatalf("ParseAWS failed: %v", err)
+	}
+	m, err := Load(ranges, Provider)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, _ := m.Lookup(netip.MustParseAddr("3.5.141.1")); got != "aws" {
+		t.Errorf("Lookup(3.5.141.1) = %q, want aws", got)
+	}
+}
@@ -0,0 +1,197 @@
+// Package feeds loads published IP range feeds, such as the address ranges of cloud
+// providers, into LPM tables, so traffic can be classified by where it comes from without
+// rebuilding the glue for every feed:
+//
+//	table, err := feeds.Fetch(ctx, http.DefaultClient, feeds.Tuple, feeds.AWS(), feeds.GCP())
+//	if err != nil {
+//	    return err
+//	}
+//	value, found := table.Lookup(addr) // "aws,EC2,us-east-1", true
+//
+// Source returns the same as an lpm.Source, to keep the table up to date with
+// lpm.NewRefresher. The Parse functions read feeds obtained by other means.
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/sakateka/lpm"
+)
+
+// Range is a prefix of a feed
+type Range struct {
+	Prefix   netip.Prefix
+	Provider string // publisher of the feed: "aws", "gcp", "azure", "cloudflare", "spamhaus", ...
+	Service  string // service of the provider or name of the list, empty if the feed has none
+	Region   string // region of the provider, empty for global ranges and lists
+
+	// rank orders ranges of the same prefix: the one of the highest rank, the first of
+	// them on ties, is stored
+	rank int
+}
+
+// Value returns the value a range is stored with in the trie, and false to leave the
+// range out
+type Value func(Range) (string, bool)
+
+// Tuple stores ranges as "provider,service,region", e.g. "aws,EC2,us-east-1"
+func Tuple(r Range) (string, bool) {
+	return strings.Join([]string{r.Provider, r.Service, r.Region}, ","), true
+}
+
+// Provider stores ranges as the name of the provider
+func Provider(r Range) (string, bool) {
+	return r.Provider, true
+}
+
+// Entries returns the entries of the ranges. Feeds list some prefixes several times, e.g.
+// AWS as AMAZON and as the service using it, and Azure under the tags of the cloud, the
+// region and the service; the most specific range of a prefix is stored, otherwise the
+// first one.
+func Entries(ranges []Range, value Value) []lpm.PrefixValue {
+	best := make(map[netip.Prefix]int) // index into ranges
+	var order []netip.Prefix
+	for i, r := range ranges {
+		prefix := r.Prefix.Masked()
+		j, ok := best[prefix]
+		if !ok {
+			order = append(order, prefix)
+		}
+		if !ok || r.rank > ranges[j].rank {
+			best[prefix] = i
+		}
+	}
+
+	var entries []lpm.PrefixValue
+	for _, prefix := range order {
+		if v, ok := value(ranges[best[prefix]]); ok {
+			entries = append(entries, lpm.PrefixValue{Prefix: prefix, Value: v})
+		}
+	}
+	return entries
+}
+
+// Load returns a trie of the entries of the ranges, see Entries. The options are passed to
+// lpm.New.
+func Load(ranges []Range, value Value, opts ...lpm.Option) (*lpm.LPM, error) {
+	m := lpm.New(opts...)
+	for _, e := range Entries(ranges, value) {
+		if err := m.Insert(e.Prefix, e.Value); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Prefix, err)
+		}
+	}
+	m.Compact()
+	return m, nil
+}
+
+// Feed is a feed published at a URL
+type Feed struct {
+	URL   string
+	Parse func(io.Reader) ([]Range, error)
+}
+
+// Fetch downloads the feeds with client, http.DefaultClient if nil, and loads their ranges
+// into a trie, see Load. Ranges of earlier feeds take precedence on equal prefixes.
+func Fetch(ctx context.Context, client *http.Client, value Value, feeds ...Feed) (*lpm.LPM, error) {
+	ranges, err := fetchAll(ctx, client, feeds)
+	if err != nil {
+		return nil, err
+	}
+	return Load(ranges, value)
+}
+
+// Source returns an lpm.Source downloading the feeds on every call, for lpm.NewRefresher,
+// which swaps the table in atomically whenever a feed changed:
+//
+//	refresher, err := lpm.NewRefresher(ctx, feeds.Source(nil, feeds.Tuple, feeds.SpamhausDROP()), time.Hour)
+//
+// Downloads are conditional: the ETag and Last-Modified headers of the previous response
+// of a feed are sent back as If-None-Match and If-Modified-Since, and a feed the server
+// reports unchanged is not downloaded and parsed again.
+func Source(client *http.Client, value Value, feeds ...Feed) lpm.Source {
+	c := &cache{feeds: make(map[string]*cachedFeed)}
+	return func(ctx context.Context) ([]lpm.PrefixValue, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		var ranges []Range
+		for _, feed := range feeds {
+			cached := c.feeds[feed.URL]
+			if cached == nil {
+				cached = &cachedFeed{}
+				c.feeds[feed.URL] = cached
+			}
+			if err := fetch(ctx, client, feed, cached); err != nil {
+				return nil, fmt.Errorf("%s: %w", feed.URL, err)
+			}
+			ranges = append(ranges, cached.ranges...)
+		}
+		return Entries(ranges, value), nil
+	}
+}
+
+// cache holds the last download of the feeds of a Source
+type cache struct {
+	mu    sync.Mutex
+	feeds map[string]*cachedFeed // by URL
+}
+
+// cachedFeed is the last download of a feed
+type cachedFeed struct {
+	etag         string
+	lastModified string
+	ranges       []Range
+}
+
+func fetchAll(ctx context.Context, client *http.Client, feeds []Feed) ([]Range, error) {
+	var ranges []Range
+	for _, feed := range feeds {
+		var fetched cachedFeed
+		if err := fetch(ctx, client, feed, &fetched); err != nil {
+			return nil, fmt.Errorf("%s: %w", feed.URL, err)
+		}
+		ranges = append(ranges, fetched.ranges...)
+	}
+	return ranges, nil
+}
+
+// fetch downloads a feed unless it is unchanged since the download cached, and updates
+// the cache
+func fetch(ctx context.Context, client *http.Client, feed Feed, cached *cachedFeed) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return err
+	}
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && (cached.etag != "" || cached.lastModified != ""):
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	ranges, err := feed.Parse(resp.Body)
+	if err != nil {
+		return err
+	}
+	cached.etag, cached.lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	cached.ranges = ranges
+	return nil
+}
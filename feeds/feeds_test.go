@@ -0,0 +1,96 @@
+package feeds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// TestFetch tests downloading several feeds, directly and as a source
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/aws", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(testAWS)) })
+	mux.HandleFunc("/gcp", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(testGCP)) })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	feeds := []Feed{{URL: server.URL + "/aws", Parse: ParseAWS}, {URL: server.URL + "/gcp", Parse: ParseGCP}}
+	m, err := Fetch(context.Background(), nil, Tuple, feeds...)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	for addr, want := range map[string]string{"3.5.140.1": "aws,S3,ap-northeast-2", "34.81.0.1": "gcp,Google Cloud,asia-east1"} {
+		if got, _ := m.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	entries, err := Source(server.Client(), Provider, feeds...)(context.Background())
+	if err != nil {
+		t.Fatalf("Source failed: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("Source returned %d entries, want 5", len(entries))
+	}
+
+	if _, err := Fetch(context.Background(), nil, Tuple, Feed{URL: server.URL + "/missing", Parse: ParseAWS}); err == nil {
+		t.Error("Fetch of a missing feed succeeded")
+	}
+}
+
+// TestSourceConditional tests that unchanged feeds are not downloaded again, and that a
+// refresher picks up changed ones
+func TestSourceConditional(t *testing.T) {
+	var mu sync.Mutex
+	list, etag := "192.0.2.0/24\n", `"v1"`
+	downloads, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(list))
+	}))
+	defer server.Close()
+
+	source := Source(nil, Provider, Feed{URL: server.URL, Parse: ParseList("test", "")})
+	refresher, err := lpm.NewRefresher(context.Background(), source, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRefresher failed: %v", err)
+	}
+	defer refresher.Close()
+	for range 2 {
+		if err := refresher.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh failed: %v", err)
+		}
+	}
+	if _, found := refresher.Lookup(netip.MustParseAddr("192.0.2.1")); !found {
+		t.Error("the unchanged feed was lost")
+	}
+
+	mu.Lock()
+	list, etag = "198.51.100.0/24\n", `"v2"`
+	mu.Unlock()
+	if err := refresher.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, found := refresher.Lookup(netip.MustParseAddr("198.51.100.1")); !found {
+		t.Error("the changed feed was not swapped in")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if downloads != 2 || notModified != 2 {
+		t.Errorf("%d downloads and %d not modified responses, want 2 and 2", downloads, notModified)
+	}
+}
@@ -0,0 +1,61 @@
+package feeds
+
+import (
+	"fmt"
+	"io"
+)
+
+// URLs of the bogon and threat feeds
+const (
+	FullBogonsV4URL   = "https://www.team-cymru.org/Services/Bogons/fullbogons-ipv4.txt"
+	FullBogonsV6URL   = "https://www.team-cymru.org/Services/Bogons/fullbogons-ipv6.txt"
+	SpamhausDROPURL   = "https://www.spamhaus.org/drop/drop.txt"
+	SpamhausDROPv6URL = "https://www.spamhaus.org/drop/dropv6.txt"
+	SpamhausEDROPURL  = "https://www.spamhaus.org/drop/edrop.txt"
+	// FireHOLURL is the URL of a FireHOL level list, with the level from 1 to 4
+	FireHOLURL = "https://iplists.firehol.org/files/firehol_level%d.netset"
+)
+
+// ParseList returns a parser of plain prefix lists, one prefix or address per line as most
+// threat feeds publish them, storing ranges with the provider and service given. Empty
+// lines, comments starting with '#' or ';' and text following the prefix, such as the
+// SBL references of Spamhaus, are skipped.
+func ParseList(provider, service string) func(io.Reader) ([]Range, error) {
+	return func(r io.Reader) ([]Range, error) {
+		return parseLines(r, provider, service)
+	}
+}
+
+// FullBogonsV4 is the feed of Team Cymru fullbogons: IPv4 space that is reserved or not
+// allocated by the RIRs, stored as "team-cymru" and "fullbogons"
+func FullBogonsV4() Feed {
+	return Feed{URL: FullBogonsV4URL, Parse: ParseList("team-cymru", "fullbogons")}
+}
+
+// FullBogonsV6 is the IPv6 feed of Team Cymru fullbogons, see FullBogonsV4
+func FullBogonsV6() Feed {
+	return Feed{URL: FullBogonsV6URL, Parse: ParseList("team-cymru", "fullbogons")}
+}
+
+// SpamhausDROP is the Spamhaus Don't Route Or Peer list of hijacked and criminal IPv4
+// netblocks, stored as "spamhaus" and "drop"
+func SpamhausDROP() Feed {
+	return Feed{URL: SpamhausDROPURL, Parse: ParseList("spamhaus", "drop")}
+}
+
+// SpamhausDROPv6 is the IPv6 DROP list of Spamhaus, see SpamhausDROP
+func SpamhausDROPv6() Feed {
+	return Feed{URL: SpamhausDROPv6URL, Parse: ParseList("spamhaus", "drop")}
+}
+
+// SpamhausEDROP is the extended DROP list of Spamhaus, stored as "spamhaus" and "edrop".
+// Spamhaus has merged it into DROP, and keeps publishing it for existing consumers.
+func SpamhausEDROP() Feed {
+	return Feed{URL: SpamhausEDROPURL, Parse: ParseList("spamhaus", "edrop")}
+}
+
+// FireHOL is the FireHOL level list of the level, from 1 (attacks with no false positives
+// expected) to 4 (more aggressive), stored as "firehol" and "level1" to "level4"
+func FireHOL(level int) Feed {
+	return Feed{URL: fmt.Sprintf(FireHOLURL, level), Parse: ParseList("firehol", fmt.Sprintf("level%d", level))}
+}
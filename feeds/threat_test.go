@@ -0,0 +1,52 @@
+package feeds
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseList tests the list formats of Team Cymru, Spamhaus and FireHOL
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		feed Feed
+		doc  string
+		want map[string]string
+	}{
+		{FullBogonsV4(), "# last updated 1700000000 (Tue Nov 14 22:13:20 2023 GMT)\n0.0.0.0/8\n10.0.0.0/8\n", map[string]string{
+			"0.0.0.0/8":  "team-cymru,fullbogons,",
+			"10.0.0.0/8": "team-cymru,fullbogons,",
+		}},
+		{SpamhausDROP(), "; Spamhaus DROP List 2024/01/01\n; Last-Modified: Mon, 01 Jan 2024\n1.10.16.0/20 ; SBL256894\n", map[string]string{
+			"1.10.16.0/20": "spamhaus,drop,",
+		}},
+		{SpamhausDROPv6(), "2001:678:738::/48 ; SBL574564\n", map[string]string{
+			"2001:678:738::/48": "spamhaus,drop,",
+		}},
+		{FireHOL(1), "#\n# firehol_level1\n#\n\n0.0.0.0/8\n1.19.0.0/16\n192.0.2.7\n", map[string]string{
+			"0.0.0.0/8":    "firehol,level1,",
+			"1.19.0.0/16":  "firehol,level1,",
+			"192.0.2.7/32": "firehol,level1,",
+		}},
+	}
+	for _, tt := range tests {
+		ranges, err := tt.feed.Parse(strings.NewReader(tt.doc))
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.feed.URL, err)
+		}
+		got := make(map[string]string)
+		for _, e := range Entries(ranges, Tuple) {
+			got[e.Prefix.String()] = e.Value
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: entries %v, want %v", tt.feed.URL, got, tt.want)
+		}
+	}
+
+	if _, err := ParseList("x", "y")(strings.NewReader("10.0.0.0/8\nnot a prefix\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("parsing a bad line: %v, want an error about line 2", err)
+	}
+	if got := FireHOL(3).URL; got != "https://iplists.firehol.org/files/firehol_level3.netset" {
+		t.Errorf("FireHOL(3).URL = %s", got)
+	}
+}
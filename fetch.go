@@ -0,0 +1,96 @@
+package lpm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotModified is returned by FetchStorage when the server reports the storage unchanged
+// since the previous fetch
+var ErrNotModified = errors.New("storage not modified")
+
+// FetchOptions configures FetchStorage
+type FetchOptions struct {
+	// Client sends the request, http.DefaultClient if nil
+	Client *http.Client
+	// Header is added to the request, e.g. for authorization
+	Header http.Header
+	// ETag and LastModified are the validators of the storage fetched before, sent as
+	// If-None-Match and If-Modified-Since. FetchStorage sets them after every download.
+	ETag         string
+	LastModified string
+	// Options are passed to NewFromReader
+	Options []Option
+}
+
+// FetchStorage downloads storage written by PackTo, PackCompressed or SaveToFile, e.g. from
+// an artifact server, and loads it like NewFromReader, verifying its checksum unless
+// SkipChecksum is given. Downloads are conditional: passing the same options again
+// returns ErrNotModified without downloading the storage if the server reports it
+// unchanged, so edge nodes can poll for new tables cheaply:
+//
+//	opts := &lpm.FetchOptions{}
+//	for range ticker.C {
+//	    table, err := lpm.FetchStorage(ctx, "https://artifacts.example.com/routes.lpm", opts)
+//	    if err != nil {
+//	        continue // ErrNotModified, or keep serving the current table
+//	    }
+//	    current.Store(table)
+//	}
+//
+// opts may be nil for a single unconditional download.
+func FetchStorage(ctx context.Context, url string, opts *FetchOptions) (*LPM, error) {
+	if opts == nil {
+		opts = &FetchOptions{}
+	}
+	o := newOptions(opts.Options)
+	lpm, err := fetchStorage(ctx, url, opts, o)
+	if !errors.Is(err, ErrNotModified) {
+		logLoad(o.log().With("url", url), lpm, err)
+	}
+	return lpm, err
+}
+
+func fetchStorage(ctx context.Context, url string, opts *FetchOptions, o options) (*LPM, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range opts.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if opts.ETag != "" {
+		req.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		req.Header.Set("If-Modified-Since", opts.LastModified)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && (opts.ETag != "" || opts.LastModified != ""):
+		return nil, ErrNotModified
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+
+	lpm, err := newFromReader(resp.Body, o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	// Validators are only kept for storage that loaded, so broken storage is fetched again
+	opts.ETag, opts.LastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	return lpm, nil
+}
@@ -0,0 +1,153 @@
+// Package fib loads the routes of the Linux kernel forwarding table (FIB) into an LPM table,
+// so userland services classify addresses the way the kernel routes them:
+//
+//	routes, err := fib.Load(fib.TableMain, fib.NextHop)
+//	if err != nil {
+//	    return err
+//	}
+//
+//	nextHop, found := routes.Lookup(addr) // "via 192.0.2.1 dev eth0", true
+//
+// Monitor keeps such a table in sync with the kernel. Routes are read over rtnetlink, so
+// both only work on Linux; elsewhere they fail with errors.ErrUnsupported.
+package fib
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+// Routing tables, see ip-route(8). The routes of a VRF are in the table of its device,
+// as listed by "ip vrf show".
+const (
+	TableAll   = 0   // every table
+	TableMain  = 254 // the table of "ip route show"
+	TableLocal = 255 // local and broadcast addresses of the host
+)
+
+// Route types, see rtnetlink(7)
+const (
+	TypeUnicast     = 1
+	TypeLocal       = 2
+	TypeBroadcast   = 3
+	TypeAnycast     = 4
+	TypeMulticast   = 5
+	TypeBlackhole   = 6
+	TypeUnreachable = 7
+	TypeProhibit    = 8
+	TypeThrow       = 9
+)
+
+var typeNames = map[uint8]string{
+	TypeUnicast:     "unicast",
+	TypeLocal:       "local",
+	TypeBroadcast:   "broadcast",
+	TypeAnycast:     "anycast",
+	TypeMulticast:   "multicast",
+	TypeBlackhole:   "blackhole",
+	TypeUnreachable: "unreachable",
+	TypeProhibit:    "prohibit",
+	TypeThrow:       "throw",
+}
+
+// Hop is a next hop of a route
+type Hop struct {
+	Gateway   netip.Addr // invalid for directly connected routes
+	Interface string     // name of the output interface, empty if unknown
+	Index     int        // index of the output interface, zero if none
+}
+
+// Route is a route of the kernel
+type Route struct {
+	Prefix   netip.Prefix
+	Table    uint32
+	Type     uint8  // TypeUnicast, TypeBlackhole, ...
+	Protocol uint8  // the routing daemon or kernel subsystem that installed it, see rtnetlink(7)
+	Metric   uint32 // routes to the same prefix with lower metrics take precedence
+	Hops     []Hop  // several for multipath routes
+}
+
+// Value returns the value a route is stored with in the trie, and false to leave the
+// route out
+type Value func(Route) (string, bool)
+
+// NextHop stores routes like "ip route show" prints their next hops: "via 192.0.2.1 dev
+// eth0" or "dev eth0" for unicast routes, with a "nexthop" clause per hop for multipath
+// routes, and the type, e.g. "blackhole", for routes of other types
+func NextHop(r Route) (string, bool) {
+	if r.Type != TypeUnicast {
+		return typeName(r.Type), true
+	}
+	if len(r.Hops) == 1 {
+		return r.Hops[0].String(), true
+	}
+	hops := make([]string, len(r.Hops))
+	for i, hop := range r.Hops {
+		hops[i] = "nexthop " + hop.String()
+	}
+	return strings.Join(hops, " "), true
+}
+
+// String formats the hop like "ip route show", e.g. "via 192.0.2.1 dev eth0"
+func (h Hop) String() string {
+	var parts []string
+	if h.Gateway.IsValid() {
+		parts = append(parts, "via", h.Gateway.String())
+	}
+	switch {
+	case h.Interface != "":
+		parts = append(parts, "dev", h.Interface)
+	case h.Index != 0:
+		parts = append(parts, "dev", fmt.Sprintf("if%d", h.Index))
+	}
+	return strings.Join(parts, " ")
+}
+
+func typeName(typ uint8) string {
+	if name, ok := typeNames[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("type%d", typ)
+}
+
+// Load returns a trie mapping the destination of every route of the table, or of all
+// tables for TableAll, to its value. Of routes to the same prefix, the one with the
+// lowest metric is stored, as the kernel prefers it. Throw routes are inserted as
+// tombstones, so lookups of their addresses find nothing, like the kernel moving on to
+// the next routing rule. The options are passed to lpm.New.
+func Load(table uint32, value Value, opts ...lpm.Option) (*lpm.LPM, error) {
+	routes, err := Routes(table)
+	if err != nil {
+		return nil, err
+	}
+	return build(routes, value, opts...)
+}
+
+// build inserts routes into a new trie, see Load
+func build(routes []Route, value Value, opts ...lpm.Option) (*lpm.LPM, error) {
+	slices.SortStableFunc(routes, func(a, b Route) int { return cmp.Compare(a.Metric, b.Metric) })
+	seen := make(map[netip.Prefix]bool, len(routes))
+	m := lpm.New(opts...)
+	for _, r := range routes {
+		if seen[r.Prefix] {
+			continue
+		}
+		seen[r.Prefix] = true
+		var err error
+		if r.Type == TypeThrow {
+			err = m.InsertTombstone(r.Prefix)
+		} else if v, ok := value(r); ok {
+			err = m.Insert(r.Prefix, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Prefix, err)
+		}
+	}
+	m.Compact()
+	return m, nil
+}
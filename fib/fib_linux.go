@@ -0,0 +1,145 @@
+package fib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+)
+
+// sizeofRtNexthop is the size of struct rtnexthop, which precedes the attributes of every
+// hop of RTA_MULTIPATH
+const sizeofRtNexthop = 8
+
+// Routes returns the routes of the table, or of all tables for TableAll
+func Routes(table uint32) ([]Route, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, os.NewSyscallError("netlinkrib", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, os.NewSyscallError("parsenetlinkmessage", err)
+	}
+	return parseRoutes(msgs, table, interfaceNames())
+}
+
+// interfaceNames returns the names of the interfaces by index
+func interfaceNames() map[int]string {
+	names := make(map[int]string)
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		names[iface.Index] = iface.Name
+	}
+	return names
+}
+
+// parseRoutes returns the routes of the table among RTM_NEWROUTE messages
+func parseRoutes(msgs []syscall.NetlinkMessage, table uint32, names map[int]string) ([]Route, error) {
+	var routes []Route
+	for i := range msgs {
+		msg := &msgs[i]
+		if msg.Header.Type != syscall.RTM_NEWROUTE {
+			continue
+		}
+		r, ok, err := parseRoute(msg, names)
+		if err != nil {
+			return nil, err
+		}
+		if ok && (table == TableAll || r.Table == table) {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+// parseRoute returns the route of an RTM_NEWROUTE message, or false for routes of families
+// other than IPv4 and IPv6
+func parseRoute(msg *syscall.NetlinkMessage, names map[int]string) (Route, bool, error) {
+	if len(msg.Data) < syscall.SizeofRtMsg {
+		return Route{}, false, fmt.Errorf("fib: route message of %d bytes", len(msg.Data))
+	}
+	// struct rtmsg: family, dst_len, src_len, tos, table, protocol, scope, type, flags
+	family, dstLen := msg.Data[0], int(msg.Data[1])
+	if family != syscall.AF_INET && family != syscall.AF_INET6 {
+		return Route{}, false, nil
+	}
+	attrs, err := syscall.ParseNetlinkRouteAttr(msg)
+	if err != nil {
+		return Route{}, false, os.NewSyscallError("parsenetlinkrouteattr", err)
+	}
+
+	dst := netip.IPv4Unspecified()
+	if family == syscall.AF_INET6 {
+		dst = netip.IPv6Unspecified()
+	}
+	r := Route{Table: uint32(msg.Data[4]), Protocol: msg.Data[5], Type: msg.Data[7]}
+	hop := Hop{}
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case syscall.RTA_DST:
+			dst, _ = netip.AddrFromSlice(attr.Value)
+		case syscall.RTA_GATEWAY:
+			hop.Gateway, _ = netip.AddrFromSlice(attr.Value)
+		case syscall.RTA_OIF:
+			hop.Index = int(nativeUint32(attr.Value))
+		case syscall.RTA_PRIORITY:
+			r.Metric = nativeUint32(attr.Value)
+		case syscall.RTA_TABLE:
+			r.Table = nativeUint32(attr.Value)
+		case syscall.RTA_MULTIPATH:
+			if r.Hops, err = parseMultipath(attr.Value, names); err != nil {
+				return Route{}, false, err
+			}
+		}
+	}
+	if len(r.Hops) == 0 && (hop.Gateway.IsValid() || hop.Index != 0) {
+		hop.Interface = names[hop.Index]
+		r.Hops = []Hop{hop}
+	}
+	r.Prefix = netip.PrefixFrom(dst, dstLen)
+	if !r.Prefix.IsValid() {
+		return Route{}, false, fmt.Errorf("fib: route to %s/%d", dst, dstLen)
+	}
+	return r, true, nil
+}
+
+// parseMultipath returns the hops of an RTA_MULTIPATH attribute: struct rtnexthop headers,
+// each followed by the attributes of the hop
+func parseMultipath(b []byte, names map[int]string) ([]Hop, error) {
+	var hops []Hop
+	for len(b) >= sizeofRtNexthop {
+		length := int(binary.NativeEndian.Uint16(b))
+		if length < sizeofRtNexthop || length > len(b) {
+			return nil, fmt.Errorf("fib: next hop of %d bytes", length)
+		}
+		hop := Hop{Index: int(int32(nativeUint32(b[4:])))}
+		hop.Interface = names[hop.Index]
+		for attrs := b[sizeofRtNexthop:length]; len(attrs) >= syscall.SizeofRtAttr; {
+			attrLen := int(binary.NativeEndian.Uint16(attrs))
+			if attrLen < syscall.SizeofRtAttr || attrLen > len(attrs) {
+				return nil, fmt.Errorf("fib: next hop attribute of %d bytes", attrLen)
+			}
+			if binary.NativeEndian.Uint16(attrs[2:]) == syscall.RTA_GATEWAY {
+				hop.Gateway, _ = netip.AddrFromSlice(attrs[syscall.SizeofRtAttr:attrLen])
+			}
+			attrs = attrs[min(rtaAlign(attrLen), len(attrs)):]
+		}
+		hops = append(hops, hop)
+		b = b[min(rtaAlign(length), len(b)):]
+	}
+	return hops, nil
+}
+
+func rtaAlign(n int) int {
+	return (n + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+}
+
+func nativeUint32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.NativeEndian.Uint32(b)
+}
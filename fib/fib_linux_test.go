@@ -0,0 +1,117 @@
+package fib
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+)
+
+// routeMessage builds an RTM_NEWROUTE message
+func routeMessage(family, dstLen, table, typ byte, attrs ...[]byte) syscall.NetlinkMessage {
+	data := []byte{family, dstLen, 0, 0, table, 4 /* static */, 0, typ, 0, 0, 0, 0}
+	for _, attr := range attrs {
+		data = append(data, attr...)
+	}
+	return syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: syscall.RTM_NEWROUTE}, Data: data}
+}
+
+// rtattr builds a route attribute
+func rtattr(typ uint16, value []byte) []byte {
+	attr := binary.NativeEndian.AppendUint16(nil, uint16(syscall.SizeofRtAttr+len(value)))
+	attr = binary.NativeEndian.AppendUint16(attr, typ)
+	attr = append(attr, value...)
+	return append(attr, make([]byte, rtaAlign(len(attr))-len(attr))...)
+}
+
+func u32(v uint32) []byte { return binary.NativeEndian.AppendUint32(nil, v) }
+
+// TestParseRoutes tests reading destinations, next hops, metrics and tables
+func TestParseRoutes(t *testing.T) {
+	nexthop := func(index uint32, gw string) []byte {
+		attrs := rtattr(syscall.RTA_GATEWAY, netip.MustParseAddr(gw).AsSlice())
+		hop := binary.NativeEndian.AppendUint16(nil, uint16(sizeofRtNexthop+len(attrs)))
+		hop = append(hop, 0, 0)
+		hop = append(hop, u32(index)...)
+		return append(hop, attrs...)
+	}
+	msgs := []syscall.NetlinkMessage{
+		routeMessage(syscall.AF_INET, 0, TableMain, TypeUnicast,
+			rtattr(syscall.RTA_GATEWAY, []byte{192, 0, 2, 1}), rtattr(syscall.RTA_OIF, u32(2)), rtattr(syscall.RTA_PRIORITY, u32(100))),
+		routeMessage(syscall.AF_INET6, 32, 252, TypeUnicast,
+			rtattr(syscall.RTA_DST, netip.MustParseAddr("2001:db8::").AsSlice()), rtattr(syscall.RTA_TABLE, u32(1000)),
+			rtattr(syscall.RTA_MULTIPATH, append(nexthop(2, "fe80::1"), nexthop(3, "fe80::2")...))),
+		routeMessage(syscall.AF_INET, 24, TableMain, TypeBlackhole, rtattr(syscall.RTA_DST, []byte{198, 51, 100, 0})),
+		routeMessage(syscall.AF_BRIDGE, 0, TableMain, TypeUnicast),
+		{Header: syscall.NlMsghdr{Type: syscall.NLMSG_DONE}},
+	}
+	names := map[int]string{2: "eth0", 3: "eth1"}
+
+	routes, err := parseRoutes(msgs, TableAll, names)
+	if err != nil {
+		t.Fatalf("parseRoutes failed: %v", err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("parseRoutes returned %d routes, want 3", len(routes))
+	}
+	if r := routes[0]; r.Prefix != netip.MustParsePrefix("0.0.0.0/0") || r.Metric != 100 || len(r.Hops) != 1 || r.Hops[0].String() != "via 192.0.2.1 dev eth0" {
+		t.Errorf("default route = %+v, want via 192.0.2.1 dev eth0 with metric 100", r)
+	}
+	if r := routes[1]; r.Table != 1000 || r.Prefix != netip.MustParsePrefix("2001:db8::/32") {
+		t.Errorf("multipath route = %+v, want 2001:db8::/32 in table 1000", r)
+	}
+	if got, _ := NextHop(routes[1]); got != "nexthop via fe80::1 dev eth0 nexthop via fe80::2 dev eth1" {
+		t.Errorf("NextHop of the multipath route = %q", got)
+	}
+	if r := routes[2]; r.Type != TypeBlackhole || r.Prefix != netip.MustParsePrefix("198.51.100.0/24") || r.Hops != nil {
+		t.Errorf("blackhole route = %+v", r)
+	}
+
+	if routes, _ := parseRoutes(msgs, 1000, names); len(routes) != 1 {
+		t.Errorf("parseRoutes of table 1000 returned %d routes, want 1", len(routes))
+	}
+	bad := []syscall.NetlinkMessage{routeMessage(syscall.AF_INET, 40, TableMain, TypeUnicast)}
+	if _, err := parseRoutes(bad, TableAll, names); err == nil {
+		t.Error("parseRoutes of a /40 IPv4 route succeeded")
+	}
+}
+
+// TestLoad tests loading the routes of this host
+func TestLoad(t *testing.T) {
+	routes, err := Routes(TableAll)
+	if err != nil {
+		t.Skipf("rtnetlink is unavailable: %v", err)
+	}
+	m, err := Load(TableLocal, NextHop)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, r := range routes {
+		if r.Table == TableLocal && r.Type == TypeLocal && r.Prefix.Addr().Is4() {
+			if got, _ := m.Lookup(r.Prefix.Addr()); got != "local" {
+				t.Errorf("Lookup(%s) = %q, want local", r.Prefix.Addr(), got)
+			}
+			return
+		}
+	}
+	t.Skip("no local IPv4 routes")
+}
+
+// TestMonitor tests starting and stopping a monitor of this host
+func TestMonitor(t *testing.T) {
+	monitor, err := NewMonitor(context.Background(), TableLocal, NextHop)
+	if err != nil {
+		t.Skipf("rtnetlink is unavailable: %v", err)
+	}
+	loaded, err := Load(TableLocal, NextHop)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if monitor.Load().Fingerprint() != loaded.Fingerprint() {
+		t.Error("monitored table differs from the loaded one")
+	}
+	if err := monitor.Close(); err != nil || monitor.Err() != nil {
+		t.Errorf("Close = %v, Err = %v, want nil", err, monitor.Err())
+	}
+}
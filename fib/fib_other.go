@@ -0,0 +1,11 @@
+//go:build !linux
+
+package fib
+
+import "errors"
+
+// Routes returns the routes of the table, or of all tables for TableAll. It needs
+// rtnetlink, so it fails with errors.ErrUnsupported on systems other than Linux.
+func Routes(table uint32) ([]Route, error) {
+	return nil, errors.ErrUnsupported
+}
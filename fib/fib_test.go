@@ -0,0 +1,61 @@
+package fib
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestNextHop tests formatting routes like ip route show
+func TestNextHop(t *testing.T) {
+	gw := netip.MustParseAddr("192.0.2.1")
+	for _, tc := range []struct {
+		route Route
+		want  string
+	}{
+		{Route{Type: TypeUnicast, Hops: []Hop{{Gateway: gw, Interface: "eth0", Index: 2}}}, "via 192.0.2.1 dev eth0"},
+		{Route{Type: TypeUnicast, Hops: []Hop{{Index: 7}}}, "dev if7"},
+		{Route{Type: TypeUnicast, Hops: []Hop{{Gateway: gw, Interface: "eth0"}, {Gateway: netip.MustParseAddr("192.0.2.2"), Interface: "eth1"}}},
+			"nexthop via 192.0.2.1 dev eth0 nexthop via 192.0.2.2 dev eth1"},
+		{Route{Type: TypeBlackhole}, "blackhole"},
+		{Route{Type: 42}, "type42"},
+	} {
+		if got, ok := NextHop(tc.route); !ok || got != tc.want {
+			t.Errorf("NextHop(%+v) = %q, %v, want %q", tc.route, got, ok, tc.want)
+		}
+	}
+}
+
+// TestBuild tests that the routes the kernel prefers are stored
+func TestBuild(t *testing.T) {
+	route := func(cidr string, typ uint8, metric uint32, dev string) Route {
+		return Route{Prefix: netip.MustParsePrefix(cidr), Type: typ, Metric: metric, Hops: []Hop{{Interface: dev}}}
+	}
+	m, err := build([]Route{
+		route("0.0.0.0/0", TypeUnicast, 600, "wlan0"),
+		route("0.0.0.0/0", TypeUnicast, 100, "eth0"),
+		route("10.0.0.0/8", TypeUnicast, 0, "tun0"),
+		route("10.1.0.0/16", TypeThrow, 0, ""),
+		route("10.2.0.0/16", TypeProhibit, 0, ""),
+		route("2001:db8::/32", TypeUnicast, 1024, "eth0"),
+		route("2001:db8:1::/48", TypeUnicast, 1024, "docker0"),
+	}, func(r Route) (string, bool) {
+		if r.Hops[0].Interface == "docker0" {
+			return "", false
+		}
+		return NextHop(r)
+	})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	for addr, want := range map[string]string{
+		"192.0.2.1":     "dev eth0",
+		"10.3.0.1":      "dev tun0",
+		"10.1.0.1":      "",
+		"10.2.0.1":      "prohibit",
+		"2001:db8:1::1": "dev eth0",
+	} {
+		if got, _ := m.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package fib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"github.com/sakateka/lpm"
+)
+
+// errOverrun means the kernel dropped route notifications the monitor did not read in time
+var errOverrun = errors.New("fib: route notifications lost")
+
+// Monitor keeps a trie in sync with a routing table of the kernel: it subscribes to the
+// route notifications of rtnetlink (RTNLGRP_IPV4_ROUTE and RTNLGRP_IPV6_ROUTE) and applies
+// every added and deleted route to its own copy of the trie, publishing a snapshot after
+// each batch of notifications, so lookups never see a half-applied change.
+//
+//	routes, err := fib.NewMonitor(ctx, fib.TableMain, fib.NextHop)
+//	if err != nil {
+//	    return err
+//	}
+//	defer routes.Close()
+//
+//	nextHop, found := routes.Lookup(addr)
+//
+// If the kernel drops notifications because the monitor fell behind, the table is reloaded
+// in full. Like Load, a Monitor only works on Linux.
+type Monitor struct {
+	tableID uint32
+	value   Value
+	opts    []lpm.Option
+
+	conn   *routeConn
+	routes map[netip.Prefix][]Route // routes of the table by destination
+	trie   *lpm.LPM                 // written by the monitor goroutine only
+	table  lpm.Atomic
+
+	mu  sync.Mutex // guards err
+	err error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMonitor loads the routes of the table, or of all tables for TableAll, like Load, and
+// keeps them up to date until Close is called or ctx is done
+func NewMonitor(ctx context.Context, table uint32, value Value, opts ...lpm.Option) (*Monitor, error) {
+	// Subscribe before loading the table, so no change between the two is missed
+	conn, err := subscribe()
+	if err != nil {
+		return nil, err
+	}
+	m := &Monitor{
+		tableID: table,
+		value:   value,
+		opts:    opts,
+		conn:    conn,
+		done:    make(chan struct{}),
+	}
+	if err := m.resync(); err != nil {
+		conn.close()
+		return nil, err
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	go m.run(ctx)
+	return m, nil
+}
+
+// Load returns the current table. It is safe to call from any goroutine.
+func (m *Monitor) Load() *lpm.LPM {
+	return m.table.Load()
+}
+
+// Lookup returns the value of the longest prefix containing addr in the current table
+func (m *Monitor) Lookup(addr netip.Addr) (string, bool) {
+	return m.table.Lookup(addr)
+}
+
+// Err returns the error that stopped the monitor, if any. The table is no longer updated
+// once it is set.
+func (m *Monitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Close stops the monitor. The last table stays available through Load.
+func (m *Monitor) Close() error {
+	m.cancel()
+	<-m.done
+	return nil
+}
+
+func (m *Monitor) run(ctx context.Context) {
+	defer close(m.done)
+	stop := context.AfterFunc(ctx, m.conn.interrupt)
+	defer stop()
+	defer m.conn.close()
+
+	for {
+		events, err := m.conn.read()
+		if errors.Is(err, errOverrun) {
+			err = m.resync()
+		} else if err == nil {
+			err = m.applyAll(events)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			m.mu.Lock()
+			m.err = err
+			m.mu.Unlock()
+			return
+		}
+	}
+}
+
+// resync rebuilds the trie from all routes of the table
+func (m *Monitor) resync() error {
+	routes, err := m.conn.dump(m.tableID)
+	if err != nil {
+		return err
+	}
+	m.routes = make(map[netip.Prefix][]Route)
+	for _, r := range routes {
+		m.routes[r.Prefix] = append(m.routes[r.Prefix], r)
+	}
+	if m.trie, err = build(routes, m.value, m.opts...); err != nil {
+		return err
+	}
+	m.table.Store(m.trie.Snapshot())
+	return nil
+}
+
+// routeEvent is a route added or deleted
+type routeEvent struct {
+	route   Route
+	deleted bool
+}
+
+// applyAll applies the events and publishes the result
+func (m *Monitor) applyAll(events []routeEvent) error {
+	changed := false
+	for _, e := range events {
+		if m.tableID != TableAll && e.route.Table != m.tableID {
+			continue
+		}
+		if err := m.apply(e); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if changed {
+		m.table.Store(m.trie.Snapshot())
+	}
+	return nil
+}
+
+// apply updates the routes to the destination of the event and stores the one the kernel
+// prefers, as Load does
+func (m *Monitor) apply(e routeEvent) error {
+	prefix := e.route.Prefix
+	// The kernel tells routes to a destination apart by table and metric
+	routes := slices.DeleteFunc(m.routes[prefix], func(r Route) bool {
+		return r.Table == e.route.Table && r.Metric == e.route.Metric
+	})
+	if !e.deleted {
+		routes = append(routes, e.route)
+	}
+	if len(routes) == 0 {
+		delete(m.routes, prefix)
+	} else {
+		m.routes[prefix] = routes
+	}
+
+	var best *Route
+	for i := range routes {
+		if best == nil || routes[i].Metric < best.Metric {
+			best = &routes[i]
+		}
+	}
+	var err error
+	switch {
+	case best == nil:
+		_, err = m.trie.Delete(prefix)
+	case best.Type == TypeThrow:
+		err = m.trie.InsertTombstone(prefix)
+	default:
+		if v, ok := m.value(*best); ok {
+			err = m.trie.Insert(prefix, v)
+		} else {
+			_, err = m.trie.Delete(prefix)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+	return nil
+}
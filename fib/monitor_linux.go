@@ -0,0 +1,95 @@
+package fib
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// routeConn is a netlink socket subscribed to route notifications
+type routeConn struct {
+	f     *os.File
+	buf   []byte
+	names map[int]string
+}
+
+func subscribe() (*routeConn, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	groups := uint32(1<<(syscall.RTNLGRP_IPV4_ROUTE-1) | 1<<(syscall.RTNLGRP_IPV6_ROUTE-1))
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	// Reads go through the runtime poller, so interrupt can cancel them
+	return &routeConn{f: os.NewFile(uintptr(fd), "rtnetlink"), buf: make([]byte, 1<<16)}, nil
+}
+
+// dump returns the routes of the table
+func (c *routeConn) dump(table uint32) ([]Route, error) {
+	c.names = interfaceNames()
+	return Routes(table)
+}
+
+// read waits for the next batch of route notifications
+func (c *routeConn) read() ([]routeEvent, error) {
+	n, err := c.f.Read(c.buf)
+	if errors.Is(err, syscall.ENOBUFS) {
+		return nil, errOverrun
+	}
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(c.buf[:n])
+	if err != nil {
+		return nil, os.NewSyscallError("parsenetlinkmessage", err)
+	}
+
+	var events []routeEvent
+	for i := range msgs {
+		msg := &msgs[i]
+		if msg.Header.Type != syscall.RTM_NEWROUTE && msg.Header.Type != syscall.RTM_DELROUTE {
+			continue
+		}
+		r, ok, err := parseRoute(msg, c.names)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if c.resolve(r.Hops) {
+			c.names = interfaceNames()
+			c.resolve(r.Hops)
+		}
+		events = append(events, routeEvent{route: r, deleted: msg.Header.Type == syscall.RTM_DELROUTE})
+	}
+	return events, nil
+}
+
+// resolve fills in the interface names of the hops, and reports whether some are unknown,
+// e.g. of interfaces created after the names were read
+func (c *routeConn) resolve(hops []Hop) bool {
+	unknown := false
+	for i := range hops {
+		if hops[i].Interface == "" && hops[i].Index != 0 {
+			hops[i].Interface = c.names[hops[i].Index]
+			unknown = unknown || hops[i].Interface == ""
+		}
+	}
+	return unknown
+}
+
+// interrupt makes a pending read fail
+func (c *routeConn) interrupt() {
+	if err := c.f.SetReadDeadline(time.Now()); err != nil {
+		c.f.Close()
+	}
+}
+
+func (c *routeConn) close() {
+	c.f.Close()
+}
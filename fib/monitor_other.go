@@ -0,0 +1,24 @@
+//go:build !linux
+
+package fib
+
+import "errors"
+
+// routeConn stands in for the netlink socket of Linux
+type routeConn struct{}
+
+func subscribe() (*routeConn, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (c *routeConn) dump(table uint32) ([]Route, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (c *routeConn) read() ([]routeEvent, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (c *routeConn) interrupt() {}
+
+func (c *routeConn) close() {}
@@ -0,0 +1,85 @@
+package fib
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+// TestMonitorApply tests applying added and deleted routes incrementally
+func TestMonitorApply(t *testing.T) {
+	m := &Monitor{tableID: TableMain, value: NextHop, routes: make(map[netip.Prefix][]Route), trie: lpm.New()}
+	route := func(cidr string, typ uint8, metric uint32, dev string) Route {
+		return Route{Prefix: netip.MustParsePrefix(cidr), Table: TableMain, Type: typ, Metric: metric, Hops: []Hop{{Interface: dev}}}
+	}
+	add := func(r Route) routeEvent { return routeEvent{route: r} }
+	del := func(r Route) routeEvent { return routeEvent{route: r, deleted: true} }
+	lookup := func(addr string) string {
+		value, _ := m.Load().Lookup(netip.MustParseAddr(addr))
+		return value
+	}
+
+	wifi := route("0.0.0.0/0", TypeUnicast, 600, "wlan0")
+	wired := route("0.0.0.0/0", TypeUnicast, 100, "eth0")
+	other := route("10.0.0.0/8", TypeUnicast, 0, "tun0")
+	other.Table = 100
+	if err := m.applyAll([]routeEvent{add(wifi), add(wired), add(other), add(route("10.1.0.0/16", TypeThrow, 0, ""))}); err != nil {
+		t.Fatalf("applyAll failed: %v", err)
+	}
+	if got := lookup("10.0.0.1"); got != "dev eth0" {
+		t.Errorf("Lookup(10.0.0.1) = %q, want the route with the lower metric", got)
+	}
+	if _, found := m.Load().Lookup(netip.MustParseAddr("10.1.0.1")); found {
+		t.Error("Lookup(10.1.0.1) of a throw route found a value")
+	}
+
+	before := m.Load()
+	if err := m.applyAll([]routeEvent{del(wired)}); err != nil {
+		t.Fatalf("applyAll failed: %v", err)
+	}
+	if got := lookup("10.0.0.1"); got != "dev wlan0" {
+		t.Errorf("Lookup(10.0.0.1) after deleting the preferred route = %q, want dev wlan0", got)
+	}
+	if got, _ := before.Lookup(netip.MustParseAddr("10.0.0.1")); got != "dev eth0" {
+		t.Errorf("published snapshot changed to %q", got)
+	}
+
+	replaced := wifi
+	replaced.Hops = []Hop{{Interface: "wlan1"}}
+	if err := m.applyAll([]routeEvent{add(replaced), del(route("10.1.0.0/16", TypeThrow, 0, ""))}); err != nil {
+		t.Fatalf("applyAll failed: %v", err)
+	}
+	if got := lookup("10.1.0.1"); got != "dev wlan1" {
+		t.Errorf("Lookup(10.1.0.1) = %q, want dev wlan1", got)
+	}
+	if err := m.applyAll([]routeEvent{del(replaced)}); err != nil {
+		t.Fatalf("applyAll failed: %v", err)
+	}
+	if _, found := m.Load().Lookup(netip.MustParseAddr("10.0.0.1")); found || len(m.routes) != 0 {
+		t.Errorf("table after deleting every route = %v routes, want none", m.routes)
+	}
+}
+
+// TestMonitorDeleteSpecific tests that deleting more specific routes uncovers the route
+// they covered completely
+func TestMonitorDeleteSpecific(t *testing.T) {
+	m := &Monitor{tableID: TableMain, value: NextHop, routes: make(map[netip.Prefix][]Route), trie: lpm.New()}
+	route := func(cidr, dev string) routeEvent {
+		return routeEvent{route: Route{Prefix: netip.MustParsePrefix(cidr), Table: TableMain, Type: TypeUnicast, Hops: []Hop{{Interface: dev}}}}
+	}
+	if err := m.applyAll([]routeEvent{route("10.0.0.0/24", "eth0"), route("10.0.0.0/25", "eth1"), route("10.0.0.128/25", "eth2")}); err != nil {
+		t.Fatalf("applyAll failed: %v", err)
+	}
+
+	deleted := route("10.0.0.0/25", "eth1")
+	deleted.deleted = true
+	if err := m.applyAll([]routeEvent{deleted}); err != nil {
+		t.Fatalf("applyAll failed: %v", err)
+	}
+	for addr, want := range map[string]string{"10.0.0.1": "dev eth0", "10.0.0.129": "dev eth2"} {
+		if got, _ := m.Load().Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
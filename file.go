@@ -0,0 +1,102 @@
+package lpm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SaveToFile packs the trie to path atomically: the storage is written to a temporary
+// file in the same directory, synced to disk and renamed over path, so readers see
+// either the previous or the new storage but never a partially written one.
+// With WithFileLock, writers and LoadFromFile callers are serialized through path.lock.
+func (m *LPM) SaveToFile(path string, opts ...Option) (err error) {
+	o := newOptions(opts)
+	defer func() {
+		if err != nil {
+			m.log().Warn("saving storage failed", "path", path, "error", err)
+		} else {
+			m.log().Info("saved storage", "path", path, "generation", m.generation)
+		}
+	}()
+
+	if o.fileLock {
+		unlock, err := lockFile(path, true)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := m.PackTo(tmp); err != nil {
+		return fmt.Errorf("%s: %w", tmp.Name(), err)
+	}
+	// CreateTemp creates the file accessible to the owner only
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// Persist the rename itself
+	return syncDir(dir)
+}
+
+// LoadFromFile loads storage written by SaveToFile or PackTo from path into memory.
+// With WithFileLock, it waits for a SaveToFile holding path.lock to finish.
+func LoadFromFile(path string, opts ...Option) (*LPM, error) {
+	o := newOptions(opts)
+	lpm, err := loadFromFile(path, o)
+	logLoad(o.log().With("path", path), lpm, err)
+	return lpm, err
+}
+
+func loadFromFile(path string, o options) (*LPM, error) {
+	if o.fileLock {
+		unlock, err := lockFile(path, false)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lpm, err := newFromReader(io.NewSectionReader(f, 0, 1<<63-1), o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lpm, nil
+}
+
+// lockPath returns the lock file guarding path. The storage file itself is replaced
+// on every save, so it cannot carry the lock.
+func lockPath(path string) string {
+	return path + ".lock"
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package lpm
+
+import (
+	"errors"
+	"fmt"
+)
+
+func lockFile(path string, exclusive bool) (func(), error) {
+	return nil, fmt.Errorf("%s: file locking is %w on this platform", lockPath(path), errors.ErrUnsupported)
+}
+
+// syncDir is a no-op: directories cannot be synced on this platform
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package lpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Generation returns a counter bumped by every modification of the trie: inserts,
+// Compact calls that remove blocks and metadata changes. It is recorded in packed storage
+// and restored on load, so it keeps growing across load, modify and pack cycles and
+// StorageInfo tells which generation a file holds without loading it.
+// Storage in format versions before 5 loads with generation 0.
+func (m *LPM) Generation() uint64 {
+	return m.generation
+}
+
+// Fingerprint returns a hash of the mapping from addresses to values that lookups see.
+// Two tries that answer every lookup the same have the same fingerprint, regardless of
+// the prefixes they were built from, priorities, the order of inserts, Compact, or whether
+// they live in shared storage, so comparing fingerprints tells whether a new table
+// actually differs from the current one. Metadata and the generation are not included.
+func (m *LPM) Fingerprint() uint64 {
+	h := fnv.New64a()
+	for _, proto := range []int{v4LPM, v6LPM} {
+		h.Write([]byte{byte(proto)})
+		if m.blockCount(proto) == 0 {
+			continue
+		}
+
+		// Hash the start address and value of every run of addresses mapping to the
+		// same value, so the way the runs are split into slots does not matter
+		var path [16]byte
+		var buf []byte
+		lastIdx, lastFound := -1, false
+		var lastValue []byte
+		var walk func(blockIdx int, depth int)
+		walk = func(blockIdx int, depth int) {
+			for slot, encoded := range m.getBlockRef(proto, blockIdx) {
+				path[depth] = byte(slot)
+				if isBlockRef(encoded) {
+					walk(decodeBlockRef(encoded), depth+1)
+					continue
+				}
+
+				valueIdx, _ := decodeValue(encoded)
+				found := !isInvalid(encoded) && valueIdx != tombstoneIdx
+				if !found {
+					valueIdx = -1
+				}
+				if valueIdx == lastIdx {
+					continue
+				}
+				var value []byte
+				if found {
+					value, _ = m.valueBytesByIndex(valueIdx)
+				}
+				lastIdx = valueIdx
+				if found == lastFound && bytes.Equal(value, lastValue) {
+					continue
+				}
+				lastFound, lastValue = found, value
+
+				buf = append(buf[:0], path[:]...)
+				if found {
+					buf = append(buf, 1)
+					buf = binary.AppendUvarint(buf, uint64(len(value)))
+					buf = append(buf, value...)
+				} else {
+					buf = append(buf, 0)
+				}
+				h.Write(buf)
+			}
+			path[depth] = 0
+		}
+		walk(m.root[proto], 0)
+	}
+	return h.Sum64()
+}
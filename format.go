@@ -0,0 +1,292 @@
+package lpm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Storage format versions:
+//
+//   - 1: header of 32-bit fields, value slots of a 1-byte length and up to
+//     255 bytes of value. Still loaded, see MigrateStorage.
+//   - 2: adds ByteOrder and Checksum to the header, value slots of a 2-byte length,
+//     a 1-byte priority and up to 65535 bytes of value. Storage packed before the
+//     header gained ByteOrder and Checksum is still loaded, including its value slots
+//     without a priority byte, see isLegacyV2.
+//   - 3: header of 64-bit counts and offsets, so storage may exceed 4GB.
+//     Value slots are the same as in version 2.
+//   - 4: adds a metadata section after the values, see metadata.go.
+//   - 5: adds the generation of the trie to the header.
+//   - 6: adds the sequence counter of the live update protocol, see seqlock.go.
+//
+// Loading decodes the header of any supported version into a StorageHeader, so the rest
+// of the loader only deals with the format differences of the value slots. Any change to
+// the header or the value slots takes a new version, so storage already out there keeps
+// loading.
+
+// storageHeaderV1 is the header of format version 1
+type storageHeaderV1 struct {
+	Magic          uint32
+	Version        uint32
+	V4BlockCount   uint32
+	V6BlockCount   uint32
+	ValueCount     uint32
+	ValueSlotSize  uint32
+	V4BlocksOffset uint32
+	V6BlocksOffset uint32
+	ValuesOffset   uint32
+}
+
+// storageHeaderV2 is the header of format version 2
+type storageHeaderV2 struct {
+	storageHeaderV1
+	ByteOrder uint32
+	Checksum  uint32
+}
+
+// storageHeaderV3 is the header of format version 3
+type storageHeaderV3 struct {
+	Magic          uint32
+	Version        uint32
+	ByteOrder      uint32
+	Checksum       uint32
+	V4BlockCount   uint64
+	V6BlockCount   uint64
+	ValueCount     uint64
+	ValueSlotSize  uint64
+	V4BlocksOffset uint64
+	V6BlocksOffset uint64
+	ValuesOffset   uint64
+}
+
+// storageHeaderV4 is the header of format version 4
+type storageHeaderV4 struct {
+	storageHeaderV3
+	MetadataOffset uint64
+	MetadataSize   uint64
+}
+
+// storageHeaderV5 is the header of format version 5
+type storageHeaderV5 struct {
+	storageHeaderV4
+	Generation uint64
+}
+
+// headerSizes holds the header size of every format version. The headers have no padding,
+// so their encoded size is their size in memory.
+var headerSizes = [...]int{
+	versionV1:      binary.Size(storageHeaderV1{}),
+	versionV2:      binary.Size(storageHeaderV2{}),
+	versionV3:      binary.Size(storageHeaderV3{}),
+	versionV4:      binary.Size(storageHeaderV4{}),
+	versionV5:      binary.Size(storageHeaderV5{}),
+	currentVersion: binary.Size(StorageHeader{}),
+}
+
+// headerSize returns the size of the header of the given format version
+func headerSize(version uint32) int {
+	if version < versionV1 || version > currentVersion {
+		return headerSizes[currentVersion]
+	}
+	return headerSizes[version]
+}
+
+// isLegacyV2 reports whether the header describes version 2 storage packed before the header
+// gained ByteOrder and Checksum. Such storage has a section starting where those fields would
+// be, and carries no checksum to verify.
+func isLegacyV2(header *StorageHeader) bool {
+	if header.Version != versionV2 {
+		return false
+	}
+	for _, offset := range []uint64{header.V4BlocksOffset, header.V6BlocksOffset, header.ValuesOffset} {
+		if offset >= uint64(headerSize(versionV1)) && offset < uint64(headerSize(versionV2)) {
+			return true
+		}
+	}
+	return false
+}
+
+// storedHeaderSize returns the size of the header at the start of the storage it describes
+func storedHeaderSize(header *StorageHeader) int {
+	if isLegacyV2(header) {
+		return headerSize(versionV1)
+	}
+	return headerSize(header.Version)
+}
+
+// hasSlotPriorities reports whether the value slots of legacy version 2 storage hold a
+// priority byte after the length. Slots are sized for the longest value, so without the
+// priority byte some value fills its slot up to the 2-byte length.
+func hasSlotPriorities(values []byte, slotSize int) bool {
+	for offset := 0; offset+slotSize <= len(values); offset += slotSize {
+		if int(binary.NativeEndian.Uint16(values[offset:])) == slotSize-2 {
+			return false
+		}
+	}
+	return true
+}
+
+// checksumOffset returns the offset of the Checksum field in the header of the given format version
+func checksumOffset(version uint32) int {
+	if version == versionV2 {
+		// Checksum follows ByteOrder after the version 1 fields
+		return headerSize(versionV1) + 4
+	}
+	// Checksum follows Magic, Version and ByteOrder
+	return 12
+}
+
+// sequenceOffset returns the offset of the Sequence field in the header of the given format
+// version, or -1 if the version has none
+func sequenceOffset(version uint32) int {
+	if version <= versionV5 {
+		return -1
+	}
+	// Sequence is the last field
+	return headerSize(currentVersion) - 8
+}
+
+// preambleSize is the size of the magic number and version all format versions start with
+const preambleSize = 8
+
+// decodePreamble checks the magic number and returns the format version in host byte order.
+// foreign reports whether the storage was packed on a host of the other byte order.
+func decodePreamble(storage []byte) (version uint32, foreign bool, err error) {
+	if len(storage) < preambleSize {
+		return 0, false, &ErrTruncated{Section: "header", Need: preambleSize, Got: len(storage)}
+	}
+
+	magic := binary.NativeEndian.Uint32(storage)
+	version = binary.NativeEndian.Uint32(storage[4:])
+	switch magic {
+	case magicNumber:
+	case bits.ReverseBytes32(magicNumber):
+		foreign = true
+		version = bits.ReverseBytes32(version)
+	default:
+		return 0, false, fmt.Errorf("%w: expected 0x%08X, got 0x%08X", ErrBadMagic, magicNumber, magic)
+	}
+
+	if version < versionV1 || version > currentVersion {
+		return 0, false, fmt.Errorf("%w: expected %d, got %d", ErrVersionMismatch, currentVersion, version)
+	}
+	return version, foreign, nil
+}
+
+// readHeader decodes and validates the header at the start of storage into host byte order.
+// Headers of older format versions are converted, leaving the fields they lack zero.
+func readHeader(storage []byte) (header StorageHeader, foreign bool, err error) {
+	version, foreign, err := decodePreamble(storage)
+	if err != nil {
+		return header, false, err
+	}
+	need := headerSize(version)
+	if version == versionV2 {
+		// Legacy version 2 headers end with the version 1 fields, checked again below
+		need = headerSize(versionV1)
+	}
+	if len(storage) < need {
+		return header, false, &ErrTruncated{Section: "header", Need: need, Got: len(storage)}
+	}
+
+	d := headerDecoder{data: storage, foreign: foreign}
+	if version == versionV1 || version == versionV2 {
+		header = StorageHeader{
+			Magic:          d.uint32(),
+			Version:        d.uint32(),
+			V4BlockCount:   uint64(d.uint32()),
+			V6BlockCount:   uint64(d.uint32()),
+			ValueCount:     uint64(d.uint32()),
+			ValueSlotSize:  uint64(d.uint32()),
+			V4BlocksOffset: uint64(d.uint32()),
+			V6BlocksOffset: uint64(d.uint32()),
+			ValuesOffset:   uint64(d.uint32()),
+		}
+		if version == versionV1 || isLegacyV2(&header) {
+			return header, foreign, nil
+		}
+		if len(storage) < headerSize(version) {
+			return header, false, &ErrTruncated{Section: "header", Need: headerSize(version), Got: len(storage)}
+		}
+		header.ByteOrder = d.uint32()
+		header.Checksum = d.uint32()
+	} else {
+		header = StorageHeader{
+			Magic:          d.uint32(),
+			Version:        d.uint32(),
+			ByteOrder:      d.uint32(),
+			Checksum:       d.uint32(),
+			V4BlockCount:   d.uint64(),
+			V6BlockCount:   d.uint64(),
+			ValueCount:     d.uint64(),
+			ValueSlotSize:  d.uint64(),
+			V4BlocksOffset: d.uint64(),
+			V6BlocksOffset: d.uint64(),
+			ValuesOffset:   d.uint64(),
+		}
+		if version != versionV3 {
+			header.MetadataOffset = d.uint64()
+			header.MetadataSize = d.uint64()
+		}
+		if version != versionV3 && version != versionV4 {
+			header.Generation = d.uint64()
+		}
+		if version != versionV3 && version != versionV4 && version != versionV5 {
+			header.Sequence = d.uint64()
+		}
+	}
+
+	if header.ByteOrder != byteOrderMark {
+		return header, false, fmt.Errorf("%w: expected 0x%08X, got 0x%08X", ErrBadByteOrder, byteOrderMark, header.ByteOrder)
+	}
+	return header, foreign, nil
+}
+
+// headerDecoder reads consecutive header fields, swapping them if the storage is foreign
+type headerDecoder struct {
+	data    []byte
+	foreign bool
+}
+
+func (d *headerDecoder) uint32() uint32 {
+	v := binary.NativeEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	if d.foreign {
+		return bits.ReverseBytes32(v)
+	}
+	return v
+}
+
+func (d *headerDecoder) uint64() uint64 {
+	v := binary.NativeEndian.Uint64(d.data)
+	d.data = d.data[8:]
+	if d.foreign {
+		return bits.ReverseBytes64(v)
+	}
+	return v
+}
+
+// sectionEnd returns the end offset of a section of count items of size bytes starting at
+// offset. It saturates at math.MaxInt, so corrupt headers cannot overflow size checks.
+func sectionEnd(offset, count, size uint64) int {
+	hi, length := bits.Mul64(count, size)
+	end, carry := bits.Add64(offset, length, 0)
+	if hi != 0 || carry != 0 || end > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(end)
+}
+
+// MigrateStorage converts storage of any supported format version, e.g. version 1 blobs
+// persisted by earlier releases, into the current format in the host byte order.
+// Storage already in the current format is repacked as well, so the result is always
+// a new slice that can be loaded with NewWithSharedStorage.
+func MigrateStorage(storage []byte) ([]byte, error) {
+	lpm, err := NewWithSharedStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+	return lpm.PackToSharedStorage()
+}
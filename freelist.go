@@ -0,0 +1,123 @@
+package lpm
+
+import "net/netip"
+
+// Blocks die when a Delete or an overriding insert leaves every slot of a block holding
+// the same value of a prefix no longer than the block's parent slot covers: the block is
+// then replaced by that value in its parent slot, which is exactly what inserting the
+// remaining prefixes into a fresh trie would produce. Dead dynamic blocks go on a free
+// list that new blocks are taken from, so tries with churn stop growing; dead shared or
+// frozen blocks are left to reclaim, like the ones replaced by copies.
+
+// newBlock copies blk into a dead block if there is one, or into a new one from the slab,
+// returning its index
+func (t *trie) newBlock(proto int, blk *LPMBlock) int {
+	if n := len(t.free[proto]); n > 0 {
+		blockIdx := t.free[proto][n-1]
+		t.free[proto] = t.free[proto][:n-1]
+		*t.dynamic[proto][blockIdx-len(t.shared[proto])] = *blk
+		return blockIdx
+	}
+	dst := t.allocBlock(proto)
+	*dst = *blk
+	t.dynamic[proto] = append(t.dynamic[proto], dst)
+	return t.blockCount(proto) - 1
+}
+
+// freeBlock records that a block is no longer referenced
+func (t *trie) freeBlock(proto int, blockIdx int) {
+	if t.isFrozen(proto, blockIdx) {
+		t.copied[proto]++
+		return
+	}
+	t.free[proto] = append(t.free[proto], blockIdx)
+}
+
+// collapsible returns the value a block at the given depth can be replaced with in its
+// parent slot, if any
+func (t *trie) collapsible(proto int, blockIdx int, depth int) (uint32, bool) {
+	blk := t.getBlockRef(proto, blockIdx)
+	first := blk[0]
+	if isBlockRef(first) {
+		return 0, false
+	}
+	if _, prefixLen := decodeValue(first); !isInvalid(first) && prefixLen > depth*8 {
+		return 0, false
+	}
+	for _, encoded := range blk[1:] {
+		if encoded != first {
+			return 0, false
+		}
+	}
+	return first, true
+}
+
+// collapse replaces the dead blocks a modification of the prefix may have left: those
+// below the slots it covers, then those on the path to it, deepest first
+func (t *trie) collapse(net netip.Prefix) {
+	proto := v4LPM
+	if net.Addr().Is6() {
+		proto = v6LPM
+	}
+	addr := net.Addr().AsSlice()
+	lastDepth := max(net.Bits()-1, 0) / 8
+
+	// The blocks on the path exist, as the modification just walked it
+	var path [16]int
+	path[0] = t.root[proto]
+	for depth := range lastDepth {
+		path[depth+1] = decodeBlockRef(t.getValue(proto, path[depth], addr[depth]))
+	}
+
+	tail := (lastDepth+1)*8 - net.Bits()
+	startIdx := addr[lastDepth] & uint8(0xff<<tail)
+	endIdx := startIdx | ^uint8(0xff<<tail)
+	for slot := int(startIdx); slot <= int(endIdx); slot++ {
+		t.collapseBelow(proto, path[lastDepth], lastDepth, uint8(slot), addr)
+	}
+	for depth := lastDepth; depth > 0; depth-- {
+		if !t.collapseChild(proto, path[depth-1], depth-1, addr[depth-1], addr) {
+			return
+		}
+	}
+}
+
+// collapseBelow collapses the dead blocks of the subtree under a slot, bottom up
+func (t *trie) collapseBelow(proto int, parentIdx int, parentDepth int, slot uint8, addr []byte) {
+	encoded := t.getValue(proto, parentIdx, slot)
+	if !isBlockRef(encoded) || t.isFrozen(proto, parentIdx) {
+		return
+	}
+	blockIdx := decodeBlockRef(encoded)
+	for childSlot, childEncoded := range t.getBlockRef(proto, blockIdx) {
+		if isBlockRef(childEncoded) {
+			t.collapseBelow(proto, blockIdx, parentDepth+1, uint8(childSlot), addr)
+		}
+	}
+	t.collapseChild(proto, parentIdx, parentDepth, slot, addr)
+}
+
+// collapseChild replaces the block under a slot with its value if it is dead, reporting
+// whether it did. addr holds the path bytes above the slot, for the IPv4Stride16 table.
+func (t *trie) collapseChild(proto int, parentIdx int, parentDepth int, slot uint8, addr []byte) bool {
+	if t.isFrozen(proto, parentIdx) {
+		return false
+	}
+	blockIdx := decodeBlockRef(t.getValue(proto, parentIdx, slot))
+	value, ok := t.collapsible(proto, blockIdx, parentDepth+1)
+	if !ok {
+		return false
+	}
+	t.setValue(proto, parentIdx, slot, value)
+	t.freeBlock(proto, blockIdx)
+
+	if proto == v4LPM {
+		switch parentDepth {
+		case 0:
+			t.refreshStride16(int(slot)<<8, int(slot)<<8|0xff)
+		case 1:
+			t.refreshStride16(int(addr[0])<<8|int(slot), int(addr[0])<<8|int(slot))
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package lpm
+
+import "net/netip"
+
+// liveValues marks the value indices referenced by any slot of any block and returns,
+// for each of the valueCount indices, its position among the live values (or -1 if
+// nothing refers to it any more), along with the number of live values.
+func (t *trie) liveValues(valueCount int) ([]int, int) {
+	remap := make([]int, valueCount)
+	for i := range remap {
+		remap[i] = -1
+	}
+
+	for _, proto := range []int{v4LPM, v6LPM} {
+		blocks, _ := t.packOrder(proto)
+		for _, i := range blocks {
+			for _, encoded := range t.getBlockRef(proto, i) {
+				if isBlockRef(encoded) || isInvalid(encoded) {
+					continue
+				}
+				valueIdx, _ := decodeValue(encoded)
+				if valueIdx < valueCount {
+					remap[valueIdx] = 0
+				}
+			}
+		}
+	}
+
+	live := 0
+	for i := range remap {
+		if remap[i] == 0 {
+			remap[i] = live
+			live++
+		}
+	}
+	return remap, live
+}
+
+// remapBlock rewrites the value indices of a block according to remap
+func remapBlock(blk *LPMBlock, remap []int) {
+	for idx, encoded := range blk {
+		if isBlockRef(encoded) || isInvalid(encoded) {
+			continue
+		}
+		valueIdx, prefixLen := decodeValue(encoded)
+		if valueIdx == tombstoneIdx {
+			continue
+		}
+		if newIdx := remap[valueIdx]; newIdx != valueIdx {
+			blk[idx] = encodeValue(newIdx, prefixLen)
+		}
+	}
+}
+
+// valueCount returns the number of shared and dynamic values
+func (m *LPM) valueCount() int {
+	return m.sharedValueCount + len(m.revValues)
+}
+
+// CompactValues drops dynamic values that are no longer referenced by any prefix,
+// e.g. after a prefix was overwritten with a different value, and returns how many
+// values were dropped. Values held in shared storage are left in place.
+// Indices previously returned by LookupIndex may change.
+func (m *LPM) CompactValues() int {
+	remap, _ := m.liveValues(m.valueCount())
+	// Shadowed prefixes keep their values for Delete to uncover
+	m.prefixes.all(func(_ netip.Prefix, valueIdx int) {
+		if valueIdx != tombstoneIdx && remap[valueIdx] < 0 {
+			remap[valueIdx] = 0
+		}
+	})
+
+	// Shared values keep their indices, dynamic ones are renumbered after them
+	next := m.sharedValueCount
+	for i := 0; i < m.sharedValueCount; i++ {
+		remap[i] = i
+	}
+	revValues := m.revValues[:0:0]
+	revPriorities := m.revPriorities[:0:0]
+	valueBytes := 0
+	for i, val := range m.revValues {
+		valueIdx := m.sharedValueCount + i
+		key := valueKey{value: val, priority: m.revPriorities[i]}
+		if remap[valueIdx] < 0 {
+			delete(m.values, key)
+			continue
+		}
+		remap[valueIdx] = next
+		m.values[key] = next
+		revValues = append(revValues, val)
+		revPriorities = append(revPriorities, key.priority)
+		valueBytes += len(val)
+		next++
+	}
+
+	dropped := len(m.revValues) - len(revValues)
+	if dropped == 0 {
+		return 0
+	}
+	m.revValues = revValues
+	m.revPriorities = revPriorities
+	m.valueBytes = valueBytes
+
+	// Blocks are rewritten in place below, which snapshots must not see
+	m.thaw()
+	for _, proto := range []int{v4LPM, v6LPM} {
+		for i := 0; i < m.blockCount(proto); i++ {
+			remapBlock(m.getBlockRef(proto, i), remap)
+		}
+	}
+	m.prefixes.remap(remap)
+	m.refreshStride16(0, stride16Size-1)
+	return dropped
+}
@@ -0,0 +1,318 @@
+// Package geolite loads the CSV edition of GeoLite2 and GeoIP2 Country and City databases
+// into an LPM table. The bundle, either as downloaded or unpacked, holds a blocks file per
+// address family mapping networks to geoname IDs, and a locations file per locale mapping
+// the IDs to countries and cities; Load joins them:
+//
+//	countries, err := geolite.Load("GeoLite2-Country-CSV_20240102.zip", "en", geolite.Country)
+//	if err != nil {
+//	    return err
+//	}
+//	code, found := countries.Lookup(addr) // "DE", true
+//
+// For MaxMind DB (.mmdb) databases, see the mmdb package.
+package geolite
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+// Location is a row of a locations file. City fields are empty in Country databases.
+type Location struct {
+	GeonameID           string
+	ContinentCode       string
+	ContinentName       string
+	CountryISOCode      string
+	CountryName         string
+	Subdivision1ISOCode string
+	Subdivision1Name    string
+	Subdivision2ISOCode string
+	Subdivision2Name    string
+	CityName            string
+	TimeZone            string
+	InEuropeanUnion     bool
+}
+
+// Block is a row of a blocks file joined with its locations. Locations whose ID is empty
+// in the row are zero.
+type Block struct {
+	Prefix             netip.Prefix
+	Location           Location // where the network is, or its registered country if unknown
+	RegisteredCountry  Location // where the ISP registered the network
+	RepresentedCountry Location // country represented by users of the network, e.g. a military base
+	AnonymousProxy     bool
+	SatelliteProvider  bool
+	Anycast            bool
+	PostalCode         string
+}
+
+// Value returns the value a block is stored with in the trie, and false to leave the block
+// out
+type Value func(Block) (string, bool)
+
+// Country stores blocks as the ISO code of their country, e.g. "DE". Blocks without one,
+// such as those of anonymous proxies, are left out.
+func Country(b Block) (string, bool) {
+	return b.Location.CountryISOCode, b.Location.CountryISOCode != ""
+}
+
+// City stores blocks as "country,subdivision,city" of ISO codes and the city name, e.g.
+// "US,CA,Mountain View"; blocks only located to a country have empty subdivision and city
+func City(b Block) (string, bool) {
+	l := b.Location
+	if l.CountryISOCode == "" {
+		return "", false
+	}
+	return strings.Join([]string{l.CountryISOCode, l.Subdivision1ISOCode, l.CityName}, ","), true
+}
+
+// GeonameID stores blocks as the geoname ID of their location, to join lookups with
+// other data of GeoNames
+func GeonameID(b Block) (string, bool) {
+	return b.Location.GeonameID, b.Location.GeonameID != ""
+}
+
+// Load loads the bundle at path, a ZIP archive or a directory, with the locations of the
+// locale, e.g. "en" or "zh-CN", see LoadFS
+func Load(path, locale string, value Value, opts ...lpm.Option) (*lpm.LPM, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return LoadFS(os.DirFS(path), locale, value, opts...)
+	}
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	return LoadFS(archive, locale, value, opts...)
+}
+
+// LoadFS loads the bundle in fsys, whose files are either at the root or, as in the
+// archives MaxMind publishes, in a single directory. It joins the IPv4 and IPv6 blocks
+// files present with the locations file of the locale, "en" if empty, and inserts the
+// values of the blocks into a trie. The options are passed to lpm.New, and the trie is
+// compacted at the end.
+func LoadFS(fsys fs.FS, locale string, value Value, opts ...lpm.Option) (*lpm.LPM, error) {
+	if locale == "" {
+		locale = "en"
+	}
+	locationsPath, err := find(fsys, "*-Locations-"+locale+".csv")
+	if err != nil {
+		return nil, err
+	}
+	if locationsPath == "" {
+		return nil, fmt.Errorf("geolite: no locations file for locale %q: %w", locale, fs.ErrNotExist)
+	}
+	f, err := fsys.Open(locationsPath)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := ReadLocations(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("geolite: %s: %w", locationsPath, err)
+	}
+
+	m := lpm.New(opts...)
+	var loaded bool
+	for _, pattern := range []string{"*-Blocks-IPv4.csv", "*-Blocks-IPv6.csv"} {
+		blocksPath, err := find(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if blocksPath == "" {
+			continue
+		}
+		f, err := fsys.Open(blocksPath)
+		if err != nil {
+			return nil, err
+		}
+		err = ReadBlocks(f, locations, func(b Block) error {
+			v, ok := value(b)
+			if !ok {
+				return nil
+			}
+			if err := m.Insert(b.Prefix, v); err != nil {
+				return fmt.Errorf("%s: %w", b.Prefix, err)
+			}
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("geolite: %s: %w", blocksPath, err)
+		}
+		loaded = true
+	}
+	if !loaded {
+		return nil, fmt.Errorf("geolite: no blocks file: %w", fs.ErrNotExist)
+	}
+	m.Compact()
+	return m, nil
+}
+
+// find returns the file matching pattern at the root of fsys or in a directory at the
+// root, or an empty path if there is none
+func find(fsys fs.FS, pattern string) (string, error) {
+	for _, p := range []string{pattern, path.Join("*", pattern)} {
+		matches, err := fs.Glob(fsys, p)
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 0:
+		case 1:
+			return matches[0], nil
+		default:
+			return "", fmt.Errorf("geolite: several files match %s: %s", pattern, strings.Join(matches, ", "))
+		}
+	}
+	return "", nil
+}
+
+// table reads a CSV file with a header, giving access to the columns by name
+type table struct {
+	r       *csv.Reader
+	columns map[string]int
+	record  []string
+}
+
+func newTable(r io.Reader, required ...string) (*table, error) {
+	t := &table{r: csv.NewReader(bufio.NewReaderSize(r, 1<<16)), columns: make(map[string]int)}
+	t.r.ReuseRecord = true
+	header, err := t.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	for i, name := range header {
+		t.columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := t.columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+	return t, nil
+}
+
+// next reads the next row, returning io.EOF at the end
+func (t *table) next() error {
+	var err error
+	t.record, err = t.r.Read()
+	return err
+}
+
+// get returns the column of the current row, or an empty string if the file has no such
+// column
+func (t *table) get(name string) string {
+	if i, ok := t.columns[name]; ok {
+		return t.record[i]
+	}
+	return ""
+}
+
+// line returns the line of the current row
+func (t *table) line() int {
+	line, _ := t.r.FieldPos(0)
+	return line
+}
+
+// ReadLocations reads a locations file, returning the locations by geoname ID
+func ReadLocations(r io.Reader) (map[string]Location, error) {
+	t, err := newTable(r, "geoname_id", "country_iso_code")
+	if err != nil {
+		return nil, err
+	}
+	locations := make(map[string]Location)
+	for {
+		if err := t.next(); errors.Is(err, io.EOF) {
+			return locations, nil
+		} else if err != nil {
+			return nil, err
+		}
+		l := Location{
+			GeonameID:           t.get("geoname_id"),
+			ContinentCode:       t.get("continent_code"),
+			ContinentName:       t.get("continent_name"),
+			CountryISOCode:      t.get("country_iso_code"),
+			CountryName:         t.get("country_name"),
+			Subdivision1ISOCode: t.get("subdivision_1_iso_code"),
+			Subdivision1Name:    t.get("subdivision_1_name"),
+			Subdivision2ISOCode: t.get("subdivision_2_iso_code"),
+			Subdivision2Name:    t.get("subdivision_2_name"),
+			CityName:            t.get("city_name"),
+			TimeZone:            t.get("time_zone"),
+			InEuropeanUnion:     t.get("is_in_european_union") == "1",
+		}
+		locations[l.GeonameID] = l
+	}
+}
+
+// ReadBlocks reads a blocks file, calling fn with every block joined with its locations.
+// An error returned by fn stops reading and is returned.
+func ReadBlocks(r io.Reader, locations map[string]Location, fn func(Block) error) error {
+	t, err := newTable(r, "network", "geoname_id", "registered_country_geoname_id")
+	if err != nil {
+		return err
+	}
+	location := func(column string) (Location, error) {
+		id := t.get(column)
+		if id == "" {
+			return Location{}, nil
+		}
+		l, ok := locations[id]
+		if !ok {
+			return Location{}, fmt.Errorf("line %d: unknown %s %s", t.line(), column, id)
+		}
+		return l, nil
+	}
+	for {
+		if err := t.next(); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		prefix, err := netip.ParsePrefix(t.get("network"))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", t.line(), err)
+		}
+		b := Block{
+			Prefix:            prefix,
+			AnonymousProxy:    t.get("is_anonymous_proxy") == "1",
+			SatelliteProvider: t.get("is_satellite_provider") == "1",
+			Anycast:           t.get("is_anycast") == "1",
+			PostalCode:        t.get("postal_code"),
+		}
+		if b.Location, err = location("geoname_id"); err != nil {
+			return err
+		}
+		if b.RegisteredCountry, err = location("registered_country_geoname_id"); err != nil {
+			return err
+		}
+		if b.RepresentedCountry, err = location("represented_country_geoname_id"); err != nil {
+			return err
+		}
+		if b.Location.GeonameID == "" {
+			b.Location = b.RegisteredCountry
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package geolite
+
+import (
+	"archive/zip"
+	"errors"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const (
+	cityBlocksV4 = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider,postal_code,latitude,longitude,accuracy_radius,is_anycast
+1.0.0.0/24,5375480,6252001,,0,0,94043,37.4043,-122.0748,1000,0
+1.0.1.0/24,,2921044,,0,0,,,,,0
+1.0.2.0/24,,,,1,0,,,,,0
+`
+	cityBlocksV6 = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider,postal_code,latitude,longitude,accuracy_radius,is_anycast
+2001:db8::/32,2950159,2921044,,0,0,10115,52.5244,13.4105,100,1
+`
+	cityLocationsEn = `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,subdivision_1_iso_code,subdivision_1_name,subdivision_2_iso_code,subdivision_2_name,city_name,metro_code,time_zone,is_in_european_union
+5375480,en,NA,"North America",US,"United States",CA,California,,,"Mountain View",807,America/Los_Angeles,0
+6252001,en,NA,"North America",US,"United States",,,,,,,America/Chicago,0
+2921044,en,EU,Europe,DE,Germany,,,,,,,Europe/Berlin,1
+2950159,en,EU,Europe,DE,Germany,BE,"Land Berlin",,,Berlin,,Europe/Berlin,1
+`
+)
+
+// bundle returns the files of a City bundle in a directory, as published
+func bundle() fstest.MapFS {
+	dir := "GeoLite2-City-CSV_20240102/"
+	return fstest.MapFS{
+		dir + "GeoLite2-City-Blocks-IPv4.csv":    {Data: []byte(cityBlocksV4)},
+		dir + "GeoLite2-City-Blocks-IPv6.csv":    {Data: []byte(cityBlocksV6)},
+		dir + "GeoLite2-City-Locations-en.csv":   {Data: []byte(cityLocationsEn)},
+		dir + "GeoLite2-City-Locations-de.csv":   {Data: []byte(strings.ReplaceAll(cityLocationsEn, "Mountain View", "Mountain View (de)"))},
+		dir + "COPYRIGHT.txt":                    {Data: []byte("test data")},
+		"GeoLite2-City-CSV_20240102/LICENSE.txt": {Data: []byte("test data")},
+	}
+}
+
+// TestLoadFS tests joining blocks of both families with their locations
+func TestLoadFS(t *testing.T) {
+	for name, tc := range map[string]struct {
+		value  Value
+		locale string
+		want   map[string]string // address to value, "" if not found
+	}{
+		"country": {Country, "", map[string]string{
+			"1.0.0.1":     "US",
+			"1.0.1.1":     "DE", // registered country
+			"1.0.2.1":     "",   // anonymous proxy without location
+			"2001:db8::1": "DE",
+			"8.8.8.8":     "",
+		}},
+		"city": {City, "en", map[string]string{
+			"1.0.0.1":     "US,CA,Mountain View",
+			"1.0.1.1":     "DE,,",
+			"2001:db8::1": "DE,BE,Berlin",
+		}},
+		"locale": {City, "de", map[string]string{
+			"1.0.0.1": "US,CA,Mountain View (de)",
+		}},
+		"geoname id": {GeonameID, "", map[string]string{
+			"1.0.0.1":     "5375480",
+			"1.0.1.1":     "2921044",
+			"2001:db8::1": "2950159",
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := LoadFS(bundle(), tc.locale, tc.value)
+			if err != nil {
+				t.Fatalf("LoadFS failed: %v", err)
+			}
+			for addr, want := range tc.want {
+				got, found := m.Lookup(netip.MustParseAddr(addr))
+				if got != want || found != (want != "") {
+					t.Errorf("Lookup(%s) = %q, %v, want %q", addr, got, found, want)
+				}
+			}
+		})
+	}
+}
+
+// TestLoad tests loading bundles from archives and directories
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, bundle()); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "GeoLite2-City-CSV_20240102.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	if err := w.AddFS(bundle()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, path := range []string{dir, filepath.Join(dir, "GeoLite2-City-CSV_20240102"), archive} {
+		m, err := Load(path, "en", Country)
+		if err != nil {
+			t.Fatalf("Load(%s) failed: %v", path, err)
+		}
+		if got, _ := m.Lookup(netip.MustParseAddr("2001:db8::1")); got != "DE" {
+			t.Errorf("Load(%s): Lookup(2001:db8::1) = %q, want DE", path, got)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.zip"), "en", Country); err == nil {
+		t.Error("Load of a missing bundle succeeded")
+	}
+}
+
+// TestLoadErrors tests rejecting incomplete and inconsistent bundles
+func TestLoadErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		fsys   fstest.MapFS
+		locale string
+		want   error
+	}{
+		"missing locale": {bundle(), "fr", fs.ErrNotExist},
+		"missing blocks": {fstest.MapFS{"GeoLite2-City-Locations-en.csv": {Data: []byte(cityLocationsEn)}}, "en", fs.ErrNotExist},
+		"unknown geoname id": {fstest.MapFS{
+			"GeoLite2-City-Locations-en.csv": {Data: []byte(cityLocationsEn)},
+			"GeoLite2-City-Blocks-IPv4.csv":  {Data: []byte("network,geoname_id,registered_country_geoname_id\n1.0.0.0/24,1,\n")},
+		}, "en", nil},
+		"invalid network": {fstest.MapFS{
+			"GeoLite2-City-Locations-en.csv": {Data: []byte(cityLocationsEn)},
+			"GeoLite2-City-Blocks-IPv4.csv":  {Data: []byte("network,geoname_id,registered_country_geoname_id\n1.0.0.0,,\n")},
+		}, "en", nil},
+		"missing column": {fstest.MapFS{
+			"GeoLite2-City-Locations-en.csv": {Data: []byte(cityLocationsEn)},
+			"GeoLite2-City-Blocks-IPv4.csv":  {Data: []byte("network,geoname_id\n1.0.0.0/24,\n")},
+		}, "en", nil},
+		"several bundles": {fstest.MapFS{
+			"a/GeoLite2-City-Locations-en.csv": {Data: []byte(cityLocationsEn)},
+			"b/GeoLite2-City-Locations-en.csv": {Data: []byte(cityLocationsEn)},
+		}, "en", nil},
+	} {
+		_, err := LoadFS(tc.fsys, tc.locale, Country)
+		if err == nil || tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%s: LoadFS error = %v, want %v", name, err, tc.want)
+		}
+	}
+}
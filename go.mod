@@ -2,7 +2,10 @@ module github.com/sakateka/lpm
 
 go 1.25.1
 
-require github.com/stretchr/testify v1.11.1
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/stretchr/testify v1.11.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
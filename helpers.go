@@ -0,0 +1,65 @@
+package lpm
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// prefixFromIPNet converts a *net.IPNet to a masked netip.Prefix.
+// IPv4 networks stored in 16-byte form are unmapped, so they end up in the IPv4 trie.
+func prefixFromIPNet(ipnet *net.IPNet) (netip.Prefix, error) {
+	if ipnet == nil {
+		return netip.Prefix{}, fmt.Errorf("nil network")
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits == 0 {
+		return netip.Prefix{}, fmt.Errorf("non-canonical mask %s", ipnet.Mask)
+	}
+
+	addr, ok := netip.AddrFromSlice(ipnet.IP)
+	if !ok {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address %v", ipnet.IP)
+	}
+	if bits == 8*net.IPv4len {
+		addr = addr.Unmap()
+	}
+	if addr.BitLen() != bits {
+		return netip.Prefix{}, fmt.Errorf("mask length %d does not match address %s", bits, addr)
+	}
+
+	return netip.PrefixFrom(addr, ones).Masked(), nil
+}
+
+// InsertIPNet inserts a prefix given as a *net.IPNet.
+// Host bits of the address are masked, and a mask that is not a contiguous run of ones is rejected.
+func (m *LPM) InsertIPNet(ipnet *net.IPNet, value string) error {
+	prefix, err := prefixFromIPNet(ipnet)
+	if err != nil {
+		return err
+	}
+	return m.Insert(prefix, value)
+}
+
+// InsertString parses cidr (e.g. "10.0.0.0/8" or "2001:db8::/32") and inserts it.
+// Host bits of the address are masked.
+func (m *LPM) InsertString(cidr string, value string) error {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return err
+	}
+	return m.Insert(prefix.Masked(), value)
+}
+
+// LookupString parses addr and looks it up in one call.
+// A parse failure is reported through the error, while a well-formed address without
+// a matching prefix returns found=false and a nil error.
+func (m *LPM) LookupString(addr string) (value string, found bool, err error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return "", false, err
+	}
+	value, found = m.Lookup(ip)
+	return value, found, nil
+}
@@ -0,0 +1,164 @@
+package lpm
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+)
+
+// HitCounter is a read-only copy of an LPM whose lookups count how many addresses every
+// prefix matched, e.g. to find the routes that actually receive traffic. Counting happens
+// in the walk of the lookup itself: every block has a counter per slot, which the lookup
+// ending in that slot increments atomically, and TopPrefixes sums the slots of each prefix.
+// Lookups are safe for concurrent use.
+//
+// Counters take 2KB per block, as much again as the trie. The counters of a block fill
+// whole cache lines, so lookups ending in different blocks never contend for one.
+type HitCounter struct {
+	values *LPM // snapshot holding the blocks and resolving value indices
+	hits   [2][]hitBlock
+}
+
+// hitBlock holds the counters of the slots of a block
+type hitBlock [blockSize]atomic.Uint64
+
+// PrefixHits is a prefix with the number of lookups it matched, see HitCounter.TopPrefixes
+type PrefixHits struct {
+	Prefix netip.Prefix
+	Value  string
+	Hits   uint64
+}
+
+// HitCounter returns a copy of the trie counting lookups per prefix. Later modifications of
+// m are not reflected.
+func (m *LPM) HitCounter() *HitCounter {
+	snap := m.Snapshot()
+	h := &HitCounter{values: snap}
+	for _, proto := range []int{v4LPM, v6LPM} {
+		h.hits[proto] = make([]hitBlock, snap.blockCount(proto))
+	}
+	return h
+}
+
+// Lookup returns the value of the longest prefix containing addr, see LPM.Lookup, and
+// counts a hit for the prefix
+func (h *HitCounter) Lookup(addr netip.Addr) (string, bool) {
+	valueIdx, ok := h.LookupIndex(addr)
+	if !ok {
+		return "", false
+	}
+	return h.values.getValueByIndex(valueIdx)
+}
+
+// LookupIndex returns the index of the value of the longest prefix containing addr, see
+// LPM.LookupIndex, and counts a hit for the prefix
+func (h *HitCounter) LookupIndex(addr netip.Addr) (int, bool) {
+	if !addr.IsValid() {
+		return 0, false
+	}
+	if addr.Is4() {
+		key := addr.As4()
+		return h.lookupKey(v4LPM, key[:])
+	}
+	key := addr.As16()
+	return h.lookupKey(v6LPM, key[:])
+}
+
+// ValueByIndex returns the value for an index obtained from LookupIndex
+func (h *HitCounter) ValueByIndex(valueIdx int) (string, bool) {
+	return h.values.ValueByIndex(valueIdx)
+}
+
+// lookupKey walks the blocks like trie.lookupKey, bypassing the stride16 table whose
+// entries have no counters, and counts a hit in the slot the walk ends in
+func (h *HitCounter) lookupKey(proto int, key []byte) (int, bool) {
+	t := &h.values.trie
+	if proto == v6LPM && t.ipv6Max64 {
+		key = key[:max64Bytes]
+	}
+	blockIdx := t.root[proto]
+	for _, inBlockIdx := range key {
+		value := t.getValue(proto, blockIdx, inBlockIdx)
+		if isBlockRef(value) {
+			blockIdx = decodeBlockRef(value)
+			continue
+		}
+		if isInvalid(value) {
+			return 0, false
+		}
+		valueIdx, _ := decodeValue(value)
+		if valueIdx == tombstoneIdx {
+			return 0, false
+		}
+		h.hits[proto][blockIdx][inBlockIdx].Add(1)
+		return valueIdx, true
+	}
+	return 0, false
+}
+
+// TopPrefixes returns at most n prefixes with the most hits, in decreasing order of hits and
+// then like Entries. Prefixes without hits are left out, so n <= 0 returns all prefixes that
+// were hit. Prefixes are those Entries reports for the trie the HitCounter was built from.
+func (h *HitCounter) TopPrefixes(n int) []PrefixHits {
+	type prefixHits struct {
+		valueIdx int
+		hits     uint64
+	}
+	byPrefix := make(map[netip.Prefix]prefixHits)
+	t := &h.values.trie
+	for _, proto := range []int{v4LPM, v6LPM} {
+		if t.blockCount(proto) == 0 {
+			continue
+		}
+		var path [16]byte
+		var walk func(blockIdx int, depth int)
+		walk = func(blockIdx int, depth int) {
+			for slot, encoded := range t.getBlockRef(proto, blockIdx) {
+				path[depth] = byte(slot)
+				if isBlockRef(encoded) {
+					walk(decodeBlockRef(encoded), depth+1)
+					continue
+				}
+				hits := h.hits[proto][blockIdx][slot].Load()
+				if hits == 0 || isInvalid(encoded) {
+					continue
+				}
+				valueIdx, prefixLen := decodeValue(encoded)
+				prefix := prefixFromPath(proto, path, prefixLen)
+				sum := byPrefix[prefix]
+				byPrefix[prefix] = prefixHits{valueIdx: valueIdx, hits: sum.hits + hits}
+			}
+			path[depth] = 0
+		}
+		walk(t.root[proto], 0)
+	}
+
+	result := make([]PrefixHits, 0, len(byPrefix))
+	for prefix, sum := range byPrefix {
+		value, _ := h.values.getValueByIndex(sum.valueIdx)
+		result = append(result, PrefixHits{Prefix: prefix, Value: value, Hits: sum.hits})
+	}
+	slices.SortFunc(result, func(a, b PrefixHits) int {
+		if c := cmp.Compare(b.Hits, a.Hits); c != 0 {
+			return c
+		}
+		return comparePrefix(a.Prefix, b.Prefix)
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Reset sets every counter to zero, e.g. after reporting the hits of an interval. Lookups
+// running concurrently may be counted in either interval.
+func (h *HitCounter) Reset() {
+	for proto := range h.hits {
+		for i := range h.hits[proto] {
+			for slot := range h.hits[proto][i] {
+				h.hits[proto][i][slot].Store(0)
+			}
+		}
+	}
+}
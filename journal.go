@@ -0,0 +1,359 @@
+package lpm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/netip"
+	"os"
+)
+
+// Journal record layout:
+//
+//	length       uint32 little-endian, size of the payload
+//	checksum     uint32 little-endian, CRC-32C of the payload
+//	payload:
+//	  op         byte, one of the journalOp constants
+//	  generation uvarint, generation of the trie after the operation
+//	  prefix     netip.Prefix binary encoding, preceded by its uvarint length
+//	  priority   byte
+//	  value      uvarint length followed by the value bytes
+//
+// Metadata records hold the key instead of the prefix and priority:
+//
+//	payload:
+//	  op         byte, journalSetMetadata or journalDeleteMetadata
+//	  generation uvarint
+//	  key        uvarint length followed by the key bytes
+//	  value      uvarint length followed by the value bytes, empty for deletes
+//
+// The byte order is fixed, so a log replays on hosts of either byte order. A crash may
+// leave a partially written record at the end of the journal; replay stops before it and
+// OpenJournal truncates it away.
+
+type journalOp byte
+
+const (
+	journalInsert journalOp = iota + 1
+	journalTombstone
+	journalDelete
+	journalSetMetadata
+	journalDeleteMetadata
+)
+
+const (
+	// journalRecordHeader is the size of the length and checksum preceding every payload
+	journalRecordHeader = 8
+	// maxJournalRecordSize bounds the payloads read: the operation, priority and
+	// uvarints plus the largest prefix and value, or metadata entry. Larger lengths are
+	// corrupt, and are rejected before the payload is allocated.
+	maxJournalRecordSize = 2 + 3*binary.MaxVarintLen64 + 18 + maxValueLen
+)
+
+// Journal persists a trie as a snapshot file plus an append-only log of the modifications
+// made since, so a long-running service recovers the current table after a crash without
+// refetching it. Modify the trie and its metadata only through the Journal; its LPM may be
+// used for lookups.
+// Call Checkpoint periodically to fold the log into a new snapshot.
+type Journal struct {
+	path string // snapshot, the log is at path.journal
+	opts []Option
+	lpm  *LPM
+	log  *os.File
+	size int64
+}
+
+// journalPath returns the log of the snapshot at path
+func journalPath(path string) string {
+	return path + ".journal"
+}
+
+// OpenJournal recovers the trie from the snapshot at path, if any, and the log next to
+// it, then opens the log for appending. opts are passed to SaveToFile and LoadFromFile,
+// and to New if there is no snapshot yet.
+func OpenJournal(path string, opts ...Option) (*Journal, error) {
+	lpm, err := LoadFromFile(path, opts...)
+	if errors.Is(err, os.ErrNotExist) {
+		lpm, err = New(opts...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(journalPath(path), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	_, size, err := replayJournal(lpm, log)
+	if err == nil {
+		// Drop a partially written record so new records follow the last complete one
+		err = log.Truncate(size)
+	}
+	if err == nil {
+		_, err = log.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		_ = log.Close()
+		return nil, fmt.Errorf("%s: %w", log.Name(), err)
+	}
+	return &Journal{path: path, opts: opts, lpm: lpm, log: log, size: size}, nil
+}
+
+// LPM returns the recovered trie. It must only be modified through the Journal.
+func (j *Journal) LPM() *LPM {
+	return j.lpm
+}
+
+// Insert stores value for the prefix and logs it, see LPM.Insert
+func (j *Journal) Insert(net netip.Prefix, value string) error {
+	return j.InsertWithPriority(net, value, 0)
+}
+
+// InsertWithPriority stores value for the prefix with a priority and logs it, see LPM.InsertWithPriority
+func (j *Journal) InsertWithPriority(net netip.Prefix, value string, priority uint8) error {
+	if err := j.lpm.InsertWithPriority(net, value, priority); err != nil {
+		return err
+	}
+	return j.append(journalInsert, net, priority, value)
+}
+
+// InsertTombstone carves the prefix out of broader prefixes and logs it, see LPM.InsertTombstone
+func (j *Journal) InsertTombstone(net netip.Prefix) error {
+	if err := j.lpm.InsertTombstone(net); err != nil {
+		return err
+	}
+	return j.append(journalTombstone, net, 0, "")
+}
+
+// Delete removes the prefix and logs it, see LPM.Delete. Deleting an absent prefix is not logged.
+func (j *Journal) Delete(net netip.Prefix) (bool, error) {
+	deleted, err := j.lpm.Delete(net)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return true, j.append(journalDelete, net, 0, "")
+}
+
+// SetMetadata sets a metadata entry and logs it, see LPM.SetMetadata. Entries are logged
+// like values, so the key and value together must not exceed 65535 bytes.
+func (j *Journal) SetMetadata(key, value string) error {
+	if len(key)+len(value) > maxValueLen {
+		return fmt.Errorf("metadata entry %q exceeds %d bytes", key, maxValueLen)
+	}
+	j.lpm.SetMetadata(key, value)
+	return j.appendMetadata(journalSetMetadata, key, value)
+}
+
+// DeleteMetadata removes a metadata entry and logs it, see LPM.DeleteMetadata. Deleting an
+// absent entry is not logged.
+func (j *Journal) DeleteMetadata(key string) error {
+	if _, ok := j.lpm.metadata[key]; !ok {
+		return nil
+	}
+	j.lpm.DeleteMetadata(key)
+	return j.appendMetadata(journalDeleteMetadata, key, "")
+}
+
+func (j *Journal) append(op journalOp, net netip.Prefix, priority uint8, value string) error {
+	prefix, err := net.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	record := j.newRecord(op, len(prefix)+len(value))
+	record = binary.AppendUvarint(record, uint64(len(prefix)))
+	record = append(record, prefix...)
+	record = append(record, priority)
+	record = binary.AppendUvarint(record, uint64(len(value)))
+	record = append(record, value...)
+	return j.write(record)
+}
+
+func (j *Journal) appendMetadata(op journalOp, key, value string) error {
+	record := j.newRecord(op, len(key)+len(value))
+	record = binary.AppendUvarint(record, uint64(len(key)))
+	record = append(record, key...)
+	record = binary.AppendUvarint(record, uint64(len(value)))
+	record = append(record, value...)
+	return j.write(record)
+}
+
+// newRecord starts a record of the operation with room for size more payload bytes
+func (j *Journal) newRecord(op journalOp, size int) []byte {
+	record := make([]byte, journalRecordHeader, journalRecordHeader+size+32)
+	record = append(record, byte(op))
+	return binary.AppendUvarint(record, j.lpm.Generation())
+}
+
+// write fills in the length and checksum of the record and appends it to the log
+func (j *Journal) write(record []byte) error {
+	payload := record[journalRecordHeader:]
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.Checksum(payload, castagnoli))
+
+	// A single write, so a crash leaves at most one partial record behind
+	n, err := j.log.Write(record)
+	j.size += int64(n)
+	return err
+}
+
+// Sync flushes the log to disk. Records are written to the file as they are made, so
+// they survive a crash of the process; Sync makes them survive a crash of the host.
+func (j *Journal) Sync() error {
+	return j.log.Sync()
+}
+
+// Size returns the size of the log in bytes, e.g. to decide when to Checkpoint
+func (j *Journal) Size() int64 {
+	return j.size
+}
+
+// Checkpoint saves the trie as the new snapshot and empties the log. A crash in between
+// leaves records that are already part of the snapshot, which replay skips by generation.
+func (j *Journal) Checkpoint() error {
+	if err := j.lpm.SaveToFile(j.path, j.opts...); err != nil {
+		return err
+	}
+	if err := j.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	j.size = 0
+	return j.log.Sync()
+}
+
+// Close closes the log. It does not checkpoint.
+func (j *Journal) Close() error {
+	return j.log.Close()
+}
+
+// ReplayJournal applies the records of a journal log to m and returns how many were
+// applied. Records whose generation m already reached, e.g. because they were made
+// before the snapshot m was loaded from, are skipped. Replay stops at a partially
+// written or corrupted record, as left behind by a crash.
+func ReplayJournal(m *LPM, r io.Reader) (int, error) {
+	applied, _, err := replayJournal(m, r)
+	return applied, err
+}
+
+// replayJournal replays r and returns the offset just past the last complete record
+func replayJournal(m *LPM, r io.Reader) (applied int, size int64, err error) {
+	br := bufio.NewReader(r)
+	var recordHeader [journalRecordHeader]byte
+	for {
+		if _, err := io.ReadFull(br, recordHeader[:]); err != nil {
+			return applied, size, ignoreTornRecord(err)
+		}
+		length := binary.LittleEndian.Uint32(recordHeader[:])
+		if length > maxJournalRecordSize {
+			return applied, size, nil
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return applied, size, ignoreTornRecord(err)
+		}
+		if crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(recordHeader[4:]) {
+			return applied, size, nil
+		}
+
+		ok, err := applyJournalRecord(m, payload)
+		if err != nil {
+			return applied, size, err
+		}
+		if ok {
+			applied++
+		}
+		size += int64(journalRecordHeader + len(payload))
+	}
+}
+
+// ignoreTornRecord treats a record cut short by the end of the log as its end
+func ignoreTornRecord(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// applyJournalRecord applies a record with a valid checksum, reporting whether it was
+// new to m
+func applyJournalRecord(m *LPM, payload []byte) (bool, error) {
+	malformed := fmt.Errorf("malformed journal record")
+	if len(payload) < 1 {
+		return false, malformed
+	}
+	op := journalOp(payload[0])
+	payload = payload[1:]
+
+	generation, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return false, malformed
+	}
+	payload = payload[n:]
+	if op == journalSetMetadata || op == journalDeleteMetadata {
+		return applyJournalMetadata(m, op, generation, payload)
+	}
+	prefixLen, n := binary.Uvarint(payload)
+	if n <= 0 || prefixLen > uint64(len(payload)-n) {
+		return false, malformed
+	}
+	var net netip.Prefix
+	if err := net.UnmarshalBinary(payload[n : n+int(prefixLen)]); err != nil {
+		return false, fmt.Errorf("malformed journal record: %w", err)
+	}
+	payload = payload[n+int(prefixLen):]
+	if len(payload) < 1 {
+		return false, malformed
+	}
+	priority := payload[0]
+	valueLen, n := binary.Uvarint(payload[1:])
+	if n <= 0 || valueLen != uint64(len(payload)-1-n) {
+		return false, malformed
+	}
+	value := string(payload[1+n:])
+
+	if generation <= m.Generation() {
+		return false, nil
+	}
+	switch op {
+	case journalInsert:
+		return true, m.InsertWithPriority(net, value, priority)
+	case journalTombstone:
+		return true, m.InsertTombstone(net)
+	case journalDelete:
+		_, err := m.Delete(net)
+		return true, err
+	}
+	return false, fmt.Errorf("malformed journal record: unknown operation %d", op)
+}
+
+// applyJournalMetadata applies the key and value of a metadata record, reporting whether
+// it was new to m
+func applyJournalMetadata(m *LPM, op journalOp, generation uint64, payload []byte) (bool, error) {
+	var fields [2]string
+	for i := range fields {
+		size, n := binary.Uvarint(payload)
+		if n <= 0 || size > uint64(len(payload)-n) {
+			return false, fmt.Errorf("malformed journal record")
+		}
+		fields[i] = string(payload[n : n+int(size)])
+		payload = payload[n+int(size):]
+	}
+	if len(payload) != 0 {
+		return false, fmt.Errorf("malformed journal record")
+	}
+
+	if generation <= m.Generation() {
+		return false, nil
+	}
+	if op == journalSetMetadata {
+		m.SetMetadata(fields[0], fields[1])
+	} else {
+		m.DeleteMetadata(fields[0])
+	}
+	return true, nil
+}
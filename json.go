@@ -0,0 +1,51 @@
+package lpm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Ensure LPM implements json.Marshaler and json.Unmarshaler
+var (
+	_ json.Marshaler   = (*LPM)(nil)
+	_ json.Unmarshaler = (*LPM)(nil)
+)
+
+// MarshalJSON implements json.Marshaler.
+// The trie is encoded as a sorted list of {"cidr": ..., "value": ...} objects, see Entries.
+// Tombstones are encoded as {"cidr": ..., "value": "", "tombstone": true}.
+func (m *LPM) MarshalJSON() ([]byte, error) {
+	entries := m.Entries()
+	if entries == nil {
+		entries = []PrefixValue{}
+	}
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// It replaces the contents of the trie with the entries from a list produced by MarshalJSON.
+func (m *LPM) UnmarshalJSON(data []byte) error {
+	var entries []PrefixValue
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	lpm := New()
+	for i, entry := range entries {
+		if !entry.Prefix.IsValid() {
+			return fmt.Errorf("entry %d: invalid or missing cidr", i)
+		}
+		var err error
+		if entry.Tombstone {
+			err = lpm.InsertTombstone(entry.Prefix.Masked())
+		} else {
+			err = lpm.InsertWithPriority(entry.Prefix.Masked(), entry.Value, entry.Priority)
+		}
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+
+	*m = *lpm
+	return nil
+}
@@ -0,0 +1,110 @@
+package lpm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"time"
+)
+
+// capacityWarnPercent is the share of a limit past which inserts log a warning, once per limit
+const capacityWarnPercent = 90
+
+// Limits an insert warns about when approaching them, see warnCapacity
+const (
+	warnedMemory uint8 = 1 << iota
+	warnedIPv4Blocks
+	warnedIPv6Blocks
+	warnedValues
+)
+
+// WithLogger makes the LPM log structured events to logger: loading storage at Debug level
+// and failures to read or validate it at Warn level, bulk loads with Build and SaveToFile at
+// Info level, packing at Debug level, and inserts rejected for exceeding the capacity or
+// memory limit, or bringing the trie within 10% of one, at Warn level. Lookups never log.
+// It applies to New, NewWithSharedStorage and the loaders built on it, and Build; tables
+// loaded or built with it keep logging packing, saving and inserts to it. Without it,
+// nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// discardLogger is used when no logger is configured
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// log returns the logger given with WithLogger, or one that discards everything
+func (o options) log() *slog.Logger {
+	if o.logger == nil {
+		return discardLogger
+	}
+	return o.logger
+}
+
+// log returns the logger given with WithLogger, or one that discards everything
+func (m *LPM) log() *slog.Logger {
+	if m.logger == nil {
+		return discardLogger
+	}
+	return m.logger
+}
+
+// logLoad logs the outcome of loading storage
+func logLoad(logger *slog.Logger, m *LPM, err error) {
+	if err != nil {
+		logger.Warn("loading storage failed", "error", err)
+		return
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	stats := m.Stats()
+	logger.Debug("loaded storage",
+		"bytes", stats.TotalSize,
+		"generation", m.generation,
+		"ipv4_blocks", stats.IPv4Blocks,
+		"ipv6_blocks", stats.IPv6Blocks,
+		"values", stats.Values)
+}
+
+// logPack logs the outcome of packing size bytes of storage started at start
+func (m *LPM) logPack(size int64, start time.Time, err error) {
+	if err != nil {
+		m.log().Warn("packing storage failed", "error", err)
+		return
+	}
+	m.log().Debug("packed storage", "bytes", size, "generation", m.generation, "duration", time.Since(start))
+}
+
+// rejected logs an insert of the prefix that failed for exceeding a limit and returns err
+func (m *LPM) rejected(net netip.Prefix, err error) error {
+	if errors.Is(err, ErrCapacity) || errors.Is(err, ErrMemoryLimit) {
+		m.log().Warn("insert rejected", "prefix", net, "error", err)
+	}
+	return err
+}
+
+// warnCapacity logs the first insert that brings the trie within 10% of its capacity or
+// memory limit
+func (m *LPM) warnCapacity() {
+	if m.logger == nil {
+		return
+	}
+	m.warnLimit(warnedIPv4Blocks, "IPv4 blocks", m.blockCount(v4LPM), maxBlockCount)
+	m.warnLimit(warnedIPv6Blocks, "IPv6 blocks", m.blockCount(v6LPM), maxBlockCount)
+	m.warnLimit(warnedValues, "values", m.valueCount(), maxValueCount)
+	if m.maxMemory > 0 {
+		m.warnLimit(warnedMemory, "memory", m.Stats().TotalSize, m.maxMemory)
+	}
+}
+
+func (m *LPM) warnLimit(flag uint8, limit string, used, capacity int) {
+	// In 64 bits, as the block capacity times 100 overflows int on 32-bit platforms
+	if m.warned&flag != 0 || int64(used)*100 < int64(capacity)*capacityWarnPercent {
+		return
+	}
+	m.warned |= flag
+	m.logger.Warn("approaching capacity", "limit", limit, "used", used, "max", capacity)
+}
@@ -49,7 +49,11 @@
 //	value, found := lpm.Lookup(netip.MustParseAddr("192.168.1.1"))
 //
 // Shared storage provides zero-copy access to the trie data, making it ideal for
-// read-heavy workloads across multiple processes.
+// read-heavy workloads across multiple processes. Values returned by Lookup point
+// directly into the storage, so it must not be modified or unmapped while they are in use.
+// The storage is never written: inserts copy the blocks they modify into process memory,
+// so a process can layer its own prefixes over a table shared with others. Load with the
+// ReadOnly option to have inserts fail with ErrReadOnly instead.
 //
 // # Performance Characteristics
 //
@@ -69,15 +73,20 @@
 // # Thread Safety
 //
 // The LPM trie is NOT thread-safe. External synchronization is required for
-// concurrent access. For read-only workloads after initial construction,
-// consider using shared memory with separate LPM instances per process.
+// concurrent access. Lookups alone may run concurrently, so a trie that is no longer
+// modified can be shared by any number of readers. To update such a trie without
+// locking readers out, build a new one and swap it in through Atomic.
+// For read-only workloads after initial construction, consider using shared memory
+// with separate LPM instances per process.
 package lpm
 
 import (
 	"encoding"
+	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"net/netip"
-	"unsafe"
+	"time"
 )
 
 // Ensure LPM implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
@@ -94,6 +103,8 @@ var (
 // - Value reference: prefix length + 1 in top byte (1-129 for IPv4/IPv6)
 //   * Bits 24-31: prefix length + 1 (1-129, since max prefix is 128)
 //   * Bits 0-23: value index (supports up to ~16 million values)
+//   * Value index 0xFFFFFF is reserved for tombstones: the prefix matches,
+//     but lookups report no value (see InsertTombstone)
 
 const (
 	v4LPM = 0
@@ -103,101 +114,222 @@ const (
 	blockIndexMask = 0x3FFFFFFF // Bottom 30 bits for block index
 	prefixLenShift = 24
 	valueIndexMask = 0x00FFFFFF // Bottom 24 bits for value index
+	tombstoneIdx   = valueIndexMask
 
 	blockSize = 256
 
 	magicNumber    = 0x4C504D00 // "LPM\0"
-	currentVersion = 1
+	currentVersion = 6
+
+	// Supported storage format versions, see format.go
+	versionV1   = 1
+	versionV2   = 2
+	versionV3   = 3
+	versionV4   = 4
+	versionV5   = 5
+	maxValueLen = 0xFFFF // Largest value that can be packed
 )
 
 // StorageHeader describes the layout of preallocated storage
 type StorageHeader struct {
 	Magic          uint32 // Magic number: 0x4C504D00 ("LPM\0")
 	Version        uint32 // Format version
-	V4BlockCount   uint32 // Number of IPv4 blocks
-	V6BlockCount   uint32 // Number of IPv6 blocks
-	ValueCount     uint32 // Number of preallocated values
-	ValueSlotSize  uint32 // Size of each value slot in bytes
-	V4BlocksOffset uint32 // Offset to IPv4 blocks data
-	V6BlocksOffset uint32 // Offset to IPv6 blocks data
-	ValuesOffset   uint32 // Offset to values data
+	ByteOrder      uint32 // 0x01020304 in the byte order of the packing host
+	Checksum       uint32 // CRC-32C of the storage with this field zeroed
+	V4BlockCount   uint64 // Number of IPv4 blocks
+	V6BlockCount   uint64 // Number of IPv6 blocks
+	ValueCount     uint64 // Number of preallocated values
+	ValueSlotSize  uint64 // Size of each value slot in bytes
+	V4BlocksOffset uint64 // Offset to IPv4 blocks data
+	V6BlocksOffset uint64 // Offset to IPv6 blocks data
+	ValuesOffset   uint64 // Offset to values data
+	MetadataOffset uint64 // Offset to the metadata section
+	MetadataSize   uint64 // Size of the metadata section in bytes
+	Generation     uint64 // Generation of the trie when it was packed, see LPM.Generation
+	Sequence       uint64 // Odd while a SeqWriter updates the storage in place
 }
 
 type LPMBlock [blockSize]uint32
 
+// trie holds the IPv4 and IPv6 block arrays. Slots store value indices, so the
+// same trie backs LPM (string values) and Numeric (integer values).
+//
+// Shared blocks are never written: inserts copy a shared block into dynamic memory
+// before modifying it and repoint its parent, so the root may move away from index 0
+// and the replaced shared blocks become unreachable. Dynamic blocks visible to a
+// Snapshot are frozen and copied the same way.
+type trie struct {
+	shared     [2][]LPMBlock
+	dynamic    [2][]*LPMBlock
+	root       [2]int // index of the root block
+	copied     [2]int // number of shared or frozen blocks replaced by dynamic copies
+	frozen     [2]int // dynamic blocks below this index are shared with snapshots
+	generation uint64 // bumped on every modification
+	ipv6Max64  bool   // IPv6 prefixes are at most /64, see IPv6Max64
+
+	free [2][]int      // indices of dead dynamic blocks to reuse, see freelist.go
+	slab [2][]LPMBlock // unused blocks of the current slab, see allocBlock
+
+	stride16       []uint32 // first level of the IPv4 trie, see IPv4Stride16
+	stride16Shared bool     // stride16 is shared with a snapshot and copied before updates
+
+	prefixes prefixSet // every prefix inserted and not deleted, see prefixset.go
+}
+
+func newTrie() trie {
+	return trie{dynamic: [2][]*LPMBlock{{{}}, {{}}}, prefixes: newPrefixSet()}
+}
+
 type LPM struct {
-	shared               [2][]LPMBlock
+	trie
+
 	sharedValues         []byte
 	sharedValuesSlotSize int
 	sharedValueCount     int
+	sharedValueLenSize   int  // Size of the length prefix of each value slot
+	sharedPriorities     bool // Whether each value slot holds a priority byte after the length
+
+	values        map[valueKey]int           // value -> index
+	revValues     []string                   // index -> value
+	valueBytes    int                        // total length of revValues, for Stats
+	revPriorities []uint8                    // index -> priority
+	sharedIndexed bool                       // shared values have been added to values
+	metadata      map[string]string          // packed along with the trie, see SetMetadata
+	readOnly      bool                       // inserts fail with ErrReadOnly, see ReadOnly
+	maxMemory     int                        // inserts fail with ErrMemoryLimit, see WithMaxMemory
+	logger        *slog.Logger               // nil if not logging, see WithLogger
+	warned        uint8                      // limits approached so far, see warnCapacity
+	watchers      []chan Event               // see Watch
+	expiries      map[netip.Prefix]time.Time // masked prefix -> expiry, see InsertWithTTL
+}
 
-	dynamic   [2][]*LPMBlock
-	values    map[string]int // value -> index
-	revValues []string       // index -> value
+// valueKey identifies an entry of the value table: equal values with different priorities are
+// separate entries, as the priority is resolved through the value index while inserting.
+type valueKey struct {
+	value    string
+	priority uint8
 }
 
-func New() *LPM {
-	return &LPM{
-		dynamic: [2][]*LPMBlock{{{}}, {{}}},
-		values:  make(map[string]int),
+// New creates an empty LPM. The options that apply to it are IPv6Max64, IPv4Stride16, WithMaxMemory
+// and WithLogger.
+func New(opts ...Option) *LPM {
+	o := newOptions(opts)
+	lpm := &LPM{
+		trie:      newTrie(),
+		values:    make(map[valueKey]int),
+		maxMemory: o.maxMemory,
+		logger:    o.logger,
 	}
+	lpm.ipv6Max64 = o.ipv6Max64
+	if o.stride16 {
+		lpm.enableStride16()
+	}
+	return lpm
 }
 
 // NewWithSharedStorage creates a new LPM instance with shared storage from a byte slice.
 // The storage must start with a StorageHeader followed by the data sections.
-func NewWithSharedStorage(storage []byte) (*LPM, error) {
-	if len(storage) < int(unsafe.Sizeof(StorageHeader{})) {
-		return nil, fmt.Errorf("storage too small: need at least %d bytes for header, got %d",
-			unsafe.Sizeof(StorageHeader{}), len(storage))
-	}
-
-	// Cast byte slice to header struct
-	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
+// Storage packed on a host of the other byte order is converted into a private copy,
+// so it is loaded correctly but not shared; so is storage written by PackCompressed, and
+// storage that is not 4-byte aligned, e.g. a slice at an odd offset of a larger buffer,
+// as blocks are accessed in place. Use AlignedBuffer to read storage into memory.
+// The storage checksum is verified unless SkipChecksum is given.
+func NewWithSharedStorage(storage []byte, opts ...Option) (*LPM, error) {
+	o := newOptions(opts)
+	lpm, err := newWithSharedStorage(storage, o)
+	logLoad(o.log(), lpm, err)
+	return lpm, err
+}
 
-	// Validate header
-	if header.Magic != magicNumber {
-		return nil, fmt.Errorf("invalid magic number: expected 0x%08X, got 0x%08X", magicNumber, header.Magic)
+func newWithSharedStorage(storage []byte, o options) (*LPM, error) {
+	if isCompressed(storage) {
+		decompressed, err := decompressStorage(storage)
+		if err != nil {
+			return nil, err
+		}
+		return newWithSharedStorage(decompressed, o)
 	}
 
-	if header.Version != currentVersion {
-		return nil, fmt.Errorf("unsupported version: expected %d, got %d", currentVersion, header.Version)
+	header, foreign, err := readHeader(storage)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate offsets and sizes
 	blockByteSize := blockSize * 4 // 256 uint32s = 1024 bytes per block
 
 	if header.V4BlockCount > 0 {
-		requiredSize := int(header.V4BlocksOffset) + (int(header.V4BlockCount) * blockByteSize)
+		requiredSize := sectionEnd(header.V4BlocksOffset, header.V4BlockCount, uint64(blockByteSize))
 		if len(storage) < requiredSize {
-			return nil, fmt.Errorf("storage too small for IPv4 blocks: need %d bytes, got %d", requiredSize, len(storage))
+			return nil, &ErrTruncated{Section: "IPv4 blocks", Need: requiredSize, Got: len(storage)}
 		}
 	}
 
 	if header.V6BlockCount > 0 {
-		requiredSize := int(header.V6BlocksOffset) + (int(header.V6BlockCount) * blockByteSize)
+		requiredSize := sectionEnd(header.V6BlocksOffset, header.V6BlockCount, uint64(blockByteSize))
 		if len(storage) < requiredSize {
-			return nil, fmt.Errorf("storage too small for IPv6 blocks: need %d bytes, got %d", requiredSize, len(storage))
+			return nil, &ErrTruncated{Section: "IPv6 blocks", Need: requiredSize, Got: len(storage)}
 		}
 	}
 
 	if header.ValueCount > 0 && header.ValueSlotSize > 0 {
-		requiredSize := int(header.ValuesOffset) + (int(header.ValueCount) * int(header.ValueSlotSize))
+		requiredSize := sectionEnd(header.ValuesOffset, header.ValueCount, header.ValueSlotSize)
+		if len(storage) < requiredSize {
+			return nil, &ErrTruncated{Section: "values", Need: requiredSize, Got: len(storage)}
+		}
+	}
+
+	if header.MetadataSize > 0 {
+		requiredSize := sectionEnd(header.MetadataOffset, 1, header.MetadataSize)
 		if len(storage) < requiredSize {
-			return nil, fmt.Errorf("storage too small for values: need %d bytes, got %d", requiredSize, len(storage))
+			return nil, &ErrTruncated{Section: "metadata", Need: requiredSize, Got: len(storage)}
 		}
 	}
 
+	// Counts within the storage size may still exceed what slots encode
+	if header.V4BlockCount > maxBlockCount || header.V6BlockCount > maxBlockCount ||
+		header.ValueCount > maxValueCount || header.ValueCount > 0 && header.ValueSlotSize == 0 {
+		return nil, fmt.Errorf("%w: header declares %d IPv4 blocks, %d IPv6 blocks, %d values in slots of %d bytes",
+			ErrCorrupt, header.V4BlockCount, header.V6BlockCount, header.ValueCount, header.ValueSlotSize)
+	}
+	if header.V4BlocksOffset%4 != 0 || header.V6BlocksOffset%4 != 0 {
+		return nil, fmt.Errorf("%w: misaligned block offsets %d and %d", ErrCorrupt, header.V4BlocksOffset, header.V6BlocksOffset)
+	}
+
+	if header.Version != versionV1 && !isLegacyV2(&header) && !o.skipChecksum {
+		if sum := storageChecksum(storage, storageSize(&header), header.Version); sum != header.Checksum {
+			return nil, fmt.Errorf("%w: header has 0x%08X, storage hashes to 0x%08X", ErrChecksumMismatch, header.Checksum, sum)
+		}
+	}
+
+	if foreign {
+		storage = swapStorage(storage, &header)
+	} else if !blocksAligned(storage) {
+		storage = alignedClone(storage)
+	}
+
+	metadata, err := readMetadata(storage, &header)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create LPM instance
 	lpm := &LPM{
 		sharedValuesSlotSize: int(header.ValueSlotSize),
 		sharedValueCount:     int(header.ValueCount),
-		values:               make(map[string]int),
+		sharedValueLenSize:   valueLenSize(header.Version),
+		values:               make(map[valueKey]int),
+		metadata:             metadata,
+		readOnly:             o.readOnly,
+		maxMemory:            o.maxMemory,
+		logger:               o.logger,
 	}
+	lpm.generation = header.Generation
 
 	// Map IPv4 blocks using unsafe pointer casting
 	if header.V4BlockCount > 0 {
 		v4Data := storage[header.V4BlocksOffset:]
-		lpm.shared[v4LPM] = unsafe.Slice((*LPMBlock)(unsafe.Pointer(&v4Data[0])), header.V4BlockCount)
+		lpm.shared[v4LPM] = mapBlocks(v4Data, header.V4BlockCount)
 		lpm.dynamic[v4LPM] = []*LPMBlock{}
 	} else {
 		lpm.dynamic[v4LPM] = []*LPMBlock{{}}
@@ -206,7 +338,7 @@ func NewWithSharedStorage(storage []byte) (*LPM, error) {
 	// Map IPv6 blocks using unsafe pointer casting
 	if header.V6BlockCount > 0 {
 		v6Data := storage[header.V6BlocksOffset:]
-		lpm.shared[v6LPM] = unsafe.Slice((*LPMBlock)(unsafe.Pointer(&v6Data[0])), header.V6BlockCount)
+		lpm.shared[v6LPM] = mapBlocks(v6Data, header.V6BlockCount)
 		lpm.dynamic[v6LPM] = []*LPMBlock{}
 	} else {
 		lpm.dynamic[v6LPM] = []*LPMBlock{{}}
@@ -214,126 +346,66 @@ func NewWithSharedStorage(storage []byte) (*LPM, error) {
 
 	// Map values
 	if header.ValueCount > 0 && header.ValueSlotSize > 0 {
-		valuesEnd := int(header.ValuesOffset) + (int(header.ValueCount) * int(header.ValueSlotSize))
+		valuesEnd := sectionEnd(header.ValuesOffset, header.ValueCount, header.ValueSlotSize)
 		lpm.sharedValues = storage[header.ValuesOffset:valuesEnd]
 	}
+	lpm.sharedPriorities = header.Version != versionV1 &&
+		(!isLegacyV2(&header) || hasSlotPriorities(lpm.sharedValues, lpm.sharedValuesSlotSize))
 
+	if o.untrusted {
+		if err := lpm.Verify(); err != nil {
+			return nil, err
+		}
+	}
+	if o.ipv6Max64 {
+		if depth := lpm.depth(v6LPM); depth > max64Bytes {
+			return nil, fmt.Errorf("%w: storage holds IPv6 prefixes longer than /64 (%d block levels)", ErrInvalidPrefix, depth)
+		}
+		lpm.ipv6Max64 = true
+	}
+	if o.stride16 {
+		lpm.enableStride16()
+	}
 	return lpm, nil
 }
 
 // PackToSharedStorage serializes the LPM trie into a byte slice suitable for shared memory.
 // The returned byte slice contains a StorageHeader followed by the block and value data.
-// It automatically determines the maximum value length and returns an error if any value exceeds 255 bytes.
-func (m *LPM) PackToSharedStorage() ([]byte, error) {
-	// Find maximum value length and validate
-	maxValueLen := 0
-
-	// Check shared values
-	if m.sharedValueCount > 0 && len(m.sharedValues) > 0 {
-		for i := 0; i < m.sharedValueCount; i++ {
-			offset := i * m.sharedValuesSlotSize
-			strLen := int(m.sharedValues[offset])
-			if strLen > 255 {
-				return nil, fmt.Errorf("shared value at index %d exceeds 255 bytes: %d", i, strLen)
-			}
-			if strLen > maxValueLen {
-				maxValueLen = strLen
-			}
-		}
-	}
+// It automatically determines the maximum value length and returns an error if any value exceeds 65535 bytes.
+// Values no longer referenced by any slot (e.g. after overwrites) are dropped and the remaining
+// value indices are renumbered in order.
+func (m *LPM) PackToSharedStorage() (storage []byte, err error) {
+	defer func(start time.Time) { m.logPack(int64(len(storage)), start, err) }(time.Now())
 
-	// Check dynamic values
-	for i, val := range m.revValues {
-		if len(val) > 255 {
-			return nil, fmt.Errorf("value at index %d exceeds 255 bytes: %d", i, len(val))
-		}
-		if len(val) > maxValueLen {
-			maxValueLen = len(val)
-		}
+	layout, err := m.packLayout()
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate sizes
-	headerSize := int(unsafe.Sizeof(StorageHeader{}))
-	blockByteSize := blockSize * 4   // 256 uint32s = 1024 bytes per block
-	valueSlotSize := maxValueLen + 1 // +1 for length byte
-
-	v4BlockCount := len(m.shared[v4LPM]) + len(m.dynamic[v4LPM])
-	v6BlockCount := len(m.shared[v6LPM]) + len(m.dynamic[v6LPM])
-	valueCount := m.sharedValueCount + len(m.revValues)
-
-	// Calculate offsets
-	v4BlocksOffset := headerSize
-	v6BlocksOffset := v4BlocksOffset + (v4BlockCount * blockByteSize)
-	valuesOffset := v6BlocksOffset + (v6BlockCount * blockByteSize)
-	totalSize := valuesOffset + (valueCount * valueSlotSize)
-
-	// Allocate storage
-	storage := make([]byte, totalSize)
-
-	// Write header
-	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
-	header.Magic = magicNumber
-	header.Version = currentVersion
-	header.V4BlockCount = uint32(v4BlockCount)
-	header.V6BlockCount = uint32(v6BlockCount)
-	header.ValueCount = uint32(valueCount)
-	header.ValueSlotSize = uint32(valueSlotSize)
-	header.V4BlocksOffset = uint32(v4BlocksOffset)
-	header.V6BlocksOffset = uint32(v6BlocksOffset)
-	header.ValuesOffset = uint32(valuesOffset)
-
-	// Write IPv4 blocks
-	offset := v4BlocksOffset
-	for i := 0; i < len(m.shared[v4LPM]); i++ {
-		block := &m.shared[v4LPM][i]
-		blockBytes := unsafe.Slice((*byte)(unsafe.Pointer(&block[0])), blockByteSize)
-		copy(storage[offset:offset+blockByteSize], blockBytes)
-		offset += blockByteSize
-	}
-	for i := 0; i < len(m.dynamic[v4LPM]); i++ {
-		block := m.dynamic[v4LPM][i]
-		blockBytes := unsafe.Slice((*byte)(unsafe.Pointer(&block[0])), blockByteSize)
-		copy(storage[offset:offset+blockByteSize], blockBytes)
-		offset += blockByteSize
-	}
-
-	// Write IPv6 blocks
-	offset = v6BlocksOffset
-	for i := 0; i < len(m.shared[v6LPM]); i++ {
-		block := &m.shared[v6LPM][i]
-		blockBytes := unsafe.Slice((*byte)(unsafe.Pointer(&block[0])), blockByteSize)
-		copy(storage[offset:offset+blockByteSize], blockBytes)
-		offset += blockByteSize
-	}
-	for i := 0; i < len(m.dynamic[v6LPM]); i++ {
-		block := m.dynamic[v6LPM][i]
-		blockBytes := unsafe.Slice((*byte)(unsafe.Pointer(&block[0])), blockByteSize)
-		copy(storage[offset:offset+blockByteSize], blockBytes)
-		offset += blockByteSize
-	}
-
-	// Write values
-	offset = valuesOffset
-
-	// Write shared values first
-	if m.sharedValueCount > 0 && len(m.sharedValues) > 0 {
-		for i := 0; i < m.sharedValueCount; i++ {
-			srcOffset := i * m.sharedValuesSlotSize
-			strLen := int(m.sharedValues[srcOffset])
-			storage[offset] = byte(strLen)
-			copy(storage[offset+1:offset+1+strLen], m.sharedValues[srcOffset+1:srcOffset+1+strLen])
-			offset += valueSlotSize
-		}
+	storage = AlignedBuffer(layout.totalSize)
+	if err := m.packInto(storage, layout); err != nil {
+		return nil, err
 	}
+	return storage, nil
+}
 
-	// Write dynamic values
-	for _, val := range m.revValues {
-		storage[offset] = byte(len(val))
-		copy(storage[offset+1:], []byte(val))
-		offset += valueSlotSize
-	}
+// PackInto serializes the LPM trie into buf, e.g. a mapped shared memory segment, and
+// returns the number of bytes written, see PackToSharedStorage. buf must hold at least
+// EstimatePackedSize bytes; the bytes after the storage are left untouched.
+func (m *LPM) PackInto(buf []byte) (n int, err error) {
+	defer func(start time.Time) { m.logPack(int64(n), start, err) }(time.Now())
 
-	return storage, nil
+	layout, err := m.packLayout()
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) < layout.totalSize {
+		return 0, fmt.Errorf("buffer too small for packed storage: need %d bytes, got %d", layout.totalSize, len(buf))
+	}
+	if err := m.packInto(buf[:layout.totalSize], layout); err != nil {
+		return 0, err
+	}
+	return layout.totalSize, nil
 }
 
 // MarshalBinary implements encoding.BinaryMarshaler.
@@ -344,8 +416,9 @@ func (m *LPM) MarshalBinary() ([]byte, error) {
 
 // UnmarshalBinary implements encoding.BinaryUnmarshaler.
 // It deserializes the LPM trie from a binary format using NewWithSharedStorage.
+// The data is copied, so callers such as encoding/gob or database drivers may reuse the buffer.
 func (m *LPM) UnmarshalBinary(data []byte) error {
-	lpm, err := NewWithSharedStorage(data)
+	lpm, err := NewWithSharedStorage(alignedClone(data))
 	if err != nil {
 		return err
 	}
@@ -354,8 +427,8 @@ func (m *LPM) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
-func blockWithValue(initValue uint32) *LPMBlock {
-	blk := &LPMBlock{}
+func blockWithValue(initValue uint32) LPMBlock {
+	var blk LPMBlock
 
 	if isInvalid(initValue) {
 		return blk
@@ -367,33 +440,72 @@ func blockWithValue(initValue uint32) *LPMBlock {
 	return blk
 }
 
-func (m *LPM) addValue(value string) int {
-	if valueIdx, ok := m.values[value]; ok {
-		return valueIdx
+func (m *LPM) addValue(value string, priority uint8) (int, error) {
+	if !m.sharedIndexed {
+		m.indexSharedValues()
+	}
+	key := valueKey{value: value, priority: priority}
+	if valueIdx, ok := m.values[key]; ok {
+		return valueIdx, nil
 	}
 	valueIdx := m.sharedValueCount + len(m.revValues)
-	m.values[value] = valueIdx
+	if err := checkValueCount(valueIdx); err != nil {
+		return 0, err
+	}
+	m.values[key] = valueIdx
 	m.revValues = append(m.revValues, value)
-	return valueIdx
+	m.revPriorities = append(m.revPriorities, priority)
+	m.valueBytes += len(value)
+	return valueIdx, nil
+}
+
+// indexSharedValues adds the values of the shared storage to the reverse index, so inserting a
+// value that already exists in shared storage reuses its index instead of adding a dynamic copy.
+// It runs lazily on the first insert to keep loading cheap for lookup-only consumers.
+func (m *LPM) indexSharedValues() {
+	for i := 0; i < m.sharedValueCount; i++ {
+		value, ok := m.getValueByIndex(i)
+		if !ok {
+			continue
+		}
+		key := valueKey{value: value, priority: m.priorityByIndex(i)}
+		if _, exists := m.values[key]; !exists {
+			m.values[key] = i
+		}
+	}
+	m.sharedIndexed = true
+}
+
+// priorityByIndex returns the insertion priority of a value index
+func (m *LPM) priorityByIndex(valueIdx int) uint8 {
+	if valueIdx < m.sharedValueCount {
+		if !m.sharedPriorities {
+			// Version 1 and early version 2 storage has no priorities
+			return 0
+		}
+		offset := valueIdx*m.sharedValuesSlotSize + m.sharedValueLenSize
+		if offset >= len(m.sharedValues) {
+			return 0
+		}
+		return m.sharedValues[offset]
+	}
+	dynamicIdx := valueIdx - m.sharedValueCount
+	if dynamicIdx >= len(m.revPriorities) {
+		return 0
+	}
+	return m.revPriorities[dynamicIdx]
 }
 
 // getValueByIndex retrieves a value by its index, supporting both shared and dynamic values
 func (m *LPM) getValueByIndex(valueIdx int) (string, bool) {
 	if valueIdx < m.sharedValueCount {
 		// Value is in shared storage
-		if m.sharedValues == nil || m.sharedValuesSlotSize == 0 {
-			return "", false
-		}
-		offset := valueIdx * m.sharedValuesSlotSize
-		if offset+m.sharedValuesSlotSize > len(m.sharedValues) {
-			return "", false
-		}
-		// First byte contains the string length
-		strLen := int(m.sharedValues[offset])
-		if strLen == 0 || offset+1+strLen > len(m.sharedValues) {
+		data, ok := m.getSharedValue(valueIdx)
+		if !ok {
 			return "", false
 		}
-		return string(m.sharedValues[offset+1 : offset+1+strLen]), true
+		// Zero-copy view: shared values are immutable and the storage outlives the LPM
+		return bytesString(data), true
 	}
 	// Value is in dynamic storage
 	dynamicIdx := valueIdx - m.sharedValueCount
@@ -403,6 +515,41 @@ func (m *LPM) getValueByIndex(valueIdx int) (string, bool) {
 	return m.revValues[dynamicIdx], true
 }
 
+// getSharedValue returns the payload of a value slot in shared storage without copying it
+func (m *LPM) getSharedValue(valueIdx int) ([]byte, bool) {
+	if m.sharedValues == nil || m.sharedValuesSlotSize == 0 {
+		return nil, false
+	}
+	offset := valueIdx * m.sharedValuesSlotSize
+	if offset+m.sharedValuesSlotSize > len(m.sharedValues) {
+		return nil, false
+	}
+	// The slot starts with the value length
+	var valueLen int
+	if m.sharedValueLenSize == 1 {
+		valueLen = int(m.sharedValues[offset])
+	} else {
+		valueLen = int(binary.NativeEndian.Uint16(m.sharedValues[offset:]))
+	}
+	start := offset + m.sharedValueLenSize
+	if m.sharedPriorities {
+		// Skip the priority byte
+		start++
+	}
+	if start+valueLen > offset+m.sharedValuesSlotSize {
+		return nil, false
+	}
+	return m.sharedValues[start : start+valueLen : start+valueLen], true
+}
+
+// valueLenSize returns the size of the per-slot value length prefix for a format version
+func valueLenSize(version uint32) int {
+	if version == versionV1 {
+		return 1
+	}
+	return 2
+}
+
 // encodeValue encodes a value index with its prefix length
 func encodeValue(valueIdx int, prefixLen int) uint32 {
 	// Store (prefixLen + 1) in the most significant byte
@@ -436,182 +583,350 @@ func isInvalid(encoded uint32) bool {
 	return encoded == 0
 }
 
-func (m *LPM) getBlockRef(proto int, blockIdx int) *LPMBlock {
-	sharedLen := len(m.shared[proto])
+func (t *trie) getBlockRef(proto int, blockIdx int) *LPMBlock {
+	sharedLen := len(t.shared[proto])
 	if blockIdx < sharedLen {
-		return &m.shared[proto][blockIdx]
+		return &t.shared[proto][blockIdx]
 	}
-	return m.dynamic[proto][blockIdx-sharedLen]
+	return t.dynamic[proto][blockIdx-sharedLen]
 }
 
-func (m *LPM) getValue(proto int, block int, slot uint8) uint32 {
-	sharedLen := len(m.shared[proto])
+func (t *trie) getValue(proto int, block int, slot uint8) uint32 {
+	sharedLen := len(t.shared[proto])
 	if block < sharedLen {
-		return m.shared[proto][block][slot]
+		return t.shared[proto][block][slot]
 	}
-	return m.dynamic[proto][block-sharedLen][slot]
+	return t.dynamic[proto][block-sharedLen][slot]
 }
 
-func (m *LPM) setValue(proto int, block int, slot uint8, value uint32) {
-	sharedLen := len(m.shared[proto])
-	if block < sharedLen {
-		m.shared[proto][block][slot] = value
-		return
+func (t *trie) setValue(proto int, block int, slot uint8, value uint32) {
+	if t.isFrozen(proto, block) {
+		panic("lpm: write to a shared block")
+	}
+	t.dynamic[proto][block-len(t.shared[proto])][slot] = value
+}
+
+// isFrozen reports whether the block lives in shared storage or is shared with a snapshot
+func (t *trie) isFrozen(proto int, blockIdx int) bool {
+	return blockIdx < len(t.shared[proto]) || blockIdx < t.frozen[proto]
+}
+
+// copyShared returns a writable index for the block: dynamic blocks are returned as is,
+// shared and frozen ones are copied to a new dynamic block whose index the caller must link in
+func (t *trie) copyShared(proto int, blockIdx int) (int, bool) {
+	if !t.isFrozen(proto, blockIdx) {
+		return blockIdx, false
+	}
+	blk := *t.getBlockRef(proto, blockIdx)
+	t.copied[proto]++
+	return t.newBlock(proto, &blk), true
+}
+
+// writableRoot returns the index of the root block, copying it out of shared storage if needed
+func (t *trie) writableRoot(proto int) int {
+	blockIdx, _ := t.copyShared(proto, t.root[proto])
+	t.root[proto] = blockIdx
+	return blockIdx
+}
+
+// writableChild returns the index of the block referenced by a slot of the writable block
+// parentIdx, copying it out of shared storage and repointing the slot if needed
+func (t *trie) writableChild(proto int, parentIdx int, slot uint8) int {
+	blockIdx, copied := t.copyShared(proto, decodeBlockRef(t.getValue(proto, parentIdx, slot)))
+	if copied {
+		t.setValue(proto, parentIdx, slot, encodeBlockRef(blockIdx))
 	}
-	m.dynamic[proto][block-sharedLen][slot] = value
+	return blockIdx
 }
 
-func (m *LPM) propagateValue(proto int, blockIdx int, valueIdx int, prefixLen int, startIdx, endIdx uint8) {
-	// Propagate the value to all slots in the range [startIdx, endIdx]
+// propagateValue stores the value in the slots [startIdx, endIdx] of the block, and in
+// every slot of the blocks below them, unless the slot holds a prefix of higher priority,
+// or of equal priority and longer length. Descending into existing blocks makes the
+// result independent of insertion order: a broader prefix inserted after narrower ones
+// still fills the addresses they leave uncovered.
+func (t *trie) propagateValue(proto int, blockIdx int, valueIdx int, prefixLen int, priority uint8, priorityOf func(int) uint8, startIdx, endIdx uint8) {
 	newValue := encodeValue(valueIdx, prefixLen)
 	for inBlockIdx := int(startIdx); inBlockIdx <= int(endIdx); inBlockIdx++ {
-		currentVal := m.getValue(proto, blockIdx, uint8(inBlockIdx))
+		currentVal := t.getValue(proto, blockIdx, uint8(inBlockIdx))
 
 		if isBlockRef(currentVal) {
-			// Block reference for a narrower subnet - propagate into it
-			// but only fill invalid slots (don't override existing values)
-			innerBlockIdx := decodeBlockRef(currentVal)
-			innerBlockRef := m.getBlockRef(proto, innerBlockIdx)
-			for idx, val := range innerBlockRef {
-				if isInvalid(val) {
-					innerBlockRef[idx] = newValue
-				}
-			}
+			// Block of narrower prefixes, fill the addresses they leave uncovered
+			innerBlockIdx := t.writableChild(proto, blockIdx, uint8(inBlockIdx))
+			t.propagateValue(proto, innerBlockIdx, valueIdx, prefixLen, priority, priorityOf, 0, blockSize-1)
 		} else if isInvalid(currentVal) {
-			m.setValue(proto, blockIdx, uint8(inBlockIdx), newValue)
+			t.setValue(proto, blockIdx, uint8(inBlockIdx), newValue)
 		} else {
-			// It's a value - check if our prefix is longer (more specific) or equal
-			_, existingPrefixLen := decodeValue(currentVal)
-			if prefixLen >= existingPrefixLen {
-				// Our prefix is more specific or equal, override
-				m.setValue(proto, blockIdx, uint8(inBlockIdx), newValue)
+			// It's a value - higher priority wins, then check if our prefix is longer (more specific) or equal
+			existingIdx, existingPrefixLen := decodeValue(currentVal)
+			existingPriority := priorityOf(existingIdx)
+			if priority > existingPriority || (priority == existingPriority && prefixLen >= existingPrefixLen) {
+				// Our prefix is more important, more specific or equal, override
+				t.setValue(proto, blockIdx, uint8(inBlockIdx), newValue)
 			}
-			// If existing prefix is more specific, keep it
+			// If existing prefix is more important or more specific, keep it
 		}
 	}
 }
 
-func (m *LPM) Insert(net netip.Prefix, value string) {
-	valueIdx := m.addValue(value)
+// Insert stores value for the prefix. It fails with ErrReadOnly if the LPM was loaded with the ReadOnly option.
+// Host bits past the prefix length are ignored, so 10.1.2.3/16 is stored as 10.1.0.0/16;
+// use InsertStrict to reject such prefixes instead. Invalid prefixes fail with
+// ErrInvalidPrefix, and inserts that would need more distinct values or blocks than
+// slots can encode fail with ErrCapacity, leaving the trie unchanged.
+func (m *LPM) Insert(net netip.Prefix, value string) error {
+	return m.InsertWithPriority(net, value, 0)
+}
 
-	proto := v4LPM
+// InsertWithPriority inserts a prefix with a priority that takes precedence over prefix length:
+// an address matches the covering prefix with the highest priority, and only among prefixes of
+// equal priority does the longest one win. Insert uses priority 0, so for example a /8 inserted
+// with priority 1 beats any /24 inserted with Insert. The same value inserted with different
+// priorities is stored as separate entries. Lookups never depend on the order in which
+// distinct prefixes were inserted. Inserting a prefix again replaces it, even with a
+// lower priority.
+func (m *LPM) InsertWithPriority(net netip.Prefix, value string, priority uint8) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkInsert(net); err != nil {
+		return m.rejected(net, err)
+	}
+	if err := m.checkMemory(net, &valueKey{value: value, priority: priority}); err != nil {
+		return m.rejected(net, err)
+	}
+	valueIdx, err := m.addValue(value, priority)
+	if err != nil {
+		return m.rejected(net, err)
+	}
+	oldIdx, oldFound := m.watchedValue(net)
+	delete(m.expiries, net.Masked())
+	m.insert(net, valueIdx, priority, m.priorityByIndex)
+	m.warnCapacity()
+	if len(m.watchers) > 0 {
+		m.notify(net, oldIdx, oldFound, valueIdx, true)
+	}
+	return nil
+}
+
+// insert stores valueIdx for the prefix, creating blocks along the path as needed.
+// Host bits past the prefix length are dropped.
+func (t *trie) insert(net netip.Prefix, valueIdx int, priority uint8, priorityOf func(int) uint8) {
+	t.generation++
+	net = net.Masked()
+	t.loadPrefixes()
+	if oldIdx, ok := t.prefixes.set(net, valueIdx); ok && priorityOf(oldIdx) > priority {
+		// The prefix may have shadowed prefixes its new priority no longer beats
+		t.rebuild(net, priorityOf)
+		return
+	}
+	proto, blockIdx, startIdx, endIdx := t.descend(net)
+	t.propagateValue(proto, blockIdx, valueIdx, net.Bits(), priority, priorityOf, startIdx, endIdx)
+	t.collapse(net)
+	t.refreshPrefix16(net)
+}
+
+// descend walks down to the block holding the last byte of the prefix, creating blocks
+// along the path as needed, and returns the range of slots the prefix covers in it
+func (t *trie) descend(net netip.Prefix) (proto int, blockIdx int, startIdx, endIdx uint8) {
+	proto = v4LPM
 	if net.Addr().Is6() {
 		proto = v6LPM
 	}
 
 	prefixLen := net.Bits()
+	blockIdx = t.writableRoot(proto)
 
-	blockIdx := 0
 	// Insertion process
 	for idx, inBlockIdx := range net.Addr().AsSlice() {
 		tail := int((idx+1)*8) - prefixLen
 		if tail >= 0 {
-			// This is the last byte - propagate to the range
+			// This is the last byte - return the range
 			mask := uint8(0xff << tail)
-			startIdx := inBlockIdx & mask
-			endIdx := startIdx | ^mask
-
-			m.propagateValue(proto, blockIdx, valueIdx, prefixLen, startIdx, endIdx)
-			return
+			startIdx = inBlockIdx & mask
+			endIdx = startIdx | ^mask
+			return proto, blockIdx, startIdx, endIdx
 		}
 
-		currentVal := m.getValue(proto, blockIdx, inBlockIdx)
+		currentVal := t.getValue(proto, blockIdx, inBlockIdx)
 
 		if isBlockRef(currentVal) {
 			// Already a block reference, continue traversal
-			blockIdx = decodeBlockRef(currentVal)
+			blockIdx = t.writableChild(proto, blockIdx, inBlockIdx)
 		} else {
 			// Need to create a new block
 			// Remember the old value (could be invalid or a value)
 			oldVal := currentVal
 
-			// Create new block
-			newBlockIdx := len(m.shared[proto]) + len(m.dynamic[proto])
-			m.setValue(proto, blockIdx, inBlockIdx, encodeBlockRef(newBlockIdx))
-
-			// Initialize new block
+			// Create new block, initialized with the old value
 			blk := blockWithValue(oldVal)
-			// Add new block to the tree
-			m.dynamic[proto] = append(m.dynamic[proto], blk)
+			newBlockIdx := t.newBlock(proto, &blk)
+			t.setValue(proto, blockIdx, inBlockIdx, encodeBlockRef(newBlockIdx))
 			blockIdx = newBlockIdx
 		}
 	}
+	panic("unreachable: prefix length exceeds address length")
+}
+
+// apply replaces every slot covered by the prefix that is not a block reference with
+// fn(slot), descending into all blocks nested below the prefix
+func (t *trie) apply(net netip.Prefix, fn func(encoded uint32) uint32) {
+	t.generation++
+	proto, blockIdx, startIdx, endIdx := t.descend(net)
+	t.applyRange(proto, blockIdx, startIdx, endIdx, fn)
+	t.collapse(net)
+	t.refreshPrefix16(net)
 }
 
+func (t *trie) applyRange(proto int, blockIdx int, startIdx, endIdx uint8, fn func(encoded uint32) uint32) {
+	for inBlockIdx := int(startIdx); inBlockIdx <= int(endIdx); inBlockIdx++ {
+		currentVal := t.getValue(proto, blockIdx, uint8(inBlockIdx))
+		if isBlockRef(currentVal) {
+			t.applyRange(proto, t.writableChild(proto, blockIdx, uint8(inBlockIdx)), 0, 0xff, fn)
+			continue
+		}
+		if newVal := fn(currentVal); newVal != currentVal {
+			t.setValue(proto, blockIdx, uint8(inBlockIdx), newVal)
+		}
+	}
+}
+
+// Lookup returns the value of the longest prefix containing addr.
+// It does not allocate: the address bytes are kept on the stack and the returned string
+// is either the interned dynamic value or a zero-copy view into shared storage.
 func (m *LPM) Lookup(addr netip.Addr) (string, bool) {
-	proto := v4LPM
-	if addr.Is6() {
-		proto = v6LPM
+	valueIdx, ok := m.LookupIndex(addr)
+	if !ok {
+		return "", false
+	}
+	return m.getValueByIndex(valueIdx)
+}
+
+// Lookup4 looks up an IPv4 address given as a uint32 in host byte order,
+// e.g. 0x0A000001 for 10.0.0.1, as it is usually held after parsing a packet header.
+func (m *LPM) Lookup4(addr uint32) (string, bool) {
+	key := [4]byte{byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr)}
+	valueIdx, ok := m.lookupKey(v4LPM, key[:])
+	if !ok {
+		return "", false
+	}
+	return m.getValueByIndex(valueIdx)
+}
+
+// Lookup6 looks up an IPv6 address given as its 16 raw bytes in network byte order.
+func (m *LPM) Lookup6(addr [16]byte) (string, bool) {
+	valueIdx, ok := m.lookupKey(v6LPM, addr[:])
+	if !ok {
+		return "", false
+	}
+	return m.getValueByIndex(valueIdx)
+}
+
+// LookupIndex returns the index of the value of the longest prefix containing addr.
+// Equal values always share an index, so hot paths can compare small integers and
+// defer string materialization to ValueByIndex.
+func (m *LPM) LookupIndex(addr netip.Addr) (int, bool) {
+	if !addr.IsValid() {
+		return 0, false
+	}
+	if addr.Is4() {
+		key := addr.As4()
+		return m.lookupKey(v4LPM, key[:])
+	}
+	key := addr.As16()
+	return m.lookupKey(v6LPM, key[:])
+}
+
+// ValueByIndex returns the value for an index obtained from LookupIndex.
+func (m *LPM) ValueByIndex(valueIdx int) (string, bool) {
+	if valueIdx < 0 {
+		return "", false
 	}
+	return m.getValueByIndex(valueIdx)
+}
 
-	blockIdx := 0
-	for _, inBlockIdx := range addr.AsSlice() {
-		value := m.getValue(proto, blockIdx, inBlockIdx)
+// lookupKey walks the trie of the given protocol using the address bytes as slot indices
+// and returns the index of the matched value
+func (t *trie) lookupKey(proto int, key []byte) (int, bool) {
+	if proto == v4LPM && t.stride16 != nil {
+		return t.lookupStride16(key)
+	}
+	if proto == v6LPM && t.ipv6Max64 {
+		key = key[:max64Bytes]
+	}
+	blockIdx := t.root[proto]
+	for _, inBlockIdx := range key {
+		value := t.getValue(proto, blockIdx, inBlockIdx)
 
 		if isBlockRef(value) {
 			// Continue traversal
 			blockIdx = decodeBlockRef(value)
 		} else if isInvalid(value) {
 			// No match
-			return "", false
+			return 0, false
 		} else {
-			// Found a value
+			// Found a value, unless the range was carved out with a tombstone
 			valueIdx, _ := decodeValue(value)
-			return m.getValueByIndex(valueIdx)
+			return valueIdx, valueIdx != tombstoneIdx
 		}
 	}
-	return "", false
+	return 0, false
 }
 
 // Stats contains statistics about the LPM trie
 type Stats struct {
 	IPv4Blocks      int // Number of blocks allocated for IPv4
 	IPv6Blocks      int // Number of blocks allocated for IPv6
+	Values          int // Number of distinct values, including ones no prefix uses until CompactValues
 	IPv4StorageSize int // Storage size in bytes for IPv4 trie
 	IPv6StorageSize int // Storage size in bytes for IPv6 trie
 	ValuesStorage   int // Storage size in bytes for values
+	PrefixesStorage int // Memory in bytes of the prefixes kept for Delete, see Delete
 	TotalSize       int // Total storage size in bytes
-}
 
-// Stats returns statistics about the LPM trie including block counts and storage sizes
-func (m *LPM) Stats() Stats {
-	// Count shared blocks
-	v4SharedLen := len(m.shared[v4LPM])
-	v6SharedLen := len(m.shared[v6LPM])
-
-	// Count dynamic blocks
-	v4DynamicLen := len(m.dynamic[v4LPM])
-	v6DynamicLen := len(m.dynamic[v6LPM])
+	// The block and value counts split into those in shared storage and those in process
+	// memory, which inserts since loading added or copied out of shared storage
+	IPv4SharedBlocks  int
+	IPv4DynamicBlocks int
+	IPv6SharedBlocks  int
+	IPv6DynamicBlocks int
+	SharedValues      int
+	DynamicValues     int
+}
 
-	// Total blocks
-	v4TotalLen := v4SharedLen + v4DynamicLen
-	v6TotalLen := v6SharedLen + v6DynamicLen
+// storageSize estimates the memory used by the blocks of the given protocol trie
+func (t *trie) storageSize(proto int) int {
+	sharedLen := len(t.shared[proto])
+	dynamicLen := len(t.dynamic[proto])
 
-	// Calculate IPv4 storage size
-	v4StorageSize := 0
+	size := 0
 	// Shared blocks: just the block data (stored in shared memory, no Go overhead)
-	if v4SharedLen > 0 {
-		v4StorageSize += v4SharedLen * blockSize * 4 // 256 uint32s per block
+	if sharedLen > 0 {
+		size += sharedLen * blockSize * 4 // 256 uint32s per block
 	}
 	// Dynamic blocks: slice overhead + block data + pointer overhead
-	if v4DynamicLen > 0 {
-		v4StorageSize += 3 * 8                        // slice header (ptr, len, cap)
-		v4StorageSize += v4DynamicLen * blockSize * 4 // block data
-		v4StorageSize += v4DynamicLen * 8             // pointers to blocks
+	if dynamicLen > 0 {
+		size += 3 * 8                         // slice header (ptr, len, cap)
+		size += dynamicLen * dynamicBlockSize // block data and pointers to blocks
 	}
-
-	// Calculate IPv6 storage size
-	v6StorageSize := 0
-	// Shared blocks: just the block data (stored in shared memory, no Go overhead)
-	if v6SharedLen > 0 {
-		v6StorageSize += v6SharedLen * blockSize * 4 // 256 uint32s per block
-	}
-	// Dynamic blocks: slice overhead + block data + pointer overhead
-	if v6DynamicLen > 0 {
-		v6StorageSize += 3 * 8                        // slice header (ptr, len, cap)
-		v6StorageSize += v6DynamicLen * blockSize * 4 // block data
-		v6StorageSize += v6DynamicLen * 8             // pointers to blocks
+	if proto == v4LPM {
+		size += len(t.stride16) * 4 // first level table, see IPv4Stride16
 	}
+	return size
+}
+
+// blockCount returns the number of shared and dynamic blocks of the given protocol trie
+func (t *trie) blockCount(proto int) int {
+	return len(t.shared[proto]) + len(t.dynamic[proto])
+}
+
+// Stats returns statistics about the LPM trie including block counts and storage sizes.
+// The counters are maintained by inserts and deletes, so it takes constant time.
+func (m *LPM) Stats() Stats {
+	v4TotalLen := m.blockCount(v4LPM)
+	v6TotalLen := m.blockCount(v6LPM)
+
+	v4StorageSize := m.storageSize(v4LPM)
+	v6StorageSize := m.storageSize(v6LPM)
 
 	// Calculate values storage size
 	valStorageSize := 0
@@ -624,25 +939,28 @@ func (m *LPM) Stats() Stats {
 	// Dynamic values: string data + Go overhead
 	if len(m.revValues) > 0 {
 		// String data
-		for _, val := range m.revValues {
-			valStorageSize += len(val)
-		}
-		// String struct overhead (ptr, len) for each string
-		valStorageSize += len(m.revValues) * 2 * 8
-		// revValues slice overhead
-		valStorageSize += 3 * 8
-		// values map overhead (approximate: map header + entries)
-		valStorageSize += 8 * 8                 // map header
-		valStorageSize += len(m.revValues) * 32 // approximate per-entry overhead
-		valStorageSize += len(m.revValues) * 4  // int values in map
+		valStorageSize += m.valueBytes
+		// String structs, map entries and priorities, see dynamicValueSize
+		valStorageSize += len(m.revValues) * dynamicValueSize
+		// revValues slice and values map headers
+		valStorageSize += 3*8 + 8*8
 	}
 
 	return Stats{
 		IPv4Blocks:      v4TotalLen,
 		IPv6Blocks:      v6TotalLen,
+		Values:          m.valueCount(),
 		IPv4StorageSize: v4StorageSize,
 		IPv6StorageSize: v6StorageSize,
 		ValuesStorage:   valStorageSize,
-		TotalSize:       v4StorageSize + v6StorageSize + valStorageSize,
+		PrefixesStorage: m.prefixes.size(),
+		TotalSize:       v4StorageSize + v6StorageSize + valStorageSize + m.prefixes.size(),
+
+		IPv4SharedBlocks:  len(m.shared[v4LPM]),
+		IPv4DynamicBlocks: len(m.dynamic[v4LPM]),
+		IPv6SharedBlocks:  len(m.shared[v6LPM]),
+		IPv6DynamicBlocks: len(m.dynamic[v6LPM]),
+		SharedValues:      m.sharedValueCount,
+		DynamicValues:     len(m.revValues),
 	}
 }
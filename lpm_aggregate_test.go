@@ -0,0 +1,61 @@
+package lpm
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestAggregate tests that aggregated entries map addresses like the trie
+func TestAggregate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := range 30 {
+		entries := randomEntries(rng, 1+rng.Intn(300))
+		m, err := Build(entries)
+		if err != nil {
+			t.Fatalf("round %d: Build: %v", round, err)
+		}
+		aggregated := m.Aggregate()
+		if len(aggregated) > len(m.Entries()) {
+			t.Errorf("round %d: %d aggregated entries, trie holds %d", round, len(aggregated), len(m.Entries()))
+		}
+		rebuilt, err := Build(aggregated)
+		if err != nil {
+			t.Fatalf("round %d: Build(Aggregate()): %v", round, err)
+		}
+		if rebuilt.Fingerprint() != m.Fingerprint() {
+			t.Fatalf("round %d: aggregated entries map addresses differently", round)
+		}
+	}
+}
+
+// TestAggregateMerge tests merging contiguous and nested prefixes
+func TestAggregateMerge(t *testing.T) {
+	m := New()
+	for i := range 256 {
+		m.Insert(netip.MustParsePrefix(fmt.Sprintf("10.20.%d.0/24", i)), "blocked")
+	}
+	for i := range 4 {
+		m.Insert(netip.MustParsePrefix(fmt.Sprintf("10.21.%d.0/24", i)), "blocked")
+	}
+	m.Insert(netip.MustParsePrefix("10.20.7.128/25"), "blocked")
+	m.InsertWithPriority(netip.MustParsePrefix("192.168.0.0/16"), "lan", 0)
+	m.Insert(netip.MustParsePrefix("192.168.0.0/17"), "lan")
+	m.InsertWithPriority(netip.MustParsePrefix("192.168.128.0/17"), "lan", 3)
+	m.InsertTombstone(netip.MustParsePrefix("192.168.1.0/24"))
+
+	want := []PrefixValue{
+		{Prefix: netip.MustParsePrefix("10.20.0.0/16"), Value: "blocked"},
+		{Prefix: netip.MustParsePrefix("10.21.0.0/22"), Value: "blocked"},
+		{Prefix: netip.MustParsePrefix("192.168.0.0/16"), Value: "lan"},
+		{Prefix: netip.MustParsePrefix("192.168.1.0/24"), Tombstone: true},
+	}
+	got := m.Aggregate()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Aggregate() = %v, want %v", got, want)
+	}
+	if len(New().Aggregate()) != 0 {
+		t.Error("Aggregate of an empty trie returned entries")
+	}
+}
@@ -0,0 +1,68 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+// TestAlignedBuffer tests the size and alignment of aligned buffers
+func TestAlignedBuffer(t *testing.T) {
+	for _, size := range []int{0, 1, 7, 8, 9, 1024, 4099} {
+		buf := AlignedBuffer(size)
+		if len(buf) != size || cap(buf) != size {
+			t.Errorf("AlignedBuffer(%d) has len %d, cap %d", size, len(buf), cap(buf))
+		}
+		if size > 0 && uintptr(unsafe.Pointer(&buf[0]))%storageAlign != 0 {
+			t.Errorf("AlignedBuffer(%d) is not aligned", size)
+		}
+	}
+}
+
+// TestSharedStorageMisaligned tests that misaligned storage is copied before its blocks are used
+func TestSharedStorageMisaligned(t *testing.T) {
+	lpm := newPackTestLPM()
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+
+	for _, offset := range []int{0, 1, 2, 3, 4} {
+		buf := AlignedBuffer(len(storage) + offset)[offset:]
+		copy(buf, storage)
+		shared, err := NewWithSharedStorage(buf)
+		if err != nil {
+			t.Fatalf("offset %d: NewWithSharedStorage: %v", offset, err)
+		}
+
+		block := uintptr(unsafe.Pointer(&shared.shared[v4LPM][0]))
+		start := uintptr(unsafe.Pointer(&buf[0]))
+		inPlace := block >= start && block < start+uintptr(len(buf))
+		if inPlace != (zeroCopy && offset%4 == 0) {
+			t.Errorf("offset %d: blocks mapped in place: %v", offset, inPlace)
+		}
+		if block%4 != 0 {
+			t.Errorf("offset %d: blocks are misaligned", offset)
+		}
+		for addr, want := range map[string]string{"10.1.2.3": "DC6", "2001:db8:1::1": "doc-subnet"} {
+			if got, _ := shared.Lookup(netip.MustParseAddr(addr)); got != want {
+				t.Errorf("offset %d: Lookup(%s) = %q, want %q", offset, addr, got, want)
+			}
+		}
+	}
+}
+
+// TestSharedStorageMisalignedOffsets tests that block sections at misaligned offsets are rejected
+func TestSharedStorageMisalignedOffsets(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
+	header.V6BlocksOffset -= 2
+	header.Checksum = storageChecksum(storage, len(storage), header.Version)
+	if _, err := NewWithSharedStorage(storage); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("NewWithSharedStorage = %v, want ErrCorrupt", err)
+	}
+}
@@ -0,0 +1,63 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+// TestAtomic tests swapping tries while other goroutines look up addresses
+func TestAtomic(t *testing.T) {
+	var empty Atomic
+	if value, found := empty.Lookup(netip.MustParseAddr("10.0.0.1")); found || value != "" {
+		t.Errorf("Lookup on zero Atomic = %q, %v, want no match", value, found)
+	}
+
+	build := func(generation int) *LPM {
+		lpm := New()
+		lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), fmt.Sprintf("gen%d", generation))
+		return lpm
+	}
+	first := build(0)
+	table := NewAtomic(first)
+	if table.Load() != first {
+		t.Fatal("Load() did not return the stored trie")
+	}
+
+	const generations = 100
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := -1
+			for last < generations {
+				value, found := table.Lookup(netip.MustParseAddr("10.1.2.3"))
+				var generation int
+				if _, err := fmt.Sscanf(value, "gen%d", &generation); !found || err != nil {
+					t.Errorf("Lookup = %q, %v, want a generation", value, found)
+					return
+				}
+				if generation < last {
+					t.Errorf("Lookup went back from generation %d to %d", last, generation)
+					return
+				}
+				last = generation
+			}
+		}()
+	}
+
+	for generation := 1; generation <= generations; generation++ {
+		next := build(generation)
+		if prev := table.Swap(next); prev == nil {
+			t.Fatal("Swap() returned nil")
+		}
+	}
+	wg.Wait()
+
+	table.Store(nil)
+	if _, found := table.Lookup(netip.MustParseAddr("10.1.2.3")); found {
+		t.Error("Lookup after Store(nil) found a value")
+	}
+}
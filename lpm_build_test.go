@@ -0,0 +1,211 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// randomEntries returns overlapping entries with duplicates, priorities and tombstones
+func randomEntries(rng *rand.Rand, n int) []PrefixValue {
+	entries := make([]PrefixValue, n)
+	for i := range entries {
+		var prefix netip.Prefix
+		if rng.Intn(2) == 0 {
+			addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(4)), byte(rng.Intn(256))})
+			prefix = netip.PrefixFrom(addr, 8+rng.Intn(25))
+		} else {
+			addr := netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(rng.Intn(4)), 15: byte(rng.Intn(256))})
+			prefix = netip.PrefixFrom(addr, 32+rng.Intn(97))
+		}
+		entries[i] = PrefixValue{Prefix: prefix, Value: string(rune('a' + rng.Intn(4))), Priority: uint8(rng.Intn(2))}
+		if rng.Intn(10) == 0 {
+			entries[i] = PrefixValue{Prefix: prefix, Tombstone: true}
+		}
+	}
+	return entries
+}
+
+// TestBuild tests that Build maps addresses like inserting the entries in order
+func TestBuild(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := range 20 {
+		entries := randomEntries(rng, 1+rng.Intn(500))
+		want := New()
+		for _, e := range entries {
+			if e.Tombstone {
+				want.InsertTombstone(e.Prefix)
+			} else {
+				want.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+			}
+		}
+		got, err := Build(entries)
+		if err != nil {
+			t.Fatalf("round %d: Build: %v", round, err)
+		}
+		if got.Fingerprint() != want.Fingerprint() {
+			t.Fatalf("round %d: Build fingerprint differs from inserting in order", round)
+		}
+		for _, e := range entries {
+			addr := e.Prefix.Masked().Addr()
+			wantValue, wantFound := want.Lookup(addr)
+			if gotValue, gotFound := got.Lookup(addr); gotValue != wantValue || gotFound != wantFound {
+				t.Fatalf("round %d: Lookup(%s) = %q (found=%v), want %q (found=%v)", round, addr, gotValue, gotFound, wantValue, wantFound)
+			}
+		}
+		if gotBlocks, wantBlocks := got.Stats().IPv4Blocks+got.Stats().IPv6Blocks, want.Stats().IPv4Blocks+want.Stats().IPv6Blocks; gotBlocks > wantBlocks {
+			t.Errorf("round %d: Build made %d blocks, inserting %d", round, gotBlocks, wantBlocks)
+		}
+		if err := got.Verify(); err != nil {
+			t.Fatalf("round %d: Verify: %v", round, err)
+		}
+	}
+}
+
+// TestBuildLayout tests that blocks are laid out breadth first and that sibling prefixes
+// with equal values are aggregated
+func TestBuildLayout(t *testing.T) {
+	m, err := Build([]PrefixValue{
+		{Prefix: netip.MustParsePrefix("10.1.1.0/25"), Value: "a"},
+		{Prefix: netip.MustParsePrefix("10.1.1.128/25"), Value: "a"},
+		{Prefix: netip.MustParsePrefix("10.2.0.0/16"), Value: "b"},
+		{Prefix: netip.MustParsePrefix("10.2.3.4/32"), Value: "c"},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "d"},
+	}, IPv4Stride16())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// Blocks for 10, 10.1, 10.2 and 10.2.3: 10.1.1 is aggregated into a single slot
+	if got := m.Stats().IPv4Blocks; got != 5 {
+		t.Errorf("IPv4Blocks = %d, want 5", got)
+	}
+	depth := map[int]int{0: 0}
+	for blockIdx := range m.blockCount(v4LPM) {
+		d, ok := depth[blockIdx]
+		if !ok {
+			t.Fatalf("block %d is not referenced by an earlier block", blockIdx)
+		}
+		if blockIdx > 0 && d < depth[blockIdx-1] {
+			t.Errorf("block %d at depth %d follows a block at depth %d", blockIdx, d, depth[blockIdx-1])
+		}
+		for _, encoded := range m.getBlockRef(v4LPM, blockIdx) {
+			if isBlockRef(encoded) {
+				depth[decodeBlockRef(encoded)] = d + 1
+			}
+		}
+	}
+
+	for addr, want := range map[string]string{"10.1.1.1": "a", "10.1.1.200": "a", "10.2.3.4": "c", "10.2.3.5": "b", "10.9.0.0": "d"} {
+		if got, _ := m.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+// TestBuildInvalid tests that Build reports the entry it failed on
+func TestBuildInvalid(t *testing.T) {
+	_, err := Build([]PrefixValue{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "a"},
+		{Value: "b"},
+	})
+	if !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Build = %v, want ErrInvalidPrefix", err)
+	}
+	if _, err := Build([]PrefixValue{{Prefix: netip.MustParsePrefix("2001:db8::1/128"), Value: "a"}}, IPv6Max64()); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Build(/128, IPv6Max64) = %v, want ErrInvalidPrefix", err)
+	}
+	m, err := Build(nil)
+	if err != nil {
+		t.Fatalf("Build(nil): %v", err)
+	}
+	if _, found := m.Lookup(netip.MustParseAddr("10.0.0.1")); found {
+		t.Error("empty trie found a value")
+	}
+}
+
+// TestBuildParallel tests that sharding the inserts by first byte builds the same trie as inserting in order
+func TestBuildParallel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := make([]PrefixValue, parallelBuildMin+1000)
+	for i := range entries {
+		var prefix netip.Prefix
+		if rng.Intn(4) > 0 {
+			addr := netip.AddrFrom4([4]byte{byte(rng.Intn(256)), byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+			prefix = netip.PrefixFrom(addr, rng.Intn(33))
+		} else {
+			addr := netip.AddrFrom16([16]byte{byte(rng.Intn(256)), 0x01, byte(rng.Intn(4)), 15: byte(rng.Intn(256))})
+			prefix = netip.PrefixFrom(addr, rng.Intn(129))
+		}
+		entries[i] = PrefixValue{Prefix: prefix, Value: string(rune('a' + rng.Intn(8))), Priority: uint8(rng.Intn(2))}
+		if rng.Intn(10) == 0 {
+			entries[i] = PrefixValue{Prefix: prefix, Tombstone: true}
+		}
+	}
+
+	want, err := build(entries, options{}, 1)
+	if err != nil {
+		t.Fatalf("sequential build: %v", err)
+	}
+	got, err := build(entries, options{}, 8)
+	if err != nil {
+		t.Fatalf("parallel build: %v", err)
+	}
+	if got.Fingerprint() != want.Fingerprint() {
+		t.Fatal("parallel build fingerprint differs from sequential")
+	}
+	if err := got.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	wantStorage, err := want.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	gotStorage, err := got.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	if !bytes.Equal(gotStorage, wantStorage) {
+		t.Error("parallel build packs differently from sequential")
+	}
+
+	invalid := slices.Clone(entries)
+	invalid[len(invalid)-1].Prefix = netip.Prefix{}
+	if _, err := build(invalid, options{}, 8); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("parallel build with an invalid prefix: error = %v, want %v", err, ErrInvalidPrefix)
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	entries := make([]PrefixValue, 100000)
+	for i := range entries {
+		addr := netip.AddrFrom4([4]byte{byte(rng.Intn(224)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		entries[i] = PrefixValue{Prefix: netip.PrefixFrom(addr, 8+rng.Intn(25)), Value: string(rune('a' + rng.Intn(50)))}
+	}
+	b.Run("Insert", func(b *testing.B) {
+		for range b.N {
+			m := New()
+			for _, e := range entries {
+				m.Insert(e.Prefix, e.Value)
+			}
+		}
+	})
+	b.Run("Build", func(b *testing.B) {
+		for range b.N {
+			if _, err := build(entries, options{}, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("BuildParallel", func(b *testing.B) {
+		for range b.N {
+			if _, err := Build(entries); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package lpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// foreignStorage returns storage as a host of the other byte order would have packed it
+func foreignStorage(t *testing.T, storage []byte) []byte {
+	t.Helper()
+
+	header, foreign, err := readHeader(storage)
+	if err != nil || foreign {
+		t.Fatalf("readHeader() = foreign %v, err %v, want native storage", foreign, err)
+	}
+	swapped := swapStorage(storage, &header)
+	if header.Version != versionV1 && !isLegacyV2(&header) {
+		offset := checksumOffset(header.Version)
+		sum := storageChecksum(swapped, len(swapped), header.Version)
+		binary.NativeEndian.PutUint32(swapped[offset:], bits.ReverseBytes32(sum))
+	}
+	return swapped
+}
+
+// TestSharedStorageForeignByteOrder tests that storage packed on a host of the other byte order is converted on load
+func TestSharedStorageForeignByteOrder(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1")
+	lpm.InsertWithPriority(netip.MustParsePrefix("192.0.2.0/24"), strings.Repeat("x", 300), 7)
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+
+	check := func(t *testing.T, loaded *LPM) {
+		tests := []struct{ addr, want string }{
+			{"10.2.3.4", "private"},
+			{"10.1.2.3", "dc1"},
+			{"192.0.2.1", strings.Repeat("x", 300)},
+			{"2001:db8::1", "doc"},
+		}
+		for _, tt := range tests {
+			got, found := loaded.Lookup(netip.MustParseAddr(tt.addr))
+			if !found || got != tt.want {
+				t.Errorf("Lookup(%s) = %q, %v, want %q", tt.addr, got, found, tt.want)
+			}
+		}
+		if _, found := loaded.Lookup(netip.MustParseAddr("11.0.0.1")); found {
+			t.Errorf("Lookup(11.0.0.1) found a value, want none")
+		}
+	}
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	t.Run("v2", func(t *testing.T) {
+		foreign := foreignStorage(t, storage)
+		if bytes.Equal(foreign, storage) {
+			t.Fatal("foreign storage equals native storage")
+		}
+		original := bytes.Clone(foreign)
+
+		loaded, err := NewWithSharedStorage(foreign)
+		if err != nil {
+			t.Fatalf("NewWithSharedStorage failed: %v", err)
+		}
+		check(t, loaded)
+
+		if !bytes.Equal(foreign, original) {
+			t.Error("loading foreign storage modified it")
+		}
+		if entries := loaded.Entries(); entries[len(entries)-2].Priority != 7 {
+			t.Errorf("priority after conversion = %d, want 7", entries[len(entries)-2].Priority)
+		}
+
+		// Repacking produces native storage
+		repacked, err := loaded.PackToSharedStorage()
+		if err != nil {
+			t.Fatalf("PackToSharedStorage after conversion failed: %v", err)
+		}
+		if !bytes.Equal(repacked, storage) {
+			t.Error("repacked storage differs from the original native storage")
+		}
+	})
+
+	t.Run("v1", func(t *testing.T) {
+		v1 := New()
+		v1.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+		v1.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+
+		loaded, err := NewWithSharedStorage(foreignStorage(t, packV1(t, v1)))
+		if err != nil {
+			t.Fatalf("NewWithSharedStorage(v1) failed: %v", err)
+		}
+		if got, _ := loaded.Lookup(netip.MustParseAddr("10.9.9.9")); got != "private" {
+			t.Errorf("Lookup(10.9.9.9) = %q, want private", got)
+		}
+		if got, _ := loaded.Lookup(netip.MustParseAddr("2001:db8::1")); got != "doc" {
+			t.Errorf("Lookup(2001:db8::1) = %q, want doc", got)
+		}
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		loaded, err := NewFromReader(bytes.NewReader(foreignStorage(t, storage)))
+		if err != nil {
+			t.Fatalf("NewFromReader failed: %v", err)
+		}
+		check(t, loaded)
+	})
+}
+
+// TestSharedStorageByteOrderMark tests that storage with a corrupt byte order mark is rejected
+func TestSharedStorageByteOrderMark(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	if mark := (*StorageHeader)(unsafe.Pointer(&storage[0])).ByteOrder; mark != byteOrderMark {
+		t.Fatalf("ByteOrder = 0x%08X, want 0x%08X", mark, byteOrderMark)
+	}
+
+	(*StorageHeader)(unsafe.Pointer(&storage[0])).ByteOrder = 0x02010403
+	_, err = NewWithSharedStorage(storage)
+	if err == nil || !strings.Contains(err.Error(), "byte order mark") {
+		t.Errorf("NewWithSharedStorage() error = %v, want byte order mark error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// TestInsertInvalidPrefix tests that invalid prefixes are rejected instead of corrupting the root block
+func TestInsertInvalidPrefix(t *testing.T) {
+	lpm := New()
+	if err := lpm.Insert(netip.Prefix{}, "bad"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Insert = %v, want ErrInvalidPrefix", err)
+	}
+	if err := lpm.InsertTombstone(netip.Prefix{}); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("InsertTombstone = %v, want ErrInvalidPrefix", err)
+	}
+	if _, err := lpm.Delete(netip.Prefix{}); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Delete = %v, want ErrInvalidPrefix", err)
+	}
+	if lpm.Generation() != 0 || len(lpm.revValues) != 0 {
+		t.Errorf("rejected inserts modified the trie")
+	}
+	if err := lpm.Verify(); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+
+	n := NewU32()
+	if err := n.Insert(netip.Prefix{}, 1); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Numeric.Insert = %v, want ErrInvalidPrefix", err)
+	}
+	if err := n.InsertTombstone(netip.Prefix{}); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Numeric.InsertTombstone = %v, want ErrInvalidPrefix", err)
+	}
+	if err := NewTags().Add(netip.Prefix{}, 1); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Tags.Add = %v, want ErrInvalidPrefix", err)
+	}
+}
+
+// TestInsertValueCapacity tests that the value table refuses indices slots cannot encode
+func TestInsertValueCapacity(t *testing.T) {
+	lpm := New()
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "existing"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	// Pretend the shared storage holds all other indices
+	lpm.sharedValueCount = maxValueCount - 1
+	lpm.values[valueKey{value: "existing"}] = maxValueCount - 1
+	lpm.revValues, lpm.revPriorities = []string{"existing"}, []uint8{0}
+
+	err := lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "new")
+	if !errors.Is(err, ErrCapacity) {
+		t.Errorf("Insert of a new value = %v, want ErrCapacity", err)
+	}
+	if err := lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "existing"); err != nil {
+		t.Errorf("Insert of an existing value = %v", err)
+	}
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("192.168.1.0/24")); err != nil {
+		t.Errorf("InsertTombstone = %v", err)
+	}
+}
+
+// TestInsertBlockCapacity tests that inserts fail before running out of block indices
+func TestInsertBlockCapacity(t *testing.T) {
+	lpm := New()
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	// Pretend the IPv4 trie is frozen up to the last block index
+	lpm.frozen[v4LPM] = maxBlockCount - 2
+	generation := lpm.Generation()
+
+	if err := lpm.Insert(netip.MustParsePrefix("10.1.2.0/24"), "b"); !errors.Is(err, ErrCapacity) {
+		t.Errorf("Insert = %v, want ErrCapacity", err)
+	}
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24")); !errors.Is(err, ErrCapacity) {
+		t.Errorf("InsertTombstone = %v, want ErrCapacity", err)
+	}
+	if lpm.Generation() != generation {
+		t.Errorf("failed inserts modified the trie")
+	}
+	// IPv6 has its own block indices
+	if err := lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "c"); err != nil {
+		t.Errorf("IPv6 Insert = %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package lpm
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// TestSharedStorageChecksum tests that corrupted storage is rejected unless verification is skipped
+func TestSharedStorageChecksum(t *testing.T) {
+	lpm := newPackTestLPM()
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	var streamed bytes.Buffer
+	if _, err := lpm.PackTo(&streamed); err != nil {
+		t.Fatalf("PackTo failed: %v", err)
+	}
+	if !bytes.Equal(streamed.Bytes(), storage) {
+		t.Fatal("PackTo and PackToSharedStorage produced different checksums or data")
+	}
+
+	if _, err := NewWithSharedStorage(storage); err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	header, _, err := readHeader(storage)
+	if err != nil {
+		t.Fatalf("readHeader failed: %v", err)
+	}
+
+	flip := func(offset int) func([]byte) {
+		return func(storage []byte) { storage[offset] ^= 0x40 }
+	}
+	tests := []struct {
+		name    string
+		corrupt func([]byte)
+	}{
+		{"header", func(storage []byte) { (*StorageHeader)(unsafe.Pointer(&storage[0])).ValueCount-- }},
+		{"IPv4 blocks", flip(int(header.V4BlocksOffset) + 4*10)},
+		{"IPv6 blocks", flip(int(header.V6BlocksOffset) + 4*blockSize + 3)},
+		{"values", flip(int(header.ValuesOffset) + 5)},
+		{"last byte", flip(len(storage) - 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := bytes.Clone(storage)
+			tt.corrupt(corrupted)
+
+			_, err := NewWithSharedStorage(corrupted)
+			if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+				t.Errorf("NewWithSharedStorage() error = %v, want checksum mismatch", err)
+			}
+			if _, err := NewFromReader(bytes.NewReader(corrupted)); err == nil {
+				t.Error("NewFromReader() succeeded on corrupted storage")
+			}
+			if _, err := NewWithSharedStorage(corrupted, SkipChecksum()); err != nil {
+				t.Errorf("NewWithSharedStorage(SkipChecksum) failed: %v", err)
+			}
+		})
+	}
+
+	t.Run("trailing data", func(t *testing.T) {
+		padded := append(bytes.Clone(storage), 0xFF, 0xFF)
+		loaded, err := NewWithSharedStorage(padded)
+		if err != nil {
+			t.Fatalf("NewWithSharedStorage failed: %v", err)
+		}
+		if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.1")); got == "" {
+			t.Errorf("Lookup(10.1.2.1) = %q, want a value", got)
+		}
+	})
+}
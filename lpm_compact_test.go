@@ -0,0 +1,201 @@
+package lpm
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestCompactAggregates tests that a block fully covered by prefixes with the same value collapses
+func TestCompactAggregates(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	for i := range 256 {
+		lpm.Insert(netip.MustParsePrefix(fmt.Sprintf("10.1.%d.0/24", i)), "dc1")
+	}
+	lpm.Insert(netip.MustParsePrefix("10.2.3.0/24"), "dc2")
+
+	before := lpm.Stats().IPv4Blocks
+	if removed := lpm.Compact(); removed != 1 {
+		t.Errorf("Compact() = %d, want 1", removed)
+	}
+	if after := lpm.Stats().IPv4Blocks; after != before-1 {
+		t.Errorf("IPv4Blocks after Compact = %d, want %d", after, before-1)
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.1.0.1", "dc1"},
+		{"10.1.255.1", "dc1"},
+		{"10.2.3.4", "dc2"},
+		{"10.2.4.4", "private"},
+		{"10.3.0.1", "private"},
+	}
+	for _, tt := range tests {
+		if got, _ := lpm.Lookup(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+
+	want := []PrefixValue{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "private"},
+		{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Value: "dc1"},
+		{Prefix: netip.MustParsePrefix("10.2.3.0/24"), Value: "dc2"},
+	}
+	entries := lpm.Entries()
+	if len(entries) != len(want) {
+		t.Fatalf("Entries() = %v, want %v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("Entries()[%d] = %v, want %v", i, entries[i], want[i])
+		}
+	}
+
+	// Nothing is left to collapse
+	if removed := lpm.Compact(); removed != 0 {
+		t.Errorf("second Compact() = %d, want 0", removed)
+	}
+}
+
+// TestCompactRedundantPrefix tests that a more specific prefix repeating its parent's value collapses
+func TestCompactRedundantPrefix(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	lpm.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "doc")
+	lpm.Insert(netip.MustParsePrefix("192.0.2.0/24"), "test-net")
+	lpm.Insert(netip.MustParsePrefix("192.0.2.128/25"), "test-net")
+
+	if removed := lpm.Compact(); removed != 3 {
+		t.Errorf("Compact() = %d, want 3", removed)
+	}
+
+	for _, addr := range []string{"2001:db8:1::1", "2001:db8:2::1"} {
+		if got, _ := lpm.Lookup(netip.MustParseAddr(addr)); got != "doc" {
+			t.Errorf("Lookup(%s) = %q, want doc", addr, got)
+		}
+	}
+	for _, addr := range []string{"192.0.2.1", "192.0.2.200"} {
+		if got, _ := lpm.Lookup(netip.MustParseAddr(addr)); got != "test-net" {
+			t.Errorf("Lookup(%s) = %q, want test-net", addr, got)
+		}
+	}
+	if entries := lpm.Entries(); len(entries) != 2 {
+		t.Errorf("Entries() = %v, want the /24 and the /32 only", entries)
+	}
+
+	// The trie stays usable for inserts
+	lpm.Insert(netip.MustParsePrefix("192.0.2.64/26"), "lab")
+	if got, _ := lpm.Lookup(netip.MustParseAddr("192.0.2.65")); got != "lab" {
+		t.Errorf("Lookup(192.0.2.65) after Insert = %q, want lab", got)
+	}
+	if got, _ := lpm.Lookup(netip.MustParseAddr("192.0.2.129")); got != "test-net" {
+		t.Errorf("Lookup(192.0.2.129) after Insert = %q, want test-net", got)
+	}
+}
+
+// TestCompactRandom tests that compaction never changes lookup results, including after a pack round trip
+func TestCompactRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	build := func() *LPM {
+		rng.Seed(1)
+		lpm := New()
+		for range 2000 {
+			addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+			lpm.Insert(netip.PrefixFrom(addr, 8+rng.Intn(25)).Masked(), fmt.Sprintf("v%d", rng.Intn(3)))
+		}
+		return lpm
+	}
+
+	original := build()
+	compacted := build()
+	removed := compacted.Compact()
+	if removed == 0 {
+		t.Fatal("Compact() removed nothing")
+	}
+	if got, want := compacted.Stats().IPv4Blocks, original.Stats().IPv4Blocks-removed; got != want {
+		t.Errorf("IPv4Blocks = %d, want %d", got, want)
+	}
+
+	storage, err := compacted.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	for range 20000 {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(5)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		want, wantOK := original.Lookup(addr)
+		if got, ok := compacted.Lookup(addr); got != want || ok != wantOK {
+			t.Fatalf("compacted Lookup(%s) = %q, %v, want %q, %v", addr, got, ok, want, wantOK)
+		}
+		if got, ok := loaded.Lookup(addr); got != want || ok != wantOK {
+			t.Fatalf("loaded Lookup(%s) = %q, %v, want %q, %v", addr, got, ok, want, wantOK)
+		}
+	}
+}
+
+// TestCompactSharedStorage tests compacting a trie loaded from shared storage
+func TestCompactSharedStorage(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "private")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	original := string(storage)
+
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if removed := loaded.Compact(); removed != 1 {
+		t.Errorf("Compact() = %d, want 1", removed)
+	}
+	if string(storage) != original {
+		t.Error("Compact modified shared storage")
+	}
+
+	// IPv4 blocks moved to dynamic memory, IPv6 had nothing to collapse and stays shared
+	if len(loaded.shared[v4LPM]) != 0 || len(loaded.shared[v6LPM]) == 0 {
+		t.Errorf("shared blocks = %d IPv4, %d IPv6, want 0 IPv4 and the IPv6 blocks kept",
+			len(loaded.shared[v4LPM]), len(loaded.shared[v6LPM]))
+	}
+	for _, tt := range []struct{ addr, want string }{{"10.1.2.3", "private"}, {"2001:db8::1", "doc"}} {
+		if got, _ := loaded.Lookup(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// TestCompactNumericTags tests compaction of the integer and tag tries
+func TestCompactNumericTags(t *testing.T) {
+	numeric := NewU32()
+	numeric.Insert(netip.MustParsePrefix("10.0.0.0/8"), 64500)
+	numeric.Insert(netip.MustParsePrefix("10.1.0.0/16"), 64500)
+	if removed := numeric.Compact(); removed != 1 {
+		t.Errorf("Numeric.Compact() = %d, want 1", removed)
+	}
+	if got, _ := numeric.Lookup(netip.MustParseAddr("10.1.2.3")); got != 64500 {
+		t.Errorf("Numeric.Lookup(10.1.2.3) = %d, want 64500", got)
+	}
+
+	tags := NewTags()
+	tags.Add(netip.MustParsePrefix("10.0.0.0/8"), tagInternal)
+	tags.Add(netip.MustParsePrefix("10.1.2.0/24"), tagInternal)
+	tags.Add(netip.MustParsePrefix("10.2.0.0/16"), tagCloud)
+	if removed := tags.Compact(); removed != 1 {
+		t.Errorf("Tags.Compact() = %d, want 1", removed)
+	}
+	if got := tags.LookupTags(netip.MustParseAddr("10.1.2.3")); got != tagInternal {
+		t.Errorf("LookupTags(10.1.2.3) = %04b, want %04b", got, tagInternal)
+	}
+	if got := tags.LookupTags(netip.MustParseAddr("10.2.2.3")); got != tagInternal|tagCloud {
+		t.Errorf("LookupTags(10.2.2.3) = %04b, want %04b", got, tagInternal|tagCloud)
+	}
+}
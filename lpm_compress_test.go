@@ -0,0 +1,82 @@
+package lpm
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPackCompressed tests that compressed storage is detected and loaded by every loader
+func TestPackCompressed(t *testing.T) {
+	lpm := newPackTestLPM()
+	plain, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := lpm.PackCompressed(&buf)
+	if err != nil {
+		t.Fatalf("PackCompressed failed: %v", err)
+	}
+	compressed := buf.Bytes()
+	if n != int64(len(compressed)) {
+		t.Errorf("PackCompressed reported %d bytes, wrote %d", n, len(compressed))
+	}
+	if len(compressed) > len(plain)/10 {
+		t.Errorf("compressed storage is %d bytes, uncompressed %d", len(compressed), len(plain))
+	}
+
+	path := filepath.Join(t.TempDir(), "table.lpm.zst")
+	if err := os.WriteFile(path, compressed, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	loaders := map[string]func() (*LPM, error){
+		"NewWithSharedStorage": func() (*LPM, error) { return NewWithSharedStorage(compressed) },
+		"NewFromReader":        func() (*LPM, error) { return NewFromReader(bytes.NewReader(compressed)) },
+		"LoadFromFile":         func() (*LPM, error) { return LoadFromFile(path) },
+	}
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			loaded, err := load()
+			if err != nil {
+				t.Fatalf("loading compressed storage failed: %v", err)
+			}
+			repacked, err := loaded.PackToSharedStorage()
+			if err != nil {
+				t.Fatalf("PackToSharedStorage failed: %v", err)
+			}
+			if !bytes.Equal(repacked, plain) {
+				t.Error("compressed storage loads differently from the uncompressed one")
+			}
+			if value, found := loaded.Lookup(netip.MustParseAddr("2001:db8:1::1")); !found || value != "doc-subnet" {
+				t.Errorf("Lookup(2001:db8:1::1) = %q, %v, want doc-subnet, true", value, found)
+			}
+		})
+	}
+}
+
+// TestPackCompressedCorrupted tests that damaged compressed storage is rejected
+func TestPackCompressedCorrupted(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := newPackTestLPM().PackCompressed(&buf); err != nil {
+		t.Fatalf("PackCompressed failed: %v", err)
+	}
+	compressed := buf.Bytes()
+
+	if _, err := NewWithSharedStorage(compressed[:len(compressed)/2]); err == nil {
+		t.Error("NewWithSharedStorage accepted truncated compressed storage")
+	}
+	if _, err := NewFromReader(bytes.NewReader(compressed[:len(compressed)/2])); err == nil {
+		t.Error("NewFromReader accepted truncated compressed storage")
+	}
+
+	corrupted := bytes.Clone(compressed)
+	corrupted[len(corrupted)/2] ^= 0xFF
+	if _, err := NewWithSharedStorage(corrupted); err == nil {
+		t.Error("NewWithSharedStorage accepted corrupted compressed storage")
+	}
+}
@@ -0,0 +1,122 @@
+package lpm
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestCopyOnWrite tests that inserts over shared storage never modify it and match a dynamic trie
+func TestCopyOnWrite(t *testing.T) {
+	base := func() *LPM {
+		lpm := New()
+		lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+		lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1")
+		lpm.Insert(netip.MustParsePrefix("10.1.2.0/24"), "rack")
+		lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+		lpm.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "doc-subnet")
+		return lpm
+	}
+	overrides := func(lpm *LPM) {
+		lpm.Insert(netip.MustParsePrefix("10.1.2.128/25"), "override")
+		lpm.Insert(netip.MustParsePrefix("10.1.3.0/24"), "new-rack")
+		lpm.InsertWithPriority(netip.MustParsePrefix("10.1.0.0/16"), "pinned", 1)
+		lpm.InsertTombstone(netip.MustParsePrefix("10.2.0.0/16"))
+		lpm.Insert(netip.MustParsePrefix("192.0.2.0/24"), "test-net")
+		lpm.Insert(netip.MustParsePrefix("2001:db8:1:2::/64"), "doc-64")
+	}
+
+	storage, err := base().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	original := bytes.Clone(storage)
+
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	overrides(loaded)
+	if !bytes.Equal(storage, original) {
+		t.Fatal("inserts modified shared storage")
+	}
+
+	want := base()
+	overrides(want)
+
+	repacked, err := loaded.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage after inserts failed: %v", err)
+	}
+	reloaded, err := NewWithSharedStorage(repacked)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage(repacked) failed: %v", err)
+	}
+	if got, want := reloaded.Stats().IPv4Blocks, want.Stats().IPv4Blocks; got != want {
+		t.Errorf("repacked IPv4Blocks = %d, want %d without the replaced shared blocks", got, want)
+	}
+
+	untouched, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	addrs := []string{"10.0.0.1", "10.1.0.1", "10.1.2.1", "10.1.2.200", "10.1.3.1", "10.2.0.1",
+		"192.0.2.1", "2001:db8::1", "2001:db8:1::1", "2001:db8:1:2::1", "2001:db9::1"}
+	for _, addr := range addrs {
+		addr := netip.MustParseAddr(addr)
+		wantValue, wantFound := want.Lookup(addr)
+		if got, found := loaded.Lookup(addr); got != wantValue || found != wantFound {
+			t.Errorf("Lookup(%s) = %q, %v, want %q, %v", addr, got, found, wantValue, wantFound)
+		}
+		if got, found := reloaded.Lookup(addr); got != wantValue || found != wantFound {
+			t.Errorf("repacked Lookup(%s) = %q, %v, want %q, %v", addr, got, found, wantValue, wantFound)
+		}
+		baseValue, baseFound := base().Lookup(addr)
+		if got, found := untouched.Lookup(addr); got != baseValue || found != baseFound {
+			t.Errorf("untouched Lookup(%s) = %q, %v, want %q, %v", addr, got, found, baseValue, baseFound)
+		}
+	}
+}
+
+// TestCopyOnWriteRandom tests random inserts over shared storage against a dynamic trie
+func TestCopyOnWriteRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomPrefix := func() netip.Prefix {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		return netip.PrefixFrom(addr, 8+rng.Intn(25)).Masked()
+	}
+
+	want := New()
+	for range 500 {
+		want.Insert(randomPrefix(), fmt.Sprintf("base%d", rng.Intn(5)))
+	}
+	storage, err := want.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	original := bytes.Clone(storage)
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	for range 500 {
+		prefix, value := randomPrefix(), fmt.Sprintf("local%d", rng.Intn(5))
+		want.Insert(prefix, value)
+		loaded.Insert(prefix, value)
+	}
+	if !bytes.Equal(storage, original) {
+		t.Fatal("inserts modified shared storage")
+	}
+
+	for range 10000 {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(5)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		wantValue, wantFound := want.Lookup(addr)
+		if got, found := loaded.Lookup(addr); got != wantValue || found != wantFound {
+			t.Fatalf("Lookup(%s) = %q, %v, want %q, %v", addr, got, found, wantValue, wantFound)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestLoadCSV tests reading columns, headers, comments and malformed rows
+func TestLoadCSV(t *testing.T) {
+	input := "network,geoname_id,country\n" +
+		"# comment\n" +
+		"10.0.0.0/8,1,\"Private, RFC 1918\"\n" +
+		"10.1.0.0/16,2,East\n" +
+		"not-a-prefix,3,Bogus\n" +
+		"2001:db8::/32,4\n" +
+		"2001:db8::/32,5,Doc\n"
+
+	var malformed []int
+	lpm, err := LoadCSV(strings.NewReader(input), CSVOptions{
+		ValueColumn: 2,
+		Comment:     '#',
+		Header:      true,
+		Malformed: func(line int, err error) error {
+			malformed = append(malformed, line)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if len(malformed) != 2 || malformed[0] != 5 || malformed[1] != 6 {
+		t.Errorf("malformed lines = %v, want [5 6]", malformed)
+	}
+	for addr, want := range map[string]string{"10.2.0.1": "Private, RFC 1918", "10.1.2.3": "East", "2001:db8::1": "Doc"} {
+		if got, _ := lpm.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	_, err = LoadCSV(strings.NewReader(input), CSVOptions{ValueColumn: 2, Comment: '#', Header: true})
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("LoadCSV without Malformed error = %v, want one for line 5", err)
+	}
+	stop := errors.New("stop")
+	if _, err := LoadCSV(strings.NewReader("10.0.0.0/8,a\n\"bad,b\n"), CSVOptions{
+		Malformed: func(int, error) error { return stop },
+	}); !errors.Is(err, stop) {
+		t.Errorf("LoadCSV error = %v, want the error of Malformed", err)
+	}
+
+	semicolons, err := LoadCSV(strings.NewReader("a;10.0.0.0/8\n"), CSVOptions{CIDRColumn: 1, ValueColumn: 0, Comma: ';'})
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if got, _ := semicolons.Lookup(netip.MustParseAddr("10.0.0.1")); got != "a" {
+		t.Errorf("Lookup(10.0.0.1) = %q, want a", got)
+	}
+}
+
+// TestLoadCSVProgress tests the progress callback
+func TestLoadCSVProgress(t *testing.T) {
+	var input strings.Builder
+	for i := range 250 {
+		fmt.Fprintf(&input, "10.%d.0.0/16,v%d\n", i, i%7)
+	}
+	var progress []int
+	if _, err := LoadCSV(strings.NewReader(input.String()), CSVOptions{
+		Progress:      func(rows int) { progress = append(progress, rows) },
+		ProgressEvery: 100,
+	}); err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if want := []int{100, 200, 250}; fmt.Sprint(progress) != fmt.Sprint(want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
+
+// TestWriteCSV tests that the written table loads back answering every lookup the same
+func TestWriteCSV(t *testing.T) {
+	lpm := newPackTestLPM()
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.128/25")); err != nil {
+		t.Fatal(err)
+	}
+	if err := lpm.Insert(netip.MustParsePrefix("192.0.2.0/24"), "quoted \"value\", with comma"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := lpm.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	loaded, err := LoadCSV(&buf, CSVOptions{})
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if loaded.Fingerprint() != lpm.Fingerprint() {
+		t.Error("table loaded from WriteCSV output differs from the original")
+	}
+
+	if err := lpm.WriteCSV(&failingWriter{limit: 8}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("WriteCSV to a failing writer error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+// BenchmarkLoadCSV measures loading a table of /24 rows
+func BenchmarkLoadCSV(b *testing.B) {
+	var input bytes.Buffer
+	for i := range 1 << 16 {
+		fmt.Fprintf(&input, "%d.%d.%d.0/24,value-%d\n", 1+i>>16, byte(i>>8), byte(i), i%1000)
+	}
+	b.SetBytes(int64(input.Len()))
+	for b.Loop() {
+		if _, err := LoadCSV(bytes.NewReader(input.Bytes()), CSVOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// TestDelete tests that deleting a prefix restores the broader prefixes around it
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		inserts []string // prefix, value pairs, an empty value inserts a tombstone
+		delete  string
+		deleted bool
+		want    map[string]string // address -> value, empty if no match
+	}{
+		{
+			name:    "nested falls back to broader",
+			inserts: []string{"10.0.0.0/8", "private", "10.1.0.0/16", "dc1", "10.1.2.0/24", "rack"},
+			delete:  "10.1.0.0/16",
+			deleted: true,
+			want:    map[string]string{"10.1.0.1": "private", "10.1.2.1": "rack", "10.2.0.1": "private"},
+		},
+		{
+			name:    "broader keeps nested",
+			inserts: []string{"10.0.0.0/8", "private", "10.1.0.0/16", "dc1"},
+			delete:  "10.0.0.0/8",
+			deleted: true,
+			want:    map[string]string{"10.1.0.1": "dc1", "10.2.0.1": ""},
+		},
+		{
+			name:    "unaligned prefixes",
+			inserts: []string{"10.0.0.0/9", "low", "10.0.0.0/12", "low12", "10.0.0.0/20", "low20"},
+			delete:  "10.0.0.0/12",
+			deleted: true,
+			want:    map[string]string{"10.0.0.1": "low20", "10.0.16.1": "low", "10.15.0.1": "low", "10.128.0.1": ""},
+		},
+		{
+			name:    "tombstone",
+			inserts: []string{"10.0.0.0/8", "private", "10.1.0.0/16", ""},
+			delete:  "10.1.0.0/16",
+			deleted: true,
+			want:    map[string]string{"10.1.0.1": "private"},
+		},
+		{
+			name:    "default route",
+			inserts: []string{"0.0.0.0/0", "default", "10.0.0.0/8", "private"},
+			delete:  "0.0.0.0/0",
+			deleted: true,
+			want:    map[string]string{"10.0.0.1": "private", "192.0.2.1": ""},
+		},
+		{
+			name:    "unmasked prefix",
+			inserts: []string{"10.0.0.0/8", "private", "10.1.0.0/16", "dc1"},
+			delete:  "10.1.2.3/16",
+			deleted: true,
+			want:    map[string]string{"10.1.0.1": "private"},
+		},
+		{
+			name:    "IPv6",
+			inserts: []string{"2001:db8::/32", "doc", "2001:db8:1::/48", "doc-subnet"},
+			delete:  "2001:db8:1::/48",
+			deleted: true,
+			want:    map[string]string{"2001:db8:1::1": "doc", "2001:db8::1": "doc"},
+		},
+		{
+			name:    "absent nested prefix",
+			inserts: []string{"10.0.0.0/8", "private", "10.1.0.0/16", "dc1"},
+			delete:  "10.1.2.0/24",
+			want:    map[string]string{"10.1.2.1": "dc1", "10.2.0.1": "private"},
+		},
+		{
+			name:    "absent broader prefix",
+			inserts: []string{"10.1.0.0/16", "dc1"},
+			delete:  "10.0.0.0/8",
+			want:    map[string]string{"10.1.0.1": "dc1"},
+		},
+		{
+			name:    "overridden prefix of the same length",
+			inserts: []string{"10.1.0.0/16", "dc1", "10.1.0.0/16", "dc2"},
+			delete:  "10.1.0.0/16",
+			deleted: true,
+			want:    map[string]string{"10.1.0.1": ""},
+		},
+		{
+			name: "fully shadowed broader prefix",
+			inserts: []string{"10.0.0.0/8", "private", "10.0.0.0/15", "pair",
+				"10.0.0.0/16", "dc0", "10.1.0.0/16", "dc1"},
+			delete:  "10.0.0.0/16",
+			deleted: true,
+			want:    map[string]string{"10.0.0.1": "pair", "10.1.0.1": "dc1", "10.2.0.1": "private"},
+		},
+		{
+			name:    "sibling of a covered prefix",
+			inserts: []string{"10.0.0.0/24", "A", "10.0.0.0/25", "B", "10.0.0.128/25", "C"},
+			delete:  "10.0.0.0/25",
+			deleted: true,
+			want:    map[string]string{"10.0.0.1": "A", "10.0.0.129": "C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lpm := New()
+			for i := 0; i < len(tt.inserts); i += 2 {
+				prefix := netip.MustParsePrefix(tt.inserts[i])
+				if tt.inserts[i+1] == "" {
+					lpm.InsertTombstone(prefix)
+				} else {
+					lpm.Insert(prefix, tt.inserts[i+1])
+				}
+			}
+
+			generation := lpm.Generation()
+			deleted, err := lpm.Delete(netip.MustParsePrefix(tt.delete))
+			if err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if deleted != tt.deleted {
+				t.Errorf("Delete(%s) = %v, want %v", tt.delete, deleted, tt.deleted)
+			}
+			if bumped := lpm.Generation() != generation; bumped != tt.deleted {
+				t.Errorf("Generation bumped = %v, want %v", bumped, tt.deleted)
+			}
+
+			for addr, want := range tt.want {
+				got, found := lpm.Lookup(netip.MustParseAddr(addr))
+				if got != want || found != (want != "") {
+					t.Errorf("Lookup(%s) = %q, %v, want %q", addr, got, found, want)
+				}
+			}
+
+			if deleted, _ := lpm.Delete(netip.MustParsePrefix(tt.delete)); deleted {
+				t.Errorf("second Delete(%s) reported a deletion", tt.delete)
+			}
+		})
+	}
+}
+
+// TestDeletePriority tests that deletes uncover prefixes a higher priority shadowed, and
+// that inserting a prefix again with a lower priority uncovers them as well
+func TestDeletePriority(t *testing.T) {
+	broad, specific := netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.1.0.0/16")
+	check := func(step string, m *LPM, want map[string]string) {
+		t.Helper()
+		for addr, value := range want {
+			got, found := m.Lookup(netip.MustParseAddr(addr))
+			if got != value || found != (value != "") {
+				t.Errorf("%s: Lookup(%s) = %q, %v, want %q", step, addr, got, found, value)
+			}
+		}
+		if err := m.Verify(); err != nil {
+			t.Errorf("%s: Verify() = %v", step, err)
+		}
+	}
+
+	m := New()
+	m.InsertWithPriority(broad, "high", 5)
+	m.Insert(specific, "low")
+	// The shadowed value must survive dropping the values no slot refers to
+	m.CompactValues()
+	if deleted, _ := m.Delete(broad); !deleted {
+		t.Fatalf("Delete(%s) = false, want true", broad)
+	}
+	check("high priority prefix deleted", m, map[string]string{"10.1.0.1": "low", "10.2.0.1": ""})
+
+	m.InsertWithPriority(broad, "high", 5)
+	if deleted, _ := m.Delete(specific); !deleted {
+		t.Fatalf("Delete(%s) = false, want true", specific)
+	}
+	check("shadowed prefix deleted", m, map[string]string{"10.1.0.1": "high"})
+	if deleted, _ := m.Delete(specific); deleted {
+		t.Errorf("second Delete(%s) reported a deletion", specific)
+	}
+
+	m.Insert(specific, "low")
+	m.Insert(broad, "demoted")
+	check("priority lowered", m, map[string]string{"10.1.0.1": "low", "10.2.0.1": "demoted"})
+}
+
+// TestDeleteSharedStorage tests that deleting from shared storage copies the blocks it changes
+func TestDeleteSharedStorage(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	original := bytes.Clone(storage)
+
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if deleted, err := loaded.Delete(netip.MustParsePrefix("10.1.2.0/24")); !deleted || err != nil {
+		t.Fatalf("Delete = %v, %v, want true, nil", deleted, err)
+	}
+	if !bytes.Equal(storage, original) {
+		t.Fatal("Delete modified shared storage")
+	}
+	if value, found := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); found {
+		t.Errorf("Lookup(10.1.2.3) after Delete = %q, want no match", value)
+	}
+	if value, found := loaded.Lookup(netip.MustParseAddr("10.1.3.3")); !found || value != "DC0" {
+		t.Errorf("Lookup(10.1.3.3) = %q, %v, want DC0, true", value, found)
+	}
+
+	readOnly, err := NewWithSharedStorage(storage, ReadOnly())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if _, err := readOnly.Delete(netip.MustParsePrefix("10.1.2.0/24")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete on a read-only LPM error = %v, want %v", err, ErrReadOnly)
+	}
+}
+
+// TestNumericDelete tests deleting from a Numeric trie
+func TestNumericDelete(t *testing.T) {
+	m := NewU32()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2)
+
+	if !m.Delete(netip.MustParsePrefix("10.1.0.0/16")) {
+		t.Fatal("Delete(10.1.0.0/16) = false, want true")
+	}
+	if value, found := m.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != 1 {
+		t.Errorf("Lookup(10.1.2.3) = %d, %v, want 1, true", value, found)
+	}
+	if m.Delete(netip.Prefix{}) {
+		t.Error("Delete(invalid prefix) = true, want false")
+	}
+}
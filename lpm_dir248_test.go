@@ -0,0 +1,94 @@
+package lpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestDIR248 tests that the DIR-24-8 table answers lookups like the trie
+func TestDIR248(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	m := New()
+	for _, e := range randomEntries(rng, 500) {
+		if e.Tombstone {
+			m.InsertTombstone(e.Prefix)
+		} else {
+			m.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+		}
+	}
+	m.Insert(netip.MustParsePrefix("192.168.0.0/16"), "wide")
+	d := m.DIR248()
+
+	if len(d.TBL8)%256 != 0 || len(d.TBL8) == 0 {
+		t.Fatalf("TBL8 of %d entries, want whole groups", len(d.TBL8))
+	}
+	for range 100000 {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(4)), byte(rng.Intn(256))})
+		if rng.Intn(10) == 0 {
+			addr = netip.AddrFrom4([4]byte{192, 168, byte(rng.Intn(256)), byte(rng.Intn(256))})
+		}
+		wantValue, wantFound := m.Lookup(addr)
+		if value, found := d.Lookup(addr); value != wantValue || found != wantFound {
+			t.Fatalf("Lookup(%s) = %q, %v, want %q, %v", addr, value, found, wantValue, wantFound)
+		}
+	}
+	if _, found := d.Lookup(netip.MustParseAddr("2001:db8::1")); found {
+		t.Error("IPv6 lookup found a value")
+	}
+}
+
+// TestDIR248WriteRead tests that written tables read back, also byte-swapped
+func TestDIR248WriteRead(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	m.Insert(netip.MustParsePrefix("10.1.2.128/25"), "b")
+	m.InsertTombstone(netip.MustParsePrefix("10.1.3.0/24"))
+	d := m.DIR248()
+
+	var buf bytes.Buffer
+	n, err := d.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if want := int64(dir248HeaderSize + 4*(1<<24+256) + 4 + 1 + 4 + 1); n != want || int64(buf.Len()) != want {
+		t.Fatalf("WriteTo wrote %d bytes (reported %d), want %d", buf.Len(), n, want)
+	}
+
+	// Swap every word of the header and tables, as written by a host of the other byte order
+	swapped := bytes.Clone(buf.Bytes())
+	for i := 4; i < dir248HeaderSize+4*(1<<24+256); i += 4 {
+		binary.BigEndian.PutUint32(swapped[i:], binary.LittleEndian.Uint32(swapped[i:]))
+	}
+	// Both values are a length and a single byte
+	for i := dir248HeaderSize + 4*(1<<24+256); i < len(swapped); i += 5 {
+		binary.BigEndian.PutUint32(swapped[i:], binary.LittleEndian.Uint32(swapped[i:]))
+	}
+
+	for name, blob := range map[string][]byte{"native": buf.Bytes(), "swapped": swapped} {
+		read, err := ReadDIR248(bytes.NewReader(blob))
+		if err != nil {
+			t.Fatalf("%s: ReadDIR248 failed: %v", name, err)
+		}
+		for addr, want := range map[string]string{"10.1.2.3": "a", "10.1.2.200": "b", "10.1.3.1": "", "11.0.0.0": ""} {
+			if got, _ := read.Lookup(netip.MustParseAddr(addr)); got != want {
+				t.Errorf("%s: Lookup(%s) = %q, want %q", name, addr, got, want)
+			}
+		}
+	}
+
+	corrupt := bytes.Clone(buf.Bytes())
+	binary.NativeEndian.PutUint32(corrupt[dir248HeaderSize+4*0x0a0000:], 7)
+	if _, err := ReadDIR248(bytes.NewReader(corrupt)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("ReadDIR248 of an entry beyond the values: %v, want ErrCorrupt", err)
+	}
+	if _, err := ReadDIR248(bytes.NewReader(buf.Bytes()[:1000])); err == nil {
+		t.Error("ReadDIR248 of a truncated table succeeded")
+	}
+	if _, err := ReadDIR248(bytes.NewReader([]byte("LPM!0000000000000000000000"))); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("ReadDIR248 of another format: %v, want ErrBadMagic", err)
+	}
+}
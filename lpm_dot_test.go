@@ -0,0 +1,69 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestWriteDOT tests that blocks, block references and value leaves are rendered
+func TestWriteDOT(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), `say "b"`)
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "c")
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	var buf bytes.Buffer
+	if err := lpm.WriteDOT(&buf, DOTOptions{}); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+	dot := buf.String()
+	for _, want := range []string{
+		"digraph lpm {",
+		`v4_b0 [label="v4 block 0, byte 0|<s0> 0-9: invalid|<s10> 10: block ref|`,
+		`-> v4_v0 [label="/8"]`,
+		`[shape=ellipse, label="1: say \"b\""]`,
+		`[shape=ellipse, label="tombstone"]`,
+		`v6_b0 [label="v6 block 0, byte 0|`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("WriteDOT output lacks %q:\n%s", want, dot)
+		}
+	}
+	if !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("WriteDOT output is not terminated:\n%s", dot)
+	}
+
+	buf.Reset()
+	if err := lpm.WriteDOT(&buf, DOTOptions{Prefix: netip.MustParsePrefix("10.1.0.0/16")}); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+	sub := buf.String()
+	if strings.Contains(sub, "v6_") || strings.Contains(sub, "v4_b0 ") || strings.Contains(sub, `"c"`) {
+		t.Errorf("WriteDOT of 10.1.0.0/16 renders blocks outside the prefix:\n%s", sub)
+	}
+	if !strings.Contains(sub, "|<s1> 1: block ref\"]") || !strings.Contains(sub, `label="tombstone"`) {
+		t.Errorf("WriteDOT of 10.1.0.0/16 lacks the slot of the prefix or the blocks below it:\n%s", sub)
+	}
+
+	buf.Reset()
+	if err := lpm.WriteDOT(&buf, DOTOptions{MaxDepth: 1}); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+	if shallow := buf.String(); strings.Contains(shallow, "byte 1") {
+		t.Errorf("WriteDOT with MaxDepth 1 renders the second level:\n%s", shallow)
+	}
+}
+
+// TestWriteDOTError tests that write errors are reported
+func TestWriteDOTError(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	if err := lpm.WriteDOT(&failingWriter{limit: 10}, DOTOptions{}); !errors.Is(err, errWriteFailed) {
+		t.Errorf("WriteDOT error = %v, want %v", err, errWriteFailed)
+	}
+}
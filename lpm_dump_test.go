@@ -0,0 +1,121 @@
+package lpm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestDump tests that the effective table is disjoint, merged and sorted
+func TestDump(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	lpm.Insert(netip.MustParsePrefix("10.128.0.0/9"), "a")
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	lpm.Insert(netip.MustParsePrefix("192.169.0.0/16"), "c d")
+	lpm.InsertWithPriority(netip.MustParsePrefix("192.168.0.0/16"), "c d", 1)
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	var buf bytes.Buffer
+	if err := lpm.Dump(&buf); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	want := `10.0.0.0/16 "a"
+10.1.0.0/23 "b"
+10.1.3.0/24 "b"
+10.1.4.0/22 "b"
+10.1.8.0/21 "b"
+10.1.16.0/20 "b"
+10.1.32.0/19 "b"
+10.1.64.0/18 "b"
+10.1.128.0/17 "b"
+10.2.0.0/15 "a"
+10.4.0.0/14 "a"
+10.8.0.0/13 "a"
+10.16.0.0/12 "a"
+10.32.0.0/11 "a"
+10.64.0.0/10 "a"
+10.128.0.0/9 "a"
+192.168.0.0/15 "c d"
+2001:db8::/32 "v6"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Dump() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestDumpRandom tests that dumped lines are disjoint and reproduce the lookups of the trie
+func TestDumpRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := range 10 {
+		lpm := New()
+		for _, e := range randomEntries(rng, 300) {
+			if e.Tombstone {
+				lpm.InsertTombstone(e.Prefix)
+			} else {
+				lpm.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+			}
+		}
+		var buf bytes.Buffer
+		if err := lpm.Dump(&buf); err != nil {
+			t.Fatalf("Dump failed: %v", err)
+		}
+
+		rebuilt := New()
+		var last netip.Addr
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			cidr, quoted, _ := strings.Cut(scanner.Text(), " ")
+			prefix := netip.MustParsePrefix(cidr)
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				t.Fatalf("round %d: bad line %q: %v", round, scanner.Text(), err)
+			}
+			if last.IsValid() && last.Is4() == prefix.Addr().Is4() && prefix.Addr().Compare(last) <= 0 {
+				t.Fatalf("round %d: %s overlaps or precedes the previous line", round, prefix)
+			}
+			last = lastAddr(prefix)
+			for _, addr := range []netip.Addr{prefix.Addr(), last} {
+				if got, _ := lpm.Lookup(addr); got != value {
+					t.Fatalf("round %d: line %q, but Lookup(%s) = %q", round, scanner.Text(), addr, got)
+				}
+			}
+			rebuilt.Insert(prefix, value)
+		}
+		if rebuilt.Fingerprint() != lpm.Fingerprint() {
+			t.Errorf("round %d: trie rebuilt from the dump answers lookups differently", round)
+		}
+	}
+}
+
+// TestEffectiveEntries tests that the effective entries are the lines of Dump
+func TestEffectiveEntries(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	lpm := New()
+	for _, e := range randomEntries(rng, 300) {
+		if e.Tombstone {
+			lpm.InsertTombstone(e.Prefix)
+		} else {
+			lpm.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+		}
+	}
+	var want, got bytes.Buffer
+	if err := lpm.Dump(&want); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	for _, e := range lpm.EffectiveEntries() {
+		if e.Tombstone || e.Priority != 0 {
+			t.Errorf("entry %s carries a tombstone or priority", e.Prefix)
+		}
+		fmt.Fprintf(&got, "%s %q\n", e.Prefix, e.Value)
+	}
+	if got.String() != want.String() {
+		t.Errorf("EffectiveEntries() =\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
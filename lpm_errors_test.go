@@ -0,0 +1,68 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+// TestSharedStorageErrors tests that loading failures can be told apart with errors.Is and errors.As
+func TestSharedStorageErrors(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header, _, err := readHeader(storage)
+	if err != nil {
+		t.Fatalf("readHeader failed: %v", err)
+	}
+
+	withHeader := func(modify func(*StorageHeader)) []byte {
+		corrupted := bytes.Clone(storage)
+		modify((*StorageHeader)(unsafe.Pointer(&corrupted[0])))
+		return corrupted
+	}
+
+	tests := []struct {
+		name    string
+		storage []byte
+		want    error
+	}{
+		{"bad magic", withHeader(func(h *StorageHeader) { h.Magic = 0xDEADBEEF }), ErrBadMagic},
+		{"future version", withHeader(func(h *StorageHeader) { h.Version = currentVersion + 1 }), ErrVersionMismatch},
+		{"old version", withHeader(func(h *StorageHeader) { h.Version = 0 }), ErrVersionMismatch},
+		{"bad byte order", withHeader(func(h *StorageHeader) { h.ByteOrder = 0 }), ErrBadByteOrder},
+		{"checksum", withHeader(func(h *StorageHeader) { h.Checksum++ }), ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWithSharedStorage(tt.storage); !errors.Is(err, tt.want) {
+				t.Errorf("NewWithSharedStorage() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	truncations := []struct {
+		size    int
+		section string
+	}{
+		{0, "header"},
+		{headerSize(versionV2) + 2, "header"},
+		{int(header.V4BlocksOffset) + 10, "IPv4 blocks"},
+		{int(header.V6BlocksOffset) + 10, "IPv6 blocks"},
+		{len(storage) - 1, "values"},
+	}
+	for _, tt := range truncations {
+		t.Run("truncated "+tt.section, func(t *testing.T) {
+			_, err := NewWithSharedStorage(storage[:tt.size])
+			var truncated *ErrTruncated
+			if !errors.As(err, &truncated) {
+				t.Fatalf("NewWithSharedStorage(%d bytes) error = %v, want ErrTruncated", tt.size, err)
+			}
+			if truncated.Section != tt.section || truncated.Got != tt.size || truncated.Need <= tt.size {
+				t.Errorf("ErrTruncated = %+v, want section %q, got %d", *truncated, tt.section, tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package lpm
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestExplain tests that the trace follows the blocks a lookup visits and agrees with Lookup
+func TestExplain(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+
+	trace := lpm.Explain(netip.MustParseAddr("10.1.3.4"))
+	if !trace.Found || trace.Value != "b" {
+		t.Errorf("Explain(10.1.3.4) = %q (found=%v), want b", trace.Value, trace.Found)
+	}
+	wantKinds := []SlotKind{SlotBlockRef, SlotBlockRef, SlotValue}
+	if len(trace.Steps) != len(wantKinds) {
+		t.Fatalf("Explain(10.1.3.4) took %d steps, want %d:\n%s", len(trace.Steps), len(wantKinds), trace)
+	}
+	for i, step := range trace.Steps {
+		if step.Kind != wantKinds[i] || step.Depth != i {
+			t.Errorf("step %d = %s at byte %d, want %s at byte %d", i, step.Kind, step.Depth, wantKinds[i], i)
+		}
+		if i > 0 && step.Block != trace.Steps[i-1].Child {
+			t.Errorf("step %d visits block %d, previous step refers to %d", i, step.Block, trace.Steps[i-1].Child)
+		}
+	}
+	last := trace.Steps[len(trace.Steps)-1]
+	if last.Prefix != netip.MustParsePrefix("10.1.0.0/16") || last.Slot != 3 {
+		t.Errorf("last step = %+v, want slot 3 of 10.1.0.0/16", last)
+	}
+	if wantIdx, _ := lpm.LookupIndex(netip.MustParseAddr("10.1.3.4")); last.ValueIndex != wantIdx {
+		t.Errorf("last step value index = %d, want %d", last.ValueIndex, wantIdx)
+	}
+	if s := trace.String(); !strings.Contains(s, `"b"`) || !strings.Contains(s, "of 10.1.0.0/16") {
+		t.Errorf("String() = %q, want the value and the prefix", s)
+	}
+
+	tests := []struct {
+		addr string
+		kind SlotKind
+	}{
+		{"10.1.2.3", SlotTombstone},
+		{"192.0.2.1", SlotInvalid},
+		{"2001:db8::1", SlotInvalid},
+	}
+	for _, tt := range tests {
+		trace := lpm.Explain(netip.MustParseAddr(tt.addr))
+		if trace.Found {
+			t.Errorf("Explain(%s) found %q", tt.addr, trace.Value)
+		}
+		if len(trace.Steps) == 0 || trace.Steps[len(trace.Steps)-1].Kind != tt.kind {
+			t.Errorf("Explain(%s) ends with %v, want %s", tt.addr, trace.Steps, tt.kind)
+		}
+	}
+
+	// Shared blocks are marked as such
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	for _, step := range loaded.Explain(netip.MustParseAddr("10.1.3.4")).Steps {
+		if !step.Shared {
+			t.Errorf("step %+v of loaded storage is not shared", step)
+		}
+	}
+}
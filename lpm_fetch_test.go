@@ -0,0 +1,103 @@
+package lpm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+)
+
+// TestFetchStorage tests that FetchStorage loads plain and compressed storage and skips
+// storage the server reports unchanged
+func TestFetchStorage(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten")
+	var plain, compressed bytes.Buffer
+	if _, err := m.PackTo(&plain); err != nil {
+		t.Fatalf("PackTo failed: %v", err)
+	}
+	if _, err := m.PackCompressed(&compressed); err != nil {
+		t.Fatalf("PackCompressed failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		storage []byte
+	}{{"plain", plain.Bytes()}, {"compressed", compressed.Bytes()}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var downloads atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					http.Error(w, "forbidden", http.StatusForbidden)
+					return
+				}
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				downloads.Add(1)
+				w.Header().Set("ETag", `"v1"`)
+				w.Write(tc.storage)
+			}))
+			defer srv.Close()
+
+			opts := &FetchOptions{Header: http.Header{"Authorization": {"Bearer secret"}}}
+			loaded, err := FetchStorage(context.Background(), srv.URL, opts)
+			if err != nil {
+				t.Fatalf("FetchStorage failed: %v", err)
+			}
+			if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); got != "ten" {
+				t.Errorf("Lookup(10.1.2.3) = %q, want %q", got, "ten")
+			}
+			if opts.ETag != `"v1"` {
+				t.Errorf("ETag = %q, want %q", opts.ETag, `"v1"`)
+			}
+
+			if _, err := FetchStorage(context.Background(), srv.URL, opts); !errors.Is(err, ErrNotModified) {
+				t.Errorf("second FetchStorage error = %v, want ErrNotModified", err)
+			}
+			if n := downloads.Load(); n != 1 {
+				t.Errorf("storage downloaded %d times, want 1", n)
+			}
+		})
+	}
+}
+
+// TestFetchStorageErrors tests that failed requests and corrupted storage are rejected and
+// leave the validators unchanged
+func TestFetchStorageErrors(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten")
+	storage, err := m.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	corrupted := bytes.Clone(storage)
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/corrupted":
+			w.Header().Set("ETag", `"v2"`)
+			w.Write(corrupted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	opts := &FetchOptions{ETag: `"v1"`}
+	if _, err := FetchStorage(context.Background(), srv.URL+"/corrupted", opts); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("FetchStorage(corrupted) error = %v, want ErrChecksumMismatch", err)
+	}
+	if opts.ETag != `"v1"` {
+		t.Errorf("ETag = %q after a failed fetch, want %q", opts.ETag, `"v1"`)
+	}
+	if _, err := FetchStorage(context.Background(), srv.URL+"/missing", nil); err == nil {
+		t.Error("FetchStorage(missing) succeeded, want an error")
+	}
+}
@@ -0,0 +1,128 @@
+package lpm
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestSaveLoadFile tests the file round trip and that saving replaces the previous file
+func TestSaveLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.lpm")
+
+	for i, want := range []string{"first", "second"} {
+		lpm := New()
+		lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), want)
+		if err := lpm.SaveToFile(path); err != nil {
+			t.Fatalf("SaveToFile #%d failed: %v", i, err)
+		}
+
+		loaded, err := LoadFromFile(path)
+		if err != nil {
+			t.Fatalf("LoadFromFile #%d failed: %v", i, err)
+		}
+		if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.1.1")); got != want {
+			t.Errorf("Lookup(10.1.1.1) = %q, want %q", got, want)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o644 {
+		t.Errorf("file mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the storage file", len(entries))
+	}
+}
+
+// TestSaveToFileFailure tests that a failed save keeps the previous file and removes the temporary one
+func TestSaveToFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.lpm")
+
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "kept")
+	if err := lpm.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), strings.Repeat("x", maxValueLen+1))
+	if err := lpm.SaveToFile(path); err == nil {
+		t.Fatal("SaveToFile with an oversized value succeeded")
+	}
+
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.1.1")); got != "kept" {
+		t.Errorf("Lookup(10.1.1.1) = %q, want kept", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the storage file", len(entries))
+	}
+
+	if _, err := LoadFromFile(filepath.Join(dir, "missing.lpm")); !os.IsNotExist(err) {
+		t.Errorf("LoadFromFile(missing) error = %v, want not exist", err)
+	}
+}
+
+// TestSaveLoadFileConcurrent tests that readers never observe a partially written file
+func TestSaveLoadFileConcurrent(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		t.Skip("file locking is not supported")
+	}
+	path := filepath.Join(t.TempDir(), "table.lpm")
+
+	small := New()
+	small.Insert(netip.MustParsePrefix("10.0.0.0/8"), "small")
+	large := newPackTestLPM()
+	large.Insert(netip.MustParsePrefix("10.0.0.0/8"), "large")
+	if err := small.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			lpm := small
+			if i%2 == 0 {
+				lpm = large
+			}
+			if err := lpm.SaveToFile(path, WithFileLock()); err != nil {
+				t.Errorf("SaveToFile failed: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			loaded, err := LoadFromFile(path, WithFileLock())
+			if err != nil {
+				t.Errorf("LoadFromFile failed: %v", err)
+				return
+			}
+			if got, _ := loaded.Lookup(netip.MustParseAddr("10.200.0.1")); got != "small" && got != "large" {
+				t.Errorf("Lookup(10.200.0.1) = %q, want small or large", got)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
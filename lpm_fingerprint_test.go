@@ -0,0 +1,159 @@
+package lpm
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+// TestFingerprint tests that tries with the same lookups have the same fingerprint
+func TestFingerprint(t *testing.T) {
+	build := func(prefixes ...string) *LPM {
+		lpm := New()
+		for i := 0; i < len(prefixes); i += 2 {
+			if prefixes[i+1] == "" {
+				lpm.InsertTombstone(netip.MustParsePrefix(prefixes[i]))
+			} else {
+				lpm.Insert(netip.MustParsePrefix(prefixes[i]), prefixes[i+1])
+			}
+		}
+		return lpm
+	}
+	base := build("10.0.0.0/8", "private", "10.1.0.0/16", "dc1", "2001:db8::/32", "doc")
+
+	same := map[string]*LPM{
+		"reordered":                  build("2001:db8::/32", "doc", "10.1.0.0/16", "dc1", "10.0.0.0/8", "private"),
+		"split":                      build("10.0.0.0/9", "private", "10.128.0.0/9", "private", "10.1.0.0/16", "dc1", "2001:db8::/32", "doc"),
+		"redundant":                  build("10.0.0.0/8", "private", "10.1.0.0/16", "dc1", "10.1.2.0/24", "dc1", "10.2.0.0/16", "private", "2001:db8::/32", "doc"),
+		"tombstone outside prefixes": build("10.0.0.0/8", "private", "10.1.0.0/16", "dc1", "2001:db8::/32", "doc", "192.0.2.0/24", ""),
+	}
+	prioritized := New()
+	prioritized.InsertWithPriority(netip.MustParsePrefix("10.1.0.0/16"), "dc1", 3)
+	prioritized.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	prioritized.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	same["priority"] = prioritized
+
+	compacted := build("10.0.0.0/8", "private", "10.1.0.0/16", "dc1", "10.1.2.0/24", "dc1", "2001:db8::/32", "doc")
+	if compacted.Compact() == 0 {
+		t.Fatal("Compact removed no blocks")
+	}
+	same["compacted"] = compacted
+
+	storage, err := build("10.0.0.0/8", "private", "2001:db8::/32", "doc").PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	shared.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1")
+	same["copy on write"] = shared
+
+	want := base.Fingerprint()
+	for name, lpm := range same {
+		if got := lpm.Fingerprint(); got != want {
+			t.Errorf("%s: Fingerprint() = %016x, want %016x", name, got, want)
+		}
+	}
+
+	different := map[string]*LPM{
+		"empty":          New(),
+		"other value":    build("10.0.0.0/8", "private", "10.1.0.0/16", "dc2", "2001:db8::/32", "doc"),
+		"tombstone":      build("10.0.0.0/8", "private", "10.1.0.0/16", "dc1", "2001:db8::/32", "doc", "10.1.2.0/24", ""),
+		"more specific":  build("10.0.0.0/8", "private", "10.1.0.0/17", "dc1", "2001:db8::/32", "doc"),
+		"no IPv6":        build("10.0.0.0/8", "private", "10.1.0.0/16", "dc1"),
+		"IPv6 only":      build("2001:db8::/32", "doc"),
+		"value to other": build("10.0.0.0/8", "private", "10.1.0.0/16", "private", "2001:db8::/32", "doc"),
+	}
+	seen := map[uint64]string{want: "base"}
+	for name, lpm := range different {
+		got := lpm.Fingerprint()
+		if other, ok := seen[got]; ok {
+			t.Errorf("%s: Fingerprint() = %016x, same as %s", name, got, other)
+		}
+		seen[got] = name
+	}
+
+	if New().Fingerprint() != build("192.0.2.0/24", "").Fingerprint() {
+		t.Error("a tombstone in an empty trie changed the fingerprint")
+	}
+}
+
+// TestGeneration tests that the generation grows with modifications and survives packing
+func TestGeneration(t *testing.T) {
+	lpm := New()
+	if gen := lpm.Generation(); gen != 0 {
+		t.Fatalf("Generation() of a new trie = %d, want 0", gen)
+	}
+
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	lpm.Insert(netip.MustParsePrefix("10.1.2.0/24"), "private")
+	lpm.InsertTombstone(netip.MustParsePrefix("192.0.2.0/24"))
+	if gen := lpm.Generation(); gen != 3 {
+		t.Errorf("Generation() after 3 inserts = %d, want 3", gen)
+	}
+	lpm.SetMetadata(MetadataDatasetVersion, "1")
+	lpm.DeleteMetadata("missing")
+	if gen := lpm.Generation(); gen != 4 {
+		t.Errorf("Generation() after SetMetadata = %d, want 4", gen)
+	}
+	lpm.Compact()
+	if gen := lpm.Generation(); gen != 5 {
+		t.Errorf("Generation() after Compact = %d, want 5", gen)
+	}
+	lpm.Compact()
+	if gen := lpm.Generation(); gen != 5 {
+		t.Errorf("Generation() after a Compact removing nothing = %d, want 5", gen)
+	}
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	info, err := StorageInfo(storage)
+	if err != nil {
+		t.Fatalf("StorageInfo failed: %v", err)
+	}
+	if info.Header.Generation != 5 {
+		t.Errorf("StorageInfo().Header.Generation = %d, want 5", info.Header.Generation)
+	}
+
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if gen := loaded.Generation(); gen != 5 {
+		t.Errorf("loaded Generation() = %d, want 5", gen)
+	}
+	loaded.Insert(netip.MustParsePrefix("10.2.0.0/16"), "dc2")
+	if gen := loaded.Generation(); gen != 6 {
+		t.Errorf("Generation() after insert into loaded trie = %d, want 6", gen)
+	}
+
+	readOnly, err := NewWithSharedStorage(storage, ReadOnly())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	readOnly.Insert(netip.MustParsePrefix("10.2.0.0/16"), "dc2")
+	if gen := readOnly.Generation(); gen != 5 {
+		t.Errorf("Generation() after rejected insert = %d, want 5", gen)
+	}
+
+	// Packing does not bump the generation, so repacking gives the same storage
+	repacked, err := readOnly.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	if !bytes.Equal(repacked, storage) {
+		t.Error("repacked storage differs from the original")
+	}
+
+	v4, err := NewWithSharedStorage(packV4(t, lpm))
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage(v4) failed: %v", err)
+	}
+	if gen := v4.Generation(); gen != 0 {
+		t.Errorf("Generation() of version 4 storage = %d, want 0", gen)
+	}
+}
@@ -0,0 +1,207 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+// TestMigrateStorage tests converting storage of older format versions into the current format
+func TestMigrateStorage(t *testing.T) {
+	lpm := newPackTestLPM()
+	lpm.generation = 0 // Older formats do not record the generation
+	want, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	v1 := packV1(t, lpm)
+
+	tests := []struct {
+		name    string
+		storage []byte
+	}{
+		{"v1", v1},
+		{"foreign v1", foreignStorage(t, v1)},
+		{"v2", packV2(t, lpm)},
+		{"foreign v2", foreignStorage(t, packV2(t, lpm))},
+		{"legacy v2", packLegacyV2(t, lpm, true)},
+		{"foreign legacy v2", foreignStorage(t, packLegacyV2(t, lpm, true))},
+		{"legacy v2 without priorities", packLegacyV2(t, lpm, false)},
+		{"foreign legacy v2 without priorities", foreignStorage(t, packLegacyV2(t, lpm, false))},
+		{"v3", packV3(t, lpm)},
+		{"foreign v3", foreignStorage(t, packV3(t, lpm))},
+		{"v4", packV4(t, lpm)},
+		{"foreign v4", foreignStorage(t, packV4(t, lpm))},
+		{"v5", packV5(t, lpm)},
+		{"foreign v5", foreignStorage(t, packV5(t, lpm))},
+		{"current", want},
+		{"foreign current", foreignStorage(t, want)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := bytes.Clone(tt.storage)
+
+			migrated, err := MigrateStorage(tt.storage)
+			if err != nil {
+				t.Fatalf("MigrateStorage failed: %v", err)
+			}
+			if !bytes.Equal(migrated, want) {
+				t.Error("migrated storage differs from storage packed in the current format")
+			}
+			if !bytes.Equal(tt.storage, original) {
+				t.Error("MigrateStorage modified its input")
+			}
+
+			loaded, err := NewWithSharedStorage(migrated)
+			if err != nil {
+				t.Fatalf("NewWithSharedStorage failed: %v", err)
+			}
+			if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); got != "DC6" {
+				t.Errorf("Lookup(10.1.2.3) = %q, want DC6", got)
+			}
+		})
+	}
+
+	if _, err := MigrateStorage(make([]byte, 64)); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("MigrateStorage(zeroes) error = %v, want %v", err, ErrBadMagic)
+	}
+}
+
+// TestNewFromReaderV1 tests that the stream loader reads the shorter version 1 header
+func TestNewFromReaderV1(t *testing.T) {
+	v1 := packV1(t, newPackTestLPM())
+
+	loaded, err := NewFromReader(bytes.NewReader(v1))
+	if err != nil {
+		t.Fatalf("NewFromReader failed: %v", err)
+	}
+	if got, _ := loaded.Lookup(netip.MustParseAddr("2001:db8:1::1")); got != "doc-subnet" {
+		t.Errorf("Lookup(2001:db8:1::1) = %q, want doc-subnet", got)
+	}
+}
+
+// TestSharedStorageLoadLegacyV2 tests that version 2 storage packed before the header gained
+// ByteOrder and Checksum loads with the priorities its value slots hold, if any
+func TestSharedStorageLoadLegacyV2(t *testing.T) {
+	lpm := New()
+	lpm.InsertWithPriority(netip.MustParsePrefix("192.0.2.0/24"), "high", 7)
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+
+	tests := []struct {
+		name       string
+		priorities bool
+		want       string
+	}{
+		{"with priorities", true, "high"},
+		{"without priorities", false, "low"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := packLegacyV2(t, lpm, tt.priorities)
+			for _, load := range []struct {
+				name string
+				load func() (*LPM, error)
+			}{
+				{"NewWithSharedStorage", func() (*LPM, error) { return NewWithSharedStorage(storage) }},
+				{"NewFromReader", func() (*LPM, error) { return NewFromReader(bytes.NewReader(storage)) }},
+			} {
+				loaded, err := load.load()
+				if err != nil {
+					t.Fatalf("%s failed: %v", load.name, err)
+				}
+				if err := loaded.Verify(); err != nil {
+					t.Fatalf("Verify failed: %v", err)
+				}
+				if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); got != "private" {
+					t.Errorf("%s: Lookup(10.1.2.3) = %q, want private", load.name, got)
+				}
+
+				// Only a priority kept from the storage stops the more specific prefix
+				if err := loaded.Insert(netip.MustParsePrefix("192.0.2.0/25"), "low"); err != nil {
+					t.Fatalf("Insert failed: %v", err)
+				}
+				if got, _ := loaded.Lookup(netip.MustParseAddr("192.0.2.1")); got != tt.want {
+					t.Errorf("%s: Lookup(192.0.2.1) = %q, want %s", load.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestSectionEnd tests that section bounds saturate instead of overflowing
+func TestSectionEnd(t *testing.T) {
+	tests := []struct {
+		offset, count, size uint64
+		want                int
+	}{
+		{72, 3, 1024, 72 + 3*1024},
+		{72, 0, 1024, 72},
+		{math.MaxUint64, 1, 1, math.MaxInt},
+		{72, math.MaxUint64, 1024, math.MaxInt},
+		{1 << 32, 1 << 32, 1 << 32, math.MaxInt},
+		{math.MaxInt, 0, 0, math.MaxInt},
+	}
+	for _, tt := range tests {
+		if got := sectionEnd(tt.offset, tt.count, tt.size); got != tt.want {
+			t.Errorf("sectionEnd(%d, %d, %d) = %d, want %d", tt.offset, tt.count, tt.size, got, tt.want)
+		}
+	}
+}
+
+// TestHeaderLayout tests that header sizes and field offsets match the in-memory layout
+func TestHeaderLayout(t *testing.T) {
+	sizes := map[uint32]uintptr{
+		versionV1:      unsafe.Sizeof(storageHeaderV1{}),
+		versionV2:      unsafe.Sizeof(storageHeaderV2{}),
+		versionV3:      unsafe.Sizeof(storageHeaderV3{}),
+		versionV4:      unsafe.Sizeof(storageHeaderV4{}),
+		versionV5:      unsafe.Sizeof(storageHeaderV5{}),
+		currentVersion: unsafe.Sizeof(StorageHeader{}),
+	}
+	for version, want := range sizes {
+		if got := headerSize(version); got != int(want) {
+			t.Errorf("headerSize(%d) = %d, want %d", version, got, want)
+		}
+	}
+	if got, want := checksumOffset(versionV2), unsafe.Offsetof(storageHeaderV2{}.Checksum); got != int(want) {
+		t.Errorf("checksumOffset(2) = %d, want %d", got, want)
+	}
+	if got, want := checksumOffset(currentVersion), unsafe.Offsetof(StorageHeader{}.Checksum); got != int(want) {
+		t.Errorf("checksumOffset(%d) = %d, want %d", currentVersion, got, want)
+	}
+	if got, want := sequenceOffset(currentVersion), unsafe.Offsetof(StorageHeader{}.Sequence); got != int(want) {
+		t.Errorf("sequenceOffset(%d) = %d, want %d", currentVersion, got, want)
+	}
+}
+
+// TestSharedStorageHugeHeader tests that counts and offsets beyond the storage are rejected without overflowing
+func TestSharedStorageHugeHeader(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*StorageHeader)
+	}{
+		{"IPv4 block count", func(h *StorageHeader) { h.V4BlockCount = 1 << 54 }},
+		{"IPv6 blocks offset", func(h *StorageHeader) { h.V6BlocksOffset = math.MaxUint64 - 100 }},
+		{"value slot size", func(h *StorageHeader) { h.ValueSlotSize = 1 << 40 }},
+		{"value count", func(h *StorageHeader) { h.ValueCount = math.MaxUint64 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := bytes.Clone(storage)
+			tt.modify((*StorageHeader)(unsafe.Pointer(&corrupted[0])))
+
+			var truncated *ErrTruncated
+			if _, err := NewWithSharedStorage(corrupted, SkipChecksum()); !errors.As(err, &truncated) {
+				t.Errorf("NewWithSharedStorage() error = %v, want ErrTruncated", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package lpm
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestFreeListChurn tests that inserting and deleting the same prefixes does not grow the trie
+func TestFreeListChurn(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "base")
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "base")
+	churn := func(round int) {
+		for i := range 50 {
+			m.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.7/32", i, round%7)), "host")
+			m.Insert(netip.MustParsePrefix(fmt.Sprintf("2001:db8:%x::%x/128", i, round)), "host")
+		}
+		for i := range 50 {
+			m.Delete(netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.7/32", i, round%7)))
+			m.Delete(netip.MustParsePrefix(fmt.Sprintf("2001:db8:%x::%x/128", i, round)))
+		}
+	}
+
+	churn(0)
+	v4, v6 := m.blockCount(v4LPM), m.blockCount(v6LPM)
+	for round := 1; round < 6; round++ {
+		churn(round)
+	}
+	if got := m.blockCount(v4LPM); got != v4 {
+		t.Errorf("IPv4 blocks grew from %d to %d", v4, got)
+	}
+	if got := m.blockCount(v6LPM); got != v6 {
+		t.Errorf("IPv6 blocks grew from %d to %d", v6, got)
+	}
+
+	// Everything was deleted, so all blocks but the root holding 10/8 are free
+	if got := len(m.free[v4LPM]); got != v4-1 {
+		t.Errorf("%d free IPv4 blocks, want %d", got, v4-1)
+	}
+	for addr, want := range map[string]string{"10.1.2.7": "base", "2001:db8:1::1": "base"} {
+		if got, _ := m.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+	if err := m.Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+// TestFreeListOverride tests that blocks overridden by a prefix of higher priority are freed
+func TestFreeListOverride(t *testing.T) {
+	m := New()
+	for i := range 256 {
+		m.Insert(netip.MustParsePrefix(fmt.Sprintf("10.1.%d.0/24", i)), "narrow")
+	}
+	m.Insert(netip.MustParsePrefix("10.1.2.3/32"), "host")
+	before := m.blockCount(v4LPM)
+	m.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "wide", 1)
+
+	if got := len(m.free[v4LPM]); got != before-1 {
+		t.Errorf("%d free blocks after the override, want %d", got, before-1)
+	}
+	if got, _ := m.Lookup(netip.MustParseAddr("10.1.2.3")); got != "wide" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want wide", got)
+	}
+
+	// Freed blocks are reused
+	m.Insert(netip.MustParsePrefix("192.168.1.0/24"), "lan")
+	if got := m.blockCount(v4LPM); got != before {
+		t.Errorf("block count %d after reusing, want %d", got, before)
+	}
+	if got, _ := m.Lookup(netip.MustParseAddr("192.168.1.1")); got != "lan" {
+		t.Errorf("Lookup(192.168.1.1) = %q, want lan", got)
+	}
+}
+
+// TestFreeListSnapshot tests that blocks shared with snapshots are never reused
+func TestFreeListSnapshot(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "base")
+	m.Insert(netip.MustParsePrefix("10.1.2.0/24"), "old")
+	snap := m.Snapshot()
+
+	// Only the copies of the frozen blocks on the path are freed
+	m.Delete(netip.MustParsePrefix("10.1.2.0/24"))
+	for _, blockIdx := range m.free[v4LPM] {
+		if m.isFrozen(v4LPM, blockIdx) {
+			t.Errorf("frozen block %d on the free list", blockIdx)
+		}
+	}
+	m.Insert(netip.MustParsePrefix("10.3.4.0/24"), "new")
+	m.Delete(netip.MustParsePrefix("10.3.4.0/24"))
+	snap2 := m.Snapshot()
+	m.Insert(netip.MustParsePrefix("10.5.6.0/24"), "newer")
+
+	for _, tt := range []struct {
+		m    *LPM
+		addr string
+		want string
+	}{
+		{snap, "10.1.2.3", "old"},
+		{snap2, "10.1.2.3", "base"},
+		{snap2, "10.5.6.7", "base"},
+		{m, "10.5.6.7", "newer"},
+		{m, "10.3.4.5", "base"},
+	} {
+		if got, _ := tt.m.Lookup(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// TestFreeListRandom tests that collapsing dead blocks keeps lookups intact: inserts with
+// overriding priorities map addresses like a trie built from scratch, deleting every prefix
+// frees every block but the roots, and inserting again reuses them.
+func TestFreeListRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := range 10 {
+		entries := randomEntries(rng, 1+rng.Intn(300))
+		m := New()
+		for _, e := range entries {
+			if e.Tombstone {
+				m.InsertTombstone(e.Prefix)
+			} else {
+				m.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+			}
+		}
+		want, err := Build(entries)
+		if err != nil {
+			t.Fatalf("round %d: Build: %v", round, err)
+		}
+		if m.Fingerprint() != want.Fingerprint() {
+			t.Fatalf("round %d: trie differs from one built from its prefixes", round)
+		}
+		if err := m.Verify(); err != nil {
+			t.Fatalf("round %d: Verify: %v", round, err)
+		}
+
+		for _, i := range rng.Perm(len(entries)) {
+			m.Delete(entries[i].Prefix)
+		}
+		for _, e := range entries {
+			if value, found := m.Lookup(e.Prefix.Addr()); found {
+				t.Fatalf("round %d: Lookup(%s) = %q after deleting everything", round, e.Prefix.Addr(), value)
+			}
+		}
+		for _, proto := range []int{v4LPM, v6LPM} {
+			if free, count := len(m.free[proto]), m.blockCount(proto); free != count-1 {
+				t.Errorf("round %d: %d of %d blocks free after deleting everything", round, free, count)
+			}
+		}
+
+		// Deletes uncovering prefixes hidden by a higher priority may have needed more blocks
+		// than the full trie, so the inserts below must not allocate any
+		blocks := m.blockCount(v4LPM) + m.blockCount(v6LPM)
+		for _, e := range entries {
+			m.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+		}
+		if got := m.blockCount(v4LPM) + m.blockCount(v6LPM); got > blocks {
+			t.Errorf("round %d: %d blocks after inserting again, %d before", round, got, blocks)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package lpm
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// TestPackDropsUnreachableValues tests that overwritten values are not packed
+func TestPackDropsUnreachableValues(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old-1")
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old-2")
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "current")
+	lpm.Insert(netip.MustParsePrefix("192.168.1.0/24"), "kept")
+	// An unreachable value must not prevent packing
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), strings.Repeat("x", 70000))
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
+	if header.ValueCount != 3 {
+		t.Errorf("ValueCount = %d, want 3", header.ValueCount)
+	}
+
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	tests := []struct{ addr, want string }{
+		{"10.1.2.3", "current"},
+		{"192.168.1.1", "kept"},
+		{"2001:db8::1", "v6"},
+	}
+	for _, tt := range tests {
+		got, found := shared.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+}
+
+// TestCompactValues tests dropping unreferenced dynamic values in memory
+func TestCompactValues(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "A")
+	lpm.Insert(netip.MustParsePrefix("172.16.0.0/16"), "B")
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "C")
+	lpm.Insert(netip.MustParsePrefix("172.16.0.0/16"), "D")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "E")
+
+	if dropped := lpm.CompactValues(); dropped != 2 {
+		t.Errorf("CompactValues() = %d, want 2", dropped)
+	}
+	if dropped := lpm.CompactValues(); dropped != 0 {
+		t.Errorf("second CompactValues() = %d, want 0", dropped)
+	}
+	if len(lpm.revValues) != 3 || len(lpm.values) != 3 {
+		t.Errorf("value table has %d/%d entries, want 3", len(lpm.revValues), len(lpm.values))
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.2.3.4", "C"},
+		{"172.16.2.3", "D"},
+		{"2001:db8::1", "E"},
+	}
+	for _, tt := range tests {
+		got, found := lpm.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+
+	// Values are still deduplicated after compaction
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "C")
+	idx1, _ := lpm.LookupIndex(netip.MustParseAddr("10.2.3.4"))
+	idx2, _ := lpm.LookupIndex(netip.MustParseAddr("192.168.1.1"))
+	if idx1 != idx2 {
+		t.Errorf("equal values have different indices after compaction: %d != %d", idx1, idx2)
+	}
+}
+
+// TestCompactValuesNested tests that overwriting a prefix with narrower prefixes below
+// it leaves no slot referring to the old value
+func TestCompactValuesNested(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old")
+	lpm.Insert(netip.MustParsePrefix("10.1.1.0/24"), "rack")
+	lpm.Insert(netip.MustParsePrefix("10.1.2.0/28"), "edge")
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "new")
+
+	if dropped := lpm.CompactValues(); dropped != 1 {
+		t.Errorf("CompactValues() = %d, want 1", dropped)
+	}
+	tests := []struct{ addr, want string }{
+		{"10.1.1.1", "rack"},
+		{"10.1.2.1", "edge"},
+		{"10.1.2.100", "new"},
+		{"10.1.3.1", "new"},
+		{"10.2.0.1", "new"},
+	}
+	for _, tt := range tests {
+		got, found := lpm.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+}
+
+// TestCompactValuesShared tests that compaction keeps shared values and renumbers dynamic ones
+func TestCompactValuesShared(t *testing.T) {
+	base := New()
+	base.Insert(netip.MustParsePrefix("10.0.0.0/8"), "shared")
+
+	storage, err := base.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	lpm, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dyn-old")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dyn-new")
+
+	if dropped := lpm.CompactValues(); dropped != 1 {
+		t.Errorf("CompactValues() = %d, want 1", dropped)
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.2.3.4", "shared"},
+		{"10.1.2.3", "dyn-new"},
+	}
+	for _, tt := range tests {
+		got, found := lpm.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package lpm
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// TestInsertIPNet tests inserting prefixes given as *net.IPNet
+func TestInsertIPNet(t *testing.T) {
+	lpm := New()
+
+	_, v4net, _ := net.ParseCIDR("10.1.0.0/16")
+	if err := lpm.InsertIPNet(v4net, "DC1"); err != nil {
+		t.Fatalf("InsertIPNet(%s) failed: %v", v4net, err)
+	}
+
+	// IPv4 address in 16-byte form with a 4-byte mask and host bits set
+	if err := lpm.InsertIPNet(&net.IPNet{
+		IP:   net.ParseIP("192.168.1.77"),
+		Mask: net.CIDRMask(24, 32),
+	}, "DC2"); err != nil {
+		t.Fatalf("InsertIPNet(192.168.1.77/24) failed: %v", err)
+	}
+
+	_, v6net, _ := net.ParseCIDR("2001:db8::/32")
+	if err := lpm.InsertIPNet(v6net, "DC3"); err != nil {
+		t.Fatalf("InsertIPNet(%s) failed: %v", v6net, err)
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.1.2.3", "DC1"},
+		{"192.168.1.1", "DC2"},
+		{"192.168.1.255", "DC2"},
+		{"2001:db8::1", "DC3"},
+	}
+	for _, tt := range tests {
+		got, found := lpm.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+
+	invalid := []*net.IPNet{
+		nil,
+		{IP: net.ParseIP("10.0.0.0"), Mask: net.IPMask{255, 0, 255, 0}},
+		{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(24, 32)},
+		{IP: net.IP{1, 2, 3}, Mask: net.CIDRMask(24, 32)},
+	}
+	for _, ipnet := range invalid {
+		if err := lpm.InsertIPNet(ipnet, "bad"); err == nil {
+			t.Errorf("InsertIPNet(%v) succeeded, want error", ipnet)
+		}
+	}
+}
+
+// TestInsertString tests inserting prefixes given as CIDR strings
+func TestInsertString(t *testing.T) {
+	lpm := New()
+
+	if err := lpm.InsertString("10.1.2.3/16", "DC1"); err != nil {
+		t.Fatalf("InsertString failed: %v", err)
+	}
+	if err := lpm.InsertString("2001:db8::/32", "DC2"); err != nil {
+		t.Fatalf("InsertString failed: %v", err)
+	}
+
+	if got, found := lpm.Lookup(netip.MustParseAddr("10.1.200.1")); !found || got != "DC1" {
+		t.Errorf("Lookup(10.1.200.1) = %q (found=%v), want %q", got, found, "DC1")
+	}
+	if got, found := lpm.Lookup(netip.MustParseAddr("2001:db8::1")); !found || got != "DC2" {
+		t.Errorf("Lookup(2001:db8::1) = %q (found=%v), want %q", got, found, "DC2")
+	}
+
+	for _, cidr := range []string{"", "10.0.0.0", "10.0.0.0/33", "not-a-cidr/8"} {
+		if err := lpm.InsertString(cidr, "bad"); err == nil {
+			t.Errorf("InsertString(%q) succeeded, want error", cidr)
+		}
+	}
+}
+
+// TestLookupString tests parsing and looking up an address in one call
+func TestLookupString(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "DC2")
+
+	tests := []struct {
+		addr    string
+		want    string
+		found   bool
+		wantErr bool
+	}{
+		{"10.1.2.3", "DC1", true, false},
+		{"2001:db8::1", "DC2", true, false},
+		{"192.168.1.1", "", false, false},
+		{"", "", false, true},
+		{"10.0.0.0/8", "", false, true},
+		{"10.0.0.256", "", false, true},
+	}
+	for _, tt := range tests {
+		got, found, err := lpm.LookupString(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LookupString(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+		}
+		if got != tt.want || found != tt.found {
+			t.Errorf("LookupString(%q) = %q (found=%v), want %q (found=%v)", tt.addr, got, found, tt.want, tt.found)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package lpm
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestHitCounter tests that lookups count hits per prefix across the slots the prefix spans
+func TestHitCounter(t *testing.T) {
+	for _, opts := range [][]Option{nil, {IPv4Stride16()}} {
+		lpm := New(opts...)
+		lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+		lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+		lpm.Insert(netip.MustParsePrefix("10.1.2.0/24"), "c")
+		lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+		lpm.InsertTombstone(netip.MustParsePrefix("10.9.0.0/16"))
+
+		h := lpm.HitCounter()
+		lpm.Insert(netip.MustParsePrefix("10.2.0.0/16"), "later")
+		for _, addr := range []string{"10.1.2.1", "10.1.2.2", "10.1.2.3", "10.1.3.1", "10.1.200.1", "10.2.0.1", "2001:db8::1", "192.0.2.1", "10.9.0.1"} {
+			want, wantFound := lpm.Snapshot().Lookup(netip.MustParseAddr(addr))
+			if addr == "10.2.0.1" {
+				want = "a"
+			}
+			if got, found := h.Lookup(netip.MustParseAddr(addr)); got != want || found != wantFound {
+				t.Errorf("Lookup(%s) = %q (found=%v), want %q (found=%v)", addr, got, found, want, wantFound)
+			}
+		}
+
+		want := []PrefixHits{
+			{netip.MustParsePrefix("10.1.2.0/24"), "c", 3},
+			{netip.MustParsePrefix("10.1.0.0/16"), "b", 2},
+			{netip.MustParsePrefix("10.0.0.0/8"), "a", 1},
+			{netip.MustParsePrefix("2001:db8::/32"), "v6", 1},
+		}
+		if got := h.TopPrefixes(0); !slices.Equal(got, want) {
+			t.Errorf("TopPrefixes(0) = %v, want %v", got, want)
+		}
+		if got := h.TopPrefixes(2); !slices.Equal(got, want[:2]) {
+			t.Errorf("TopPrefixes(2) = %v, want %v", got, want[:2])
+		}
+
+		h.Reset()
+		if got := h.TopPrefixes(0); len(got) != 0 {
+			t.Errorf("TopPrefixes after Reset = %v, want none", got)
+		}
+	}
+}
+
+// TestHitCounterConcurrent tests that concurrent lookups lose no hits
+func TestHitCounterConcurrent(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	h := lpm.HitCounter()
+
+	const goroutines, lookups = 8, 1000
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for i := range lookups {
+				h.Lookup(netip.AddrFrom4([4]byte{10, byte(i), 0, 1}))
+			}
+		})
+	}
+	wg.Wait()
+	if got := h.TopPrefixes(1); len(got) != 1 || got[0].Hits != goroutines*lookups {
+		t.Errorf("TopPrefixes(1) = %v, want %d hits for 10.0.0.0/8", got, goroutines*lookups)
+	}
+}
+
+// BenchmarkHitCounter benchmarks counting lookups against plain ones
+func BenchmarkHitCounter(b *testing.B) {
+	lpm := New()
+	for i := range 256 {
+		lpm.Insert(netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 0}), 16), "dc")
+		lpm.Insert(netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 1, 0}), 24), "rack")
+	}
+	addr := netip.MustParseAddr("10.42.1.1")
+	b.Run("Lookup", func(b *testing.B) {
+		for b.Loop() {
+			lpm.Lookup(addr)
+		}
+	})
+	h := lpm.HitCounter()
+	b.Run("HitCounter", func(b *testing.B) {
+		for b.Loop() {
+			h.Lookup(addr)
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package lpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func openTestJournal(t *testing.T, path string) *Journal {
+	t.Helper()
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	t.Cleanup(func() { _ = j.Close() })
+	return j
+}
+
+func journalOps(t *testing.T, j *Journal, round int) {
+	t.Helper()
+
+	ops := []func() error{
+		func() error { return j.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private") },
+		func() error { return j.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1") },
+		func() error { return j.InsertWithPriority(netip.MustParsePrefix("10.2.0.0/16"), "pinned", 2) },
+		func() error { return j.InsertTombstone(netip.MustParsePrefix("10.3.0.0/16")) },
+		func() error { _, err := j.Delete(netip.MustParsePrefix("10.1.0.0/16")); return err },
+		func() error { return j.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc") },
+	}
+	for _, op := range ops {
+		if err := op(); err != nil {
+			t.Fatalf("round %d: journal operation failed: %v", round, err)
+		}
+	}
+	if err := j.Insert(netip.PrefixFrom(netip.AddrFrom4([4]byte{192, 0, byte(round), 0}), 24), "round"); err != nil {
+		t.Fatalf("round %d: Insert failed: %v", round, err)
+	}
+}
+
+// TestJournalRecover tests that reopening a journal recovers the trie
+func TestJournalRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+
+	j := openTestJournal(t, path)
+	journalOps(t, j, 1)
+	want := j.LPM().Fingerprint()
+	if err := j.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	j.Close()
+
+	j = openTestJournal(t, path)
+	if got := j.LPM().Fingerprint(); got != want {
+		t.Fatalf("recovered trie differs from the journaled one")
+	}
+	if value, found := j.LPM().Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != "private" {
+		t.Errorf("Lookup(10.1.2.3) = %q, %v, want private, true", value, found)
+	}
+
+	// Checkpoint folds the log into the snapshot
+	if err := j.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if j.Size() != 0 {
+		t.Errorf("Size() after Checkpoint = %d, want 0", j.Size())
+	}
+	journalOps(t, j, 2)
+	want = j.LPM().Fingerprint()
+	j.Close()
+
+	j = openTestJournal(t, path)
+	if got := j.LPM().Fingerprint(); got != want {
+		t.Errorf("trie recovered from snapshot and log differs from the journaled one")
+	}
+}
+
+// TestJournalTornRecord tests recovery from a record cut short by a crash
+func TestJournalTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	j := openTestJournal(t, path)
+	journalOps(t, j, 1)
+	want := j.LPM().Fingerprint()
+	size := j.Size()
+	j.Close()
+
+	// Append the first half of another record
+	f, err := os.OpenFile(journalPath(path), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if _, err := f.Write([]byte{40, 0, 0, 0, 1, 2, 3, 4, 1, 2}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.Close()
+
+	j = openTestJournal(t, path)
+	if got := j.LPM().Fingerprint(); got != want {
+		t.Fatal("trie recovered past a torn record differs")
+	}
+	if j.Size() != size {
+		t.Errorf("Size() = %d, want the torn record dropped (%d)", j.Size(), size)
+	}
+	if err := j.Insert(netip.MustParsePrefix("192.0.2.0/24"), "after"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	want = j.LPM().Fingerprint()
+	j.Close()
+
+	j = openTestJournal(t, path)
+	if got := j.LPM().Fingerprint(); got != want {
+		t.Error("records appended after a torn record were lost")
+	}
+}
+
+// TestJournalByteOrder tests that logs are little-endian whatever the byte order of the host
+func TestJournalByteOrder(t *testing.T) {
+	prefix, _ := netip.MustParsePrefix("10.0.0.0/8").MarshalBinary()
+	payload := []byte{byte(journalInsert), 1, byte(len(prefix))}
+	payload = append(payload, prefix...)
+	payload = append(payload, 0, 4)
+	payload = append(payload, "leaf"...)
+	log := binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))
+	log = binary.LittleEndian.AppendUint32(log, crc32.Checksum(payload, castagnoli))
+	log = append(log, payload...)
+
+	m := New()
+	applied, err := ReplayJournal(m, bytes.NewReader(log))
+	if err != nil || applied != 1 {
+		t.Fatalf("ReplayJournal = %d, %v, want 1, nil", applied, err)
+	}
+	if value, found := m.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != "leaf" {
+		t.Errorf("Lookup(10.1.2.3) = %q, %v, want leaf, true", value, found)
+	}
+
+	// And Journal writes the same bytes
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	j := openTestJournal(t, path)
+	if err := j.Insert(netip.MustParsePrefix("10.0.0.0/8"), "leaf"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	written, err := os.ReadFile(journalPath(path))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(written, log) {
+		t.Errorf("journal record = %x, want %x", written, log)
+	}
+}
+
+// TestJournalCorruptLength tests that a corrupt record length ends replay without
+// allocating the payload it claims
+func TestJournalCorruptLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	j := openTestJournal(t, path)
+	journalOps(t, j, 1)
+	want := j.LPM().Fingerprint()
+	size := j.Size()
+	j.Close()
+
+	f, err := os.OpenFile(journalPath(path), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if _, err := f.Write(append([]byte{0xff, 0xff, 0xff, 0xf0, 1, 2, 3, 4}, make([]byte, 64)...)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	j = openTestJournal(t, path)
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("OpenJournal allocated %d bytes for a corrupt record length", allocated)
+	}
+	if got := j.LPM().Fingerprint(); got != want {
+		t.Fatal("trie recovered past a corrupt record differs")
+	}
+	if j.Size() != size {
+		t.Errorf("Size() = %d, want the corrupt record dropped (%d)", j.Size(), size)
+	}
+}
+
+// TestJournalInterruptedCheckpoint tests that records already in the snapshot are not replayed
+func TestJournalInterruptedCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	j := openTestJournal(t, path)
+	journalOps(t, j, 1)
+
+	// Crash after saving the snapshot, before emptying the log
+	if err := j.LPM().SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	want := j.LPM().Fingerprint()
+	j.Close()
+
+	log, err := os.ReadFile(journalPath(path))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	snapshot, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if applied, err := ReplayJournal(snapshot, bytes.NewReader(log)); applied != 0 || err != nil {
+		t.Errorf("ReplayJournal over the newer snapshot = %d, %v, want 0, nil", applied, err)
+	}
+	if applied, err := ReplayJournal(New(), bytes.NewReader(log)); applied != 7 || err != nil {
+		t.Errorf("ReplayJournal over an empty trie = %d, %v, want 7, nil", applied, err)
+	}
+
+	j = openTestJournal(t, path)
+	if got := j.LPM().Fingerprint(); got != want {
+		t.Error("trie recovered after an interrupted checkpoint differs")
+	}
+}
+
+// TestJournalMetadata tests that metadata changes made between checkpoints are recovered
+func TestJournalMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	j := openTestJournal(t, path)
+	if err := j.SetMetadata(MetadataDatasetVersion, "v1"); err != nil {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	if err := j.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	journalOps(t, j, 1)
+	if err := j.SetMetadata(MetadataDatasetVersion, "v2"); err != nil {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	if err := j.SetMetadata("source", "feed"); err != nil {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	if err := j.DeleteMetadata("source"); err != nil {
+		t.Fatalf("DeleteMetadata failed: %v", err)
+	}
+	size := j.Size()
+	if err := j.DeleteMetadata("absent"); err != nil || j.Size() != size {
+		t.Errorf("DeleteMetadata of an absent key = %v, logged %d bytes, want nothing logged", err, j.Size()-size)
+	}
+	if err := j.SetMetadata("huge", string(make([]byte, maxValueLen))); err == nil {
+		t.Error("SetMetadata of an entry larger than a record succeeded")
+	}
+	want := j.LPM().Generation()
+	j.Close()
+
+	j = openTestJournal(t, path)
+	if got := j.LPM().Metadata(); len(got) != 1 || got[MetadataDatasetVersion] != "v2" {
+		t.Errorf("recovered metadata = %v, want only %s=v2", got, MetadataDatasetVersion)
+	}
+	if got := j.LPM().Generation(); got != want {
+		t.Errorf("recovered generation = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package lpm
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+)
+
+// TestMarshalUnmarshalJSON tests the JSON round trip of the trie
+func TestMarshalUnmarshalJSON(t *testing.T) {
+	original := New()
+	original.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+	original.Insert(netip.MustParsePrefix("10.1.0.0/16"), "DC2")
+	original.Insert(netip.MustParsePrefix("192.168.1.128/25"), "DC3")
+	original.Insert(netip.MustParsePrefix("2001:db8::/32"), "DC4")
+	original.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "DC5")
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	want := `[{"cidr":"10.0.0.0/8","value":"DC1"},` +
+		`{"cidr":"10.1.0.0/16","value":"DC2"},` +
+		`{"cidr":"192.168.1.128/25","value":"DC3"},` +
+		`{"cidr":"2001:db8::/32","value":"DC4"},` +
+		`{"cidr":"2001:db8:1::/48","value":"DC5"}]`
+	if string(data) != want {
+		t.Fatalf("MarshalJSON = %s, want %s", data, want)
+	}
+
+	restored := New()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.2.3.4", "DC1"},
+		{"10.1.2.3", "DC2"},
+		{"192.168.1.200", "DC3"},
+		{"192.168.1.1", ""},
+		{"2001:db8:2::1", "DC4"},
+		{"2001:db8:1::1", "DC5"},
+	}
+	for _, tt := range tests {
+		got, found := restored.Lookup(netip.MustParseAddr(tt.addr))
+		if found != (tt.want != "") || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+
+	again, err := json.Marshal(restored)
+	if err != nil {
+		t.Fatalf("MarshalJSON after restore failed: %v", err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("MarshalJSON is not stable: %s != %s", again, data)
+	}
+}
+
+// TestMarshalJSONEmpty tests that an empty trie is encoded as an empty list
+func TestMarshalJSONEmpty(t *testing.T) {
+	data, err := json.Marshal(New())
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("MarshalJSON = %s, want []", data)
+	}
+}
+
+// TestMarshalJSONSharedStorage tests that values from shared storage are encoded as well
+func TestMarshalJSONSharedStorage(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("172.16.0.0/12"), "shared")
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	loaded.Insert(netip.MustParsePrefix("172.16.1.0/24"), "dynamic")
+
+	data, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	want := `[{"cidr":"172.16.0.0/12","value":"shared"},{"cidr":"172.16.1.0/24","value":"dynamic"}]`
+	if string(data) != want {
+		t.Errorf("MarshalJSON = %s, want %s", data, want)
+	}
+}
+
+// TestUnmarshalJSONInvalid tests that malformed input is rejected
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	inputs := []string{
+		`{"cidr":"10.0.0.0/8","value":"DC1"}`,
+		`[{"cidr":"10.0.0.0/33","value":"DC1"}]`,
+		`[{"value":"DC1"}]`,
+	}
+	for _, input := range inputs {
+		lpm := New()
+		if err := json.Unmarshal([]byte(input), lpm); err == nil {
+			t.Errorf("UnmarshalJSON(%s) succeeded, want error", input)
+		}
+	}
+}
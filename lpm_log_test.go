@@ -0,0 +1,190 @@
+package lpm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testLog collects the records of a JSON logger
+type testLog struct {
+	buf bytes.Buffer
+}
+
+func (l *testLog) logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(&l.buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// records returns the logged records with the given message and resets the log
+func (l *testLog) records(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	var found []map[string]any
+	dec := json.NewDecoder(&l.buf)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("decoding log record: %v", err)
+		}
+		if record["msg"] == msg {
+			found = append(found, record)
+		}
+	}
+	l.buf.Reset()
+	return found
+}
+
+// TestLoggerLoad tests the events of loading, validating and packing storage
+func TestLoggerLoad(t *testing.T) {
+	var log testLog
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	loaded, err := NewWithSharedStorage(storage, WithLogger(log.logger()))
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	records := log.records(t, "loaded storage")
+	if len(records) != 1 || records[0]["level"] != "DEBUG" || records[0]["generation"] != float64(loaded.Generation()) {
+		t.Errorf("loaded storage records = %v, want one at DEBUG level with the generation", records)
+	}
+
+	if _, err := loaded.PackToSharedStorage(); err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	if records := log.records(t, "packed storage"); len(records) != 1 || records[0]["bytes"] != float64(len(storage)) {
+		t.Errorf("packed storage records = %v, want one of %d bytes", records, len(storage))
+	}
+
+	corrupted := bytes.Clone(storage)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if _, err := NewWithSharedStorage(corrupted, WithLogger(log.logger())); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("NewWithSharedStorage error = %v, want %v", err, ErrChecksumMismatch)
+	}
+	records = log.records(t, "loading storage failed")
+	if len(records) != 1 || records[0]["level"] != "WARN" {
+		t.Errorf("loading storage failed records = %v, want one at WARN level", records)
+	}
+
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	if _, err := LoadFromFile(path, WithLogger(log.logger())); err == nil {
+		t.Fatal("LoadFromFile of a missing file succeeded")
+	}
+	if records := log.records(t, "loading storage failed"); len(records) != 1 || records[0]["path"] != path {
+		t.Errorf("loading storage failed records = %v, want one with path %s", records, path)
+	}
+	if err := loaded.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	if records := log.records(t, "saved storage"); len(records) != 1 || records[0]["path"] != path {
+		t.Errorf("saved storage records = %v, want one with path %s", records, path)
+	}
+	if _, err := LoadFromFile(path, WithLogger(log.logger())); err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if records := log.records(t, "loaded storage"); len(records) != 1 || records[0]["path"] != path {
+		t.Errorf("loaded storage records = %v, want one with path %s", records, path)
+	}
+}
+
+// TestLoggerBuild tests the event of a bulk load
+func TestLoggerBuild(t *testing.T) {
+	var log testLog
+	entries := []PrefixValue{pv("10.0.0.0/8", "a"), pv("10.1.0.0/16", "b"), pv("2001:db8::/32", "c")}
+	if _, err := Build(entries, WithLogger(log.logger())); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	records := log.records(t, "built table")
+	if len(records) != 1 || records[0]["level"] != "INFO" || records[0]["entries"] != float64(len(entries)) ||
+		records[0]["values"] != float64(3) {
+		t.Errorf("built table records = %v, want one at INFO level with 3 entries and values", records)
+	}
+
+	entries = append(entries, PrefixValue{Value: "invalid"})
+	if _, err := Build(entries, WithLogger(log.logger())); err == nil {
+		t.Fatal("Build of an invalid prefix succeeded")
+	}
+	if records := log.records(t, "building table failed"); len(records) != 1 {
+		t.Errorf("building table failed records = %v, want one", records)
+	}
+}
+
+// TestLoggerCapacity tests warnings about inserts approaching and exceeding the memory limit
+func TestLoggerCapacity(t *testing.T) {
+	var log testLog
+	lpm := New(WithMaxMemory(64<<10), WithLogger(log.logger()))
+
+	var err error
+	for i := range 1000 {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), byte(i), 1}), 32)
+		if err = lpm.Insert(prefix, fmt.Sprintf("host-%d", i)); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("Insert error = %v, want %v", err, ErrMemoryLimit)
+	}
+	approaching := log.records(t, "approaching capacity")
+	if len(approaching) != 1 || approaching[0]["limit"] != "memory" {
+		t.Errorf("approaching capacity records = %v, want one for memory", approaching)
+	}
+}
+
+// TestLoggerRejected tests that only inserts exceeding a limit are logged as rejected
+func TestLoggerRejected(t *testing.T) {
+	var log testLog
+	lpm := New(WithMaxMemory(1), WithLogger(log.logger()))
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a"); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("Insert error = %v, want %v", err, ErrMemoryLimit)
+	}
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("10.1.0.0/16")); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("InsertTombstone error = %v, want %v", err, ErrMemoryLimit)
+	}
+	if err := lpm.Insert(netip.Prefix{}, "invalid"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("Insert error = %v, want %v", err, ErrInvalidPrefix)
+	}
+	records := log.records(t, "insert rejected")
+	if len(records) != 2 || records[0]["prefix"] != "10.0.0.0/8" || records[1]["prefix"] != "10.1.0.0/16" {
+		t.Errorf("insert rejected records = %v, want 10.0.0.0/8 and 10.1.0.0/16", records)
+	}
+}
+
+// TestRefreshLogger tests the events of successful, unchanged and failed refreshes
+func TestRefreshLogger(t *testing.T) {
+	var log testLog
+	var f feed
+	f.set(pv("10.0.0.0/8", "private"))
+
+	r, err := NewRefresher(context.Background(), f.fetch, time.Hour, RefreshLogger(log.logger()))
+	if err != nil {
+		t.Fatalf("NewRefresher failed: %v", err)
+	}
+	defer r.Close()
+	if records := log.records(t, "refreshed table"); len(records) != 1 || records[0]["prefixes"] != float64(1) {
+		t.Errorf("refreshed table records = %v, want one with 1 prefix", records)
+	}
+
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if records := log.records(t, "refresh found the table unchanged"); len(records) != 1 {
+		t.Errorf("unchanged table records = %v, want one", records)
+	}
+
+	f.fail(errors.New("feed down"))
+	if err := r.Refresh(context.Background()); err == nil {
+		t.Fatal("Refresh of a failing feed succeeded")
+	}
+	records := log.records(t, "refresh failed")
+	if len(records) != 1 || records[0]["level"] != "WARN" || records[0]["consecutive_failures"] != float64(1) {
+		t.Errorf("refresh failed records = %v, want one at WARN level after 1 failure", records)
+	}
+}
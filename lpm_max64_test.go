@@ -0,0 +1,73 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// TestIPv6Max64 tests lookups and inserts of a trie limited to /64
+func TestIPv6Max64(t *testing.T) {
+	lpm := New(IPv6Max64())
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	lpm.Insert(netip.MustParsePrefix("2001:db8:1:2::/64"), "subnet")
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "v4")
+	lpm.Insert(netip.MustParsePrefix("10.1.2.3/32"), "host")
+
+	if err := lpm.Insert(netip.MustParsePrefix("2001:db8:1:2::1/128"), "host"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Insert(/128) = %v, want ErrInvalidPrefix", err)
+	}
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("2001:db8:1:2:8000::/65")); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("InsertTombstone(/65) = %v, want ErrInvalidPrefix", err)
+	}
+
+	tests := map[string]string{
+		"2001:db8:1:2::1":     "subnet",
+		"2001:db8:1:2:ffff::": "subnet",
+		"2001:db8:1:3::1":     "doc",
+		"10.1.2.3":            "host",
+		"10.1.2.4":            "v4",
+	}
+	check := func(name string, lpm *LPM) {
+		t.Helper()
+		for addr, want := range tests {
+			if got, _ := lpm.Lookup(netip.MustParseAddr(addr)); got != want {
+				t.Errorf("%s: Lookup(%s) = %q, want %q", name, addr, got, want)
+			}
+		}
+		if got, _ := lpm.Lookup6(netip.MustParseAddr("2001:db8:1:2::1").As16()); got != "subnet" {
+			t.Errorf("%s: Lookup6(2001:db8:1:2::1) = %q, want subnet", name, got)
+		}
+		if depth := lpm.depth(v6LPM); depth != max64Bytes {
+			t.Errorf("%s: IPv6 trie has %d levels, want %d", name, depth, max64Bytes)
+		}
+	}
+	check("dynamic", lpm)
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage, IPv6Max64())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+	check("shared", shared)
+	if err := shared.Insert(netip.MustParsePrefix("2001:db8::1/128"), "host"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("shared: Insert(/128) = %v, want ErrInvalidPrefix", err)
+	}
+
+	// Storage packed without the limit loads without the option only
+	full := New()
+	full.Insert(netip.MustParsePrefix("2001:db8::1/128"), "host")
+	storage, err = full.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	if _, err := NewWithSharedStorage(storage, IPv6Max64()); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("NewWithSharedStorage(/128) = %v, want ErrInvalidPrefix", err)
+	}
+	if _, err := NewWithSharedStorage(storage); err != nil {
+		t.Errorf("NewWithSharedStorage: %v", err)
+	}
+}
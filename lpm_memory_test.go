@@ -0,0 +1,64 @@
+package lpm
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+// TestMaxMemory tests that inserts fail with ErrMemoryLimit before the trie outgrows the limit
+func TestMaxMemory(t *testing.T) {
+	const limit = 64 << 10
+	lpm := New(WithMaxMemory(limit))
+
+	inserted := 0
+	var err error
+	for i := range 1000 {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), byte(i), 1}), 32)
+		if err = lpm.Insert(prefix, fmt.Sprintf("host-%d", i)); err != nil {
+			break
+		}
+		inserted++
+	}
+	if !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("Insert error = %v, want %v", err, ErrMemoryLimit)
+	}
+	if inserted == 0 {
+		t.Fatal("no insert fit in the limit")
+	}
+	if size := lpm.Stats().TotalSize; size > limit {
+		t.Errorf("TotalSize = %d, over the limit of %d", size, limit)
+	}
+
+	// The failed insert left the trie unchanged, and inserts that need no new memory still work
+	before := lpm.Stats()
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("2001:db8::1/128")); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("InsertTombstone error = %v, want %v", err, ErrMemoryLimit)
+	}
+	if after := lpm.Stats(); after != before {
+		t.Errorf("Stats after failed inserts = %+v, want %+v", after, before)
+	}
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "host-0"); err != nil {
+		t.Errorf("Insert of a short prefix with a known value failed: %v", err)
+	}
+
+	if _, err := Build([]PrefixValue{{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "a"}}, WithMaxMemory(1)); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Build error = %v, want %v", err, ErrMemoryLimit)
+	}
+}
+
+// TestMaxMemoryShared tests that shared storage counts towards the limit
+func TestMaxMemoryShared(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	loaded, err := NewWithSharedStorage(storage, WithMaxMemory(len(storage)/2))
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if err := loaded.Insert(netip.MustParsePrefix("192.0.2.0/24"), "new"); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Insert error = %v, want %v", err, ErrMemoryLimit)
+	}
+}
@@ -0,0 +1,173 @@
+package lpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+func newMetadataTestLPM() *LPM {
+	lpm := newPackTestLPM()
+	lpm.SetMetadata(MetadataBuildTime, "2024-05-01T12:00:00Z")
+	lpm.SetMetadata(MetadataDatasetVersion, "routes-1842")
+	lpm.SetMetadata("source", "")
+	return lpm
+}
+
+// TestMetadata tests that metadata survives packing, loading and repacking
+func TestMetadata(t *testing.T) {
+	lpm := newMetadataTestLPM()
+	want := map[string]string{
+		MetadataBuildTime:      "2024-05-01T12:00:00Z",
+		MetadataDatasetVersion: "routes-1842",
+		"source":               "",
+	}
+	if got := lpm.Metadata(); !maps.Equal(got, want) {
+		t.Fatalf("Metadata() = %v, want %v", got, want)
+	}
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	var streamed bytes.Buffer
+	if _, err := lpm.PackTo(&streamed); err != nil {
+		t.Fatalf("PackTo failed: %v", err)
+	}
+	if !bytes.Equal(streamed.Bytes(), storage) {
+		t.Error("PackTo output differs from PackToSharedStorage")
+	}
+	if size := lpm.EstimatePackedSize(); size != len(storage) {
+		t.Errorf("EstimatePackedSize() = %d, want %d", size, len(storage))
+	}
+
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if got := loaded.Metadata(); !maps.Equal(got, want) {
+		t.Errorf("loaded Metadata() = %v, want %v", got, want)
+	}
+	if value, found := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != "DC6" {
+		t.Errorf("Lookup(10.1.2.3) = %q, %v, want DC6, true", value, found)
+	}
+
+	repacked, err := loaded.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	if !bytes.Equal(repacked, storage) {
+		t.Error("repacked storage differs from the original")
+	}
+
+	loaded.DeleteMetadata("source")
+	loaded.Metadata()[MetadataBuildTime] = "modified copy"
+	delete(want, "source")
+	if got := loaded.Metadata(); !maps.Equal(got, want) {
+		t.Errorf("Metadata() after DeleteMetadata = %v, want %v", got, want)
+	}
+}
+
+// TestStorageInfo tests reading metadata without loading the trie
+func TestStorageInfo(t *testing.T) {
+	lpm := newMetadataTestLPM()
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	var compressed bytes.Buffer
+	if _, err := lpm.PackCompressed(&compressed); err != nil {
+		t.Fatalf("PackCompressed failed: %v", err)
+	}
+	plain, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		storage    []byte
+		foreign    bool
+		compressed bool
+		metadata   map[string]string
+	}{
+		{"native", storage, false, false, lpm.Metadata()},
+		{"foreign", foreignStorage(t, storage), true, false, lpm.Metadata()},
+		{"compressed", compressed.Bytes(), false, true, lpm.Metadata()},
+		{"no metadata", plain, false, false, nil},
+		{"v2", packV2(t, newPackTestLPM()), false, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := StorageInfo(tt.storage)
+			if err != nil {
+				t.Fatalf("StorageInfo failed: %v", err)
+			}
+			if info.Foreign != tt.foreign || info.Compressed != tt.compressed {
+				t.Errorf("StorageInfo() foreign = %v, compressed = %v, want %v, %v", info.Foreign, info.Compressed, tt.foreign, tt.compressed)
+			}
+			if !maps.Equal(info.Metadata, tt.metadata) {
+				t.Errorf("StorageInfo().Metadata = %v, want %v", info.Metadata, tt.metadata)
+			}
+			if info.Header.ValueCount == 0 || info.Header.V4BlockCount == 0 {
+				t.Errorf("StorageInfo().Header = %+v, want the packed counts", info.Header)
+			}
+		})
+	}
+
+	var truncated *ErrTruncated
+	if _, err := StorageInfo(storage[:len(storage)-1]); !errors.As(err, &truncated) {
+		t.Errorf("StorageInfo(truncated) error = %v, want *ErrTruncated", err)
+	}
+}
+
+// TestMetadataCorrupted tests that undecodable metadata is rejected
+func TestMetadataCorrupted(t *testing.T) {
+	storage, err := newMetadataTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
+
+	// Claim one more entry than the section holds and fix up the checksum
+	storage[header.MetadataOffset]++
+	offset := checksumOffset(currentVersion)
+	binary.NativeEndian.PutUint32(storage[offset:], storageChecksum(storage, len(storage), currentVersion))
+
+	if _, err := NewWithSharedStorage(storage); !errors.Is(err, ErrBadMetadata) {
+		t.Errorf("NewWithSharedStorage error = %v, want %v", err, ErrBadMetadata)
+	}
+	if _, err := StorageInfo(storage); !errors.Is(err, ErrBadMetadata) {
+		t.Errorf("StorageInfo error = %v, want %v", err, ErrBadMetadata)
+	}
+}
+
+// TestPatchMetadata tests that patches carry metadata changes
+func TestPatchMetadata(t *testing.T) {
+	lpm := newMetadataTestLPM()
+	old, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	lpm.SetMetadata(MetadataDatasetVersion, "routes-1843")
+	new, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	patch, err := DiffStorage(old, new)
+	if err != nil {
+		t.Fatalf("DiffStorage failed: %v", err)
+	}
+	patched, err := ApplyPatch(old, patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	if !bytes.Equal(patched, new) {
+		t.Error("patched storage differs from the new storage")
+	}
+}
@@ -0,0 +1,91 @@
+package lpm
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestMultiAddLookupAll tests attaching several values to the same prefix
+func TestMultiAddLookupAll(t *testing.T) {
+	m := NewMulti()
+	m.Add(netip.MustParsePrefix("192.0.2.0/24"), "blocklist:spamhaus")
+	m.Add(netip.MustParsePrefix("192.0.2.0/24"), "region:eu")
+	m.Add(netip.MustParsePrefix("192.0.2.0/24"), "region:eu") // duplicate
+	m.Add(netip.MustParsePrefix("192.0.2.128/25"), "region:eu")
+	m.Add(netip.MustParsePrefix("10.1.2.3/8"), "internal") // host bits are masked
+	m.Set(netip.MustParsePrefix("2001:db8::/32"), "doc", "", "ipv6")
+
+	check := func(t *testing.T, m *Multi) {
+		tests := []struct {
+			addr string
+			want []string
+		}{
+			{"192.0.2.1", []string{"blocklist:spamhaus", "region:eu"}},
+			{"192.0.2.200", []string{"region:eu"}},
+			{"10.9.9.9", []string{"internal"}},
+			{"2001:db8::1", []string{"doc", "", "ipv6"}},
+			{"198.51.100.1", nil},
+		}
+		for _, tt := range tests {
+			if got := m.LookupAll(netip.MustParseAddr(tt.addr)); !slices.Equal(got, tt.want) {
+				t.Errorf("LookupAll(%s) = %q, want %q", tt.addr, got, tt.want)
+			}
+		}
+		if got := m.Values(netip.MustParsePrefix("10.0.0.0/8")); !slices.Equal(got, []string{"internal"}) {
+			t.Errorf("Values(10.0.0.0/8) = %q, want [internal]", got)
+		}
+	}
+
+	t.Run("dynamic", func(t *testing.T) {
+		check(t, m)
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		storage, err := m.LPM().PackToSharedStorage()
+		if err != nil {
+			t.Fatalf("PackToSharedStorage failed: %v", err)
+		}
+		shared, err := NewMultiWithSharedStorage(storage)
+		if err != nil {
+			t.Fatalf("NewMultiWithSharedStorage failed: %v", err)
+		}
+		check(t, shared)
+
+		// Adding to a loaded set extends it
+		shared.Add(netip.MustParsePrefix("10.0.0.0/8"), "lab")
+		want := []string{"internal", "lab"}
+		if got := shared.LookupAll(netip.MustParseAddr("10.1.1.1")); !slices.Equal(got, want) {
+			t.Errorf("LookupAll(10.1.1.1) after Add = %q, want %q", got, want)
+		}
+	})
+}
+
+// TestMultiSetReplaces tests that Set replaces the previous values
+func TestMultiSetReplaces(t *testing.T) {
+	m := NewMulti()
+	m.Add(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	m.Add(netip.MustParsePrefix("10.0.0.0/8"), "b")
+
+	values := m.Values(netip.MustParsePrefix("10.0.0.0/8"))
+	values[0] = "mutated"
+
+	m.Set(netip.MustParsePrefix("10.0.0.0/8"), "c")
+	if got := m.LookupAll(netip.MustParseAddr("10.0.0.1")); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("LookupAll(10.0.0.1) = %q, want [c]", got)
+	}
+}
+
+// TestDecodeSetMalformed tests that truncated value sets are rejected
+func TestDecodeSetMalformed(t *testing.T) {
+	encoded := encodeSet([]string{"abc", strings.Repeat("x", 300)})
+	for i := 1; i < len(encoded); i++ {
+		if values, err := decodeSet(encoded[:i]); err == nil && i != 4 {
+			t.Errorf("decodeSet(%q) = %q, want error", encoded[:i], values)
+		}
+	}
+	if _, err := decodeSet(encoded); err != nil {
+		t.Errorf("decodeSet failed: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+// TestNumericU32 tests insert and lookup with uint32 values
+func TestNumericU32(t *testing.T) {
+	asn := NewU32()
+	asn.Insert(netip.MustParsePrefix("8.8.8.0/24"), 15169)
+	asn.Insert(netip.MustParsePrefix("1.1.1.0/24"), 13335)
+	asn.Insert(netip.MustParsePrefix("1.0.0.0/8"), 4294967295)
+	asn.Insert(netip.MustParsePrefix("2001:4860::/32"), 15169)
+
+	tests := []struct {
+		addr  string
+		want  uint32
+		found bool
+	}{
+		{"8.8.8.8", 15169, true},
+		{"1.1.1.1", 13335, true},
+		{"1.2.3.4", 4294967295, true},
+		{"2001:4860::8888", 15169, true},
+		{"9.9.9.9", 0, false},
+		{"2001:db8::1", 0, false},
+	}
+	for _, tt := range tests {
+		got, found := asn.Lookup(netip.MustParseAddr(tt.addr))
+		if got != tt.want || found != tt.found {
+			t.Errorf("Lookup(%s) = %d (found=%v), want %d (found=%v)", tt.addr, got, found, tt.want, tt.found)
+		}
+	}
+
+	if got, found := asn.Lookup4(0x08080808); !found || got != 15169 {
+		t.Errorf("Lookup4(8.8.8.8) = %d (found=%v), want 15169", got, found)
+	}
+	if got, found := asn.Lookup6(netip.MustParseAddr("2001:4860::1").As16()); !found || got != 15169 {
+		t.Errorf("Lookup6(2001:4860::1) = %d (found=%v), want 15169", got, found)
+	}
+
+	// Equal values are stored once
+	if len(asn.revValues) != 3 {
+		t.Errorf("value table has %d entries, want 3", len(asn.revValues))
+	}
+}
+
+// TestNumericU64 tests overlapping prefixes with uint64 values
+func TestNumericU64(t *testing.T) {
+	nexthop := NewU64()
+	nexthop.Insert(netip.MustParsePrefix("10.0.0.0/8"), 1<<40)
+	nexthop.Insert(netip.MustParsePrefix("10.1.0.0/16"), 2)
+	nexthop.Insert(netip.MustParsePrefix("10.1.1.0/24"), 0)
+
+	tests := []struct {
+		addr string
+		want uint64
+	}{
+		{"10.2.0.1", 1 << 40},
+		{"10.1.2.1", 2},
+		{"10.1.1.1", 0},
+	}
+	for _, tt := range tests {
+		got, found := nexthop.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %d (found=%v), want %d", tt.addr, got, found, tt.want)
+		}
+	}
+}
+
+// TestNumericStats tests that numeric values use less memory than equivalent strings
+func TestNumericStats(t *testing.T) {
+	numeric := NewU32()
+	strings := New()
+	for i := range 1000 {
+		prefix := netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+		numeric.Insert(prefix, uint32(64512+i))
+		strings.Insert(prefix, fmt.Sprintf("AS%d", 64512+i))
+	}
+
+	ns := numeric.Stats()
+	ss := strings.Stats()
+	if ns.IPv4Blocks != ss.IPv4Blocks {
+		t.Errorf("IPv4Blocks = %d, want %d", ns.IPv4Blocks, ss.IPv4Blocks)
+	}
+	if ns.ValuesStorage >= ss.ValuesStorage {
+		t.Errorf("numeric ValuesStorage = %d, want less than string ValuesStorage %d", ns.ValuesStorage, ss.ValuesStorage)
+	}
+}
+
+// BenchmarkNumericLookup benchmarks lookups in a uint32 trie
+func BenchmarkNumericLookup(b *testing.B) {
+	asn := NewU32()
+	for i := range 256 {
+		asn.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.0.0/16", i)), uint32(i))
+	}
+	addr := netip.MustParseAddr("10.42.1.1")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = asn.Lookup(addr)
+	}
+}
@@ -1,6 +1,7 @@
 package lpm
 
 import (
+	"math/rand"
 	"net/netip"
 	"testing"
 )
@@ -139,8 +140,7 @@ func TestLPMSmallerThenLargerRange(t *testing.T) {
 	}
 }
 
-// TestLPMReverseInsertionOrder tests that insertion order shouldn't matter
-// This test demonstrates the bug more clearly
+// TestLPMReverseInsertionOrder tests that insertion order doesn't matter
 func TestLPMReverseInsertionOrder(t *testing.T) {
 	t.Run("larger then smaller - should work", func(t *testing.T) {
 		lpm := New()
@@ -167,7 +167,7 @@ func TestLPMReverseInsertionOrder(t *testing.T) {
 		}
 	})
 
-	t.Run("smaller then larger - demonstrates bug", func(t *testing.T) {
+	t.Run("smaller then larger - should work", func(t *testing.T) {
 		lpm := New()
 
 		// Insert smaller range first
@@ -180,7 +180,7 @@ func TestLPMReverseInsertionOrder(t *testing.T) {
 		tests := []struct{ addr, want string }{
 			{"10.1.0.1", "LARGE"},
 			{"10.1.1.1", "SMALL"}, // More specific should win
-			{"10.1.2.1", "LARGE"}, // THIS WILL LIKELY FAIL - the bug!
+			{"10.1.2.1", "LARGE"}, // Broader prefix fills the sub-block of the smaller one
 		}
 
 		for _, tt := range tests {
@@ -192,3 +192,105 @@ func TestLPMReverseInsertionOrder(t *testing.T) {
 		}
 	})
 }
+
+// TestInsertionOrderIndependence tests that shuffled insertions of overlapping prefixes,
+// priorities and tombstones all produce the lookups of the longest highest-priority match
+func TestInsertionOrderIndependence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	type entry struct {
+		prefix   netip.Prefix
+		value    string
+		priority uint8
+	}
+	seen := make(map[netip.Prefix]bool)
+	var entries []entry
+	for len(entries) < 200 {
+		var addr netip.Addr
+		var bits int
+		if rng.Intn(4) == 0 {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+			bits = 32 + rng.Intn(33)
+		} else {
+			addr = netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+			bits = 8 + rng.Intn(25)
+		}
+		prefix := netip.PrefixFrom(addr, bits).Masked()
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		e := entry{prefix: prefix, value: prefix.String(), priority: uint8(rng.Intn(3))}
+		if rng.Intn(8) == 0 {
+			e.value, e.priority = "", 0 // tombstone
+		}
+		entries = append(entries, e)
+	}
+
+	// The reference match: highest priority, then longest prefix
+	want := func(addr netip.Addr) string {
+		var best *entry
+		for i := range entries {
+			e := &entries[i]
+			if !e.prefix.Contains(addr) {
+				continue
+			}
+			if best == nil || e.priority > best.priority || e.priority == best.priority && e.prefix.Bits() > best.prefix.Bits() {
+				best = e
+			}
+		}
+		if best == nil {
+			return ""
+		}
+		return best.value
+	}
+
+	var addrs []netip.Addr
+	for _, e := range entries {
+		a := e.prefix.Addr().As16()
+		for range 4 {
+			b := a
+			for i := e.prefix.Bits() / 8; i < 16; i++ {
+				if e.prefix.Addr().Is4() && i < 12 {
+					continue
+				}
+				b[i] ^= byte(rng.Intn(256)) & mask(e.prefix, i)
+			}
+			addrs = append(addrs, netip.AddrFrom16(b).Unmap())
+		}
+	}
+
+	for round := range 5 {
+		rng.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+		lpm := New()
+		for _, e := range entries {
+			if e.value == "" {
+				lpm.InsertTombstone(e.prefix)
+			} else {
+				lpm.InsertWithPriority(e.prefix, e.value, e.priority)
+			}
+		}
+		for _, addr := range addrs {
+			got, found := lpm.Lookup(addr)
+			if w := want(addr); found != (w != "") || got != w {
+				t.Fatalf("round %d: Lookup(%s) = %q (found=%v), want %q", round, addr, got, found, w)
+			}
+		}
+	}
+}
+
+// mask returns the bits of byte i of an address that lie outside the prefix, with i
+// indexing the 16-byte form of the address
+func mask(prefix netip.Prefix, i int) byte {
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	switch {
+	case bits >= (i+1)*8:
+		return 0
+	case bits <= i*8:
+		return 0xff
+	}
+	return 0xff >> (bits - i*8)
+}
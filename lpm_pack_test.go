@@ -0,0 +1,177 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newPackTestLPM() *LPM {
+	lpm := New()
+	for i := range 300 {
+		lpm.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)), fmt.Sprintf("DC%d", i%7))
+	}
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	lpm.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "doc-subnet")
+	return lpm
+}
+
+// TestPackTo tests that streaming produces the same bytes as PackToSharedStorage
+func TestPackTo(t *testing.T) {
+	lpm := newPackTestLPM()
+
+	want, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	n, err := lpm.PackTo(f)
+	if err != nil {
+		t.Fatalf("PackTo failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("PackTo wrote %d bytes, want %d", n, len(want))
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("PackTo output differs from PackToSharedStorage")
+	}
+
+	loaded, err := NewWithSharedStorage(got)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if value, found := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != "DC6" {
+		t.Errorf("Lookup(10.1.2.3) = %q (found=%v), want %q", value, found, "DC6")
+	}
+}
+
+// failingWriter accepts limit bytes and then fails
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriteFailed
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+// TestPackToWriteError tests that write errors are reported with the number of bytes written
+func TestPackToWriteError(t *testing.T) {
+	lpm := newPackTestLPM()
+
+	n, err := lpm.PackTo(&failingWriter{limit: 3000})
+	if !errors.Is(err, errWriteFailed) {
+		t.Fatalf("PackTo error = %v, want %v", err, errWriteFailed)
+	}
+	if n != 3000 {
+		t.Errorf("PackTo reported %d bytes written, want 3000", n)
+	}
+}
+
+// TestEstimatePackedSize tests that the estimate matches the packed storage exactly
+func TestEstimatePackedSize(t *testing.T) {
+	overwritten := newPackTestLPM()
+	overwritten.Insert(netip.MustParsePrefix("10.0.0.0/24"), "replaced-with-a-longer-value")
+	overwritten.Insert(netip.MustParsePrefix("10.0.0.0/24"), "short")
+
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	shared.Insert(netip.MustParsePrefix("10.0.5.0/25"), "copied")
+
+	tests := []struct {
+		name string
+		lpm  *LPM
+	}{
+		{"empty", New()},
+		{"populated", newPackTestLPM()},
+		{"overwritten", overwritten},
+		{"copy on write", shared},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packed, err := tt.lpm.PackToSharedStorage()
+			if err != nil {
+				t.Fatalf("PackToSharedStorage failed: %v", err)
+			}
+			if got := tt.lpm.EstimatePackedSize(); got != len(packed) {
+				t.Errorf("EstimatePackedSize() = %d, want %d", got, len(packed))
+			}
+		})
+	}
+
+	tooLong := New()
+	tooLong.Insert(netip.MustParsePrefix("10.0.0.0/8"), string(make([]byte, maxValueLen+1)))
+	if got := tooLong.EstimatePackedSize(); got != -1 {
+		t.Errorf("EstimatePackedSize() with an oversized value = %d, want -1", got)
+	}
+}
+
+// TestPackInto tests packing into a caller-provided buffer
+func TestPackInto(t *testing.T) {
+	lpm := newPackTestLPM()
+	want, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	buf := bytes.Repeat([]byte{0xAA}, lpm.EstimatePackedSize()+16)
+	n, err := lpm.PackInto(buf)
+	if err != nil {
+		t.Fatalf("PackInto failed: %v", err)
+	}
+	if n != len(want) {
+		t.Fatalf("PackInto wrote %d bytes, want %d", n, len(want))
+	}
+	if !bytes.Equal(buf[:n], want) {
+		t.Fatal("PackInto output differs from PackToSharedStorage")
+	}
+	if !bytes.Equal(buf[n:], bytes.Repeat([]byte{0xAA}, 16)) {
+		t.Error("PackInto wrote past the packed storage")
+	}
+
+	loaded, err := NewWithSharedStorage(buf[:n])
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if value, found := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != "DC6" {
+		t.Errorf("Lookup(10.1.2.3) = %q, %v, want DC6, true", value, found)
+	}
+
+	short := make([]byte, len(want)-1)
+	if n, err := lpm.PackInto(short); err == nil || n != 0 {
+		t.Errorf("PackInto into %d bytes = %d, %v, want an error", len(short), n, err)
+	}
+	if !bytes.Equal(short, make([]byte, len(short))) {
+		t.Error("PackInto modified a buffer that is too small")
+	}
+}
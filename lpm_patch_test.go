@@ -0,0 +1,120 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestDiffApplyPatch tests that applying a diff reproduces the new storage exactly
+func TestDiffApplyPatch(t *testing.T) {
+	pack := func(t *testing.T, lpm *LPM) []byte {
+		t.Helper()
+		storage, err := lpm.PackToSharedStorage()
+		if err != nil {
+			t.Fatalf("PackToSharedStorage failed: %v", err)
+		}
+		return storage
+	}
+
+	old := pack(t, newPackTestLPM())
+
+	tests := []struct {
+		name   string
+		modify func(lpm *LPM)
+	}{
+		{"unchanged", func(lpm *LPM) {}},
+		{"changed value", func(lpm *LPM) {
+			lpm.Insert(netip.MustParsePrefix("10.0.5.0/24"), "DC1")
+		}},
+		{"new blocks and values", func(lpm *LPM) {
+			lpm.Insert(netip.MustParsePrefix("10.200.1.0/24"), "new-dc")
+			lpm.Insert(netip.MustParsePrefix("2001:db8:2:3::/64"), "new-v6")
+		}},
+		{"longer values", func(lpm *LPM) {
+			lpm.Insert(netip.MustParsePrefix("192.0.2.0/24"), strings.Repeat("long", 100))
+		}},
+		{"fewer values", func(lpm *LPM) {
+			for i := range 300 {
+				lpm.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)), "DC0")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lpm := newPackTestLPM()
+			tt.modify(lpm)
+			want := pack(t, lpm)
+
+			patch, err := DiffStorage(old, want)
+			if err != nil {
+				t.Fatalf("DiffStorage failed: %v", err)
+			}
+			got, err := ApplyPatch(old, patch)
+			if err != nil {
+				t.Fatalf("ApplyPatch failed: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatal("patched storage differs from the new storage")
+			}
+			if _, err := NewWithSharedStorage(got); err != nil {
+				t.Errorf("NewWithSharedStorage(patched) failed: %v", err)
+			}
+		})
+	}
+
+	t.Run("small", func(t *testing.T) {
+		lpm := newPackTestLPM()
+		lpm.Insert(netip.MustParsePrefix("10.0.5.0/24"), "DC1")
+		patch, err := DiffStorage(old, pack(t, lpm))
+		if err != nil {
+			t.Fatalf("DiffStorage failed: %v", err)
+		}
+		// The header and the one block holding the changed slot
+		if max := 8 + headerSize(currentVersion) + 3 + 2 + blockSize*4; len(patch) > max {
+			t.Errorf("patch is %d bytes, want at most %d", len(patch), max)
+		}
+	})
+}
+
+// TestApplyPatchErrors tests that patches are only applied to their base and malformed ones are rejected
+func TestApplyPatchErrors(t *testing.T) {
+	old, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	lpm := newPackTestLPM()
+	lpm.Insert(netip.MustParsePrefix("10.200.1.0/24"), "new-dc")
+	new, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	patch, err := DiffStorage(old, new)
+	if err != nil {
+		t.Fatalf("DiffStorage failed: %v", err)
+	}
+
+	if _, err := ApplyPatch(new, patch); !errors.Is(err, ErrPatchMismatch) {
+		t.Errorf("ApplyPatch(wrong base) error = %v, want %v", err, ErrPatchMismatch)
+	}
+
+	for _, size := range []int{0, 10, len(patch) / 2, len(patch) - 1} {
+		if _, err := ApplyPatch(old, patch[:size]); err == nil {
+			t.Errorf("ApplyPatch(%d of %d bytes) succeeded", size, len(patch))
+		}
+	}
+
+	// The patch ends with the last changed value slot and the empty metadata section
+	corrupted := bytes.Clone(patch)
+	corrupted[len(corrupted)-2] ^= 0xFF
+	if _, err := ApplyPatch(old, corrupted); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("ApplyPatch(corrupted) error = %v, want %v", err, ErrChecksumMismatch)
+	}
+
+	if _, err := DiffStorage(packV1(t, newPackTestLPM()), new); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("DiffStorage(v1) error = %v, want %v", err, ErrVersionMismatch)
+	}
+}
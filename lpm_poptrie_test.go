@@ -0,0 +1,145 @@
+package lpm
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestPoptrie tests that a Poptrie looks up the same values as the trie it was built from
+func TestPoptrie(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	m := New()
+	for range 1000 {
+		var addr netip.Addr
+		var bits int
+		if rng.Intn(2) == 0 {
+			addr = netip.AddrFrom4([4]byte{byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+			bits = rng.Intn(33)
+		} else {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, byte(rng.Intn(4)), byte(rng.Intn(256)), 15: byte(rng.Intn(256))})
+			bits = rng.Intn(129)
+		}
+		prefix := netip.PrefixFrom(addr, bits)
+		if rng.Intn(10) == 0 {
+			m.InsertTombstone(prefix)
+		} else {
+			m.InsertWithPriority(prefix, string(rune('a'+rng.Intn(20))), uint8(rng.Intn(2)))
+		}
+	}
+	p := m.Poptrie()
+
+	for range 20000 {
+		var addr netip.Addr
+		if rng.Intn(2) == 0 {
+			addr = netip.AddrFrom4([4]byte{byte(rng.Intn(5)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		} else {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, byte(rng.Intn(5)), byte(rng.Intn(256)), 15: byte(rng.Intn(256))})
+		}
+		want, wantFound := m.Lookup(addr)
+		got, found := p.Lookup(addr)
+		if got != want || found != wantFound {
+			t.Fatalf("Lookup(%s) = %q (found=%v), want %q (found=%v)", addr, got, found, want, wantFound)
+		}
+		wantIdx, _ := m.LookupIndex(addr)
+		if gotIdx, _ := p.LookupIndex(addr); gotIdx != wantIdx {
+			t.Fatalf("LookupIndex(%s) = %d, want %d", addr, gotIdx, wantIdx)
+		}
+	}
+
+	// Later modifications are not reflected
+	addr := netip.MustParseAddr("200.0.0.1")
+	m.Insert(netip.MustParsePrefix("200.0.0.0/8"), "late")
+	if got, _ := p.Lookup(addr); got == "late" {
+		t.Errorf("Lookup(%s) found a prefix inserted after building", addr)
+	}
+	if got, _ := p.Lookup4(0xC8000001); got == "late" {
+		t.Errorf("Lookup4(%s) found a prefix inserted after building", addr)
+	}
+}
+
+// TestPoptrieSparse tests the memory taken by sparse host routes
+func TestPoptrieSparse(t *testing.T) {
+	m := New()
+	empty := New().Poptrie()
+	for i := range 1000 {
+		addr := netip.AddrFrom4([4]byte{10, byte(i >> 8), byte(i), 1})
+		m.Insert(netip.PrefixFrom(addr, 32), "host")
+	}
+	p := m.Poptrie()
+
+	if got, _ := p.Lookup(netip.MustParseAddr("10.3.231.1")); got != "host" {
+		t.Errorf("Lookup(10.3.231.1) = %q, want host", got)
+	}
+	if _, found := p.Lookup(netip.MustParseAddr("10.3.231.2")); found {
+		t.Error("Lookup(10.3.231.2) found a value")
+	}
+	if _, found := empty.Lookup(netip.MustParseAddr("10.3.231.1")); found {
+		t.Error("empty Poptrie found a value")
+	}
+
+	flat, compressed := m.Stats(), p.Stats()
+	if compressed.IPv4Blocks > flat.IPv4Blocks {
+		t.Errorf("Poptrie has %d IPv4 nodes, trie has %d blocks", compressed.IPv4Blocks, flat.IPv4Blocks)
+	}
+	if compressed.IPv4StorageSize*10 > flat.IPv4StorageSize {
+		t.Errorf("Poptrie takes %d bytes for IPv4, trie %d", compressed.IPv4StorageSize, flat.IPv4StorageSize)
+	}
+}
+
+func BenchmarkPoptrie(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	m := New()
+	for range 100000 {
+		addr := netip.AddrFrom4([4]byte{byte(rng.Intn(224)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		m.Insert(netip.PrefixFrom(addr, 16+rng.Intn(17)), "v")
+	}
+	p := m.Poptrie()
+	addrs := make([]uint32, 4096)
+	for i := range addrs {
+		addrs[i] = rng.Uint32()
+	}
+	b.Run("flat", func(b *testing.B) {
+		for i := range b.N {
+			m.Lookup4(addrs[i%len(addrs)])
+		}
+	})
+	b.Run("poptrie", func(b *testing.B) {
+		for i := range b.N {
+			p.Lookup4(addrs[i%len(addrs)])
+		}
+	})
+}
+
+// TestPoptriePathCompression tests that chains of single-child blocks collapse into one node
+func TestPoptriePathCompression(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	hosts := []string{"2001:db8::1", "2001:db8:0:1::1", "2001:db8:1::ff"}
+	for _, host := range hosts {
+		m.Insert(netip.PrefixFrom(netip.MustParseAddr(host), 128), host)
+	}
+	p := m.Poptrie()
+
+	for _, host := range hosts {
+		if got, _ := p.Lookup(netip.MustParseAddr(host)); got != host {
+			t.Errorf("Lookup(%s) = %q, want itself", host, got)
+		}
+	}
+	// Addresses leaving the chains at every depth
+	for _, addr := range []string{"2001:db8::2", "2001:db8::100:1", "2001:db8:0:1::2", "2001:db8:1::fe", "2001:db8:ff::1"} {
+		if got, _ := p.Lookup(netip.MustParseAddr(addr)); got != "doc" {
+			t.Errorf("Lookup(%s) = %q, want doc", addr, got)
+		}
+	}
+	for _, addr := range []string{"2001:db9::1", "::1", "3000::"} {
+		if got, found := p.Lookup(netip.MustParseAddr(addr)); found {
+			t.Errorf("Lookup(%s) = %q, want no match", addr, got)
+		}
+	}
+
+	flat, compressed := m.Stats(), p.Stats()
+	if compressed.IPv6Blocks*4 > flat.IPv6Blocks {
+		t.Errorf("Poptrie has %d IPv6 nodes for %d blocks", compressed.IPv6Blocks, flat.IPv6Blocks)
+	}
+}
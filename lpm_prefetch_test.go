@@ -0,0 +1,84 @@
+package lpm
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestLookupPrefetch tests that batch lookups return what Lookup returns
+func TestLookupPrefetch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 2000)
+	plain, err := Build(entries)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	stride, err := Build(entries, IPv4Stride16())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	storage, err := plain.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+
+	addrs := []netip.Addr{{}, netip.MustParseAddr("::ffff:10.0.0.1")}
+	for _, e := range entries {
+		addrs = append(addrs, e.Prefix.Addr(), e.Prefix.Masked().Addr())
+	}
+	for range 1000 {
+		addrs = append(addrs, netip.AddrFrom4([4]byte{10, byte(rng.Intn(5)), byte(rng.Intn(5)), byte(rng.Intn(256))}))
+	}
+	values := make([]string, len(addrs))
+	found := make([]bool, len(addrs))
+
+	for name, m := range map[string]*LPM{"dynamic": plain, "stride16": stride, "shared": shared} {
+		for _, n := range []int{0, 1, prefetchWidth - 1, len(addrs)} {
+			m.LookupPrefetch(addrs[:n], values, found)
+			for i, addr := range addrs[:n] {
+				want, wantFound := plain.Lookup(addr)
+				if values[i] != want || found[i] != wantFound {
+					t.Fatalf("%s: LookupPrefetch(%s) = %q (found=%v), want %q (found=%v)", name, addr, values[i], found[i], want, wantFound)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkLookupPrefetch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	entries := make([]PrefixValue, 500000)
+	for i := range entries {
+		addr := netip.AddrFrom4([4]byte{byte(rng.Intn(224)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+		entries[i] = PrefixValue{Prefix: netip.PrefixFrom(addr, 20+rng.Intn(13)), Value: "v"}
+	}
+	m, err := Build(entries)
+	if err != nil {
+		b.Fatal(err)
+	}
+	addrs := make([]netip.Addr, 1<<16)
+	for i := range addrs {
+		addrs[i] = netip.AddrFrom4([4]byte{byte(rng.Intn(224)), byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))})
+	}
+	values := make([]string, 256)
+	found := make([]bool, 256)
+
+	b.Run("Lookup", func(b *testing.B) {
+		for i := 0; i < b.N; i += len(values) {
+			batch := addrs[i%len(addrs):][:len(values)]
+			for j, addr := range batch {
+				values[j], found[j] = m.Lookup(addr)
+			}
+		}
+	})
+	b.Run("LookupPrefetch", func(b *testing.B) {
+		for i := 0; i < b.N; i += len(values) {
+			m.LookupPrefetch(addrs[i%len(addrs):][:len(values)], values, found)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package lpm
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+)
+
+// TestInsertWithPriority tests that priority takes precedence over prefix length
+func TestInsertWithPriority(t *testing.T) {
+	type insert struct {
+		cidr     string
+		value    string
+		priority uint8
+	}
+	tests := []struct {
+		name    string
+		inserts []insert
+		lookups []struct{ addr, want string }
+	}{
+		{
+			name: "broader high priority inserted first",
+			inserts: []insert{
+				{"10.0.0.0/8", "sourceA", 1},
+				{"10.1.1.0/24", "sourceB", 0},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.1.1.1", "sourceA"},
+				{"10.2.0.1", "sourceA"},
+			},
+		},
+		{
+			name: "broader high priority inserted last",
+			inserts: []insert{
+				{"10.1.1.0/24", "sourceB", 0},
+				{"10.1.0.0/16", "sourceA", 1},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.1.1.1", "sourceA"},
+				{"10.1.2.1", "sourceA"},
+			},
+		},
+		{
+			name: "broader high priority inserted over nested prefixes",
+			inserts: []insert{
+				{"10.1.1.0/24", "sourceB", 0},
+				{"10.1.1.128/25", "sourceC", 0},
+				{"10.1.1.192/28", "sourceD", 2},
+				{"10.0.0.0/8", "sourceA", 1},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.1.1.1", "sourceA"},
+				{"10.1.1.130", "sourceA"},
+				{"10.1.1.193", "sourceD"},
+				{"10.2.0.1", "sourceA"},
+			},
+		},
+		{
+			name: "broader low priority inserted under nested prefixes",
+			inserts: []insert{
+				{"10.1.1.0/24", "sourceB", 2},
+				{"10.1.1.128/25", "sourceC", 3},
+				{"10.0.0.0/8", "sourceA", 1},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.1.1.1", "sourceB"},
+				{"10.1.1.130", "sourceC"},
+				{"10.1.2.1", "sourceA"},
+			},
+		},
+		{
+			name: "more specific wins at equal priority",
+			inserts: []insert{
+				{"10.0.0.0/8", "broad", 2},
+				{"10.1.0.0/16", "specific", 2},
+				{"10.1.1.0/24", "low", 1},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.2.0.1", "broad"},
+				{"10.1.2.1", "specific"},
+				{"10.1.1.1", "specific"},
+			},
+		},
+		{
+			name: "more specific with higher priority",
+			inserts: []insert{
+				{"10.0.0.0/8", "broad", 1},
+				{"10.1.0.0/16", "specific", 2},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.2.0.1", "broad"},
+				{"10.1.2.1", "specific"},
+			},
+		},
+		{
+			name: "same value with different priorities",
+			inserts: []insert{
+				{"192.168.0.0/16", "dc1", 1},
+				{"192.168.1.0/24", "dc2", 0},
+				{"192.168.2.0/24", "dc1", 0},
+			},
+			lookups: []struct{ addr, want string }{
+				{"192.168.1.1", "dc1"},
+				{"192.168.2.1", "dc1"},
+				{"192.168.3.1", "dc1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lpm := New()
+			for _, ins := range tt.inserts {
+				lpm.InsertWithPriority(netip.MustParsePrefix(ins.cidr), ins.value, ins.priority)
+			}
+
+			storage, err := lpm.PackToSharedStorage()
+			if err != nil {
+				t.Fatalf("PackToSharedStorage failed: %v", err)
+			}
+			shared, err := NewWithSharedStorage(storage)
+			if err != nil {
+				t.Fatalf("NewWithSharedStorage failed: %v", err)
+			}
+
+			for name, lpm := range map[string]*LPM{"dynamic": lpm, "shared": shared} {
+				for _, l := range tt.lookups {
+					got, found := lpm.Lookup(netip.MustParseAddr(l.addr))
+					if !found || got != l.want {
+						t.Errorf("%s: Lookup(%s) = %q (found=%v), want %q", name, l.addr, got, found, l.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestPrioritySurvivesSharedStorage tests that priorities stored in shared storage
+// are honored by inserts made after loading
+func TestPrioritySurvivesSharedStorage(t *testing.T) {
+	base := New()
+	base.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "policy", 5)
+
+	storage, err := base.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	lpm, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "feed")
+	if got, _ := lpm.Lookup(netip.MustParseAddr("10.1.2.3")); got != "policy" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want %q", got, "policy")
+	}
+
+	lpm.InsertWithPriority(netip.MustParsePrefix("10.2.0.0/16"), "override", 6)
+	if got, _ := lpm.Lookup(netip.MustParseAddr("10.2.3.4")); got != "override" {
+		t.Errorf("Lookup(10.2.3.4) = %q, want %q", got, "override")
+	}
+
+	// The shared value is reused for the same priority only
+	lpm.InsertWithPriority(netip.MustParsePrefix("172.16.0.0/12"), "policy", 5)
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "policy")
+	idxShared, _ := lpm.LookupIndex(netip.MustParseAddr("10.3.0.1"))
+	idxSame, _ := lpm.LookupIndex(netip.MustParseAddr("172.16.0.1"))
+	idxOther, _ := lpm.LookupIndex(netip.MustParseAddr("192.168.0.1"))
+	if idxShared != idxSame {
+		t.Errorf("same value and priority have different indices: %d != %d", idxShared, idxSame)
+	}
+	if idxShared == idxOther {
+		t.Errorf("same value with different priority shares index %d", idxShared)
+	}
+}
+
+// TestPriorityJSON tests that priorities survive a JSON round trip
+func TestPriorityJSON(t *testing.T) {
+	lpm := New()
+	lpm.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "A", 3)
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "B")
+
+	data, err := json.Marshal(lpm)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	want := `[{"cidr":"10.0.0.0/8","value":"A","priority":3},{"cidr":"192.168.0.0/16","value":"B"}]`
+	if string(data) != want {
+		t.Errorf("MarshalJSON = %s, want %s", data, want)
+	}
+
+	restored := New()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	restored.Insert(netip.MustParsePrefix("10.1.0.0/16"), "C")
+	if got, _ := restored.Lookup(netip.MustParseAddr("10.1.2.3")); got != "A" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want %q", got, "A")
+	}
+}
@@ -0,0 +1,129 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/iotest"
+	"unsafe"
+)
+
+// TestNewFromReader tests loading storage from a stream
+func TestNewFromReader(t *testing.T) {
+	lpm := newPackTestLPM()
+
+	var buf bytes.Buffer
+	if _, err := lpm.PackTo(&buf); err != nil {
+		t.Fatalf("PackTo failed: %v", err)
+	}
+	// Trailing data after the storage must be left unread
+	buf.WriteString("trailer")
+
+	loaded, err := NewFromReader(&buf)
+	if err != nil {
+		t.Fatalf("NewFromReader failed: %v", err)
+	}
+	if rest := buf.String(); rest != "trailer" {
+		t.Errorf("unread data = %q, want %q", rest, "trailer")
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.0.5.1", "DC5"},
+		{"2001:db8:1::1", "doc-subnet"},
+	}
+	for _, tt := range tests {
+		got, found := loaded.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+}
+
+// TestNewFromReaderAt tests loading storage from a file
+func TestNewFromReaderAt(t *testing.T) {
+	lpm := newPackTestLPM()
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	if err := os.WriteFile(path, storage, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	loaded, err := NewFromReaderAt(f)
+	if err != nil {
+		t.Fatalf("NewFromReaderAt failed: %v", err)
+	}
+	if got, found := loaded.Lookup(netip.MustParseAddr("10.0.5.1")); !found || got != "DC5" {
+		t.Errorf("Lookup(10.0.5.1) = %q (found=%v), want %q", got, found, "DC5")
+	}
+}
+
+// TestNewFromReaderTruncated tests that truncated streams are rejected
+func TestNewFromReaderTruncated(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	for _, size := range []int{0, 10, len(storage) / 2, len(storage) - 1} {
+		_, err := NewFromReader(bytes.NewReader(storage[:size]))
+		var truncated *ErrTruncated
+		if !errors.As(err, &truncated) || truncated.Got != size {
+			t.Errorf("NewFromReader(%d of %d bytes) error = %v, want ErrTruncated", size, len(storage), err)
+		}
+	}
+
+	// Errors other than the stream ending early are passed through
+	failing := io.MultiReader(bytes.NewReader(storage[:100]), iotest.ErrReader(os.ErrClosed))
+	if _, err := NewFromReader(failing); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("NewFromReader(failing reader) error = %v, want %v", err, os.ErrClosed)
+	}
+
+	if _, err := NewFromReader(bytes.NewReader(make([]byte, 64))); err == nil {
+		t.Error("NewFromReader(zeroes) succeeded, want bad magic error")
+	}
+}
+
+// TestNewFromReaderHugeHeader tests that headers declaring more storage than the stream
+// holds fail without allocating the declared size
+func TestNewFromReaderHugeHeader(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
+	v6BlockCount := header.V6BlockCount
+
+	header.V6BlockCount = 1 << 62
+	if _, err := NewFromReader(bytes.NewReader(storage)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("NewFromReader(%d IPv6 blocks) error = %v, want %v", header.V6BlockCount, err, ErrCorrupt)
+	}
+
+	// 512MB of blocks
+	header.V6BlockCount = v6BlockCount + 1<<19
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = NewFromReader(bytes.NewReader(storage))
+	runtime.ReadMemStats(&after)
+	var truncated *ErrTruncated
+	if !errors.As(err, &truncated) || truncated.Got != len(storage) {
+		t.Errorf("NewFromReader(%d IPv6 blocks) error = %v, want ErrTruncated", header.V6BlockCount, err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 2*readChunkSize {
+		t.Errorf("NewFromReader allocated %d bytes for %d bytes of storage", allocated, len(storage))
+	}
+}
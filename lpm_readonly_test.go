@@ -0,0 +1,78 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// TestReadOnly tests that inserts into a read-only LPM fail without touching the storage
+func TestReadOnly(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	original := bytes.Clone(storage)
+
+	loaded, err := NewWithSharedStorage(storage, ReadOnly())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	prefix := netip.MustParsePrefix("10.1.0.0/16")
+	inserts := []struct {
+		name   string
+		insert func() error
+	}{
+		{"Insert", func() error { return loaded.Insert(prefix, "dc1") }},
+		{"InsertWithPriority", func() error { return loaded.InsertWithPriority(prefix, "dc1", 1) }},
+		{"InsertTombstone", func() error { return loaded.InsertTombstone(prefix) }},
+		{"InsertBytes", func() error { return loaded.InsertBytes(prefix, []byte("dc1")) }},
+		{"InsertString", func() error { return loaded.InsertString("10.1.0.0/16", "dc1") }},
+	}
+	for _, tt := range inserts {
+		if err := tt.insert(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s error = %v, want %v", tt.name, err, ErrReadOnly)
+		}
+	}
+
+	if !bytes.Equal(storage, original) {
+		t.Error("inserts into a read-only LPM modified the storage")
+	}
+	if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); got != "private" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want private", got)
+	}
+
+	// Without the option the LPM stays writable
+	writable, err := NewWithSharedStorage(bytes.Clone(original))
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if err := writable.Insert(prefix, "dc1"); err != nil {
+		t.Errorf("Insert failed: %v", err)
+	}
+}
+
+// TestReadOnlyMulti tests that a read-only multi-value trie keeps its sets on failed inserts
+func TestReadOnlyMulti(t *testing.T) {
+	m := NewMulti()
+	m.Add(netip.MustParsePrefix("10.0.0.0/8"), "internal")
+	storage, err := m.LPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	loaded, err := NewMultiWithSharedStorage(storage, ReadOnly())
+	if err != nil {
+		t.Fatalf("NewMultiWithSharedStorage failed: %v", err)
+	}
+	if err := loaded.Add(netip.MustParsePrefix("10.0.0.0/8"), "lab"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Add error = %v, want %v", err, ErrReadOnly)
+	}
+	if got := loaded.Values(netip.MustParsePrefix("10.0.0.0/8")); len(got) != 1 || got[0] != "internal" {
+		t.Errorf("Values(10.0.0.0/8) = %q, want [internal]", got)
+	}
+}
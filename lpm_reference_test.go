@@ -0,0 +1,138 @@
+package lpm
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/sakateka/lpm/reference"
+)
+
+var _ reference.Table = (*LPM)(nil)
+
+// differentialOp applies one operation encoded in 6 bytes to both tables: the operation,
+// three address bytes, the prefix length and the value. Prefixes are IPv4 in 10.0.0.0/8,
+// or IPv6 in 2001:db8::/32 when bit 7 of the operation is set. With priorities, bits 2
+// and 3 of the operation give the priority of inserts.
+func differentialOp(t *testing.T, m *LPM, ref *reference.LPM, op []byte, priorities bool) {
+	var prefix netip.Prefix
+	if op[0]&0x80 != 0 {
+		addr := netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, op[1], op[2], op[3]})
+		prefix = netip.PrefixFrom(addr, int(op[4])%129)
+	} else {
+		addr := netip.AddrFrom4([4]byte{10, op[1], op[2], op[3]})
+		prefix = netip.PrefixFrom(addr, int(op[4])%33)
+	}
+	priority := uint8(0)
+	if priorities {
+		priority = op[0] >> 2 & 3
+	}
+	value := fmt.Sprintf("v%d", op[5])
+
+	var err, refErr error
+	switch kind := op[0] & 3; {
+	case kind <= 1:
+		err, refErr = m.InsertWithPriority(prefix, value, priority), ref.InsertWithPriority(prefix, value, priority)
+	case kind == 2:
+		err, refErr = m.InsertTombstone(prefix), ref.InsertTombstone(prefix)
+	default:
+		var deleted, refDeleted bool
+		deleted, err = m.Delete(prefix)
+		refDeleted, refErr = ref.Delete(prefix)
+		if deleted != refDeleted {
+			t.Fatalf("Delete(%s) = %v, reference %v", prefix, deleted, refDeleted)
+		}
+	}
+	if (err != nil) != (refErr != nil) {
+		t.Fatalf("op %x on %s: error %v, reference %v", op[0], prefix, err, refErr)
+	}
+}
+
+// differentialCheck compares lookups of addresses around every prefix boundary
+func differentialCheck(t *testing.T, m *LPM, ref *reference.LPM, addrs []netip.Addr) {
+	for _, addr := range addrs {
+		got, found := m.Lookup(addr)
+		want, wantFound := ref.Lookup(addr)
+		if got != want || found != wantFound {
+			t.Fatalf("Lookup(%s) = %q (found=%v), reference %q (found=%v)", addr, got, found, want, wantFound)
+		}
+	}
+}
+
+// differentialAddrs returns addresses probing the ranges the ops touch
+func differentialAddrs(data []byte) []netip.Addr {
+	var addrs []netip.Addr
+	for i := 0; i+5 < len(data); i += 6 {
+		for _, last := range []byte{0, 1, 0x7f, 0x80, 0xff} {
+			addrs = append(addrs,
+				netip.AddrFrom4([4]byte{10, data[i+1], data[i+2], last}),
+				netip.AddrFrom4([4]byte{10, data[i+1], last, last}),
+				netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, data[i+1], data[i+2], last}),
+				netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, data[i+1], last, last}))
+		}
+	}
+	return addrs
+}
+
+// FuzzDifferential compares the trie against the reference implementation after random
+// inserts, tombstones and deletes
+func FuzzDifferential(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 0, 16, 1, 0, 1, 1, 0, 24, 2, 3, 1, 0, 0, 16, 0})
+	f.Add([]byte{2, 0, 0, 0, 8, 0, 0, 5, 5, 5, 32, 1, 0x80, 0, 0, 0, 32, 3, 0x83, 0, 0, 0, 32, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, ref := New(), reference.New()
+		for i := 0; i+5 < len(data); i += 6 {
+			differentialOp(t, m, ref, data[i:i+6], false)
+		}
+		if err := m.Verify(); err != nil {
+			t.Fatalf("Verify() = %v", err)
+		}
+		differentialCheck(t, m, ref, differentialAddrs(data))
+	})
+}
+
+// FuzzDifferentialPriority compares the trie against the reference implementation after
+// random inserts with priorities, tombstones and deletes, which uncover the prefixes a
+// higher priority shadowed
+func FuzzDifferentialPriority(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 0, 16, 1, 0, 1, 1, 0, 24, 2, 2, 1, 0, 0, 20, 0})
+	f.Add([]byte{4, 0, 0, 0, 24, 1, 0, 0, 0, 0, 25, 2, 0, 0, 0x80, 0, 25, 3, 3, 0, 0, 0, 24, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, ref := New(), reference.New()
+		for i := 0; i+5 < len(data); i += 6 {
+			differentialOp(t, m, ref, data[i:i+6], true)
+		}
+		if err := m.Verify(); err != nil {
+			t.Fatalf("Verify() = %v", err)
+		}
+		differentialCheck(t, m, ref, differentialAddrs(data))
+	})
+}
+
+// TestDifferentialRandom runs the differential checks on random overlapping inserts,
+// tombstones and deletes, with and without priorities
+func TestDifferentialRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := range 100 {
+		data := make([]byte, 6*(1+rng.Intn(80)))
+		for i := range data {
+			data[i] = byte(rng.Intn(256))
+			if i%6 >= 1 && i%6 <= 3 {
+				data[i] %= 4 // keep prefixes overlapping
+			}
+		}
+		t.Run(fmt.Sprint(round), func(t *testing.T) {
+			m, ref := New(), reference.New()
+			for i := 0; i+5 < len(data); i += 6 {
+				differentialOp(t, m, ref, data[i:i+6], round%2 == 1)
+				if i%60 == 0 {
+					differentialCheck(t, m, ref, differentialAddrs(data[:i+6]))
+				}
+			}
+			differentialCheck(t, m, ref, differentialAddrs(data))
+		})
+	}
+}
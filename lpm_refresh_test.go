@@ -0,0 +1,178 @@
+package lpm
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// feed is a Source serving the prefixes set last, or failing with err
+type feed struct {
+	entries atomic.Pointer[[]PrefixValue]
+	err     atomic.Pointer[error]
+	calls   atomic.Int64
+}
+
+func (f *feed) set(entries ...PrefixValue) {
+	f.entries.Store(&entries)
+}
+
+func (f *feed) fail(err error) {
+	if err == nil {
+		f.err.Store(nil)
+		return
+	}
+	f.err.Store(&err)
+}
+
+func (f *feed) fetch(ctx context.Context) ([]PrefixValue, error) {
+	f.calls.Add(1)
+	if err := f.err.Load(); err != nil {
+		return nil, *err
+	}
+	return *f.entries.Load(), nil
+}
+
+func pv(prefix, value string) PrefixValue {
+	return PrefixValue{Prefix: netip.MustParsePrefix(prefix), Value: value}
+}
+
+// TestRefresher tests manual refreshes, failures and skipping of unchanged tables
+func TestRefresher(t *testing.T) {
+	var f feed
+	f.set(pv("10.0.0.0/8", "private"), PrefixValue{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Tombstone: true})
+
+	var hooked atomic.Int64
+	r, err := NewRefresher(context.Background(), f.fetch, time.Hour, RefreshHook(func(RefreshStats) { hooked.Add(1) }))
+	if err != nil {
+		t.Fatalf("NewRefresher: %v", err)
+	}
+	defer r.Close()
+
+	if got, ok := r.Lookup(netip.MustParseAddr("10.2.0.1")); !ok || got != "private" {
+		t.Errorf("Lookup(10.2.0.1) = %q, %v, want private", got, ok)
+	}
+	if _, ok := r.Lookup(netip.MustParseAddr("10.1.0.1")); ok {
+		t.Errorf("Lookup(10.1.0.1) matched a tombstoned prefix")
+	}
+	if err := r.Load().Insert(netip.MustParsePrefix("192.168.0.0/16"), "home"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Insert into refreshed table: got %v, want ErrReadOnly", err)
+	}
+
+	// An unchanged feed keeps the current table
+	first := r.Load()
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if r.Load() != first {
+		t.Errorf("unchanged table was swapped in")
+	}
+
+	// A failure keeps the current table
+	f.fail(errors.New("feed down"))
+	if err := r.Refresh(context.Background()); err == nil {
+		t.Fatalf("Refresh of a failing feed succeeded")
+	}
+	if r.Load() != first {
+		t.Errorf("failed refresh replaced the table")
+	}
+	stats := r.Stats()
+	if stats.ConsecutiveFailures != 1 || stats.Failures != 1 || stats.LastError == nil {
+		t.Errorf("stats after failure: %+v", stats)
+	}
+
+	f.fail(nil)
+	f.set(pv("10.0.0.0/8", "changed"))
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if got, _ := r.Lookup(netip.MustParseAddr("10.1.0.1")); got != "changed" {
+		t.Errorf("Lookup after refresh = %q, want changed", got)
+	}
+
+	stats = r.Stats()
+	want := RefreshStats{Attempts: 4, Failures: 1, Swaps: 2, Prefixes: 1}
+	if stats.Attempts != want.Attempts || stats.Failures != want.Failures || stats.Swaps != want.Swaps ||
+		stats.Prefixes != want.Prefixes || stats.ConsecutiveFailures != 0 || stats.LastError != nil {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+	if hooked.Load() != 4 {
+		t.Errorf("hook called %d times, want 4", hooked.Load())
+	}
+}
+
+// TestRefresherErrors tests that a failing first refresh or an invalid entry are reported
+func TestRefresherErrors(t *testing.T) {
+	var f feed
+	f.fail(errors.New("feed down"))
+	if _, err := NewRefresher(context.Background(), f.fetch, time.Hour); err == nil {
+		t.Errorf("NewRefresher with a failing feed succeeded")
+	}
+
+	f.fail(nil)
+	f.set(PrefixValue{Value: "no prefix"})
+	if _, err := NewRefresher(context.Background(), f.fetch, time.Hour); err == nil {
+		t.Errorf("NewRefresher with an invalid prefix succeeded")
+	}
+
+	f.set(pv("10.0.0.0/8", "private"))
+	if _, err := NewRefresher(context.Background(), f.fetch, 0); err == nil {
+		t.Errorf("NewRefresher with zero interval succeeded")
+	}
+}
+
+// TestRefresherSchedule tests that the table is refreshed in the background
+func TestRefresherSchedule(t *testing.T) {
+	var f feed
+	f.set(pv("10.0.0.0/8", "v1"))
+	r, err := NewRefresher(context.Background(), f.fetch, time.Millisecond, RefreshJitter(time.Millisecond/2))
+	if err != nil {
+		t.Fatalf("NewRefresher: %v", err)
+	}
+
+	f.set(pv("10.0.0.0/8", "v2"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, _ := r.Lookup(netip.MustParseAddr("10.0.0.1")); got == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("table was not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	calls := f.calls.Load()
+	time.Sleep(10 * time.Millisecond)
+	if f.calls.Load() != calls {
+		t.Errorf("source called after Close")
+	}
+	if got, _ := r.Lookup(netip.MustParseAddr("10.0.0.1")); got != "v2" {
+		t.Errorf("Lookup after Close = %q, want v2", got)
+	}
+}
+
+// TestRefresherBackoff tests the delays after consecutive failures
+func TestRefresherBackoff(t *testing.T) {
+	r := &Refresher{interval: time.Minute}
+	RefreshBackoff(time.Second, 10*time.Second)(&r.opts)
+
+	for failures, want := range []time.Duration{time.Minute, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := r.nextDelay(failures); got != want {
+			t.Errorf("nextDelay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+
+	RefreshJitter(time.Second)(&r.opts)
+	for range 100 {
+		if got := r.nextDelay(0); got < time.Minute-time.Second || got > time.Minute+time.Second {
+			t.Fatalf("nextDelay(0) with jitter = %v, want within a second of a minute", got)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+//go:build !lpm_safe
+
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// newSeqStorage packs lpm into an 8-byte aligned buffer with room to grow
+func newSeqStorage(t *testing.T, lpm *LPM, size int) []byte {
+	t.Helper()
+
+	words := make([]uint64, (size+7)/8)
+	storage := unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), size)
+	if _, err := lpm.PackInto(storage); err != nil {
+		t.Fatalf("PackInto failed: %v", err)
+	}
+	return storage
+}
+
+// TestSeqLock tests publishing updates in place to a reader of the same storage
+func TestSeqLock(t *testing.T) {
+	lpm := newPackTestLPM()
+	storage := newSeqStorage(t, lpm, 2*lpm.EstimatePackedSize())
+
+	writer, err := NewSeqWriter(storage)
+	if err != nil {
+		t.Fatalf("NewSeqWriter failed: %v", err)
+	}
+	reader, err := NewSeqReader(storage)
+	if err != nil {
+		t.Fatalf("NewSeqReader failed: %v", err)
+	}
+
+	lookup := func(addr string) string {
+		value, _ := reader.Lookup(netip.MustParseAddr(addr))
+		return value
+	}
+	if got := lookup("10.1.2.3"); got != "DC6" {
+		t.Fatalf("Lookup(10.1.2.3) = %q, want DC6", got)
+	}
+
+	writer.LPM().Insert(netip.MustParsePrefix("10.1.2.0/24"), "updated")
+	writer.LPM().Insert(netip.MustParsePrefix("192.0.2.0/24"), "test-net")
+	if got := lookup("10.1.2.3"); got != "DC6" {
+		t.Errorf("Lookup(10.1.2.3) before Publish = %q, want DC6", got)
+	}
+
+	if err := writer.Publish(); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if got := lookup("10.1.2.3"); got != "updated" {
+		t.Errorf("Lookup(10.1.2.3) after Publish = %q, want updated", got)
+	}
+	if got := lookup("192.0.2.1"); got != "test-net" {
+		t.Errorf("Lookup(192.0.2.1) after Publish = %q, want test-net", got)
+	}
+
+	// The sequence counter does not invalidate the checksum
+	header, _, _ := readHeader(storage)
+	if header.Sequence != 2 {
+		t.Errorf("Sequence = %d, want 2", header.Sequence)
+	}
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if value, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); value != "updated" {
+		t.Errorf("loaded Lookup(10.1.2.3) = %q, want updated", value)
+	}
+
+	// A second publish works from the reloaded trie
+	writer.LPM().Delete(netip.MustParsePrefix("192.0.2.0/24"))
+	if err := writer.Publish(); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if value, found := reader.Lookup(netip.MustParseAddr("192.0.2.1")); found {
+		t.Errorf("Lookup(192.0.2.1) after Delete = %q, want no match", value)
+	}
+}
+
+// TestSeqLockWaitsForWriter tests that readers wait while an update is in progress
+func TestSeqLockWaitsForWriter(t *testing.T) {
+	lpm := newPackTestLPM()
+	storage := newSeqStorage(t, lpm, lpm.EstimatePackedSize())
+	reader, err := NewSeqReader(storage)
+	if err != nil {
+		t.Fatalf("NewSeqReader failed: %v", err)
+	}
+	seq, err := sequence(storage)
+	if err != nil {
+		t.Fatalf("sequence failed: %v", err)
+	}
+
+	atomic.StoreUint64(seq, 1)
+	result := make(chan string)
+	go func() {
+		value, _ := reader.Lookup(netip.MustParseAddr("10.1.2.3"))
+		result <- value
+	}()
+	select {
+	case value := <-result:
+		t.Fatalf("Lookup returned %q during an update", value)
+	case <-time.After(20 * time.Millisecond):
+	}
+	atomic.StoreUint64(seq, 2)
+	if value := <-result; value != "DC6" {
+		t.Errorf("Lookup after the update = %q, want DC6", value)
+	}
+}
+
+// TestSeqLockErrors tests storage that cannot be updated in place
+func TestSeqLockErrors(t *testing.T) {
+	lpm := newPackTestLPM()
+	size := lpm.EstimatePackedSize()
+	storage := newSeqStorage(t, lpm, size)
+
+	writer, err := NewSeqWriter(storage)
+	if err != nil {
+		t.Fatalf("NewSeqWriter failed: %v", err)
+	}
+	writer.LPM().Insert(netip.MustParsePrefix("2001:db8:2::/48"), "does not fit")
+	original := bytes.Clone(storage)
+	if err := writer.Publish(); err == nil {
+		t.Error("Publish of a larger trie into full storage succeeded")
+	}
+	if !bytes.Equal(storage, original) {
+		t.Error("failed Publish modified the storage")
+	}
+
+	if _, err := NewSeqReader(packV4(t, lpm)); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("NewSeqReader(v4) error = %v, want %v", err, ErrVersionMismatch)
+	}
+	misaligned := newSeqStorage(t, New(), size+4)[4:]
+	copy(misaligned, storage)
+	if _, err := NewSeqWriter(misaligned); err == nil {
+		t.Error("NewSeqWriter of misaligned storage succeeded")
+	}
+}
+
+// TestSeqLockZeroAddr tests that readers find nothing for the zero Addr
+func TestSeqLockZeroAddr(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("::/0"), "default6")
+	storage := newSeqStorage(t, lpm, 2*lpm.EstimatePackedSize())
+	reader, err := NewSeqReader(storage)
+	if err != nil {
+		t.Fatalf("NewSeqReader failed: %v", err)
+	}
+	if value, found := reader.Lookup(netip.Addr{}); found {
+		t.Errorf("Lookup(zero Addr) = %q, want not found", value)
+	}
+}
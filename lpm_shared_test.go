@@ -1,10 +1,15 @@
 package lpm
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
 	"net/netip"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"unsafe"
 )
 
 // TestSharedStoragePackAndLoad tests packing LPM to shared storage and loading it back
@@ -265,23 +270,329 @@ func TestSharedStorageStats(t *testing.T) {
 	}
 }
 
-// TestSharedStorageValueTooLong tests that values exceeding 255 bytes are rejected
-func TestSharedStorageValueTooLong(t *testing.T) {
+// TestSharedStorageStatsBreakdown tests that Stats splits blocks and values into shared and dynamic ones
+func TestSharedStorageStatsBreakdown(t *testing.T) {
 	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "base")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
 
-	// Create a value that's too long (> 255 bytes)
-	longValue := string(make([]byte, 256))
-	for i := range longValue {
-		longValue = longValue[:i] + "a"
+	check := func(step string, want Stats) {
+		t.Helper()
+		got := loaded.Stats()
+		if got.IPv4SharedBlocks != want.IPv4SharedBlocks || got.IPv4DynamicBlocks != want.IPv4DynamicBlocks ||
+			got.IPv6SharedBlocks != want.IPv6SharedBlocks || got.IPv6DynamicBlocks != want.IPv6DynamicBlocks ||
+			got.SharedValues != want.SharedValues || got.DynamicValues != want.DynamicValues {
+			t.Errorf("%s: Stats() = %+v, want %+v", step, got, want)
+		}
+		if got.IPv4Blocks != got.IPv4SharedBlocks+got.IPv4DynamicBlocks || got.IPv6Blocks != got.IPv6SharedBlocks+got.IPv6DynamicBlocks ||
+			got.Values != got.SharedValues+got.DynamicValues {
+			t.Errorf("%s: totals of %+v differ from the sum of shared and dynamic counts", step, got)
+		}
 	}
+	v4Blocks, v6Blocks := lpm.Stats().IPv4Blocks, lpm.Stats().IPv6Blocks
+	check("loaded", Stats{IPv4SharedBlocks: v4Blocks, IPv6SharedBlocks: v6Blocks, SharedValues: 2})
+
+	// The insert copies the root and the block of 192 out of shared storage and adds one for 192.168
+	loaded.Insert(netip.MustParsePrefix("192.168.1.0/24"), "override")
+	check("after insert", Stats{IPv4SharedBlocks: v4Blocks, IPv4DynamicBlocks: 3, IPv6SharedBlocks: v6Blocks, SharedValues: 2, DynamicValues: 1})
+}
+
+// TestSharedStorageValueTooLong tests that values exceeding 65535 bytes are rejected
+func TestSharedStorageValueTooLong(t *testing.T) {
+	lpm := New()
+
+	// Create a value that's too long (> 65535 bytes)
+	longValue := strings.Repeat("a", 65536)
 
 	lpm.Insert(netip.MustParsePrefix("192.168.1.0/24"), longValue)
 
 	// Should fail to pack
 	_, err := lpm.PackToSharedStorage()
 	if err == nil {
-		t.Error("Expected error for value exceeding 255 bytes, got nil")
+		t.Error("Expected error for value exceeding 65535 bytes, got nil")
+	}
+}
+
+// TestSharedStorageLongValues tests packing values longer than 255 bytes
+func TestSharedStorageLongValues(t *testing.T) {
+	lpm := New()
+
+	values := map[string]string{
+		"10.0.0.0/8":     strings.Repeat("a", 256),
+		"192.168.0.0/16": strings.Repeat(`{"k":"v"}`, 1000),
+		"2001:db8::/32":  strings.Repeat("b", 65535),
+		"172.16.0.0/12":  "short",
+	}
+	for cidr, value := range values {
+		lpm.Insert(netip.MustParsePrefix(cidr), value)
+	}
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	// Repack a shared-backed trie with an extra dynamic value
+	shared.Insert(netip.MustParsePrefix("203.0.113.0/24"), strings.Repeat("c", 300))
+	values["203.0.113.0/24"] = strings.Repeat("c", 300)
+	storage, err = shared.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage of shared-backed trie failed: %v", err)
+	}
+	repacked, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
 	}
+
+	for cidr, want := range values {
+		addr := netip.MustParsePrefix(cidr).Addr().Next()
+		got, found := repacked.Lookup(addr)
+		if !found || got != want {
+			t.Errorf("Lookup(%s) = %d bytes (found=%v), want %d bytes", addr, len(got), found, len(want))
+		}
+	}
+}
+
+// packV1 builds a version 1 storage blob (1-byte value length prefix, no priorities)
+// with the same content as lpm
+func packV1(t *testing.T, lpm *LPM) []byte {
+	t.Helper()
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := *(*StorageHeader)(unsafe.Pointer(&storage[0]))
+
+	slotSize := int(header.ValueSlotSize) - 2
+	v1 := make([]byte, int(header.ValuesOffset)+int(header.ValueCount)*slotSize)
+	copy(v1, storage[:header.ValuesOffset])
+	for i := 0; i < int(header.ValueCount); i++ {
+		src := int(header.ValuesOffset) + i*int(header.ValueSlotSize)
+		dst := int(header.ValuesOffset) + i*slotSize
+		valueLen := int(binary.NativeEndian.Uint16(storage[src:]))
+		v1[dst] = byte(valueLen)
+		copy(v1[dst+1:], storage[src+3:src+3+valueLen])
+	}
+
+	// The blocks stay where the longer current header put them, the offsets say so
+	clear(v1[:header.V4BlocksOffset])
+	*(*storageHeaderV1)(unsafe.Pointer(&v1[0])) = storageHeaderV1{
+		Magic:          magicNumber,
+		Version:        versionV1,
+		V4BlockCount:   uint32(header.V4BlockCount),
+		V6BlockCount:   uint32(header.V6BlockCount),
+		ValueCount:     uint32(header.ValueCount),
+		ValueSlotSize:  uint32(slotSize),
+		V4BlocksOffset: uint32(header.V4BlocksOffset),
+		V6BlocksOffset: uint32(header.V6BlocksOffset),
+		ValuesOffset:   uint32(header.ValuesOffset),
+	}
+	return v1
+}
+
+// packV2 packs lpm and rewrites the header in the format version 2 layout
+func packV2(t *testing.T, lpm *LPM) []byte {
+	t.Helper()
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := *(*StorageHeader)(unsafe.Pointer(&storage[0]))
+
+	clear(storage[:header.V4BlocksOffset])
+	*(*storageHeaderV2)(unsafe.Pointer(&storage[0])) = storageHeaderV2{
+		storageHeaderV1: storageHeaderV1{
+			Magic:          magicNumber,
+			Version:        versionV2,
+			V4BlockCount:   uint32(header.V4BlockCount),
+			V6BlockCount:   uint32(header.V6BlockCount),
+			ValueCount:     uint32(header.ValueCount),
+			ValueSlotSize:  uint32(header.ValueSlotSize),
+			V4BlocksOffset: uint32(header.V4BlocksOffset),
+			V6BlocksOffset: uint32(header.V6BlocksOffset),
+			ValuesOffset:   uint32(header.ValuesOffset),
+		},
+		ByteOrder: byteOrderMark,
+	}
+	offset := checksumOffset(versionV2)
+	binary.NativeEndian.PutUint32(storage[offset:], storageChecksum(storage, len(storage), versionV2))
+	return storage
+}
+
+// packLegacyV2 builds version 2 storage as packed before the header gained ByteOrder and
+// Checksum: the sections follow the version 1 fields, and the value slots hold a priority
+// byte only if priorities is set
+func packLegacyV2(t *testing.T, lpm *LPM, priorities bool) []byte {
+	t.Helper()
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := *(*StorageHeader)(unsafe.Pointer(&storage[0]))
+
+	blockByteSize := uint64(blockSize * 4)
+	legacy := make([]byte, headerSize(versionV1))
+	v4BlocksOffset := len(legacy)
+	legacy = append(legacy, storage[header.V4BlocksOffset:header.V4BlocksOffset+header.V4BlockCount*blockByteSize]...)
+	v6BlocksOffset := len(legacy)
+	legacy = append(legacy, storage[header.V6BlocksOffset:header.V6BlocksOffset+header.V6BlockCount*blockByteSize]...)
+	valuesOffset := len(legacy)
+	slotSize := int(header.ValueSlotSize)
+	if !priorities {
+		slotSize--
+	}
+	for i := 0; i < int(header.ValueCount); i++ {
+		slot := storage[int(header.ValuesOffset)+i*int(header.ValueSlotSize):][:header.ValueSlotSize]
+		if !priorities {
+			slot = append(slot[:2:2], slot[3:]...)
+		}
+		legacy = append(legacy, slot...)
+	}
+
+	*(*storageHeaderV1)(unsafe.Pointer(&legacy[0])) = storageHeaderV1{
+		Magic:          magicNumber,
+		Version:        versionV2,
+		V4BlockCount:   uint32(header.V4BlockCount),
+		V6BlockCount:   uint32(header.V6BlockCount),
+		ValueCount:     uint32(header.ValueCount),
+		ValueSlotSize:  uint32(slotSize),
+		V4BlocksOffset: uint32(v4BlocksOffset),
+		V6BlocksOffset: uint32(v6BlocksOffset),
+		ValuesOffset:   uint32(valuesOffset),
+	}
+	return legacy
+}
+
+// packV3 packs lpm and rewrites the header in the format version 3 layout
+func packV3(t *testing.T, lpm *LPM) []byte {
+	t.Helper()
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := *(*StorageHeader)(unsafe.Pointer(&storage[0]))
+	if header.MetadataSize != 0 {
+		t.Fatal("version 3 storage cannot hold metadata")
+	}
+
+	clear(storage[:header.V4BlocksOffset])
+	*(*storageHeaderV3)(unsafe.Pointer(&storage[0])) = storageHeaderV3{
+		Magic:          magicNumber,
+		Version:        versionV3,
+		ByteOrder:      byteOrderMark,
+		V4BlockCount:   header.V4BlockCount,
+		V6BlockCount:   header.V6BlockCount,
+		ValueCount:     header.ValueCount,
+		ValueSlotSize:  header.ValueSlotSize,
+		V4BlocksOffset: header.V4BlocksOffset,
+		V6BlocksOffset: header.V6BlocksOffset,
+		ValuesOffset:   header.ValuesOffset,
+	}
+	offset := checksumOffset(versionV3)
+	binary.NativeEndian.PutUint32(storage[offset:], storageChecksum(storage, len(storage), versionV3))
+	return storage
+}
+
+// packV4 packs lpm and rewrites the header in the format version 4 layout
+func packV4(t *testing.T, lpm *LPM) []byte {
+	t.Helper()
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	header := *(*StorageHeader)(unsafe.Pointer(&storage[0]))
+
+	clear(storage[:header.V4BlocksOffset])
+	*(*storageHeaderV4)(unsafe.Pointer(&storage[0])) = storageHeaderV4{
+		storageHeaderV3: storageHeaderV3{
+			Magic:          magicNumber,
+			Version:        versionV4,
+			ByteOrder:      byteOrderMark,
+			V4BlockCount:   header.V4BlockCount,
+			V6BlockCount:   header.V6BlockCount,
+			ValueCount:     header.ValueCount,
+			ValueSlotSize:  header.ValueSlotSize,
+			V4BlocksOffset: header.V4BlocksOffset,
+			V6BlocksOffset: header.V6BlocksOffset,
+			ValuesOffset:   header.ValuesOffset,
+		},
+		MetadataOffset: header.MetadataOffset,
+		MetadataSize:   header.MetadataSize,
+	}
+	offset := checksumOffset(versionV4)
+	binary.NativeEndian.PutUint32(storage[offset:], storageChecksum(storage, len(storage), versionV4))
+	return storage
+}
+
+// packV5 packs lpm and rewrites the header in the format version 5 layout
+func packV5(t *testing.T, lpm *LPM) []byte {
+	t.Helper()
+
+	storage := packV4(t, lpm)
+	header := (*storageHeaderV5)(unsafe.Pointer(&storage[0]))
+	header.Version = versionV5
+	header.Generation = lpm.Generation()
+	offset := checksumOffset(versionV5)
+	binary.NativeEndian.PutUint32(storage[offset:], storageChecksum(storage, len(storage), versionV5))
+	return storage
+}
+
+// TestSharedStorageLoadV1 tests that storage in the version 1 format can still be loaded and repacked
+func TestSharedStorageLoadV1(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+
+	loaded, err := NewWithSharedStorage(packV1(t, lpm))
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage(v1) failed: %v", err)
+	}
+
+	check := func(lpm *LPM) {
+		tests := []struct{ addr, want string }{
+			{"10.2.3.4", "private"},
+			{"10.1.2.3", "dc1"},
+			{"2001:db8::1", "doc"},
+		}
+		for _, tt := range tests {
+			got, found := lpm.Lookup(netip.MustParseAddr(tt.addr))
+			if !found || got != tt.want {
+				t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+			}
+		}
+	}
+	check(loaded)
+
+	storage, err := loaded.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	if version := (*StorageHeader)(unsafe.Pointer(&storage[0])).Version; version != currentVersion {
+		t.Errorf("repacked version = %d, want %d", version, currentVersion)
+	}
+	repacked, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	check(repacked)
 }
 
 // TestSharedStorageEmptyLPM tests packing and loading an empty LPM
@@ -571,3 +882,105 @@ func TestMarshalUnmarshalBinary(t *testing.T) {
 		}
 	}
 }
+
+// TestMarshalBinaryGob tests that the LPM can be stored directly in a gob stream
+func TestMarshalBinaryGob(t *testing.T) {
+	type Config struct {
+		Name   string
+		Routes *LPM
+	}
+
+	original := Config{Name: "edge", Routes: New()}
+	original.Routes.Insert(netip.MustParsePrefix("192.168.1.0/24"), "DC1")
+	original.Routes.Insert(netip.MustParsePrefix("2001:db8::/32"), "DC2")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(original); err != nil {
+		t.Fatalf("gob Encode failed: %v", err)
+	}
+
+	var restored Config
+	if err := gob.NewDecoder(&buf).Decode(&restored); err != nil {
+		t.Fatalf("gob Decode failed: %v", err)
+	}
+
+	if restored.Name != "edge" {
+		t.Errorf("Name = %q, want %q", restored.Name, "edge")
+	}
+	if got, found := restored.Routes.Lookup(netip.MustParseAddr("192.168.1.10")); !found || got != "DC1" {
+		t.Errorf("Lookup(192.168.1.10) = %q (found=%v), want %q", got, found, "DC1")
+	}
+	if got, found := restored.Routes.Lookup(netip.MustParseAddr("2001:db8::1")); !found || got != "DC2" {
+		t.Errorf("Lookup(2001:db8::1) = %q (found=%v), want %q", got, found, "DC2")
+	}
+}
+
+// TestUnmarshalBinaryCopiesData tests that UnmarshalBinary does not retain the input buffer
+func TestUnmarshalBinaryCopiesData(t *testing.T) {
+	original := New()
+	original.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+
+	data, err := original.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	restored := New()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	// Simulate the caller reusing its buffer
+	clear(data)
+
+	if got, found := restored.Lookup(netip.MustParseAddr("10.1.2.3")); !found || got != "DC1" {
+		t.Errorf("Lookup(10.1.2.3) = %q (found=%v), want %q", got, found, "DC1")
+	}
+}
+
+// TestSharedStorageValueDeduplication tests that inserting a value that already exists
+// in shared storage reuses it instead of creating a dynamic copy
+func TestSharedStorageValueDeduplication(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "DC2")
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+
+	// Simulate several reload cycles that re-insert the same values
+	for cycle := range 3 {
+		loaded, err := NewWithSharedStorage(storage)
+		if err != nil {
+			t.Fatalf("cycle %d: NewWithSharedStorage failed: %v", cycle, err)
+		}
+		loaded.Insert(netip.MustParsePrefix("172.16.0.0/12"), "DC1")
+		loaded.Insert(netip.MustParsePrefix("2001:db8::/32"), "DC2")
+		loaded.Insert(netip.MustParsePrefix("2001:db8:1::/48"), "DC3")
+
+		// Only DC3 is new, and only until it has been packed once
+		wantDynamic := 0
+		if cycle == 0 {
+			wantDynamic = 1
+		}
+		if len(loaded.revValues) != wantDynamic {
+			t.Errorf("cycle %d: %d dynamic values, want %d", cycle, len(loaded.revValues), wantDynamic)
+		}
+
+		idx1, _ := loaded.LookupIndex(netip.MustParseAddr("10.1.1.1"))
+		idx2, _ := loaded.LookupIndex(netip.MustParseAddr("172.16.1.1"))
+		if idx1 != idx2 {
+			t.Errorf("cycle %d: shared and re-inserted DC1 have different indices: %d != %d", cycle, idx1, idx2)
+		}
+
+		storage, err = loaded.PackToSharedStorage()
+		if err != nil {
+			t.Fatalf("cycle %d: PackToSharedStorage failed: %v", cycle, err)
+		}
+		if count := (*StorageHeader)(unsafe.Pointer(&storage[0])).ValueCount; count != 3 {
+			t.Errorf("cycle %d: packed ValueCount = %d, want 3", cycle, count)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+// TestSlabAllocation tests that dynamic blocks are carved out of slabs
+func TestSlabAllocation(t *testing.T) {
+	m := New()
+	prefixes := make([]netip.Prefix, 1000)
+	for i := range prefixes {
+		prefixes[i] = netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/250, i%250))
+	}
+	allocs := testing.AllocsPerRun(1, func() {
+		for _, prefix := range prefixes {
+			m.Insert(prefix, "v")
+		}
+	})
+	// About one block per prefix, so a block per allocation would make over 1000
+	blocks := m.dynamic[v4LPM]
+	if allocs > float64(len(blocks)/slabBlocks+50) {
+		t.Errorf("%v allocations for %d blocks", allocs, len(blocks))
+	}
+
+	// Blocks allocated one after the other are adjacent, except across slabs
+	adjacent := 0
+	for i := 1; i+1 < len(blocks); i++ {
+		if uintptr(unsafe.Pointer(blocks[i+1]))-uintptr(unsafe.Pointer(blocks[i])) == unsafe.Sizeof(LPMBlock{}) {
+			adjacent++
+		}
+	}
+	if want := len(blocks) - 2 - len(blocks)/slabBlocks - 1; adjacent < want {
+		t.Errorf("%d of %d blocks adjacent to the previous one, want at least %d", adjacent, len(blocks), want)
+	}
+}
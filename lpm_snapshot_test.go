@@ -0,0 +1,159 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+// TestSnapshot tests that snapshots do not see modifications made after they were taken
+func TestSnapshot(t *testing.T) {
+	lpm := newPackTestLPM()
+	snap := lpm.Snapshot()
+	want := snap.Fingerprint()
+	if got := lpm.Fingerprint(); got != want {
+		t.Fatalf("snapshot Fingerprint() = %016x, want %016x", want, got)
+	}
+
+	lpm.Insert(netip.MustParsePrefix("10.1.2.128/25"), "override")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "new-value")
+	lpm.InsertTombstone(netip.MustParsePrefix("10.0.5.0/24"))
+	lpm.Delete(netip.MustParsePrefix("2001:db8:1::/48"))
+	lpm.Insert(netip.MustParsePrefix("192.0.2.0/24"), "test-net")
+	lpm.CompactValues()
+
+	if got := snap.Fingerprint(); got != want {
+		t.Errorf("snapshot Fingerprint() changed to %016x after modifications", got)
+	}
+	tests := []struct{ addr, snapWant, lpmWant string }{
+		{"10.1.2.200", "DC6", "override"},
+		{"10.1.100.1", "", "new-value"},
+		{"10.0.5.1", "DC5", ""},
+		{"2001:db8:1::1", "doc-subnet", "doc"},
+		{"192.0.2.1", "", "test-net"},
+	}
+	for _, tt := range tests {
+		addr := netip.MustParseAddr(tt.addr)
+		if got, _ := snap.Lookup(addr); got != tt.snapWant {
+			t.Errorf("snapshot Lookup(%s) = %q, want %q", addr, got, tt.snapWant)
+		}
+		if got, _ := lpm.Lookup(addr); got != tt.lpmWant {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, tt.lpmWant)
+		}
+	}
+
+	if err := snap.Insert(netip.MustParsePrefix("10.0.0.0/8"), "x"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Insert into snapshot error = %v, want %v", err, ErrReadOnly)
+	}
+}
+
+// TestSnapshotSharedStorage tests snapshots of a trie loaded from shared storage
+func TestSnapshotSharedStorage(t *testing.T) {
+	storage, err := newPackTestLPM().PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	original := bytes.Clone(storage)
+	lpm, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	lpm.Insert(netip.MustParsePrefix("10.1.2.0/25"), "first")
+	snap := lpm.Snapshot()
+	lpm.Insert(netip.MustParsePrefix("10.1.2.0/25"), "second")
+
+	if got, _ := snap.Lookup(netip.MustParseAddr("10.1.2.1")); got != "first" {
+		t.Errorf("snapshot Lookup = %q, want first", got)
+	}
+	if got, _ := lpm.Lookup(netip.MustParseAddr("10.1.2.1")); got != "second" {
+		t.Errorf("Lookup = %q, want second", got)
+	}
+	if !bytes.Equal(storage, original) {
+		t.Error("shared storage was modified")
+	}
+
+	packed, err := snap.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage of snapshot failed: %v", err)
+	}
+	reloaded, err := NewWithSharedStorage(packed)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if reloaded.Fingerprint() != snap.Fingerprint() {
+		t.Error("packed snapshot differs from the snapshot")
+	}
+}
+
+// TestSnapshotReclaim tests that blocks replaced after snapshots do not accumulate
+func TestSnapshotReclaim(t *testing.T) {
+	lpm := New()
+	for i := range 64 {
+		lpm.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.0.0/16", i)), "base")
+	}
+	for round := range 200 {
+		lpm.Snapshot()
+		lpm.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", round%64, round)), fmt.Sprintf("v%d", round))
+	}
+
+	reachable, _ := lpm.packOrder(v4LPM)
+	if total := lpm.blockCount(v4LPM); total > 3*len(reachable) {
+		t.Errorf("trie holds %d blocks, only %d reachable", total, len(reachable))
+	}
+}
+
+// TestSnapshotConcurrent tests one writer publishing snapshots to concurrent readers
+func TestSnapshotConcurrent(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "base")
+
+	first := lpm.Snapshot()
+	var current Atomic
+	current.Store(first)
+	fingerprints := sync.Map{}
+	fingerprints.Store(first, first.Fingerprint())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snap := current.Load()
+				want, _ := fingerprints.Load(snap)
+				if got := snap.Fingerprint(); got != want.(uint64) {
+					t.Errorf("snapshot Fingerprint() = %016x, want %016x", got, want)
+					return
+				}
+			}
+		}()
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for round := range 200 {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(256)), 0}), 16+rng.Intn(9)).Masked()
+		if round%3 == 2 {
+			lpm.Delete(prefix)
+		} else {
+			lpm.Insert(prefix, fmt.Sprintf("v%d", round))
+		}
+		if round%10 == 0 {
+			snap := lpm.Snapshot()
+			fingerprints.Store(snap, snap.Fingerprint())
+			current.Store(snap)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package lpm
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// recomputedValuesStorage sums the value bytes by walking the values, as Stats did before keeping a counter
+func recomputedValuesStorage(m *LPM) int {
+	size := m.sharedValueCount * m.sharedValuesSlotSize
+	if len(m.revValues) > 0 {
+		for _, val := range m.revValues {
+			size += len(val)
+		}
+		size += len(m.revValues)*(2*8+32+4) + 3*8 + 8*8
+	}
+	return size
+}
+
+// TestStatsIncremental tests that the counters behind Stats follow inserts, deletes, CompactValues and snapshots
+func TestStatsIncremental(t *testing.T) {
+	check := func(step string, m *LPM) {
+		t.Helper()
+		stats := m.Stats()
+		if want := recomputedValuesStorage(m); stats.ValuesStorage != want {
+			t.Errorf("%s: ValuesStorage = %d, want %d", step, stats.ValuesStorage, want)
+		}
+		if want := m.sharedValueCount + len(m.revValues); stats.Values != want {
+			t.Errorf("%s: Values = %d, want %d", step, stats.Values, want)
+		}
+		if want := stats.IPv4StorageSize + stats.IPv6StorageSize + stats.ValuesStorage + stats.PrefixesStorage; stats.TotalSize != want {
+			t.Errorf("%s: TotalSize = %d, want %d", step, stats.TotalSize, want)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 500)
+	lpm := New()
+	for _, e := range entries {
+		if err := lpm.Insert(e.Prefix, e.Value); err != nil {
+			t.Fatalf("Insert(%s) failed: %v", e.Prefix, err)
+		}
+	}
+	check("insert", lpm)
+
+	for _, e := range entries[:250] {
+		if _, err := lpm.Delete(e.Prefix); err != nil {
+			t.Fatalf("Delete(%s) failed: %v", e.Prefix, err)
+		}
+	}
+	check("delete", lpm)
+
+	lpm.CompactValues()
+	check("CompactValues", lpm)
+
+	snapshot := lpm.Snapshot()
+	check("snapshot", snapshot)
+	lpm.Insert(netip.MustParsePrefix("203.0.113.0/24"), "after-snapshot")
+	check("insert after snapshot", lpm)
+	check("snapshot after insert", snapshot)
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	loaded, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	check("shared", loaded)
+	loaded.Insert(netip.MustParsePrefix("198.51.100.0/24"), "dynamic")
+	check("shared with dynamic", loaded)
+}
+
+// TestStatsAllocs tests that Stats does not allocate
+func TestStatsAllocs(t *testing.T) {
+	lpm := New()
+	for i := range 1000 {
+		lpm.Insert(netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)), fmt.Sprintf("v%d", i))
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = lpm.Stats() }); allocs != 0 {
+		t.Errorf("Stats allocated %.0f times, want 0", allocs)
+	}
+}
+
+// BenchmarkStats benchmarks Stats on a trie with many values
+func BenchmarkStats(b *testing.B) {
+	lpm := New()
+	for i := range 100000 {
+		lpm.Insert(netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}), 32), fmt.Sprintf("v%d", i))
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = lpm.Stats()
+	}
+}
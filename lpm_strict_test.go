@@ -0,0 +1,61 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// TestInsertUnmasked tests that host bits past the prefix length are ignored
+func TestInsertUnmasked(t *testing.T) {
+	unmasked, masked := New(), New()
+	for _, p := range []struct{ unmasked, masked, value string }{
+		{"10.1.2.3/16", "10.1.0.0/16", "dc1"},
+		{"10.9.2.3/13", "10.8.0.0/13", "region"},
+		{"192.168.1.255/31", "192.168.1.254/31", "link"},
+		{"2001:db8:ffff::1/30", "2001:db8::/30", "doc"},
+	} {
+		if err := unmasked.Insert(netip.MustParsePrefix(p.unmasked), p.value); err != nil {
+			t.Fatalf("Insert(%s): %v", p.unmasked, err)
+		}
+		if err := masked.Insert(netip.MustParsePrefix(p.masked), p.value); err != nil {
+			t.Fatalf("Insert(%s): %v", p.masked, err)
+		}
+	}
+	unmasked.InsertTombstone(netip.MustParsePrefix("10.1.200.7/24"))
+	masked.InsertTombstone(netip.MustParsePrefix("10.1.200.0/24"))
+
+	if unmasked.Fingerprint() != masked.Fingerprint() {
+		t.Errorf("unmasked inserts differ from masked ones:\n%v\n%v", unmasked.Entries(), masked.Entries())
+	}
+	if got, ok := unmasked.Lookup(netip.MustParseAddr("10.1.0.1")); !ok || got != "dc1" {
+		t.Errorf("Lookup(10.1.0.1) = %q, %v, want dc1", got, ok)
+	}
+}
+
+// TestInsertStrict tests that unmasked and invalid prefixes are rejected
+func TestInsertStrict(t *testing.T) {
+	lpm := New()
+	for _, cidr := range []string{"10.1.0.0/16", "192.168.1.1/32", "0.0.0.0/0", "2001:db8::/32"} {
+		if err := lpm.InsertStrict(netip.MustParsePrefix(cidr), cidr); err != nil {
+			t.Errorf("InsertStrict(%s) = %v", cidr, err)
+		}
+	}
+	for _, prefix := range []netip.Prefix{
+		netip.MustParsePrefix("10.1.2.3/16"),
+		netip.MustParsePrefix("2001:db8::1/64"),
+		{},
+	} {
+		if err := lpm.InsertStrict(prefix, "bad"); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("InsertStrict(%v) = %v, want ErrInvalidPrefix", prefix, err)
+		}
+	}
+	if got, ok := lpm.Lookup(netip.MustParseAddr("10.1.2.3")); !ok || got != "10.1.0.0/16" {
+		t.Errorf("Lookup(10.1.2.3) = %q, %v, want the masked prefix", got, ok)
+	}
+
+	ro := lpm.Snapshot()
+	if err := ro.InsertStrict(netip.MustParsePrefix("10.2.0.0/16"), "x"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("InsertStrict on snapshot = %v, want ErrReadOnly", err)
+	}
+}
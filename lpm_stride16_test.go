@@ -0,0 +1,126 @@
+package lpm
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// TestIPv4Stride16 tests that the first level table follows every kind of modification
+func TestIPv4Stride16(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randPrefix := func() netip.Prefix {
+		addr := netip.AddrFrom4([4]byte{byte(rng.Intn(4)), byte(rng.Intn(4)), byte(rng.Intn(4)), byte(rng.Intn(256))})
+		return netip.PrefixFrom(addr, rng.Intn(33)).Masked()
+	}
+	plain, stride := New(), New(IPv4Stride16())
+	check := func(step string, want, got *LPM) {
+		t.Helper()
+		for range 2000 {
+			addr := netip.AddrFrom4([4]byte{byte(rng.Intn(5)), byte(rng.Intn(5)), byte(rng.Intn(5)), byte(rng.Intn(256))})
+			wantValue, wantFound := want.Lookup(addr)
+			gotValue, gotFound := got.Lookup(addr)
+			if gotValue != wantValue || gotFound != wantFound {
+				t.Fatalf("%s: Lookup(%s) = %q (found=%v), want %q (found=%v)", step, addr, gotValue, gotFound, wantValue, wantFound)
+			}
+		}
+	}
+
+	var inserted []netip.Prefix
+	for i := range 500 {
+		prefix := randPrefix()
+		switch op := rng.Intn(10); {
+		case op < 6:
+			value := string(rune('a' + rng.Intn(20)))
+			priority := uint8(rng.Intn(3))
+			plain.InsertWithPriority(prefix, value, priority)
+			stride.InsertWithPriority(prefix, value, priority)
+			inserted = append(inserted, prefix)
+		case op < 7:
+			plain.InsertTombstone(prefix)
+			stride.InsertTombstone(prefix)
+		case len(inserted) > 0:
+			prefix = inserted[rng.Intn(len(inserted))]
+			plain.Delete(prefix)
+			stride.Delete(prefix)
+		}
+
+		switch i % 100 {
+		case 25:
+			snap := stride.Snapshot()
+			check("snapshot", plain.Snapshot(), snap)
+		case 50:
+			plain.Compact()
+			stride.Compact()
+		case 75:
+			plain.CompactValues()
+			stride.CompactValues()
+		}
+		if i%10 == 0 {
+			check("modify", plain, stride)
+		}
+	}
+	check("final", plain, stride)
+
+	storage, err := stride.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage, IPv4Stride16())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+	check("shared", plain, shared)
+	prefix := netip.MustParsePrefix("1.0.0.0/12")
+	plain.Insert(prefix, "copied")
+	shared.Insert(prefix, "copied")
+	check("shared insert", plain, shared)
+
+	if got, want := stride.Stats().IPv4StorageSize-plain.Stats().IPv4StorageSize, stride16Size*4; got < want {
+		t.Errorf("Stats reports %d bytes for the first level table, want at least %d", got, want)
+	}
+}
+
+// TestIPv4Stride16Snapshot tests that snapshots keep their table while the writer updates its own
+func TestIPv4Stride16Snapshot(t *testing.T) {
+	m := New(IPv4Stride16())
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "old")
+	snap := m.Snapshot()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "new")
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "sub")
+
+	addr := netip.MustParseAddr("10.1.2.3")
+	if got, _ := snap.Lookup(addr); got != "old" {
+		t.Errorf("snapshot Lookup(%s) = %q, want old", addr, got)
+	}
+	if got, _ := m.Lookup(addr); got != "sub" {
+		t.Errorf("Lookup(%s) = %q, want sub", addr, got)
+	}
+	if got, _ := m.Lookup4(0x0A020304); got != "new" {
+		t.Errorf("Lookup4(10.2.3.4) = %q, want new", got)
+	}
+}
+
+func BenchmarkIPv4Stride16(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range []string{"plain", "stride16"} {
+		var opts []Option
+		if name == "stride16" {
+			opts = append(opts, IPv4Stride16())
+		}
+		m := New(opts...)
+		for range 100000 {
+			addr := netip.AddrFrom4([4]byte{byte(rng.Intn(224)), byte(rng.Intn(256)), byte(rng.Intn(256)), 0})
+			m.Insert(netip.PrefixFrom(addr, 16+rng.Intn(9)), "v")
+		}
+		addrs := make([]uint32, 4096)
+		for i := range addrs {
+			addrs[i] = rng.Uint32()
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := range b.N {
+				m.Lookup4(addrs[i%len(addrs)])
+			}
+		})
+	}
+}
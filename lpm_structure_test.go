@@ -0,0 +1,57 @@
+package lpm
+
+import (
+	"net/netip"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// TestStructureStats tests the prefix length histogram and slot counts
+func TestStructureStats(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	lpm.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+
+	stats := lpm.StructureStats()
+	v4, v6 := stats.IPv4, stats.IPv6
+	wantV4 := ProtoStructure{Blocks: 3, ValueSlots: 510, TombstoneSlots: 1, BlockRefSlots: 2, InvalidSlots: 255, Runs: 9}
+	wantV6 := ProtoStructure{Blocks: 4, ValueSlots: 1, BlockRefSlots: 3, InvalidSlots: 1020, Runs: 12}
+	for _, tt := range []struct {
+		name      string
+		got, want ProtoStructure
+		lengths   map[int]int
+		maxBits   int
+	}{
+		{"IPv4", v4, wantV4, map[int]int{8: 1, 16: 1, 24: 1}, 32},
+		{"IPv6", v6, wantV6, map[int]int{32: 1}, 128},
+	} {
+		wantLengths := make([]int, tt.maxBits+1)
+		for bits, n := range tt.lengths {
+			wantLengths[bits] = n
+		}
+		if !slices.Equal(tt.got.PrefixLengths, wantLengths) {
+			t.Errorf("%s PrefixLengths = %v, want %v", tt.name, tt.got.PrefixLengths, wantLengths)
+		}
+		tt.got.PrefixLengths = nil
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+	if got, want := v4.FillFactor(), 513.0/768; got != want {
+		t.Errorf("IPv4 FillFactor() = %v, want %v", got, want)
+	}
+
+	// Blocks freed by deletes are not counted
+	lpm.Delete(netip.MustParsePrefix("10.1.2.0/24"))
+	lpm.Delete(netip.MustParsePrefix("10.1.0.0/16"))
+	if got := lpm.StructureStats().IPv4; got.Blocks != 1 || got.Blocks >= lpm.Stats().IPv4Blocks {
+		t.Errorf("IPv4 after deletes = %+v, want 1 reachable block out of %d", got, lpm.Stats().IPv4Blocks)
+	}
+
+	if empty := New().StructureStats(); empty.IPv6.Blocks != 1 || empty.IPv6.InvalidSlots != blockSize || empty.IPv6.FillFactor() != 0 {
+		t.Errorf("empty IPv6 = %+v, want a root block of invalid slots", empty.IPv6)
+	}
+}
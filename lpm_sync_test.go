@@ -0,0 +1,82 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+// TestSync tests concurrent inserts, deletes and lookups through Sync
+func TestSync(t *testing.T) {
+	s := NewSync(nil)
+	if err := s.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for writer := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				prefix := netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", writer, i))
+				if err := s.Insert(prefix, fmt.Sprintf("w%d", writer)); err != nil {
+					t.Errorf("Insert failed: %v", err)
+					return
+				}
+				if i%2 == 0 {
+					if deleted, err := s.Delete(prefix); !deleted || err != nil {
+						t.Errorf("Delete(%s) = %v, %v, want true, nil", prefix, deleted, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				addr := netip.AddrFrom4([4]byte{10, byte(i % 4), byte(i % 200), 1})
+				if value, found := s.Lookup(addr); !found || (value != "private" && value[0] != 'w') {
+					t.Errorf("Lookup(%s) = %q, %v", addr, value, found)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.View(func(m *LPM) {
+		for writer := range 4 {
+			for _, i := range []int{10, 11} {
+				addr := netip.AddrFrom4([4]byte{10, byte(writer), byte(i), 1})
+				want := "private"
+				if i%2 == 1 {
+					want = fmt.Sprintf("w%d", writer)
+				}
+				if value, _ := m.Lookup(addr); value != want {
+					t.Errorf("Lookup(%s) = %q, want %q", addr, value, want)
+				}
+			}
+		}
+	})
+
+	err := s.Update(func(m *LPM) error {
+		if err := m.InsertTombstone(netip.MustParsePrefix("10.0.2.0/24")); err != nil {
+			return err
+		}
+		return m.InsertWithPriority(netip.MustParsePrefix("10.0.1.0/24"), "pinned", 1)
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, found := s.Lookup(netip.MustParseAddr("10.0.2.1")); found {
+		t.Error("Lookup inside the tombstone found a value")
+	}
+	if value, _ := s.Lookup(netip.MustParseAddr("10.0.1.1")); value != "pinned" {
+		t.Errorf("Lookup(10.0.1.1) = %q, want pinned", value)
+	}
+}
@@ -0,0 +1,160 @@
+package lpm
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+const (
+	tagBogon uint64 = 1 << iota
+	tagCloud
+	tagInternal
+	tagBlocked
+)
+
+// TestTagsLookup tests that lookups return the union of all covering prefixes' tags
+func TestTagsLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		inserts []struct {
+			cidr string
+			tags uint64
+		}
+		lookups []struct {
+			addr string
+			want uint64
+		}
+	}{
+		{
+			name: "nested prefixes broad first",
+			inserts: []struct {
+				cidr string
+				tags uint64
+			}{
+				{"10.0.0.0/8", tagBogon | tagInternal},
+				{"10.20.0.0/16", tagCloud},
+				{"10.20.30.0/24", tagBlocked},
+			},
+			lookups: []struct {
+				addr string
+				want uint64
+			}{
+				{"10.1.1.1", tagBogon | tagInternal},
+				{"10.20.1.1", tagBogon | tagInternal | tagCloud},
+				{"10.20.30.1", tagBogon | tagInternal | tagCloud | tagBlocked},
+				{"11.0.0.1", 0},
+			},
+		},
+		{
+			name: "nested prefixes specific first",
+			inserts: []struct {
+				cidr string
+				tags uint64
+			}{
+				{"10.20.30.40/32", tagBlocked},
+				{"10.20.0.0/16", tagCloud},
+				{"0.0.0.0/0", tagInternal},
+			},
+			lookups: []struct {
+				addr string
+				want uint64
+			}{
+				{"10.20.30.40", tagInternal | tagCloud | tagBlocked},
+				{"10.20.30.41", tagInternal | tagCloud},
+				{"10.21.0.1", tagInternal},
+			},
+		},
+		{
+			name: "same prefix accumulates tags",
+			inserts: []struct {
+				cidr string
+				tags uint64
+			}{
+				{"192.168.0.0/16", tagInternal},
+				{"192.168.0.0/16", tagBlocked},
+				{"192.168.0.0/16", 0},
+			},
+			lookups: []struct {
+				addr string
+				want uint64
+			}{
+				{"192.168.1.1", tagInternal | tagBlocked},
+			},
+		},
+		{
+			name: "IPv6",
+			inserts: []struct {
+				cidr string
+				tags uint64
+			}{
+				{"2001:db8::/32", tagBogon},
+				{"2001:db8:1::/48", tagCloud},
+			},
+			lookups: []struct {
+				addr string
+				want uint64
+			}{
+				{"2001:db8::1", tagBogon},
+				{"2001:db8:1::1", tagBogon | tagCloud},
+				{"2001:db9::1", 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := NewTags()
+			for _, ins := range tt.inserts {
+				tags.Add(netip.MustParsePrefix(ins.cidr), ins.tags)
+			}
+			for _, l := range tt.lookups {
+				if got := tags.LookupTags(netip.MustParseAddr(l.addr)); got != l.want {
+					t.Errorf("LookupTags(%s) = %04b, want %04b", l.addr, got, l.want)
+				}
+			}
+		})
+	}
+}
+
+// TestTagsOrderIndependent tests that the result does not depend on insertion order
+func TestTagsOrderIndependent(t *testing.T) {
+	type insert struct {
+		prefix netip.Prefix
+		tags   uint64
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var inserts []insert
+	for range 200 {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(4)), byte(rng.Intn(256))})
+		prefix := netip.PrefixFrom(addr, 8+rng.Intn(25)).Masked()
+		inserts = append(inserts, insert{prefix, 1 << rng.Intn(64)})
+	}
+
+	forward := NewTags()
+	for _, ins := range inserts {
+		forward.Add(ins.prefix, ins.tags)
+	}
+	reverse := NewTags()
+	for i := len(inserts) - 1; i >= 0; i-- {
+		reverse.Add(inserts[i].prefix, inserts[i].tags)
+	}
+
+	for range 2000 {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.Intn(4)), byte(rng.Intn(4)), byte(rng.Intn(256))})
+
+		var want uint64
+		for _, ins := range inserts {
+			if ins.prefix.Contains(addr) {
+				want |= ins.tags
+			}
+		}
+		if got := forward.LookupTags(addr); got != want {
+			t.Fatalf("forward LookupTags(%s) = %x, want %x", addr, got, want)
+		}
+		if got := reverse.LookupTags(addr); got != want {
+			t.Fatalf("reverse LookupTags(%s) = %x, want %x", addr, got, want)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"net/netip"
 	"testing"
+
+	"github.com/sakateka/lpm/reference"
 )
 
 // TestLPMBasicOperations tests basic insert and lookup operations
@@ -459,6 +461,8 @@ func FuzzLPMInsertLookup(f *testing.F) {
 		}
 
 		lpm.Insert(prefix, "DC1")
+		ref := reference.New()
+		ref.Insert(prefix, "DC1")
 
 		// Lookup an address
 		lookupAddr := fmt.Sprintf("%d.%d.%d.%d", a, b, c, lookupD)
@@ -467,8 +471,11 @@ func FuzzLPMInsertLookup(f *testing.F) {
 			t.Skip("Invalid address")
 		}
 
-		// Just ensure it doesn't panic
-		_, _ = lpm.Lookup(addr)
+		// Compare with the reference implementation
+		got, found := lpm.Lookup(addr)
+		if want, wantFound := ref.Lookup(addr); got != want || found != wantFound {
+			t.Errorf("Lookup(%s) = %q (found=%v), reference %q (found=%v)", addr, got, found, want, wantFound)
+		}
 
 		// Verify the tree structure is valid
 		if lpm.Stats().IPv4Blocks == 0 {
@@ -487,6 +494,7 @@ func FuzzLPMMultipleInserts(f *testing.F) {
 		}
 
 		lpm := New()
+		ref := reference.New()
 
 		// Insert multiple prefixes from fuzz data
 		for i := 0; i+4 < len(data); i += 5 {
@@ -505,6 +513,7 @@ func FuzzLPMMultipleInserts(f *testing.F) {
 
 			value := fmt.Sprintf("DC%d", i)
 			lpm.Insert(prefix, value)
+			ref.Insert(prefix, value)
 		}
 
 		// Try lookups with the same data
@@ -517,8 +526,11 @@ func FuzzLPMMultipleInserts(f *testing.F) {
 				continue
 			}
 
-			// Should not panic
-			_, _ = lpm.Lookup(parsedAddr)
+			// Compare with the reference implementation
+			got, found := lpm.Lookup(parsedAddr)
+			if want, wantFound := ref.Lookup(parsedAddr); got != want || found != wantFound {
+				t.Errorf("Lookup(%s) = %q (found=%v), reference %q (found=%v)", parsedAddr, got, found, want, wantFound)
+			}
 		}
 	})
 }
@@ -766,3 +778,188 @@ func BenchmarkLPMConcurrentLookup(b *testing.B) {
 		}
 	})
 }
+
+// TestLPMLookupRaw tests lookups by raw uint32 and 16-byte representations
+func TestLPMLookupRaw(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+	lpm.Insert(netip.MustParsePrefix("10.1.2.0/24"), "DC2")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "DC3")
+
+	v4Tests := []struct {
+		addr  uint32
+		want  string
+		found bool
+	}{
+		{0x0A000001, "DC1", true}, // 10.0.0.1
+		{0x0A010203, "DC2", true}, // 10.1.2.3
+		{0xC0A80101, "", false},   // 192.168.1.1
+	}
+	for _, tt := range v4Tests {
+		got, found := lpm.Lookup4(tt.addr)
+		if got != tt.want || found != tt.found {
+			t.Errorf("Lookup4(0x%08X) = %q (found=%v), want %q (found=%v)", tt.addr, got, found, tt.want, tt.found)
+		}
+	}
+
+	v6Tests := []struct {
+		addr  string
+		want  string
+		found bool
+	}{
+		{"2001:db8::1", "DC3", true},
+		{"2001:db9::1", "", false},
+	}
+	for _, tt := range v6Tests {
+		got, found := lpm.Lookup6(netip.MustParseAddr(tt.addr).As16())
+		if got != tt.want || found != tt.found {
+			t.Errorf("Lookup6(%s) = %q (found=%v), want %q (found=%v)", tt.addr, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+// TestLPMLookupAllocs tests that lookups do not allocate
+func TestLPMLookupAllocs(t *testing.T) {
+	dynamic := New()
+	dynamic.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+	dynamic.Insert(netip.MustParsePrefix("2001:db8::/32"), "DC2")
+
+	storage, err := dynamic.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+
+	v4 := netip.MustParseAddr("10.1.2.3")
+	v6 := netip.MustParseAddr("2001:db8::1")
+	v6raw := v6.As16()
+
+	for name, lpm := range map[string]*LPM{"dynamic": dynamic, "shared": shared} {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, found := lpm.Lookup(v4); !found {
+				t.Fatal("Lookup(10.1.2.3) not found")
+			}
+			if _, found := lpm.Lookup(v6); !found {
+				t.Fatal("Lookup(2001:db8::1) not found")
+			}
+			if _, found := lpm.Lookup4(0x0A010203); !found {
+				t.Fatal("Lookup4(10.1.2.3) not found")
+			}
+			if _, found := lpm.Lookup6(v6raw); !found {
+				t.Fatal("Lookup6(2001:db8::1) not found")
+			}
+		})
+		if name == "shared" && !zeroCopy {
+			// values are copied out of storage with the lpm_safe build tag
+			continue
+		}
+		if allocs != 0 {
+			t.Errorf("%s: lookups allocated %v times per run, want 0", name, allocs)
+		}
+	}
+}
+
+// TestLPMLookupIndex tests lookups returning value indices
+func TestLPMLookupIndex(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "DC1")
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "DC1")
+	lpm.Insert(netip.MustParsePrefix("172.16.0.0/12"), "DC2")
+
+	idx1, ok := lpm.LookupIndex(netip.MustParseAddr("10.1.2.3"))
+	if !ok {
+		t.Fatal("LookupIndex(10.1.2.3) not found")
+	}
+	idx2, ok := lpm.LookupIndex(netip.MustParseAddr("192.168.1.1"))
+	if !ok {
+		t.Fatal("LookupIndex(192.168.1.1) not found")
+	}
+	idx3, ok := lpm.LookupIndex(netip.MustParseAddr("172.16.1.1"))
+	if !ok {
+		t.Fatal("LookupIndex(172.16.1.1) not found")
+	}
+	if idx1 != idx2 {
+		t.Errorf("equal values have different indices: %d != %d", idx1, idx2)
+	}
+	if idx1 == idx3 {
+		t.Errorf("different values share index %d", idx1)
+	}
+	if _, ok := lpm.LookupIndex(netip.MustParseAddr("8.8.8.8")); ok {
+		t.Error("LookupIndex(8.8.8.8) found, want no match")
+	}
+
+	if got, ok := lpm.ValueByIndex(idx1); !ok || got != "DC1" {
+		t.Errorf("ValueByIndex(%d) = %q (ok=%v), want %q", idx1, got, ok, "DC1")
+	}
+	if got, ok := lpm.ValueByIndex(idx3); !ok || got != "DC2" {
+		t.Errorf("ValueByIndex(%d) = %q (ok=%v), want %q", idx3, got, ok, "DC2")
+	}
+	for _, idx := range []int{-1, 2, 100} {
+		if _, ok := lpm.ValueByIndex(idx); ok {
+			t.Errorf("ValueByIndex(%d) succeeded, want failure", idx)
+		}
+	}
+
+	// Indices remain valid across shared storage packing
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	sharedIdx, ok := shared.LookupIndex(netip.MustParseAddr("10.1.2.3"))
+	if !ok || sharedIdx != idx1 {
+		t.Errorf("shared LookupIndex(10.1.2.3) = %d (ok=%v), want %d", sharedIdx, ok, idx1)
+	}
+	if got, ok := shared.ValueByIndex(sharedIdx); !ok || got != "DC1" {
+		t.Errorf("shared ValueByIndex(%d) = %q (ok=%v), want %q", sharedIdx, got, ok, "DC1")
+	}
+}
+
+// TestLookupZeroAddr tests that the zero Addr matches nothing, not even default routes
+func TestLookupZeroAddr(t *testing.T) {
+	m := New()
+	m.Insert(netip.MustParsePrefix("0.0.0.0/0"), "default4")
+	m.Insert(netip.MustParsePrefix("::/0"), "default6")
+	var zero netip.Addr
+
+	if value, found := m.Lookup(zero); found {
+		t.Errorf("Lookup(zero Addr) = %q, want not found", value)
+	}
+	if _, found := m.LookupIndex(zero); found {
+		t.Error("LookupIndex(zero Addr) found a value")
+	}
+	if _, found := m.LookupBytes(zero); found {
+		t.Error("LookupBytes(zero Addr) found a value")
+	}
+	values, found := make([]string, 2), make([]bool, 2)
+	m.LookupPrefetch([]netip.Addr{zero, netip.MustParseAddr("::1")}, values, found)
+	if found[0] || !found[1] {
+		t.Errorf("LookupPrefetch found = %v, want [false true]", found)
+	}
+	if trace := m.Explain(zero); trace.Found || len(trace.Steps) != 0 {
+		t.Errorf("Explain(zero Addr) = %+v, want no steps", trace)
+	}
+	if _, found := m.HitCounter().Lookup(zero); found {
+		t.Error("HitCounter.Lookup(zero Addr) found a value")
+	}
+	if _, found := m.Poptrie().Lookup(zero); found {
+		t.Error("Poptrie.Lookup(zero Addr) found a value")
+	}
+
+	n := NewU32()
+	n.Insert(netip.MustParsePrefix("::/0"), 6)
+	if value, found := n.Lookup(zero); found {
+		t.Errorf("Numeric.Lookup(zero Addr) = %d, want not found", value)
+	}
+	tags := NewTags()
+	tags.Add(netip.MustParsePrefix("::/0"), 1)
+	if got := tags.LookupTags(zero); got != 0 {
+		t.Errorf("LookupTags(zero Addr) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,166 @@
+package lpm
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+)
+
+// TestInsertTombstone tests carving exceptions out of a broader prefix
+func TestInsertTombstone(t *testing.T) {
+	tests := []struct {
+		name    string
+		inserts []struct{ cidr, value string } // empty value inserts a tombstone
+		lookups []struct{ addr, want string }  // empty want expects no match
+	}{
+		{
+			name: "tombstone after broader prefix",
+			inserts: []struct{ cidr, value string }{
+				{"10.0.0.0/8", "allowed"},
+				{"10.1.0.0/16", ""},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.0.0.1", "allowed"},
+				{"10.1.2.3", ""},
+				{"10.2.0.1", "allowed"},
+			},
+		},
+		{
+			name: "tombstone before broader prefix",
+			inserts: []struct{ cidr, value string }{
+				{"10.1.1.0/24", ""},
+				{"10.1.0.0/16", "allowed"},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.1.0.1", "allowed"},
+				{"10.1.1.1", ""},
+				{"10.1.2.1", "allowed"},
+			},
+		},
+		{
+			name: "more specific value inside tombstone",
+			inserts: []struct{ cidr, value string }{
+				{"192.168.0.0/16", "allowed"},
+				{"192.168.1.0/24", ""},
+				{"192.168.1.128/25", "edge"},
+			},
+			lookups: []struct{ addr, want string }{
+				{"192.168.0.1", "allowed"},
+				{"192.168.1.1", ""},
+				{"192.168.1.200", "edge"},
+			},
+		},
+		{
+			name: "value replaces tombstone of the same prefix",
+			inserts: []struct{ cidr, value string }{
+				{"172.16.0.0/12", "allowed"},
+				{"172.16.1.0/24", ""},
+				{"172.16.1.0/24", "restored"},
+			},
+			lookups: []struct{ addr, want string }{
+				{"172.16.1.1", "restored"},
+				{"172.16.2.1", "allowed"},
+			},
+		},
+		{
+			name: "broader tombstone over nested prefixes",
+			inserts: []struct{ cidr, value string }{
+				{"10.0.0.0/8", "allowed"},
+				{"10.1.1.0/24", "rack"},
+				{"10.1.2.0/28", "edge"},
+				{"10.1.0.0/16", ""},
+			},
+			lookups: []struct{ addr, want string }{
+				{"10.1.0.1", ""},
+				{"10.1.1.1", "rack"},
+				{"10.1.2.1", "edge"},
+				{"10.1.2.100", ""},
+				{"10.2.0.1", "allowed"},
+			},
+		},
+		{
+			name: "IPv6 tombstone",
+			inserts: []struct{ cidr, value string }{
+				{"2001:db8::/32", "doc"},
+				{"2001:db8:dead::/48", ""},
+			},
+			lookups: []struct{ addr, want string }{
+				{"2001:db8::1", "doc"},
+				{"2001:db8:dead::1", ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lpm := New()
+			for _, ins := range tt.inserts {
+				prefix := netip.MustParsePrefix(ins.cidr)
+				if ins.value == "" {
+					lpm.InsertTombstone(prefix)
+				} else {
+					lpm.Insert(prefix, ins.value)
+				}
+			}
+
+			storage, err := lpm.PackToSharedStorage()
+			if err != nil {
+				t.Fatalf("PackToSharedStorage failed: %v", err)
+			}
+			shared, err := NewWithSharedStorage(storage)
+			if err != nil {
+				t.Fatalf("NewWithSharedStorage failed: %v", err)
+			}
+
+			for name, lpm := range map[string]*LPM{"dynamic": lpm, "shared": shared} {
+				for _, l := range tt.lookups {
+					got, found := lpm.Lookup(netip.MustParseAddr(l.addr))
+					if found != (l.want != "") || got != l.want {
+						t.Errorf("%s: Lookup(%s) = %q (found=%v), want %q", name, l.addr, got, found, l.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestTombstoneJSON tests that tombstones survive a JSON round trip
+func TestTombstoneJSON(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "allowed")
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.0.0/16"))
+
+	data, err := json.Marshal(lpm)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	want := `[{"cidr":"10.0.0.0/8","value":"allowed"},{"cidr":"10.1.0.0/16","value":"","tombstone":true}]`
+	if string(data) != want {
+		t.Errorf("MarshalJSON = %s, want %s", data, want)
+	}
+
+	restored := New()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if _, found := restored.Lookup(netip.MustParseAddr("10.1.2.3")); found {
+		t.Error("Lookup(10.1.2.3) found a value inside a tombstone")
+	}
+	if got, found := restored.Lookup(netip.MustParseAddr("10.2.3.4")); !found || got != "allowed" {
+		t.Errorf("Lookup(10.2.3.4) = %q (found=%v), want %q", got, found, "allowed")
+	}
+}
+
+// TestNumericTombstone tests tombstones in a numeric trie
+func TestNumericTombstone(t *testing.T) {
+	asn := NewU32()
+	asn.Insert(netip.MustParsePrefix("10.0.0.0/8"), 64512)
+	asn.InsertTombstone(netip.MustParsePrefix("10.1.0.0/16"))
+
+	if got, found := asn.Lookup(netip.MustParseAddr("10.2.0.1")); !found || got != 64512 {
+		t.Errorf("Lookup(10.2.0.1) = %d (found=%v), want 64512", got, found)
+	}
+	if got, found := asn.Lookup(netip.MustParseAddr("10.1.0.1")); found {
+		t.Errorf("Lookup(10.1.0.1) = %d, want no match", got)
+	}
+}
@@ -0,0 +1,165 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestInsertWithTTL tests that expired prefixes are deleted and covering prefixes restored
+func TestInsertWithTTL(t *testing.T) {
+	lpm := New()
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	start := time.Now()
+	if err := lpm.InsertWithTTL(netip.MustParsePrefix("10.1.0.0/16"), "threat", time.Hour); err != nil {
+		t.Fatalf("InsertWithTTL: %v", err)
+	}
+	if err := lpm.InsertWithTTL(netip.MustParsePrefix("10.1.2.0/24"), "rack", 2*time.Hour); err != nil {
+		t.Fatalf("InsertWithTTL: %v", err)
+	}
+	if err := lpm.InsertWithTTL(netip.MustParsePrefix("192.168.0.0/16"), "home", 2*time.Hour); err != nil {
+		t.Fatalf("InsertWithTTL: %v", err)
+	}
+	if err := lpm.InsertWithTTL(netip.MustParsePrefix("10.2.0.0/16"), "zero", 0); err == nil {
+		t.Errorf("InsertWithTTL with zero TTL succeeded")
+	}
+
+	next, ok := lpm.NextExpiry()
+	if !ok || next.Before(start.Add(time.Hour)) || next.After(time.Now().Add(time.Hour)) {
+		t.Errorf("NextExpiry() = %v, %v, want about an hour from now", next, ok)
+	}
+
+	lookups := func(want map[string]string) {
+		t.Helper()
+		for addr, value := range want {
+			got, ok := lpm.Lookup(netip.MustParseAddr(addr))
+			if value == "" && ok || value != "" && got != value {
+				t.Errorf("Lookup(%s) = %q, %v, want %q", addr, got, ok, value)
+			}
+		}
+	}
+
+	if n, err := lpm.ExpireNow(time.Now()); n != 0 || err != nil {
+		t.Errorf("ExpireNow(now) = %d, %v, want nothing expired", n, err)
+	}
+	lookups(map[string]string{"10.1.0.1": "threat", "10.1.2.1": "rack", "192.168.1.1": "home"})
+
+	// Re-inserting without a TTL makes the prefix permanent
+	if err := lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "home"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	if n, err := lpm.ExpireNow(time.Now().Add(90 * time.Minute)); n != 1 || err != nil {
+		t.Errorf("ExpireNow(+90m) = %d, %v, want 1", n, err)
+	}
+	lookups(map[string]string{"10.1.0.1": "private", "10.1.2.1": "rack", "192.168.1.1": "home"})
+
+	if n, err := lpm.ExpireNow(time.Now().Add(3 * time.Hour)); n != 1 || err != nil {
+		t.Errorf("ExpireNow(+3h) = %d, %v, want 1", n, err)
+	}
+	lookups(map[string]string{"10.1.0.1": "private", "10.1.2.1": "private", "192.168.1.1": "home"})
+
+	if _, ok := lpm.NextExpiry(); ok {
+		t.Errorf("NextExpiry() reports an expiry after all expired")
+	}
+}
+
+// TestExpireNowShadowed tests expiring prefixes that shadow a covering prefix completely,
+// and a prefix a higher priority shadows completely
+func TestExpireNowShadowed(t *testing.T) {
+	lpm := New()
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/24"), "covering"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	for _, cidr := range []string{"10.0.0.0/25", "10.0.0.128/25"} {
+		if err := lpm.InsertWithTTL(netip.MustParsePrefix(cidr), "half", time.Hour); err != nil {
+			t.Fatalf("InsertWithTTL: %v", err)
+		}
+	}
+	if err := lpm.InsertWithPriority(netip.MustParsePrefix("192.168.0.0/16"), "high", 5); err != nil {
+		t.Fatalf("InsertWithPriority: %v", err)
+	}
+	if err := lpm.InsertWithTTL(netip.MustParsePrefix("192.168.1.0/24"), "hidden", time.Hour); err != nil {
+		t.Fatalf("InsertWithTTL: %v", err)
+	}
+
+	if n, err := lpm.ExpireNow(time.Now().Add(2 * time.Hour)); n != 3 || err != nil {
+		t.Errorf("ExpireNow(+2h) = %d, %v, want 3", n, err)
+	}
+	for addr, want := range map[string]string{"10.0.0.1": "covering", "10.0.0.129": "covering", "192.168.1.1": "high"} {
+		if got, _ := lpm.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	// The hidden prefix is gone, so deleting the one above it leaves nothing behind
+	if _, err := lpm.Delete(netip.MustParsePrefix("192.168.0.0/16")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, found := lpm.Lookup(netip.MustParseAddr("192.168.1.1")); found {
+		t.Errorf("Lookup(192.168.1.1) = %q after deleting every prefix, want no match", got)
+	}
+}
+
+// TestExpireNowEvents tests that expiry is reported to watchers as deletes, in order of expiry
+func TestExpireNowEvents(t *testing.T) {
+	lpm := New()
+	for i, prefix := range []string{"10.3.0.0/16", "10.1.0.0/16", "10.2.0.0/16"} {
+		if err := lpm.InsertWithTTL(netip.MustParsePrefix(prefix), "feed", time.Duration(i+1)*time.Minute); err != nil {
+			t.Fatalf("InsertWithTTL: %v", err)
+		}
+	}
+	events := lpm.Watch()
+	if n, err := lpm.ExpireNow(time.Now().Add(time.Hour)); n != 3 || err != nil {
+		t.Fatalf("ExpireNow = %d, %v, want 3", n, err)
+	}
+	for _, want := range []string{"10.3.0.0/16", "10.1.0.0/16", "10.2.0.0/16"} {
+		got := <-events
+		if got.Kind != EventDelete || got.Prefix != netip.MustParsePrefix(want) || got.Old != "feed" {
+			t.Errorf("got %+v, want delete of %s", got, want)
+		}
+	}
+}
+
+// TestExpireNowReadOnly tests that a read-only trie refuses to expire prefixes
+func TestExpireNowReadOnly(t *testing.T) {
+	lpm := New()
+	if err := lpm.InsertWithTTL(netip.MustParsePrefix("10.0.0.0/8"), "feed", time.Minute); err != nil {
+		t.Fatalf("InsertWithTTL: %v", err)
+	}
+	if _, err := lpm.Snapshot().ExpireNow(time.Now().Add(time.Hour)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ExpireNow on snapshot: got %v, want ErrReadOnly", err)
+	}
+	if err := lpm.Snapshot().InsertWithTTL(netip.MustParsePrefix("10.0.0.0/8"), "feed", time.Minute); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("InsertWithTTL on snapshot: got %v, want ErrReadOnly", err)
+	}
+}
+
+// TestSyncExpireEvery tests the background expiry sweep
+func TestSyncExpireEvery(t *testing.T) {
+	s := NewSync(nil)
+	if err := s.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := s.InsertWithTTL(netip.MustParsePrefix("10.1.0.0/16"), "threat", time.Millisecond); err != nil {
+		t.Fatalf("InsertWithTTL: %v", err)
+	}
+	stop := s.ExpireEvery(time.Millisecond)
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, _ := s.Lookup(netip.MustParseAddr("10.1.0.1")); got == "private" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("prefix did not expire")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop() // idempotent
+}
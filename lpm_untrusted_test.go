@@ -0,0 +1,118 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+// TestUntrusted tests that crafted storage passing the checksum is rejected by Untrusted
+func TestUntrusted(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	lpm.Insert(netip.MustParsePrefix("192.168.1.0/24"), "home")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+
+	tests := []struct {
+		name    string
+		corrupt func(header *StorageHeader, storage []byte)
+	}{
+		{
+			name: "block reference out of range",
+			corrupt: func(header *StorageHeader, storage []byte) {
+				root := (*LPMBlock)(unsafe.Pointer(&storage[header.V4BlocksOffset]))
+				root[192] = encodeBlockRef(1 << 20)
+			},
+		},
+		{
+			name: "cycle",
+			corrupt: func(header *StorageHeader, storage []byte) {
+				root := (*LPMBlock)(unsafe.Pointer(&storage[header.V4BlocksOffset]))
+				child := (*LPMBlock)(unsafe.Pointer(&storage[header.V4BlocksOffset+uint64(decodeBlockRef(root[192]))*blockSize*4]))
+				child[168] = encodeBlockRef(0)
+			},
+		},
+		{
+			name: "value index out of range",
+			corrupt: func(header *StorageHeader, storage []byte) {
+				root := (*LPMBlock)(unsafe.Pointer(&storage[header.V6BlocksOffset]))
+				root[0x30] = encodeValue(1000, 4)
+			},
+		},
+		{
+			name: "value length past its slot",
+			corrupt: func(header *StorageHeader, storage []byte) {
+				storage[header.ValuesOffset] = 0xff
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := lpm.PackToSharedStorage()
+			if err != nil {
+				t.Fatalf("PackToSharedStorage: %v", err)
+			}
+			if _, err := NewWithSharedStorage(storage, Untrusted()); err != nil {
+				t.Fatalf("NewWithSharedStorage(Untrusted) of intact storage: %v", err)
+			}
+
+			tt.corrupt((*StorageHeader)(unsafe.Pointer(&storage[0])), storage)
+			// An attacker recomputes the checksum
+			version := (*StorageHeader)(unsafe.Pointer(&storage[0])).Version
+			(*StorageHeader)(unsafe.Pointer(&storage[0])).Checksum = storageChecksum(storage, len(storage), version)
+
+			if _, err := NewWithSharedStorage(storage); err != nil {
+				t.Fatalf("trusted NewWithSharedStorage: %v", err)
+			}
+			if _, err := NewWithSharedStorage(storage, Untrusted()); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("NewWithSharedStorage(Untrusted) = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+// FuzzNewWithSharedStorage tests that storage accepted by the Untrusted loader can be used
+// without panics
+func FuzzNewWithSharedStorage(f *testing.F) {
+	lpm := newPackTestLPM()
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	lpm.SetMetadata(MetadataDatasetVersion, "1")
+	for _, m := range []*LPM{New(), lpm} {
+		storage, err := m.PackToSharedStorage()
+		if err != nil {
+			f.Fatalf("PackToSharedStorage: %v", err)
+		}
+		f.Add(storage)
+	}
+
+	addrs := []netip.Addr{
+		netip.MustParseAddr("0.0.0.0"),
+		netip.MustParseAddr("10.1.2.3"),
+		netip.MustParseAddr("192.168.1.1"),
+		netip.MustParseAddr("255.255.255.255"),
+		netip.MustParseAddr("::"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("ffff::ffff"),
+	}
+	f.Fuzz(func(t *testing.T, storage []byte) {
+		// Skip the checksum, so mutations reach the verification
+		m, err := NewWithSharedStorage(storage, Untrusted(), SkipChecksum())
+		if err != nil {
+			return
+		}
+		for _, addr := range addrs {
+			m.Lookup(addr)
+			m.LookupBytes(addr)
+		}
+		m.Entries()
+		m.Stats()
+		if err := m.Insert(netip.MustParsePrefix("10.0.0.0/16"), "fuzz"); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if err := m.Verify(); err != nil {
+			t.Fatalf("Verify after insert: %v", err)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package lpm
+
+import (
+	"bytes"
+	"maps"
+	"net/netip"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+// TestInsertLookupBytes tests binary values in dynamic and shared modes
+func TestInsertLookupBytes(t *testing.T) {
+	payload1 := []byte{0x08, 0x96, 0x01, 0x00, 0xff}
+	payload2 := []byte{0x00}
+	empty := []byte{}
+
+	lpm := New()
+	lpm.InsertBytes(netip.MustParsePrefix("10.0.0.0/8"), payload1)
+	lpm.InsertBytes(netip.MustParsePrefix("10.1.0.0/16"), payload2)
+	lpm.InsertBytes(netip.MustParsePrefix("2001:db8::/32"), empty)
+
+	// The payload must be copied on insert
+	payload1[0] = 0x42
+	want1 := []byte{0x08, 0x96, 0x01, 0x00, 0xff}
+
+	check := func(t *testing.T, lpm *LPM) {
+		tests := []struct {
+			addr string
+			want []byte
+		}{
+			{"10.2.3.4", want1},
+			{"10.1.2.3", payload2},
+			{"2001:db8::1", empty},
+		}
+		for _, tt := range tests {
+			got, found := lpm.LookupBytes(netip.MustParseAddr(tt.addr))
+			if !found || !bytes.Equal(got, tt.want) {
+				t.Errorf("LookupBytes(%s) = %x (found=%v), want %x", tt.addr, got, found, tt.want)
+			}
+		}
+		if got, found := lpm.LookupBytes(netip.MustParseAddr("192.168.1.1")); found {
+			t.Errorf("LookupBytes(192.168.1.1) = %x, want no match", got)
+		}
+	}
+
+	t.Run("dynamic", func(t *testing.T) {
+		check(t, lpm)
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		storage, err := lpm.PackToSharedStorage()
+		if err != nil {
+			t.Fatalf("PackToSharedStorage failed: %v", err)
+		}
+		shared, err := NewWithSharedStorage(storage)
+		if err != nil {
+			t.Fatalf("NewWithSharedStorage failed: %v", err)
+		}
+		check(t, shared)
+
+		// Shared values are returned without copying
+		got, _ := shared.LookupBytes(netip.MustParseAddr("10.2.3.4"))
+		start := &storage[0]
+		end := &storage[len(storage)-1]
+		if p := &got[0]; uintptrOf(p) < uintptrOf(start) || uintptrOf(p) > uintptrOf(end) {
+			t.Error("LookupBytes on shared storage returned a copy")
+		}
+	})
+}
+
+func uintptrOf(p *byte) uintptr {
+	return uintptr(unsafe.Pointer(p))
+}
+
+// TestValuesAndPrefixCounts tests value enumeration and per-value prefix counts
+func TestValuesAndPrefixCounts(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "dc1")
+	lpm.Insert(netip.MustParsePrefix("192.168.0.0/16"), "dc2")
+	lpm.Insert(netip.MustParsePrefix("192.168.1.0/24"), "dc1")
+	lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "dc1")
+	lpm.Insert(netip.MustParsePrefix("172.16.0.0/12"), "gone")
+	lpm.Insert(netip.MustParsePrefix("172.16.0.0/12"), "dc3")
+
+	got := slices.Collect(lpm.Values())
+	want := []string{"dc1", "dc2", "dc3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+
+	counts := lpm.PrefixCountByValue()
+	wantCounts := map[string]int{"dc1": 3, "dc2": 1, "dc3": 1}
+	if !maps.Equal(counts, wantCounts) {
+		t.Errorf("PrefixCountByValue() = %v, want %v", counts, wantCounts)
+	}
+
+	// Early termination of the iterator
+	for value := range lpm.Values() {
+		if value != "dc1" {
+			t.Errorf("first value = %q, want %q", value, "dc1")
+		}
+		break
+	}
+
+	// Shared storage reports the same values
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage failed: %v", err)
+	}
+	if got := slices.Collect(shared.Values()); !slices.Equal(got, want) {
+		t.Errorf("shared Values() = %v, want %v", got, want)
+	}
+	if counts := shared.PrefixCountByValue(); !maps.Equal(counts, wantCounts) {
+		t.Errorf("shared PrefixCountByValue() = %v, want %v", counts, wantCounts)
+	}
+}
@@ -0,0 +1,163 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"unsafe"
+)
+
+// TestVerify tests that tries built through the API pass verification
+func TestVerify(t *testing.T) {
+	lpm := newPackTestLPM()
+	lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	lpm.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "pinned", 3)
+	lpm.Insert(netip.MustParsePrefix("2001:db8::1/128"), "host")
+	lpm.Insert(netip.MustParsePrefix("0.0.0.0/0"), "default")
+	if _, err := lpm.Delete(netip.MustParsePrefix("2001:db8::1/128")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := New().Verify(); err != nil {
+		t.Errorf("empty: Verify() = %v", err)
+	}
+	if err := lpm.Verify(); err != nil {
+		t.Errorf("dynamic: Verify() = %v", err)
+	}
+
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+	snap := shared.Snapshot()
+	shared.Insert(netip.MustParsePrefix("192.168.7.0/24"), "override")
+	foreign, err := NewWithSharedStorage(foreignStorage(t, storage))
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage(foreign): %v", err)
+	}
+	compacted := newPackTestLPM()
+	compacted.Compact()
+
+	for name, m := range map[string]*LPM{"shared": shared, "snapshot": snap, "foreign": foreign, "compacted": compacted} {
+		if err := m.Verify(); err != nil {
+			t.Errorf("%s: Verify() = %v", name, err)
+		}
+	}
+}
+
+// TestVerifyCorrupt tests that broken invariants are reported as ErrCorrupt
+func TestVerifyCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(m *LPM)
+	}{
+		{
+			name: "block reference out of range",
+			corrupt: func(m *LPM) {
+				m.dynamic[v4LPM][0][10] = encodeBlockRef(1000)
+			},
+		},
+		{
+			name: "value index out of range",
+			corrupt: func(m *LPM) {
+				m.dynamic[v4LPM][0][10] = encodeValue(1000, 8)
+			},
+		},
+		{
+			name: "prefix longer than its path",
+			corrupt: func(m *LPM) {
+				m.dynamic[v4LPM][0][10] = encodeValue(0, 9)
+			},
+		},
+		{
+			name: "value without prefix length",
+			corrupt: func(m *LPM) {
+				m.dynamic[v6LPM][0][0x20] = 5
+			},
+		},
+		{
+			name: "cycle",
+			corrupt: func(m *LPM) {
+				child := decodeBlockRef(m.dynamic[v4LPM][0][192])
+				m.dynamic[v4LPM][child][168] = encodeBlockRef(0)
+			},
+		},
+		{
+			name: "block below the last byte",
+			corrupt: func(m *LPM) {
+				blk := m.getBlockRef(v4LPM, 0)
+				for _, b := range []byte{192, 168, 1} {
+					blk = m.getBlockRef(v4LPM, decodeBlockRef(blk[b]))
+				}
+				blk[1] = encodeBlockRef(0)
+			},
+		},
+		{
+			name: "root out of range",
+			corrupt: func(m *LPM) {
+				m.root[v6LPM] = 7
+			},
+		},
+		{
+			name: "dynamic value without priority",
+			corrupt: func(m *LPM) {
+				m.revPriorities = m.revPriorities[:0]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lpm := New()
+			lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+			lpm.Insert(netip.MustParsePrefix("192.168.1.0/24"), "home")
+			lpm.Insert(netip.MustParsePrefix("192.168.1.1/32"), "host")
+			lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+			if err := lpm.Verify(); err != nil {
+				t.Fatalf("Verify() before corruption = %v", err)
+			}
+			tt.corrupt(lpm)
+			if err := lpm.Verify(); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Verify() = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+// TestVerifySharedStorage tests verification of corrupted storage loaded without checksum
+func TestVerifySharedStorage(t *testing.T) {
+	lpm := New()
+	lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	lpm.Insert(netip.MustParsePrefix("192.168.1.0/24"), "home")
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+
+	header := (*StorageHeader)(unsafe.Pointer(&storage[0]))
+	root := (*LPMBlock)(unsafe.Pointer(&storage[header.V4BlocksOffset]))
+	root[192] = encodeBlockRef(99)
+	shared, err := NewWithSharedStorage(storage, SkipChecksum())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+	if err := shared.Verify(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Verify() = %v, want ErrCorrupt", err)
+	}
+
+	// An oversized value slot length
+	storage, _ = lpm.PackToSharedStorage()
+	header = (*StorageHeader)(unsafe.Pointer(&storage[0]))
+	storage[header.ValuesOffset] = 0xff
+	storage[header.ValuesOffset+1] = 0xff
+	shared, err = NewWithSharedStorage(storage, SkipChecksum())
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+	if err := shared.Verify(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Verify() = %v, want ErrCorrupt", err)
+	}
+}
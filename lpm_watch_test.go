@@ -0,0 +1,152 @@
+package lpm
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestWatch tests the events sent for inserts, updates, tombstones and deletes
+func TestWatch(t *testing.T) {
+	lpm := New()
+	events := lpm.Watch()
+
+	mustInsert := func(prefix, value string) {
+		t.Helper()
+		if err := lpm.Insert(netip.MustParsePrefix(prefix), value); err != nil {
+			t.Fatalf("Insert(%s): %v", prefix, err)
+		}
+	}
+	mustInsert("10.0.0.0/8", "private")
+	mustInsert("10.1.0.0/16", "dc1")
+	mustInsert("10.1.0.0/16", "dc1") // unchanged, no event
+	mustInsert("10.1.0.0/16", "dc2")
+	mustInsert("10.1.2.3/24", "rack") // reported masked
+	if err := lpm.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24")); err != nil {
+		t.Fatalf("InsertTombstone: %v", err)
+	}
+	if _, err := lpm.Delete(netip.MustParsePrefix("10.1.0.0/16")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := lpm.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "private", 5); err != nil {
+		t.Fatalf("InsertWithPriority: %v", err)
+	}
+	if _, err := lpm.Delete(netip.MustParsePrefix("192.168.0.0/16")); err != nil { // absent, no event
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := lpm.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	want := []Event{
+		{Kind: EventInsert, Prefix: netip.MustParsePrefix("10.0.0.0/8"), New: "private"},
+		{Kind: EventInsert, Prefix: netip.MustParsePrefix("10.1.0.0/16"), New: "dc1"},
+		{Kind: EventUpdate, Prefix: netip.MustParsePrefix("10.1.0.0/16"), Old: "dc1", New: "dc2"},
+		{Kind: EventInsert, Prefix: netip.MustParsePrefix("10.1.2.0/24"), New: "rack"},
+		{Kind: EventUpdate, Prefix: netip.MustParsePrefix("10.1.2.0/24"), Old: "rack", NewTombstone: true},
+		{Kind: EventDelete, Prefix: netip.MustParsePrefix("10.1.0.0/16"), Old: "dc2"},
+		{Kind: EventUpdate, Prefix: netip.MustParsePrefix("10.0.0.0/8"), Old: "private", New: "private"},
+		{Kind: EventInsert, Prefix: netip.MustParsePrefix("2001:db8::/32"), New: "doc"},
+	}
+	for i, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Errorf("event %d: got %+v, want %+v", i, got, w)
+			}
+		default:
+			t.Fatalf("event %d: missing, want %+v", i, w)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("unexpected event %+v", got)
+	default:
+	}
+
+	lpm.Unwatch(events)
+	if _, ok := <-events; ok {
+		t.Errorf("channel not closed by Unwatch")
+	}
+	mustInsert("172.16.0.0/12", "private") // must not block or panic
+}
+
+// TestWatchMultiple tests that every watcher receives every event
+func TestWatchMultiple(t *testing.T) {
+	lpm := New()
+	first, second := lpm.Watch(), lpm.Watch()
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	for _, ch := range []<-chan Event{first, second} {
+		if got := <-ch; got.Kind != EventInsert || got.New != "private" {
+			t.Errorf("got %+v", got)
+		}
+	}
+
+	lpm.Unwatch(first)
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "changed"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if got := <-second; got.Kind != EventUpdate || got.Old != "private" || got.New != "changed" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// TestWatchSharedStorage tests events for prefixes loaded from shared storage
+func TestWatchSharedStorage(t *testing.T) {
+	lpm := New()
+	if err := lpm.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	storage, err := lpm.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage: %v", err)
+	}
+	shared, err := NewWithSharedStorage(storage)
+	if err != nil {
+		t.Fatalf("NewWithSharedStorage: %v", err)
+	}
+
+	events := shared.Watch()
+	if err := shared.Insert(netip.MustParsePrefix("10.0.0.0/8"), "override"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if got := <-events; got.Kind != EventUpdate || got.Old != "private" || got.New != "override" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// TestWatchShadowed tests events for a prefix a higher priority shadows completely
+func TestWatchShadowed(t *testing.T) {
+	lpm := New()
+	events := lpm.Watch()
+	prefix := netip.MustParsePrefix("10.1.0.0/16")
+	if err := lpm.InsertWithPriority(netip.MustParsePrefix("10.0.0.0/8"), "hi", 5); err != nil {
+		t.Fatalf("InsertWithPriority: %v", err)
+	}
+	for _, value := range []string{"lo", "lo2"} {
+		if err := lpm.Insert(prefix, value); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+	if deleted, err := lpm.Delete(prefix); !deleted || err != nil {
+		t.Fatalf("Delete = %v, %v, want true, nil", deleted, err)
+	}
+
+	want := []Event{
+		{Kind: EventInsert, Prefix: netip.MustParsePrefix("10.0.0.0/8"), New: "hi"},
+		{Kind: EventInsert, Prefix: prefix, New: "lo"},
+		{Kind: EventUpdate, Prefix: prefix, Old: "lo", New: "lo2"},
+		{Kind: EventDelete, Prefix: prefix, Old: "lo2"},
+	}
+	for i, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Errorf("event %d: got %+v, want %+v", i, got, w)
+			}
+		default:
+			t.Fatalf("event %d: missing, want %+v", i, w)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package lpm
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// TestNewWellKnown tests classifying addresses of the special-purpose ranges
+func TestNewWellKnown(t *testing.T) {
+	m, err := NewWellKnown()
+	if err != nil {
+		t.Fatalf("NewWellKnown failed: %v", err)
+	}
+	for addr, want := range map[string]string{
+		"0.0.0.0":         WellKnownUnspecified,
+		"0.1.2.3":         WellKnownReserved,
+		"10.1.2.3":        WellKnownPrivate,
+		"172.31.255.255":  WellKnownPrivate,
+		"172.32.0.1":      "",
+		"192.168.1.1":     WellKnownPrivate,
+		"100.64.0.1":      WellKnownCGNAT,
+		"100.128.0.1":     "",
+		"127.0.0.1":       WellKnownLoopback,
+		"169.254.169.254": WellKnownLinkLocal,
+		"192.0.2.1":       WellKnownDocumentation,
+		"198.51.100.1":    WellKnownDocumentation,
+		"203.0.113.1":     WellKnownDocumentation,
+		"198.19.0.1":      WellKnownBenchmarking,
+		"224.0.0.1":       WellKnownMulticast,
+		"240.0.0.1":       WellKnownReserved,
+		"255.255.255.255": WellKnownBroadcast,
+		"8.8.8.8":         "",
+		"::":              WellKnownUnspecified,
+		"::1":             WellKnownLoopback,
+		"::ffff:10.0.0.1": WellKnownReserved,
+		"64:ff9b::a00:1":  WellKnownTranslation,
+		"2001::1":         WellKnownTranslation,
+		"2001:4::1":       WellKnownReserved,
+		"2001:2::1":       WellKnownBenchmarking,
+		"2001:db8::1":     WellKnownDocumentation,
+		"3fff::1":         WellKnownDocumentation,
+		"fd00::1":         WellKnownPrivate,
+		"fe80::1":         WellKnownLinkLocal,
+		"ff02::1":         WellKnownMulticast,
+		"2a00:1450::1":    "",
+	} {
+		got, found := m.Lookup(netip.MustParseAddr(addr))
+		if got != want || found != (want != "") {
+			t.Errorf("Lookup(%s) = %q, %v, want %q", addr, got, found, want)
+		}
+	}
+
+	if _, err := NewWellKnown(IPv6Max64()); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("NewWellKnown(IPv6Max64()) error = %v, want %v", err, ErrInvalidPrefix)
+	}
+}
+
+// TestParseSpecialRegistry tests reading the CSV form of the IANA registries
+func TestParseSpecialRegistry(t *testing.T) {
+	registry := `Address Block,Name,RFC,Allocation Date,Termination Date,Source,Destination,Forwardable,Globally Reachable,Reserved-by-Protocol
+0.0.0.0/8,"""This network""","[RFC791], Section 3.2",1981-09,N/A,True,False,False,False,True
+10.0.0.0/8,Private-Use,[RFC1918],1996-02,N/A,True,True,True,False,False
+192.0.0.0/24 [2],IETF Protocol Assignments,"[RFC6890], Section 2.1",2010-01,N/A,False,False,False,False,False
+"192.0.0.170/32, 192.0.0.171/32",NAT64/DNS64 Discovery,"[RFC8880][RFC7050], Section 2.2",2013-02,N/A,False,False,False,False,True
+`
+	entries, err := ParseSpecialRegistry(strings.NewReader(registry))
+	if err != nil {
+		t.Fatalf("ParseSpecialRegistry failed: %v", err)
+	}
+	want := []PrefixValue{
+		{Prefix: netip.MustParsePrefix("0.0.0.0/8"), Value: `"This network"`},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "Private-Use"},
+		{Prefix: netip.MustParsePrefix("192.0.0.0/24"), Value: "IETF Protocol Assignments"},
+		{Prefix: netip.MustParsePrefix("192.0.0.170/32"), Value: "NAT64/DNS64 Discovery"},
+		{Prefix: netip.MustParsePrefix("192.0.0.171/32"), Value: "NAT64/DNS64 Discovery"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ParseSpecialRegistry returned %d entries, want %d: %v", len(entries), len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %v, want %v", i, entries[i], want[i])
+		}
+	}
+
+	for name, input := range map[string]string{
+		"empty":           "",
+		"missing columns": "Prefix,Value\n10.0.0.0/8,ten\n",
+		"invalid block":   "Address Block,Name\n10.0.0.0,ten\n",
+	} {
+		if _, err := ParseSpecialRegistry(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseSpecialRegistry of %s input succeeded", name)
+		}
+	}
+}
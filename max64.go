@@ -0,0 +1,54 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// max64Bytes is the number of IPv6 address bytes looked at by tries created with IPv6Max64
+const max64Bytes = 8
+
+// IPv6Max64 limits the IPv6 trie to prefixes of at most /64, the longest most routing data
+// uses. Lookups then look at the first 8 address bytes only, and inserts of longer IPv6
+// prefixes fail with ErrInvalidPrefix. Pass it to New, or to NewWithSharedStorage, which
+// then rejects storage holding longer IPv6 prefixes. Storage packed from such a trie loads
+// without the option as well.
+func IPv6Max64() Option {
+	return func(o *options) {
+		o.ipv6Max64 = true
+	}
+}
+
+// checkMax64 reports an IPv6 prefix longer than /64 inserted into a trie limited to /64
+func (t *trie) checkMax64(net netip.Prefix) error {
+	if t.ipv6Max64 && net.Addr().Is6() && net.Bits() > max64Bytes*8 {
+		return fmt.Errorf("%w: %v is longer than /64", ErrInvalidPrefix, net)
+	}
+	return nil
+}
+
+// depth returns the number of block levels of the trie of the given protocol
+func (t *trie) depth(proto int) int {
+	seen := make([]bool, t.blockCount(proto))
+	level := []int{t.root[proto]}
+	seen[t.root[proto]] = true
+	depth := 0
+	for len(level) > 0 {
+		depth++
+		var next []int
+		for _, blockIdx := range level {
+			for _, slot := range t.getBlockRef(proto, blockIdx) {
+				if !isBlockRef(slot) {
+					continue
+				}
+				child := decodeBlockRef(slot)
+				if !seen[child] {
+					seen[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+		level = next
+	}
+	return depth
+}
@@ -0,0 +1,53 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+const (
+	// dynamicBlockSize is the memory Stats counts for a dynamic block: its data and the pointer to it
+	dynamicBlockSize = blockSize*4 + 8
+	// dynamicValueSize is the memory Stats counts for a dynamic value besides its bytes: the
+	// string header (ptr, len) and the approximate overhead of its values map entry
+	dynamicValueSize = 2*8 + 32 + 4
+	// prefixEntrySize is the memory Stats counts for a prefix of the prefix set: its key,
+	// value index and the approximate overhead of its map entry
+	prefixEntrySize = 18 + 4 + 16
+	// prefixBucketSize is the memory Stats counts for a bucket of the prefix set: its key
+	// and map pointer in the bucket map and the header of its own map
+	prefixBucketSize = 18 + 8 + 16 + 48
+)
+
+// WithMaxMemory limits the memory of an LPM, as reported by Stats().TotalSize, to the given
+// number of bytes: inserts that might grow it past the limit fail with ErrMemoryLimit and
+// leave the trie unchanged, so a feed of very specific prefixes cannot exhaust the process.
+// An insert is assumed to create a block for every address byte of the prefix and to add a
+// new value, so the trie stays below the limit, except for the copies an insert makes of
+// shared blocks below the prefix. Shared storage counts towards the limit. It applies to
+// New, NewWithSharedStorage and Build; zero means no limit.
+func WithMaxMemory(bytes int) Option {
+	return func(o *options) {
+		o.maxMemory = bytes
+	}
+}
+
+// checkMemory reports an insert of the prefix that might grow the LPM past its memory limit.
+// value is nil for tombstones.
+func (m *LPM) checkMemory(net netip.Prefix, value *valueKey) error {
+	if m.maxMemory <= 0 {
+		return nil
+	}
+	// A block per byte above the last one of the prefix, plus a copy of the root, and
+	// the prefix in a new bucket of the prefix set
+	need := m.Stats().TotalSize + (net.Bits()/8+1)*dynamicBlockSize + prefixEntrySize + prefixBucketSize
+	if value != nil {
+		if _, ok := m.values[*value]; !ok {
+			need += len(value.value) + dynamicValueSize
+		}
+	}
+	if need > m.maxMemory {
+		return fmt.Errorf("%w: inserting %s may take %d bytes, over the limit of %d", ErrMemoryLimit, net, need, m.maxMemory)
+	}
+	return nil
+}
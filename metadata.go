@@ -0,0 +1,143 @@
+package lpm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Metadata section layout, all integers are uvarints so the section reads the same in
+// either byte order:
+//
+//	count        number of entries
+//	entries      key length, key, value length, value, sorted by key
+
+// Well-known metadata keys. Any other key may be used as well.
+const (
+	MetadataBuildTime      = "build_time"      // when the storage was built, RFC 3339
+	MetadataDatasetVersion = "dataset_version" // version of the source dataset
+)
+
+// ErrBadMetadata means the metadata section of the storage cannot be decoded
+var ErrBadMetadata = errors.New("malformed storage metadata")
+
+// SetMetadata sets a metadata entry that is packed along with the trie and can be read
+// back with Metadata or, without loading the trie, with StorageInfo. Storage loaded with
+// NewWithSharedStorage keeps its metadata, so repacking it preserves the entries.
+func (m *LPM) SetMetadata(key, value string) {
+	if m.metadata == nil {
+		m.metadata = make(map[string]string)
+	}
+	m.metadata[key] = value
+	m.generation++
+}
+
+// DeleteMetadata removes a metadata entry set with SetMetadata
+func (m *LPM) DeleteMetadata(key string) {
+	if _, ok := m.metadata[key]; ok {
+		delete(m.metadata, key)
+		m.generation++
+	}
+}
+
+// Metadata returns a copy of the metadata entries
+func (m *LPM) Metadata() map[string]string {
+	return maps.Clone(m.metadata)
+}
+
+// encodeMetadata returns the metadata section for the entries, empty if there are none
+func encodeMetadata(metadata map[string]string) []byte {
+	if len(metadata) == 0 {
+		return nil
+	}
+	data := binary.AppendUvarint(nil, uint64(len(metadata)))
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		data = binary.AppendUvarint(data, uint64(len(key)))
+		data = append(data, key...)
+		data = binary.AppendUvarint(data, uint64(len(metadata[key])))
+		data = append(data, metadata[key]...)
+	}
+	return data
+}
+
+// readMetadata decodes the metadata section of storage whose size has been validated
+func readMetadata(storage []byte, header *StorageHeader) (map[string]string, error) {
+	if header.MetadataSize == 0 {
+		return nil, nil
+	}
+	data := storage[header.MetadataOffset : header.MetadataOffset+header.MetadataSize]
+
+	readString := func() (string, bool) {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || n > uint64(len(data)-size) {
+			return "", false
+		}
+		s := string(data[size : size+int(n)])
+		data = data[size+int(n):]
+		return s, true
+	}
+
+	count, size := binary.Uvarint(data)
+	if size <= 0 || count > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: bad entry count", ErrBadMetadata)
+	}
+	data = data[size:]
+	metadata := make(map[string]string, count)
+	for range count {
+		key, ok := readString()
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated key", ErrBadMetadata)
+		}
+		value, ok := readString()
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated value of %q", ErrBadMetadata, key)
+		}
+		metadata[key] = value
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrBadMetadata, len(data))
+	}
+	return metadata, nil
+}
+
+// Info describes packed storage, see StorageInfo
+type Info struct {
+	Header     StorageHeader     // header in host byte order
+	Foreign    bool              // packed on a host of the other byte order
+	Compressed bool              // written by PackCompressed
+	Metadata   map[string]string // entries set with SetMetadata, nil if there are none
+}
+
+// StorageInfo returns the header and metadata of storage without loading the trie, e.g. to
+// tell which dataset a file on disk was built from. Section sizes are validated, but the
+// checksum is not; compressed storage is decompressed first.
+func StorageInfo(storage []byte) (Info, error) {
+	var info Info
+	if isCompressed(storage) {
+		decompressed, err := decompressStorage(storage)
+		if err != nil {
+			return info, err
+		}
+		storage = decompressed
+		info.Compressed = true
+	}
+
+	header, foreign, err := readHeader(storage)
+	if err != nil {
+		return info, err
+	}
+	if size := storageSize(&header); len(storage) < size {
+		return info, &ErrTruncated{Section: "storage", Need: size, Got: len(storage)}
+	}
+	metadata, err := readMetadata(storage, &header)
+	if err != nil {
+		return info, err
+	}
+
+	info.Header = header
+	info.Foreign = foreign
+	info.Metadata = metadata
+	return info, nil
+}
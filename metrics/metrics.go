@@ -0,0 +1,176 @@
+// Package metrics publishes the statistics of an LPM table as Prometheus metrics, so
+// services get the same dashboards without hand-rolling gauges:
+//
+//	table := lpm.NewAtomic(initial)
+//	collector := metrics.NewCollector(table.Load)
+//	prometheus.MustRegister(collector)
+//
+//	// Lookups through the collector are counted
+//	value, found := collector.Lookup(addr)
+//
+// The table is read on every scrape through the function passed to NewCollector, so
+// tables swapped in by an lpm.Atomic or lpm.Refresher are picked up. Block, value and
+// byte counts come from LPM.Stats, which takes constant time; prefix counts walk the
+// trie and are cached until the table or its generation changes.
+package metrics
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sakateka/lpm"
+)
+
+// Option configures a Collector
+type Option func(*options)
+
+type options struct {
+	namespace   string
+	constLabels prometheus.Labels
+}
+
+// WithNamespace sets the namespace of the metric names, "lpm" by default
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels to every metric, e.g. the name of the table when a service
+// registers a collector per table
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// Collector is a prometheus.Collector publishing the statistics of the current table:
+//
+//	lpm_blocks{proto, storage}    blocks per protocol in shared storage or process memory
+//	lpm_values{storage}           distinct values in shared storage or process memory
+//	lpm_storage_bytes{section}    approximate memory of the ipv4 and ipv6 blocks and the values
+//	lpm_prefixes{proto}           prefixes as LPM.Entries reports them
+//	lpm_generation                generation of the table, see LPM.Generation
+//	lpm_lookups_total{result}     lookups counted by Lookup and Observe, by hit or miss
+type Collector struct {
+	source func() *lpm.LPM
+
+	blocks     *prometheus.Desc
+	values     *prometheus.Desc
+	bytes      *prometheus.Desc
+	prefixes   *prometheus.Desc
+	generation *prometheus.Desc
+	lookups    *prometheus.Desc
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	mu          sync.Mutex // guards the prefix count cache
+	cachedTable *lpm.LPM
+	cachedGen   uint64
+	cachedCount [2]int
+}
+
+// NewCollector returns a collector publishing the statistics of the table source returns
+// on every scrape. source may return nil while no table is loaded, in which case only the
+// lookup counters are published.
+func NewCollector(source func() *lpm.LPM, opts ...Option) *Collector {
+	o := options{namespace: "lpm"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(o.namespace, "", name), help, labels, o.constLabels)
+	}
+	return &Collector{
+		source:     source,
+		blocks:     desc("blocks", "Number of trie blocks.", "proto", "storage"),
+		values:     desc("values", "Number of distinct values.", "storage"),
+		bytes:      desc("storage_bytes", "Approximate memory taken by the table.", "section"),
+		prefixes:   desc("prefixes", "Number of prefixes in the table.", "proto"),
+		generation: desc("generation", "Generation of the table, bumped by every modification."),
+		lookups:    desc("lookups_total", "Number of lookups.", "result"),
+	}
+}
+
+// Lookup looks up addr in the current table and counts the lookup
+func (c *Collector) Lookup(addr netip.Addr) (string, bool) {
+	var value string
+	var found bool
+	if m := c.source(); m != nil {
+		value, found = m.Lookup(addr)
+	}
+	c.Observe(found)
+	return value, found
+}
+
+// Observe counts a lookup made elsewhere, e.g. with LookupIndex or on a Poptrie
+func (c *Collector) Observe(found bool) {
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.blocks
+	ch <- c.values
+	ch <- c.bytes
+	ch <- c.prefixes
+	ch <- c.generation
+	ch <- c.lookups
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.lookups, prometheus.CounterValue, float64(c.hits.Load()), "hit")
+	ch <- prometheus.MustNewConstMetric(c.lookups, prometheus.CounterValue, float64(c.misses.Load()), "miss")
+
+	m := c.source()
+	if m == nil {
+		return
+	}
+	stats := m.Stats()
+	gauge := func(desc *prometheus.Desc, value int, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(value), labels...)
+	}
+	gauge(c.blocks, stats.IPv4SharedBlocks, "ipv4", "shared")
+	gauge(c.blocks, stats.IPv4DynamicBlocks, "ipv4", "dynamic")
+	gauge(c.blocks, stats.IPv6SharedBlocks, "ipv6", "shared")
+	gauge(c.blocks, stats.IPv6DynamicBlocks, "ipv6", "dynamic")
+	gauge(c.values, stats.SharedValues, "shared")
+	gauge(c.values, stats.DynamicValues, "dynamic")
+	gauge(c.bytes, stats.IPv4StorageSize, "ipv4")
+	gauge(c.bytes, stats.IPv6StorageSize, "ipv6")
+	gauge(c.bytes, stats.ValuesStorage, "values")
+	prefixes := c.prefixCounts(m)
+	gauge(c.prefixes, prefixes[0], "ipv4")
+	gauge(c.prefixes, prefixes[1], "ipv6")
+	ch <- prometheus.MustNewConstMetric(c.generation, prometheus.GaugeValue, float64(m.Generation()))
+}
+
+// prefixCounts returns the number of IPv4 and IPv6 prefixes of the table, walking it only
+// when it differs from the one of the previous scrape
+func (c *Collector) prefixCounts(m *lpm.LPM) [2]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cachedTable == m && c.cachedGen == m.Generation() {
+		return c.cachedCount
+	}
+	structure := m.StructureStats()
+	c.cachedTable, c.cachedGen = m, m.Generation()
+	c.cachedCount = [2]int{sum(structure.IPv4.PrefixLengths), sum(structure.IPv6.PrefixLengths)}
+	return c.cachedCount
+}
+
+func sum(counts []int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
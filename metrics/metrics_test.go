@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sakateka/lpm"
+)
+
+func TestCollector(t *testing.T) {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	table := lpm.NewAtomic(m)
+
+	c := NewCollector(table.Load, WithConstLabels(prometheus.Labels{"table": "routes"}))
+	if value, found := c.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != "b" {
+		t.Errorf("Lookup(10.1.2.3) = %q (found=%v), want b", value, found)
+	}
+	c.Lookup(netip.MustParseAddr("192.0.2.1"))
+	c.Observe(true)
+
+	stats := m.Stats()
+	want := fmt.Sprintf(`
+# HELP lpm_blocks Number of trie blocks.
+# TYPE lpm_blocks gauge
+lpm_blocks{proto="ipv4",storage="dynamic",table="routes"} 2
+lpm_blocks{proto="ipv4",storage="shared",table="routes"} 0
+lpm_blocks{proto="ipv6",storage="dynamic",table="routes"} 4
+lpm_blocks{proto="ipv6",storage="shared",table="routes"} 0
+# HELP lpm_generation Generation of the table, bumped by every modification.
+# TYPE lpm_generation gauge
+lpm_generation{table="routes"} 3
+# HELP lpm_lookups_total Number of lookups.
+# TYPE lpm_lookups_total counter
+lpm_lookups_total{result="hit",table="routes"} 2
+lpm_lookups_total{result="miss",table="routes"} 1
+# HELP lpm_prefixes Number of prefixes in the table.
+# TYPE lpm_prefixes gauge
+lpm_prefixes{proto="ipv4",table="routes"} 2
+lpm_prefixes{proto="ipv6",table="routes"} 1
+# HELP lpm_storage_bytes Approximate memory taken by the table.
+# TYPE lpm_storage_bytes gauge
+lpm_storage_bytes{section="ipv4",table="routes"} %d
+lpm_storage_bytes{section="ipv6",table="routes"} %d
+lpm_storage_bytes{section="values",table="routes"} %d
+# HELP lpm_values Number of distinct values.
+# TYPE lpm_values gauge
+lpm_values{storage="dynamic",table="routes"} 3
+lpm_values{storage="shared",table="routes"} 0
+`, stats.IPv4StorageSize, stats.IPv6StorageSize, stats.ValuesStorage)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// A swapped in table is picked up by the next scrape
+	next := lpm.New()
+	next.Insert(netip.MustParsePrefix("192.0.2.0/24"), "c")
+	next.Insert(netip.MustParsePrefix("198.51.100.0/24"), "c")
+	table.Store(next)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP lpm_prefixes Number of prefixes in the table.
+# TYPE lpm_prefixes gauge
+lpm_prefixes{proto="ipv4",table="routes"} 2
+lpm_prefixes{proto="ipv6",table="routes"} 0
+`), "lpm_prefixes"); err != nil {
+		t.Error(err)
+	}
+
+	// Modifications of the table are picked up as well
+	next.Insert(netip.MustParsePrefix("203.0.113.0/24"), "d")
+	if err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP lpm_prefixes Number of prefixes in the table.
+# TYPE lpm_prefixes gauge
+lpm_prefixes{proto="ipv4",table="routes"} 3
+lpm_prefixes{proto="ipv6",table="routes"} 0
+`), "lpm_prefixes"); err != nil {
+		t.Error(err)
+	}
+
+	// Nothing but lookups is published without a table
+	table.Store(nil)
+	if n := testutil.CollectAndCount(c); n != 2 {
+		t.Errorf("collected %d metrics without a table, want 2", n)
+	}
+	if _, found := c.Lookup(netip.MustParseAddr("10.1.2.3")); found {
+		t.Error("Lookup without a table found a value")
+	}
+}
+
+func TestCollectorLint(t *testing.T) {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	c := NewCollector(func() *lpm.LPM { return m }, WithNamespace("routes"))
+	problems, err := testutil.CollectAndLint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("%s: %s", p.Metric, p.Text)
+	}
+	if n := testutil.CollectAndCount(c, "routes_blocks"); n != 4 {
+		t.Errorf("collected %d routes_blocks metrics, want 4", n)
+	}
+}
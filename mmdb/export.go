@@ -0,0 +1,314 @@
+package mmdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// metadataStart separates the data section from the metadata of a database
+const metadataStart = "\xAB\xCD\xEFMaxMind.com"
+
+// ExportOptions configures Export
+type ExportOptions struct {
+	// Field is the dot-separated path of map keys the value is stored at in each record,
+	// e.g. "country.iso_code". If empty, the records are the values themselves.
+	Field string
+	// DatabaseType names the kind of data in the database, "LPM" if empty
+	DatabaseType string
+	// Description is the English description of the database, "Longest prefix match table"
+	// if empty
+	Description string
+	// BuildTime is the build time recorded in the metadata, the current time if zero
+	BuildTime time.Time
+}
+
+// Export writes the table as an IPv6 MaxMind DB, so MMDB readers such as the nginx geoip2
+// module or telegraf find the same values for every address as Lookup does, stored as
+// strings at opts.Field. Like in MaxMind databases, IPv4 networks are placed in ::/96, so
+// IPv6 prefixes within ::/96 are left out, and aliased at ::ffff:0:0/96 unless the table
+// holds IPv6 prefixes within that range.
+//
+// The networks written are those of Aggregate, the fewest that answer every lookup the same.
+func Export(w io.Writer, m *lpm.LPM, opts ExportOptions) error {
+	var path []string
+	if opts.Field != "" {
+		path = strings.Split(opts.Field, ".")
+	}
+	entries := m.Aggregate()
+	ipv4 := netip.MustParsePrefix("::/96")
+	for _, e := range entries {
+		// Keep broader IPv6 prefixes from covering the IPv4 addresses
+		if e.Prefix.Addr().Is6() && e.Prefix.Bits() <= 96 && e.Prefix.Contains(ipv4.Addr()) {
+			entries = append([]lpm.PrefixValue{{Prefix: netip.MustParsePrefix("0.0.0.0/0"), Tombstone: true}}, entries...)
+			break
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b lpm.PrefixValue) int {
+		return treeBits(a.Prefix) - treeBits(b.Prefix)
+	})
+
+	t := newTree()
+	for _, e := range entries {
+		if e.Prefix.Addr().Is6() && e.Prefix.Bits() > 96 && ipv4.Contains(e.Prefix.Addr()) {
+			continue
+		}
+		if e.Tombstone {
+			t.insert(e.Prefix, nil)
+			continue
+		}
+		var record any = e.Value
+		for i := len(path) - 1; i >= 0; i-- {
+			record = map[string]any{path[i]: record}
+		}
+		t.insert(e.Prefix, record)
+	}
+
+	databaseType := opts.DatabaseType
+	if databaseType == "" {
+		databaseType = "LPM"
+	}
+	description := opts.Description
+	if description == "" {
+		description = "Longest prefix match table"
+	}
+	buildTime := opts.BuildTime
+	if buildTime.IsZero() {
+		buildTime = time.Now()
+	}
+	return t.writeTo(w, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(buildTime.Unix()),
+		"database_type":               databaseType,
+		"description":                 map[string]any{"en": description},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{},
+	})
+}
+
+// tree is the binary search tree of a database being written, with its data section
+type tree struct {
+	// children of the nodes: node indexes if positive, no record if zero and
+	// -1-offset of a record in the data section if negative
+	nodes   [][2]int
+	data    bytes.Buffer
+	offsets map[string]int // offsets of the records in the data section by encoding
+}
+
+func newTree() *tree {
+	return &tree{nodes: [][2]int{{}}, offsets: make(map[string]int)}
+}
+
+// treeBits returns the depth of a prefix in the tree, IPv4 prefixes being placed in ::/96
+func treeBits(prefix netip.Prefix) int {
+	if prefix.Addr().Is4() {
+		return prefix.Bits() + 96
+	}
+	return prefix.Bits()
+}
+
+// insert maps the network to the record, or to no record if nil. Networks must be inserted
+// in order of increasing length, so narrower networks override broader ones.
+func (t *tree) insert(prefix netip.Prefix, record any) {
+	child := 0
+	if record != nil {
+		encoded := encodeValue(nil, record)
+		offset, ok := t.offsets[string(encoded)]
+		if !ok {
+			offset = t.data.Len()
+			t.offsets[string(encoded)] = offset
+			t.data.Write(encoded)
+		}
+		child = -1 - offset
+	}
+
+	addr := prefix.Addr().As16()
+	if prefix.Addr().Is4() {
+		addr = [16]byte{}
+		v4 := prefix.Addr().As4()
+		copy(addr[12:], v4[:])
+	}
+	bits := treeBits(prefix)
+	if bits == 0 {
+		t.nodes[0] = [2]int{child, child}
+		return
+	}
+	t.set(addr, bits, child)
+}
+
+// set sets the child at the end of the path of bits of addr, splitting the records of
+// broader networks on the way
+func (t *tree) set(addr [16]byte, bits int, child int) {
+	node := 0
+	for i := range bits - 1 {
+		bit := addr[i/8] >> (7 - i%8) & 1
+		if next := t.nodes[node][bit]; next <= 0 {
+			t.nodes = append(t.nodes, [2]int{next, next})
+			t.nodes[node][bit] = len(t.nodes) - 1
+		}
+		node = t.nodes[node][bit]
+	}
+	t.nodes[node][addr[(bits-1)/8]>>(7-(bits-1)%8)&1] = child
+}
+
+// child returns the child at the end of the path of bits of addr
+func (t *tree) child(addr [16]byte, bits int) int {
+	child := 0
+	for i := range bits {
+		child = t.nodes[child][addr[i/8]>>(7-i%8)&1]
+		if child <= 0 {
+			break
+		}
+	}
+	return child
+}
+
+// writeTo writes the database with the given metadata, to which the node count and record
+// size are added
+func (t *tree) writeTo(w io.Writer, metadata map[string]any) error {
+	mapped := netip.MustParseAddr("::ffff:0:0").As16()
+	if ipv4 := t.child([16]byte{}, 96); ipv4 > 0 && t.child(mapped, 96) <= 0 {
+		t.set(mapped, 96, ipv4)
+	}
+
+	nodeCount := len(t.nodes)
+	maxRecord := int64(nodeCount) + 16 + int64(t.data.Len())
+	var recordSize int
+	switch {
+	case maxRecord < 1<<24:
+		recordSize = 24
+	case maxRecord < 1<<28:
+		recordSize = 28
+	case maxRecord < 1<<32:
+		recordSize = 32
+	default:
+		return fmt.Errorf("mmdb: %d nodes and %d bytes of records exceed the largest record size", nodeCount, t.data.Len())
+	}
+	metadata["node_count"] = uint32(nodeCount)
+	metadata["record_size"] = uint16(recordSize)
+
+	bw := bufio.NewWriter(w)
+	var node [8]byte
+	for _, children := range t.nodes {
+		var records [2]uint32
+		for i, child := range children {
+			switch {
+			case child == 0:
+				records[i] = uint32(nodeCount)
+			case child < 0:
+				records[i] = uint32(nodeCount + 16 - 1 - child)
+			default:
+				records[i] = uint32(child)
+			}
+		}
+		left, right := records[0], records[1]
+		switch recordSize {
+		case 24:
+			bw.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			bw.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>24<<4 | right>>24), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			binary.BigEndian.PutUint32(node[:4], left)
+			binary.BigEndian.PutUint32(node[4:], right)
+			bw.Write(node[:])
+		}
+	}
+	bw.Write(make([]byte, 16))
+	bw.Write(t.data.Bytes())
+	bw.WriteString(metadataStart)
+	bw.Write(encodeValue(nil, metadata))
+	return bw.Flush()
+}
+
+// Types of the MaxMind DB data format
+const (
+	typeString = 2
+	typeMap    = 7
+	typeUint16 = 5
+	typeUint32 = 6
+	typeUint64 = 9
+	typeArray  = 11
+	typeBool   = 14
+)
+
+// encodeValue appends a value in the MaxMind DB data format to buf. Map keys are written in
+// sorted order, so equal records encode to the same bytes.
+func encodeValue(buf []byte, v any) []byte {
+	switch v := v.(type) {
+	case string:
+		buf = appendControl(buf, typeString, len(v))
+		return append(buf, v...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		return appendControl(buf, typeBool, size)
+	case uint16:
+		return appendUint(buf, typeUint16, uint64(v))
+	case uint32:
+		return appendUint(buf, typeUint32, uint64(v))
+	case uint64:
+		return appendUint(buf, typeUint64, v)
+	case []any:
+		buf = appendControl(buf, typeArray, len(v))
+		for _, item := range v {
+			buf = encodeValue(buf, item)
+		}
+		return buf
+	case map[string]any:
+		buf = appendControl(buf, typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			buf = encodeValue(buf, key)
+			buf = encodeValue(buf, v[key])
+		}
+		return buf
+	}
+	panic(fmt.Sprintf("mmdb: cannot encode %T", v))
+}
+
+// appendUint appends an unsigned integer in as few bytes as it takes
+func appendUint(buf []byte, typ byte, n uint64) []byte {
+	b := bytes.TrimLeft(binary.BigEndian.AppendUint64(nil, n), "\x00")
+	buf = appendControl(buf, typ, len(b))
+	return append(buf, b...)
+}
+
+// appendControl appends the control byte of a value of the type and size, with the
+// extended type and size bytes it takes
+func appendControl(buf []byte, typ byte, size int) []byte {
+	var extra []byte
+	switch {
+	case size < 29:
+	case size < 29+256:
+		extra = []byte{byte(size - 29)}
+		size = 29
+	case size < 285+65536:
+		extra = binary.BigEndian.AppendUint16(nil, uint16(size-285))
+		size = 30
+	default:
+		n := size - 65821
+		extra = []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+		size = 31
+	}
+	if typ <= 7 {
+		buf = append(buf, typ<<5|byte(size))
+	} else {
+		buf = append(buf, byte(size), typ-7)
+	}
+	return append(buf, extra...)
+}
@@ -0,0 +1,164 @@
+package mmdb
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/sakateka/lpm"
+)
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// exportTestDB exports the table and opens the database, checking its format
+func exportTestDB(t *testing.T, m *lpm.LPM, opts ExportOptions) *maxminddb.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Export(&buf, m, opts); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	db, err := maxminddb.FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes failed: %v", err)
+	}
+	if err := db.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return db
+}
+
+// TestExport tests that MMDB readers find the values lookups do
+func TestExport(t *testing.T) {
+	m := lpm.New()
+	for cidr, value := range map[string]string{
+		"10.0.0.0/8":    "DE",
+		"10.1.0.0/16":   "FR",
+		"::/0":          "ZZ",
+		"2001:db8::/32": "AQ",
+		"::1.2.3.4/128": "hidden",
+	} {
+		if err := m.Insert(netip.MustParsePrefix(cidr), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.InsertTombstone(netip.MustParsePrefix("10.2.0.0/16")); err != nil {
+		t.Fatal(err)
+	}
+
+	built := time.Unix(1700000000, 0)
+	db := exportTestDB(t, m, ExportOptions{Field: "country.iso_code", Description: "countries", BuildTime: built})
+	if db.Metadata.DatabaseType != "LPM" || db.Metadata.Description["en"] != "countries" || db.Metadata.BuildEpoch != uint(built.Unix()) {
+		t.Errorf("Metadata = %+v, want type LPM, the description and the build time", db.Metadata)
+	}
+	for addr, want := range map[string]string{
+		"10.3.0.1":        "DE",
+		"10.1.2.3":        "FR",
+		"10.2.0.1":        "",
+		"192.0.2.1":       "",
+		"2001:db8::1":     "AQ",
+		"2001:db9::1":     "ZZ",
+		"::ffff:10.1.2.3": "FR",
+		"::1.2.3.4":       "",
+	} {
+		var record countryRecord
+		if _, _, err := db.LookupNetwork(net.ParseIP(addr), &record); err != nil {
+			t.Fatalf("LookupNetwork(%s) failed: %v", addr, err)
+		}
+		if record.Country.ISOCode != want {
+			t.Errorf("LookupNetwork(%s) = %q, want %q", addr, record.Country.ISOCode, want)
+		}
+	}
+
+	var value string
+	if err := exportTestDB(t, m, ExportOptions{}).Lookup(net.ParseIP("10.1.2.3"), &value); err != nil || value != "FR" {
+		t.Errorf("Lookup(10.1.2.3) without a field = %q, %v, want FR", value, err)
+	}
+}
+
+// TestExportLoad tests that a table exported and loaded back answers every lookup the same
+func TestExportLoad(t *testing.T) {
+	m := lpm.New()
+	for cidr, value := range map[string]string{
+		"0.0.0.0/0":         "default",
+		"10.0.0.0/8":        "private",
+		"10.1.0.0/16":       strings.Repeat("long ", 100),
+		"192.0.2.128/25":    "doc",
+		"2001:db8::/32":     "v6",
+		"2001:db8:1::/48":   "v6-more",
+		"2001:db8:1::1/128": "host",
+	} {
+		if err := m.Insert(netip.MustParsePrefix(cidr), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.InsertTombstone(netip.MustParsePrefix("10.2.0.0/16")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, m, ExportOptions{Field: "route.next_hop"}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "routes.mmdb")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path, "route.next_hop")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var want, got bytes.Buffer
+	if err := m.Dump(&want); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Dump(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("loaded table dumps\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+// TestExportRecordSize tests databases too large for 24-bit records
+func TestExportRecordSize(t *testing.T) {
+	tree := newTree()
+	tree.insert(netip.MustParsePrefix("10.0.0.0/8"), strings.Repeat("x", 1<<24))
+	tree.insert(netip.MustParsePrefix("10.1.0.0/16"), "small")
+	var buf bytes.Buffer
+	if err := tree.writeTo(&buf, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"database_type":               "Test",
+		"description":                 map[string]any{},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{},
+	}); err != nil {
+		t.Fatalf("writeTo failed: %v", err)
+	}
+	db, err := maxminddb.FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes failed: %v", err)
+	}
+	if db.Metadata.RecordSize != 28 {
+		t.Errorf("RecordSize = %d, want 28", db.Metadata.RecordSize)
+	}
+	var value string
+	if err := db.Lookup(net.ParseIP("10.1.0.1"), &value); err != nil || value != "small" {
+		t.Errorf("Lookup(10.1.0.1) = %q, %v, want small", value, err)
+	}
+	if err := db.Lookup(net.ParseIP("10.2.0.1"), &value); err != nil || len(value) != 1<<24 {
+		t.Errorf("Lookup(10.2.0.1) = %d bytes, %v, want %d", len(value), err, 1<<24)
+	}
+}
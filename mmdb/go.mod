@@ -0,0 +1,13 @@
+module github.com/sakateka/lpm/mmdb
+
+go 1.25.1
+
+require (
+	github.com/oschwald/maxminddb-golang v1.13.1
+	github.com/sakateka/lpm v0.1.0
+)
+
+require (
+	github.com/klauspost/compress v1.18.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+)
@@ -0,0 +1,141 @@
+// Package mmdb loads MaxMind DB (.mmdb) databases, such as GeoLite2 Country or ASN, into an
+// LPM table, so lookups that need a single field of the records go through the packed trie
+// instead of a maxminddb reader decoding a record per lookup:
+//
+//	countries, err := mmdb.Load("GeoLite2-Country.mmdb", "country.iso_code")
+//	if err != nil {
+//	    return err
+//	}
+//	if err := countries.SaveToFile("countries.lpm"); err != nil {
+//	    return err
+//	}
+//
+//	code, found := countries.Lookup(addr) // "DE", true
+//
+// The table can then be shared between processes like any other, see the shm package.
+// Conversely, Export writes a table as a MaxMind DB for existing MMDB readers.
+package mmdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/sakateka/lpm"
+)
+
+// Load reads the database at path into a trie mapping each of its networks to the field
+// of its record, see LoadReader
+func Load(path, field string, opts ...lpm.Option) (*lpm.LPM, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return LoadReader(db, field, opts...)
+}
+
+// LoadReader returns a trie mapping each network of the database to the field of its
+// record. The field is a dot-separated path of map keys and array indexes, such as
+// "country.iso_code", "autonomous_system_number" or "subdivisions.0.iso_code"; if empty,
+// the whole record is used. Strings are stored as they are, numbers and booleans in
+// decimal, and maps and arrays as JSON. Networks whose record lacks the field are left
+// out, so lookups of their addresses find nothing.
+//
+// IPv4 networks of IPv6 databases are loaded once, as IPv4 prefixes, rather than at every
+// place the database aliases them (::ffff:0:0/96, 2002::/16). The options are passed to
+// lpm.New, and the trie is compacted at the end.
+func LoadReader(db *maxminddb.Reader, field string, opts ...lpm.Option) (*lpm.LPM, error) {
+	var path []string
+	if field != "" {
+		path = strings.Split(field, ".")
+	}
+
+	m := lpm.New(opts...)
+	// Networks share records, so values are decoded once per record
+	values := make(map[uintptr]*string)
+	networks := db.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var skip struct{}
+		network, err := networks.Network(&skip)
+		if err != nil {
+			return nil, err
+		}
+		addr, ok := netip.AddrFromSlice(network.IP)
+		ones, bits := network.Mask.Size()
+		if !ok || bits != addr.BitLen() {
+			// Records of networks wider than the IPv4 subtree they contain
+			continue
+		}
+		prefix := netip.PrefixFrom(addr, ones)
+
+		offset, err := db.LookupOffset(network.IP)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+		value, decoded := values[offset]
+		if !decoded {
+			var record any
+			if err := db.Decode(offset, &record); err != nil {
+				return nil, fmt.Errorf("%s: %w", prefix, err)
+			}
+			if v, found := lookupField(record, path); found {
+				s, err := format(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", prefix, err)
+				}
+				value = &s
+			}
+			values[offset] = value
+		}
+		if value == nil {
+			continue
+		}
+		if err := m.Insert(prefix, *value); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+	}
+	if err := networks.Err(); err != nil {
+		return nil, err
+	}
+	m.Compact()
+	return m, nil
+}
+
+// lookupField returns the value at path in a decoded record
+func lookupField(v any, path []string) (any, bool) {
+	for _, key := range path {
+		switch node := v.(type) {
+		case map[string]any:
+			var found bool
+			if v, found = node[key]; !found {
+				return nil, false
+			}
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			v = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// format returns the stored form of a decoded value
+func format(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, float32, float64, int, uint16, uint32, uint64, *big.Int:
+		return fmt.Sprint(v), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
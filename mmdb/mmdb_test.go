@@ -0,0 +1,135 @@
+package mmdb
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/sakateka/lpm"
+)
+
+// writeTestDB writes a database mapping networks to arbitrary records
+func writeTestDB(t *testing.T, networks map[string]any) string {
+	t.Helper()
+	var prefixes []netip.Prefix
+	for cidr := range networks {
+		prefixes = append(prefixes, netip.MustParsePrefix(cidr))
+	}
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int { return treeBits(a) - treeBits(b) })
+	tree := newTree()
+	for _, prefix := range prefixes {
+		tree.insert(prefix, networks[prefix.String()])
+	}
+
+	var db bytes.Buffer
+	if err := tree.writeTo(&db, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               "Test",
+		"description":                 map[string]any{},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, db.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func country(code string) map[string]any {
+	return map[string]any{
+		"country":    map[string]any{"iso_code": code, "names": map[string]any{"en": code + " name"}},
+		"is_anycast": code == "AQ",
+	}
+}
+
+// TestLoad tests loading fields of records and skipping the aliases of IPv4 networks
+func TestLoad(t *testing.T) {
+	path := writeTestDB(t, map[string]any{
+		"10.0.0.0/8":      country("DE"),
+		"10.1.0.0/16":     country("FR"),
+		"192.0.2.0/24":    country("DE"),
+		"198.51.0.0/16":   map[string]any{"registered_country": map[string]any{"iso_code": "NL"}},
+		"2001:db8::/32":   country("AQ"),
+		"2001:db8:1::/48": map[string]any{},
+	})
+
+	countries, err := Load(path, "country.iso_code")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for addr, want := range map[string]string{
+		"10.2.3.4":        "DE",
+		"10.1.2.3":        "FR",
+		"192.0.2.1":       "DE",
+		"198.51.100.1":    "",
+		"2001:db8::1":     "AQ",
+		"2001:db9::1":     "",
+		"2001:db8:1::1":   "",
+		"::ffff:10.1.0.1": "",
+	} {
+		if got, _ := countries.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	for field, want := range map[string]string{
+		"is_anycast":         "true",
+		"country.names":      `{"en":"AQ name"}`,
+		"country.names.en":   "AQ name",
+		"country.iso_code.0": "",
+	} {
+		table, err := Load(path, field)
+		if err != nil {
+			t.Fatalf("Load(%q) failed: %v", field, err)
+		}
+		if got, _ := table.Lookup(netip.MustParseAddr("2001:db8::1")); got != want {
+			t.Errorf("Load(%q) value = %q, want %q", field, got, want)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.mmdb"), ""); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+// TestLoadReader tests loading an open database with trie options
+func TestLoadReader(t *testing.T) {
+	path := writeTestDB(t, map[string]any{
+		"10.0.0.0/8":          map[string]any{"autonomous_system_number": uint32(64500)},
+		"2001:db8::/32":       map[string]any{"autonomous_system_number": uint32(64501), "tags": []any{"a", "b"}},
+		"2001:db8:1:2::1/128": map[string]any{"autonomous_system_number": uint32(64502)},
+	})
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	asns, err := LoadReader(db, "autonomous_system_number")
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if got, _ := asns.Lookup(netip.MustParseAddr("10.1.2.3")); got != "64500" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want 64500", got)
+	}
+	tags, err := LoadReader(db, "tags.1")
+	if err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if got, _ := tags.Lookup(netip.MustParseAddr("2001:db8::1")); got != "b" {
+		t.Errorf("Lookup(2001:db8::1) = %q, want b", got)
+	}
+
+	if _, err := LoadReader(db, "", lpm.IPv6Max64()); err == nil {
+		t.Error("LoadReader of a /128 into a trie limited to /64 succeeded")
+	}
+}
@@ -0,0 +1,83 @@
+package mrt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sakateka/lpm"
+)
+
+// Value selects what Load maps prefixes to
+type Value int
+
+const (
+	// OriginASN maps prefixes to their origin AS in decimal, the one most peers see if
+	// they disagree, or the first of those on a tie. Prefixes whose routes all end in an
+	// AS_SET of several ASes are left out.
+	OriginASN Value = iota
+	// ASPath maps prefixes to the shortest AS path of the peers, formatted by Path.String
+	ASPath
+)
+
+// Load reads the unicast RIBs of the dump in r into a trie mapping every prefix to the
+// value selected by v. The options are passed to lpm.New, and the trie is compacted at
+// the end.
+func Load(r io.Reader, v Value, opts ...lpm.Option) (*lpm.LPM, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	m := lpm.New(opts...)
+	for {
+		rib, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value, ok := v.of(rib.Entries)
+		if !ok {
+			continue
+		}
+		if err := m.Insert(rib.Prefix, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", rib.Prefix, err)
+		}
+	}
+	m.Compact()
+	return m, nil
+}
+
+// of returns the value of a prefix with the given routes
+func (v Value) of(entries []Entry) (string, bool) {
+	switch v {
+	case OriginASN:
+		var best uint32
+		votes := make(map[uint32]int, 1)
+		for _, e := range entries {
+			origin, ok := e.Path.Origin(e.Peer)
+			if !ok {
+				continue
+			}
+			votes[origin]++
+			if votes[origin] > votes[best] {
+				best = origin
+			}
+		}
+		if len(votes) == 0 {
+			return "", false
+		}
+		return strconv.FormatUint(uint64(best), 10), true
+	case ASPath:
+		var best Path
+		for i, e := range entries {
+			if i == 0 || e.Path.Len() < best.Len() {
+				best = e.Path
+			}
+		}
+		return best.String(), len(entries) > 0
+	}
+	return "", false
+}
@@ -0,0 +1,351 @@
+// Package mrt reads the RIB dumps of route collectors, such as RouteViews and RIPE RIS, in
+// the MRT TABLE_DUMP_V2 format (RFC 6396) and loads them into an LPM table mapping every
+// announced prefix to its origin AS or AS path:
+//
+//	f, err := os.Open("rib.20250101.0000.bz2")
+//	if err != nil {
+//	    return err
+//	}
+//	defer f.Close()
+//	origins, err := mrt.Load(f, mrt.OriginASN)
+//	if err != nil {
+//	    return err
+//	}
+//
+//	asn, found := origins.Lookup(addr) // "13335", true
+//
+// Dumps compressed with gzip or bzip2 are decompressed on the fly. Reader gives access to
+// the entries of every prefix for other uses.
+package mrt
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMalformed means a record of the dump cannot be parsed. It is wrapped with details.
+var ErrMalformed = errors.New("mrt: malformed record")
+
+// MRT types and TABLE_DUMP_V2 subtypes, see RFC 6396 and RFC 8050
+const (
+	typeTableDumpV2 = 13
+
+	subtypePeerIndexTable        = 1
+	subtypeRIBIPv4Unicast        = 2
+	subtypeRIBIPv6Unicast        = 4
+	subtypeRIBIPv4UnicastAddPath = 8
+	subtypeRIBIPv6UnicastAddPath = 10
+)
+
+// BGP path attribute and AS_PATH segment types, see RFC 4271
+const (
+	attrASPath = 2
+
+	attrFlagExtendedLength = 0x10
+
+	SegmentSet      = 1 // AS_SET, unordered
+	SegmentSequence = 2 // AS_SEQUENCE, ordered from the neighbour to the origin
+)
+
+const (
+	// headerSize is the size of the common header of MRT records
+	headerSize = 12
+	// maxRecordSize bounds the records read, so corrupt lengths fail instead of
+	// allocating gigabytes
+	maxRecordSize = 16 << 20
+)
+
+// Peer is a BGP peer of the collector, from the PEER_INDEX_TABLE of the dump
+type Peer struct {
+	BGPID netip.Addr
+	Addr  netip.Addr
+	AS    uint32
+}
+
+// Segment is an AS_PATH segment
+type Segment struct {
+	Type byte // SegmentSet or SegmentSequence; confederation segments are kept as read
+	ASNs []uint32
+}
+
+// Path is the AS_PATH of a route
+type Path []Segment
+
+// Origin returns the AS that originated the route: the last AS of the path, or the peer AS
+// for routes the peer originated itself. Routes aggregated into an AS_SET of several ASes
+// have no single origin.
+func (p Path) Origin(peer Peer) (uint32, bool) {
+	if len(p) == 0 {
+		return peer.AS, peer.AS != 0
+	}
+	last := p[len(p)-1]
+	if len(last.ASNs) == 0 || last.Type == SegmentSet && len(last.ASNs) > 1 {
+		return 0, false
+	}
+	return last.ASNs[len(last.ASNs)-1], true
+}
+
+// Len returns the length of the path as BGP counts it, an AS_SET counting as one AS
+func (p Path) Len() int {
+	n := 0
+	for _, seg := range p {
+		if seg.Type == SegmentSet {
+			n++
+		} else {
+			n += len(seg.ASNs)
+		}
+	}
+	return n
+}
+
+// String formats the path like bgpdump does, e.g. "3356 1299 {64500,64501}"
+func (p Path) String() string {
+	var b strings.Builder
+	for i, seg := range p {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		sep := " "
+		if seg.Type == SegmentSet {
+			b.WriteByte('{')
+			sep = ","
+		}
+		for j, asn := range seg.ASNs {
+			if j > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(strconv.FormatUint(uint64(asn), 10))
+		}
+		if seg.Type == SegmentSet {
+			b.WriteByte('}')
+		}
+	}
+	return b.String()
+}
+
+// Entry is the route of one peer to a prefix
+type Entry struct {
+	Peer       Peer
+	Originated time.Time
+	PathID     uint32 // ADD-PATH identifier, zero in dumps without ADD-PATH
+	Path       Path
+}
+
+// RIB is a prefix with the routes of the peers to it
+type RIB struct {
+	Prefix  netip.Prefix
+	Entries []Entry
+}
+
+// Reader reads the unicast RIB records of a TABLE_DUMP_V2 dump. Records of other types and
+// subtypes, such as BGP4MP updates or multicast RIBs, are skipped.
+type Reader struct {
+	r     *bufio.Reader
+	peers []Peer
+	buf   []byte
+}
+
+// NewReader returns a reader of the dump in r, decompressing it if it starts with a gzip
+// or bzip2 header
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	magic, err := br.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReaderSize(zr, 1<<16)
+	case string(magic) == "BZh":
+		br = bufio.NewReaderSize(bzip2.NewReader(br), 1<<16)
+	}
+	return &Reader{r: br}, nil
+}
+
+// Peers returns the peers of the last PEER_INDEX_TABLE read
+func (r *Reader) Peers() []Peer {
+	return r.peers
+}
+
+// Next returns the next unicast RIB record, or io.EOF at the end of the dump
+func (r *Reader) Next() (*RIB, error) {
+	for {
+		var header [headerSize]byte
+		if _, err := io.ReadFull(r.r, header[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("%w: truncated header", ErrMalformed)
+			}
+			return nil, err
+		}
+		typ := binary.BigEndian.Uint16(header[4:])
+		subtype := binary.BigEndian.Uint16(header[6:])
+		length := binary.BigEndian.Uint32(header[8:])
+		if typ != typeTableDumpV2 {
+			if _, err := r.r.Discard(int(length)); err != nil {
+				return nil, fmt.Errorf("%w: truncated record of type %d", ErrMalformed, typ)
+			}
+			continue
+		}
+
+		if length > maxRecordSize {
+			return nil, fmt.Errorf("%w: %d byte record of subtype %d", ErrMalformed, length, subtype)
+		}
+		if cap(r.buf) < int(length) {
+			r.buf = make([]byte, length)
+		}
+		body := r.buf[:length]
+		if _, err := io.ReadFull(r.r, body); err != nil {
+			return nil, fmt.Errorf("%w: truncated record of subtype %d", ErrMalformed, subtype)
+		}
+		switch subtype {
+		case subtypePeerIndexTable:
+			peers, err := parsePeerIndexTable(body)
+			if err != nil {
+				return nil, err
+			}
+			r.peers = peers
+		case subtypeRIBIPv4Unicast, subtypeRIBIPv4UnicastAddPath:
+			return r.parseRIB(body, 4, subtype == subtypeRIBIPv4UnicastAddPath)
+		case subtypeRIBIPv6Unicast, subtypeRIBIPv6UnicastAddPath:
+			return r.parseRIB(body, 16, subtype == subtypeRIBIPv6UnicastAddPath)
+		}
+	}
+}
+
+// parser reads the fields of a record, recording the first read past its end
+type parser struct {
+	b   []byte
+	err error
+}
+
+func (p *parser) bytes(n int) []byte {
+	if p.err != nil || n > len(p.b) {
+		if p.err == nil {
+			p.err = fmt.Errorf("%w: field past the end of the record", ErrMalformed)
+		}
+		return make([]byte, n)
+	}
+	b := p.b[:n]
+	p.b = p.b[n:]
+	return b
+}
+
+func (p *parser) uint8() uint8   { return p.bytes(1)[0] }
+func (p *parser) uint16() uint16 { return binary.BigEndian.Uint16(p.bytes(2)) }
+func (p *parser) uint32() uint32 { return binary.BigEndian.Uint32(p.bytes(4)) }
+
+func (p *parser) addr(size int) netip.Addr {
+	addr, _ := netip.AddrFromSlice(p.bytes(size))
+	return addr
+}
+
+func parsePeerIndexTable(body []byte) ([]Peer, error) {
+	p := &parser{b: body}
+	p.uint32() // collector BGP ID
+	p.bytes(int(p.uint16()))
+	peers := make([]Peer, p.uint16())
+	for i := range peers {
+		typ := p.uint8()
+		peers[i].BGPID = p.addr(4)
+		if typ&1 != 0 {
+			peers[i].Addr = p.addr(16)
+		} else {
+			peers[i].Addr = p.addr(4)
+		}
+		if typ&2 != 0 {
+			peers[i].AS = p.uint32()
+		} else {
+			peers[i].AS = uint32(p.uint16())
+		}
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("peer index table: %w", p.err)
+	}
+	return peers, nil
+}
+
+func (r *Reader) parseRIB(body []byte, addrSize int, addPath bool) (*RIB, error) {
+	p := &parser{b: body}
+	p.uint32() // sequence number
+	bits := int(p.uint8())
+	if bits > addrSize*8 {
+		return nil, fmt.Errorf("%w: prefix length %d", ErrMalformed, bits)
+	}
+	var addr [16]byte
+	copy(addr[:], p.bytes((bits+7)/8))
+	var prefix netip.Prefix
+	if addrSize == 4 {
+		prefix = netip.PrefixFrom(netip.AddrFrom4([4]byte(addr[:4])), bits)
+	} else {
+		prefix = netip.PrefixFrom(netip.AddrFrom16(addr), bits)
+	}
+	rib := &RIB{Prefix: prefix.Masked(), Entries: make([]Entry, p.uint16())}
+	for i := range rib.Entries {
+		e := &rib.Entries[i]
+		peer := int(p.uint16())
+		if peer >= len(r.peers) {
+			return nil, fmt.Errorf("%w: %s: peer %d not in the peer index table", ErrMalformed, prefix, peer)
+		}
+		e.Peer = r.peers[peer]
+		e.Originated = time.Unix(int64(p.uint32()), 0)
+		if addPath {
+			e.PathID = p.uint32()
+		}
+		var err error
+		if e.Path, err = parseAttributes(p.bytes(int(p.uint16()))); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("%s: %w", prefix, p.err)
+	}
+	return rib, nil
+}
+
+// parseAttributes returns the AS_PATH of BGP path attributes, which TABLE_DUMP_V2 encodes
+// with 4-byte ASNs
+func parseAttributes(attrs []byte) (Path, error) {
+	p := &parser{b: attrs}
+	for len(p.b) > 0 && p.err == nil {
+		flags := p.uint8()
+		typ := p.uint8()
+		var length int
+		if flags&attrFlagExtendedLength != 0 {
+			length = int(p.uint16())
+		} else {
+			length = int(p.uint8())
+		}
+		value := p.bytes(length)
+		if typ != attrASPath || p.err != nil {
+			continue
+		}
+		var path Path
+		seg := &parser{b: value}
+		for len(seg.b) > 0 && seg.err == nil {
+			typ := seg.uint8()
+			s := Segment{Type: typ, ASNs: make([]uint32, seg.uint8())}
+			for i := range s.ASNs {
+				s.ASNs[i] = seg.uint32()
+			}
+			path = append(path, s)
+		}
+		if seg.err != nil {
+			return nil, fmt.Errorf("AS_PATH: %w", seg.err)
+		}
+		return path, nil
+	}
+	return nil, p.err
+}
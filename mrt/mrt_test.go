@@ -0,0 +1,213 @@
+package mrt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+// testDump builds MRT records
+type testDump struct {
+	bytes.Buffer
+	seq uint32
+}
+
+func (d *testDump) record(typ, subtype uint16, body []byte) {
+	header := binary.BigEndian.AppendUint32(nil, 1700000000)
+	header = binary.BigEndian.AppendUint16(header, typ)
+	header = binary.BigEndian.AppendUint16(header, subtype)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(body)))
+	d.Write(header)
+	d.Write(body)
+}
+
+// peers writes a PEER_INDEX_TABLE of an IPv4 peer with a 2-byte AS and IPv6 peers with
+// 4-byte ASes
+func (d *testDump) peers(asns ...uint32) {
+	body := []byte{192, 0, 2, 254, 0, 4, 't', 'e', 's', 't'}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(asns)))
+	for i, asn := range asns {
+		if i == 0 {
+			body = append(body, 0, 10, 0, 0, 1, 10, 0, 0, 1)
+			body = binary.BigEndian.AppendUint16(body, uint16(asn))
+			continue
+		}
+		body = append(body, 3, 10, 0, 0, byte(i+1))
+		body = append(body, netip.MustParseAddr("2001:db8::1").AsSlice()...)
+		body = binary.BigEndian.AppendUint32(body, asn)
+	}
+	d.record(typeTableDumpV2, subtypePeerIndexTable, body)
+}
+
+type testRoute struct {
+	peer   uint16
+	pathID uint32
+	path   Path
+}
+
+func (d *testDump) rib(cidr string, addPath bool, routes ...testRoute) {
+	prefix := netip.MustParsePrefix(cidr)
+	subtype := uint16(subtypeRIBIPv4Unicast)
+	if prefix.Addr().Is6() {
+		subtype = subtypeRIBIPv6Unicast
+	}
+	if addPath {
+		subtype += 6
+	}
+	d.seq++
+	body := binary.BigEndian.AppendUint32(nil, d.seq)
+	body = append(body, byte(prefix.Bits()))
+	body = append(body, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(routes)))
+	for _, route := range routes {
+		body = binary.BigEndian.AppendUint16(body, route.peer)
+		body = binary.BigEndian.AppendUint32(body, 1700000000)
+		if addPath {
+			body = binary.BigEndian.AppendUint32(body, route.pathID)
+		}
+		var segments []byte
+		for _, seg := range route.path {
+			segments = append(segments, seg.Type, byte(len(seg.ASNs)))
+			for _, asn := range seg.ASNs {
+				segments = binary.BigEndian.AppendUint32(segments, asn)
+			}
+		}
+		// ORIGIN, then AS_PATH with an extended length, then NEXT_HOP
+		attrs := []byte{0x40, 1, 1, 0, 0x50, attrASPath}
+		attrs = binary.BigEndian.AppendUint16(attrs, uint16(len(segments)))
+		attrs = append(attrs, segments...)
+		attrs = append(attrs, 0x40, 3, 4, 10, 0, 0, 1)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(attrs)))
+		body = append(body, attrs...)
+	}
+	d.record(typeTableDumpV2, subtype, body)
+}
+
+func seq(asns ...uint32) Path { return Path{{Type: SegmentSequence, ASNs: asns}} }
+
+func newTestDump() *testDump {
+	d := &testDump{}
+	d.peers(64496, 64497, 64498)
+	d.rib("10.0.0.0/8", false,
+		testRoute{peer: 0, path: seq(64496, 64510)},
+		testRoute{peer: 1, path: seq(64497, 3356, 64511)},
+		testRoute{peer: 2, path: seq(64498, 64511)})
+	d.rib("10.1.0.0/16", false, testRoute{peer: 1, path: nil})
+	d.rib("192.0.2.0/24", false, testRoute{peer: 0, path: Path{
+		{Type: SegmentSequence, ASNs: []uint32{64496}},
+		{Type: SegmentSet, ASNs: []uint32{64520, 64521}},
+	}})
+	// A BGP4MP message, which is skipped
+	d.record(16, 4, []byte{1, 2, 3})
+	d.rib("2001:db8::/32", true,
+		testRoute{peer: 1, pathID: 1, path: seq(64497, 4200000000)},
+		testRoute{peer: 1, pathID: 2, path: seq(64497, 64499, 4200000000)})
+	return d
+}
+
+// TestReader tests reading peers, RIB entries and AS paths
+func TestReader(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(newTestDump().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ribs []*RIB
+	for {
+		rib, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		ribs = append(ribs, rib)
+	}
+	if len(ribs) != 4 {
+		t.Fatalf("read %d RIBs, want 4", len(ribs))
+	}
+	if peers := r.Peers(); len(peers) != 3 || peers[0].AS != 64496 || peers[2].AS != 64498 || peers[2].Addr != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("Peers = %+v, want the 3 peers of the index table", peers)
+	}
+
+	first := ribs[0]
+	if first.Prefix != netip.MustParsePrefix("10.0.0.0/8") || len(first.Entries) != 3 || first.Entries[1].Peer.AS != 64497 {
+		t.Errorf("first RIB = %+v, want 10.0.0.0/8 with 3 entries", first)
+	}
+	if got := first.Entries[1].Path.String(); got != "64497 3356 64511" {
+		t.Errorf("Path = %q, want 64497 3356 64511", got)
+	}
+	set := ribs[2].Entries[0].Path
+	if got := set.String(); got != "64496 {64520,64521}" || set.Len() != 2 {
+		t.Errorf("Path = %q of length %d, want 64496 {64520,64521} of length 2", got, set.Len())
+	}
+	if _, ok := set.Origin(ribs[2].Entries[0].Peer); ok {
+		t.Error("Origin of a path ending in an AS_SET found")
+	}
+	if e := ribs[3].Entries[1]; e.PathID != 2 || e.Originated.Unix() != 1700000000 {
+		t.Errorf("ADD-PATH entry = %+v, want path ID 2", e)
+	}
+}
+
+// TestLoad tests loading origins and paths, including from a gzip-compressed dump
+func TestLoad(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(newTestDump().Bytes())
+	zw.Close()
+
+	origins, err := Load(&compressed, OriginASN)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for addr, want := range map[string]string{
+		"10.2.0.1":    "64511",
+		"10.1.0.1":    "64497",
+		"192.0.2.1":   "",
+		"2001:db8::1": "4200000000",
+	} {
+		if got, _ := origins.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	paths, err := Load(bytes.NewReader(newTestDump().Bytes()), ASPath, lpm.WithMaxMemory(1<<20))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for addr, want := range map[string]string{
+		"10.2.0.1":    "64496 64510",
+		"192.0.2.1":   "64496 {64520,64521}",
+		"2001:db8::1": "64497 4200000000",
+	} {
+		if got, _ := paths.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+// TestMalformed tests that truncated and inconsistent dumps fail
+func TestMalformed(t *testing.T) {
+	dump := newTestDump().Bytes()
+	noPeers := &testDump{}
+	noPeers.rib("10.0.0.0/8", false, testRoute{peer: 0, path: seq(64496)})
+	badLength := &testDump{}
+	badLength.peers(64496)
+	badLength.record(typeTableDumpV2, subtypeRIBIPv4Unicast, []byte{0, 0, 0, 1, 33, 10, 0, 0, 0, 0, 0})
+
+	for name, input := range map[string][]byte{
+		"truncated header": dump[:5],
+		"truncated record": dump[:len(dump)-3],
+		"unknown peer":     noPeers.Bytes(),
+		"prefix length":    badLength.Bytes(),
+	} {
+		if _, err := Load(bytes.NewReader(input), OriginASN); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Load of a dump with a %s error = %v, want ErrMalformed", name, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package lpm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// Multi maps prefixes to sets of values, e.g. a prefix tagged with both
+// "blocklist:spamhaus" and "region:eu". Each set is stored as a single encoded
+// value of an underlying LPM, so the usual longest prefix match applies to sets
+// and the trie can be packed to shared storage like any other LPM.
+type Multi struct {
+	lpm  *LPM
+	sets map[netip.Prefix][]string
+}
+
+// NewMulti creates an empty multi-value trie
+func NewMulti() *Multi {
+	return &Multi{
+		lpm:  New(),
+		sets: make(map[netip.Prefix][]string),
+	}
+}
+
+// NewMultiWithSharedStorage loads a multi-value trie packed with Multi.LPM().PackToSharedStorage().
+// Prefixes fully covered by more specific ones are not restored, see LPM.Entries.
+func NewMultiWithSharedStorage(storage []byte, opts ...Option) (*Multi, error) {
+	lpm, err := NewWithSharedStorage(storage, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Multi{
+		lpm:  lpm,
+		sets: make(map[netip.Prefix][]string),
+	}
+	for _, entry := range lpm.Entries() {
+		if entry.Tombstone {
+			continue
+		}
+		values, err := decodeSet(entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("prefix %s: %w", entry.Prefix, err)
+		}
+		m.sets[entry.Prefix] = values
+	}
+	return m, nil
+}
+
+// LPM returns the underlying trie holding the encoded value sets, e.g. for packing or Stats.
+// Its values must not be modified directly.
+func (m *Multi) LPM() *LPM {
+	return m.lpm
+}
+
+// Add attaches value to the set of the prefix. Adding a value that is already
+// in the set is a no-op.
+func (m *Multi) Add(net netip.Prefix, value string) error {
+	net = net.Masked()
+	values := m.sets[net]
+	if slices.Contains(values, value) {
+		return nil
+	}
+	return m.Set(net, append(slices.Clip(values), value)...)
+}
+
+// Set replaces the set of values of the prefix.
+func (m *Multi) Set(net netip.Prefix, values ...string) error {
+	net = net.Masked()
+	values = slices.Clone(values)
+	if err := m.lpm.Insert(net, encodeSet(values)); err != nil {
+		return err
+	}
+	m.sets[net] = values
+	return nil
+}
+
+// Values returns the set of values attached to exactly this prefix.
+func (m *Multi) Values(net netip.Prefix) []string {
+	return slices.Clone(m.sets[net.Masked()])
+}
+
+// LookupAll returns all values attached to the longest prefix containing addr.
+func (m *Multi) LookupAll(addr netip.Addr) []string {
+	encoded, ok := m.lpm.Lookup(addr)
+	if !ok {
+		return nil
+	}
+	values, _ := decodeSet(encoded)
+	return values
+}
+
+// encodeSet serializes values as a sequence of uvarint length-prefixed strings
+func encodeSet(values []string) string {
+	var buf []byte
+	for _, value := range values {
+		buf = binary.AppendUvarint(buf, uint64(len(value)))
+		buf = append(buf, value...)
+	}
+	return string(buf)
+}
+
+// decodeSet parses a value produced by encodeSet. The returned strings share memory with encoded.
+func decodeSet(encoded string) ([]string, error) {
+	var values []string
+	for len(encoded) > 0 {
+		n, size := binary.Uvarint([]byte(encoded[:min(len(encoded), binary.MaxVarintLen64)]))
+		if size <= 0 || uint64(len(encoded)-size) < n {
+			return nil, fmt.Errorf("malformed value set")
+		}
+		encoded = encoded[size:]
+		values = append(values, encoded[:n])
+		encoded = encoded[n:]
+	}
+	return values, nil
+}
@@ -0,0 +1,142 @@
+package lpm
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// Numeric is an LPM trie whose values are plain integers, e.g. origin ASNs or next-hop
+// indices. Values are kept in a flat integer table instead of the string table used by
+// LPM, so there is no per-value string header or string data to store.
+type Numeric[T uint32 | uint64] struct {
+	trie
+
+	values    map[T]int // value -> index
+	revValues []T       // index -> value
+}
+
+// U32 is a Numeric trie with uint32 values
+type U32 = Numeric[uint32]
+
+// U64 is a Numeric trie with uint64 values
+type U64 = Numeric[uint64]
+
+// NewNumeric creates an empty Numeric trie
+func NewNumeric[T uint32 | uint64]() *Numeric[T] {
+	return &Numeric[T]{
+		trie:   newTrie(),
+		values: make(map[T]int),
+	}
+}
+
+// NewU32 creates an empty trie with uint32 values
+func NewU32() *U32 {
+	return NewNumeric[uint32]()
+}
+
+// NewU64 creates an empty trie with uint64 values
+func NewU64() *U64 {
+	return NewNumeric[uint64]()
+}
+
+func (m *Numeric[T]) addValue(value T) (int, error) {
+	if valueIdx, ok := m.values[value]; ok {
+		return valueIdx, nil
+	}
+	valueIdx := len(m.revValues)
+	if err := checkValueCount(valueIdx); err != nil {
+		return 0, err
+	}
+	m.values[value] = valueIdx
+	m.revValues = append(m.revValues, value)
+	return valueIdx, nil
+}
+
+// Insert stores value for the prefix, failing like LPM.Insert with ErrInvalidPrefix
+// or ErrCapacity
+func (m *Numeric[T]) Insert(net netip.Prefix, value T) error {
+	if err := m.checkInsert(net); err != nil {
+		return err
+	}
+	valueIdx, err := m.addValue(value)
+	if err != nil {
+		return err
+	}
+	m.insert(net, valueIdx, 0, noPriority)
+	return nil
+}
+
+// Lookup returns the value of the longest prefix containing addr
+func (m *Numeric[T]) Lookup(addr netip.Addr) (T, bool) {
+	if !addr.IsValid() {
+		return 0, false
+	}
+	var valueIdx int
+	var ok bool
+	if addr.Is4() {
+		key := addr.As4()
+		valueIdx, ok = m.lookupKey(v4LPM, key[:])
+	} else {
+		key := addr.As16()
+		valueIdx, ok = m.lookupKey(v6LPM, key[:])
+	}
+	return m.valueByIndex(valueIdx, ok)
+}
+
+// Lookup4 looks up an IPv4 address given as a uint32 in host byte order, see LPM.Lookup4
+func (m *Numeric[T]) Lookup4(addr uint32) (T, bool) {
+	key := [4]byte{byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr)}
+	return m.valueByIndex(m.lookupKey(v4LPM, key[:]))
+}
+
+// Lookup6 looks up an IPv6 address given as its 16 raw bytes in network byte order
+func (m *Numeric[T]) Lookup6(addr [16]byte) (T, bool) {
+	return m.valueByIndex(m.lookupKey(v6LPM, addr[:]))
+}
+
+func (m *Numeric[T]) valueByIndex(valueIdx int, ok bool) (T, bool) {
+	if !ok || valueIdx >= len(m.revValues) {
+		return 0, false
+	}
+	return m.revValues[valueIdx], true
+}
+
+// Stats returns statistics about the trie including block counts and storage sizes
+func (m *Numeric[T]) Stats() Stats {
+	v4StorageSize := m.storageSize(v4LPM)
+	v6StorageSize := m.storageSize(v6LPM)
+
+	valStorageSize := 0
+	if len(m.revValues) > 0 {
+		var zero T
+		valueSize := binary.Size(zero)
+		// revValues slice: header + inline integers
+		valStorageSize += 3 * 8
+		valStorageSize += len(m.revValues) * valueSize
+		// values map overhead (approximate: map header + entries)
+		valStorageSize += 8 * 8
+		valStorageSize += len(m.revValues) * (valueSize + 8)
+	}
+
+	return Stats{
+		IPv4Blocks:      m.blockCount(v4LPM),
+		IPv6Blocks:      m.blockCount(v6LPM),
+		Values:          len(m.revValues),
+		IPv4StorageSize: v4StorageSize,
+		IPv6StorageSize: v6StorageSize,
+		ValuesStorage:   valStorageSize,
+		PrefixesStorage: m.prefixes.size(),
+		TotalSize:       v4StorageSize + v6StorageSize + valStorageSize + m.prefixes.size(),
+
+		IPv4SharedBlocks:  len(m.shared[v4LPM]),
+		IPv4DynamicBlocks: len(m.dynamic[v4LPM]),
+		IPv6SharedBlocks:  len(m.shared[v6LPM]),
+		IPv6DynamicBlocks: len(m.dynamic[v6LPM]),
+		DynamicValues:     len(m.revValues),
+	}
+}
+
+// noPriority is the priority lookup of value tables without priorities
+func noPriority(int) uint8 {
+	return 0
+}
@@ -0,0 +1,213 @@
+// Package objstore publishes packed tables to object storage and polls it for new
+// versions, so a build pipeline can distribute tables to any number of consumers through
+// a bucket:
+//
+//	store := &objstore.S3{Endpoint: "https://s3.eu-west-1.amazonaws.com", Region: "eu-west-1", Bucket: "tables"}
+//	publication, err := objstore.PublishTable(ctx, store, "routes", table)
+//
+// and on every consumer:
+//
+//	poller, err := objstore.NewPoller(ctx, store, "routes", time.Minute)
+//	value, found := poller.Lookup(addr)
+//
+// Every publication is stored under its own versioned key and then recorded as the latest
+// one, so consumers never read a table that is still being uploaded. Publisher and Fetcher
+// are interfaces; S3 implements both for S3-compatible storage (AWS, MinIO, Ceph, ...).
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// ErrNotFound is returned by Fetcher.Latest for tables that were never published
+var ErrNotFound = errors.New("objstore: table not published")
+
+// ErrConflict is returned by Publisher.Publish when another publisher stored the same
+// version of the table first
+var ErrConflict = errors.New("objstore: version already published")
+
+// Publication describes a published version of a table
+type Publication struct {
+	Name       string    `json:"name"`       // name of the table, e.g. "routes"
+	Version    uint64    `json:"version"`    // 1 for the first publication of the table, then increasing by 1
+	Key        string    `json:"key"`        // object key of the storage
+	Size       int64     `json:"size"`       // size of the storage in bytes
+	SHA256     string    `json:"sha256"`     // hex SHA-256 of the storage
+	Generation uint64    `json:"generation"` // generation recorded in the storage, see lpm.LPM.Generation
+	Time       time.Time `json:"time"`       // when the table was published
+}
+
+// Publisher stores versions of packed tables
+type Publisher interface {
+	// Publish stores storage written by PackTo or PackCompressed as the next version of the
+	// table name, then makes it the latest one
+	Publish(ctx context.Context, name string, storage []byte) (Publication, error)
+}
+
+// Fetcher reads tables stored by a Publisher
+type Fetcher interface {
+	// Latest returns the latest publication of the table name, or ErrNotFound
+	Latest(ctx context.Context, name string) (Publication, error)
+	// Fetch downloads the storage of a publication
+	Fetch(ctx context.Context, p Publication) ([]byte, error)
+}
+
+// PublishTable packs m compressed (see lpm.LPM.PackCompressed) and publishes it as the
+// next version of the table name
+func PublishTable(ctx context.Context, p Publisher, name string, m *lpm.LPM) (Publication, error) {
+	var storage bytes.Buffer
+	if _, err := m.PackCompressed(&storage); err != nil {
+		return Publication{}, err
+	}
+	return p.Publish(ctx, name, storage.Bytes())
+}
+
+// Load downloads the storage of a publication and loads it with lpm.NewFromReader, after
+// checking it against the SHA-256 of the publication. The options are passed to
+// lpm.NewFromReader, which verifies the checksum of the storage as well.
+func Load(ctx context.Context, f Fetcher, p Publication, opts ...lpm.Option) (*lpm.LPM, error) {
+	storage, err := f.Fetch(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(storage)
+	if got := hex.EncodeToString(sum[:]); got != p.SHA256 {
+		return nil, fmt.Errorf("%s: %w: SHA-256 %s, published %s", p.Key, lpm.ErrChecksumMismatch, got, p.SHA256)
+	}
+	m, err := lpm.NewFromReader(bytes.NewReader(storage), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Key, err)
+	}
+	return m, nil
+}
+
+// LoadLatest loads the latest publication of the table name, see Load
+func LoadLatest(ctx context.Context, f Fetcher, name string, opts ...lpm.Option) (*lpm.LPM, Publication, error) {
+	p, err := f.Latest(ctx, name)
+	if err != nil {
+		return nil, p, err
+	}
+	m, err := Load(ctx, f, p, opts...)
+	return m, p, err
+}
+
+// Poller keeps the latest publication of a table loaded: it checks the fetcher every
+// interval and loads a new version as soon as it is published. A failed poll keeps the
+// current table and is retried after the next interval.
+type Poller struct {
+	fetcher  Fetcher
+	name     string
+	interval time.Duration
+	opts     []lpm.Option
+
+	table lpm.Atomic
+
+	mu          sync.Mutex // serializes polls and guards publication and err
+	publication Publication
+	err         error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPoller loads the latest publication of the table name and starts polling for newer
+// ones every interval until Close is called or ctx is done. It fails if the first poll
+// fails. The options are passed to lpm.NewFromReader.
+func NewPoller(ctx context.Context, f Fetcher, name string, interval time.Duration, opts ...lpm.Option) (*Poller, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval: %v", interval)
+	}
+	p := &Poller{
+		fetcher:  f,
+		name:     name,
+		interval: interval,
+		opts:     opts,
+		done:     make(chan struct{}),
+	}
+	if err := p.Poll(ctx); err != nil {
+		return nil, err
+	}
+	ctx, p.cancel = context.WithCancel(ctx)
+	go p.run(ctx)
+	return p, nil
+}
+
+// Poll checks for a new publication right away and loads it
+func (p *Poller) Poll(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = p.poll(ctx)
+	return p.err
+}
+
+func (p *Poller) poll(ctx context.Context) error {
+	latest, err := p.fetcher.Latest(ctx, p.name)
+	if err != nil {
+		return err
+	}
+	if p.table.Load() != nil && latest.Key == p.publication.Key {
+		return nil
+	}
+	m, err := Load(ctx, p.fetcher, latest, p.opts...)
+	if err != nil {
+		return err
+	}
+	p.table.Store(m)
+	p.publication = latest
+	return nil
+}
+
+// Load returns the current table
+func (p *Poller) Load() *lpm.LPM {
+	return p.table.Load()
+}
+
+// Lookup looks addr up in the current table
+func (p *Poller) Lookup(addr netip.Addr) (string, bool) {
+	return p.table.Lookup(addr)
+}
+
+// Publication returns the publication of the current table
+func (p *Poller) Publication() Publication {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.publication
+}
+
+// Err returns the error of the last poll, or nil if it succeeded
+func (p *Poller) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Close stops polling. The current table stays loaded.
+func (p *Poller) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
+
+func (p *Poller) run(ctx context.Context) {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Poll(ctx)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// DefaultPartSize is the part size of multipart uploads if S3.PartSize is zero
+const DefaultPartSize = 16 << 20
+
+// S3 publishes and fetches tables in a bucket of S3-compatible object storage. Version n of
+// the table name is stored under the key Prefix+name+"/"+n (zero padded to 20 digits, so
+// keys list in version order) with the suffix ".lpm", and the latest publication is
+// recorded as JSON under Prefix+name+"/latest.json". Storage objects carry the version,
+// generation and SHA-256 of the storage as x-amz-meta-lpm-* metadata.
+//
+// Requests are signed with AWS Signature Version 4, which makes the storage service verify
+// the SHA-256 of every uploaded body. Storage larger than PartSize is uploaded in parts.
+type S3 struct {
+	Endpoint string // e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region   string // us-east-1 if empty
+	Bucket   string
+	Prefix   string // prepended to all keys, e.g. "tables/"
+	// VirtualHost addresses the bucket as a subdomain of the endpoint host instead of the
+	// first segment of the path, as AWS requires for newer buckets
+	VirtualHost bool
+
+	// Credentials; requests are sent unsigned if AccessKeyID is empty, e.g. for consumers
+	// of a public bucket
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // for temporary credentials
+
+	// PartSize is the size of the parts of multipart uploads, DefaultPartSize if zero. S3
+	// requires parts of at least 5MiB except for the last one.
+	PartSize int64
+
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+// Error is a request the storage service failed
+type Error struct {
+	StatusCode int    // HTTP status of the response
+	Code       string // S3 error code, e.g. "NoSuchKey" or "AccessDenied"
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3: %s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+func (s *S3) storageKey(name string, version uint64) string {
+	return fmt.Sprintf("%s%s/%020d.lpm", s.Prefix, name, version)
+}
+
+func (s *S3) latestKey(name string) string {
+	return s.Prefix + name + "/latest.json"
+}
+
+// Publish implements Publisher. The storage object is written with If-None-Match: *, so
+// of two publishers racing for the same version one fails with ErrConflict on services
+// supporting conditional writes.
+func (s *S3) Publish(ctx context.Context, name string, storage []byte) (Publication, error) {
+	info, err := lpm.StorageInfo(storage)
+	if err != nil {
+		return Publication{}, err
+	}
+	version := uint64(1)
+	latest, err := s.Latest(ctx, name)
+	switch {
+	case err == nil:
+		version = latest.Version + 1
+	case !errors.Is(err, ErrNotFound):
+		return Publication{}, err
+	}
+
+	sum := sha256.Sum256(storage)
+	p := Publication{
+		Name:       name,
+		Version:    version,
+		Key:        s.storageKey(name, version),
+		Size:       int64(len(storage)),
+		SHA256:     hex.EncodeToString(sum[:]),
+		Generation: info.Header.Generation,
+		Time:       time.Now().UTC().Truncate(time.Second),
+	}
+	header := http.Header{
+		"Content-Type":              {"application/octet-stream"},
+		"If-None-Match":             {"*"},
+		"X-Amz-Meta-Lpm-Version":    {strconv.FormatUint(p.Version, 10)},
+		"X-Amz-Meta-Lpm-Sha256":     {p.SHA256},
+		"X-Amz-Meta-Lpm-Generation": {strconv.FormatUint(p.Generation, 10)},
+	}
+	if err := s.upload(ctx, p.Key, storage, header); err != nil {
+		var failed *Error
+		if errors.As(err, &failed) && (failed.StatusCode == http.StatusPreconditionFailed || failed.Code == "PreconditionFailed") {
+			return Publication{}, fmt.Errorf("%s: %w", p.Key, ErrConflict)
+		}
+		return Publication{}, err
+	}
+
+	record, err := json.Marshal(p)
+	if err != nil {
+		return Publication{}, err
+	}
+	header = http.Header{"Content-Type": {"application/json"}}
+	if _, err := s.do(ctx, http.MethodPut, s.latestKey(name), nil, header, record); err != nil {
+		return Publication{}, err
+	}
+	return p, nil
+}
+
+// Latest implements Fetcher
+func (s *S3) Latest(ctx context.Context, name string) (Publication, error) {
+	var p Publication
+	body, err := s.do(ctx, http.MethodGet, s.latestKey(name), nil, nil, nil)
+	if err != nil {
+		var failed *Error
+		if errors.As(err, &failed) && failed.StatusCode == http.StatusNotFound {
+			return p, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return p, err
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return p, fmt.Errorf("%s: %w", s.latestKey(name), err)
+	}
+	return p, nil
+}
+
+// Fetch implements Fetcher
+func (s *S3) Fetch(ctx context.Context, p Publication) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, p.Key, nil, nil, nil)
+}
+
+// upload puts an object, in parts if it is larger than the part size
+func (s *S3) upload(ctx context.Context, key string, data []byte, header http.Header) error {
+	partSize := s.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	if int64(len(data)) <= partSize {
+		_, err := s.do(ctx, http.MethodPut, key, nil, header, data)
+		return err
+	}
+
+	// Conditional headers apply to completing the upload, the rest to creating it
+	create, complete := http.Header{}, http.Header{}
+	for name, values := range header {
+		if strings.HasPrefix(name, "If-") {
+			complete[name] = values
+		} else {
+			create[name] = values
+		}
+	}
+	body, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, create, nil)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &initiated); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	upload := url.Values{"uploadId": {initiated.UploadID}}
+
+	err = s.uploadParts(ctx, key, data, partSize, initiated.UploadID, complete)
+	if err != nil {
+		// Parts of aborted uploads are freed, otherwise they are billed until a lifecycle
+		// rule removes them
+		s.do(context.WithoutCancel(ctx), http.MethodDelete, key, upload, nil, nil)
+	}
+	return err
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (s *S3) uploadParts(ctx context.Context, key string, data []byte, partSize int64, uploadID string, header http.Header) error {
+	var completion struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}
+	for n := 1; len(data) > 0; n++ {
+		part := data[:min(int64(len(data)), partSize)]
+		data = data[len(part):]
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := s.send(ctx, http.MethodPut, key, query, nil, part)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		completion.Parts = append(completion.Parts, completedPart{PartNumber: n, ETag: resp.Header.Get("ETag")})
+	}
+
+	payload, err := xml.Marshal(completion)
+	if err != nil {
+		return err
+	}
+	body, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, header, payload)
+	if err != nil {
+		return err
+	}
+	// Completion fails with an error document after the status was sent as 200 OK
+	return parseError(http.StatusOK, body)
+}
+
+// do sends a request and returns the body of the response
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	resp, err := s.send(ctx, method, key, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// send sends a signed request, returning the failures of the service as *Error
+func (s *S3) send(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	path := "/" + s.Bucket + "/" + key
+	if s.VirtualHost {
+		endpoint.Host = s.Bucket + "." + endpoint.Host
+		path = "/" + key
+	}
+	target := endpoint.Scheme + "://" + endpoint.Host + escapePath(path)
+	if len(query) > 0 {
+		target += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if s.AccessKeyID != "" {
+		s.sign(req, body, time.Now())
+	}
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		document, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if err := parseError(resp.StatusCode, document); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", method, key, err)
+		}
+		return nil, fmt.Errorf("%s %s: %w", method, key, &Error{StatusCode: resp.StatusCode, Code: http.StatusText(resp.StatusCode), Message: resp.Status})
+	}
+	return resp, nil
+}
+
+// parseError returns the error of an S3 error document, or nil if document is not one
+func parseError(status int, document []byte) error {
+	var failed struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal(document, &failed) != nil || failed.XMLName.Local != "Error" {
+		return nil
+	}
+	return &Error{StatusCode: status, Code: failed.Code, Message: failed.Message}
+}
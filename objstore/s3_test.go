@@ -0,0 +1,313 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// fakeS3 serves a single bucket from memory, with multipart uploads and conditional
+// writes
+type fakeS3 struct {
+	bucket string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	metadata map[string]http.Header
+	uploads  map[string]map[int][]byte
+	parts    int // parts uploaded
+	nextID   int
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{
+		bucket:   bucket,
+		objects:  make(map[string][]byte),
+		metadata: make(map[string]http.Header),
+		uploads:  make(map[string]map[int][]byte),
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, http.StatusText(status))
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		f.fail(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		f.fail(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	put := func(data []byte, header http.Header) bool {
+		if _, exists := f.objects[key]; exists && r.Header.Get("If-None-Match") == "*" {
+			f.fail(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return false
+		}
+		f.objects[key] = data
+		f.metadata[key] = header
+		return true
+	}
+
+	switch {
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[n] = body
+		f.parts++
+		w.Header().Set("ETag", fmt.Sprintf(`"part%d"`, n))
+	case r.Method == http.MethodPut:
+		put(body, r.Header)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		f.metadata["upload:"+id] = r.Header
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		id := query.Get("uploadId")
+		var completion struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &completion); err != nil {
+			f.fail(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var data []byte
+		for i, part := range completion.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"part%d"`, i+1) {
+				f.fail(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			data = append(data, f.uploads[id][part.PartNumber]...)
+		}
+		if put(data, f.metadata["upload:"+id]) {
+			delete(f.uploads, id)
+			fmt.Fprint(w, "<CompleteMultipartUploadResult/>")
+		}
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func table(value string) *lpm.LPM {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), value)
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), value)
+	return m
+}
+
+// TestS3PublishFetch tests publishing versions and loading the latest one
+func TestS3PublishFetch(t *testing.T) {
+	fake, srv := newFakeS3(t, "tables")
+	store := &S3{Endpoint: srv.URL, Bucket: "tables", Prefix: "edge/", AccessKeyID: "key", SecretAccessKey: "secret"}
+	ctx := context.Background()
+
+	if _, err := store.Latest(ctx, "routes"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Latest before publishing error = %v, want ErrNotFound", err)
+	}
+
+	for i, value := range []string{"first", "second"} {
+		m := table(value)
+		p, err := PublishTable(ctx, store, "routes", m)
+		if err != nil {
+			t.Fatalf("PublishTable #%d failed: %v", i, err)
+		}
+		wantKey := fmt.Sprintf("edge/routes/%020d.lpm", i+1)
+		if p.Version != uint64(i+1) || p.Key != wantKey || p.Generation != m.Generation() {
+			t.Errorf("publication #%d = %+v, want version %d, key %s, generation %d", i, p, i+1, wantKey, m.Generation())
+		}
+		meta := fake.metadata[p.Key]
+		if meta.Get("X-Amz-Meta-Lpm-Sha256") != p.SHA256 || meta.Get("X-Amz-Meta-Lpm-Version") != strconv.Itoa(i+1) {
+			t.Errorf("metadata of %s = %v", p.Key, meta)
+		}
+
+		loaded, latest, err := LoadLatest(ctx, store, "routes")
+		if err != nil {
+			t.Fatalf("LoadLatest #%d failed: %v", i, err)
+		}
+		if latest != p {
+			t.Errorf("latest publication = %+v, want %+v", latest, p)
+		}
+		if got, _ := loaded.Lookup(netip.MustParseAddr("2001:db8::1")); got != value {
+			t.Errorf("Lookup(2001:db8::1) = %q, want %q", got, value)
+		}
+	}
+	if fake.parts != 0 {
+		t.Errorf("%d parts uploaded, want single requests for small storage", fake.parts)
+	}
+
+	// The first version stays available
+	if _, ok := fake.objects["edge/routes/00000000000000000001.lpm"]; !ok {
+		t.Error("version 1 was removed")
+	}
+}
+
+// TestS3Multipart tests that storage larger than the part size is uploaded in parts
+func TestS3Multipart(t *testing.T) {
+	fake, srv := newFakeS3(t, "tables")
+	store := &S3{Endpoint: srv.URL, Bucket: "tables", AccessKeyID: "key", SecretAccessKey: "secret", PartSize: 100}
+	ctx := context.Background()
+
+	m := table("multipart")
+	var storage bytes.Buffer
+	if _, err := m.PackTo(&storage); err != nil {
+		t.Fatalf("PackTo failed: %v", err)
+	}
+	p, err := store.Publish(ctx, "routes", storage.Bytes())
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if want := (storage.Len() + 99) / 100; fake.parts != want {
+		t.Errorf("%d parts uploaded, want %d", fake.parts, want)
+	}
+	if len(fake.uploads) != 0 {
+		t.Errorf("%d uploads left incomplete", len(fake.uploads))
+	}
+	if got := fake.metadata[p.Key].Get("X-Amz-Meta-Lpm-Generation"); got != strconv.FormatUint(m.Generation(), 10) {
+		t.Errorf("generation metadata = %q, want %d", got, m.Generation())
+	}
+	loaded, err := Load(ctx, store, p)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, _ := loaded.Lookup(netip.MustParseAddr("10.1.2.3")); got != "multipart" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want %q", got, "multipart")
+	}
+
+	// A publisher that missed the latest version conflicts with the stored one
+	fake.objects["routes/00000000000000000002.lpm"] = []byte("taken")
+	if _, err := store.Publish(ctx, "routes", storage.Bytes()); !errors.Is(err, ErrConflict) {
+		t.Errorf("Publish of a taken version error = %v, want ErrConflict", err)
+	}
+	if len(fake.uploads) != 0 {
+		t.Errorf("%d uploads left after the conflict, want them aborted", len(fake.uploads))
+	}
+}
+
+// TestS3Errors tests that service errors and corrupted objects are reported
+func TestS3Errors(t *testing.T) {
+	fake, srv := newFakeS3(t, "tables")
+	ctx := context.Background()
+
+	anonymous := &S3{Endpoint: srv.URL, Bucket: "tables"}
+	var failed *Error
+	if _, err := PublishTable(ctx, anonymous, "routes", table("x")); !errors.As(err, &failed) || failed.Code != "AccessDenied" {
+		t.Errorf("anonymous PublishTable error = %v, want AccessDenied", err)
+	}
+
+	store := &S3{Endpoint: srv.URL, Bucket: "tables", AccessKeyID: "key", SecretAccessKey: "secret"}
+	if _, err := store.Publish(ctx, "routes", []byte("not storage")); err == nil {
+		t.Error("Publish of invalid storage succeeded")
+	}
+	p, err := PublishTable(ctx, store, "routes", table("x"))
+	if err != nil {
+		t.Fatalf("PublishTable failed: %v", err)
+	}
+	fake.objects[p.Key][len(fake.objects[p.Key])-1] ^= 0xFF
+	if _, err := Load(ctx, store, p); !errors.Is(err, lpm.ErrChecksumMismatch) {
+		t.Errorf("Load of a corrupted object error = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+// TestPoller tests that the poller loads new publications
+func TestPoller(t *testing.T) {
+	_, srv := newFakeS3(t, "tables")
+	store := &S3{Endpoint: srv.URL, Bucket: "tables", AccessKeyID: "key", SecretAccessKey: "secret"}
+	ctx := context.Background()
+
+	if _, err := NewPoller(ctx, store, "routes", time.Hour); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("NewPoller before publishing error = %v, want ErrNotFound", err)
+	}
+	if _, err := PublishTable(ctx, store, "routes", table("first")); err != nil {
+		t.Fatal(err)
+	}
+	poller, err := NewPoller(ctx, store, "routes", time.Hour, lpm.ReadOnly())
+	if err != nil {
+		t.Fatalf("NewPoller failed: %v", err)
+	}
+	defer poller.Close()
+	current := poller.Load()
+
+	// Unchanged publications are not loaded again
+	if err := poller.Poll(ctx); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if poller.Load() != current {
+		t.Error("unchanged publication was loaded again")
+	}
+
+	p, err := PublishTable(ctx, store, "routes", table("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := poller.Poll(ctx); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if got, _ := poller.Lookup(netip.MustParseAddr("10.0.0.1")); got != "second" {
+		t.Errorf("Lookup(10.0.0.1) = %q, want %q", got, "second")
+	}
+	if poller.Publication() != p || poller.Err() != nil {
+		t.Errorf("Publication() = %+v, Err() = %v, want %+v and no error", poller.Publication(), poller.Err(), p)
+	}
+
+	srv.Close()
+	if err := poller.Poll(ctx); err == nil || poller.Err() == nil {
+		t.Error("Poll of a closed server succeeded")
+	}
+	if got, _ := poller.Lookup(netip.MustParseAddr("10.0.0.1")); got != "second" {
+		t.Errorf("Lookup after a failed poll = %q, want the current table", got)
+	}
+}
+
+func TestPollerInterval(t *testing.T) {
+	if _, err := NewPoller(context.Background(), &S3{}, "routes", 0); err == nil {
+		t.Error("NewPoller with a zero interval succeeded")
+	}
+}
@@ -0,0 +1,62 @@
+package lpm
+
+import "log/slog"
+
+// Option configures new tries and how shared storage is loaded and saved
+type Option func(*options)
+
+type options struct {
+	skipChecksum bool
+	fileLock     bool
+	readOnly     bool
+	untrusted    bool
+	ipv6Max64    bool
+	stride16     bool
+	maxMemory    int
+	logger       *slog.Logger
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SkipChecksum disables verification of the storage checksum on load. Verifying reads
+// the whole storage once, which defeats lazy paging of large mmapped files; skip it
+// only for storage whose integrity is guaranteed otherwise.
+func SkipChecksum() Option {
+	return func(o *options) {
+		o.skipChecksum = true
+	}
+}
+
+// WithFileLock makes SaveToFile and LoadFromFile take an flock(2) on a lock file next
+// to the storage file, exclusive while saving and shared while loading. It is only
+// supported on unix platforms; elsewhere they fail with errors.ErrUnsupported.
+func WithFileLock() Option {
+	return func(o *options) {
+		o.fileLock = true
+	}
+}
+
+// ReadOnly makes NewWithSharedStorage return an LPM whose inserts fail with ErrReadOnly,
+// for tables that are meant to be served exactly as they were packed.
+func ReadOnly() Option {
+	return func(o *options) {
+		o.readOnly = true
+	}
+}
+
+// Untrusted makes NewWithSharedStorage run Verify on the loaded trie and fail with
+// ErrCorrupt unless every block reference and value index is in range, for storage
+// received from sources that could craft it. The checksum only detects accidental
+// corruption, while lookups on crafted storage that was not verified may panic.
+// Verification reads every reachable block, like the checksum does.
+func Untrusted() Option {
+	return func(o *options) {
+		o.untrusted = true
+	}
+}
@@ -0,0 +1,267 @@
+package lpm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// packLayout describes the storage PackToSharedStorage and PackTo produce
+type packLayout struct {
+	header        StorageHeader
+	remap         []int    // value index -> packed value index, -1 if dropped
+	blocks        [2][]int // blocks to write per protocol, see packOrder
+	blockRemap    [2][]int // block index -> packed block index, nil if unchanged
+	valueSlotSize int
+	metadata      []byte // encoded metadata section
+	totalSize     int
+}
+
+// packLayout computes the header and section sizes of the packed storage
+func (m *LPM) packLayout() (packLayout, error) {
+	remap, liveCount := m.liveValues(m.valueCount())
+
+	// Find maximum value length and validate
+	maxLen := 0
+	for valueIdx, newIdx := range remap {
+		if newIdx < 0 {
+			continue
+		}
+		val, _ := m.valueBytesByIndex(valueIdx)
+		if len(val) > maxValueLen {
+			return packLayout{}, fmt.Errorf("value at index %d exceeds %d bytes: %d", valueIdx, maxValueLen, len(val))
+		}
+		if len(val) > maxLen {
+			maxLen = len(val)
+		}
+	}
+
+	// Calculate sizes
+	headerSize := headerSize(currentVersion)
+	blockByteSize := blockSize * 4 // 256 uint32s = 1024 bytes per block
+	valueSlotSize := maxLen + 3    // +2 for length prefix, +1 for priority
+
+	var blocks, blockRemap [2][]int
+	for _, proto := range []int{v4LPM, v6LPM} {
+		blocks[proto], blockRemap[proto] = m.packOrder(proto)
+	}
+	v4BlockCount := len(blocks[v4LPM])
+	v6BlockCount := len(blocks[v6LPM])
+	valueCount := liveCount
+
+	// Calculate offsets, failing rather than wrapping around on platforms where
+	// the storage would not fit in the address space
+	v4BlocksOffset := headerSize
+	v6BlocksOffset := sectionEnd(uint64(v4BlocksOffset), uint64(v4BlockCount), uint64(blockByteSize))
+	valuesOffset := sectionEnd(uint64(v6BlocksOffset), uint64(v6BlockCount), uint64(blockByteSize))
+	metadataOffset := sectionEnd(uint64(valuesOffset), uint64(valueCount), uint64(valueSlotSize))
+	metadata := encodeMetadata(m.metadata)
+	totalSize := sectionEnd(uint64(metadataOffset), 1, uint64(len(metadata)))
+	if totalSize == math.MaxInt {
+		return packLayout{}, fmt.Errorf("packed storage exceeds the address space: %d IPv4 blocks, %d IPv6 blocks, %d values of %d bytes",
+			v4BlockCount, v6BlockCount, valueCount, valueSlotSize)
+	}
+
+	return packLayout{
+		header: StorageHeader{
+			Magic:          magicNumber,
+			Version:        currentVersion,
+			ByteOrder:      byteOrderMark,
+			V4BlockCount:   uint64(v4BlockCount),
+			V6BlockCount:   uint64(v6BlockCount),
+			ValueCount:     uint64(valueCount),
+			ValueSlotSize:  uint64(valueSlotSize),
+			V4BlocksOffset: uint64(v4BlocksOffset),
+			V6BlocksOffset: uint64(v6BlocksOffset),
+			ValuesOffset:   uint64(valuesOffset),
+			MetadataOffset: uint64(metadataOffset),
+			MetadataSize:   uint64(len(metadata)),
+			Generation:     m.generation,
+		},
+		remap:         remap,
+		blocks:        blocks,
+		blockRemap:    blockRemap,
+		valueSlotSize: valueSlotSize,
+		metadata:      metadata,
+		totalSize:     totalSize,
+	}, nil
+}
+
+// packOrder returns the blocks of the protocol trie to pack, root first as loaders expect,
+// and the packed index of every block, or nil if the blocks are packed as they are.
+// Shared blocks replaced by copies on insert are unreachable and left out.
+func (t *trie) packOrder(proto int) ([]int, []int) {
+	count := t.blockCount(proto)
+	if t.root[proto] == 0 && t.copied[proto] == 0 {
+		order := make([]int, count)
+		for i := range order {
+			order[i] = i
+		}
+		return order, nil
+	}
+
+	remap := make([]int, count)
+	for i := range remap {
+		remap[i] = -1
+	}
+	var mark func(blockIdx int)
+	mark = func(blockIdx int) {
+		remap[blockIdx] = 0
+		for _, encoded := range t.getBlockRef(proto, blockIdx) {
+			if isBlockRef(encoded) && remap[decodeBlockRef(encoded)] < 0 {
+				mark(decodeBlockRef(encoded))
+			}
+		}
+	}
+	mark(t.root[proto])
+
+	// Keep the relative order of the other blocks
+	order := []int{t.root[proto]}
+	remap[t.root[proto]] = 0
+	for blockIdx := range remap {
+		if remap[blockIdx] == 0 && blockIdx != t.root[proto] {
+			remap[blockIdx] = len(order)
+			order = append(order, blockIdx)
+		}
+	}
+	return order, remap
+}
+
+// EstimatePackedSize returns the exact number of bytes PackToSharedStorage and PackTo
+// would produce, so shared memory segments or files can be sized up front.
+// It returns -1 if packing would fail, e.g. because a value is too long.
+func (m *LPM) EstimatePackedSize() int {
+	layout, err := m.packLayout()
+	if err != nil {
+		return -1
+	}
+	return layout.totalSize
+}
+
+// PackTo streams the same storage PackToSharedStorage produces to w, without
+// materializing it in memory, and returns the number of bytes written.
+// Writes are buffered internally.
+func (m *LPM) PackTo(w io.Writer) (n int64, err error) {
+	defer func(start time.Time) { m.logPack(n, start, err) }(time.Now())
+
+	layout, err := m.packLayout()
+	if err != nil {
+		return 0, err
+	}
+
+	// The checksum precedes the data in the header, so hash the data in a first pass
+	crc := crc32.New(castagnoli)
+	if _, err := crc.Write(appendHeader(nil, &layout.header)); err != nil {
+		return 0, err
+	}
+	if err := m.writeData(crc, layout); err != nil {
+		return 0, err
+	}
+	layout.header.Checksum = crc.Sum32()
+
+	return m.writePacked(w, layout)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (m *LPM) writePacked(w io.Writer, layout packLayout) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriterSize(cw, 64<<10)
+
+	// Write header
+	if _, err := bw.Write(appendHeader(nil, &layout.header)); err != nil {
+		return cw.n, err
+	}
+	if err := m.writeData(bw, layout); err != nil {
+		return cw.n, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+// sliceWriter writes into a fixed slice, failing instead of growing it
+type sliceWriter struct {
+	buf []byte
+	n   int
+}
+
+func (s *sliceWriter) Write(p []byte) (int, error) {
+	if len(p) > len(s.buf)-s.n {
+		return 0, io.ErrShortWrite
+	}
+	s.n += copy(s.buf[s.n:], p)
+	return len(p), nil
+}
+
+// packInto writes the storage described by layout into buf of exactly layout.totalSize
+// bytes and fills in the checksum
+func (m *LPM) packInto(buf []byte, layout packLayout) error {
+	sw := &sliceWriter{buf: buf}
+	if _, err := sw.Write(appendHeader(nil, &layout.header)); err != nil {
+		return err
+	}
+	if err := m.writeData(sw, layout); err != nil {
+		return err
+	}
+	binary.NativeEndian.PutUint32(buf[checksumOffset(currentVersion):], storageChecksum(buf, len(buf), currentVersion))
+	return nil
+}
+
+// writeData writes the block, value and metadata sections that follow the header
+func (m *LPM) writeData(w io.Writer, layout packLayout) error {
+	// Write IPv4 and IPv6 blocks with renumbered value indices
+	var blk LPMBlock
+	blockBytes := make([]byte, 0, blockSize*4)
+	for _, proto := range []int{v4LPM, v6LPM} {
+		for _, i := range layout.blocks[proto] {
+			blk = *m.getBlockRef(proto, i)
+			remapBlock(&blk, layout.remap)
+			if blockRemap := layout.blockRemap[proto]; blockRemap != nil {
+				for slot, encoded := range blk {
+					if isBlockRef(encoded) {
+						blk[slot] = encodeBlockRef(blockRemap[decodeBlockRef(encoded)])
+					}
+				}
+			}
+			if _, err := w.Write(appendBlock(blockBytes[:0], &blk)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Write live values, shared ones first
+	slot := make([]byte, layout.valueSlotSize)
+	for valueIdx, newIdx := range layout.remap {
+		if newIdx < 0 {
+			continue
+		}
+		val, _ := m.valueBytesByIndex(valueIdx)
+		clear(slot)
+		binary.NativeEndian.PutUint16(slot, uint16(len(val)))
+		slot[2] = m.priorityByIndex(valueIdx)
+		copy(slot[3:], val)
+		if _, err := w.Write(slot); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(layout.metadata)
+	return err
+}
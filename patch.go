@@ -0,0 +1,172 @@
+package lpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Patch format, all integers are uvarints unless noted:
+//
+//	magic        uint32 native "LPMP"
+//	base         uint32 native, checksum of the storage the patch applies to
+//	header       the header of the resulting storage
+//	4 sections   IPv4 blocks, IPv6 blocks, value slots, metadata as a single item:
+//	  count      number of changed items
+//	  items      index followed by the item bytes
+//
+// Items of the resulting storage not listed in the patch are copied from the base
+// storage at the same index, so appending blocks or values only ships the new ones.
+
+const patchMagic = 0x4C504D50 // "LPMP"
+
+// ErrPatchMismatch means the patch was produced against different base storage
+var ErrPatchMismatch = errors.New("patch does not apply to this storage")
+
+// patchSection is one of the item arrays of a storage the patch addresses by index
+type patchSection struct {
+	offset, count, size int
+}
+
+func patchSections(header *StorageHeader) [4]patchSection {
+	blockByteSize := blockSize * 4
+	metadataCount := 0
+	if header.MetadataSize > 0 {
+		metadataCount = 1
+	}
+	return [4]patchSection{
+		{int(header.V4BlocksOffset), int(header.V4BlockCount), blockByteSize},
+		{int(header.V6BlocksOffset), int(header.V6BlockCount), blockByteSize},
+		{int(header.ValuesOffset), int(header.ValueCount), int(header.ValueSlotSize)},
+		{int(header.MetadataOffset), metadataCount, int(header.MetadataSize)},
+	}
+}
+
+func (s patchSection) item(storage []byte, idx int) []byte {
+	start := s.offset + idx*s.size
+	return storage[start : start+s.size]
+}
+
+// readPatchStorage validates storage used as a patch base or target
+func readPatchStorage(storage []byte) (StorageHeader, error) {
+	if _, err := NewWithSharedStorage(storage); err != nil {
+		return StorageHeader{}, err
+	}
+	header, foreign, err := readHeader(storage)
+	if err != nil {
+		return StorageHeader{}, err
+	}
+	if header.Version != currentVersion || foreign {
+		return StorageHeader{}, fmt.Errorf("%w: patches need storage in the current format and byte order, see MigrateStorage",
+			ErrVersionMismatch)
+	}
+	return header, nil
+}
+
+// DiffStorage returns a patch that turns the storage old into new, both produced by
+// PackToSharedStorage. Only the blocks and value slots that differ are included,
+// so the patch is small when new was packed from old plus a few inserts.
+func DiffStorage(old, new []byte) ([]byte, error) {
+	oldHeader, err := readPatchStorage(old)
+	if err != nil {
+		return nil, fmt.Errorf("old storage: %w", err)
+	}
+	newHeader, err := readPatchStorage(new)
+	if err != nil {
+		return nil, fmt.Errorf("new storage: %w", err)
+	}
+
+	patch := binary.NativeEndian.AppendUint32(nil, patchMagic)
+	patch = binary.NativeEndian.AppendUint32(patch, oldHeader.Checksum)
+	patch = append(patch, new[:headerSize(currentVersion)]...)
+
+	oldSections := patchSections(&oldHeader)
+	for i, section := range patchSections(&newHeader) {
+		oldSection := oldSections[i]
+
+		var changed []int
+		for idx := range section.count {
+			if idx < oldSection.count && oldSection.size == section.size &&
+				bytes.Equal(oldSection.item(old, idx), section.item(new, idx)) {
+				continue
+			}
+			changed = append(changed, idx)
+		}
+
+		patch = binary.AppendUvarint(patch, uint64(len(changed)))
+		for _, idx := range changed {
+			patch = binary.AppendUvarint(patch, uint64(idx))
+			patch = append(patch, section.item(new, idx)...)
+		}
+	}
+	return patch, nil
+}
+
+// ApplyPatch applies a patch produced by DiffStorage to the storage it was computed
+// against and returns the resulting storage. base is not modified. The result is
+// verified against the checksum of the target storage recorded in the patch.
+func ApplyPatch(base, patch []byte) ([]byte, error) {
+	baseHeader, err := readPatchStorage(base)
+	if err != nil {
+		return nil, fmt.Errorf("base storage: %w", err)
+	}
+
+	size := headerSize(currentVersion)
+	if len(patch) < 8+size || binary.NativeEndian.Uint32(patch) != patchMagic {
+		return nil, fmt.Errorf("malformed patch: missing header")
+	}
+	if checksum := binary.NativeEndian.Uint32(patch[4:]); checksum != baseHeader.Checksum {
+		return nil, fmt.Errorf("%w: patch base checksum 0x%08X, storage checksum 0x%08X", ErrPatchMismatch, checksum, baseHeader.Checksum)
+	}
+	headerBytes := patch[8 : 8+size]
+	patch = patch[8+size:]
+
+	header, foreign, err := readHeader(headerBytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed patch: %w", err)
+	}
+	if header.Version != currentVersion || foreign {
+		return nil, fmt.Errorf("malformed patch: %w", ErrVersionMismatch)
+	}
+	totalSize := storageSize(&header)
+	if totalSize > len(base)+len(patch) {
+		// Every byte of the result comes from either base or patch
+		return nil, fmt.Errorf("malformed patch: result of %d bytes is larger than base and patch", totalSize)
+	}
+
+	result := AlignedBuffer(totalSize)
+	copy(result, headerBytes)
+
+	baseSections := patchSections(&baseHeader)
+	for i, section := range patchSections(&header) {
+		baseSection := baseSections[i]
+		if baseSection.size == section.size {
+			for idx := range min(section.count, baseSection.count) {
+				copy(section.item(result, idx), baseSection.item(base, idx))
+			}
+		}
+
+		count, n := binary.Uvarint(patch)
+		if n <= 0 {
+			return nil, fmt.Errorf("malformed patch: section %d: bad item count", i)
+		}
+		patch = patch[n:]
+		for range count {
+			idx, n := binary.Uvarint(patch)
+			if n <= 0 || idx >= uint64(section.count) || len(patch)-n < section.size {
+				return nil, fmt.Errorf("malformed patch: section %d: bad item", i)
+			}
+			copy(section.item(result, int(idx)), patch[n:n+section.size])
+			patch = patch[n+section.size:]
+		}
+	}
+	if len(patch) != 0 {
+		return nil, fmt.Errorf("malformed patch: %d trailing bytes", len(patch))
+	}
+
+	if sum := storageChecksum(result, len(result), currentVersion); sum != header.Checksum {
+		return nil, fmt.Errorf("%w: patched storage hashes to 0x%08X, want 0x%08X", ErrChecksumMismatch, sum, header.Checksum)
+	}
+	return result, nil
+}
@@ -0,0 +1,236 @@
+package lpm
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// Poptrie is a read-only, bitmap-compressed copy of an LPM for tables dominated by sparse
+// subtrees, where most of the 256 slots of a block repeat the value of their neighbours.
+// Each block becomes a node of two 256-bit bitmaps, one marking the slots that hold child
+// nodes and one marking the slots where the value changes, plus the offsets of its packed
+// children and values, following the Poptrie design (Asai and Ohara, SIGCOMM 2015).
+// A node takes 80 bytes instead of the 1KB of a block, at the cost of a few population
+// counts per lookup.
+//
+// Chains of blocks holding a single child and one value around it, as IPv6 host routes
+// create down to their last byte, are path compressed: the node below the chain stores
+// the address bytes leading to it together with the value of every skipped block, so a
+// /128 takes one node instead of 15 blocks.
+//
+// The flat block layout stays the one packed into shared storage: build a Poptrie from
+// the finished trie in the process that serves it.
+type Poptrie struct {
+	nodes  [2][]poptrieNode
+	leaves [2][]uint32      // encoded value slots, see encodeValue
+	skips  [2][]poptrieSkip // steps of compressed paths
+	values *LPM             // snapshot resolving value indices
+}
+
+// poptrieSkip is a compressed block of a chain: addresses continue down the chain if their
+// byte matches slot and get leaf otherwise
+type poptrieSkip struct {
+	slot uint8
+	leaf uint32
+}
+
+// poptrieNode is a compressed block. Children are stored contiguously from base1 in slot
+// order; leaves, one per run of equal values across the slots without children, from base0.
+type poptrieNode struct {
+	vector  [4]uint64 // slots holding a child node
+	leafvec [4]uint64 // slots starting a run of leaves with a new value
+	base0   uint32    // index of the first leaf
+	base1   uint32    // index of the first child
+	skip    uint32    // index of the first skipped block above this node
+	skipLen uint32    // number of skipped blocks
+}
+
+// Poptrie builds a compressed copy of the trie. Later modifications of m are not reflected.
+func (m *LPM) Poptrie() *Poptrie {
+	snap := m.Snapshot()
+	p := &Poptrie{values: snap}
+	for _, proto := range []int{v4LPM, v6LPM} {
+		p.build(proto, &snap.trie)
+	}
+	return p
+}
+
+// build compresses the blocks of a protocol breadth first, so the children of every
+// node end up next to each other
+func (p *Poptrie) build(proto int, t *trie) {
+	nodes := []poptrieNode{{}}
+	blocks := []int{t.root[proto]}
+	var leaves []uint32
+	for i := 0; i < len(blocks); i++ {
+		node := &nodes[i]
+		node.skip = uint32(len(p.skips[proto]))
+		for {
+			slot, leaf, ok := chainBlock(t.getBlockRef(proto, blocks[i]))
+			if !ok {
+				break
+			}
+			p.skips[proto] = append(p.skips[proto], poptrieSkip{slot: slot, leaf: leaf})
+			node.skipLen++
+			blocks[i] = decodeBlockRef(t.getValue(proto, blocks[i], slot))
+		}
+		node.base0 = uint32(len(leaves))
+		node.base1 = uint32(len(nodes))
+		last, haveLeaf := uint32(0), false
+		for slot, encoded := range t.getBlockRef(proto, blocks[i]) {
+			word, bit := slot>>6, uint64(1)<<(slot&63)
+			if isBlockRef(encoded) {
+				node.vector[word] |= bit
+				blocks = append(blocks, decodeBlockRef(encoded))
+				nodes = append(nodes, poptrieNode{})
+				node = &nodes[i] // nodes may have moved
+				continue
+			}
+			if !haveLeaf || encoded != last {
+				node.leafvec[word] |= bit
+				leaves = append(leaves, encoded)
+				last, haveLeaf = encoded, true
+			}
+		}
+	}
+	p.nodes[proto] = nodes
+	p.leaves[proto] = leaves
+}
+
+// chainBlock reports whether the block holds a single child and the same value in all
+// other slots, returning the slot of the child and the value
+func chainBlock(blk *LPMBlock) (slot uint8, leaf uint32, ok bool) {
+	children := 0
+	haveLeaf := false
+	for i, encoded := range blk {
+		switch {
+		case isBlockRef(encoded):
+			children++
+			slot = uint8(i)
+		case !haveLeaf:
+			leaf, haveLeaf = encoded, true
+		case encoded != leaf:
+			return 0, 0, false
+		}
+	}
+	return slot, leaf, children == 1
+}
+
+// rank returns the number of bits set in v below position pos
+func rank(v *[4]uint64, pos uint8) int {
+	word := pos >> 6
+	n := bits.OnesCount64(v[word] & (uint64(1)<<(pos&63) - 1))
+	for i := range word {
+		n += bits.OnesCount64(v[i])
+	}
+	return n
+}
+
+// lookupKey walks the nodes of the given protocol like trie.lookupKey walks blocks
+func (p *Poptrie) lookupKey(proto int, key []byte) (int, bool) {
+	nodes := p.nodes[proto]
+	node := &nodes[0]
+	for depth := 0; depth < len(key); depth++ {
+		if node.skipLen != 0 {
+			for _, step := range p.skips[proto][node.skip : node.skip+node.skipLen] {
+				if key[depth] != step.slot {
+					return leafValue(step.leaf)
+				}
+				depth++
+			}
+		}
+		inBlockIdx := key[depth]
+		word, bit := inBlockIdx>>6, uint64(1)<<(inBlockIdx&63)
+		if node.vector[word]&bit != 0 {
+			node = &nodes[int(node.base1)+rank(&node.vector, inBlockIdx)]
+			continue
+		}
+		// The leaf of the run the slot belongs to
+		leaf := rank(&node.leafvec, inBlockIdx)
+		if node.leafvec[word]&bit == 0 {
+			leaf--
+		}
+		return leafValue(p.leaves[proto][int(node.base0)+leaf])
+	}
+	return 0, false
+}
+
+// leafValue returns the value index of a leaf slot, if it holds a value
+func leafValue(encoded uint32) (int, bool) {
+	if isInvalid(encoded) {
+		return 0, false
+	}
+	valueIdx, _ := decodeValue(encoded)
+	return valueIdx, valueIdx != tombstoneIdx
+}
+
+// Lookup returns the value of the longest prefix containing addr, see LPM.Lookup
+func (p *Poptrie) Lookup(addr netip.Addr) (string, bool) {
+	valueIdx, ok := p.LookupIndex(addr)
+	if !ok {
+		return "", false
+	}
+	return p.values.getValueByIndex(valueIdx)
+}
+
+// Lookup4 looks up an IPv4 address given as a uint32 in host byte order, see LPM.Lookup4
+func (p *Poptrie) Lookup4(addr uint32) (string, bool) {
+	key := [4]byte{byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr)}
+	valueIdx, ok := p.lookupKey(v4LPM, key[:])
+	if !ok {
+		return "", false
+	}
+	return p.values.getValueByIndex(valueIdx)
+}
+
+// Lookup6 looks up an IPv6 address given as its 16 raw bytes in network byte order
+func (p *Poptrie) Lookup6(addr [16]byte) (string, bool) {
+	valueIdx, ok := p.lookupKey(v6LPM, addr[:])
+	if !ok {
+		return "", false
+	}
+	return p.values.getValueByIndex(valueIdx)
+}
+
+// LookupIndex returns the index of the value of the longest prefix containing addr,
+// the same index LPM.LookupIndex returns on the trie the Poptrie was built from
+func (p *Poptrie) LookupIndex(addr netip.Addr) (int, bool) {
+	if !addr.IsValid() {
+		return 0, false
+	}
+	if addr.Is4() {
+		key := addr.As4()
+		return p.lookupKey(v4LPM, key[:])
+	}
+	key := addr.As16()
+	return p.lookupKey(v6LPM, key[:])
+}
+
+// ValueByIndex returns the value for an index obtained from LookupIndex
+func (p *Poptrie) ValueByIndex(valueIdx int) (string, bool) {
+	return p.values.ValueByIndex(valueIdx)
+}
+
+// Stats returns node counts and the memory taken by nodes, leaves and skipped blocks: IPv4Blocks and
+// IPv6Blocks count nodes, and the values are shared with the trie it was built from.
+func (p *Poptrie) Stats() Stats {
+	valueStats := p.values.Stats()
+	size := func(proto int) int {
+		// 80 bytes per node, 4 per leaf and 8 per skipped block
+		return len(p.nodes[proto])*80 + len(p.leaves[proto])*4 + len(p.skips[proto])*8
+	}
+	v4StorageSize, v6StorageSize := size(v4LPM), size(v6LPM)
+	return Stats{
+		IPv4Blocks:      len(p.nodes[v4LPM]),
+		IPv6Blocks:      len(p.nodes[v6LPM]),
+		Values:          valueStats.Values,
+		IPv4StorageSize: v4StorageSize,
+		IPv6StorageSize: v6StorageSize,
+		ValuesStorage:   valueStats.ValuesStorage,
+		TotalSize:       v4StorageSize + v6StorageSize + valueStats.ValuesStorage,
+
+		IPv4DynamicBlocks: len(p.nodes[v4LPM]),
+		IPv6DynamicBlocks: len(p.nodes[v6LPM]),
+		SharedValues:      valueStats.SharedValues,
+		DynamicValues:     valueStats.DynamicValues,
+	}
+}
@@ -0,0 +1,87 @@
+package lpm
+
+import "net/netip"
+
+// prefetchWidth is the number of lookups LookupPrefetch interleaves
+const prefetchWidth = 8
+
+// prefetchLane is the state of one of the interleaved lookups
+type prefetchLane struct {
+	key     [16]byte
+	keyLen  int
+	proto   int
+	depth   int
+	encoded uint32 // slot reached at depth, a block reference while the lookup goes on
+}
+
+// LookupPrefetch looks up a batch of addresses, storing the value of each in values and
+// whether it matched in found, which must be at least as long as addrs. It returns the
+// same results as calling Lookup for each address, but faster on tables larger than the
+// CPU caches, e.g. large mmapped storage, where lookups spend their time waiting for
+// blocks to be loaded from memory.
+//
+// Go has no prefetch intrinsic, so the batch gets the same effect by walking groups of
+// lookups level by level in lockstep: the slot reads of a level are independent, and the
+// CPU keeps the cache misses of the whole group in flight instead of one at a time.
+func (m *LPM) LookupPrefetch(addrs []netip.Addr, values []string, found []bool) {
+	_ = values[:len(addrs)]
+	_ = found[:len(addrs)]
+
+	var lanes [prefetchWidth]prefetchLane
+	for start := 0; start < len(addrs); start += prefetchWidth {
+		batch := addrs[start:min(start+prefetchWidth, len(addrs))]
+		for i, addr := range batch {
+			m.startLane(&lanes[i], addr)
+		}
+
+		// Walk down one level of every unfinished lookup per round
+		for active := true; active; {
+			active = false
+			for i := range batch {
+				lane := &lanes[i]
+				if !isBlockRef(lane.encoded) || lane.depth >= lane.keyLen {
+					continue
+				}
+				lane.encoded = m.getValue(lane.proto, decodeBlockRef(lane.encoded), lane.key[lane.depth])
+				lane.depth++
+				active = true
+			}
+		}
+
+		for i := range batch {
+			values[start+i], found[start+i] = "", false
+			encoded := lanes[i].encoded
+			if isBlockRef(encoded) || isInvalid(encoded) {
+				continue
+			}
+			if valueIdx, _ := decodeValue(encoded); valueIdx != tombstoneIdx {
+				values[start+i], found[start+i] = m.getValueByIndex(valueIdx)
+			}
+		}
+	}
+}
+
+// startLane prepares a lookup of addr, resolving the root level like lookupKey does
+func (m *LPM) startLane(lane *prefetchLane, addr netip.Addr) {
+	if !addr.IsValid() {
+		// An invalid slot ends the lookup without a match
+		lane.encoded, lane.depth = 0, 0
+		return
+	}
+	lane.key = addr.As16()
+	lane.proto, lane.keyLen = v6LPM, len(lane.key)
+	if addr.Is4() {
+		lane.proto, lane.keyLen = v4LPM, 4
+		copy(lane.key[:4], lane.key[12:])
+	} else if m.ipv6Max64 {
+		lane.keyLen = max64Bytes
+	}
+
+	if lane.proto == v4LPM && m.stride16 != nil {
+		lane.encoded = m.stride16[int(lane.key[0])<<8|int(lane.key[1])]
+		lane.depth = 2
+		return
+	}
+	lane.encoded = m.getValue(lane.proto, m.root[lane.proto], lane.key[0])
+	lane.depth = 1
+}
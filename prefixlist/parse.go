@@ -0,0 +1,374 @@
+package prefixlist
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+// ErrMalformed is returned, wrapped with the line, for prefix list statements that cannot
+// be parsed
+var ErrMalformed = errors.New("prefixlist: malformed statement")
+
+// seqStep is the sequence number routers assign to entries without one, past the
+// highest of the list
+const seqStep = 5
+
+// Entry is an entry of a prefix list
+type Entry struct {
+	Seq    int
+	Deny   bool
+	Prefix netip.Prefix
+	// GE and LE bound the length of the routes the entry matches, 0 if not given: only
+	// the prefix itself matches without them
+	GE, LE int
+}
+
+// List is a named prefix list
+type List struct {
+	Name    string
+	Entries []Entry // sorted by sequence number
+}
+
+// Parse reads the prefix lists of router configuration text:
+//
+//   - "ip prefix-list" and "ipv6 prefix-list" statements of Cisco IOS, FRRouting, Quagga
+//     and Arista, including "no" statements removing entries or lists read before
+//   - "set policy-options prefix-list" statements of Junos
+//   - "define NAME = [ ... ];" prefix set constants of BIRD, with the patterns "+", "-"
+//     and "{a,b}"
+//
+// Everything else is skipped, so whole configurations may be read. IPv4 and IPv6 lists of
+// the same name are returned as one list. Lists are returned in the order they first
+// appear.
+func Parse(r io.Reader) ([]List, error) {
+	p := &parser{byName: make(map[string]*List)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var define strings.Builder // BIRD statement spanning lines
+	defineLine := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if define.Len() > 0 || strings.HasPrefix(strings.TrimSpace(text), "define ") {
+			if define.Len() == 0 {
+				defineLine = line
+			}
+			// BIRD comments run to the end of the line
+			text, _, _ = strings.Cut(text, "#")
+			define.WriteString(text)
+			define.WriteByte(' ')
+			if !strings.Contains(text, ";") {
+				continue
+			}
+			err := p.define(define.String())
+			define.Reset()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", defineLine, err)
+			}
+			continue
+		}
+		if err := p.statement(strings.Fields(text)); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if define.Len() > 0 {
+		return nil, fmt.Errorf("line %d: unterminated define: %w", defineLine, ErrMalformed)
+	}
+
+	var lists []List
+	for _, name := range p.order {
+		if l, ok := p.byName[name]; ok {
+			slices.SortStableFunc(l.Entries, func(a, b Entry) int { return cmp.Compare(a.Seq, b.Seq) })
+			lists = append(lists, *l)
+		}
+	}
+	return lists, nil
+}
+
+type parser struct {
+	byName map[string]*List
+	order  []string // names in order of appearance, including removed lists
+}
+
+// list returns the list of the name, creating it if needed
+func (p *parser) list(name string) *List {
+	l, ok := p.byName[name]
+	if !ok {
+		l = &List{Name: name}
+		p.byName[name] = l
+		p.order = append(p.order, name)
+	}
+	return l
+}
+
+// statement parses a line of Cisco or Junos configuration
+func (p *parser) statement(fields []string) error {
+	switch {
+	case len(fields) >= 5 && fields[0] == "set" && fields[1] == "policy-options" && fields[2] == "prefix-list":
+		prefix, err := parsePrefix(fields[4])
+		if err != nil {
+			return err
+		}
+		l := p.list(fields[3])
+		l.Entries = append(l.Entries, Entry{Seq: nextSeq(l), Prefix: prefix})
+		return nil
+	case len(fields) >= 4 && fields[0] == "delete" && fields[1] == "policy-options" && fields[2] == "prefix-list":
+		delete(p.byName, fields[3])
+		return nil
+	}
+
+	remove := len(fields) > 0 && fields[0] == "no"
+	if remove {
+		fields = fields[1:]
+	}
+	if len(fields) < 3 || (fields[0] != "ip" && fields[0] != "ipv6") || fields[1] != "prefix-list" {
+		return nil
+	}
+	ipv6, name, fields := fields[0] == "ipv6", fields[2], fields[3:]
+	if len(fields) == 0 {
+		if remove {
+			p.removeFamily(name, ipv6)
+		}
+		return nil
+	}
+
+	entry := Entry{Seq: -1}
+	if fields[0] == "seq" {
+		if len(fields) < 2 {
+			return fmt.Errorf("sequence number missing: %w", ErrMalformed)
+		}
+		seq, err := strconv.Atoi(fields[1])
+		if err != nil || seq < 0 {
+			return fmt.Errorf("sequence number %q: %w", fields[1], ErrMalformed)
+		}
+		entry.Seq, fields = seq, fields[2:]
+	}
+	if len(fields) == 0 || fields[0] != "permit" && fields[0] != "deny" {
+		// description, sequence-number and other statements about the list
+		return nil
+	}
+	entry.Deny = fields[0] == "deny"
+	if len(fields) < 2 {
+		return fmt.Errorf("prefix missing: %w", ErrMalformed)
+	}
+	if fields[1] == "any" {
+		fields[1] = "0.0.0.0/0"
+		if ipv6 {
+			fields[1] = "::/0"
+		}
+	}
+	prefix, err := parsePrefix(fields[1])
+	if err != nil {
+		return err
+	}
+	if prefix.Addr().Is6() != ipv6 {
+		return fmt.Errorf("prefix %s in a prefix-list of the other family: %w", prefix, ErrMalformed)
+	}
+	entry.Prefix = prefix
+	for fields = fields[2:]; len(fields) > 0; fields = fields[2:] {
+		if len(fields) < 2 || fields[0] != "ge" && fields[0] != "le" {
+			return fmt.Errorf("unexpected %q: %w", strings.Join(fields, " "), ErrMalformed)
+		}
+		bits, err := strconv.Atoi(fields[1])
+		if err != nil || bits < prefix.Bits() || bits > prefix.Addr().BitLen() {
+			return fmt.Errorf("%s %q for %s: %w", fields[0], fields[1], prefix, ErrMalformed)
+		}
+		if fields[0] == "ge" {
+			entry.GE = bits
+		} else {
+			entry.LE = bits
+		}
+	}
+
+	if remove {
+		p.removeEntry(name, entry)
+		return nil
+	}
+	l := p.list(name)
+	if entry.Seq < 0 {
+		entry.Seq = nextSeq(l)
+	}
+	// An entry with the sequence number of an existing one replaces it
+	l.Entries = slices.DeleteFunc(l.Entries, func(e Entry) bool {
+		return e.Seq == entry.Seq && e.Prefix.Addr().Is6() == ipv6
+	})
+	l.Entries = append(l.Entries, entry)
+	return nil
+}
+
+// nextSeq returns the sequence number of an entry added to the list without one
+func nextSeq(l *List) int {
+	highest := 0
+	for _, e := range l.Entries {
+		highest = max(highest, e.Seq)
+	}
+	return highest + seqStep
+}
+
+// removeFamily removes the entries of a family from a list
+func (p *parser) removeFamily(name string, ipv6 bool) {
+	l, ok := p.byName[name]
+	if !ok {
+		return
+	}
+	l.Entries = slices.DeleteFunc(l.Entries, func(e Entry) bool { return e.Prefix.Addr().Is6() == ipv6 })
+	if len(l.Entries) == 0 {
+		delete(p.byName, name)
+	}
+}
+
+// removeEntry removes the entry with the sequence number of e, or with its action and
+// prefix if it has none
+func (p *parser) removeEntry(name string, e Entry) {
+	l, ok := p.byName[name]
+	if !ok {
+		return
+	}
+	l.Entries = slices.DeleteFunc(l.Entries, func(old Entry) bool {
+		if e.Seq >= 0 {
+			return old.Seq == e.Seq && old.Prefix.Addr().Is6() == e.Prefix.Addr().Is6()
+		}
+		return old.Deny == e.Deny && old.Prefix == e.Prefix && old.GE == e.GE && old.LE == e.LE
+	})
+}
+
+// define parses a BIRD define statement, skipping constants other than prefix sets
+func (p *parser) define(statement string) error {
+	statement = strings.TrimSpace(statement)
+	name, value, ok := strings.Cut(strings.TrimPrefix(statement, "define "), "=")
+	value = strings.TrimSpace(value)
+	if !ok || !strings.HasPrefix(value, "[") {
+		return nil
+	}
+	end := strings.LastIndex(value, "]")
+	if end < 0 || strings.TrimSpace(value[end+1:]) != ";" {
+		return fmt.Errorf("set %q: %w", value, ErrMalformed)
+	}
+
+	var entries []Entry
+	for i, pattern := range splitSet(value[1:end]) {
+		entry, err := parsePattern(pattern)
+		if err != nil {
+			if i == 0 {
+				// A set of numbers or other types
+				return nil
+			}
+			return err
+		}
+		entry.Seq = (i + 1) * seqStep
+		entries = append(entries, entry)
+	}
+	name = strings.TrimSpace(name)
+	delete(p.byName, name)
+	p.list(name).Entries = entries
+	return nil
+}
+
+// splitSet splits the elements of a set at commas outside braces
+func splitSet(set string) []string {
+	var elements []string
+	depth, start := 0, 0
+	for i, c := range set {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				elements = append(elements, strings.TrimSpace(set[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(set[start:]); last != "" {
+		elements = append(elements, last)
+	}
+	return elements
+}
+
+// parsePattern parses a prefix pattern of BIRD: "prefix", "prefix+" (the prefix and longer
+// ones), "prefix-" (the prefix and shorter ones) or "prefix{a,b}"
+func parsePattern(pattern string) (Entry, error) {
+	s := strings.ReplaceAll(pattern, " ", "")
+	var suffix string
+	if i := strings.IndexAny(s, "+-{"); i >= 0 {
+		s, suffix = s[:i], s[i:]
+	}
+	prefix, err := parsePrefix(s)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Prefix: prefix}
+	switch {
+	case suffix == "":
+	case suffix == "+":
+		entry.LE = prefix.Addr().BitLen()
+	case suffix == "-":
+		entry.LE = prefix.Bits()
+	case strings.HasPrefix(suffix, "{") && strings.HasSuffix(suffix, "}"):
+		lo, hi, ok := strings.Cut(suffix[1:len(suffix)-1], ",")
+		ge, errLo := strconv.Atoi(lo)
+		le, errHi := strconv.Atoi(hi)
+		if !ok || errLo != nil || errHi != nil || ge > le || le > prefix.Addr().BitLen() {
+			return Entry{}, fmt.Errorf("pattern %q: %w", pattern, ErrMalformed)
+		}
+		entry.GE, entry.LE = ge, le
+	default:
+		return Entry{}, fmt.Errorf("pattern %q: %w", pattern, ErrMalformed)
+	}
+	return entry, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return prefix.Masked(), nil
+}
+
+// Load returns a trie mapping the addresses of every list to its name, to compare router
+// configurations with the table they were generated from, e.g. by Fingerprint or Dump.
+// Routers apply the first entry matching a route, a trie the longest prefix: the entries of
+// a list are inserted from the last to the first, permits as the name and denies as
+// tombstones, so they agree for the usual lists where specific entries precede broader
+// ones. GE and LE are ignored, as they restrict the routes an entry matches but not the
+// addresses it covers. Lists are built separately, so denies only take addresses out of
+// their own list; where lists overlap, the longer prefix wins, and of equal prefixes the
+// later list. The options are passed to lpm.New.
+func Load(lists []List, opts ...lpm.Option) (*lpm.LPM, error) {
+	m := lpm.New(opts...)
+	for _, l := range lists {
+		list := lpm.New()
+		for _, e := range slices.Backward(l.Entries) {
+			var err error
+			if e.Deny {
+				err = list.InsertTombstone(e.Prefix)
+			} else {
+				err = list.Insert(e.Prefix, l.Name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", l.Name, e.Prefix, err)
+			}
+		}
+		for _, e := range list.EffectiveEntries() {
+			if err := m.Insert(e.Prefix, e.Value); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", l.Name, e.Prefix, err)
+			}
+		}
+	}
+	m.Compact()
+	return m, nil
+}
@@ -0,0 +1,122 @@
+package prefixlist
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+// TestParse tests the statements of every syntax
+func TestParse(t *testing.T) {
+	config := `!
+hostname r1
+ip prefix-list CUST description customer routes
+ip prefix-list CUST seq 10 permit 10.0.0.0/8 le 24
+ip prefix-list CUST seq 5 deny 10.1.0.0/16 ge 20 le 32
+ip prefix-list CUST permit 192.0.2.0/24
+ipv6 prefix-list CUST seq 5 permit 2001:db8::/32
+ip prefix-list GONE seq 5 permit 198.51.100.0/24
+no ip prefix-list GONE
+ip prefix-list CUST seq 30 permit 203.0.113.0/24
+no ip prefix-list CUST seq 30 permit 203.0.113.0/24
+ ip prefix-list DEFAULT seq 5 permit any
+set policy-options prefix-list jun 172.16.0.0/12
+set policy-options prefix-list jun 2001:db8:1::/48
+define ASN = 65000;
+define bird_V4 = [ 10.0.0.0/8+, 10.1.0.0/16{20,24}, # comment
+	100.64.0.0/10- ];
+define ports = [ 1, 2 ];
+`
+	lists, err := Parse(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	p := netip.MustParsePrefix
+	want := []List{
+		{Name: "CUST", Entries: []Entry{
+			{Seq: 5, Deny: true, Prefix: p("10.1.0.0/16"), GE: 20, LE: 32},
+			{Seq: 5, Prefix: p("2001:db8::/32")},
+			{Seq: 10, Prefix: p("10.0.0.0/8"), LE: 24},
+			{Seq: 15, Prefix: p("192.0.2.0/24")},
+		}},
+		{Name: "DEFAULT", Entries: []Entry{{Seq: 5, Prefix: p("0.0.0.0/0")}}},
+		{Name: "jun", Entries: []Entry{{Seq: 5, Prefix: p("172.16.0.0/12")}, {Seq: 10, Prefix: p("2001:db8:1::/48")}}},
+		{Name: "bird_V4", Entries: []Entry{
+			{Seq: 5, Prefix: p("10.0.0.0/8"), LE: 32},
+			{Seq: 10, Prefix: p("10.1.0.0/16"), GE: 20, LE: 24},
+			{Seq: 15, Prefix: p("100.64.0.0/10"), LE: 10},
+		}},
+	}
+	if !reflect.DeepEqual(lists, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", lists, want)
+	}
+
+	for _, bad := range []string{
+		"ip prefix-list X seq five permit 10.0.0.0/8",
+		"ip prefix-list X permit 10.0.0.0/33",
+		"ip prefix-list X permit 10.0.0.0/8 le 4",
+		"ip prefix-list X permit 10.0.0.0/8 eq 16",
+		"ipv6 prefix-list X permit 10.0.0.0/8",
+		"define X = [ 10.0.0.0/8, 10.0.0.0/8{30,20} ];",
+		"define X = [ 10.0.0.0/8,",
+	} {
+		if _, err := Parse(strings.NewReader(bad)); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Parse(%q): %v, want ErrMalformed", bad, err)
+		}
+	}
+}
+
+// TestLoad tests that denies carve addresses out of their own list only
+func TestLoad(t *testing.T) {
+	lists, err := Parse(strings.NewReader(`ip prefix-list A seq 5 deny 10.1.0.0/16
+ip prefix-list A seq 10 permit 10.0.0.0/8 le 32
+ip prefix-list A seq 15 deny 0.0.0.0/0 le 32
+ip prefix-list B seq 5 permit 10.1.2.0/24
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m, err := Load(lists)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for addr, want := range map[string]string{"10.0.0.1": "A", "10.1.1.1": "", "10.1.2.1": "B", "11.0.0.0": ""} {
+		if got, _ := m.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+// TestRoundTrip tests that loading written lists gives back the table
+func TestRoundTrip(t *testing.T) {
+	table := lpm.New()
+	table.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	table.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	table.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24"))
+	table.Insert(netip.MustParsePrefix("2001:db8::/32"), "b")
+	for _, format := range []Format{Cisco, Juniper} {
+		var config bytes.Buffer
+		if err := Write(&config, table, Options{Format: format}); err != nil {
+			t.Fatalf("%v: Write failed: %v", format, err)
+		}
+		lists, err := Parse(&config)
+		if err != nil {
+			t.Fatalf("%v: Parse failed: %v", format, err)
+		}
+		m, err := Load(lists)
+		if err != nil {
+			t.Fatalf("%v: Load failed: %v", format, err)
+		}
+		if m.Fingerprint() != table.Fingerprint() {
+			var got, want strings.Builder
+			m.Dump(&got)
+			table.Dump(&want)
+			t.Errorf("%v: loaded\n%s\nwant\n%s", format, got.String(), want.String())
+		}
+	}
+}
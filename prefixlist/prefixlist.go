@@ -0,0 +1,200 @@
+// Package prefixlist writes LPM tables as router prefix lists, one list per value, so
+// tables curated with this library can be pushed to routers as route filters:
+//
+//	err := prefixlist.Write(os.Stdout, table, prefixlist.Options{Format: prefixlist.Cisco})
+//
+//	ip prefix-list customer_a seq 5 permit 192.0.2.0/24
+//	ip prefix-list customer_b seq 5 permit 198.51.100.0/24
+//
+// The lists hold the effective entries of the table (see lpm.LPM.EffectiveEntries), so an
+// address falls into the list of the value a lookup returns for it, and tombstoned
+// addresses are in no list.
+//
+// Parse reads prefix lists back from router configurations, and Load turns them into a
+// table mapping addresses to list names, to check what routers are configured with
+// against the table.
+package prefixlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+// Format is a router configuration syntax
+type Format int
+
+const (
+	// Cisco is the "ip prefix-list" and "ipv6 prefix-list" syntax of Cisco IOS, also
+	// understood by Arista EOS, FRRouting and Quagga
+	Cisco Format = iota + 1
+	// Juniper is the set syntax of Junos policy-options prefix lists
+	Juniper
+	// BIRD defines prefix set constants for BIRD 2 filters, NAME_V4 and NAME_V6 per list,
+	// as a set holds prefixes of one family only
+	BIRD
+)
+
+func (f Format) String() string {
+	switch f {
+	case Cisco:
+		return "cisco"
+	case Juniper:
+		return "juniper"
+	case BIRD:
+		return "bird"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Options configures Write
+type Options struct {
+	Format Format
+	// Name returns the name of the list of a value. If nil, DefaultName is used.
+	Name func(value string) string
+	// OrLonger makes the lists match more specific routes within the prefixes as well:
+	// "le 32" and "le 128" for Cisco, "+" for BIRD. Junos prefix lists always match exactly;
+	// apply them with "prefix-list-filter NAME orlonger" in the policy instead.
+	OrLonger bool
+	// Replace deletes the lists before they are written, so entries removed from the table
+	// are removed from the router as well: "no ip prefix-list" for Cisco, "delete
+	// policy-options prefix-list" for Juniper. BIRD constants are always replaced whole.
+	Replace bool
+}
+
+// DefaultName turns a value into a name every format accepts: characters other than ASCII
+// letters, digits and underscores become underscores, and names not starting with a
+// letter get the prefix "PL_"
+func DefaultName(value string) string {
+	name := []byte(value)
+	for i, c := range name {
+		if !isLetter(c) && (c < '0' || c > '9') && c != '_' {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || !isLetter(name[0]) {
+		return "PL_" + string(name)
+	}
+	return string(name)
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// list is the prefixes of a value
+type list struct {
+	name     string
+	prefixes []netip.Prefix // in address order, IPv4 first
+}
+
+// Write writes a prefix list for every value of m, ordered by name. Values whose names
+// collide are rejected, as their lists would merge on the router.
+func Write(w io.Writer, m *lpm.LPM, opts Options) error {
+	name := opts.Name
+	if name == nil {
+		name = DefaultName
+	}
+	byValue := make(map[string]*list)
+	values := make(map[string]string) // value by list name
+	var lists []*list
+	for _, e := range m.EffectiveEntries() {
+		l, ok := byValue[e.Value]
+		if !ok {
+			l = &list{name: name(e.Value)}
+			if other, taken := values[l.name]; taken {
+				return fmt.Errorf("prefixlist: values %q and %q are both named %q", other, e.Value, l.name)
+			}
+			values[l.name] = e.Value
+			byValue[e.Value] = l
+			lists = append(lists, l)
+		}
+		l.prefixes = append(l.prefixes, e.Prefix)
+	}
+	slices.SortFunc(lists, func(a, b *list) int { return strings.Compare(a.name, b.name) })
+
+	bw := bufio.NewWriter(w)
+	for _, l := range lists {
+		switch opts.Format {
+		case Cisco:
+			writeCisco(bw, l, opts)
+		case Juniper:
+			writeJuniper(bw, l, opts)
+		case BIRD:
+			writeBIRD(bw, l, opts)
+		default:
+			return fmt.Errorf("prefixlist: unknown format %v", opts.Format)
+		}
+	}
+	return bw.Flush()
+}
+
+// split returns the IPv4 and IPv6 prefixes of a list
+func (l *list) split() (v4, v6 []netip.Prefix) {
+	i := 0
+	for i < len(l.prefixes) && l.prefixes[i].Addr().Is4() {
+		i++
+	}
+	return l.prefixes[:i], l.prefixes[i:]
+}
+
+func writeCisco(w *bufio.Writer, l *list, opts Options) {
+	v4, v6 := l.split()
+	for _, family := range []struct {
+		command  string
+		prefixes []netip.Prefix
+		maxBits  int
+	}{{"ip", v4, 32}, {"ipv6", v6, 128}} {
+		if len(family.prefixes) == 0 {
+			continue
+		}
+		if opts.Replace {
+			fmt.Fprintf(w, "no %s prefix-list %s\n", family.command, l.name)
+		}
+		for i, prefix := range family.prefixes {
+			fmt.Fprintf(w, "%s prefix-list %s seq %d permit %s", family.command, l.name, (i+1)*seqStep, prefix)
+			if opts.OrLonger && prefix.Bits() < family.maxBits {
+				fmt.Fprintf(w, " le %d", family.maxBits)
+			}
+			w.WriteByte('\n')
+		}
+	}
+}
+
+func writeJuniper(w *bufio.Writer, l *list, opts Options) {
+	if opts.Replace {
+		fmt.Fprintf(w, "delete policy-options prefix-list %s\n", l.name)
+	}
+	for _, prefix := range l.prefixes {
+		fmt.Fprintf(w, "set policy-options prefix-list %s %s\n", l.name, prefix)
+	}
+}
+
+func writeBIRD(w *bufio.Writer, l *list, opts Options) {
+	v4, v6 := l.split()
+	for _, family := range []struct {
+		suffix   string
+		prefixes []netip.Prefix
+	}{{"_V4", v4}, {"_V6", v6}} {
+		if len(family.prefixes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "define %s%s = [\n", l.name, family.suffix)
+		for i, prefix := range family.prefixes {
+			fmt.Fprintf(w, "\t%s", prefix)
+			if opts.OrLonger {
+				w.WriteByte('+')
+			}
+			if i < len(family.prefixes)-1 {
+				w.WriteByte(',')
+			}
+			w.WriteByte('\n')
+		}
+		fmt.Fprintln(w, "];")
+	}
+}
@@ -0,0 +1,100 @@
+package prefixlist
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+func testTable() *lpm.LPM {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "customer-a")
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "7 seas")
+	m.InsertTombstone(netip.MustParsePrefix("10.128.0.0/9"))
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "customer-a")
+	return m
+}
+
+// TestWrite tests the syntax of every format
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{Format: Cisco}, `ip prefix-list PL_7_seas seq 5 permit 10.1.0.0/16
+ip prefix-list customer_a seq 5 permit 10.0.0.0/16
+ip prefix-list customer_a seq 10 permit 10.2.0.0/15
+ip prefix-list customer_a seq 15 permit 10.4.0.0/14
+ip prefix-list customer_a seq 20 permit 10.8.0.0/13
+ip prefix-list customer_a seq 25 permit 10.16.0.0/12
+ip prefix-list customer_a seq 30 permit 10.32.0.0/11
+ip prefix-list customer_a seq 35 permit 10.64.0.0/10
+ipv6 prefix-list customer_a seq 5 permit 2001:db8::/32
+`},
+		{Options{Format: Cisco, OrLonger: true, Replace: true, Name: func(v string) string { return strings.ToUpper(DefaultName(v)) }}, `no ip prefix-list CUSTOMER_A
+ip prefix-list CUSTOMER_A seq 5 permit 10.0.0.0/16 le 32
+ip prefix-list CUSTOMER_A seq 10 permit 10.2.0.0/15 le 32
+ip prefix-list CUSTOMER_A seq 15 permit 10.4.0.0/14 le 32
+ip prefix-list CUSTOMER_A seq 20 permit 10.8.0.0/13 le 32
+ip prefix-list CUSTOMER_A seq 25 permit 10.16.0.0/12 le 32
+ip prefix-list CUSTOMER_A seq 30 permit 10.32.0.0/11 le 32
+ip prefix-list CUSTOMER_A seq 35 permit 10.64.0.0/10 le 32
+no ipv6 prefix-list CUSTOMER_A
+ipv6 prefix-list CUSTOMER_A seq 5 permit 2001:db8::/32 le 128
+no ip prefix-list PL_7_SEAS
+ip prefix-list PL_7_SEAS seq 5 permit 10.1.0.0/16 le 32
+`},
+		{Options{Format: Juniper, Replace: true}, `delete policy-options prefix-list PL_7_seas
+set policy-options prefix-list PL_7_seas 10.1.0.0/16
+delete policy-options prefix-list customer_a
+set policy-options prefix-list customer_a 10.0.0.0/16
+set policy-options prefix-list customer_a 10.2.0.0/15
+set policy-options prefix-list customer_a 10.4.0.0/14
+set policy-options prefix-list customer_a 10.8.0.0/13
+set policy-options prefix-list customer_a 10.16.0.0/12
+set policy-options prefix-list customer_a 10.32.0.0/11
+set policy-options prefix-list customer_a 10.64.0.0/10
+set policy-options prefix-list customer_a 2001:db8::/32
+`},
+		{Options{Format: BIRD, OrLonger: true}, `define PL_7_seas_V4 = [
+	10.1.0.0/16+
+];
+define customer_a_V4 = [
+	10.0.0.0/16+,
+	10.2.0.0/15+,
+	10.4.0.0/14+,
+	10.8.0.0/13+,
+	10.16.0.0/12+,
+	10.32.0.0/11+,
+	10.64.0.0/10+
+];
+define customer_a_V6 = [
+	2001:db8::/32+
+];
+`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := Write(&b, testTable(), tt.opts); err != nil {
+			t.Fatalf("%v: Write failed: %v", tt.opts.Format, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%v: Write() =\n%s\nwant\n%s", tt.opts.Format, b.String(), tt.want)
+		}
+	}
+}
+
+// TestWriteErrors tests colliding names and unknown formats
+func TestWriteErrors(t *testing.T) {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a-b")
+	m.Insert(netip.MustParsePrefix("11.0.0.0/8"), "a.b")
+	if err := Write(&strings.Builder{}, m, Options{Format: Cisco}); err == nil {
+		t.Error("Write with colliding names succeeded")
+	}
+	if err := Write(&strings.Builder{}, testTable(), Options{}); err == nil {
+		t.Error("Write without a format succeeded")
+	}
+}
@@ -0,0 +1,201 @@
+package lpm
+
+import "net/netip"
+
+// prefixBucketBits is the number of leading address bits, per protocol, by which the
+// prefix set groups its prefixes
+var prefixBucketBits = [2]int{v4LPM: 16, v6LPM: 32}
+
+// prefixKey identifies a masked prefix. Unlike netip.Prefix it holds no pointer, so maps
+// of them are not scanned by the garbage collector.
+type prefixKey struct {
+	addr [16]byte // IPv4 addresses in IPv4-mapped IPv6 form
+	bits uint8
+	is6  bool
+}
+
+func newPrefixKey(net netip.Prefix) prefixKey {
+	return prefixKey{addr: net.Addr().As16(), bits: uint8(net.Bits()), is6: net.Addr().Is6()}
+}
+
+func (k prefixKey) prefix() netip.Prefix {
+	addr := netip.AddrFrom16(k.addr)
+	if !k.is6 {
+		addr = addr.Unmap()
+	}
+	return netip.PrefixFrom(addr, int(k.bits))
+}
+
+// prefixSet holds every prefix inserted into a trie with its value index, shadowed or not.
+// The slots of the trie only keep the prefixes that decide some lookup, which is not
+// enough to undo an insert: Delete has to uncover the broader and more specific prefixes
+// the deleted one hid. The prefixes are grouped into buckets by their first
+// prefixBucketBits address bits, so finding those inside a prefix only scans its bucket,
+// or the buckets themselves for prefixes shorter than that.
+//
+// The zero value is not known yet: tries loaded from storage fill it on the first
+// modification, see trie.loadPrefixes.
+type prefixSet struct {
+	buckets map[prefixKey]map[prefixKey]uint32
+	count   int
+}
+
+func newPrefixSet() prefixSet {
+	return prefixSet{buckets: make(map[prefixKey]map[prefixKey]uint32)}
+}
+
+// bucketBits returns the prefix bucket bits of the protocol of addr
+func bucketBits(addr netip.Addr) int {
+	if addr.Is6() {
+		return prefixBucketBits[v6LPM]
+	}
+	return prefixBucketBits[v4LPM]
+}
+
+// bucketKey returns the key of the bucket holding the masked prefix: the prefix itself if
+// it is not longer than the bucket bits, otherwise its first bucket bits
+func bucketKey(net netip.Prefix) prefixKey {
+	if bits := bucketBits(net.Addr()); net.Bits() > bits {
+		net = netip.PrefixFrom(net.Addr(), bits).Masked()
+	}
+	return newPrefixKey(net)
+}
+
+// get returns the value index of the masked prefix
+func (s *prefixSet) get(net netip.Prefix) (int, bool) {
+	valueIdx, ok := s.buckets[bucketKey(net)][newPrefixKey(net)]
+	return int(valueIdx), ok
+}
+
+// set stores the value index of the masked prefix, returning the one it replaced, if any
+func (s *prefixSet) set(net netip.Prefix, valueIdx int) (int, bool) {
+	bucket := bucketKey(net)
+	entries := s.buckets[bucket]
+	if entries == nil {
+		entries = make(map[prefixKey]uint32)
+		s.buckets[bucket] = entries
+	}
+	key := newPrefixKey(net)
+	old, ok := entries[key]
+	entries[key] = uint32(valueIdx)
+	if !ok {
+		s.count++
+	}
+	return int(old), ok
+}
+
+// remove drops the masked prefix, reporting whether it was present
+func (s *prefixSet) remove(net netip.Prefix) bool {
+	bucket := bucketKey(net)
+	entries := s.buckets[bucket]
+	key := newPrefixKey(net)
+	if _, ok := entries[key]; !ok {
+		return false
+	}
+	delete(entries, key)
+	if len(entries) == 0 {
+		delete(s.buckets, bucket)
+	}
+	s.count--
+	return true
+}
+
+// covering calls fn for the prefixes strictly broader than the masked prefix that contain
+// it, shortest first
+func (s *prefixSet) covering(net netip.Prefix, fn func(prefix netip.Prefix, valueIdx int)) {
+	for bits := range net.Bits() {
+		broader := netip.PrefixFrom(net.Addr(), bits).Masked()
+		if valueIdx, ok := s.get(broader); ok {
+			fn(broader, valueIdx)
+		}
+	}
+}
+
+// within calls fn for the prefixes contained in the masked prefix, the prefix itself included
+func (s *prefixSet) within(net netip.Prefix, fn func(prefix netip.Prefix, valueIdx int)) {
+	contains := func(key prefixKey) bool {
+		return key.is6 == net.Addr().Is6() && int(key.bits) >= net.Bits() && net.Contains(key.prefix().Addr())
+	}
+	visit := func(entries map[prefixKey]uint32) {
+		for key, valueIdx := range entries {
+			if contains(key) {
+				fn(key.prefix(), int(valueIdx))
+			}
+		}
+	}
+
+	if net.Bits() >= bucketBits(net.Addr()) {
+		// Every prefix inside shares the bucket of the prefix
+		visit(s.buckets[bucketKey(net)])
+		return
+	}
+	// Buckets inside the prefix hold nothing but prefixes inside it
+	for bucket, entries := range s.buckets {
+		if contains(bucket) {
+			visit(entries)
+		}
+	}
+}
+
+// all calls fn for every prefix in the set
+func (s *prefixSet) all(fn func(prefix netip.Prefix, valueIdx int)) {
+	for _, entries := range s.buckets {
+		for key, valueIdx := range entries {
+			fn(key.prefix(), int(valueIdx))
+		}
+	}
+}
+
+// remap rewrites the value indices of the prefixes according to remap, see CompactValues
+func (s *prefixSet) remap(remap []int) {
+	for _, entries := range s.buckets {
+		for key, valueIdx := range entries {
+			if valueIdx != tombstoneIdx {
+				entries[key] = uint32(remap[valueIdx])
+			}
+		}
+	}
+}
+
+// size estimates the memory used by the set, see Stats
+func (s *prefixSet) size() int {
+	return s.count*prefixEntrySize + len(s.buckets)*prefixBucketSize
+}
+
+// loadPrefixes fills the prefix set of a trie loaded from storage before its first
+// modification. Storage keeps only the prefixes that decide some lookup, so those are
+// all the set learns, see Entries.
+func (t *trie) loadPrefixes() {
+	if t.prefixes.buckets != nil {
+		return
+	}
+	t.prefixes = newPrefixSet()
+	for _, proto := range []int{v4LPM, v6LPM} {
+		for prefix, valueIdx := range t.entries(proto) {
+			t.prefixes.set(prefix, valueIdx)
+		}
+	}
+}
+
+// rebuild recomputes the slots of the masked prefix from the prefix set: they fall back to
+// the broader prefix covering it with the highest priority, then the longest one, and the
+// prefixes inside it are propagated again on top of that
+func (t *trie) rebuild(net netip.Prefix, priorityOf func(int) uint8) {
+	var fallback uint32
+	var fallbackPriority uint8
+	t.prefixes.covering(net, func(prefix netip.Prefix, valueIdx int) {
+		// Broader prefixes come shortest first, so the longest of equal priority wins
+		if priority := priorityOf(valueIdx); isInvalid(fallback) || priority >= fallbackPriority {
+			fallback, fallbackPriority = encodeValue(valueIdx, prefix.Bits()), priority
+		}
+	})
+
+	proto, blockIdx, startIdx, endIdx := t.descend(net)
+	t.applyRange(proto, blockIdx, startIdx, endIdx, func(uint32) uint32 { return fallback })
+	t.prefixes.within(net, func(prefix netip.Prefix, valueIdx int) {
+		proto, blockIdx, startIdx, endIdx := t.descend(prefix)
+		t.propagateValue(proto, blockIdx, valueIdx, prefix.Bits(), priorityOf(valueIdx), priorityOf, startIdx, endIdx)
+	})
+	t.collapse(net)
+	t.refreshPrefix16(net)
+}
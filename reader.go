@@ -0,0 +1,113 @@
+package lpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// readChunkSize is the size of the buffer NewFromReader starts reading storage into; larger
+// storage is read into a buffer doubling in size
+const readChunkSize = 64 << 20
+
+// storageSize returns the number of bytes the storage described by the header occupies
+func storageSize(header *StorageHeader) int {
+	blockByteSize := blockSize * 4
+	size := storedHeaderSize(header)
+	if header.V4BlockCount > 0 {
+		size = max(size, sectionEnd(header.V4BlocksOffset, header.V4BlockCount, uint64(blockByteSize)))
+	}
+	if header.V6BlockCount > 0 {
+		size = max(size, sectionEnd(header.V6BlocksOffset, header.V6BlockCount, uint64(blockByteSize)))
+	}
+	if header.ValueCount > 0 && header.ValueSlotSize > 0 {
+		size = max(size, sectionEnd(header.ValuesOffset, header.ValueCount, header.ValueSlotSize))
+	}
+	if header.MetadataSize > 0 {
+		size = max(size, sectionEnd(header.MetadataOffset, 1, header.MetadataSize))
+	}
+	return size
+}
+
+// NewFromReader loads storage produced by PackToSharedStorage or PackTo from r.
+// The header is read first to size a single buffer that the LPM then owns, so there
+// is no intermediate copy for storage of up to 64MB; larger storage is read into a buffer
+// that doubles as data arrives, so a corrupt header cannot allocate more than r holds. Reading stops at the end of the storage, so r may
+// contain further data, except after storage written by PackCompressed, which is
+// decompressed on the fly and read to the end of r.
+func NewFromReader(r io.Reader, opts ...Option) (*LPM, error) {
+	o := newOptions(opts)
+	lpm, err := newFromReader(r, o)
+	logLoad(o.log(), lpm, err)
+	return lpm, err
+}
+
+func newFromReader(r io.Reader, o options) (*LPM, error) {
+	// The preamble tells the version and so the size of the rest of the header
+	headerBytes := make([]byte, preambleSize, headerSize(currentVersion))
+	if n, err := io.ReadFull(r, headerBytes); err != nil {
+		return nil, readError("header", preambleSize, n, err)
+	}
+	if isCompressed(headerBytes) {
+		dec, err := zstd.NewReader(io.MultiReader(bytes.NewReader(headerBytes), r), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		return newFromReader(dec, o)
+	}
+	version, _, err := decodePreamble(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	headerBytes = headerBytes[:headerSize(version)]
+	if n, err := io.ReadFull(r, headerBytes[preambleSize:]); err != nil {
+		return nil, readError("header", len(headerBytes), preambleSize+n, err)
+	}
+	header, _, err := readHeader(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	size := storageSize(&header)
+	if size == math.MaxInt {
+		return nil, fmt.Errorf("%w: header declares sections past the addressable size", ErrCorrupt)
+	}
+
+	// The header is not verified yet, so the buffer only grows as data arrives: a header
+	// declaring more than the stream holds fails with ErrTruncated instead of allocating it
+	storage := AlignedBuffer(min(size, readChunkSize))
+	copy(storage, headerBytes)
+	read := len(headerBytes)
+	for {
+		n, err := io.ReadFull(r, storage[read:])
+		read += n
+		if err != nil {
+			return nil, readError("storage", size, read, err)
+		}
+		if read == size {
+			break
+		}
+		grown := AlignedBuffer(min(size, 2*len(storage)))
+		copy(grown, storage)
+		storage = grown
+	}
+	return newWithSharedStorage(storage, o)
+}
+
+// readError reports a failed read of section, with the stream ending early as ErrTruncated
+func readError(section string, need, got int, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &ErrTruncated{Section: section, Need: need, Got: got}
+	}
+	return fmt.Errorf("reading storage %s: %w", section, err)
+}
+
+// NewFromReaderAt loads storage located at the start of r, e.g. an *os.File, see NewFromReader.
+func NewFromReaderAt(r io.ReaderAt, opts ...Option) (*LPM, error) {
+	return NewFromReader(io.NewSectionReader(r, 0, 1<<63-1), opts...)
+}
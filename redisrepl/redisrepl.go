@@ -0,0 +1,209 @@
+// Package redisrepl replicates a table to any number of instances through a Redis stream.
+// The primary modifies its table through a Replicator, which appends every insert,
+// tombstone and delete to the stream; every instance keeps a replica with a Subscriber,
+// which applies the changes as they arrive:
+//
+//	replicator, err := redisrepl.NewReplicator(ctx, client, "routes", table, 0)
+//	err = replicator.Insert(ctx, prefix, "customer-a")
+//
+// and on the instances:
+//
+//	subscriber, err := redisrepl.NewSubscriber(ctx, client, "routes")
+//	value, found := subscriber.Lookup(addr)
+//
+// Along with the stream, the replicator keeps the current entries in the hash
+// "<stream>:state" and the number of changes in "<stream>:seq", updating all three
+// atomically. Subscribers start from the state and then follow the stream, so the stream
+// is trimmed to a bounded length; a subscriber that falls behind further than that notices
+// the gap in the change numbers and starts over from the state. With Redis Cluster, name
+// the stream with a hash tag, e.g. "{routes}", so the keys share a slot.
+package redisrepl
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sakateka/lpm"
+)
+
+// DefaultMaxLen is the approximate length the stream is trimmed to if NewReplicator is
+// given none
+const DefaultMaxLen = 10000
+
+// Operations of stream entries
+const (
+	opInsert    = "insert"
+	opTombstone = "tombstone"
+	opDelete    = "delete"
+)
+
+// change is an operation as appended to the stream
+type change struct {
+	op    string
+	cidr  string
+	entry string // encoded entry, empty for deletes
+}
+
+// encodeEntry encodes an entry for the state hash and the stream: "tombstone" for
+// tombstones, otherwise the priority and the value separated by a space
+func encodeEntry(e lpm.PrefixValue) string {
+	if e.Tombstone {
+		return opTombstone
+	}
+	return strconv.Itoa(int(e.Priority)) + " " + e.Value
+}
+
+// decodeEntry decodes an entry of the prefix encoded by encodeEntry
+func decodeEntry(cidr, entry string) (lpm.PrefixValue, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return lpm.PrefixValue{}, err
+	}
+	if entry == opTombstone {
+		return lpm.PrefixValue{Prefix: prefix, Tombstone: true}, nil
+	}
+	priority, value, ok := strings.Cut(entry, " ")
+	p, err := strconv.ParseUint(priority, 10, 8)
+	if !ok || err != nil {
+		return lpm.PrefixValue{}, fmt.Errorf("%s: malformed entry %q", cidr, entry)
+	}
+	return lpm.PrefixValue{Prefix: prefix, Value: value, Priority: uint8(p)}, nil
+}
+
+// publishScript appends changes to the stream KEYS[1] as entries with the IDs 0-<seq>, so
+// subscribers can tell whether they missed any, and applies them to the state hash KEYS[3].
+// The last seq is kept in KEYS[2]. ARGV holds the length to trim the stream to, followed by
+// the op, prefix and entry of every change.
+var publishScript = redis.NewScript(`
+local seq = tonumber(redis.call('GET', KEYS[2]) or '0')
+for i = 2, #ARGV, 3 do
+	seq = seq + 1
+	local op, cidr, entry = ARGV[i], ARGV[i + 1], ARGV[i + 2]
+	redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[1], '0-' .. seq, 'op', op, 'cidr', cidr, 'entry', entry)
+	if op == 'delete' then
+		redis.call('HDEL', KEYS[3], cidr)
+	else
+		redis.call('HSET', KEYS[3], cidr, entry)
+	end
+end
+redis.call('SET', KEYS[2], seq)
+return seq
+`)
+
+// publishBatch is the number of changes appended by one script call
+const publishBatch = 1000
+
+func keys(stream string) []string {
+	return []string{stream, stream + ":seq", stream + ":state"}
+}
+
+// Replicator modifies a table and appends the changes to a Redis stream. Modify the table
+// only through the Replicator; it may be used for lookups. Like inserts into the table,
+// the Replicator is not safe for concurrent use.
+type Replicator struct {
+	client redis.UniversalClient
+	stream string
+	maxLen int64
+	lpm    *lpm.LPM
+}
+
+// NewReplicator returns a replicator of m through the stream, trimmed to about maxLen
+// entries (DefaultMaxLen if zero). It appends the differences between m and the state of
+// the stream right away, so replicas converge to m even if the stream held another table.
+func NewReplicator(ctx context.Context, client redis.UniversalClient, stream string, m *lpm.LPM, maxLen int64) (*Replicator, error) {
+	if maxLen <= 0 {
+		maxLen = DefaultMaxLen
+	}
+	r := &Replicator{client: client, stream: stream, maxLen: maxLen, lpm: m}
+	if err := r.Sync(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LPM returns the table. It must only be modified through the Replicator.
+func (r *Replicator) LPM() *lpm.LPM {
+	return r.lpm
+}
+
+// Insert stores value for the prefix and replicates it, see lpm.LPM.Insert
+func (r *Replicator) Insert(ctx context.Context, net netip.Prefix, value string) error {
+	return r.InsertWithPriority(ctx, net, value, 0)
+}
+
+// InsertWithPriority stores value for the prefix with a priority and replicates it, see
+// lpm.LPM.InsertWithPriority
+func (r *Replicator) InsertWithPriority(ctx context.Context, net netip.Prefix, value string, priority uint8) error {
+	if err := r.lpm.InsertWithPriority(net, value, priority); err != nil {
+		return err
+	}
+	e := lpm.PrefixValue{Prefix: net.Masked(), Value: value, Priority: priority}
+	return r.publish(ctx, []change{{opInsert, e.Prefix.String(), encodeEntry(e)}})
+}
+
+// InsertTombstone carves the prefix out of broader prefixes and replicates it, see
+// lpm.LPM.InsertTombstone
+func (r *Replicator) InsertTombstone(ctx context.Context, net netip.Prefix) error {
+	if err := r.lpm.InsertTombstone(net); err != nil {
+		return err
+	}
+	return r.publish(ctx, []change{{opTombstone, net.Masked().String(), opTombstone}})
+}
+
+// Delete removes the prefix and replicates it, see lpm.LPM.Delete. Deleting an absent
+// prefix is not replicated.
+func (r *Replicator) Delete(ctx context.Context, net netip.Prefix) (bool, error) {
+	deleted, err := r.lpm.Delete(net)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return true, r.publish(ctx, []change{{opDelete, net.Masked().String(), ""}})
+}
+
+// Sync appends the changes turning the state of the stream into the entries of the table
+// (see lpm.LPM.Entries), e.g. to catch up after a change failed to be appended
+func (r *Replicator) Sync(ctx context.Context) error {
+	state, err := r.client.HGetAll(ctx, r.stream+":state").Result()
+	if err != nil {
+		return fmt.Errorf("redisrepl: %w", err)
+	}
+	var changes []change
+	for _, e := range r.lpm.Entries() {
+		cidr, entry := e.Prefix.String(), encodeEntry(e)
+		stored, ok := state[cidr]
+		delete(state, cidr)
+		if ok && stored == entry {
+			continue
+		}
+		op := opInsert
+		if e.Tombstone {
+			op = opTombstone
+		}
+		changes = append(changes, change{op, cidr, entry})
+	}
+	for cidr := range state {
+		changes = append(changes, change{opDelete, cidr, ""})
+	}
+	return r.publish(ctx, changes)
+}
+
+// publish appends changes to the stream in batches
+func (r *Replicator) publish(ctx context.Context, changes []change) error {
+	for len(changes) > 0 {
+		batch := changes[:min(len(changes), publishBatch)]
+		changes = changes[len(batch):]
+		args := make([]any, 0, 1+3*len(batch))
+		args = append(args, r.maxLen)
+		for _, c := range batch {
+			args = append(args, c.op, c.cidr, c.entry)
+		}
+		if err := publishScript.Run(ctx, r.client, keys(r.stream), args...).Err(); err != nil {
+			return fmt.Errorf("redisrepl: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package redisrepl
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sakateka/lpm"
+)
+
+func newClient(t *testing.T) redis.UniversalClient {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// waitSeq waits until the subscriber applied the changes up to seq
+func waitSeq(t *testing.T, s *Subscriber, seq uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Seq() < seq {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscriber at change %d, want %d (error %v)", s.Seq(), seq, s.Err())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func primary() *lpm.LPM {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten")
+	m.InsertWithPriority(netip.MustParsePrefix("10.1.0.0/16"), "override", 3)
+	m.InsertTombstone(netip.MustParsePrefix("10.2.0.0/16"))
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	return m
+}
+
+// TestReplicate tests that subscribers follow the changes made through the replicator
+func TestReplicate(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	replicator, err := NewReplicator(ctx, client, "routes", primary(), 0)
+	if err != nil {
+		t.Fatalf("NewReplicator failed: %v", err)
+	}
+	subscriber, err := NewSubscriber(ctx, client, "routes")
+	if err != nil {
+		t.Fatalf("NewSubscriber failed: %v", err)
+	}
+	defer subscriber.Close()
+	if subscriber.Load().Fingerprint() != replicator.LPM().Fingerprint() {
+		t.Error("replica differs from the primary after loading the state")
+	}
+
+	if err := replicator.Insert(ctx, netip.MustParsePrefix("192.0.2.0/24"), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := replicator.InsertTombstone(ctx, netip.MustParsePrefix("10.3.0.0/16")); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := replicator.Delete(ctx, netip.MustParsePrefix("2001:db8::/32")); !deleted || err != nil {
+		t.Fatalf("Delete = %v, %v", deleted, err)
+	}
+	if deleted, _ := replicator.Delete(ctx, netip.MustParsePrefix("2001:db8::/32")); deleted {
+		t.Error("Delete of an absent prefix reported a deletion")
+	}
+	waitSeq(t, subscriber, 7)
+
+	if subscriber.Load().Fingerprint() != replicator.LPM().Fingerprint() {
+		t.Error("replica differs from the primary")
+	}
+	for addr, want := range map[string]string{"192.0.2.1": "test", "10.1.0.1": "override", "10.3.0.1": "", "2001:db8::1": ""} {
+		if got, _ := subscriber.Lookup(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", addr, got, want)
+		}
+	}
+	if err := subscriber.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+
+	// Invalid prefixes are rejected before being replicated
+	if err := replicator.Insert(ctx, netip.Prefix{}, "x"); err == nil {
+		t.Error("Insert of an invalid prefix succeeded")
+	}
+	if seq, _ := client.Get(ctx, "routes:seq").Uint64(); seq != 7 {
+		t.Errorf("seq = %d after a rejected insert, want 7", seq)
+	}
+}
+
+// TestReplicatorSync tests that a new replicator turns the state into its own table
+func TestReplicatorSync(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	if _, err := NewReplicator(ctx, client, "routes", primary(), 0); err != nil {
+		t.Fatal(err)
+	}
+	subscriber, err := NewSubscriber(ctx, client, "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten")
+	m.Insert(netip.MustParsePrefix("172.16.0.0/12"), "private")
+	replicator, err := NewReplicator(ctx, client, "routes", m, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 initial changes, then 3 deletes and 1 insert
+	waitSeq(t, subscriber, 8)
+	if subscriber.Load().Fingerprint() != replicator.LPM().Fingerprint() {
+		t.Error("replica differs from the new primary")
+	}
+	if n, _ := client.HLen(ctx, "routes:state").Result(); n != 2 {
+		t.Errorf("state holds %d entries, want 2", n)
+	}
+}
+
+// TestGap tests that a subscriber missing trimmed entries starts over from the state
+func TestGap(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	replicator, err := NewReplicator(ctx, client, "routes", lpm.New(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriber, err := NewSubscriber(ctx, client, "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriber.Close()
+
+	for i, prefix := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16"} {
+		if err := replicator.Insert(ctx, netip.MustParsePrefix(prefix), string(rune('a'+i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.XTrimMaxLen(ctx, "routes", 1)
+	if err := subscriber.read(ctx); !errors.Is(err, errGap) {
+		t.Fatalf("read error = %v, want errGap", err)
+	}
+	if err := subscriber.reload(ctx); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if subscriber.Seq() != 3 || subscriber.Load().Fingerprint() != replicator.LPM().Fingerprint() {
+		t.Errorf("replica at change %d differs from the primary after reloading", subscriber.Seq())
+	}
+}
+
+func TestEntryEncoding(t *testing.T) {
+	for _, e := range []lpm.PrefixValue{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: "with space", Priority: 7},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Value: ""},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Tombstone: true},
+	} {
+		got, err := decodeEntry(e.Prefix.String(), encodeEntry(e))
+		if err != nil || got != e {
+			t.Errorf("decodeEntry(encodeEntry(%+v)) = %+v, %v", e, got, err)
+		}
+	}
+	for _, entry := range []string{"", "x", "256 v", "-1 v"} {
+		if _, err := decodeEntry("10.0.0.0/8", entry); err == nil {
+			t.Errorf("decodeEntry(%q) succeeded", entry)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package redisrepl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sakateka/lpm"
+)
+
+// readBlock is how long a read of the stream waits for new entries. Blocked reads are not
+// interrupted when their context is canceled, so it bounds how long Close takes.
+const readBlock = time.Second
+
+// readCount is the number of entries applied per read, and so per published snapshot
+const readCount = 1000
+
+// errGap is returned when entries were trimmed from the stream before being read
+var errGap = errors.New("missed stream entries")
+
+// Subscriber keeps a replica of a table replicated through a stream. Replicas are
+// published as snapshots after every batch of changes read, so lookups never block.
+type Subscriber struct {
+	client redis.UniversalClient
+	stream string
+	opts   []lpm.Option
+
+	table lpm.Atomic
+
+	mu      sync.Mutex // guards replica, seq and err
+	replica *lpm.LPM   // working copy the changes are applied to
+	seq     uint64     // last change applied
+	err     error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSubscriber loads the state of the stream and follows it until Close is called or ctx
+// is done. It fails if the state cannot be loaded. The options are passed to lpm.Build.
+func NewSubscriber(ctx context.Context, client redis.UniversalClient, stream string, opts ...lpm.Option) (*Subscriber, error) {
+	s := &Subscriber{
+		client: client,
+		stream: stream,
+		opts:   opts,
+		done:   make(chan struct{}),
+	}
+	if err := s.reload(ctx); err != nil {
+		return nil, err
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.run(ctx)
+	return s, nil
+}
+
+// Load returns the current replica
+func (s *Subscriber) Load() *lpm.LPM {
+	return s.table.Load()
+}
+
+// Lookup looks addr up in the current replica
+func (s *Subscriber) Lookup(addr netip.Addr) (string, bool) {
+	return s.table.Lookup(addr)
+}
+
+// Seq returns the number of changes the current replica reflects
+func (s *Subscriber) Seq() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+// Err returns the error of the last read of the stream, or nil if it succeeded. Failed
+// reads are retried.
+func (s *Subscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops following the stream, waiting up to a second for a pending read. The
+// current replica stays loaded.
+func (s *Subscriber) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// reload replaces the replica with one built from the state
+func (s *Subscriber) reload(ctx context.Context) error {
+	var seq *redis.StringCmd
+	var state *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		seq = pipe.Get(ctx, s.stream+":seq")
+		state = pipe.HGetAll(ctx, s.stream+":state")
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("redisrepl: %w", err)
+	}
+	var last uint64
+	if seq.Err() == nil {
+		if last, err = seq.Uint64(); err != nil {
+			return fmt.Errorf("redisrepl: %s:seq: %w", s.stream, err)
+		}
+	}
+	entries := make([]lpm.PrefixValue, 0, len(state.Val()))
+	for cidr, entry := range state.Val() {
+		e, err := decodeEntry(cidr, entry)
+		if err != nil {
+			return fmt.Errorf("redisrepl: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	replica, err := lpm.Build(entries, s.opts...)
+	if err != nil {
+		return fmt.Errorf("redisrepl: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replica, s.seq = replica, last
+	s.table.Store(replica.Snapshot())
+	return nil
+}
+
+// read applies the next entries of the stream, waiting for them up to readBlock
+func (s *Subscriber) read(ctx context.Context) error {
+	s.mu.Lock()
+	from := "0-" + strconv.FormatUint(s.seq, 10)
+	s.mu.Unlock()
+	streams, err := s.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{s.stream, from},
+		Count:   readCount,
+		Block:   readBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("redisrepl: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq := s.seq
+	defer func() {
+		if s.seq != seq {
+			s.table.Store(s.replica.Snapshot())
+		}
+	}()
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			if err := s.apply(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply applies an entry of the stream to the replica
+func (s *Subscriber) apply(msg redis.XMessage) error {
+	seq, err := strconv.ParseUint(strings.TrimPrefix(msg.ID, "0-"), 10, 64)
+	if err != nil || !strings.HasPrefix(msg.ID, "0-") {
+		return fmt.Errorf("redisrepl: unexpected entry ID %s", msg.ID)
+	}
+	if seq != s.seq+1 {
+		return fmt.Errorf("redisrepl: %w: read change %d after %d", errGap, seq, s.seq)
+	}
+	op, _ := msg.Values["op"].(string)
+	cidr, _ := msg.Values["cidr"].(string)
+	entry, _ := msg.Values["entry"].(string)
+	if op == opDelete {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("redisrepl: entry %s: %w", msg.ID, err)
+		}
+		_, err = s.replica.Delete(prefix)
+		if err != nil {
+			return fmt.Errorf("redisrepl: entry %s: %w", msg.ID, err)
+		}
+		s.seq = seq
+		return nil
+	}
+	e, err := decodeEntry(cidr, entry)
+	if err != nil {
+		return fmt.Errorf("redisrepl: entry %s: %w", msg.ID, err)
+	}
+	if e.Tombstone {
+		err = s.replica.InsertTombstone(e.Prefix)
+	} else {
+		err = s.replica.InsertWithPriority(e.Prefix, e.Value, e.Priority)
+	}
+	if err != nil {
+		return fmt.Errorf("redisrepl: entry %s: %w", msg.ID, err)
+	}
+	s.seq = seq
+	return nil
+}
+
+func (s *Subscriber) run(ctx context.Context) {
+	defer close(s.done)
+	for ctx.Err() == nil {
+		err := s.read(ctx)
+		if errors.Is(err, errGap) {
+			// The changes missed are only in the state now
+			err = s.reload(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package reference implements the longest prefix match API of package lpm with a linear
+// scan over a sorted list of prefixes. It is slow, but simple enough to be obviously
+// correct, so it serves as the oracle of differential tests: apply the same operations
+// to an lpm.LPM and a reference.LPM and compare their lookups.
+//
+//	table, oracle := lpm.New(), reference.New()
+//	for _, op := range ops {
+//	    table.Insert(op.prefix, op.value)
+//	    oracle.Insert(op.prefix, op.value)
+//	}
+//	got, _ := table.Lookup(addr)
+//	want, _ := oracle.Lookup(addr)
+//
+// It follows the matching rules of lpm.LPM: the covering prefix with the highest priority
+// wins, then the longest one, and a matching tombstone reports no value. Deleting a prefix
+// uncovers anything it shadowed, including prefixes hidden by a higher priority, and
+// inserting a prefix again replaces it, even with a lower priority.
+package reference
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// Table is the part of the lpm.LPM API implemented by LPM
+type Table interface {
+	Insert(net netip.Prefix, value string) error
+	InsertWithPriority(net netip.Prefix, value string, priority uint8) error
+	InsertTombstone(net netip.Prefix) error
+	Delete(net netip.Prefix) (bool, error)
+	Lookup(addr netip.Addr) (string, bool)
+}
+
+var _ Table = (*LPM)(nil)
+
+// entry is a prefix with what it maps to
+type entry struct {
+	prefix    netip.Prefix
+	value     string
+	priority  uint8
+	tombstone bool
+}
+
+// LPM is a longest prefix match table kept as a list of prefixes sorted by decreasing
+// priority and length, so the first prefix containing an address is its match.
+// The zero value is an empty table.
+type LPM struct {
+	entries []entry
+}
+
+// New returns an empty table
+func New() *LPM {
+	return &LPM{}
+}
+
+// Insert stores value for the prefix, replacing what the prefix mapped to before
+func (m *LPM) Insert(net netip.Prefix, value string) error {
+	return m.InsertWithPriority(net, value, 0)
+}
+
+// InsertWithPriority stores value for the prefix with a priority that takes precedence
+// over prefix length, see lpm.LPM.InsertWithPriority
+func (m *LPM) InsertWithPriority(net netip.Prefix, value string, priority uint8) error {
+	return m.insert(net, entry{value: value, priority: priority})
+}
+
+// InsertTombstone makes the prefix match with no value, see lpm.LPM.InsertTombstone
+func (m *LPM) InsertTombstone(net netip.Prefix) error {
+	return m.insert(net, entry{tombstone: true})
+}
+
+func (m *LPM) insert(net netip.Prefix, e entry) error {
+	if !net.IsValid() {
+		return fmt.Errorf("invalid prefix %v", net)
+	}
+	e.prefix = net.Masked()
+	m.remove(e.prefix)
+	i, _ := slices.BinarySearchFunc(m.entries, e, compare)
+	m.entries = slices.Insert(m.entries, i, e)
+	return nil
+}
+
+// Delete removes the prefix and reports whether it was present
+func (m *LPM) Delete(net netip.Prefix) (bool, error) {
+	if !net.IsValid() {
+		return false, fmt.Errorf("invalid prefix %v", net)
+	}
+	return m.remove(net.Masked()), nil
+}
+
+func (m *LPM) remove(net netip.Prefix) bool {
+	i := slices.IndexFunc(m.entries, func(e entry) bool { return e.prefix == net })
+	if i < 0 {
+		return false
+	}
+	m.entries = slices.Delete(m.entries, i, i+1)
+	return true
+}
+
+// Lookup returns the value of the highest priority, then longest prefix containing addr
+func (m *LPM) Lookup(addr netip.Addr) (string, bool) {
+	for _, e := range m.entries {
+		if e.prefix.Contains(addr) {
+			return e.value, !e.tombstone
+		}
+	}
+	return "", false
+}
+
+// Len returns the number of prefixes in the table, tombstones included
+func (m *LPM) Len() int {
+	return len(m.entries)
+}
+
+// compare orders entries by decreasing priority and prefix length; the order among
+// prefixes of equal priority and length does not matter, as they never overlap
+func compare(a, b entry) int {
+	if c := cmp.Compare(b.priority, a.priority); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(b.prefix.Bits(), a.prefix.Bits()); c != 0 {
+		return c
+	}
+	return a.prefix.Addr().Compare(b.prefix.Addr())
+}
@@ -0,0 +1,77 @@
+package reference
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestLPM tests matching by priority, length and tombstones
+func TestLPM(t *testing.T) {
+	m := New()
+	inserts := []struct {
+		cidr     string
+		value    string
+		priority uint8
+	}{
+		{"10.0.0.0/8", "private", 0},
+		{"10.1.0.0/16", "dc1", 0},
+		{"10.1.1.7/24", "rack", 0}, // masked
+		{"10.2.0.0/16", "pinned", 2},
+		{"10.2.3.0/24", "hidden", 1},
+		{"2001:db8::/32", "doc", 0},
+		{"0.0.0.0/0", "default", 0},
+	}
+	for _, ins := range inserts {
+		if err := m.InsertWithPriority(netip.MustParsePrefix(ins.cidr), ins.value, ins.priority); err != nil {
+			t.Fatalf("InsertWithPriority(%s): %v", ins.cidr, err)
+		}
+	}
+	if err := m.InsertTombstone(netip.MustParsePrefix("10.1.2.0/24")); err != nil {
+		t.Fatalf("InsertTombstone: %v", err)
+	}
+	if err := m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc2"); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := m.Insert(netip.Prefix{}, "invalid"); err == nil {
+		t.Errorf("Insert of an invalid prefix succeeded")
+	}
+
+	check := func(want map[string]string) {
+		t.Helper()
+		for addr, value := range want {
+			got, found := m.Lookup(netip.MustParseAddr(addr))
+			if found != (value != "") || got != value {
+				t.Errorf("Lookup(%s) = %q (found=%v), want %q", addr, got, found, value)
+			}
+		}
+	}
+	check(map[string]string{
+		"10.0.0.1":    "private",
+		"10.1.0.1":    "dc2",
+		"10.1.1.1":    "rack",
+		"10.1.2.1":    "",
+		"10.2.3.1":    "pinned",
+		"2001:db8::1": "doc",
+		"2001:db9::1": "",
+		"192.0.2.1":   "default",
+	})
+	if m.Len() != 8 {
+		t.Errorf("Len() = %d, want 8", m.Len())
+	}
+
+	// Deleting uncovers everything the prefix shadowed
+	for _, cidr := range []string{"10.2.0.0/16", "10.1.2.0/24", "10.1.0.0/16"} {
+		if ok, err := m.Delete(netip.MustParsePrefix(cidr)); !ok || err != nil {
+			t.Errorf("Delete(%s) = %v, %v", cidr, ok, err)
+		}
+	}
+	if ok, _ := m.Delete(netip.MustParsePrefix("10.1.0.0/16")); ok {
+		t.Errorf("second Delete reported a deleted prefix")
+	}
+	check(map[string]string{
+		"10.1.2.1": "private",
+		"10.1.1.1": "rack",
+		"10.2.3.1": "hidden",
+		"10.2.4.1": "private",
+	})
+}
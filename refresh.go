@@ -0,0 +1,264 @@
+package lpm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Source fetches the complete set of prefixes of a table, e.g. from a feed
+type Source func(ctx context.Context) ([]PrefixValue, error)
+
+// RefreshOption configures a Refresher
+type RefreshOption func(*refreshOptions)
+
+type refreshOptions struct {
+	jitter     time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+	hook       func(RefreshStats)
+	logger     *slog.Logger
+}
+
+// RefreshJitter spreads refreshes by a random offset of up to d in either direction, so
+// many instances polling the same feed do not hit it at once
+func RefreshJitter(d time.Duration) RefreshOption {
+	return func(o *refreshOptions) {
+		o.jitter = d
+	}
+}
+
+// RefreshBackoff sets how long to wait after a failed refresh: min after the first
+// failure, doubling with every further one up to max. It defaults to one second up to
+// the refresh interval.
+func RefreshBackoff(min, max time.Duration) RefreshOption {
+	return func(o *refreshOptions) {
+		o.minBackoff, o.maxBackoff = min, max
+	}
+}
+
+// RefreshTimeout bounds each call of the source, by default to the refresh interval
+func RefreshTimeout(d time.Duration) RefreshOption {
+	return func(o *refreshOptions) {
+		o.timeout = d
+	}
+}
+
+// RefreshHook calls fn with the updated statistics after every refresh attempt, e.g. to
+// export them as metrics. fn runs on the refreshing goroutine and must not block.
+func RefreshHook(fn func(RefreshStats)) RefreshOption {
+	return func(o *refreshOptions) {
+		o.hook = fn
+	}
+}
+
+// RefreshLogger logs every refresh to logger: swaps and unchanged tables at Info and Debug
+// level, failures at Warn level. Tables are built with WithLogger(logger).
+func RefreshLogger(logger *slog.Logger) RefreshOption {
+	return func(o *refreshOptions) {
+		o.logger = logger
+	}
+}
+
+// RefreshStats reports the outcome of the refreshes of a Refresher
+type RefreshStats struct {
+	Attempts            uint64        // calls of the source
+	Failures            uint64        // attempts that failed to fetch or build a table
+	Swaps               uint64        // tables swapped in, unchanged tables are not swapped
+	ConsecutiveFailures int           // failures since the last success
+	LastAttempt         time.Time     // start of the last attempt
+	LastSuccess         time.Time     // start of the last successful attempt
+	LastDuration        time.Duration // duration of the last attempt
+	LastError           error         // error of the last attempt, nil if it succeeded
+	Prefixes            int           // prefixes in the current table
+}
+
+// Refresher keeps a table built from a Source up to date: every interval it fetches the
+// prefixes, builds a fresh trie off to the side and swaps it in atomically, so lookups
+// never wait for a refresh and never see a partially built table.
+//
+//	refresher, err := lpm.NewRefresher(ctx, fetchFeed, 5*time.Minute, lpm.RefreshJitter(30*time.Second))
+//	if err != nil {
+//	    return err
+//	}
+//	defer refresher.Close()
+//
+//	value, found := refresher.Lookup(addr)
+//
+// A failed refresh keeps the previous table and is retried with exponential backoff.
+// Tables are built read-only; a table whose Fingerprint matches the current one is
+// dropped instead of swapped in.
+type Refresher struct {
+	source   Source
+	interval time.Duration
+	opts     refreshOptions
+
+	table Atomic
+
+	mu    sync.Mutex // serializes refreshes and guards stats
+	stats RefreshStats
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRefresher builds the first table from source and starts refreshing it every interval
+// until Close is called or ctx is done. It fails if the first refresh fails.
+func NewRefresher(ctx context.Context, source Source, interval time.Duration, opts ...RefreshOption) (*Refresher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid refresh interval: %v", interval)
+	}
+	o := refreshOptions{minBackoff: time.Second, maxBackoff: interval, timeout: interval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	r := &Refresher{
+		source:   source,
+		interval: interval,
+		opts:     o,
+		done:     make(chan struct{}),
+	}
+	if err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.run(ctx)
+	return r, nil
+}
+
+// Load returns the current table. It is safe to call from any goroutine; the table must
+// not be kept longer than needed, as it is replaced on refresh.
+func (r *Refresher) Load() *LPM {
+	return r.table.Load()
+}
+
+// Lookup returns the value of the longest prefix containing addr in the current table
+func (r *Refresher) Lookup(addr netip.Addr) (string, bool) {
+	return r.table.Lookup(addr)
+}
+
+// Stats returns the refresh statistics
+func (r *Refresher) Stats() RefreshStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Refresh fetches and swaps in a new table right away, outside the schedule
+func (r *Refresher) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	err := r.refresh(ctx)
+
+	r.stats.Attempts++
+	r.stats.LastAttempt = start
+	r.stats.LastDuration = time.Since(start)
+	r.stats.LastError = err
+	if err != nil {
+		r.stats.Failures++
+		r.stats.ConsecutiveFailures++
+		r.log().Warn("refresh failed", "error", err, "consecutive_failures", r.stats.ConsecutiveFailures,
+			"duration", r.stats.LastDuration)
+	} else {
+		r.stats.ConsecutiveFailures = 0
+		r.stats.LastSuccess = start
+	}
+	if r.opts.hook != nil {
+		r.opts.hook(r.stats)
+	}
+	return err
+}
+
+func (r *Refresher) refresh(ctx context.Context) error {
+	if r.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.timeout)
+		defer cancel()
+	}
+	entries, err := r.source(ctx)
+	if err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	table, err := buildTable(entries, WithLogger(r.opts.logger))
+	if err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	r.stats.Prefixes = len(entries)
+
+	if current := r.table.Load(); current != nil && current.Fingerprint() == table.Fingerprint() {
+		r.log().Debug("refresh found the table unchanged", "prefixes", len(entries))
+		return nil
+	}
+	r.table.Store(table)
+	r.stats.Swaps++
+	r.log().Info("refreshed table", "prefixes", len(entries), "generation", table.Generation())
+	return nil
+}
+
+// log returns the logger given with RefreshLogger, or one that discards everything
+func (r *Refresher) log() *slog.Logger {
+	if r.opts.logger == nil {
+		return discardLogger
+	}
+	return r.opts.logger
+}
+
+// buildTable builds a new read-only trie from entries
+func buildTable(entries []PrefixValue, opts ...Option) (*LPM, error) {
+	m, err := Build(entries, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.readOnly = true
+	return m, nil
+}
+
+// nextDelay returns how long to wait before the next refresh after the given number of
+// consecutive failures
+func (r *Refresher) nextDelay(failures int) time.Duration {
+	delay := r.interval
+	if failures > 0 {
+		delay = r.opts.minBackoff
+		for i := 1; i < failures && delay < r.opts.maxBackoff; i++ {
+			delay *= 2
+		}
+		delay = min(delay, r.opts.maxBackoff)
+	}
+	if r.opts.jitter > 0 {
+		delay += time.Duration(rand.Int64N(int64(2*r.opts.jitter)+1)) - r.opts.jitter
+	}
+	return max(delay, 0)
+}
+
+func (r *Refresher) run(ctx context.Context) {
+	defer close(r.done)
+
+	timer := time.NewTimer(r.nextDelay(0))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			_ = r.Refresh(ctx)
+			timer.Reset(r.nextDelay(r.Stats().ConsecutiveFailures))
+		}
+	}
+}
+
+// Close stops refreshing and waits for a running refresh to finish.
+// The current table stays available through Load.
+func (r *Refresher) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
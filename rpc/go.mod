@@ -0,0 +1,17 @@
+module github.com/sakateka/lpm/rpc
+
+go 1.25.1
+
+require (
+	github.com/sakateka/lpm v0.1.0
+	google.golang.org/grpc v1.75.1
+	google.golang.org/protobuf v1.36.8
+)
+
+require (
+	github.com/klauspost/compress v1.18.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+)
@@ -0,0 +1,234 @@
+// Package rpc serves an LPM table over gRPC, so the trie can back a central classification
+// service that clients query and update remotely. The service is defined in lpm.proto:
+//
+//	table := lpm.NewAtomic(initial)
+//	srv := grpc.NewServer()
+//	rpc.RegisterLPMServer(srv, rpc.NewServer(table))
+//	_ = srv.Serve(listener)
+//
+// Lookups read whatever table the lpm.Atomic holds, without locks. Inserts and deletes
+// are applied to a private copy of that table and published to the lpm.Atomic as a
+// snapshot after every change, so tables stored in it by others, e.g. an lpm.Refresher
+// or a reload of a storage file, are picked up as the base of later changes.
+package rpc
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sakateka/lpm"
+)
+
+// snapshotChunkSize is the size of the chunks Snapshot streams the storage in, well below
+// the default 4MB message limit of gRPC clients
+const snapshotChunkSize = 256 << 10
+
+// Server implements LPMServer over an lpm.Atomic
+type Server struct {
+	UnimplementedLPMServer
+
+	table *lpm.Atomic
+
+	mu        sync.Mutex // serializes changes
+	writer    *lpm.LPM   // private copy changes are applied to
+	published *lpm.LPM   // snapshot of writer last stored in table
+}
+
+// NewServer returns a server answering from table. table may be empty, in which case
+// lookups find nothing until the first insert.
+func NewServer(table *lpm.Atomic) *Server {
+	return &Server{table: table}
+}
+
+// Lookup implements LPMServer
+func (s *Server) Lookup(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
+	addr, err := netip.ParseAddr(req.GetIp())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	table := s.table.Load()
+	if table == nil {
+		return &LookupResponse{}, nil
+	}
+	value, found := table.Lookup(addr)
+	return &LookupResponse{Value: value, Found: found, Generation: table.Generation()}, nil
+}
+
+// BatchLookup implements LPMServer
+func (s *Server) BatchLookup(ctx context.Context, req *BatchLookupRequest) (*BatchLookupResponse, error) {
+	addrs := make([]netip.Addr, len(req.GetIps()))
+	for i, ip := range req.GetIps() {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "address %d: %v", i, err)
+		}
+		addrs[i] = addr
+	}
+
+	values := make([]string, len(addrs))
+	found := make([]bool, len(addrs))
+	resp := &BatchLookupResponse{Results: make([]*LookupResult, len(addrs))}
+	if table := s.table.Load(); table != nil {
+		table.LookupPrefetch(addrs, values, found)
+		resp.Generation = table.Generation()
+	}
+	for i := range addrs {
+		resp.Results[i] = &LookupResult{Value: values[i], Found: found[i]}
+	}
+	return resp, nil
+}
+
+// Insert implements LPMServer
+func (s *Server) Insert(ctx context.Context, req *InsertRequest) (*InsertResponse, error) {
+	prefix, err := netip.ParsePrefix(req.GetPrefix())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.GetPriority() > 255 {
+		return nil, status.Errorf(codes.InvalidArgument, "priority %d out of range", req.GetPriority())
+	}
+
+	generation, err := s.update(func(m *lpm.LPM) error {
+		if req.GetTombstone() {
+			return m.InsertTombstone(prefix)
+		}
+		return m.InsertWithPriority(prefix, req.GetValue(), uint8(req.GetPriority()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &InsertResponse{Generation: generation}, nil
+}
+
+// Delete implements LPMServer
+func (s *Server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	prefix, err := netip.ParsePrefix(req.GetPrefix())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var deleted bool
+	generation, err := s.update(func(m *lpm.LPM) (err error) {
+		deleted, err = m.Delete(prefix)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{Deleted: deleted, Generation: generation}, nil
+}
+
+// Snapshot implements LPMServer
+func (s *Server) Snapshot(req *SnapshotRequest, stream LPM_SnapshotServer) error {
+	table := s.table.Load()
+	if table == nil {
+		table = lpm.New()
+	}
+	w := &chunkWriter{stream: stream, buf: make([]byte, 0, snapshotChunkSize)}
+	var err error
+	if req.GetCompress() {
+		_, err = table.PackCompressed(w)
+	} else {
+		_, err = table.PackTo(w)
+	}
+	if err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// update applies fn to the private copy of the table and publishes the result, returning
+// its generation
+func (s *Server) update(fn func(m *lpm.LPM) error) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current := s.table.Load(); s.writer == nil || current != s.published {
+		writer, err := mutableCopy(current)
+		if err != nil {
+			return 0, status.Errorf(codes.Internal, "copying the table: %v", err)
+		}
+		s.writer = writer
+	}
+	if err := fn(s.writer); err != nil {
+		return 0, statusError(err)
+	}
+	s.published = s.writer.Snapshot()
+	s.table.Store(s.published)
+	return s.published.Generation(), nil
+}
+
+// mutableCopy returns a trie answering like m that accepts changes, m itself may be
+// read-only or shared. The copy shares the packed blocks and copies those it changes.
+func mutableCopy(m *lpm.LPM) (*lpm.LPM, error) {
+	if m == nil {
+		return lpm.New(), nil
+	}
+	storage, err := m.PackToSharedStorage()
+	if err != nil {
+		return nil, err
+	}
+	return lpm.NewWithSharedStorage(storage, lpm.SkipChecksum())
+}
+
+// statusError maps errors of inserts and deletes to gRPC status codes
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, lpm.ErrInvalidPrefix):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, lpm.ErrCapacity), errors.Is(err, lpm.ErrMemoryLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, lpm.ErrReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// chunkWriter sends the bytes written to it as SnapshotChunks of snapshotChunkSize
+type chunkWriter struct {
+	stream LPM_SnapshotServer
+	buf    []byte
+}
+
+var _ io.Writer = (*chunkWriter)(nil)
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		k := min(len(p), cap(w.buf)-len(w.buf))
+		w.buf = append(w.buf, p[:k]...)
+		p = p[k:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.stream.Send(&SnapshotChunk{Data: w.buf}); err != nil {
+		return fmt.Errorf("sending snapshot: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
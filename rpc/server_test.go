@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sakateka/lpm"
+)
+
+// newTestClient serves table on an in-memory connection and returns a client of it
+func newTestClient(t *testing.T, table *lpm.Atomic) LPMClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterLPMServer(srv, NewServer(table))
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewLPMClient(conn)
+}
+
+func newTestTable(t *testing.T) *lpm.LPM {
+	t.Helper()
+	table := lpm.New()
+	for prefix, value := range map[string]string{"10.0.0.0/8": "private", "2001:db8::/32": "doc"} {
+		if err := table.Insert(netip.MustParsePrefix(prefix), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return table.Snapshot()
+}
+
+// TestLookup tests single and batch lookups
+func TestLookup(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	client := newTestClient(t, lpm.NewAtomic(table))
+
+	resp, err := client.Lookup(ctx, &LookupRequest{Ip: "10.1.2.3"})
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if resp.GetValue() != "private" || !resp.GetFound() || resp.GetGeneration() != table.Generation() {
+		t.Errorf("Lookup(10.1.2.3) = %v, want private of generation %d", resp, table.Generation())
+	}
+	if _, err := client.Lookup(ctx, &LookupRequest{Ip: "bogus"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Lookup(bogus) error = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	batch, err := client.BatchLookup(ctx, &BatchLookupRequest{Ips: []string{"2001:db8::1", "192.0.2.1", "10.0.0.1"}})
+	if err != nil {
+		t.Fatalf("BatchLookup failed: %v", err)
+	}
+	var got []string
+	for _, result := range batch.GetResults() {
+		got = append(got, result.GetValue())
+	}
+	if len(got) != 3 || got[0] != "doc" || batch.GetResults()[1].GetFound() || got[2] != "private" {
+		t.Errorf("BatchLookup results = %v, want doc, no match, private", batch.GetResults())
+	}
+	if _, err := client.BatchLookup(ctx, &BatchLookupRequest{Ips: []string{"10.0.0.1", "bogus"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchLookup with a bad address error = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	empty := newTestClient(t, &lpm.Atomic{})
+	if resp, err := empty.Lookup(ctx, &LookupRequest{Ip: "10.0.0.1"}); err != nil || resp.GetFound() {
+		t.Errorf("Lookup on an empty table = %v, %v, want no match", resp, err)
+	}
+}
+
+// TestInsertDelete tests that changes are published to the atomic table
+func TestInsertDelete(t *testing.T) {
+	ctx := context.Background()
+	table := lpm.NewAtomic(newTestTable(t))
+	client := newTestClient(t, table)
+
+	inserted, err := client.Insert(ctx, &InsertRequest{Prefix: "10.1.0.0/16", Value: "east"})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if got, _ := table.Lookup(netip.MustParseAddr("10.1.2.3")); got != "east" {
+		t.Errorf("Lookup(10.1.2.3) after Insert = %q, want east", got)
+	}
+	if table.Load().Generation() != inserted.GetGeneration() {
+		t.Errorf("Insert generation = %d, want %d", inserted.GetGeneration(), table.Load().Generation())
+	}
+	if _, err := client.Insert(ctx, &InsertRequest{Prefix: "10.2.0.0/16", Tombstone: true}); err != nil {
+		t.Fatalf("Insert of a tombstone failed: %v", err)
+	}
+	if _, found := table.Lookup(netip.MustParseAddr("10.2.0.1")); found {
+		t.Error("Lookup(10.2.0.1) found the prefix carved out by a tombstone")
+	}
+
+	// A table stored by someone else becomes the base of later changes
+	replaced := lpm.New()
+	if err := replaced.Insert(netip.MustParsePrefix("192.0.2.0/24"), "test-net"); err != nil {
+		t.Fatal(err)
+	}
+	table.Store(replaced)
+	deleted, err := client.Delete(ctx, &DeleteRequest{Prefix: "192.0.2.0/24"})
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if !deleted.GetDeleted() {
+		t.Error("Delete of a stored prefix reported false")
+	}
+	if _, found := table.Lookup(netip.MustParseAddr("192.0.2.1")); found {
+		t.Error("Lookup(192.0.2.1) found a deleted prefix")
+	}
+	if got, _ := table.Lookup(netip.MustParseAddr("10.1.2.3")); got != "" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want the insert dropped along with the replaced table", got)
+	}
+
+	tests := []struct {
+		req  *InsertRequest
+		code codes.Code
+	}{
+		{&InsertRequest{Prefix: "10.0.0.0/33"}, codes.InvalidArgument},
+		{&InsertRequest{Prefix: "10.0.0.0/8", Priority: 256}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if _, err := client.Insert(ctx, tt.req); status.Code(err) != tt.code {
+			t.Errorf("Insert(%v) error = %v, want %v", tt.req, err, tt.code)
+		}
+	}
+}
+
+// TestSnapshot tests streaming the table as packed storage
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	client := newTestClient(t, lpm.NewAtomic(table))
+
+	for _, compress := range []bool{false, true} {
+		stream, err := client.Snapshot(ctx, &SnapshotRequest{Compress: compress})
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		var storage bytes.Buffer
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("receiving snapshot: %v", err)
+			}
+			storage.Write(chunk.GetData())
+		}
+
+		loaded, err := lpm.NewFromReader(&storage)
+		if err != nil {
+			t.Fatalf("loading snapshot (compress %t): %v", compress, err)
+		}
+		if loaded.Fingerprint() != table.Fingerprint() {
+			t.Errorf("snapshot (compress %t) differs from the table", compress)
+		}
+	}
+}
+
+// TestChunkWriter tests that storage larger than a chunk is split
+func TestChunkWriter(t *testing.T) {
+	stream := &recordingStream{}
+	w := &chunkWriter{stream: stream, buf: make([]byte, 0, 4)}
+	for _, p := range []string{"ab", "cdefghij", "k"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"abcd", "efgh", "ijk"}; len(stream.chunks) != len(want) ||
+		stream.chunks[0] != want[0] || stream.chunks[1] != want[1] || stream.chunks[2] != want[2] {
+		t.Errorf("chunks = %q, want %q", stream.chunks, want)
+	}
+}
+
+type recordingStream struct {
+	grpc.ServerStream
+	chunks []string
+}
+
+func (s *recordingStream) Send(chunk *SnapshotChunk) error {
+	s.chunks = append(s.chunks, string(chunk.GetData()))
+	return nil
+}
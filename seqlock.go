@@ -0,0 +1,195 @@
+//go:build !lpm_safe
+
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Live update protocol for storage in writable shared memory. One writer process rewrites
+// the storage in place while reader processes keep looking addresses up, coordinated by
+// the Sequence header field as a seqlock:
+//
+//   - the writer makes Sequence odd, rewrites the storage, then makes it even again;
+//   - a reader reads Sequence, waits while it is odd, looks the address up, and retries
+//     if Sequence changed in the meantime, as the lookup may have seen a partial update.
+//
+// Readers never block the writer. The storage must be allocated with room to grow, as
+// updates are packed into the same memory, e.g. a shared memory segment sized well over
+// EstimatePackedSize and filled with PackInto.
+
+// sequence returns the Sequence field of storage in the current format for atomic access
+func sequence(storage []byte) (*uint64, error) {
+	header, foreign, err := readHeader(storage)
+	if err != nil {
+		return nil, err
+	}
+	if header.Version != currentVersion || foreign {
+		return nil, fmt.Errorf("%w: live updates need storage in the current format and byte order, see MigrateStorage",
+			ErrVersionMismatch)
+	}
+	ptr := unsafe.Pointer(&storage[sequenceOffset(currentVersion)])
+	if uintptr(ptr)%8 != 0 {
+		return nil, fmt.Errorf("storage is not 8-byte aligned")
+	}
+	return (*uint64)(ptr), nil
+}
+
+// SeqWriter updates storage in place for SeqReader instances, possibly in other processes.
+// Only one SeqWriter may update a storage at a time.
+type SeqWriter struct {
+	storage  []byte
+	sequence *uint64
+	lpm      *LPM
+}
+
+// NewSeqWriter returns a writer for the storage, which must be writable memory holding
+// storage in the current format, e.g. a mapped shared memory segment filled with PackInto.
+func NewSeqWriter(storage []byte) (*SeqWriter, error) {
+	seq, err := sequence(storage)
+	if err != nil {
+		return nil, err
+	}
+	w := &SeqWriter{storage: storage, sequence: seq}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// reload loads a private copy of the storage, so packing never reads the memory it writes
+func (w *SeqWriter) reload() error {
+	header, _, err := readHeader(w.storage)
+	if err != nil {
+		return err
+	}
+	size := storageSize(&header)
+	if size > len(w.storage) {
+		return &ErrTruncated{Section: "storage", Need: size, Got: len(w.storage)}
+	}
+	lpm, err := NewWithSharedStorage(alignedClone(w.storage[:size]))
+	if err != nil {
+		return err
+	}
+	w.lpm = lpm
+	return nil
+}
+
+// LPM returns the trie to modify. Changes become visible to readers on Publish.
+// The returned trie is replaced by Publish, so call LPM again afterwards.
+func (w *SeqWriter) LPM() *LPM {
+	return w.lpm
+}
+
+// Publish packs the trie into the storage in place. It fails without touching the storage
+// if the packed trie does not fit.
+func (w *SeqWriter) Publish() error {
+	layout, err := w.lpm.packLayout()
+	if err != nil {
+		return err
+	}
+	if layout.totalSize > len(w.storage) {
+		return fmt.Errorf("packed storage of %d bytes does not fit in %d bytes", layout.totalSize, len(w.storage))
+	}
+
+	seq := atomic.LoadUint64(w.sequence)
+	atomic.StoreUint64(w.sequence, seq+1)
+	layout.header.Sequence = seq + 1
+	err = w.lpm.packInto(w.storage[:layout.totalSize], layout)
+	atomic.StoreUint64(w.sequence, seq+2)
+	if err != nil {
+		return err
+	}
+	return w.reload()
+}
+
+// SeqReader looks addresses up in storage updated in place by a SeqWriter. Values are
+// copied out of the storage, as it may change right after the lookup. A SeqReader is not
+// safe for concurrent use; give every goroutine its own.
+type SeqReader struct {
+	storage  []byte
+	sequence *uint64
+	view     *LPM
+	viewSeq  uint64
+}
+
+// NewSeqReader returns a reader for storage updated by a SeqWriter
+func NewSeqReader(storage []byte) (*SeqReader, error) {
+	seq, err := sequence(storage)
+	if err != nil {
+		return nil, err
+	}
+	return &SeqReader{storage: storage, sequence: seq}, nil
+}
+
+// Lookup returns the value of the longest prefix containing addr, retrying while the
+// writer updates the storage
+func (r *SeqReader) Lookup(addr netip.Addr) (string, bool) {
+	for {
+		seq := atomic.LoadUint64(r.sequence)
+		if seq&1 != 0 {
+			runtime.Gosched()
+			continue
+		}
+
+		// The layout may have changed, load the header again
+		if r.view == nil || seq != r.viewSeq {
+			view, err := NewWithSharedStorage(r.storage, SkipChecksum(), ReadOnly())
+			if err != nil {
+				if atomic.LoadUint64(r.sequence) == seq {
+					// Not a partial update, the storage is broken
+					return "", false
+				}
+				continue
+			}
+			r.view, r.viewSeq = view, seq
+		}
+
+		var value string
+		valueIdx, found := r.view.lookupChecked(addr)
+		if found {
+			var data []byte
+			data, found = r.view.valueBytesByIndex(valueIdx)
+			value = string(data)
+		}
+		if atomic.LoadUint64(r.sequence) == seq {
+			return value, found
+		}
+	}
+}
+
+// lookupChecked is LookupIndex for storage that may be modified concurrently: block
+// references are bounds-checked, so a partial update yields a wrong result rather than
+// a panic, for the caller to detect and retry.
+func (m *LPM) lookupChecked(addr netip.Addr) (int, bool) {
+	if !addr.IsValid() {
+		return 0, false
+	}
+	proto, key := v6LPM, addr.As16()
+	path := key[:]
+	if addr.Is4() {
+		proto, path = v4LPM, key[12:]
+	}
+
+	blockIdx := m.root[proto]
+	for _, inBlockIdx := range path {
+		value := m.getValue(proto, blockIdx, inBlockIdx)
+		switch {
+		case isBlockRef(value):
+			blockIdx = decodeBlockRef(value)
+			if blockIdx >= m.blockCount(proto) {
+				return 0, false
+			}
+		case isInvalid(value):
+			return 0, false
+		default:
+			valueIdx, _ := decodeValue(value)
+			return valueIdx, valueIdx != tombstoneIdx
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,7 @@
+package shm
+
+import "syscall"
+
+func adviseRandom(data []byte) {
+	_ = syscall.Madvise(data, syscall.MADV_RANDOM)
+}
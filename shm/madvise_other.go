@@ -0,0 +1,5 @@
+//go:build unix && !linux
+
+package shm
+
+func adviseRandom(data []byte) {}
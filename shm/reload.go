@@ -0,0 +1,178 @@
+//go:build unix
+
+package shm
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+// Reloader keeps a storage file mapped and maps it again whenever it is replaced, e.g. by
+// lpm.SaveToFile, so services can serve the latest table without reload plumbing:
+//
+//	reloader, err := shm.NewReloader("/var/lib/routes.lpm", 10*time.Second)
+//	if err != nil {
+//	    return err
+//	}
+//	defer reloader.Close()
+//
+//	value, found := reloader.Load().Lookup(addr)
+//
+// The file is polled every interval and reloaded when its identity, size or modification
+// time changes. A table that fails to load is skipped and reported by Err, while Load keeps
+// returning the previous one. A replaced table is unmapped one interval after the swap,
+// so a table returned by Load and the values obtained from it must not be used longer.
+type Reloader struct {
+	path     string
+	interval time.Duration
+	opts     []lpm.Option
+
+	current atomic.Pointer[lpm.LPM]
+
+	mu      sync.Mutex
+	info    os.FileInfo // file the current table was mapped from
+	closer  io.Closer   // mapping of the current table
+	retired []retiredMapping
+	err     error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// retiredMapping is the mapping of a replaced table waiting out the grace period
+type retiredMapping struct {
+	closer io.Closer
+	at     time.Time
+}
+
+// NewReloader maps the storage file at path and starts polling it every interval.
+// opts are passed to lpm.NewWithSharedStorage on every load.
+func NewReloader(path string, interval time.Duration, opts ...lpm.Option) (*Reloader, error) {
+	r := &Reloader{
+		path:     path,
+		interval: interval,
+		opts:     opts,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	go r.run()
+	return r, nil
+}
+
+// Load returns the most recently loaded table. It is safe to call from any goroutine.
+func (r *Reloader) Load() *lpm.LPM {
+	return r.current.Load()
+}
+
+// Err returns the error of the last reload attempt, or nil if it succeeded or found
+// the file unchanged
+func (r *Reloader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Reload checks the file right away and reloads it if it changed, reporting whether
+// a new table was swapped in
+func (r *Reloader) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reloaded, err := r.reload()
+	r.err = err
+	return reloaded, err
+}
+
+func (r *Reloader) reload() (bool, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// Stat the opened file, the path may be replaced again at any time
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if r.info != nil && os.SameFile(r.info, info) && r.info.Size() == info.Size() && r.info.ModTime().Equal(info.ModTime()) {
+		return false, nil
+	}
+
+	table, closer, err := Open(f, r.opts...)
+	if err != nil {
+		return false, err
+	}
+	r.current.Store(table)
+	if r.closer != nil {
+		r.retired = append(r.retired, retiredMapping{closer: r.closer, at: time.Now()})
+	}
+	r.info, r.closer = info, closer
+	return true, nil
+}
+
+// closeRetired unmaps the replaced tables whose grace period is over
+func (r *Reloader) closeRetired(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.retired[:0]
+	for _, m := range r.retired {
+		if now.Sub(m.at) >= r.interval {
+			_ = m.closer.Close()
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	r.retired = kept
+}
+
+func (r *Reloader) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			r.closeRetired(now)
+			_, _ = r.Reload()
+		}
+	}
+}
+
+// Close stops polling and unmaps every table, including the current one.
+// Neither Load nor the tables it returned may be used afterwards.
+func (r *Reloader) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for _, m := range r.retired {
+		if cerr := m.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	r.retired = nil
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+		r.closer = nil
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+//go:build unix
+
+package shm
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sakateka/lpm"
+)
+
+func saveTable(t *testing.T, path, value string) {
+	t.Helper()
+
+	table := lpm.New()
+	table.Insert(netip.MustParsePrefix("10.0.0.0/8"), value)
+	if err := table.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+}
+
+func lookupValue(table *lpm.LPM) string {
+	value, _ := table.Lookup(netip.MustParseAddr("10.1.2.3"))
+	return value
+}
+
+// TestReloader tests that replacing the file swaps in the new table
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	saveTable(t, path, "first")
+
+	reloader, err := NewReloader(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	defer reloader.Close()
+
+	if got := lookupValue(reloader.Load()); got != "first" {
+		t.Fatalf("Lookup = %q, want first", got)
+	}
+
+	saveTable(t, path, "second")
+	deadline := time.Now().Add(5 * time.Second)
+	for lookupValue(reloader.Load()) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("Reloader did not pick up the replaced file")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := reloader.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+// TestReloaderReload tests explicit reloads and failed loads
+func TestReloaderReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	saveTable(t, path, "first")
+
+	reloader, err := NewReloader(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+
+	if reloaded, err := reloader.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of an unchanged file = %v, %v, want false, nil", reloaded, err)
+	}
+
+	saveTable(t, path, "second")
+	if reloaded, err := reloader.Reload(); !reloaded || err != nil {
+		t.Errorf("Reload() of a replaced file = %v, %v, want true, nil", reloaded, err)
+	}
+	if got := lookupValue(reloader.Load()); got != "second" {
+		t.Errorf("Lookup = %q, want second", got)
+	}
+
+	// A broken file is reported and the current table kept
+	if err := os.WriteFile(path+".tmp", []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if reloaded, err := reloader.Reload(); reloaded || err == nil {
+		t.Errorf("Reload() of a broken file = %v, %v, want false and an error", reloaded, err)
+	}
+	if reloader.Err() == nil {
+		t.Error("Err() = nil after a failed reload")
+	}
+	if got := lookupValue(reloader.Load()); got != "second" {
+		t.Errorf("Lookup after a failed reload = %q, want second", got)
+	}
+
+	if err := reloader.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := reloader.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	if _, err := NewReloader(filepath.Join(t.TempDir(), "missing.lpm"), time.Hour); err == nil {
+		t.Error("NewReloader of a missing file succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+//go:build unix
+
+// Package shm maps packed LPM storage files and POSIX shared memory objects into
+// memory and loads them with lpm.NewWithSharedStorage, so every consumer does not
+// have to reimplement the mmap plumbing.
+//
+// The mapping is read-only and shared (MAP_SHARED): all processes mapping the same
+// file use the same physical pages, and changes published by replacing the file are
+// picked up by mapping it again. Values returned by lookups point into the mapping,
+// so they must not be used after the mapping is closed.
+//
+//	table, closer, err := shm.OpenFile("/var/lib/routes.lpm")
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//
+//	value, found := table.Lookup(netip.MustParseAddr("10.1.2.3"))
+//
+// The mapped memory is never written: inserts into the returned LPM copy the blocks
+// they modify into process memory, so each process can add its own overrides on top
+// of the shared table. Pass lpm.ReadOnly to reject inserts instead.
+package shm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/sakateka/lpm"
+)
+
+// mapping is the io.Closer returned to callers, it unmaps the storage exactly once
+type mapping struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func (m *mapping) Close() error {
+	m.once.Do(func() {
+		m.err = syscall.Munmap(m.data)
+		m.data = nil
+	})
+	return m.err
+}
+
+// OpenFile maps the storage file at path read-only and loads it.
+// The returned closer unmaps the storage; the LPM and any value obtained
+// from it must not be used afterwards.
+func OpenFile(path string, opts ...lpm.Option) (*lpm.LPM, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return Open(f, opts...)
+}
+
+// Open maps the storage held by an already opened file read-only and loads it.
+// The file may be closed once Open returns, the mapping stays valid until the
+// returned closer is closed.
+func Open(f *os.File, opts ...lpm.Option) (*lpm.LPM, io.Closer, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil, fmt.Errorf("%s: empty storage", f.Name())
+	}
+	if size != int64(int(size)) {
+		return nil, nil, fmt.Errorf("%s: storage of %d bytes does not fit in the address space", f.Name(), size)
+	}
+
+	// mmap returns page-aligned memory, which satisfies the alignment of the block arrays
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: mmap: %w", f.Name(), err)
+	}
+	m := &mapping{data: data}
+
+	// Lookups touch blocks in no particular order, readahead only wastes page cache
+	adviseRandom(data)
+
+	table, err := lpm.NewWithSharedStorage(data, opts...)
+	if err != nil {
+		_ = m.Close()
+		return nil, nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return table, m, nil
+}
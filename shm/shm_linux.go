@@ -0,0 +1,31 @@
+package shm
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/sakateka/lpm"
+)
+
+// shmDir is where Linux exposes POSIX shared memory objects (shm_open(3))
+var shmDir = "/dev/shm"
+
+// OpenShm maps the POSIX shared memory object name (as passed to shm_open(3),
+// with or without the leading slash) read-only and loads it, see OpenFile.
+func OpenShm(name string, opts ...lpm.Option) (*lpm.LPM, io.Closer, error) {
+	path, err := shmPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return OpenFile(path, opts...)
+}
+
+func shmPath(name string) (string, error) {
+	name = strings.TrimPrefix(name, "/")
+	if name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid shared memory object name %q", name)
+	}
+	return filepath.Join(shmDir, name), nil
+}
@@ -0,0 +1,34 @@
+package shm
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestOpenShm tests mapping a POSIX shared memory object
+func TestOpenShm(t *testing.T) {
+	// Point shmDir at a temporary directory so the test does not depend on /dev/shm
+	orig := shmDir
+	shmDir = t.TempDir()
+	defer func() { shmDir = orig }()
+
+	writeStorage(t, filepath.Join(shmDir, "lpm-test"))
+
+	table, closer, err := OpenShm("/lpm-test")
+	if err != nil {
+		t.Fatalf("OpenShm failed: %v", err)
+	}
+	defer closer.Close()
+
+	if got, found := table.Lookup(netip.MustParseAddr("10.1.2.3")); !found || got != "private" {
+		t.Errorf("Lookup(10.1.2.3) = %q (found=%v), want %q", got, found, "private")
+	}
+
+	for _, name := range []string{"", "/", "a/b", "../etc/passwd"} {
+		if _, _, err := OpenShm(name); err == nil || os.IsNotExist(err) {
+			t.Errorf("OpenShm(%q) error = %v, want invalid name", name, err)
+		}
+	}
+}
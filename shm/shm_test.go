@@ -0,0 +1,143 @@
+//go:build unix
+
+package shm
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+func writeStorage(t *testing.T, path string) {
+	t.Helper()
+
+	table := lpm.New()
+	table.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	table.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+
+	storage, err := table.PackToSharedStorage()
+	if err != nil {
+		t.Fatalf("PackToSharedStorage failed: %v", err)
+	}
+	if err := os.WriteFile(path, storage, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+// TestOpenFile tests mapping a storage file and looking up addresses
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	writeStorage(t, path)
+
+	table, closer, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+
+	tests := []struct{ addr, want string }{
+		{"10.1.2.3", "private"},
+		{"2001:db8::1", "doc"},
+	}
+	for _, tt := range tests {
+		got, found := table.Lookup(netip.MustParseAddr(tt.addr))
+		if !found || got != tt.want {
+			t.Errorf("Lookup(%s) = %q (found=%v), want %q", tt.addr, got, found, tt.want)
+		}
+	}
+
+	if err := closer.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	// Closing twice is harmless
+	if err := closer.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+}
+
+// TestOpenFileInvalid tests that missing, empty and corrupt files are rejected
+func TestOpenFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, _, err := OpenFile(filepath.Join(dir, "missing.lpm")); err == nil {
+		t.Error("OpenFile(missing) succeeded, want error")
+	}
+
+	empty := filepath.Join(dir, "empty.lpm")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, _, err := OpenFile(empty); err == nil {
+		t.Error("OpenFile(empty) succeeded, want error")
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.lpm")
+	if err := os.WriteFile(corrupt, make([]byte, 4096), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, _, err := OpenFile(corrupt); err == nil {
+		t.Error("OpenFile(corrupt) succeeded, want error")
+	}
+}
+
+// TestOpenFileChecksum tests that a corrupted file is rejected unless checksum verification is skipped
+func TestOpenFileChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	writeStorage(t, path)
+
+	storage, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	storage[len(storage)-1] ^= 0xFF
+	if err := os.WriteFile(path, storage, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, _, err := OpenFile(path); err == nil {
+		t.Fatal("OpenFile succeeded on a corrupted file")
+	}
+
+	_, closer, err := OpenFile(path, lpm.SkipChecksum())
+	if err != nil {
+		t.Fatalf("OpenFile(SkipChecksum) failed: %v", err)
+	}
+	if err := closer.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+// TestOpenFileInsert tests that inserts into a mapped table copy blocks instead of faulting
+func TestOpenFileInsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.lpm")
+	writeStorage(t, path)
+
+	table, closer, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer closer.Close()
+
+	if err := table.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if got, _ := table.Lookup(netip.MustParseAddr("10.1.2.3")); got != "dc1" {
+		t.Errorf("Lookup(10.1.2.3) = %q, want dc1", got)
+	}
+
+	// Other mappings of the file do not see the override
+	other, otherCloser, err := OpenFile(path, lpm.ReadOnly())
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer otherCloser.Close()
+	if got, _ := other.Lookup(netip.MustParseAddr("10.1.2.3")); got != "private" {
+		t.Errorf("other Lookup(10.1.2.3) = %q, want private", got)
+	}
+	if err := other.Insert(netip.MustParsePrefix("10.1.0.0/16"), "dc1"); !errors.Is(err, lpm.ErrReadOnly) {
+		t.Errorf("Insert with ReadOnly error = %v, want %v", err, lpm.ErrReadOnly)
+	}
+}
@@ -0,0 +1,28 @@
+package lpm
+
+// slabBlocks is the number of dynamic blocks allocated together. Blocks hold no pointers,
+// so a slab is a single object the garbage collector never scans, instead of one object
+// per block, and blocks allocated one after the other are next to each other in memory.
+const slabBlocks = 64
+
+// allocBlock returns a zeroed block from the current slab of the protocol, starting a new
+// slab when it is used up. Slabs stay alive while any of their blocks is referenced.
+func (t *trie) allocBlock(proto int) *LPMBlock {
+	if len(t.slab[proto]) == 0 {
+		t.slab[proto] = make([]LPMBlock, slabBlocks)
+	}
+	blk := &t.slab[proto][0]
+	t.slab[proto] = t.slab[proto][1:]
+	return blk
+}
+
+// cloneBlocks copies blocks into a single new slab, for passes that rebuild the block array
+func cloneBlocks(blocks []*LPMBlock) []*LPMBlock {
+	slab := make([]LPMBlock, len(blocks))
+	clones := make([]*LPMBlock, len(blocks))
+	for i, blk := range blocks {
+		slab[i] = *blk
+		clones[i] = &slab[i]
+	}
+	return clones
+}
@@ -0,0 +1,126 @@
+package lpm
+
+import (
+	"maps"
+	"slices"
+)
+
+// Snapshot returns an immutable view of the trie as it is now, for the single-writer,
+// many-readers pattern: the writer keeps modifying m while readers look addresses up in
+// the snapshot without locks. Taking a snapshot does not copy the trie; instead the
+// blocks it shares with m are frozen, and the writer copies a frozen block before its
+// first modification, much like inserts copy blocks out of shared storage.
+//
+// Blocks the writer replaced stay reachable only from older snapshots and are reclaimed
+// by the garbage collector once those are dropped. Inserts into the snapshot fail with
+// ErrReadOnly. Snapshot itself must be called by the writer, not concurrently with
+// modifications.
+func (m *LPM) Snapshot() *LPM {
+	for _, proto := range []int{v4LPM, v6LPM} {
+		m.reclaim(proto)
+	}
+
+	snap := &LPM{
+		trie: trie{
+			shared:     m.shared,
+			root:       m.root,
+			copied:     m.copied,
+			generation: m.generation,
+			ipv6Max64:  m.ipv6Max64,
+			stride16:   m.stride16,
+		},
+		sharedValues:         m.sharedValues,
+		sharedValuesSlotSize: m.sharedValuesSlotSize,
+		sharedValueCount:     m.sharedValueCount,
+		sharedValueLenSize:   m.sharedValueLenSize,
+		sharedPriorities:     m.sharedPriorities,
+		values:               make(map[valueKey]int),
+		revValues:            slices.Clip(m.revValues),
+		revPriorities:        slices.Clip(m.revPriorities),
+		valueBytes:           m.valueBytes,
+		metadata:             maps.Clone(m.metadata),
+		readOnly:             true,
+		logger:               m.logger,
+	}
+	m.stride16Shared = m.stride16 != nil
+	for _, proto := range []int{v4LPM, v6LPM} {
+		// The writer only appends past the clipped length, which the snapshot never reads
+		snap.dynamic[proto] = slices.Clip(m.dynamic[proto])
+		// Dead blocks are frozen along with the others: leave them to reclaim instead of reusing them
+		m.copied[proto] += len(m.free[proto])
+		m.free[proto] = nil
+		m.frozen[proto] = m.blockCount(proto)
+		snap.frozen[proto] = m.frozen[proto]
+	}
+	return snap
+}
+
+// reclaim drops the blocks replaced by copies once they outnumber the reachable dynamic
+// blocks: the reachable ones are copied into a fresh block array, so the replaced blocks
+// are only referenced by the snapshots sharing them. Shared storage stays as it is.
+func (t *trie) reclaim(proto int) {
+	sharedLen := len(t.shared[proto])
+	if t.copied[proto] == 0 || t.copied[proto] < len(t.dynamic[proto])/2 {
+		return
+	}
+
+	reachable := make([]bool, t.blockCount(proto))
+	var mark func(blockIdx int)
+	mark = func(blockIdx int) {
+		reachable[blockIdx] = true
+		for _, encoded := range t.getBlockRef(proto, blockIdx) {
+			if isBlockRef(encoded) && !reachable[decodeBlockRef(encoded)] {
+				mark(decodeBlockRef(encoded))
+			}
+		}
+	}
+	mark(t.root[proto])
+
+	// Renumber the reachable dynamic blocks after the shared ones, keeping their order
+	remap := make([]int, len(reachable))
+	blocks := make([]*LPMBlock, 0, len(t.dynamic[proto])-t.copied[proto])
+	copied := 0
+	for blockIdx := range reachable {
+		switch {
+		case blockIdx < sharedLen:
+			remap[blockIdx] = blockIdx
+			if !reachable[blockIdx] {
+				copied++
+			}
+		case reachable[blockIdx]:
+			remap[blockIdx] = sharedLen + len(blocks)
+			blocks = append(blocks, t.getBlockRef(proto, blockIdx))
+		}
+	}
+	blocks = cloneBlocks(blocks)
+	for _, blk := range blocks {
+		for slot, encoded := range blk {
+			if isBlockRef(encoded) {
+				blk[slot] = encodeBlockRef(remap[decodeBlockRef(encoded)])
+			}
+		}
+	}
+
+	t.dynamic[proto] = blocks
+	t.root[proto] = remap[t.root[proto]]
+	t.copied[proto] = copied
+	t.frozen[proto] = 0
+	t.free[proto] = nil
+	if proto == v4LPM {
+		t.refreshStride16(0, stride16Size-1)
+	}
+}
+
+// thaw gives the writer private copies of the frozen dynamic blocks at the same indices,
+// for modifications that rewrite blocks in place rather than through copyShared
+func (t *trie) thaw() {
+	for _, proto := range []int{v4LPM, v6LPM} {
+		frozen := t.frozen[proto] - len(t.shared[proto])
+		if frozen <= 0 {
+			continue
+		}
+		t.dynamic[proto] = slices.Clone(t.dynamic[proto])
+		copy(t.dynamic[proto], cloneBlocks(t.dynamic[proto][:frozen]))
+		t.frozen[proto] = 0
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/sakateka/lpm/sqlite
+
+go 1.25.1
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/sakateka/lpm v0.1.0
+)
+
+require github.com/klauspost/compress v1.18.0 // indirect
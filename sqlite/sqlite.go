@@ -0,0 +1,227 @@
+// Package sqlite keeps the entries of a table in a SQLite database, for small deployments
+// that want durable storage they can query and edit with SQL without a separate build
+// pipeline. The package uses database/sql and takes the database open, so any SQLite
+// driver works, e.g. github.com/mattn/go-sqlite3 or modernc.org/sqlite:
+//
+//	db, err := sql.Open("sqlite3", "routes.db")
+//	store, err := sqlite.Open(ctx, db, "prefixes")
+//	table, err := store.Load(ctx)
+//	...
+//	table.Insert(prefix, "customer-a")
+//	stats, err := store.Sync(ctx, table)
+//
+// Every entry is a row holding its prefix, value, tombstone flag and priority, along with
+// the address range it covers as blobs in network byte order, so SQL can answer lookups as
+// well:
+//
+//	SELECT cidr, value FROM prefixes
+//	WHERE family = 4 AND first <= X'0A010203' AND last >= X'0A010203' AND NOT tombstone
+//	ORDER BY bits DESC LIMIT 1
+//
+// Load and Source rebuild tables from the rows; Sync writes the entries of a table back,
+// changing only the rows that differ.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/netip"
+
+	"github.com/sakateka/lpm"
+)
+
+// Store keeps the entries of a table in a table of a SQLite database
+type Store struct {
+	db    *sql.DB
+	table string // quoted
+}
+
+// Open returns a store keeping entries in the table of db with the given name, creating
+// the table unless it exists
+func Open(ctx context.Context, db *sql.DB, table string) (*Store, error) {
+	if !validName(table) {
+		return nil, fmt.Errorf("sqlite: invalid table name %q", table)
+	}
+	s := &Store{db: db, table: `"` + table + `"`}
+	schema := `CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+		cidr      TEXT PRIMARY KEY,
+		value     TEXT NOT NULL DEFAULT '',
+		tombstone INTEGER NOT NULL DEFAULT 0,
+		priority  INTEGER NOT NULL DEFAULT 0,
+		family    INTEGER,
+		bits      INTEGER,
+		first     BLOB,
+		last      BLOB
+	)`
+	index := `CREATE INDEX IF NOT EXISTS "` + table + `_range" ON ` + s.table + ` (family, first, last)`
+	for _, stmt := range []string{schema, index} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("sqlite: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// validName reports whether name is an identifier that needs no escaping
+func validName(name string) bool {
+	for i, c := range []byte(name) {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// row is an entry as stored
+type row struct {
+	cidr    string // as written, which may differ from the canonical form of the prefix
+	entry   lpm.PrefixValue
+	derived bool // family, bits, first and last are filled in
+}
+
+func (s *Store) rows(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}) ([]row, error) {
+	rows, err := q.QueryContext(ctx, `SELECT cidr, value, tombstone, priority, first IS NOT NULL FROM `+s.table)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %w", err)
+	}
+	defer rows.Close()
+	var result []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.cidr, &r.entry.Value, &r.entry.Tombstone, &r.entry.Priority, &r.derived); err != nil {
+			return nil, fmt.Errorf("sqlite: %w", err)
+		}
+		prefix, err := netip.ParsePrefix(r.cidr)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite: row %q: %w", r.cidr, err)
+		}
+		r.entry.Prefix = prefix.Masked()
+		if r.entry.Tombstone {
+			r.entry.Value = ""
+		}
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: %w", err)
+	}
+	return result, nil
+}
+
+// Entries returns the entries of the rows
+func (s *Store) Entries(ctx context.Context) ([]lpm.PrefixValue, error) {
+	rows, err := s.rows(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]lpm.PrefixValue, len(rows))
+	for i, r := range rows {
+		entries[i] = r.entry
+	}
+	return entries, nil
+}
+
+// Load builds a table of the rows with lpm.Build. The options are passed to lpm.Build.
+func (s *Store) Load(ctx context.Context, opts ...lpm.Option) (*lpm.LPM, error) {
+	entries, err := s.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lpm.Build(entries, opts...)
+}
+
+// Source returns the rows as an lpm.Source, so lpm.NewRefresher picks up changes made to
+// the database with SQL
+func (s *Store) Source() lpm.Source {
+	return s.Entries
+}
+
+// SyncStats counts the rows changed by Sync
+type SyncStats struct {
+	Inserted int
+	Updated  int
+	Deleted  int
+}
+
+// Sync makes the rows hold the entries of m (see lpm.LPM.Entries) in a single transaction,
+// inserting, updating and deleting only the rows that differ, so syncing after a few
+// changes to a large table is cheap
+func (s *Store) Sync(ctx context.Context, m *lpm.LPM) (SyncStats, error) {
+	var stats SyncStats
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, fmt.Errorf("sqlite: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := s.rows(ctx, tx)
+	if err != nil {
+		return stats, err
+	}
+	stored := make(map[netip.Prefix]row, len(rows))
+	for _, r := range rows {
+		if r.cidr != r.entry.Prefix.String() {
+			// Rows written by hand in a different form are replaced by canonical ones
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE cidr = ?`, r.cidr); err != nil {
+				return stats, fmt.Errorf("sqlite: %w", err)
+			}
+			stats.Deleted++
+			continue
+		}
+		stored[r.entry.Prefix] = r
+	}
+
+	upsert, err := tx.PrepareContext(ctx, `INSERT INTO `+s.table+` (cidr, value, tombstone, priority, family, bits, first, last)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (cidr) DO UPDATE SET value = excluded.value, tombstone = excluded.tombstone,
+			priority = excluded.priority, family = excluded.family, bits = excluded.bits,
+			first = excluded.first, last = excluded.last`)
+	if err != nil {
+		return stats, fmt.Errorf("sqlite: %w", err)
+	}
+	defer upsert.Close()
+	for _, e := range m.Entries() {
+		r, ok := stored[e.Prefix]
+		delete(stored, e.Prefix)
+		if ok && r.entry == e && r.derived {
+			continue
+		}
+		family := 4
+		if e.Prefix.Addr().Is6() {
+			family = 6
+		}
+		first, last := e.Prefix.Addr(), lastAddr(e.Prefix)
+		if _, err := upsert.ExecContext(ctx, e.Prefix.String(), e.Value, e.Tombstone, e.Priority,
+			family, e.Prefix.Bits(), first.AsSlice(), last.AsSlice()); err != nil {
+			return stats, fmt.Errorf("sqlite: %s: %w", e.Prefix, err)
+		}
+		if ok {
+			stats.Updated++
+		} else {
+			stats.Inserted++
+		}
+	}
+	for prefix := range stored {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE cidr = ?`, prefix.String()); err != nil {
+			return stats, fmt.Errorf("sqlite: %w", err)
+		}
+		stats.Deleted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return SyncStats{}, fmt.Errorf("sqlite: %w", err)
+	}
+	return stats, nil
+}
+
+// lastAddr returns the last address of a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(addr)*8; bit++ {
+		addr[bit/8] |= 0x80 >> (bit % 8)
+	}
+	last, _ := netip.AddrFromSlice(addr)
+	return last
+}
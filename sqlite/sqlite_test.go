@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sakateka/lpm"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "routes.db"))
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Skipf("SQLite driver unavailable: %v", err) // built without cgo
+	}
+	return db
+}
+
+// TestSyncLoad tests that synced tables load back with tombstones and priorities, and that
+// syncs only change the rows that differ
+func TestSyncLoad(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(ctx, openDB(t), "prefixes")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten")
+	m.InsertWithPriority(netip.MustParsePrefix("10.1.0.0/16"), "override", 5)
+	m.InsertTombstone(netip.MustParsePrefix("10.2.0.0/16"))
+	m.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	stats, err := store.Sync(ctx, m)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if stats != (SyncStats{Inserted: 4}) {
+		t.Errorf("first Sync = %+v, want 4 inserts", stats)
+	}
+
+	loaded, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Fingerprint() != m.Fingerprint() {
+		t.Error("loaded table differs from the synced one")
+	}
+	if got, want := len(loaded.Entries()), len(m.Entries()); got != want {
+		t.Errorf("loaded %d entries, want %d", got, want)
+	}
+
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "TEN")
+	m.Delete(netip.MustParsePrefix("2001:db8::/32"))
+	m.Insert(netip.MustParsePrefix("192.0.2.0/24"), "test")
+	stats, err = store.Sync(ctx, m)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if stats != (SyncStats{Inserted: 1, Updated: 1, Deleted: 1}) {
+		t.Errorf("second Sync = %+v, want one insert, update and delete", stats)
+	}
+	if stats, _ := store.Sync(ctx, m); stats != (SyncStats{}) {
+		t.Errorf("Sync of an unchanged table = %+v, want no changes", stats)
+	}
+}
+
+// TestSQL tests that rows written with SQL are picked up and that the range columns answer
+// lookups
+func TestSQL(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store, err := Open(ctx, db, "routes")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO routes (cidr, value) VALUES ('10.1.2.3/8', 'ten'), ('10.1.0.0/16', 'sub')`); err != nil {
+		t.Fatal(err)
+	}
+
+	refresher, err := lpm.NewRefresher(ctx, store.Source(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewRefresher failed: %v", err)
+	}
+	defer refresher.Close()
+	if got, _ := refresher.Lookup(netip.MustParseAddr("10.200.0.1")); got != "ten" {
+		t.Errorf("Lookup(10.200.0.1) = %q, want %q", got, "ten")
+	}
+
+	// Sync canonicalizes hand-written rows and fills in the range columns
+	stats, err := store.Sync(ctx, refresher.Load())
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if stats != (SyncStats{Inserted: 1, Updated: 1, Deleted: 1}) {
+		t.Errorf("Sync = %+v, want the non-canonical row replaced and the other updated", stats)
+	}
+
+	var cidr, value string
+	err = db.QueryRow(`SELECT cidr, value FROM routes
+		WHERE family = 4 AND first <= ? AND last >= ? AND NOT tombstone
+		ORDER BY bits DESC LIMIT 1`,
+		[]byte{10, 1, 2, 3}, []byte{10, 1, 2, 3}).Scan(&cidr, &value)
+	if err != nil {
+		t.Fatalf("lookup query failed: %v", err)
+	}
+	if cidr != "10.1.0.0/16" || value != "sub" {
+		t.Errorf("lookup query = %s %s, want 10.1.0.0/16 sub", cidr, value)
+	}
+}
+
+func TestOpenInvalidName(t *testing.T) {
+	for _, name := range []string{"", "1routes", `routes"; DROP TABLE x; --`} {
+		if _, err := Open(context.Background(), nil, name); err == nil {
+			t.Errorf("Open(%q) succeeded", name)
+		}
+	}
+}
+
+func TestLastAddr(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"10.0.0.0/8":    "10.255.255.255",
+		"10.1.2.3/32":   "10.1.2.3",
+		"0.0.0.0/0":     "255.255.255.255",
+		"2001:db8::/33": "2001:db8:7fff:ffff:ffff:ffff:ffff:ffff",
+	} {
+		if got := lastAddr(netip.MustParsePrefix(prefix)); got.String() != want {
+			t.Errorf("lastAddr(%s) = %s, want %s", prefix, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+//go:build lpm_safe
+
+package lpm
+
+import "encoding/binary"
+
+// zeroCopy reports whether shared storage is accessed in place rather than decoded
+const zeroCopy = false
+
+// AlignedBuffer returns a zeroed buffer of size bytes for reading storage into. With the
+// lpm_safe build tag storage is decoded rather than accessed in place, so any buffer will do.
+func AlignedBuffer(size int) []byte {
+	return make([]byte, size)
+}
+
+// blocksAligned reports whether the blocks of the storage can be accessed in place,
+// which does not matter when they are decoded
+func blocksAligned(storage []byte) bool {
+	return true
+}
+
+// mapBlocks decodes the count blocks at the start of data into a private copy
+func mapBlocks(data []byte, count uint64) []LPMBlock {
+	blocks := make([]LPMBlock, count)
+	for i := range blocks {
+		blk := data[i*blockSize*4:]
+		for slot := range blocks[i] {
+			blocks[i][slot] = binary.NativeEndian.Uint32(blk[slot*4:])
+		}
+	}
+	return blocks
+}
+
+// appendHeader appends the header in native byte order
+func appendHeader(dst []byte, header *StorageHeader) []byte {
+	dst, _ = binary.Append(dst, binary.NativeEndian, header)
+	return dst
+}
+
+// appendBlock appends the block in native byte order
+func appendBlock(dst []byte, blk *LPMBlock) []byte {
+	for _, slot := range blk {
+		dst = binary.NativeEndian.AppendUint32(dst, slot)
+	}
+	return dst
+}
+
+// bytesString returns b as a string. Without unsafe, that takes a copy.
+func bytesString(b []byte) string {
+	return string(b)
+}
+
+// stringBytes returns s as a slice. Without unsafe, that takes a copy.
+func stringBytes(s string) []byte {
+	return []byte(s)
+}
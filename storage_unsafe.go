@@ -0,0 +1,53 @@
+//go:build !lpm_safe
+
+package lpm
+
+import "unsafe"
+
+// zeroCopy reports whether shared storage is accessed in place rather than decoded
+const zeroCopy = true
+
+// AlignedBuffer returns a zeroed buffer of size bytes aligned for storage, for callers
+// that read storage into memory they allocate themselves. A slice starting at an
+// arbitrary offset of a larger buffer is not aligned, and NewWithSharedStorage has to
+// copy it before use.
+func AlignedBuffer(size int) []byte {
+	// uint64 is only 4-byte aligned on 32-bit platforms, so allocate a spare word to skip to
+	// the next 8-byte boundary
+	words := make([]uint64, (size+storageAlign-1)/storageAlign+1)
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(words))), len(words)*storageAlign)
+	skip := int(-uintptr(unsafe.Pointer(&buf[0])) % storageAlign)
+	return buf[skip : skip+size : skip+size]
+}
+
+// blocksAligned reports whether the blocks of the storage can be accessed in place.
+// Packed storage places blocks at offsets that are multiples of 8, so only the start
+// of the buffer matters.
+func blocksAligned(storage []byte) bool {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(storage)))%unsafe.Alignof(uint32(0)) == 0
+}
+
+// mapBlocks returns the count blocks at the start of data without copying them
+func mapBlocks(data []byte, count uint64) []LPMBlock {
+	return unsafe.Slice((*LPMBlock)(unsafe.Pointer(&data[0])), count)
+}
+
+// appendHeader appends the header in native byte order
+func appendHeader(dst []byte, header *StorageHeader) []byte {
+	return append(dst, unsafe.Slice((*byte)(unsafe.Pointer(header)), unsafe.Sizeof(*header))...)
+}
+
+// appendBlock appends the block in native byte order
+func appendBlock(dst []byte, blk *LPMBlock) []byte {
+	return append(dst, unsafe.Slice((*byte)(unsafe.Pointer(&blk[0])), blockSize*4)...)
+}
+
+// bytesString returns a string sharing the memory of b, which must never be modified
+func bytesString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// stringBytes returns a slice sharing the memory of s, which must not be modified
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
@@ -0,0 +1,27 @@
+package lpm
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// InsertStrict stores value for the prefix like Insert, but fails with ErrInvalidPrefix
+// instead of dropping host bits when the prefix is not masked, e.g. 10.1.2.3/16, which
+// usually means the caller confused an address with a network.
+func (m *LPM) InsertStrict(net netip.Prefix, value string) error {
+	if err := checkMasked(net); err != nil {
+		return err
+	}
+	return m.Insert(net, value)
+}
+
+// checkMasked reports an invalid prefix or one with host bits set
+func checkMasked(net netip.Prefix) error {
+	if !net.IsValid() {
+		return fmt.Errorf("%w: %v", ErrInvalidPrefix, net)
+	}
+	if masked := net.Masked(); masked != net {
+		return fmt.Errorf("%w: %s has host bits set, want %s", ErrInvalidPrefix, net, masked)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package lpm
+
+import "net/netip"
+
+// stride16Size is the number of entries of the IPv4 table indexed by the first two address bytes
+const stride16Size = 1 << 16
+
+// IPv4Stride16 adds a 65536-entry first level to the IPv4 trie, indexed by the first two
+// address bytes (a DIR-16-8-8 layout): prefixes up to /16 resolve in one memory access
+// and any IPv4 lookup in at most three. The table takes 256KB of process memory; it is
+// kept up to date by every modification and rebuilt when a trie is loaded, so storage
+// does not change. Pass it to New or NewWithSharedStorage.
+func IPv4Stride16() Option {
+	return func(o *options) {
+		o.stride16 = true
+	}
+}
+
+// enableStride16 builds the first level table of the IPv4 trie
+func (t *trie) enableStride16() {
+	t.stride16 = make([]uint32, stride16Size)
+	t.refreshStride16(0, stride16Size-1)
+}
+
+// refreshStride16 recomputes the table entries lo to hi from the first two trie levels.
+// Each entry holds the slot a lookup reaches after the second address byte.
+func (t *trie) refreshStride16(lo, hi int) {
+	if t.stride16 == nil {
+		return
+	}
+	if t.stride16Shared {
+		t.stride16 = append([]uint32(nil), t.stride16...)
+		t.stride16Shared = false
+	}
+	root := t.root[v4LPM]
+	for i := lo; i <= hi; i++ {
+		encoded := t.getValue(v4LPM, root, uint8(i>>8))
+		if isBlockRef(encoded) {
+			encoded = t.getValue(v4LPM, decodeBlockRef(encoded), uint8(i))
+		}
+		t.stride16[i] = encoded
+	}
+}
+
+// refreshPrefix16 recomputes the table entries covered by a modified prefix
+func (t *trie) refreshPrefix16(net netip.Prefix) {
+	if t.stride16 == nil || !net.Addr().Is4() {
+		return
+	}
+	addr := net.Masked().Addr().As4()
+	lo := int(addr[0])<<8 | int(addr[1])
+	t.refreshStride16(lo, lo|(1<<(16-min(net.Bits(), 16))-1))
+}
+
+// lookupStride16 looks up an IPv4 address through the first level table
+func (t *trie) lookupStride16(key []byte) (int, bool) {
+	encoded := t.stride16[int(key[0])<<8|int(key[1])]
+	for _, inBlockIdx := range key[2:] {
+		if !isBlockRef(encoded) {
+			break
+		}
+		encoded = t.getValue(v4LPM, decodeBlockRef(encoded), inBlockIdx)
+	}
+	if isBlockRef(encoded) || isInvalid(encoded) {
+		return 0, false
+	}
+	valueIdx, _ := decodeValue(encoded)
+	return valueIdx, valueIdx != tombstoneIdx
+}
@@ -0,0 +1,73 @@
+package lpm
+
+// StructureStats describes the shape of the trie, see LPM.StructureStats
+type StructureStats struct {
+	IPv4 ProtoStructure
+	IPv6 ProtoStructure
+}
+
+// ProtoStructure describes the shape of the IPv4 or IPv6 trie. Slot counts are over the
+// blocks reachable from the root, so blocks left for reuse by deletes are not included.
+type ProtoStructure struct {
+	PrefixLengths  []int // Number of prefixes, as Entries reports them, of every length from 0 to 32 or 128
+	Blocks         int   // Number of blocks reachable from the root
+	ValueSlots     int   // Slots holding a value
+	TombstoneSlots int   // Slots carved out by a tombstone
+	BlockRefSlots  int   // Slots referring to a block below
+	InvalidSlots   int   // Slots covered by no prefix
+	Runs           int   // Runs of neighbouring slots with equal content, what a Poptrie node stores
+}
+
+// FillFactor returns the fraction of slots of the reachable blocks that hold a value or a
+// block reference rather than nothing
+func (s ProtoStructure) FillFactor() float64 {
+	if s.Blocks == 0 {
+		return 0
+	}
+	return float64(s.ValueSlots+s.TombstoneSlots+s.BlockRefSlots) / float64(s.Blocks*blockSize)
+}
+
+// StructureStats walks the trie and returns a histogram of the lengths of the stored
+// prefixes and the kinds of slots its blocks hold, e.g. to tell whether IPv4Stride16 or
+// Poptrie pay off for a dataset: many runs per block favour the flat blocks, few favour
+// a Poptrie. Unlike Stats, it takes time proportional to the size of the trie.
+func (m *LPM) StructureStats() StructureStats {
+	return StructureStats{
+		IPv4: m.protoStructure(v4LPM, 32),
+		IPv6: m.protoStructure(v6LPM, 128),
+	}
+}
+
+func (t *trie) protoStructure(proto int, maxBits int) ProtoStructure {
+	s := ProtoStructure{PrefixLengths: make([]int, maxBits+1)}
+	if t.blockCount(proto) == 0 {
+		return s
+	}
+	for prefix := range t.entries(proto) {
+		s.PrefixLengths[prefix.Bits()]++
+	}
+
+	var count func(blockIdx int)
+	count = func(blockIdx int) {
+		s.Blocks++
+		blk := t.getBlockRef(proto, blockIdx)
+		for slot, encoded := range blk {
+			if slot == 0 || encoded != blk[slot-1] {
+				s.Runs++
+			}
+			switch slotKind(encoded) {
+			case SlotBlockRef:
+				s.BlockRefSlots++
+				count(decodeBlockRef(encoded))
+			case SlotValue:
+				s.ValueSlots++
+			case SlotTombstone:
+				s.TombstoneSlots++
+			case SlotInvalid:
+				s.InvalidSlots++
+			}
+		}
+	}
+	count(t.root[proto])
+	return s
+}
@@ -0,0 +1,78 @@
+package lpm
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// Sync guards an LPM with a read-write mutex, for tables that are modified while other
+// goroutines look addresses up. Lookups share a read lock, so they only wait for
+// modifications. When modifications are rare, rebuilding the table and swapping it in
+// through Atomic keeps lookups free of locks instead.
+//
+// Methods not mirrored here are available through View and Update, which also group
+// several calls under a single lock.
+type Sync struct {
+	mu  sync.RWMutex
+	lpm *LPM
+}
+
+// NewSync returns a Sync guarding m, which must not be used directly afterwards.
+// A nil m starts an empty table.
+func NewSync(m *LPM) *Sync {
+	if m == nil {
+		m = New()
+	}
+	return &Sync{lpm: m}
+}
+
+// Insert stores value for the prefix, see LPM.Insert
+func (s *Sync) Insert(net netip.Prefix, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lpm.Insert(net, value)
+}
+
+// InsertWithPriority stores value for the prefix with a priority, see LPM.InsertWithPriority
+func (s *Sync) InsertWithPriority(net netip.Prefix, value string, priority uint8) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lpm.InsertWithPriority(net, value, priority)
+}
+
+// InsertTombstone carves the prefix out of any broader prefix, see LPM.InsertTombstone
+func (s *Sync) InsertTombstone(net netip.Prefix) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lpm.InsertTombstone(net)
+}
+
+// Delete removes the prefix, see LPM.Delete
+func (s *Sync) Delete(net netip.Prefix) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lpm.Delete(net)
+}
+
+// Lookup returns the value of the longest prefix containing addr, see LPM.Lookup
+func (s *Sync) Lookup(addr netip.Addr) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lpm.Lookup(addr)
+}
+
+// View calls fn with the table under the read lock. fn must not modify the table or
+// keep it after returning.
+func (s *Sync) View(fn func(m *LPM)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.lpm)
+}
+
+// Update calls fn with the table under the write lock and returns its error.
+// fn must not keep the table after returning.
+func (s *Sync) Update(fn func(m *LPM) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.lpm)
+}
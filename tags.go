@@ -0,0 +1,133 @@
+package lpm
+
+import "net/netip"
+
+// Tags is a trie where every prefix carries a 64-bit tag bitmask, and a lookup returns
+// the union (bitwise OR) of the tags of all prefixes covering the address rather than
+// only those of the longest one. This fits multi-source classification such as
+// bogon + cloud + internal without juggling combined string values:
+//
+//	const (
+//	    TagBogon uint64 = 1 << iota
+//	    TagCloud
+//	    TagInternal
+//	)
+//
+//	tags := lpm.NewTags()
+//	tags.Add(netip.MustParsePrefix("10.0.0.0/8"), TagBogon|TagInternal)
+//	tags.Add(netip.MustParsePrefix("10.20.0.0/16"), TagCloud)
+//	tags.LookupTags(netip.MustParseAddr("10.20.1.1")) // TagBogon|TagInternal|TagCloud
+//
+// Tags are additive: adding tags to a prefix never clears bits set before.
+type Tags struct {
+	trie
+
+	values    map[uint64]int // tag set -> index
+	revValues []uint64       // index -> tag set
+}
+
+// NewTags creates an empty tag trie
+func NewTags() *Tags {
+	return &Tags{
+		trie:   newTrie(),
+		values: make(map[uint64]int),
+	}
+}
+
+func (m *Tags) addValue(tags uint64) (int, error) {
+	if valueIdx, ok := m.values[tags]; ok {
+		return valueIdx, nil
+	}
+	valueIdx := len(m.revValues)
+	if err := checkValueCount(valueIdx); err != nil {
+		return 0, err
+	}
+	m.values[tags] = valueIdx
+	m.revValues = append(m.revValues, tags)
+	return valueIdx, nil
+}
+
+// Add sets the given tag bits on the prefix. Every address covered by the prefix,
+// including those covered by more specific prefixes, gains these tags. It fails with
+// ErrInvalidPrefix, or with ErrCapacity when the trie runs out of blocks or tag sets;
+// in the latter case addresses whose merged tag set found no index keep their tags.
+func (m *Tags) Add(net netip.Prefix, tags uint64) error {
+	if tags == 0 {
+		return nil
+	}
+	if err := m.checkInsert(net); err != nil {
+		return err
+	}
+	net = net.Masked()
+	var err error
+	m.apply(net, func(encoded uint32) uint32 {
+		merged, prefixLen := tags, net.Bits()
+		if !isInvalid(encoded) {
+			valueIdx, valuePrefixLen := decodeValue(encoded)
+			merged = m.revValues[valueIdx] | tags
+			if merged == m.revValues[valueIdx] {
+				return encoded
+			}
+			prefixLen = max(valuePrefixLen, prefixLen)
+		}
+		valueIdx, addErr := m.addValue(merged)
+		if addErr != nil {
+			err = addErr
+			return encoded
+		}
+		return encodeValue(valueIdx, prefixLen)
+	})
+	return err
+}
+
+// LookupTags returns the union of the tags of all prefixes containing addr, or 0 if there are none
+func (m *Tags) LookupTags(addr netip.Addr) uint64 {
+	if !addr.IsValid() {
+		return 0
+	}
+	var valueIdx int
+	var ok bool
+	if addr.Is4() {
+		key := addr.As4()
+		valueIdx, ok = m.lookupKey(v4LPM, key[:])
+	} else {
+		key := addr.As16()
+		valueIdx, ok = m.lookupKey(v6LPM, key[:])
+	}
+	if !ok || valueIdx >= len(m.revValues) {
+		return 0
+	}
+	return m.revValues[valueIdx]
+}
+
+// Stats returns statistics about the trie including block counts and storage sizes
+func (m *Tags) Stats() Stats {
+	v4StorageSize := m.storageSize(v4LPM)
+	v6StorageSize := m.storageSize(v6LPM)
+
+	valStorageSize := 0
+	if len(m.revValues) > 0 {
+		// revValues slice: header + inline tag sets
+		valStorageSize += 3 * 8
+		valStorageSize += len(m.revValues) * 8
+		// values map overhead (approximate: map header + entries)
+		valStorageSize += 8 * 8
+		valStorageSize += len(m.revValues) * 16
+	}
+
+	return Stats{
+		IPv4Blocks:      m.blockCount(v4LPM),
+		IPv6Blocks:      m.blockCount(v6LPM),
+		Values:          len(m.revValues),
+		IPv4StorageSize: v4StorageSize,
+		IPv6StorageSize: v6StorageSize,
+		ValuesStorage:   valStorageSize,
+		TotalSize:       v4StorageSize + v6StorageSize + valStorageSize,
+
+		IPv4SharedBlocks:  len(m.shared[v4LPM]),
+		IPv4DynamicBlocks: len(m.dynamic[v4LPM]),
+		IPv6SharedBlocks:  len(m.shared[v6LPM]),
+		IPv6DynamicBlocks: len(m.dynamic[v6LPM]),
+		DynamicValues:     len(m.revValues),
+	}
+}
@@ -0,0 +1,38 @@
+package lpm
+
+import "net/netip"
+
+// InsertTombstone carves the prefix out of any broader prefix covering it: lookups of
+// addresses inside the prefix report no match, unless an even more specific prefix
+// with a value covers them. It follows the same longest-prefix rules as Insert, so
+// inserting a value for exactly the same prefix replaces the tombstone and vice versa.
+// Like Insert, it ignores host bits past the prefix length.
+func (m *LPM) InsertTombstone(net netip.Prefix) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkInsert(net); err != nil {
+		return m.rejected(net, err)
+	}
+	if err := m.checkMemory(net, nil); err != nil {
+		return m.rejected(net, err)
+	}
+	oldIdx, oldFound := m.watchedValue(net)
+	delete(m.expiries, net.Masked())
+	m.insert(net, tombstoneIdx, 0, m.priorityByIndex)
+	m.warnCapacity()
+	if len(m.watchers) > 0 {
+		m.notify(net, oldIdx, oldFound, tombstoneIdx, true)
+	}
+	return nil
+}
+
+// InsertTombstone carves the prefix out of any broader prefix covering it, failing like
+// LPM.InsertTombstone
+func (m *Numeric[T]) InsertTombstone(net netip.Prefix) error {
+	if err := m.checkInsert(net); err != nil {
+		return err
+	}
+	m.insert(net, tombstoneIdx, 0, noPriority)
+	return nil
+}
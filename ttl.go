@@ -0,0 +1,117 @@
+package lpm
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InsertWithTTL stores value for the prefix like Insert, and marks it to be removed by
+// the first ExpireNow sweep at or after ttl from now. Inserting or deleting the prefix
+// again without a TTL makes it permanent. Expiry times are kept in memory only and are
+// not packed with the storage.
+func (m *LPM) InsertWithTTL(net netip.Prefix, value string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid TTL for %s: %v", net, ttl)
+	}
+	if err := m.Insert(net, value); err != nil {
+		return err
+	}
+	if m.expiries == nil {
+		m.expiries = make(map[netip.Prefix]time.Time)
+	}
+	m.expiries[net.Masked()] = time.Now().Add(ttl)
+	return nil
+}
+
+// ExpireNow deletes the prefixes inserted with InsertWithTTL whose TTL ran out at now,
+// uncovering the prefixes they shadowed as Delete does, and returns how many were
+// deleted. Prefixes are deleted in order of expiry.
+func (m *LPM) ExpireNow(now time.Time) (int, error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	var expired []netip.Prefix
+	for net, deadline := range m.expiries {
+		if !deadline.After(now) {
+			expired = append(expired, net)
+		}
+	}
+	slices.SortFunc(expired, func(a, b netip.Prefix) int {
+		if c := m.expiries[a].Compare(m.expiries[b]); c != 0 {
+			return c
+		}
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Bits(), b.Bits())
+	})
+
+	deleted := 0
+	for _, net := range expired {
+		ok, err := m.Delete(net)
+		if err != nil {
+			return deleted, err
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// NextExpiry returns the earliest expiry time of the prefixes inserted with InsertWithTTL,
+// or false if there are none
+func (m *LPM) NextExpiry() (time.Time, bool) {
+	var next time.Time
+	for _, deadline := range m.expiries {
+		if next.IsZero() || deadline.Before(next) {
+			next = deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// InsertWithTTL stores value for the prefix until it expires, see LPM.InsertWithTTL
+func (s *Sync) InsertWithTTL(net netip.Prefix, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lpm.InsertWithTTL(net, value, ttl)
+}
+
+// ExpireNow deletes the prefixes expired at now, see LPM.ExpireNow
+func (s *Sync) ExpireNow(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lpm.ExpireNow(now)
+}
+
+// ExpireEvery starts a goroutine calling ExpireNow every interval and returns a function
+// that stops it. Expired prefixes stay visible to lookups until the next sweep.
+func (s *Sync) ExpireEvery(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_, _ = s.ExpireNow(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
@@ -0,0 +1,74 @@
+package lpm
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// InsertBytes inserts a prefix with a raw binary value, e.g. protobuf-encoded metadata.
+// The payload is copied; values are deduplicated by content just like string values.
+func (m *LPM) InsertBytes(net netip.Prefix, value []byte) error {
+	return m.Insert(net, string(value))
+}
+
+// LookupBytes returns the raw value of the longest prefix containing addr without copying it.
+// The returned slice aliases the value table (or the shared storage) and must not be modified.
+func (m *LPM) LookupBytes(addr netip.Addr) ([]byte, bool) {
+	valueIdx, ok := m.LookupIndex(addr)
+	if !ok {
+		return nil, false
+	}
+	return m.valueBytesByIndex(valueIdx)
+}
+
+// valueBytesByIndex is the []byte counterpart of getValueByIndex
+func (m *LPM) valueBytesByIndex(valueIdx int) ([]byte, bool) {
+	if valueIdx < m.sharedValueCount {
+		return m.getSharedValue(valueIdx)
+	}
+	value, ok := m.getValueByIndex(valueIdx)
+	if !ok {
+		return nil, false
+	}
+	return stringBytes(value), true
+}
+
+// Values returns an iterator over the distinct values referenced by at least one prefix,
+// in value index order. Values that were overwritten and are no longer reachable are skipped.
+func (m *LPM) Values() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		remap, _ := m.liveValues(m.valueCount())
+		// The same value may be stored several times with different priorities
+		seen := make(map[string]struct{})
+		for valueIdx, newIdx := range remap {
+			if newIdx < 0 {
+				continue
+			}
+			value, ok := m.getValueByIndex(valueIdx)
+			if !ok {
+				continue
+			}
+			if _, dup := seen[value]; dup {
+				continue
+			}
+			seen[value] = struct{}{}
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// PrefixCountByValue returns the number of prefixes pointing at each value.
+// Prefixes completely covered by more specific ones are not counted, see Entries.
+func (m *LPM) PrefixCountByValue() map[string]int {
+	counts := make(map[string]int)
+	for _, proto := range []int{v4LPM, v6LPM} {
+		for _, valueIdx := range m.entries(proto) {
+			if value, ok := m.getValueByIndex(valueIdx); ok {
+				counts[value]++
+			}
+		}
+	}
+	return counts
+}
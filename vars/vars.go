@@ -0,0 +1,69 @@
+// Package vars publishes the statistics of an LPM table under expvar, for services that
+// already expose /debug/vars and do not run Prometheus:
+//
+//	table := lpm.NewAtomic(initial)
+//	vars.Publish("routes", table.Load)
+//
+// The table is read whenever the variable is, so tables swapped in by an lpm.Atomic or
+// lpm.Refresher are picked up. Importing this package registers the /debug/vars handler
+// of expvar on http.DefaultServeMux, which is why package lpm does not do it itself.
+package vars
+
+import (
+	"expvar"
+
+	"github.com/sakateka/lpm"
+)
+
+// Option configures the published variable
+type Option func(*options)
+
+type options struct {
+	hits func() *lpm.HitCounter
+	top  int
+}
+
+// WithHitCounter adds the top most-hit prefixes of the hit counter hits returns, see
+// lpm.HitCounter.TopPrefixes. hits may return nil while counting is off.
+func WithHitCounter(hits func() *lpm.HitCounter, top int) Option {
+	return func(o *options) {
+		o.hits = hits
+		o.top = top
+	}
+}
+
+// Table is the value of the published variable, marshaled to JSON on every read
+type Table struct {
+	Stats       lpm.Stats        `json:"stats"`
+	Generation  uint64           `json:"generation"`
+	TopPrefixes []lpm.PrefixHits `json:"top_prefixes,omitempty"`
+}
+
+// Func returns an expvar.Func reporting the Table of the table source returns, or null
+// while source returns nil, for publishing under a name of the caller's choice or in an
+// expvar.Map
+func Func(source func() *lpm.LPM, opts ...Option) expvar.Func {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func() any {
+		m := source()
+		if m == nil {
+			return nil
+		}
+		table := Table{Stats: m.Stats(), Generation: m.Generation()}
+		if o.hits != nil {
+			if hits := o.hits(); hits != nil {
+				table.TopPrefixes = hits.TopPrefixes(o.top)
+			}
+		}
+		return table
+	}
+}
+
+// Publish publishes the Table of the table source returns under name. Like expvar.Publish,
+// it panics if the name is already registered.
+func Publish(name string, source func() *lpm.LPM, opts ...Option) {
+	expvar.Publish(name, Func(source, opts...))
+}
@@ -0,0 +1,54 @@
+package vars
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sakateka/lpm"
+)
+
+func TestPublish(t *testing.T) {
+	m := lpm.New()
+	m.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	m.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	table := lpm.NewAtomic(m)
+
+	var counting atomic.Pointer[lpm.HitCounter]
+	Publish("lpm_test_routes", table.Load, WithHitCounter(counting.Load, 1))
+
+	read := func() Table {
+		t.Helper()
+		var got Table
+		if err := json.Unmarshal([]byte(expvar.Get("lpm_test_routes").String()), &got); err != nil {
+			t.Fatalf("unmarshal published table: %v", err)
+		}
+		return got
+	}
+
+	got := read()
+	if got.Stats.IPv4Blocks != m.Stats().IPv4Blocks || got.Generation != m.Generation() {
+		t.Errorf("published %+v, want the stats and generation of the table", got)
+	}
+	if got.TopPrefixes != nil {
+		t.Errorf("published top prefixes %v while counting is off", got.TopPrefixes)
+	}
+
+	hitCounter := m.HitCounter()
+	counting.Store(hitCounter)
+	hitCounter.Lookup(netip.MustParseAddr("10.1.2.3"))
+	hitCounter.Lookup(netip.MustParseAddr("10.1.2.4"))
+	hitCounter.Lookup(netip.MustParseAddr("10.2.0.1"))
+	got = read()
+	want := lpm.PrefixHits{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Value: "b", Hits: 2}
+	if len(got.TopPrefixes) != 1 || got.TopPrefixes[0] != want {
+		t.Errorf("published top prefixes %v, want [%v]", got.TopPrefixes, want)
+	}
+
+	table.Store(nil)
+	if s := expvar.Get("lpm_test_routes").String(); s != "null" {
+		t.Errorf("published %s without a table, want null", s)
+	}
+}
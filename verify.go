@@ -0,0 +1,94 @@
+package lpm
+
+import "fmt"
+
+// Verify walks the trie and checks its structural invariants, returning an error wrapping
+// ErrCorrupt for the first violation found:
+//   - the root and every block reference point to an existing block, and no block is
+//     referenced below the last byte of an address or from different depths, which
+//     also rules out cycles;
+//   - every value slot encodes a prefix length valid for its protocol and no longer
+//     than the path leading to the slot, and a value index that resolves to a value
+//     or is a tombstone;
+//   - every value in shared storage fits its slot.
+//
+// Lookups trust these invariants, so run Verify on storage from untrusted sources
+// before serving it. Verify reads every reachable block once.
+func (m *LPM) Verify() error {
+	if len(m.revValues) != len(m.revPriorities) {
+		return fmt.Errorf("%w: %d dynamic values with %d priorities", ErrCorrupt, len(m.revValues), len(m.revPriorities))
+	}
+	for valueIdx := range m.sharedValueCount {
+		if _, ok := m.getSharedValue(valueIdx); !ok {
+			return fmt.Errorf("%w: shared value %d does not fit its slot", ErrCorrupt, valueIdx)
+		}
+	}
+
+	valueCount := m.valueCount()
+	for _, proto := range []int{v4LPM, v6LPM} {
+		if err := m.verifyTrie(proto, valueCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyTrie checks the blocks reachable from the root of the protocol trie
+func (t *trie) verifyTrie(proto int, valueCount int) error {
+	name, addrLen := "IPv4", 4
+	if proto == v6LPM {
+		name, addrLen = "IPv6", 16
+	}
+	count := t.blockCount(proto)
+	if count == 0 {
+		return nil
+	}
+	root := t.root[proto]
+	if root < 0 || root >= count {
+		return fmt.Errorf("%w: %s root block %d out of range [0, %d)", ErrCorrupt, name, root, count)
+	}
+
+	// depths[i] is one more than the depth block i was first reached at, 0 if not yet reached
+	depths := make([]uint8, count)
+	var verify func(blockIdx, depth int) error
+	verify = func(blockIdx, depth int) error {
+		switch seen := int(depths[blockIdx]) - 1; {
+		case seen == depth:
+			return nil
+		case seen >= 0:
+			return fmt.Errorf("%w: %s block %d referenced at depths %d and %d", ErrCorrupt, name, blockIdx, seen, depth)
+		}
+		depths[blockIdx] = uint8(depth + 1)
+
+		for slot, encoded := range t.getBlockRef(proto, blockIdx) {
+			switch {
+			case isInvalid(encoded):
+			case isBlockRef(encoded):
+				childIdx := decodeBlockRef(encoded)
+				if childIdx >= count {
+					return fmt.Errorf("%w: %s block %d slot %d refers to block %d out of range [0, %d)",
+						ErrCorrupt, name, blockIdx, slot, childIdx, count)
+				}
+				if depth+1 >= addrLen {
+					return fmt.Errorf("%w: %s block %d slot %d refers to a block below the last address byte",
+						ErrCorrupt, name, blockIdx, slot)
+				}
+				if err := verify(childIdx, depth+1); err != nil {
+					return err
+				}
+			default:
+				valueIdx, prefixLen := decodeValue(encoded)
+				if prefixLen < 0 || prefixLen > (depth+1)*8 {
+					return fmt.Errorf("%w: %s block %d slot %d at depth %d holds prefix length %d",
+						ErrCorrupt, name, blockIdx, slot, depth, prefixLen)
+				}
+				if valueIdx != tombstoneIdx && valueIdx >= valueCount {
+					return fmt.Errorf("%w: %s block %d slot %d refers to value %d out of range [0, %d)",
+						ErrCorrupt, name, blockIdx, slot, valueIdx, valueCount)
+				}
+			}
+		}
+		return nil
+	}
+	return verify(root, 0)
+}
@@ -0,0 +1,90 @@
+package lpm
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// PrefixValue is a prefix paired with the value it maps to.
+// Tombstone is set for prefixes inserted with InsertTombstone, in which case Value is empty.
+// Priority is the priority the prefix was inserted with, see InsertWithPriority.
+type PrefixValue struct {
+	Prefix    netip.Prefix `json:"cidr"`
+	Value     string       `json:"value"`
+	Tombstone bool         `json:"tombstone,omitempty"`
+	Priority  uint8        `json:"priority,omitempty"`
+}
+
+// walkValues calls fn for every value slot reachable from the root block of the given protocol trie.
+// The address passed to fn has the path bytes leading to the slot filled in and the rest zeroed.
+func (t *trie) walkValues(proto int, fn func(addr [16]byte, depth int, encoded uint32)) {
+	if len(t.shared[proto])+len(t.dynamic[proto]) == 0 {
+		return
+	}
+	var path [16]byte
+	t.walkBlock(proto, t.root[proto], &path, 0, fn)
+}
+
+func (t *trie) walkBlock(proto int, blockIdx int, path *[16]byte, depth int, fn func(addr [16]byte, depth int, encoded uint32)) {
+	block := t.getBlockRef(proto, blockIdx)
+	for slot, encoded := range block {
+		path[depth] = byte(slot)
+		if isBlockRef(encoded) {
+			t.walkBlock(proto, decodeBlockRef(encoded), path, depth+1, fn)
+		} else if !isInvalid(encoded) {
+			fn(*path, depth, encoded)
+		}
+	}
+	path[depth] = 0
+}
+
+// prefixFromPath builds the prefix that produced a value slot found while walking the trie.
+func prefixFromPath(proto int, addr [16]byte, prefixLen int) netip.Prefix {
+	var ip netip.Addr
+	if proto == v4LPM {
+		ip = netip.AddrFrom4([4]byte(addr[:4]))
+	} else {
+		ip = netip.AddrFrom16(addr)
+	}
+	prefix, _ := ip.Prefix(prefixLen)
+	return prefix
+}
+
+// entries reconstructs the set of prefixes that are still visible in the trie, together with their
+// value indices. Prefixes fully shadowed by more specific ones are not reported.
+func (t *trie) entries(proto int) map[netip.Prefix]int {
+	result := make(map[netip.Prefix]int)
+	t.walkValues(proto, func(addr [16]byte, depth int, encoded uint32) {
+		valueIdx, prefixLen := decodeValue(encoded)
+		result[prefixFromPath(proto, addr, prefixLen)] = valueIdx
+	})
+	return result
+}
+
+// comparePrefix orders prefixes by address family, then address, then prefix length.
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// Entries returns the prefixes stored in the trie with their values, sorted by address family,
+// address and prefix length. Prefixes completely covered by more specific ones are omitted.
+func (m *LPM) Entries() []PrefixValue {
+	var result []PrefixValue
+	for _, proto := range []int{v4LPM, v6LPM} {
+		for prefix, valueIdx := range m.entries(proto) {
+			if valueIdx == tombstoneIdx {
+				result = append(result, PrefixValue{Prefix: prefix, Tombstone: true})
+				continue
+			}
+			value, _ := m.getValueByIndex(valueIdx)
+			result = append(result, PrefixValue{Prefix: prefix, Value: value, Priority: m.priorityByIndex(valueIdx)})
+		}
+	}
+	slices.SortFunc(result, func(a, b PrefixValue) int {
+		return comparePrefix(a.Prefix, b.Prefix)
+	})
+	return result
+}
@@ -0,0 +1,114 @@
+package lpm
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// EventKind tells what happened to a prefix
+type EventKind uint8
+
+const (
+	EventInsert EventKind = iota + 1 // a new prefix was inserted
+	EventUpdate                      // an existing prefix got a different value or priority
+	EventDelete                      // a prefix was deleted
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventInsert:
+		return "insert"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Event describes a change of a prefix. Old is empty for inserts and New for deletes;
+// the tombstone flags are set when the old or new entry is a tombstone.
+type Event struct {
+	Kind         EventKind
+	Prefix       netip.Prefix
+	Old          string
+	New          string
+	OldTombstone bool
+	NewTombstone bool
+}
+
+// watchBuffer is the number of events a watcher may fall behind before inserts block
+const watchBuffer = 1024
+
+// Watch returns a channel receiving an Event for every Insert, InsertWithPriority,
+// InsertTombstone and Delete that changes a prefix, in order, so downstream components
+// can follow the trie incrementally. Inserting a prefix with the value and priority it
+// already has sends nothing. The channel is buffered; once a watcher falls behind by the
+// buffer size, modifications block until it catches up, so every watcher must keep
+// receiving until it calls Unwatch. Like inserts, Watch is not safe for concurrent use.
+func (m *LPM) Watch() <-chan Event {
+	ch := make(chan Event, watchBuffer)
+	m.watchers = append(m.watchers, ch)
+	return ch
+}
+
+// Unwatch stops sending events to a channel returned by Watch and closes it
+func (m *LPM) Unwatch(ch <-chan Event) {
+	for i, watcher := range m.watchers {
+		if watcher == ch {
+			m.watchers = slices.Delete(m.watchers, i, i+1)
+			close(watcher)
+			return
+		}
+	}
+}
+
+// exactValue returns the value index of the prefix itself, not of a broader one covering
+// it, even if other prefixes shadow it completely
+func (m *LPM) exactValue(net netip.Prefix) (int, bool) {
+	m.loadPrefixes()
+	return m.prefixes.get(net.Masked())
+}
+
+// watchedValue is exactValue when there are watchers to notify, so tries without
+// watchers do not pay for the lookup
+func (m *LPM) watchedValue(net netip.Prefix) (int, bool) {
+	if len(m.watchers) == 0 {
+		return 0, false
+	}
+	return m.exactValue(net)
+}
+
+// notify sends an event for the prefix changing from oldIdx, if present, to newIdx, if present
+func (m *LPM) notify(net netip.Prefix, oldIdx int, oldFound bool, newIdx int, newFound bool) {
+	if oldFound && newFound && oldIdx == newIdx {
+		return
+	}
+
+	event := Event{Prefix: net.Masked(), Kind: EventUpdate}
+	switch {
+	case !oldFound:
+		event.Kind = EventInsert
+	case !newFound:
+		event.Kind = EventDelete
+	}
+	if oldFound {
+		event.Old, event.OldTombstone = m.eventValue(oldIdx)
+	}
+	if newFound {
+		event.New, event.NewTombstone = m.eventValue(newIdx)
+	}
+	for _, watcher := range m.watchers {
+		watcher <- event
+	}
+}
+
+// eventValue returns the value of an index for an event. Values in shared storage are
+// copied, as the event may outlive the storage.
+func (m *LPM) eventValue(valueIdx int) (string, bool) {
+	if valueIdx == tombstoneIdx {
+		return "", true
+	}
+	value, _ := m.valueBytesByIndex(valueIdx)
+	return string(value), false
+}
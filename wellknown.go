@@ -0,0 +1,144 @@
+package lpm
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// Classes of the ranges of NewWellKnown, stored as their values
+const (
+	WellKnownUnspecified   = "unspecified"   // 0.0.0.0/32, ::/128
+	WellKnownLoopback      = "loopback"      // RFC 1122, RFC 4291
+	WellKnownPrivate       = "private"       // RFC 1918, unique local addresses of RFC 4193
+	WellKnownCGNAT         = "cgnat"         // shared address space of RFC 6598
+	WellKnownLinkLocal     = "link-local"    // RFC 3927, RFC 4291
+	WellKnownDocumentation = "documentation" // RFC 5737, RFC 3849, RFC 9637
+	WellKnownBenchmarking  = "benchmarking"  // RFC 2544, RFC 5180
+	WellKnownTranslation   = "translation"   // NAT64, 6to4 and Teredo
+	WellKnownMulticast     = "multicast"     // RFC 5771, RFC 4291
+	WellKnownBroadcast     = "broadcast"     // limited broadcast of RFC 919
+	WellKnownReserved      = "reserved"      // other special-purpose and reserved ranges
+)
+
+// wellKnown lists the ranges of the IANA IPv4 and IPv6 special-purpose address registries,
+// multicast, and the address space IANA reserves. Nested ranges override the broader ones.
+var wellKnown = []struct {
+	cidr  string
+	class string
+}{
+	{"0.0.0.0/8", WellKnownReserved},
+	{"0.0.0.0/32", WellKnownUnspecified},
+	{"10.0.0.0/8", WellKnownPrivate},
+	{"100.64.0.0/10", WellKnownCGNAT},
+	{"127.0.0.0/8", WellKnownLoopback},
+	{"169.254.0.0/16", WellKnownLinkLocal},
+	{"172.16.0.0/12", WellKnownPrivate},
+	{"192.0.0.0/24", WellKnownReserved},
+	{"192.0.2.0/24", WellKnownDocumentation},
+	{"192.88.99.0/24", WellKnownTranslation},
+	{"192.168.0.0/16", WellKnownPrivate},
+	{"198.18.0.0/15", WellKnownBenchmarking},
+	{"198.51.100.0/24", WellKnownDocumentation},
+	{"203.0.113.0/24", WellKnownDocumentation},
+	{"224.0.0.0/4", WellKnownMulticast},
+	{"240.0.0.0/4", WellKnownReserved},
+	{"255.255.255.255/32", WellKnownBroadcast},
+
+	{"::/8", WellKnownReserved},
+	{"::/128", WellKnownUnspecified},
+	{"::1/128", WellKnownLoopback},
+	{"64:ff9b::/96", WellKnownTranslation},
+	{"64:ff9b:1::/48", WellKnownTranslation},
+	{"100::/64", WellKnownReserved},
+	{"2001::/23", WellKnownReserved},
+	{"2001::/32", WellKnownTranslation},
+	{"2001:2::/48", WellKnownBenchmarking},
+	{"2001:db8::/32", WellKnownDocumentation},
+	{"2002::/16", WellKnownTranslation},
+	{"3fff::/20", WellKnownDocumentation},
+	{"5f00::/16", WellKnownReserved},
+	{"fc00::/7", WellKnownPrivate},
+	{"fe80::/10", WellKnownLinkLocal},
+	{"fec0::/10", WellKnownReserved},
+	{"ff00::/8", WellKnownMulticast},
+}
+
+// WellKnownEntries returns the entries of NewWellKnown, e.g. to merge them into another
+// table
+func WellKnownEntries() []PrefixValue {
+	entries := make([]PrefixValue, len(wellKnown))
+	for i, r := range wellKnown {
+		entries[i] = PrefixValue{Prefix: netip.MustParsePrefix(r.cidr), Value: r.class}
+	}
+	return entries
+}
+
+// NewWellKnown returns a table classifying the special-purpose ranges of IPv4 and IPv6 as
+// one of the WellKnown classes, so addresses can be told apart as private, CGNAT,
+// documentation and so on without a dataset of one's own:
+//
+//	wellKnown, err := lpm.NewWellKnown()
+//	class, found := wellKnown.Lookup(addr) // "private", true for 192.168.1.1
+//
+// Addresses outside the ranges, i.e. global unicast ones, are not found. The dataset
+// follows the IANA registries at the time of the release; ParseSpecialRegistry reads the
+// current registries instead. The options are passed to Build.
+func NewWellKnown(opts ...Option) (*LPM, error) {
+	return Build(WellKnownEntries(), opts...)
+}
+
+// ParseSpecialRegistry reads the CSV form of an IANA special-purpose address registry,
+// iana-ipv4-special-registry-1.csv or iana-ipv6-special-registry-1.csv, returning an
+// entry per address block with its name as the value, e.g. "Private-Use" or
+// "Documentation"
+func ParseSpecialRegistry(r io.Reader) ([]PrefixValue, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	blockColumn, nameColumn := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case "Address Block":
+			blockColumn = i
+		case "Name":
+			nameColumn = i
+		}
+	}
+	if blockColumn < 0 || nameColumn < 0 {
+		return nil, errors.New("special-purpose registry lacks the Address Block and Name columns")
+	}
+
+	var entries []PrefixValue
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		// Cells may hold several blocks, and footnote references such as "[2]"
+		for _, block := range strings.Split(record[blockColumn], ",") {
+			block, _, _ = strings.Cut(strings.TrimSpace(block), " ")
+			if block == "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(block)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w: %w", line, ErrInvalidPrefix, err)
+			}
+			entries = append(entries, PrefixValue{Prefix: prefix.Masked(), Value: strings.TrimSpace(record[nameColumn])})
+		}
+	}
+}